@@ -123,6 +123,24 @@ function ensureSummaryModelColumn(db: DatabaseSync): void {
   }
 }
 
+// created_seq orders summaries written in the same created_at second. rowid
+// cannot be used for that because VACUUM may renumber it, so existing rows
+// are seeded from rowid once and every later insert takes MAX + 1.
+function ensureSummaryCreatedSeqColumn(db: DatabaseSync): void {
+  const summaryColumns = db.prepare(`PRAGMA table_info(summaries)`).all() as SummaryColumnInfo[];
+  const hasCreatedSeq = summaryColumns.some((col) => col.name === "created_seq");
+  if (!hasCreatedSeq) {
+    db.exec(`ALTER TABLE summaries ADD COLUMN created_seq INTEGER`);
+  }
+  db.exec(`
+    UPDATE summaries
+    SET created_seq = (SELECT COALESCE(MAX(created_seq), 0) FROM summaries) + rowid
+    WHERE created_seq IS NULL
+  `);
+  // Inserts take MAX(created_seq) + 1; the index keeps that lookup cheap.
+  db.exec(`CREATE INDEX IF NOT EXISTS summaries_created_seq_idx ON summaries (created_seq)`);
+}
+
 function ensureCompactionTelemetryColumns(db: DatabaseSync): void {
   const telemetryColumns = db.prepare(`PRAGMA table_info(conversation_compaction_telemetry)`).all() as SummaryColumnInfo[];
   const hasConsecutiveColdObservations = telemetryColumns.some(
@@ -1146,6 +1164,7 @@ export function runLcmMigrations(
       descendant_token_count INTEGER NOT NULL DEFAULT 0,
       source_message_token_count INTEGER NOT NULL DEFAULT 0,
       created_at TEXT NOT NULL DEFAULT (datetime('now')),
+      created_seq INTEGER,
       file_ids TEXT NOT NULL DEFAULT '[]'
     );
 
@@ -1395,6 +1414,9 @@ export function runLcmMigrations(
       ensureSummaryMetadataColumns(db),
     );
     runMigrationStep("ensureSummaryModelColumn", log, () => ensureSummaryModelColumn(db));
+    runMigrationStep("ensureSummaryCreatedSeqColumn", log, () =>
+      ensureSummaryCreatedSeqColumn(db),
+    );
     runMigrationStep("ensureMessageIdentityHashColumn", log, () =>
       ensureMessageIdentityHashColumn(db),
     );
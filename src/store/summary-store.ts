@@ -642,9 +642,11 @@ export class SummaryStore {
           descendant_count,
           descendant_token_count,
           source_message_token_count,
-          model
+          model,
+          created_seq
         )
-       VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+       VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+         (SELECT COALESCE(MAX(created_seq), 0) + 1 FROM summaries))`,
       )
       .run(
         input.summaryId,
@@ -371,6 +371,11 @@ describe("runLcmMigrations summary depth backfill", () => {
     expect(summaryColumns.some((column) => column.name === "descendant_count")).toBe(true);
     expect(summaryColumns.some((column) => column.name === "descendant_token_count")).toBe(true);
     expect(summaryColumns.some((column) => column.name === "source_message_token_count")).toBe(true);
+    expect(summaryColumns.some((column) => column.name === "created_seq")).toBe(true);
+    const unseeded = db
+      .prepare(`SELECT COUNT(*) AS count FROM summaries WHERE created_seq IS NULL`)
+      .get() as { count: number };
+    expect(unseeded.count).toBe(0);
 
     const migrationStateRows = db
       .prepare(
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// defaultAgentDefaultsPath maps agent names to option defaults for backfill,
+// rewrite, and interactive rewrites.
+const defaultAgentDefaultsPath = "~/.config/lcm-tui/agents.json"
+
+// agentDefaults is one agent's entry in agents.json. Zero values (and a nil
+// FreshTail or RewriteFreshTail) mean the command's own default applies.
+// FreshTail is backfill's compaction tail; RewriteFreshTail is the smaller
+// count of leaf messages rewrite marks [most recent].
+type agentDefaults struct {
+	LeafChunkTokens       int    `json:"leafChunkTokens,omitempty"`
+	LeafTargetTokens      int    `json:"leafTargetTokens,omitempty"`
+	CondensedTargetTokens int    `json:"condensedTargetTokens,omitempty"`
+	LeafFanout            int    `json:"leafFanout,omitempty"`
+	CondensedFanout       int    `json:"condensedFanout,omitempty"`
+	HardFanout            int    `json:"hardFanout,omitempty"`
+	FreshTail             *int   `json:"freshTail,omitempty"`
+	RewriteFreshTail      *int   `json:"rewriteFreshTail,omitempty"`
+	Provider              string `json:"provider,omitempty"`
+	Model                 string `json:"model,omitempty"`
+	BaseURL               string `json:"baseUrl,omitempty"`
+	PromptDir             string `json:"promptDir,omitempty"`
+}
+
+// rewriteFreshTail is the interactive rewrite fresh-tail count (0 when
+// unset).
+func (d agentDefaults) rewriteFreshTail() int {
+	if d.RewriteFreshTail == nil {
+		return 0
+	}
+	return *d.RewriteFreshTail
+}
+
+// promptDir is the expanded prompt override directory ("" when unset).
+func (d agentDefaults) promptDir() string {
+	if d.PromptDir == "" {
+		return ""
+	}
+	return lcm.ExpandHomePath(d.PromptDir)
+}
+
+func (d agentDefaults) validate() error {
+	for name, value := range map[string]int{
+		"leafChunkTokens":       d.LeafChunkTokens,
+		"leafTargetTokens":      d.LeafTargetTokens,
+		"condensedTargetTokens": d.CondensedTargetTokens,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s must be >= 0 (0 keeps the default)", name)
+		}
+	}
+	for name, value := range map[string]int{
+		"leafFanout":      d.LeafFanout,
+		"condensedFanout": d.CondensedFanout,
+		"hardFanout":      d.HardFanout,
+	} {
+		if value != 0 && value < 2 {
+			return fmt.Errorf("%s must be >= 2", name)
+		}
+	}
+	if d.FreshTail != nil && *d.FreshTail < 0 {
+		return errors.New("freshTail must be >= 0")
+	}
+	if d.RewriteFreshTail != nil && *d.RewriteFreshTail < 0 {
+		return errors.New("rewriteFreshTail must be >= 0")
+	}
+	return nil
+}
+
+// loadAgentDefaultsFile reads agents.json. A missing file yields no defaults.
+func loadAgentDefaultsFile(path string) (map[string]agentDefaults, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read agent defaults %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var byAgent map[string]agentDefaults
+	if err := decoder.Decode(&byAgent); err != nil {
+		return nil, fmt.Errorf("parse agent defaults %s: %w", path, err)
+	}
+	for agent, defaults := range byAgent {
+		if err := defaults.validate(); err != nil {
+			return nil, fmt.Errorf("agent defaults %s: agent %q: %w", path, agent, err)
+		}
+	}
+	return byAgent, nil
+}
+
+// resolveAgentDefaults returns the agents.json entry for agent, if any.
+func resolveAgentDefaults(agent string) (agentDefaults, bool, error) {
+	agent = strings.TrimSpace(agent)
+	if agent == "" {
+		return agentDefaults{}, false, nil
+	}
+	byAgent, err := loadAgentDefaultsFile(lcm.ExpandHomePath(defaultAgentDefaultsPath))
+	if err != nil {
+		return agentDefaults{}, false, err
+	}
+	defaults, ok := byAgent[agent]
+	return defaults, ok, nil
+}
+
+// agentFromSessionKey extracts the agent name from an OpenClaw session key
+// of the form agent:<name>:<rest>.
+func agentFromSessionKey(sessionKey string) string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(sessionKey), "agent:")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, ":")
+	return strings.TrimSpace(name)
+}
+
+// resolveConversationAgent finds the agent that owns a conversation, first
+// from its session_key and then by locating the session JSONL on disk. It
+// returns "" when the owner cannot be determined.
+func resolveConversationAgent(ctx context.Context, db *sql.DB, agentsDir string, conversationID int64) (string, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	query := `SELECT session_id, '' FROM conversations WHERE conversation_id = ?`
+	if caps.HasColumn("conversations", "session_key") {
+		query = `SELECT session_id, COALESCE(session_key, '') FROM conversations WHERE conversation_id = ?`
+	}
+
+	var sessionID, sessionKey string
+	if err := db.QueryRowContext(ctx, query, conversationID).Scan(&sessionID, &sessionKey); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("query session for conversation %d: %w", conversationID, err)
+	}
+	if agent := agentFromSessionKey(sessionKey); agent != "" {
+		return agent, nil
+	}
+	if sessionID == "" {
+		return "", nil
+	}
+
+	// A missing or unreadable agents dir just means no defaults apply.
+	agents, err := loadAgents(agentsDir)
+	if err != nil {
+		return "", nil
+	}
+	for _, agent := range agents {
+		if _, err := os.Stat(filepath.Join(agent.path, "sessions", sessionID+".jsonl")); err == nil {
+			return agent.name, nil
+		}
+	}
+	return "", nil
+}
+
+// explicitFlags records which flags were passed on the command line so
+// agent defaults never override them.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// applyBackfillAgentDefaults fills backfill options the user did not set.
+func applyBackfillAgentDefaults(opts *backfillOptions, defaults agentDefaults) {
+	set := opts.explicitFlags
+	applyIntDefault(&opts.leafChunkTokens, defaults.LeafChunkTokens, set["leaf-chunk-tokens"])
+	applyIntDefault(&opts.leafTargetTokens, defaults.LeafTargetTokens, set["leaf-target-tokens"])
+	applyIntDefault(&opts.condensedTargetToken, defaults.CondensedTargetTokens, set["condensed-target-tokens"])
+	applyIntDefault(&opts.leafFanout, defaults.LeafFanout, set["leaf-fanout"])
+	applyIntDefault(&opts.condensedFanout, defaults.CondensedFanout, set["condensed-fanout"])
+	applyIntDefault(&opts.hardFanout, defaults.HardFanout, set["hard-fanout"])
+	if defaults.FreshTail != nil && !set["fresh-tail"] {
+		opts.freshTailCount = *defaults.FreshTail
+	}
+	applyStringDefault(&opts.provider, defaults.Provider)
+	applyStringDefault(&opts.model, defaults.Model)
+	applyStringDefault(&opts.baseURL, defaults.BaseURL)
+	if opts.promptDir == "" {
+		opts.promptDir = defaults.promptDir()
+	}
+}
+
+// applyRewriteAgentDefaults fills rewrite options the user did not set.
+func applyRewriteAgentDefaults(opts *rewriteOptions, defaults agentDefaults) {
+	if defaults.RewriteFreshTail != nil && !opts.explicitFlags["fresh-tail"] {
+		opts.freshTail = *defaults.RewriteFreshTail
+	}
+	applyStringDefault(&opts.provider, defaults.Provider)
+	applyStringDefault(&opts.model, defaults.Model)
+	applyStringDefault(&opts.baseURL, defaults.BaseURL)
+	if opts.promptDir == "" {
+		opts.promptDir = defaults.promptDir()
+	}
+}
+
+func applyIntDefault(target *int, value int, explicit bool) {
+	if value > 0 && !explicit {
+		*target = value
+	}
+}
+
+func applyStringDefault(target *string, value string) {
+	if *target == "" {
+		*target = strings.TrimSpace(value)
+	}
+}
+
+func describeAgentDefaults(agent string) string {
+	return fmt.Sprintf("Agent defaults: %s (from %s)", agent, defaultAgentDefaultsPath)
+}
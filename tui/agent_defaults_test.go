@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeAgentDefaultsFile(t *testing.T, home, content string) {
+	t.Helper()
+	dir := filepath.Join(home, ".config", "lcm-tui")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "agents.json"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write agents.json: %v", err)
+	}
+}
+
+func TestResolveAgentDefaultsLoadsEntryAndToleratesMissingFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if _, ok, err := resolveAgentDefaults("coder"); err != nil || ok {
+		t.Fatalf("expected no defaults without agents.json, got ok=%v err=%v", ok, err)
+	}
+
+	writeAgentDefaultsFile(t, home, `{"coder": {"leafFanout": 6, "freshTail": 0, "model": "claude-x", "promptDir": "~/prompts/coder"}}`)
+	defaults, ok, err := resolveAgentDefaults("coder")
+	if err != nil || !ok {
+		t.Fatalf("expected coder defaults, got ok=%v err=%v", ok, err)
+	}
+	if defaults.LeafFanout != 6 || defaults.FreshTail == nil || *defaults.FreshTail != 0 || defaults.Model != "claude-x" {
+		t.Fatalf("unexpected defaults %+v", defaults)
+	}
+	if got, want := defaults.promptDir(), filepath.Join(home, "prompts", "coder"); got != want {
+		t.Fatalf("expected expanded prompt dir %q, got %q", want, got)
+	}
+	if _, ok, _ := resolveAgentDefaults("chat"); ok {
+		t.Fatal("expected no defaults for an unlisted agent")
+	}
+}
+
+func TestLoadAgentDefaultsFileAcceptsTokenTargetKeys(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	writeAgentDefaultsFile(t, home, `{"coder": {"leafChunkTokens": 30000, "leafTargetTokens": 1500, "condensedTargetTokens": 2500}}`)
+	defaults, ok, err := resolveAgentDefaults("coder")
+	if err != nil || !ok {
+		t.Fatalf("expected coder defaults, got ok=%v err=%v", ok, err)
+	}
+	if defaults.LeafChunkTokens != 30000 || defaults.LeafTargetTokens != 1500 || defaults.CondensedTargetTokens != 2500 {
+		t.Fatalf("unexpected defaults %+v", defaults)
+	}
+
+	writeAgentDefaultsFile(t, home, `{"coder": {"condensedTargetTokens": -1}}`)
+	if _, _, err := resolveAgentDefaults("coder"); err == nil || !strings.Contains(err.Error(), "condensedTargetTokens must be >= 0") {
+		t.Fatalf("expected validation error naming condensedTargetTokens, got %v", err)
+	}
+}
+
+func TestLoadAgentDefaultsFileRejectsTyposAndBadValues(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	writeAgentDefaultsFile(t, home, `{"coder": {"leafFanot": 6}}`)
+	if _, _, err := resolveAgentDefaults("coder"); err == nil || !strings.Contains(err.Error(), "leafFanot") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+
+	writeAgentDefaultsFile(t, home, `{"coder": {"hardFanout": 1}}`)
+	if _, _, err := resolveAgentDefaults("coder"); err == nil || !strings.Contains(err.Error(), `agent "coder": hardFanout must be >= 2`) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestApplyBackfillAgentDefaultsKeepsExplicitFlags(t *testing.T) {
+	opts, err := parseBackfillArgs([]string{"coder", "session_abc", "--leaf-fanout", "3", "--model", "flag-model"})
+	if err != nil {
+		t.Fatalf("parse backfill args: %v", err)
+	}
+	freshTail := 4
+	applyBackfillAgentDefaults(&opts, agentDefaults{
+		LeafFanout:      10,
+		CondensedFanout: 7,
+		FreshTail:       &freshTail,
+		Provider:        "openai",
+		Model:           "agent-model",
+	})
+
+	if opts.leafFanout != 3 || opts.model != "flag-model" {
+		t.Fatalf("explicit flags were overridden: fanout=%d model=%q", opts.leafFanout, opts.model)
+	}
+	if opts.condensedFanout != 7 || opts.freshTailCount != 4 || opts.provider != "openai" {
+		t.Fatalf("defaults not applied: %+v", opts)
+	}
+}
+
+func TestApplyRewriteAgentDefaultsKeepsExplicitFreshTail(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"1", "--all", "--fresh-tail", "0"})
+	if err != nil {
+		t.Fatalf("parse rewrite args: %v", err)
+	}
+	rewriteFreshTail := 12
+	applyRewriteAgentDefaults(&opts, agentDefaults{RewriteFreshTail: &rewriteFreshTail, Provider: "openai"})
+	if opts.freshTail != 0 {
+		t.Fatalf("expected explicit --fresh-tail 0 to win, got %d", opts.freshTail)
+	}
+	if opts.provider != "openai" {
+		t.Fatalf("expected provider default, got %q", opts.provider)
+	}
+}
+
+func TestApplyRewriteAgentDefaultsIgnoresCompactionFreshTail(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"1", "--all"})
+	if err != nil {
+		t.Fatalf("parse rewrite args: %v", err)
+	}
+	freshTail := 48
+	applyRewriteAgentDefaults(&opts, agentDefaults{FreshTail: &freshTail})
+	if opts.freshTail != 0 {
+		t.Fatalf("compaction freshTail should not set rewrite's marker count, got %d", opts.freshTail)
+	}
+
+	rewriteFreshTail := 4
+	applyRewriteAgentDefaults(&opts, agentDefaults{FreshTail: &freshTail, RewriteFreshTail: &rewriteFreshTail})
+	if opts.freshTail != 4 {
+		t.Fatalf("expected rewriteFreshTail 4, got %d", opts.freshTail)
+	}
+}
+
+func TestResolveConversationAgentUsesSessionKeyThenSessionFile(t *testing.T) {
+	db := newBackfillTestDB(t)
+	agentsDir := t.TempDir()
+	mustExec(t, db, `ALTER TABLE conversations ADD COLUMN session_key TEXT`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, session_key) VALUES (1, 'sess-key', 'agent:coder:main')`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (2, 'sess-file')`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (3, 'sess-unknown')`)
+
+	sessionsDir := filepath.Join(agentsDir, "chat", "sessions")
+	if err := os.MkdirAll(sessionsDir, 0o755); err != nil {
+		t.Fatalf("mkdir sessions: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sessionsDir, "sess-file.jsonl"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+
+	ctx := context.Background()
+	for id, want := range map[int64]string{1: "coder", 2: "chat", 3: "", 99: ""} {
+		got, err := resolveConversationAgent(ctx, db, agentsDir, id)
+		if err != nil {
+			t.Fatalf("resolve agent for %d: %v", id, err)
+		}
+		if got != want {
+			t.Fatalf("conversation %d: expected agent %q, got %q", id, want, got)
+		}
+	}
+}
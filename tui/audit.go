@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// version is recorded in every audit entry lcm-tui writes.
+func init() {
+	lcm.ToolVersion = version
+}
+
+type auditOptions struct {
+	limit      int
+	jsonOutput bool
+}
+
+// loadAuditLog returns conversationID's audit entries oldest first. With a
+// positive limit only the most recent limit entries are returned. A DB that
+// was never changed by lcm-tui has no audit_log and yields no entries.
+func loadAuditLog(ctx context.Context, q sqlQueryer, conversationID int64, limit int) ([]lcm.AuditEntry, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("check audit_log table: %w", err)
+	}
+	if !caps.HasTable("audit_log") {
+		return nil, nil
+	}
+	query := `
+		SELECT audit_id, created_at, command, conversation_id, summary_ids, tokens_before, tokens_after, tool_version, detail
+		FROM audit_log
+		WHERE conversation_id = ?
+		ORDER BY audit_id DESC
+	`
+	args := []any{conversationID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit_log for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var entries []lcm.AuditEntry
+	for rows.Next() {
+		var (
+			entry      lcm.AuditEntry
+			summaryIDs string
+		)
+		if err := rows.Scan(&entry.ID, &entry.CreatedAt, &entry.Command, &entry.ConversationID, &summaryIDs,
+			&entry.TokensBefore, &entry.TokensAfter, &entry.ToolVersion, &entry.Detail); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		if err := json.Unmarshal([]byte(summaryIDs), &entry.SummaryIDs); err != nil {
+			return nil, fmt.Errorf("decode audit entry %d summary IDs: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit_log: %w", err)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// formatAuditSummaryIDs lists up to three IDs and counts the rest, so bulk
+// entries such as transplants stay on one row.
+func formatAuditSummaryIDs(ids []string) string {
+	const shown = 3
+	if len(ids) <= shown {
+		return strings.Join(ids, ",")
+	}
+	return fmt.Sprintf("%s +%d", strings.Join(ids[:shown], ","), len(ids)-shown)
+}
+
+// runAuditCommand executes the standalone audit CLI path.
+func runAuditCommand(args []string) error {
+	opts, conversationID, err := parseAuditArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	entries, err := loadAuditLog(context.Background(), db, conversationID, opts.limit)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		if entries == nil {
+			entries = []lcm.AuditEntry{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			return fmt.Errorf("encode audit log: %w", err)
+		}
+		return nil
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No audit entries for conversation %d.\n", conversationID)
+		return nil
+	}
+
+	fmt.Printf("Audit log for conversation %d (%d entries, oldest first)\n\n", conversationID, len(entries))
+	table := cliTable{columns: []cliTableColumn{
+		{header: "id", align: cliAlignRight},
+		{header: "created_at"},
+		{header: "command"},
+		{header: "tokens", align: cliAlignRight},
+		{header: "delta", align: cliAlignRight},
+		{header: "summaries"},
+		{header: "version"},
+		{header: "detail", flex: true},
+	}}
+	for _, entry := range entries {
+		table.addRow(
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt,
+			entry.Command,
+			fmt.Sprintf("%d->%d", entry.TokensBefore, entry.TokensAfter),
+			fmt.Sprintf("%+d", entry.TokensAfter-entry.TokensBefore),
+			formatAuditSummaryIDs(entry.SummaryIDs),
+			entry.ToolVersion,
+			sanitizeForTerminal(entry.Detail),
+		)
+	}
+	for _, line := range table.render(resolveCLIOutputStyle()) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func parseAuditArgs(args []string) (auditOptions, int64, error) {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	limit := fs.Int("limit", 0, "show only the most recent n entries")
+	jsonOutput := fs.Bool("json", false, "print the entries as JSON")
+
+	normalizedArgs, err := normalizeAuditArgs(args)
+	if err != nil {
+		return auditOptions{}, 0, fmt.Errorf("%w\n%s", err, auditUsageText())
+	}
+	if err := fs.Parse(normalizedArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return auditOptions{}, 0, errors.New(auditUsageText())
+		}
+		return auditOptions{}, 0, fmt.Errorf("%w\n%s", err, auditUsageText())
+	}
+	if fs.NArg() != 1 {
+		return auditOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", auditUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return auditOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), auditUsageText())
+	}
+	if *limit < 0 {
+		return auditOptions{}, 0, fmt.Errorf("--limit must be >= 0\n%s", auditUsageText())
+	}
+	return auditOptions{limit: *limit, jsonOutput: *jsonOutput}, conversationID, nil
+}
+
+func normalizeAuditArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--limit":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func auditUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui audit <conversation_id> [--limit <n>] [--json]
+
+Lists every change lcm-tui made to a conversation, oldest first: rewrite,
+repair, doctor, dissolve, fold, prune, transplant, backfill imports and
+compaction, merge, recount, and check-context fixes. Each entry records the
+time, command, affected summary IDs, token_count before and after, and the
+lcm-tui version. Entries are written in the same transaction as the change
+into an append-only audit_log table. Read-only.
+
+Flags:
+  --limit <n>   show only the most recent n entries (default: all)
+  --json        print the entries as JSON
+`)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogRecordsMutationsInTheirTransaction(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_a', 1, 'leaf', 0, 'an old summary that is long enough', 900, '2026-03-01T10:00:00Z'),
+			('sum_zero', 1, 'leaf', 0, 'twelve chars', 0, '2026-03-01T10:01:00Z')
+	`)
+
+	if entries, err := loadAuditLog(ctx, db, 1, 0); err != nil || len(entries) != 0 {
+		t.Fatalf("expected no entries before the table exists, got %+v (%v)", entries, err)
+	}
+
+	forced := defaultRewriteGuard()
+	forced.force = true
+	if err := applySummaryRewrite(ctx, db, "sum_a", "new", 1, 1200, forced); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if _, err := recountSummaryTokens(ctx, db, []string{"sum_zero"}); err != nil {
+		t.Fatalf("recount: %v", err)
+	}
+	// A rejected rewrite writes nothing, so it must not be logged either.
+	if err := applySummaryRewrite(ctx, db, "sum_missing", "new", 1, 1200, forced); err == nil {
+		t.Fatal("expected rewrite of a missing summary to fail")
+	}
+
+	entries, err := loadAuditLog(ctx, db, 1, 0)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	rewrite := entries[0]
+	if rewrite.Command != "rewrite" || rewrite.TokensBefore != 900 || rewrite.TokensAfter != 1 ||
+		!reflect.DeepEqual(rewrite.SummaryIDs, []string{"sum_a"}) || rewrite.Detail != "guard forced" || rewrite.ToolVersion != version {
+		t.Fatalf("unexpected rewrite entry %+v", rewrite)
+	}
+	if entries[1].Command != "recount" || entries[1].TokensBefore != 0 || entries[1].TokensAfter != 3 {
+		t.Fatalf("unexpected recount entry %+v", entries[1])
+	}
+
+	latest, err := loadAuditLog(ctx, db, 1, 1)
+	if err != nil || len(latest) != 1 || latest[0].Command != "recount" {
+		t.Fatalf("--limit should keep the most recent entry, got %+v (%v)", latest, err)
+	}
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM audit_log`); err == nil || !strings.Contains(err.Error(), "append-only") {
+		t.Fatalf("expected audit_log deletes to be rejected, got %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE audit_log SET command = 'edited'`); err == nil {
+		t.Fatal("expected audit_log updates to be rejected")
+	}
+}
+
+func TestParseAuditArgsAndSummaryIDFormatting(t *testing.T) {
+	opts, conversationID, err := parseAuditArgs([]string{"44", "--limit", "5", "--json"})
+	if err != nil || conversationID != 44 || opts.limit != 5 || !opts.jsonOutput {
+		t.Fatalf("unexpected parse result %+v %d (%v)", opts, conversationID, err)
+	}
+	if _, _, err := parseAuditArgs([]string{"44", "--limit", "-1"}); err == nil {
+		t.Fatal("expected a negative --limit to be rejected")
+	}
+	if got := formatAuditSummaryIDs([]string{"a", "b", "c", "d", "e"}); got != "a,b,c +2" {
+		t.Fatalf("unexpected summary ID formatting %q", got)
+	}
+}
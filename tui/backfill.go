@@ -1,20 +1,18 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 type backfillOptions struct {
@@ -22,8 +20,13 @@ type backfillOptions struct {
 	dryRun               bool
 	singleRoot           bool
 	recompact            bool
+	simulateLive         bool // --simulate-live: real summarize calls in a --recompact dry run
+	verify               bool
+	normalizeWhitespace  bool
 	agent                string
 	sessionID            string
+	sessionPath          string
+	encoding             string
 	title                string
 	transplantTo         int64
 	hasTransplantTarget  bool
@@ -37,88 +40,76 @@ type backfillOptions struct {
 	promptDir            string
 	provider             string
 	model                string
+	modelFallbacks       []string
 	baseURL              string
-}
-
-type backfillMessage struct {
-	seq       int
-	role      string
-	content   string
-	createdAt string
-}
-
-type backfillImportPlan struct {
-	conversationID int64
-	hasData        bool
-	messageCount   int
-	contextCount   int
-	summaryCount   int
-}
-
-type backfillImportResult struct {
-	conversationID int64
-	imported       bool
-	messageCount   int
-}
-
-type backfillCompactionStats struct {
-	leafPasses      int
-	condensedPasses int
-	rootFoldPasses  int
-}
-
-type backfillContextItem struct {
-	ordinal    int64
-	itemType   string
-	messageID  sql.NullInt64
-	summaryID  sql.NullString
-	tokenCount int
-	depth      int
-}
-
-type backfillSummaryRecord struct {
-	summaryID   string
-	content     string
-	tokenCount  int
-	depth       int
-	kind        string
-	createdAt   string
-	earliestAt  string
-	latestAt    string
-	descendants int
-}
-
-type backfillSessionInput struct {
-	agent       string
-	sessionID   string
-	title       string
-	sessionPath string
-	messages    []backfillMessage
-}
-
-type backfillSummarizeFn func(ctx context.Context, prompt string, targetTokens int) (string, error)
-
-func runBackfillCommand(args []string) error {
+	httpTimeout          time.Duration
+	generation           summaryGenerationSettings // --temperature / --max-output-tokens
+	explicitFlags        map[string]bool
+	roleMap              map[string]string // --role-map source role -> stored role
+	seqRange             lcm.SeqRange      // --start-seq/--end-seq staged import window
+	reportFile           string            // --report-file JSON (or .md) run report; "" disables
+	redactor             *sourceRedactor   // --redact; nil leaves summary sources unchanged
+	backupDB             bool              // --backup-db: copy lcm.db before --apply writes
+	smartChunk           bool              // --smart-chunk: end leaf chunks at user turns or time gaps
+	ignorePatterns       ignorePatternList // --ignore globs for the --recompact simulation
+	// prevContext is --prev-context-count/--prev-context-depth: prior
+	// summaries placed in each leaf and d1 prompt. Depth -1 means any depth
+	// for leaves and the condensed depth for d1.
+	prevContext lcm.PreviousContextOptions
+}
+
+type backfillSummarizeFn = lcm.SummarizerFunc
+
+func runBackfillCommand(args []string) (err error) {
 	opts, err := parseBackfillArgs(args)
 	if err != nil {
 		return err
 	}
+	report := newRunReport(opts.reportFile, "backfill", args, opts.apply)
+	defer func() { err = report.close(err) }()
 
 	paths, err := resolveDataPaths()
 	if err != nil {
 		return err
 	}
 
-	sessionPath, err := resolveBackfillSessionPath(paths.agentsDir, opts.agent, opts.sessionID)
+	defaults, hasDefaults, err := resolveAgentDefaults(opts.agent)
+	if err != nil {
+		return err
+	}
+	if hasDefaults {
+		applyBackfillAgentDefaults(&opts, defaults)
+		fmt.Println(describeAgentDefaults(opts.agent))
+	}
+
+	sessionPath := opts.sessionPath
+	if sessionPath != "" {
+		err = checkBackfillSessionFile(sessionPath)
+	} else {
+		sessionPath, err = resolveBackfillSessionPath(paths.agentsDir, opts.agent, opts.sessionID)
+	}
 	if err != nil {
 		return err
 	}
-	messages, err := parseBackfillSessionFile(sessionPath)
+	decoder, err := newSessionDecoder(opts.encoding)
 	if err != nil {
 		return err
 	}
+	messages, err := parseBackfillSessionFile(sessionPath, opts.normalizeWhitespace, decoder, opts.roleMap)
+	if err != nil {
+		return err
+	}
+	if note := decoder.summary(sessionPath); note != "" {
+		fmt.Println(note)
+	}
 	if len(messages) == 0 {
-		return fmt.Errorf("session %s has no message rows to backfill", opts.sessionID)
+		if decoder.parseErrors > 0 && decoder.parseErrors == decoder.lines {
+			return fmt.Errorf("session %s has no message rows to backfill: every line failed to parse as JSON (%s); check the file or --encoding", opts.sessionID, decoder.parseDiagnostic())
+		}
+		return fmt.Errorf("session %s has no message rows to backfill: %s", opts.sessionID, decoder.parseDiagnostic())
+	}
+	if decoder.parseErrors > 0 {
+		fmt.Printf("Warning: %s; unparseable lines are skipped\n", decoder.parseDiagnostic())
 	}
 
 	db, err := openLCMDB(paths.lcmDBPath)
@@ -127,32 +118,62 @@ func runBackfillCommand(args []string) error {
 	}
 	defer db.Close()
 
+	if opts.verify {
+		return runBackfillVerify(context.Background(), db, opts.sessionID, sessionPath, messages)
+	}
+
 	settings := resolveTUISummaryRuntimeSettings(paths, opts.provider, opts.model, opts.baseURL, "", "")
 	opts.provider = settings.provider
 	opts.model = settings.model
 	opts.baseURL = settings.baseURL
+	if err := opts.generation.checkProvider(opts.provider); err != nil {
+		return err
+	}
+	printStubSummarizerNotice(opts.provider)
 
 	ctx := context.Background()
-	input := backfillSessionInput{
-		agent:       opts.agent,
-		sessionID:   opts.sessionID,
-		title:       opts.title,
-		sessionPath: sessionPath,
-		messages:    messages,
+	input := lcm.BackfillInput{
+		Agent:       opts.agent,
+		SessionID:   opts.sessionID,
+		Title:       opts.title,
+		SessionPath: sessionPath,
+		Messages:    messages,
+		SeqRange:    opts.seqRange,
 	}
 
 	if opts.dryRun {
-		plan, err := inspectBackfillImportPlan(ctx, db, input.sessionID)
+		plan, err := lcm.InspectBackfillImport(ctx, db, input.SessionID)
 		if err != nil {
 			return err
 		}
-		if plan.hasData {
-			fmt.Printf("Backfill dry-run: session %s already imported as conversation %d (%d messages, %d context items, %d summaries).\n", input.sessionID, plan.conversationID, plan.messageCount, plan.contextCount, plan.summaryCount)
+		if plan.HasData {
+			if err := applyConversationSettings(ctx, db, plan.ConversationID, &opts); err != nil {
+				return err
+			}
+		}
+		if input.SeqRange.Set {
+			pending, err := input.SeqRange.Pending(input.Messages, plan.HighestSeq)
+			if err != nil {
+				return err
+			}
+			target := "a new conversation"
+			if plan.HasData {
+				target = fmt.Sprintf("conversation %d (%d messages, imported through seq %d)", plan.ConversationID, plan.MessageCount, plan.HighestSeq)
+			}
+			if len(pending) == 0 {
+				fmt.Printf("Backfill dry-run: %s of %s is already imported into %s; nothing to append.\n", input.SeqRange.Describe(), input.SessionPath, target)
+			} else {
+				fmt.Printf("Backfill dry-run: would import seq %d-%d (%d messages of %d in the file) from %s into %s.\n", pending[0].Seq, pending[len(pending)-1].Seq, len(pending), len(input.Messages), input.SessionPath, target)
+			}
+		} else if plan.HasData {
+			fmt.Printf("Backfill dry-run: session %s already imported as conversation %d (%d messages, %d context items, %d summaries).\n", input.SessionID, plan.ConversationID, plan.MessageCount, plan.ContextCount, plan.SummaryCount)
 			if opts.recompact {
 				fmt.Println("Recompact mode: would skip import and rerun compaction on existing conversation.")
 			}
+		} else if opts.recompact {
+			fmt.Printf("Backfill dry-run: would import %d messages from %s into a new conversation (nothing to recompact yet).\n", len(input.Messages), input.SessionPath)
 		} else {
-			fmt.Printf("Backfill dry-run: would import %d messages from %s into a new conversation.\n", len(input.messages), input.sessionPath)
+			fmt.Printf("Backfill dry-run: would import %d messages from %s into a new conversation.\n", len(input.Messages), input.SessionPath)
 		}
 		fmt.Printf("Compaction dry-run: leaf chunk=%dt, leaf target=%dt, condensed target=%dt, fanout=%d/%d (hard=%d), fresh-tail=%d\n",
 			opts.leafChunkTokens,
@@ -166,12 +187,34 @@ func runBackfillCommand(args []string) error {
 		if opts.singleRoot {
 			fmt.Println("Single-root mode: enabled (forced fold when possible).")
 		}
+		if opts.smartChunk {
+			fmt.Println("Smart chunking: enabled (leaf chunks end at user turns or time gaps when possible).")
+		}
 		if opts.recompact {
 			fmt.Println("Recompact mode: enabled (run compaction for already-imported sessions).")
+			if plan.HasData {
+				summarize := backfillSummarizeFn(stubBackfillSummarize)
+				if opts.simulateLive {
+					client, err := newBackfillSummaryClient(paths, opts)
+					if err != nil {
+						return err
+					}
+					summarize = client.summarize
+				}
+				ignore, err := resolveContextIgnoreRules(paths.openclawConfig, opts.ignorePatterns)
+				if err != nil {
+					return err
+				}
+				sim, err := simulateBackfillRecompaction(ctx, db, paths.lcmDBPath, plan.ConversationID, opts, summarize, opts.simulateLive, ignore)
+				if err != nil {
+					return err
+				}
+				printBackfillCompactionSimulation(sim)
+			}
 		}
 		if opts.hasTransplantTarget {
-			if plan.hasData {
-				transplantPlan, terr := buildTransplantPlan(ctx, db, plan.conversationID, opts.transplantTo)
+			if plan.HasData {
+				transplantPlan, terr := lcm.BuildTransplantPlan(ctx, db, plan.ConversationID, opts.transplantTo)
 				if terr != nil {
 					return terr
 				}
@@ -183,90 +226,206 @@ func runBackfillCommand(args []string) error {
 		return nil
 	}
 
-	apiKey, err := resolveProviderAPIKey(paths, opts.provider)
+	client, err := newBackfillSummaryClient(paths, opts)
 	if err != nil {
 		return err
 	}
-	client := &anthropicClient{
-		provider: opts.provider,
-		apiKey:   apiKey,
-		http:     &http.Client{Timeout: defaultHTTPTimeout},
-		model:    opts.model,
-		baseURL:  opts.baseURL,
+	report.observe(client)
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "backfill", opts.backupDB, report); err != nil {
+		return err
 	}
 
+	snapshot, err := snapshotBackfillReport(ctx, db, report, input.SessionID, opts)
+	if err != nil {
+		return err
+	}
 	result, stats, err := runBackfillWorkflow(ctx, db, opts, input, client.summarize)
+	if reportErr := snapshot.record(ctx, db, report, input.SessionID, opts); reportErr != nil && err == nil {
+		err = reportErr
+	}
 	if err != nil {
 		return err
 	}
-
-	if result.imported {
+	if len(opts.modelFallbacks) > 0 && len(client.modelUsage) > 0 {
+		fmt.Printf("Summary models: %s\n", formatModelUsage(client.modelUsage))
+	}
+
+	if result.Imported && input.SeqRange.Set {
+		fmt.Printf("Imported seq %d-%d (%d messages) for %s/%s into conversation %d; it now holds %d messages through seq %d of %d in the file.\n",
+			result.FirstSeq,
+			result.LastSeq,
+			result.MessageCount,
+			input.Agent,
+			input.SessionID,
+			result.ConversationID,
+			result.TotalMessages,
+			result.HighestSeq,
+			len(input.Messages)-1,
+		)
+	} else if result.Imported {
 		fmt.Printf("Imported %d messages for %s/%s into conversation %d.\n",
-			result.messageCount,
-			input.agent,
-			input.sessionID,
-			result.conversationID,
+			result.MessageCount,
+			input.Agent,
+			input.SessionID,
+			result.ConversationID,
 		)
+	} else if input.SeqRange.Set {
+		fmt.Printf("Idempotency guard: %s of session %s is already imported in conversation %d (%d messages through seq %d); nothing to append.\n", input.SeqRange.Describe(), input.SessionID, result.ConversationID, result.TotalMessages, result.HighestSeq)
 	} else if opts.recompact {
-		fmt.Printf("Idempotency guard: session %s already imported in conversation %d, skipping import and re-running compaction.\n", input.sessionID, result.conversationID)
+		fmt.Printf("Idempotency guard: session %s already imported in conversation %d, skipping import and re-running compaction.\n", input.SessionID, result.ConversationID)
 	} else {
-		fmt.Printf("Idempotency guard: session %s already imported in conversation %d, skipping import.\n", input.sessionID, result.conversationID)
+		fmt.Printf("Idempotency guard: session %s already imported in conversation %d, skipping import.\n", input.SessionID, result.ConversationID)
 	}
 
-	fmt.Printf("Compaction passes: leaf=%d condensed=%d single-root=%d\n", stats.leafPasses, stats.condensedPasses, stats.rootFoldPasses)
+	fmt.Printf("Compaction passes: leaf=%d condensed=%d single-root=%d\n", stats.LeafPasses, stats.CondensedPasses, stats.RootFoldPasses)
+	printRedactionTotal(opts.redactor, stats.Redactions)
 	if opts.hasTransplantTarget {
 		fmt.Printf("Transplant target: conversation %d\n", opts.transplantTo)
 	}
 	return nil
 }
 
-func runBackfillWorkflow(ctx context.Context, db *sql.DB, opts backfillOptions, input backfillSessionInput, summarize backfillSummarizeFn) (backfillImportResult, backfillCompactionStats, error) {
-	plan, err := inspectBackfillImportPlan(ctx, db, input.sessionID)
+// newBackfillSummaryClient builds the summary client for opts' resolved
+// provider, model, and generation settings.
+func newBackfillSummaryClient(paths appDataPaths, opts backfillOptions) (*anthropicClient, error) {
+	apiKey, err := resolveProviderAPIKey(paths, opts.provider)
 	if err != nil {
-		return backfillImportResult{}, backfillCompactionStats{}, err
+		return nil, err
 	}
+	return &anthropicClient{
+		provider: opts.provider,
+		apiKey:   apiKey,
+		http:     newSummaryHTTPClient(opts.httpTimeout),
+		model:    opts.model,
+		baseURL:  opts.baseURL,
 
-	result := backfillImportResult{}
-	stats := backfillCompactionStats{}
-	if plan.hasData {
-		result = backfillImportResult{
-			conversationID: plan.conversationID,
-			imported:       false,
-			messageCount:   plan.messageCount,
-		}
-	} else {
-		result, err = applyBackfillImport(ctx, db, input)
-		if err != nil {
-			return backfillImportResult{}, backfillCompactionStats{}, err
+		modelFallbacks:  opts.modelFallbacks,
+		logf:            stdoutLogf,
+		temperature:     opts.generation.temperature,
+		maxOutputTokens: opts.generation.maxOutputTokens,
+	}, nil
+}
+
+// backfillReportSnapshot holds the summary IDs that existed before a
+// backfill run, so the run report lists only what the run created.
+type backfillReportSnapshot struct {
+	source map[string]bool
+	target map[string]bool
+}
+
+func snapshotBackfillReport(ctx context.Context, q sqlQueryer, report *runReport, sessionID string, opts backfillOptions) (backfillReportSnapshot, error) {
+	if report == nil {
+		return backfillReportSnapshot{}, nil
+	}
+	plan, err := lcm.InspectBackfillImport(ctx, q, sessionID)
+	if err != nil {
+		return backfillReportSnapshot{}, err
+	}
+	snapshot := backfillReportSnapshot{}
+	if snapshot.source, err = loadConversationSummaryIDs(ctx, q, plan.ConversationID); err != nil {
+		return backfillReportSnapshot{}, err
+	}
+	if opts.hasTransplantTarget {
+		if snapshot.target, err = loadConversationSummaryIDs(ctx, q, opts.transplantTo); err != nil {
+			return backfillReportSnapshot{}, err
 		}
 	}
+	return snapshot, nil
+}
 
-	shouldCompact := result.imported || (!result.imported && opts.recompact)
-	if shouldCompact {
-		if summarize == nil {
-			return backfillImportResult{}, backfillCompactionStats{}, errors.New("backfill summarize function is required for apply mode")
-		}
-		stats, err = runBackfillCompaction(ctx, db, result.conversationID, opts, summarize)
-		if err != nil {
-			return backfillImportResult{}, backfillCompactionStats{}, err
+// record adds the summaries created in the session's conversation, and those
+// copied into the transplant target, to report. It runs after success or
+// failure; passes commit as they go, so partial runs are listed too.
+func (s backfillReportSnapshot) record(ctx context.Context, q sqlQueryer, report *runReport, sessionID string, opts backfillOptions) error {
+	if report == nil {
+		return nil
+	}
+	plan, err := lcm.InspectBackfillImport(ctx, q, sessionID)
+	if err != nil {
+		return err
+	}
+	if plan.ConversationID > 0 {
+		report.addConversation(plan.ConversationID)
+		if err := report.addNewSummaries(ctx, q, plan.ConversationID, s.source, "created"); err != nil {
+			return err
 		}
 	}
-
 	if opts.hasTransplantTarget {
-		transplantPlan, err := buildTransplantPlan(ctx, db, result.conversationID, opts.transplantTo)
-		if err != nil {
-			return backfillImportResult{}, backfillCompactionStats{}, err
-		}
-		printTransplantDryRunReport(transplantPlan)
-		if len(transplantPlan.duplicates) > 0 {
-			return backfillImportResult{}, backfillCompactionStats{}, fmt.Errorf("aborting transplant: target conversation %d already contains %d matching summary content hashes", opts.transplantTo, len(transplantPlan.duplicates))
-		}
-		if _, err := applyTransplant(ctx, db, transplantPlan); err != nil {
-			return backfillImportResult{}, backfillCompactionStats{}, err
+		report.addConversation(opts.transplantTo)
+		if err := report.addNewSummaries(ctx, q, opts.transplantTo, s.target, "transplanted"); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return result, stats, nil
+// runBackfillWorkflow runs lcm.RunBackfill with the CLI's options: stored
+// conversation settings fill in flags the user did not give, and the
+// transplant plan is printed before it is applied.
+func runBackfillWorkflow(ctx context.Context, db *sql.DB, opts backfillOptions, input lcm.BackfillInput, summarize backfillSummarizeFn) (lcm.BackfillResult, lcm.CompactionStats, error) {
+	var summarizer lcm.Summarizer
+	if summarize != nil {
+		summarizer = summarize
+	}
+	return lcm.RunBackfill(ctx, db, input, lcm.BackfillOptions{
+		Compaction:   opts.compaction(),
+		Recompact:    opts.recompact,
+		Transplant:   opts.hasTransplantTarget,
+		TransplantTo: opts.transplantTo,
+		Configure: func(ctx context.Context, conversationID int64, compaction *lcm.CompactionOptions) error {
+			if err := applyConversationSettings(ctx, db, conversationID, &opts); err != nil {
+				return err
+			}
+			*compaction = opts.compaction()
+			return nil
+		},
+		ReviewTransplant: printTransplantDryRunReport,
+	}, summarizer)
+}
+
+// runBackfillCompaction compacts conversationID with opts' compaction
+// settings.
+func runBackfillCompaction(ctx context.Context, db *sql.DB, conversationID int64, opts backfillOptions, summarize backfillSummarizeFn) (lcm.CompactionStats, error) {
+	return lcm.RunCompaction(ctx, db, conversationID, opts.compaction(), summarize)
+}
+
+// compaction returns the lcm compaction settings in opts. Progress notes go
+// to stdout.
+func (opts backfillOptions) compaction() lcm.CompactionOptions {
+	compaction := lcm.CompactionOptions{
+		LeafChunkTokens:       opts.leafChunkTokens,
+		LeafTargetTokens:      opts.leafTargetTokens,
+		CondensedTargetTokens: opts.condensedTargetToken,
+		LeafFanout:            opts.leafFanout,
+		CondensedFanout:       opts.condensedFanout,
+		HardFanout:            opts.hardFanout,
+		FreshTailCount:        opts.freshTailCount,
+		SingleRoot:            opts.singleRoot,
+		SmartChunk:            opts.smartChunk,
+		PromptDir:             opts.promptDir,
+		PrevContext:           opts.prevContext,
+		Log:                   os.Stdout,
+	}
+	if opts.redactor != nil {
+		compaction.Redactor = opts.redactor
+	}
+	return compaction
+}
+
+// defaultBackfillCompactionOptions returns backfill's default compaction
+// settings, shared with other commands that recompact a conversation.
+func defaultBackfillCompactionOptions() backfillOptions {
+	return backfillOptions{
+		leafChunkTokens:      20000,
+		leafTargetTokens:     1200,
+		condensedTargetToken: lcm.CondensedTargetTokens,
+		leafFanout:           8,
+		condensedFanout:      4,
+		hardFanout:           2,
+		freshTailCount:       32,
+		httpTimeout:          defaultHTTPTimeout,
+		prevContext:          lcm.PreviousContextOptions{Count: 2, Depth: -1},
+	}
 }
 
 func parseBackfillArgs(args []string) (backfillOptions, error) {
@@ -277,19 +436,42 @@ func parseBackfillArgs(args []string) (backfillOptions, error) {
 	dryRun := fs.Bool("dry-run", true, "show plan without writing")
 	singleRoot := fs.Bool("single-root", false, "force condensed folding until one summary remains when possible")
 	recompact := fs.Bool("recompact", false, "rerun compaction on an existing imported conversation")
+	simulateLive := fs.Bool("simulate-live", false, "use real summarize calls when simulating a --recompact dry run")
+	verify := fs.Bool("verify", false, "compare an imported conversation against its session JSONL")
+	normalizeWhitespace := fs.Bool("normalize-whitespace", false, "normalize line endings and blank-line runs in imported content")
 	transplantTo := fs.Int64("transplant-to", 0, "target conversation ID to transplant backfilled summaries into")
 	title := fs.String("title", "", "conversation title override")
-	leafChunk := fs.Int("leaf-chunk-tokens", 20000, "max input tokens per leaf chunk")
-	leafTarget := fs.Int("leaf-target-tokens", 1200, "target output tokens for leaf summaries")
-	condensedTarget := fs.Int("condensed-target-tokens", condensedTargetTokens, "target output tokens for condensed summaries")
-	leafFanout := fs.Int("leaf-fanout", 8, "minimum leaf summaries required before d1 condensation")
-	condensedFanout := fs.Int("condensed-fanout", 4, "minimum summaries required before d2+ condensation")
-	hardFanout := fs.Int("hard-fanout", 2, "minimum summaries used in forced single-root fold")
-	freshTail := fs.Int("fresh-tail", 32, "number of freshest raw messages to preserve from leaf compaction")
+	sessionPath := fs.String("session-path", "", "import this session JSONL instead of looking under the agent's sessions dir")
+	sessionIDFlag := fs.String("session-id", "", "session ID to record for --session-path (default: file name)")
+	encodingFlag := fs.String("encoding", "auto", "session JSONL character encoding (auto detects BOMs and non-UTF-8 lines)")
+	roleMapFlag := fs.String("role-map", "", "comma-separated source=stored role overrides, e.g. developer=user,function=tool")
+	startSeq := fs.Int("start-seq", 0, "first session message seq to import (staged import)")
+	endSeq := fs.Int("end-seq", -1, "last session message seq to import, inclusive (staged import; -1 = end of file)")
+	defaults := defaultBackfillCompactionOptions()
+	leafChunk := fs.Int("leaf-chunk-tokens", defaults.leafChunkTokens, "max input tokens per leaf chunk")
+	leafTarget := fs.Int("leaf-target-tokens", defaults.leafTargetTokens, "target output tokens for leaf summaries")
+	condensedTarget := fs.Int("condensed-target-tokens", defaults.condensedTargetToken, "target output tokens for condensed summaries")
+	leafFanout := fs.Int("leaf-fanout", defaults.leafFanout, "minimum leaf summaries required before d1 condensation")
+	condensedFanout := fs.Int("condensed-fanout", defaults.condensedFanout, "minimum summaries required before d2+ condensation")
+	hardFanout := fs.Int("hard-fanout", defaults.hardFanout, "minimum summaries used in forced single-root fold")
+	freshTail := fs.Int("fresh-tail", defaults.freshTailCount, "number of freshest raw messages to preserve from leaf compaction")
+	prevContextCount := fs.Int("prev-context-count", defaults.prevContext.Count, "prior summaries to include as previous context")
+	prevContextDepth := fs.Int("prev-context-depth", defaults.prevContext.Depth, "depth to draw previous context from (-1 = default)")
 	promptDir := fs.String("prompt-dir", "", "custom prompt template directory")
 	provider := fs.String("provider", "", "provider id (e.g. anthropic, openai)")
 	model := fs.String("model", "", "summary model id")
+	modelFallback := fs.String("model-fallback", "", "comma-separated models to try when the model is unavailable")
 	baseURL := fs.String("base-url", "", "custom API base URL")
+	stub := fs.Bool("stub", false, "use the deterministic stub summarizer (demos/tests only)")
+	httpTimeout := fs.Duration("http-timeout", defaultHTTPTimeout, "timeout for each summary API call (e.g. 300s, 10m)")
+	temperature := fs.String("temperature", "", "sampling temperature for summary calls (default: provider default)")
+	maxOutputTokens := fs.Int("max-output-tokens", 0, "output token ceiling for summary calls (default: derived from the target)")
+	reportFile := fs.String("report-file", "", "write a JSON (or .md) run report to this path")
+	var ignorePatterns ignorePatternList
+	fs.Var(&ignorePatterns, "ignore", "leave messages whose content matches this glob out of the --recompact simulation (repeatable)")
+	redact := fs.Bool("redact", false, "redact secrets from source text before it is sent to the summary API")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+	smartChunk := fs.Bool("smart-chunk", false, "end leaf chunks at user turns or long time gaps within a tolerance of the budget")
 
 	normalized, err := normalizeBackfillArgs(args)
 	if err != nil {
@@ -298,8 +480,18 @@ func parseBackfillArgs(args []string) (backfillOptions, error) {
 	if err := fs.Parse(normalized); err != nil {
 		return backfillOptions{}, fmt.Errorf("%w\n%s", err, backfillUsageText())
 	}
-	if fs.NArg() != 2 {
-		return backfillOptions{}, fmt.Errorf("agent and session_id are required\n%s", backfillUsageText())
+	sessionID, explicitPath, err := resolveBackfillSessionArgs(fs.Args(), strings.TrimSpace(*sessionIDFlag), strings.TrimSpace(*sessionPath))
+	if err != nil {
+		return backfillOptions{}, fmt.Errorf("%w\n%s", err, backfillUsageText())
+	}
+
+	stubProvider, err := resolveStubProviderFlag(*stub, strings.TrimSpace(*provider))
+	if err != nil {
+		return backfillOptions{}, fmt.Errorf("%w\n%s", err, backfillUsageText())
+	}
+	roleMap, err := parseBackfillRoleMap(*roleMapFlag)
+	if err != nil {
+		return backfillOptions{}, fmt.Errorf("%w\n%s", err, backfillUsageText())
 	}
 
 	opts := backfillOptions{
@@ -307,8 +499,13 @@ func parseBackfillArgs(args []string) (backfillOptions, error) {
 		dryRun:               *dryRun,
 		singleRoot:           *singleRoot,
 		recompact:            *recompact,
+		simulateLive:         *simulateLive,
+		verify:               *verify,
+		normalizeWhitespace:  *normalizeWhitespace,
 		agent:                strings.TrimSpace(fs.Arg(0)),
-		sessionID:            normalizeBackfillSessionID(fs.Arg(1)),
+		sessionID:            sessionID,
+		sessionPath:          explicitPath,
+		encoding:             strings.TrimSpace(*encodingFlag),
 		title:                strings.TrimSpace(*title),
 		transplantTo:         *transplantTo,
 		hasTransplantTarget:  *transplantTo > 0,
@@ -320,9 +517,21 @@ func parseBackfillArgs(args []string) (backfillOptions, error) {
 		hardFanout:           *hardFanout,
 		freshTailCount:       *freshTail,
 		promptDir:            strings.TrimSpace(*promptDir),
-		provider:             strings.TrimSpace(*provider),
+		provider:             stubProvider,
 		model:                strings.TrimSpace(*model),
+		modelFallbacks:       parseModelFallbackList(*modelFallback),
 		baseURL:              strings.TrimSpace(*baseURL),
+		httpTimeout:          *httpTimeout,
+		reportFile:           strings.TrimSpace(*reportFile),
+		explicitFlags:        explicitFlags(fs),
+		roleMap:              roleMap,
+		smartChunk:           *smartChunk,
+		ignorePatterns:       ignorePatterns,
+	}
+	opts.seqRange = lcm.SeqRange{
+		Set:   opts.explicitFlags["start-seq"] || opts.explicitFlags["end-seq"],
+		Start: *startSeq,
+		End:   *endSeq,
 	}
 	if opts.apply {
 		opts.dryRun = false
@@ -330,6 +539,24 @@ func parseBackfillArgs(args []string) (backfillOptions, error) {
 	if !opts.apply {
 		opts.dryRun = true
 	}
+	if opts.simulateLive && (opts.apply || !opts.recompact) {
+		return backfillOptions{}, fmt.Errorf("--simulate-live only applies to --recompact dry runs\n%s", backfillUsageText())
+	}
+	if len(opts.ignorePatterns) > 0 && (opts.apply || !opts.recompact) {
+		return backfillOptions{}, fmt.Errorf("--ignore only applies to --recompact dry runs\n%s", backfillUsageText())
+	}
+	if opts.verify && (opts.apply || opts.recompact || opts.hasTransplantTarget) {
+		return backfillOptions{}, fmt.Errorf("--verify is read-only and cannot be combined with --apply, --recompact, or --transplant-to")
+	}
+	if opts.seqRange.Set && opts.verify {
+		return backfillOptions{}, fmt.Errorf("--verify compares the whole session file and cannot be combined with --start-seq or --end-seq")
+	}
+	if opts.seqRange.Start < 0 {
+		return backfillOptions{}, fmt.Errorf("--start-seq must be >= 0")
+	}
+	if opts.seqRange.End < -1 || (opts.explicitFlags["end-seq"] && opts.seqRange.End >= 0 && opts.seqRange.End < opts.seqRange.Start) {
+		return backfillOptions{}, fmt.Errorf("--end-seq must be >= --start-seq (or -1 for the end of the file)")
+	}
 	if opts.agent == "" {
 		return backfillOptions{}, fmt.Errorf("agent must not be empty\n%s", backfillUsageText())
 	}
@@ -357,8 +584,30 @@ func parseBackfillArgs(args []string) (backfillOptions, error) {
 	if opts.freshTailCount < 0 {
 		return backfillOptions{}, fmt.Errorf("--fresh-tail must be >= 0")
 	}
+	if opts.httpTimeout <= 0 {
+		return backfillOptions{}, fmt.Errorf("--http-timeout must be > 0")
+	}
+	opts.prevContext, err = resolvePreviousContextSettings(*prevContextCount, *prevContextDepth)
+	if err != nil {
+		return backfillOptions{}, err
+	}
+	opts.generation, err = resolveSummaryGenerationSettings(*temperature, *maxOutputTokens)
+	if err != nil {
+		return backfillOptions{}, err
+	}
+	opts.redactor, err = resolveSourceRedactor(*redact)
+	if err != nil {
+		return backfillOptions{}, err
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return backfillOptions{}, err
+	}
+	if _, err := newSessionDecoder(opts.encoding); err != nil {
+		return backfillOptions{}, err
+	}
 	if opts.promptDir != "" {
-		opts.promptDir = expandHomePath(opts.promptDir)
+		opts.promptDir = lcm.ExpandHomePath(opts.promptDir)
 	}
 	return opts, nil
 }
@@ -370,6 +619,12 @@ func normalizeBackfillArgs(args []string) ([]string, error) {
 	takesValue := map[string]bool{
 		"--transplant-to":           true,
 		"--title":                   true,
+		"--session-path":            true,
+		"--session-id":              true,
+		"--encoding":                true,
+		"--role-map":                true,
+		"--start-seq":               true,
+		"--end-seq":                 true,
 		"--leaf-chunk-tokens":       true,
 		"--leaf-target-tokens":      true,
 		"--condensed-target-tokens": true,
@@ -377,10 +632,18 @@ func normalizeBackfillArgs(args []string) ([]string, error) {
 		"--condensed-fanout":        true,
 		"--hard-fanout":             true,
 		"--fresh-tail":              true,
+		"--prev-context-count":      true,
+		"--prev-context-depth":      true,
 		"--prompt-dir":              true,
 		"--provider":                true,
 		"--model":                   true,
+		"--model-fallback":          true,
 		"--base-url":                true,
+		"--http-timeout":            true,
+		"--temperature":             true,
+		"--max-output-tokens":       true,
+		"--report-file":             true,
+		"--ignore":                  true,
 	}
 
 	for i := 0; i < len(args); i++ {
@@ -393,7 +656,7 @@ func normalizeBackfillArgs(args []string) ([]string, error) {
 			i++
 			continue
 		}
-		if arg == "--apply" || arg == "--dry-run" || arg == "--single-root" || arg == "--recompact" {
+		if arg == "--apply" || arg == "--dry-run" || arg == "--single-root" || arg == "--recompact" || arg == "--simulate-live" || arg == "--verify" || arg == "--normalize-whitespace" || arg == "--redact" || arg == "--backup-db" {
 			flags = append(flags, arg)
 			continue
 		}
@@ -410,29 +673,65 @@ func backfillUsageText() string {
 	return strings.TrimSpace(`Usage:
   lcm-tui backfill <agent> <session_id> [--dry-run]
   lcm-tui backfill <agent> <session_id> --apply
+  lcm-tui backfill <agent> <session_id> --verify
+  lcm-tui backfill <agent> [session_id] --session-path <file> [--session-id <id>] [--dry-run|--apply]
 
 Flags:
   --dry-run                    show backfill plan without writes (default)
   --apply                      import + compact + optional transplant
-  --recompact                  re-run compaction on already-imported session data
+  --recompact                  re-run compaction on already-imported session data; with --dry-run,
+                               simulate it on an in-memory copy and compare depth counts and context
+  --simulate-live              make real summarize calls in that simulation (billed; still no writes)
+  --ignore <glob>              leave messages whose content matches glob out of that simulation
+                               (repeatable); ignoreSessionPatterns only match the session key
+  --verify                     compare imported messages against the session JSONL (read-only)
+  --normalize-whitespace       normalize line endings, trailing spaces, and blank-line runs on import
   --single-root                force condensed folding until one summary remains when possible
   --transplant-to <conv_id>    transplant backfilled summaries into target conversation
   --title <text>               conversation title override
+  --session-path <file>        import a session JSONL from outside ~/.openclaw/agents (archives, backups)
+  --session-id <id>            session ID for --session-path (default: file name without .jsonl)
+  --encoding <name>            session file encoding: auto (default), utf-8, latin1, windows-1252, utf-16le, ...
+  --role-map <from=to,...>     store source roles as system/user/assistant/tool (e.g. developer=user,function=tool);
+                               unmapped unknown roles still become assistant
+  --start-seq <n>              staged import: first message seq to import (default 0); messages already
+                               imported are skipped, and a start past the next unimported seq is refused
+  --end-seq <n>                staged import: last message seq to import, inclusive (default: end of file);
+                               either flag appends to the session's existing conversation
   --leaf-chunk-tokens <n>      max source tokens per leaf chunk (default 20000)
+  --smart-chunk                end leaf chunks at a user-turn start or a 30m+ gap between messages when
+                               one falls within 25% under --leaf-chunk-tokens
   --leaf-target-tokens <n>     target output tokens for leaf summaries (default 1200)
   --condensed-target-tokens <n> target output tokens for condensed summaries (default 2000)
   --leaf-fanout <n>            min leaves per d1 condensation (default 8)
   --condensed-fanout <n>       min summaries per d2+ condensation (default 4)
   --hard-fanout <n>            min summaries per forced single-root pass (default 2)
   --fresh-tail <n>             preserve freshest N raw messages from leaf compaction (default 32)
+  --prev-context-count <n>     prior summaries given to each leaf and d1 prompt as previous context (default 2)
+  --prev-context-depth <n>     only draw previous context from depth N (default: any depth for leaves,
+                               the condensed depth for d1)
   --prompt-dir <path>          custom prompt template directory
   --provider <id>              API provider (inferred from model when omitted)
   --model <id>                 API model (default: provider-specific)
+  --model-fallback <ids>       comma-separated models to retry with when the model is unknown, retired, or overloaded
   --base-url <url>             custom API base URL (overrides openclaw.json and env)
+  --stub                       use the deterministic stub summarizer (demos/tests only)
+  --http-timeout <dur>         timeout for each summary API call (default 3m0s)
+  --temperature <t>            sampling temperature for summary calls, 0-2 (0-1 on Anthropic; default: provider default)
+  --max-output-tokens <n>      output token ceiling per call; Anthropic keeps at least the target plus a margin
+  --report-file <path>         write a JSON run report (markdown when path ends in .md), even on failure
+  --redact                     replace secrets in source text with [REDACTED:<kind>] before it is sent
+  --backup-db                  with --apply, copy lcm.db to a timestamped backup first
 
 Env:
   LCM_TUI_SUMMARY_PROVIDER / LCM_TUI_SUMMARY_MODEL / LCM_TUI_SUMMARY_BASE_URL
   fall back to LCM_SUMMARY_PROVIDER / LCM_SUMMARY_MODEL / LCM_SUMMARY_BASE_URL
+  LCM_TUI_SUMMARY_TEMPERATURE / LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS
+  back --temperature / --max-output-tokens
+  HTTPS_PROXY / HTTP_PROXY / NO_PROXY route summary API calls through a proxy
+  LCM_SUMMARIZER=stub uses the deterministic stub summarizer (no API calls)
+  LCM_TUI_REDACT=1 turns on --redact for every command
+  LCM_TUI_BACKUP_DB=1 turns on --backup-db for every command
 `)
 }
 
@@ -442,6 +741,54 @@ func normalizeBackfillSessionID(raw string) string {
 	return trimmed
 }
 
+// resolveBackfillSessionArgs takes the agent and optional session ID
+// positionals plus --session-id/--session-path. With --session-path the
+// session ID may come from the flag or, failing that, the file name.
+func resolveBackfillSessionArgs(positionals []string, sessionIDFlag, sessionPath string) (string, string, error) {
+	if len(positionals) < 1 || len(positionals) > 2 {
+		return "", "", errors.New("agent and session_id are required")
+	}
+	sessionID := ""
+	if len(positionals) == 2 {
+		sessionID = normalizeBackfillSessionID(positionals[1])
+	}
+	if flagID := normalizeBackfillSessionID(sessionIDFlag); flagID != "" {
+		if sessionID != "" && sessionID != flagID {
+			return "", "", fmt.Errorf("session_id %q conflicts with --session-id %q", sessionID, flagID)
+		}
+		sessionID = flagID
+	}
+	if sessionPath == "" {
+		if sessionID == "" {
+			return "", "", errors.New("agent and session_id are required")
+		}
+		return sessionID, "", nil
+	}
+
+	sessionPath = lcm.ExpandHomePath(sessionPath)
+	if sessionID == "" {
+		sessionID = normalizeBackfillSessionID(filepath.Base(sessionPath))
+	}
+	return sessionID, sessionPath, nil
+}
+
+// checkBackfillSessionFile confirms an explicit --session-path names a
+// readable regular file before any DB work starts.
+func checkBackfillSessionFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("session file %q: %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("session file %q is a directory", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("session file %q is not readable: %w", path, err)
+	}
+	return file.Close()
+}
+
 func resolveBackfillSessionPath(agentsDir, agent, sessionID string) (string, error) {
 	normalizedSessionID := normalizeBackfillSessionID(sessionID)
 	if normalizedSessionID == "" {
@@ -461,43 +808,42 @@ func resolveBackfillSessionPath(agentsDir, agent, sessionID string) (string, err
 	return "", fmt.Errorf("session file not found for agent %q session %q", agent, normalizedSessionID)
 }
 
-func parseBackfillSessionFile(path string) ([]backfillMessage, error) {
-	file, err := os.Open(path)
+// parseBackfillSessionFile reads message rows from a session JSONL. When
+// normalizeWhitespace is set, content passes through
+// normalizeContentWhitespace so re-imports of the same logical content hash
+// and count tokens identically. The file is converted to UTF-8 with decoder
+// first; a nil decoder auto-detects. roleMap overrides role normalization; see
+// normalizeBackfillRole.
+func parseBackfillSessionFile(path string, normalizeWhitespace bool, decoder *sessionDecoder, roleMap map[string]string) ([]lcm.BackfillMessage, error) {
+	if decoder == nil {
+		decoder = &sessionDecoder{label: "auto"}
+	}
+	scanner, file, err := openSessionScanner(path, decoder)
 	if err != nil {
-		return nil, fmt.Errorf("open session %q: %w", path, err)
+		return nil, err
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 64*1024)
-	scanner.Buffer(buf, 16*1024*1024)
-
-	messages := make([]backfillMessage, 0, 512)
+	messages := make([]lcm.BackfillMessage, 0, 512)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
-
-		var item sessionLine
-		if err := json.Unmarshal(line, &item); err != nil || item.Type != "message" {
-			continue
-		}
-
-		var msg lineMessage
-		if err := json.Unmarshal(item.Message, &msg); err != nil {
+		item, msg, ok := decoder.messageLine(scanner.Bytes())
+		if !ok {
 			continue
 		}
 
 		createdAt := normalizeBackfillTimestamp(pickTimestamp(item.Timestamp, msg.Timestamp))
-		role := normalizeBackfillRole(msg.Role)
+		role := normalizeBackfillRole(msg.Role, roleMap)
 		content := strings.TrimSpace(normalizeMessageContent(msg.Content))
+		if normalizeWhitespace {
+			content = normalizeContentWhitespace(content)
+		}
 
-		messages = append(messages, backfillMessage{
-			seq:       len(messages),
-			role:      role,
-			content:   content,
-			createdAt: createdAt,
+		messages = append(messages, lcm.BackfillMessage{
+			Seq:       len(messages),
+			Role:      role,
+			RawRole:   strings.TrimSpace(msg.Role),
+			Content:   content,
+			CreatedAt: createdAt,
 		})
 	}
 	if err := scanner.Err(); err != nil {
@@ -506,859 +852,84 @@ func parseBackfillSessionFile(path string) ([]backfillMessage, error) {
 	return messages, nil
 }
 
-func normalizeBackfillRole(role string) string {
-	switch strings.ToLower(strings.TrimSpace(role)) {
-	case "system", "user", "assistant", "tool":
-		return strings.ToLower(strings.TrimSpace(role))
-	default:
-		// Matches runtime behavior where unknown roles are preserved in parts
-		// and treated as assistant for core message rows.
-		return "assistant"
-	}
-}
-
-func normalizeBackfillTimestamp(raw string) string {
-	trimmed := strings.TrimSpace(raw)
-	if trimmed == "" {
-		return time.Now().UTC().Format("2006-01-02 15:04:05")
-	}
-	if parsed, err := parseSQLiteTime(trimmed); err == nil {
-		return parsed.UTC().Format("2006-01-02 15:04:05")
-	}
-	return time.Now().UTC().Format("2006-01-02 15:04:05")
-}
-
-func inspectBackfillImportPlan(ctx context.Context, q sqlQueryer, sessionID string) (backfillImportPlan, error) {
-	var conversationID int64
-	err := q.QueryRowContext(ctx, `
-		SELECT conversation_id
-		FROM conversations
-		WHERE session_id = ?
-		ORDER BY conversation_id DESC
-		LIMIT 1
-	`, sessionID).Scan(&conversationID)
-	if errors.Is(err, sql.ErrNoRows) {
-		return backfillImportPlan{}, nil
-	}
-	if err != nil {
-		return backfillImportPlan{}, fmt.Errorf("query existing conversation for session %s: %w", sessionID, err)
-	}
-
-	var messageCount int
-	if err := q.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM messages WHERE conversation_id = ?
-	`, conversationID).Scan(&messageCount); err != nil {
-		return backfillImportPlan{}, fmt.Errorf("count messages for conversation %d: %w", conversationID, err)
-	}
-
-	var contextCount int
-	if err := q.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM context_items WHERE conversation_id = ?
-	`, conversationID).Scan(&contextCount); err != nil {
-		return backfillImportPlan{}, fmt.Errorf("count context items for conversation %d: %w", conversationID, err)
-	}
-
-	var summaryCount int
-	if err := q.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM summaries WHERE conversation_id = ?
-	`, conversationID).Scan(&summaryCount); err != nil {
-		return backfillImportPlan{}, fmt.Errorf("count summaries for conversation %d: %w", conversationID, err)
-	}
-
-	return backfillImportPlan{
-		conversationID: conversationID,
-		hasData:        messageCount > 0 || contextCount > 0 || summaryCount > 0,
-		messageCount:   messageCount,
-		contextCount:   contextCount,
-		summaryCount:   summaryCount,
-	}, nil
-}
-
-func applyBackfillImport(ctx context.Context, db *sql.DB, input backfillSessionInput) (backfillImportResult, error) {
-	plan, err := inspectBackfillImportPlan(ctx, db, input.sessionID)
-	if err != nil {
-		return backfillImportResult{}, err
-	}
-	if plan.hasData {
-		return backfillImportResult{
-			conversationID: plan.conversationID,
-			imported:       false,
-			messageCount:   plan.messageCount,
-		}, nil
-	}
-
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return backfillImportResult{}, fmt.Errorf("begin backfill import transaction: %w", err)
-	}
-	rollback := true
-	defer func() {
-		if rollback {
-			_ = tx.Rollback()
-		}
-	}()
-
-	conversationID := plan.conversationID
-	title := strings.TrimSpace(input.title)
-	if title == "" {
-		title = input.sessionID
-	}
-
-	if conversationID == 0 {
-		result, err := tx.ExecContext(ctx, `
-			INSERT INTO conversations (session_id, title, bootstrapped_at)
-			VALUES (?, ?, datetime('now'))
-		`, input.sessionID, title)
-		if err != nil {
-			return backfillImportResult{}, fmt.Errorf("insert conversation for session %s: %w", input.sessionID, err)
-		}
-		conversationID, err = result.LastInsertId()
-		if err != nil {
-			return backfillImportResult{}, fmt.Errorf("read conversation ID for session %s: %w", input.sessionID, err)
-		}
-	} else {
-		if _, err := tx.ExecContext(ctx, `
-			UPDATE conversations
-			SET title = COALESCE(NULLIF(title, ''), ?),
-			    bootstrapped_at = COALESCE(bootstrapped_at, datetime('now')),
-			    updated_at = datetime('now')
-			WHERE conversation_id = ?
-		`, title, conversationID); err != nil {
-			return backfillImportResult{}, fmt.Errorf("update existing conversation %d: %w", conversationID, err)
-		}
-	}
-
-	for idx, msg := range input.messages {
-		result, err := tx.ExecContext(ctx, `
-			INSERT INTO messages (conversation_id, seq, role, content, token_count, identity_hash, created_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`, conversationID, idx, msg.role, msg.content, estimateTokenCount(msg.content), messageIdentityHash(msg.role, msg.content), msg.createdAt)
-		if err != nil {
-			return backfillImportResult{}, fmt.Errorf("insert backfill message seq=%d: %w", idx, err)
-		}
-		messageID, err := result.LastInsertId()
-		if err != nil {
-			return backfillImportResult{}, fmt.Errorf("read message ID for seq=%d: %w", idx, err)
-		}
-
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, created_at)
-			VALUES (?, ?, 'message', ?, ?)
-		`, conversationID, idx, messageID, msg.createdAt); err != nil {
-			return backfillImportResult{}, fmt.Errorf("insert context item seq=%d: %w", idx, err)
-		}
-
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO messages_fts (rowid, content)
-			VALUES (?, ?)
-		`, messageID, msg.content); err != nil {
-			return backfillImportResult{}, fmt.Errorf("insert messages_fts row for message %d: %w", messageID, err)
-		}
-
-		partID, err := newMessagePartID()
-		if err != nil {
-			return backfillImportResult{}, err
-		}
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content)
-			VALUES (?, ?, ?, 'text', 0, ?)
-		`, partID, messageID, input.sessionID, msg.content); err != nil {
-			return backfillImportResult{}, fmt.Errorf("insert message_part for message %d: %w", messageID, err)
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		return backfillImportResult{}, fmt.Errorf("commit backfill import transaction: %w", err)
-	}
-	rollback = false
-
-	return backfillImportResult{
-		conversationID: conversationID,
-		imported:       true,
-		messageCount:   len(input.messages),
-	}, nil
-}
-
-func runBackfillCompaction(ctx context.Context, db *sql.DB, conversationID int64, opts backfillOptions, summarize backfillSummarizeFn) (backfillCompactionStats, error) {
-	stats := backfillCompactionStats{}
-
-	for {
-		items, err := loadBackfillContextItems(ctx, db, conversationID)
-		if err != nil {
-			return stats, err
-		}
-
-		leafChunk := selectBackfillLeafChunk(items, opts.leafChunkTokens, opts.freshTailCount)
-		if len(leafChunk) > 0 {
-			if err := applyBackfillLeafPass(ctx, db, conversationID, leafChunk, opts, summarize); err != nil {
-				return stats, err
-			}
-			stats.leafPasses++
-			continue
-		}
-
-		candidate, ok := selectBackfillCondensedCandidate(items, opts, false)
-		if ok {
-			if err := applyBackfillCondensedPass(ctx, db, conversationID, candidate, opts, summarize); err != nil {
-				return stats, err
-			}
-			stats.condensedPasses++
-			continue
-		}
-
-		break
-	}
-
-	if opts.singleRoot {
-		for {
-			items, err := loadBackfillContextItems(ctx, db, conversationID)
-			if err != nil {
-				return stats, err
-			}
-			if !backfillCanForceSingleRoot(items) {
-				break
-			}
-			candidate, ok := selectBackfillCondensedCandidate(items, opts, true)
-			if !ok {
-				break
-			}
-			if err := applyBackfillCondensedPass(ctx, db, conversationID, candidate, opts, summarize); err != nil {
-				return stats, err
-			}
-			stats.rootFoldPasses++
-		}
-	}
-
-	return stats, nil
-}
-
-func loadBackfillContextItems(ctx context.Context, q sqlQueryer, conversationID int64) ([]backfillContextItem, error) {
-	rows, err := q.QueryContext(ctx, `
-		SELECT
-			ci.ordinal,
-			ci.item_type,
-			ci.message_id,
-			ci.summary_id,
-			COALESCE(m.token_count, s.token_count, 0) AS token_count,
-			COALESCE(s.depth, 0) AS depth
-		FROM context_items ci
-		LEFT JOIN messages m ON m.message_id = ci.message_id
-		LEFT JOIN summaries s ON s.summary_id = ci.summary_id
-		WHERE ci.conversation_id = ?
-		ORDER BY ci.ordinal ASC
-	`, conversationID)
-	if err != nil {
-		return nil, fmt.Errorf("query context items for conversation %d: %w", conversationID, err)
-	}
-	defer rows.Close()
-
-	items := make([]backfillContextItem, 0, 256)
-	for rows.Next() {
-		var item backfillContextItem
-		if err := rows.Scan(&item.ordinal, &item.itemType, &item.messageID, &item.summaryID, &item.tokenCount, &item.depth); err != nil {
-			return nil, fmt.Errorf("scan context item row: %w", err)
-		}
-		items = append(items, item)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate context items: %w", err)
-	}
-	return items, nil
-}
-
-func selectBackfillLeafChunk(items []backfillContextItem, chunkTokens, freshTail int) []backfillContextItem {
-	if len(items) == 0 {
-		return nil
-	}
-	if chunkTokens <= 0 {
-		chunkTokens = 1
-	}
-	freshTailOrdinal := resolveBackfillFreshTailOrdinal(items, freshTail)
-	chunk := make([]backfillContextItem, 0, 32)
-	tokens := 0
-	started := false
-	for _, item := range items {
-		if item.ordinal >= freshTailOrdinal {
-			break
-		}
-		if !started {
-			if item.itemType != "message" || !item.messageID.Valid {
+// normalizeContentWhitespace converts CRLF/CR line endings to LF, strips
+// trailing spaces and tabs from each line, and collapses runs of blank lines
+// to a single blank line. Leading indentation is preserved.
+func normalizeContentWhitespace(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank {
 				continue
 			}
-			started = true
-		} else if item.itemType != "message" || !item.messageID.Valid {
-			break
-		}
-
-		messageTokens := item.tokenCount
-		if messageTokens <= 0 {
-			messageTokens = 1
-		}
-		if len(chunk) > 0 && tokens+messageTokens > chunkTokens {
-			break
-		}
-		chunk = append(chunk, item)
-		tokens += messageTokens
-		if tokens >= chunkTokens {
-			break
-		}
-	}
-	return chunk
-}
-
-func resolveBackfillFreshTailOrdinal(items []backfillContextItem, freshTail int) int64 {
-	if freshTail <= 0 {
-		return int64(^uint64(0) >> 1)
-	}
-	messageItems := make([]backfillContextItem, 0, len(items))
-	for _, item := range items {
-		if item.itemType == "message" && item.messageID.Valid {
-			messageItems = append(messageItems, item)
-		}
-	}
-	if len(messageItems) == 0 {
-		return int64(^uint64(0) >> 1)
-	}
-	tailStart := len(messageItems) - freshTail
-	if tailStart < 0 {
-		tailStart = 0
-	}
-	return messageItems[tailStart].ordinal
-}
-
-type backfillCondensedCandidate struct {
-	targetDepth int
-	chunk       []backfillContextItem
-}
-
-func selectBackfillCondensedCandidate(items []backfillContextItem, opts backfillOptions, hard bool) (backfillCondensedCandidate, bool) {
-	freshTailOrdinal := resolveBackfillFreshTailOrdinal(items, opts.freshTailCount)
-	depthSet := make(map[int]bool)
-	for _, item := range items {
-		if item.ordinal >= freshTailOrdinal {
-			break
-		}
-		if item.itemType == "summary" && item.summaryID.Valid {
-			depthSet[item.depth] = true
-		}
-	}
-	if len(depthSet) == 0 {
-		return backfillCondensedCandidate{}, false
-	}
-	depths := make([]int, 0, len(depthSet))
-	for depth := range depthSet {
-		depths = append(depths, depth)
-	}
-	sort.Ints(depths)
-
-	minChunkTokens := opts.condensedTargetToken
-	floor := opts.leafChunkTokens / 2
-	if floor > minChunkTokens {
-		minChunkTokens = floor
-	}
-	if hard {
-		minChunkTokens = 0
-	}
-
-	for _, depth := range depths {
-		chunk, tokenSum := selectBackfillChunkAtDepth(items, depth, opts.leafChunkTokens, freshTailOrdinal)
-		if len(chunk) == 0 {
-			continue
-		}
-		fanout := opts.condensedFanout
-		if depth == 0 {
-			fanout = opts.leafFanout
-		}
-		if hard {
-			fanout = opts.hardFanout
-		}
-		if len(chunk) < fanout {
-			continue
-		}
-		if tokenSum < minChunkTokens {
-			continue
-		}
-		return backfillCondensedCandidate{targetDepth: depth, chunk: chunk}, true
-	}
-	return backfillCondensedCandidate{}, false
-}
-
-func selectBackfillChunkAtDepth(items []backfillContextItem, depth, chunkTokenBudget int, freshTailOrdinal int64) ([]backfillContextItem, int) {
-	if chunkTokenBudget <= 0 {
-		chunkTokenBudget = 1
-	}
-	chunk := make([]backfillContextItem, 0, 16)
-	tokenSum := 0
-	for _, item := range items {
-		if item.ordinal >= freshTailOrdinal {
-			break
-		}
-		if item.itemType != "summary" || !item.summaryID.Valid {
-			if len(chunk) > 0 {
-				break
-			}
-			continue
-		}
-		if item.depth != depth {
-			if len(chunk) > 0 {
-				break
-			}
-			continue
-		}
-		tokens := item.tokenCount
-		if tokens <= 0 {
-			tokens = 1
-		}
-		if len(chunk) > 0 && tokenSum+tokens > chunkTokenBudget {
-			break
-		}
-		chunk = append(chunk, item)
-		tokenSum += tokens
-		if tokenSum >= chunkTokenBudget {
-			break
-		}
-	}
-	return chunk, tokenSum
-}
-
-func applyBackfillLeafPass(ctx context.Context, db *sql.DB, conversationID int64, chunk []backfillContextItem, opts backfillOptions, summarize backfillSummarizeFn) error {
-	if len(chunk) == 0 {
-		return nil
-	}
-
-	messages, err := loadBackfillMessagesByContextChunk(ctx, db, chunk)
-	if err != nil {
-		return err
-	}
-	if len(messages) == 0 {
-		return nil
-	}
-
-	loc := time.Local
-	sourceParts := make([]string, 0, len(messages))
-	earliest := ""
-	latest := ""
-	for _, message := range messages {
-		ts := formatTimestampWithLoc(message.createdAt, loc)
-		if ts != "" {
-			sourceParts = append(sourceParts, "["+ts+"]\n"+message.content)
+			blank = true
 		} else {
-			sourceParts = append(sourceParts, message.content)
-		}
-		if earliest == "" || message.createdAt < earliest {
-			earliest = message.createdAt
-		}
-		if latest == "" || message.createdAt > latest {
-			latest = message.createdAt
-		}
-	}
-
-	previousContext, err := backfillPriorSummaryContext(ctx, db, conversationID, chunk[0].ordinal, -1, 2)
-	if err != nil {
-		return err
-	}
-	sourceText := strings.Join(sourceParts, "\n\n")
-	targetTokens := opts.leafTargetTokens
-	if targetTokens <= 0 {
-		targetTokens = calculateLeafTargetTokens(estimateTokenCount(sourceText))
-	}
-	prompt, err := renderPrompt(0, PromptVars{
-		TargetTokens:    targetTokens,
-		PreviousContext: previousContext,
-		ChildCount:      len(messages),
-		TimeRange:       formatTimeRange(formatTimestampWithLoc(earliest, loc), formatTimestampWithLoc(latest, loc)),
-		Depth:           0,
-		SourceText:      sourceText,
-	}, opts.promptDir)
-	if err != nil {
-		return fmt.Errorf("render leaf prompt: %w", err)
-	}
-
-	newContent, err := summarize(ctx, prompt, targetTokens)
-	if err != nil {
-		return fmt.Errorf("summarize leaf chunk: %w", err)
-	}
-	newContent = strings.TrimSpace(newContent)
-	if newContent == "" {
-		return errors.New("leaf summarization returned empty content")
-	}
-
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin leaf compaction transaction: %w", err)
-	}
-	rollback := true
-	defer func() {
-		if rollback {
-			_ = tx.Rollback()
-		}
-	}()
-
-	summaryID, err := generateSummaryID(ctx, tx)
-	if err != nil {
-		return err
-	}
-	summaryCreatedAt := messages[len(messages)-1].createdAt
-	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth)
-		VALUES (?, ?, 'leaf', ?, ?, ?, '[]', 0)
-	`, summaryID, conversationID, newContent, estimateTokenCount(newContent), summaryCreatedAt); err != nil {
-		return fmt.Errorf("insert leaf summary %s: %w", summaryID, err)
-	}
-
-	for i, msg := range messages {
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO summary_messages (summary_id, message_id, ordinal)
-			VALUES (?, ?, ?)
-		`, summaryID, msg.messageID, i); err != nil {
-			return fmt.Errorf("insert summary_message for %s: %w", summaryID, err)
+			blank = false
 		}
+		out = append(out, line)
 	}
-
-	startOrdinal := chunk[0].ordinal
-	endOrdinal := chunk[len(chunk)-1].ordinal
-	if err := replaceBackfillContextRangeWithSummary(ctx, tx, conversationID, startOrdinal, endOrdinal, summaryID); err != nil {
-		return err
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit leaf compaction transaction: %w", err)
-	}
-	rollback = false
-	return nil
-}
-
-type backfillChunkMessage struct {
-	messageID int64
-	content   string
-	createdAt string
-}
-
-func loadBackfillMessagesByContextChunk(ctx context.Context, q sqlQueryer, chunk []backfillContextItem) ([]backfillChunkMessage, error) {
-	messages := make([]backfillChunkMessage, 0, len(chunk))
-	for _, item := range chunk {
-		if !item.messageID.Valid {
-			continue
-		}
-		var row backfillChunkMessage
-		row.messageID = item.messageID.Int64
-		if err := q.QueryRowContext(ctx, `
-			SELECT COALESCE(content, ''), COALESCE(created_at, '')
-			FROM messages
-			WHERE message_id = ?
-		`, row.messageID).Scan(&row.content, &row.createdAt); err != nil {
-			return nil, fmt.Errorf("load message %d for backfill chunk: %w", row.messageID, err)
-		}
-		messages = append(messages, row)
-	}
-	return messages, nil
+	return strings.TrimSpace(strings.Join(out, "\n"))
 }
 
-func backfillPriorSummaryContext(ctx context.Context, q sqlQueryer, conversationID int64, beforeOrdinal int64, depthFilter int, take int) (string, error) {
-	if take <= 0 {
-		return "", nil
-	}
-	rows, err := q.QueryContext(ctx, `
-		SELECT ci.summary_id, COALESCE(s.depth, 0), COALESCE(s.content, '')
-		FROM context_items ci
-		JOIN summaries s ON s.summary_id = ci.summary_id
-		WHERE ci.conversation_id = ?
-		  AND ci.item_type = 'summary'
-		  AND ci.ordinal < ?
-		ORDER BY ci.ordinal DESC
-		LIMIT 8
-	`, conversationID, beforeOrdinal)
-	if err != nil {
-		return "", fmt.Errorf("query prior summary context: %w", err)
-	}
-	defer rows.Close()
-
-	parts := make([]string, 0, take)
-	for rows.Next() {
-		var summaryID string
-		var depth int
-		var content string
-		if err := rows.Scan(&summaryID, &depth, &content); err != nil {
-			return "", fmt.Errorf("scan prior summary context row: %w", err)
-		}
-		if depthFilter >= 0 && depth != depthFilter {
-			continue
-		}
-		content = strings.TrimSpace(content)
-		if content == "" {
-			continue
-		}
-		parts = append(parts, content)
-		if len(parts) >= take {
-			break
-		}
+// normalizeBackfillRole maps a session role onto the stored role set. A
+// roleMap entry (keyed by the lowercased source role) wins; otherwise known
+// roles pass through and anything else falls back to assistant.
+func normalizeBackfillRole(role string, roleMap map[string]string) string {
+	if mapped, ok := roleMap[strings.ToLower(strings.TrimSpace(role))]; ok {
+		return mapped
 	}
-	if err := rows.Err(); err != nil {
-		return "", fmt.Errorf("iterate prior summary context rows: %w", err)
-	}
-	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
-		parts[i], parts[j] = parts[j], parts[i]
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "system", "user", "assistant", "tool":
+		return strings.ToLower(strings.TrimSpace(role))
+	default:
+		// Matches runtime behavior where unknown roles are preserved in parts
+		// and treated as assistant for core message rows.
+		return "assistant"
 	}
-	return strings.Join(parts, "\n\n"), nil
 }
 
-func applyBackfillCondensedPass(ctx context.Context, db *sql.DB, conversationID int64, candidate backfillCondensedCandidate, opts backfillOptions, summarize backfillSummarizeFn) error {
-	if len(candidate.chunk) == 0 {
-		return nil
-	}
-
-	summaries, err := loadBackfillSummariesByChunk(ctx, db, candidate.chunk)
-	if err != nil {
-		return err
-	}
-	if len(summaries) == 0 {
-		return nil
-	}
-
-	loc := time.Local
-	sourceParts := make([]string, 0, len(summaries))
-	earliest := ""
-	latest := ""
-	totalDescendants := 0
-	for _, summary := range summaries {
-		rangeText := formatTimeRange(formatTimestampWithLoc(summary.earliestAt, loc), formatTimestampWithLoc(summary.latestAt, loc))
-		if rangeText == "" {
-			rangeText = formatTimestampWithLoc(summary.createdAt, loc)
-		}
-		if rangeText != "" {
-			sourceParts = append(sourceParts, "["+rangeText+"]\n"+summary.content)
-		} else {
-			sourceParts = append(sourceParts, summary.content)
-		}
-
-		start := summary.earliestAt
-		if strings.TrimSpace(start) == "" {
-			start = summary.createdAt
-		}
-		end := summary.latestAt
-		if strings.TrimSpace(end) == "" {
-			end = summary.createdAt
-		}
-		if earliest == "" || (start != "" && start < earliest) {
-			earliest = start
-		}
-		if latest == "" || (end != "" && end > latest) {
-			latest = end
-		}
-
-		if summary.descendants < 0 {
-			summary.descendants = 0
-		}
-		totalDescendants += summary.descendants + 1
-	}
-
-	previousContext := ""
-	if candidate.targetDepth == 0 {
-		previousContext, err = backfillPriorSummaryContext(ctx, db, conversationID, candidate.chunk[0].ordinal, candidate.targetDepth, 2)
-		if err != nil {
-			return err
-		}
-	}
-
-	targetTokens := opts.condensedTargetToken
-	if targetTokens <= 0 {
-		targetTokens = condensedTargetTokens
-	}
-	sourceText := strings.Join(sourceParts, "\n\n")
-	prompt, err := renderPrompt(candidate.targetDepth+1, PromptVars{
-		TargetTokens:    targetTokens,
-		PreviousContext: previousContext,
-		ChildCount:      len(summaries),
-		TimeRange:       formatTimeRange(formatTimestampWithLoc(earliest, loc), formatTimestampWithLoc(latest, loc)),
-		Depth:           candidate.targetDepth + 1,
-		SourceText:      sourceText,
-	}, opts.promptDir)
-	if err != nil {
-		return fmt.Errorf("render condensed prompt: %w", err)
-	}
-
-	newContent, err := summarize(ctx, prompt, targetTokens)
-	if err != nil {
-		return fmt.Errorf("summarize condensed chunk: %w", err)
-	}
-	newContent = strings.TrimSpace(newContent)
-	if newContent == "" {
-		return errors.New("condensed summarization returned empty content")
-	}
-
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin condensed compaction transaction: %w", err)
-	}
-	rollback := true
-	defer func() {
-		if rollback {
-			_ = tx.Rollback()
-		}
-	}()
-
-	summaryID, err := generateSummaryID(ctx, tx)
-	if err != nil {
-		return err
-	}
-	summaryCreatedAt := latest
-	if strings.TrimSpace(summaryCreatedAt) == "" {
-		summaryCreatedAt = time.Now().UTC().Format("2006-01-02 15:04:05")
-	}
-	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth, earliest_at, latest_at, descendant_count)
-		VALUES (?, ?, 'condensed', ?, ?, ?, '[]', ?, ?, ?, ?)
-	`, summaryID, conversationID, newContent, estimateTokenCount(newContent), summaryCreatedAt, candidate.targetDepth+1, earliest, latest, totalDescendants); err != nil {
-		// Compatibility fallback for DBs that have not yet migrated metadata columns.
-		if _, fallbackErr := tx.ExecContext(ctx, `
-			INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth)
-			VALUES (?, ?, 'condensed', ?, ?, ?, '[]', ?)
-		`, summaryID, conversationID, newContent, estimateTokenCount(newContent), summaryCreatedAt, candidate.targetDepth+1); fallbackErr != nil {
-			return fmt.Errorf("insert condensed summary %s: %w", summaryID, err)
-		}
-	}
-
-	for i, summary := range summaries {
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
-			VALUES (?, ?, ?)
-		`, summaryID, summary.summaryID, i); err != nil {
-			return fmt.Errorf("insert summary_parent %s -> %s: %w", summaryID, summary.summaryID, err)
-		}
-	}
-
-	startOrdinal := candidate.chunk[0].ordinal
-	endOrdinal := candidate.chunk[len(candidate.chunk)-1].ordinal
-	if err := replaceBackfillContextRangeWithSummary(ctx, tx, conversationID, startOrdinal, endOrdinal, summaryID); err != nil {
-		return err
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit condensed compaction transaction: %w", err)
+// parseBackfillRoleMap parses a --role-map value such as
+// "developer=user,function=tool". Targets must be stored roles so a typo
+// can't create a role the assembler doesn't understand.
+func parseBackfillRoleMap(spec string) (map[string]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
 	}
-	rollback = false
-	return nil
-}
-
-func loadBackfillSummariesByChunk(ctx context.Context, q sqlQueryer, chunk []backfillContextItem) ([]backfillSummaryRecord, error) {
-	summaries := make([]backfillSummaryRecord, 0, len(chunk))
-	for _, item := range chunk {
-		if !item.summaryID.Valid {
+	roleMap := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
 			continue
 		}
-		row := backfillSummaryRecord{summaryID: item.summaryID.String}
-		err := q.QueryRowContext(ctx, `
-			SELECT
-				COALESCE(content, ''),
-				COALESCE(token_count, 0),
-				COALESCE(depth, 0),
-				COALESCE(kind, 'leaf'),
-				COALESCE(created_at, ''),
-				COALESCE(earliest_at, ''),
-				COALESCE(latest_at, ''),
-				COALESCE(descendant_count, 0)
-			FROM summaries
-			WHERE summary_id = ?
-		`, row.summaryID).Scan(&row.content, &row.tokenCount, &row.depth, &row.kind, &row.createdAt, &row.earliestAt, &row.latestAt, &row.descendants)
-		if err != nil {
-			if strings.Contains(strings.ToLower(err.Error()), "no such column") {
-				if fallbackErr := q.QueryRowContext(ctx, `
-					SELECT
-						COALESCE(content, ''),
-						COALESCE(token_count, 0),
-						COALESCE(depth, 0),
-						COALESCE(kind, 'leaf'),
-						COALESCE(created_at, '')
-					FROM summaries
-					WHERE summary_id = ?
-				`, row.summaryID).Scan(&row.content, &row.tokenCount, &row.depth, &row.kind, &row.createdAt); fallbackErr != nil {
-					return nil, fmt.Errorf("load summary %s for condensed pass: %w", row.summaryID, fallbackErr)
-				}
-			} else {
-				return nil, fmt.Errorf("load summary %s for condensed pass: %w", row.summaryID, err)
-			}
-		}
-		summaries = append(summaries, row)
-	}
-	return summaries, nil
-}
-
-func replaceBackfillContextRangeWithSummary(ctx context.Context, q sqlQueryer, conversationID, startOrdinal, endOrdinal int64, summaryID string) error {
-	if _, err := q.ExecContext(ctx, `
-		DELETE FROM context_items
-		WHERE conversation_id = ?
-		  AND ordinal >= ?
-		  AND ordinal <= ?
-	`, conversationID, startOrdinal, endOrdinal); err != nil {
-		return fmt.Errorf("delete context range [%d,%d] for conversation %d: %w", startOrdinal, endOrdinal, conversationID, err)
-	}
-
-	if _, err := q.ExecContext(ctx, `
-		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id, created_at)
-		VALUES (?, ?, 'summary', ?, datetime('now'))
-	`, conversationID, startOrdinal, summaryID); err != nil {
-		return fmt.Errorf("insert replacement summary %s at ordinal %d: %w", summaryID, startOrdinal, err)
-	}
-
-	rows, err := q.QueryContext(ctx, `
-		SELECT ordinal
-		FROM context_items
-		WHERE conversation_id = ?
-		ORDER BY ordinal ASC
-	`, conversationID)
-	if err != nil {
-		return fmt.Errorf("query context ordinals for resequence: %w", err)
-	}
-	ordinals := make([]int64, 0, 256)
-	for rows.Next() {
-		var ordinal int64
-		if err := rows.Scan(&ordinal); err != nil {
-			rows.Close()
-			return fmt.Errorf("scan context ordinal for resequence: %w", err)
-		}
-		ordinals = append(ordinals, ordinal)
-	}
-	if err := rows.Err(); err != nil {
-		rows.Close()
-		return fmt.Errorf("iterate context ordinals for resequence: %w", err)
-	}
-	if err := rows.Close(); err != nil {
-		return fmt.Errorf("close context ordinal rows: %w", err)
-	}
-
-	for i, oldOrdinal := range ordinals {
-		tmpOrdinal := -int64(i + 1)
-		if _, err := q.ExecContext(ctx, `
-			UPDATE context_items
-			SET ordinal = ?
-			WHERE conversation_id = ? AND ordinal = ?
-		`, tmpOrdinal, conversationID, oldOrdinal); err != nil {
-			return fmt.Errorf("stage context ordinal %d -> %d: %w", oldOrdinal, tmpOrdinal, err)
+		from, to, ok := strings.Cut(entry, "=")
+		from = strings.ToLower(strings.TrimSpace(from))
+		to = strings.ToLower(strings.TrimSpace(to))
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid --role-map entry %q (want source=role)", entry)
 		}
-	}
-	for i := range ordinals {
-		finalOrdinal := int64(i)
-		tmpOrdinal := -int64(i + 1)
-		if _, err := q.ExecContext(ctx, `
-			UPDATE context_items
-			SET ordinal = ?
-			WHERE conversation_id = ? AND ordinal = ?
-		`, finalOrdinal, conversationID, tmpOrdinal); err != nil {
-			return fmt.Errorf("finalize context ordinal %d -> %d: %w", tmpOrdinal, finalOrdinal, err)
+		switch to {
+		case "system", "user", "assistant", "tool":
+		default:
+			return nil, fmt.Errorf("invalid --role-map target %q for %q (want system, user, assistant, or tool)", to, from)
 		}
+		roleMap[from] = to
 	}
-	return nil
+	return roleMap, nil
 }
 
-func backfillCanForceSingleRoot(items []backfillContextItem) bool {
-	summaryCount := 0
-	for _, item := range items {
-		if item.itemType == "message" && item.messageID.Valid {
-			return false
-		}
-		if item.itemType == "summary" && item.summaryID.Valid {
-			summaryCount++
-		}
+func normalizeBackfillTimestamp(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return time.Now().UTC().Format("2006-01-02 15:04:05")
 	}
-	return summaryCount > 1
+	if parsed, err := lcm.ParseSQLiteTime(trimmed); err == nil {
+		return parsed.UTC().Format("2006-01-02 15:04:05")
+	}
+	return time.Now().UTC().Format("2006-01-02 15:04:05")
 }
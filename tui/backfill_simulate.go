@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// backfillSimulationTables are the tables compaction reads and writes, with
+// the filter that selects one conversation's rows from each.
+var backfillSimulationTables = []struct {
+	name   string
+	filter string
+}{
+	{name: "conversations", filter: "conversation_id = ?"},
+	{name: "messages", filter: "conversation_id = ?"},
+	{name: "summaries", filter: "conversation_id = ?"},
+	{name: "context_items", filter: "conversation_id = ?"},
+	{name: "summary_parents", filter: "summary_id IN (SELECT summary_id FROM src.summaries WHERE conversation_id = ?)"},
+	{name: "summary_messages", filter: "summary_id IN (SELECT summary_id FROM src.summaries WHERE conversation_id = ?)"},
+}
+
+// backfillCompactionShape is the part of a conversation's DAG that
+// recompaction changes.
+type backfillCompactionShape struct {
+	summariesByDepth map[int]int
+	contextItems     int
+	contextSummaries int
+	contextTokens    int
+}
+
+// backfillCompactionSimulation compares a conversation before and after a
+// simulated recompaction.
+type backfillCompactionSimulation struct {
+	current   backfillCompactionShape
+	simulated backfillCompactionShape
+	stats     lcm.CompactionStats
+	live      bool
+	// ignore and ignored are the ignore patterns applied to the copy and
+	// how many context messages its --ignore globs dropped before
+	// compaction. sessionIgnoredBy is the configured pattern matching the
+	// conversation's session key, if any.
+	ignore           *contextIgnoreRules
+	ignored          int
+	sessionIgnoredBy string
+}
+
+// cloneConversationInMemory copies conversationID's rows from the LCM DB at
+// dbPath into a private in-memory database with the same table definitions.
+// Compaction can then run against the copy without touching the real DB.
+func cloneConversationInMemory(ctx context.Context, dbPath string, conversationID int64) (*sql.DB, error) {
+	mem, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("open in-memory simulation db: %w", err)
+	}
+	// Every connection to :memory: is a separate database; keep one.
+	mem.SetMaxOpenConns(1)
+	ok := false
+	defer func() {
+		if !ok {
+			_ = mem.Close()
+		}
+	}()
+
+	if _, err := mem.ExecContext(ctx, `ATTACH DATABASE ? AS src`, dbPath); err != nil {
+		return nil, fmt.Errorf("attach LCM database for simulation: %w", err)
+	}
+	for _, table := range backfillSimulationTables {
+		var createSQL string
+		if err := mem.QueryRowContext(ctx, `
+			SELECT sql FROM src.sqlite_master WHERE type = 'table' AND name = ?
+		`, table.name).Scan(&createSQL); err != nil {
+			return nil, fmt.Errorf("read %s schema for simulation: %w", table.name, err)
+		}
+		if _, err := mem.ExecContext(ctx, createSQL); err != nil {
+			return nil, fmt.Errorf("create %s for simulation: %w", table.name, err)
+		}
+		if _, err := mem.ExecContext(ctx, fmt.Sprintf(
+			`INSERT INTO main.%s SELECT * FROM src.%s WHERE %s`, table.name, table.name, table.filter,
+		), conversationID); err != nil {
+			return nil, fmt.Errorf("copy %s for simulation: %w", table.name, err)
+		}
+	}
+	if _, err := mem.ExecContext(ctx, `DETACH DATABASE src`); err != nil {
+		return nil, fmt.Errorf("detach LCM database after simulation copy: %w", err)
+	}
+	ok = true
+	return mem, nil
+}
+
+func loadBackfillCompactionShape(ctx context.Context, q sqlQueryer, conversationID int64) (backfillCompactionShape, error) {
+	shape := backfillCompactionShape{summariesByDepth: make(map[int]int)}
+	rows, err := q.QueryContext(ctx, `
+		SELECT COALESCE(depth, 0), COUNT(*)
+		FROM summaries
+		WHERE conversation_id = ?
+		GROUP BY COALESCE(depth, 0)
+	`, conversationID)
+	if err != nil {
+		return shape, fmt.Errorf("count summaries by depth for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var depth, count int
+		if err := rows.Scan(&depth, &count); err != nil {
+			return shape, fmt.Errorf("scan summary depth count: %w", err)
+		}
+		shape.summariesByDepth[depth] = count
+	}
+	if err := rows.Err(); err != nil {
+		return shape, fmt.Errorf("iterate summary depth counts: %w", err)
+	}
+
+	items, err := lcm.LoadContextItems(ctx, q, conversationID)
+	if err != nil {
+		return shape, err
+	}
+	shape.contextItems = len(items)
+	for _, item := range items {
+		if item.SummaryID.Valid {
+			shape.contextSummaries++
+		}
+		shape.contextTokens += item.TokenCount
+	}
+	return shape, nil
+}
+
+// simulateBackfillRecompaction runs the compaction loop with opts against an
+// in-memory copy of conversationID. With the stub summarizer the result is
+// structure only: node counts are exact, token counts approximate each
+// pass's target. Context messages matching an --ignore glob are dropped from
+// the copy first. Nothing is written to dbPath.
+func simulateBackfillRecompaction(ctx context.Context, db *sql.DB, dbPath string, conversationID int64, opts backfillOptions, summarize backfillSummarizeFn, live bool, ignore *contextIgnoreRules) (backfillCompactionSimulation, error) {
+	sim := backfillCompactionSimulation{live: live, ignore: ignore}
+	var err error
+	if sim.current, err = loadBackfillCompactionShape(ctx, db, conversationID); err != nil {
+		return sim, err
+	}
+	mem, err := cloneConversationInMemory(ctx, dbPath, conversationID)
+	if err != nil {
+		return sim, err
+	}
+	defer mem.Close()
+
+	if ignore != nil {
+		sessionKey, err := loadConversationSessionKey(ctx, db, conversationID)
+		if err != nil {
+			return sim, err
+		}
+		if sessionKey != "" {
+			sim.sessionIgnoredBy = ignore.matchSessionKey(sessionKey)
+		}
+	}
+	if sim.ignored, err = dropIgnoredContextMessages(ctx, mem, conversationID, ignore); err != nil {
+		return sim, err
+	}
+	if sim.stats, err = runBackfillCompaction(ctx, mem, conversationID, opts, summarize); err != nil {
+		return sim, fmt.Errorf("simulate recompaction: %w", err)
+	}
+	if sim.simulated, err = loadBackfillCompactionShape(ctx, mem, conversationID); err != nil {
+		return sim, err
+	}
+	return sim, nil
+}
+
+// stubBackfillSummarize is the structure-only summarizer for simulations.
+func stubBackfillSummarize(_ context.Context, prompt string, targetTokens int) (string, error) {
+	return summarizeStub(prompt, targetTokens), nil
+}
+
+func printBackfillCompactionSimulation(sim backfillCompactionSimulation) {
+	mode := "structure only, stub summarizer"
+	if sim.live {
+		mode = "live summarize calls"
+	}
+	fmt.Printf("\nRecompaction simulation (%s): leaf=%d condensed=%d single-root=%d passes\n",
+		mode, sim.stats.LeafPasses, sim.stats.CondensedPasses, sim.stats.RootFoldPasses)
+	if sim.sessionIgnoredBy != "" {
+		fmt.Printf("Note: the session key matches ignore pattern %s; the live plugin stores nothing for this session.\n", sim.sessionIgnoredBy)
+	}
+	if patterns := sim.ignore.contentPatterns(); len(patterns) > 0 {
+		fmt.Printf("Ignored %d context messages matching --ignore %s\n", sim.ignored, strings.Join(patterns, ", "))
+	}
+
+	depthSet := make(map[int]bool)
+	for depth := range sim.current.summariesByDepth {
+		depthSet[depth] = true
+	}
+	for depth := range sim.simulated.summariesByDepth {
+		depthSet[depth] = true
+	}
+	depths := make([]int, 0, len(depthSet))
+	for depth := range depthSet {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+
+	table := cliTable{
+		indent: "  ",
+		columns: []cliTableColumn{
+			{header: ""},
+			{header: "current", align: cliAlignRight},
+			{header: "simulated", align: cliAlignRight},
+			{header: "delta", align: cliAlignRight},
+		},
+	}
+	addRow := func(label string, current, simulated int) {
+		table.addRow(label, strconv.Itoa(current), strconv.Itoa(simulated), fmt.Sprintf("%+d", simulated-current))
+	}
+	for _, depth := range depths {
+		addRow(fmt.Sprintf("d%d summaries", depth), sim.current.summariesByDepth[depth], sim.simulated.summariesByDepth[depth])
+	}
+	addRow("context items", sim.current.contextItems, sim.simulated.contextItems)
+	addRow("context summaries", sim.current.contextSummaries, sim.simulated.contextSummaries)
+	addRow("context tokens", sim.current.contextTokens, sim.simulated.contextTokens)
+	for _, line := range table.render(resolveCLIOutputStyle()) {
+		fmt.Println(line)
+	}
+	if !sim.live {
+		fmt.Println("Summary token counts are stub estimates; pass --simulate-live to make real summarize calls (billed, still no writes).")
+	}
+}
@@ -9,36 +9,92 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 func TestBackfillImportCreatesConversationMessagesAndContext(t *testing.T) {
 	db := newBackfillTestDB(t)
 	ctx := context.Background()
 
-	input := backfillSessionInput{
-		agent:       "agent-a",
-		sessionID:   "session-import",
-		title:       "Imported Session",
-		messages:    makeBackfillMessages(6),
-		sessionPath: "/tmp/session-import.jsonl",
+	input := lcm.BackfillInput{
+		Agent:       "agent-a",
+		SessionID:   "session-import",
+		Title:       "Imported Session",
+		Messages:    makeBackfillMessages(6),
+		SessionPath: "/tmp/session-import.jsonl",
 	}
 
-	result, err := applyBackfillImport(ctx, db, input)
+	result, err := lcm.ApplyBackfillImport(ctx, db, input)
 	if err != nil {
 		t.Fatalf("apply backfill import: %v", err)
 	}
-	if !result.imported {
+	if !result.Imported {
 		t.Fatalf("expected import to run")
 	}
-	if result.messageCount != len(input.messages) {
-		t.Fatalf("imported message count mismatch: got=%d want=%d", result.messageCount, len(input.messages))
+	if result.MessageCount != len(input.Messages) {
+		t.Fatalf("imported message count mismatch: got=%d want=%d", result.MessageCount, len(input.Messages))
 	}
 
 	assertCount(t, db, `SELECT COUNT(*) FROM conversations WHERE session_id = 'session-import'`, 1)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, len(input.messages), result.conversationID)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ? AND identity_hash IS NOT NULL AND identity_hash != ''`, len(input.messages), result.conversationID)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ? AND item_type = 'message'`, len(input.messages), result.conversationID)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM message_parts mp JOIN messages m ON m.message_id = mp.message_id WHERE m.conversation_id = ?`, len(input.messages), result.conversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, len(input.Messages), result.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ? AND identity_hash IS NOT NULL AND identity_hash != ''`, len(input.Messages), result.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ? AND item_type = 'message'`, len(input.Messages), result.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM message_parts mp JOIN messages m ON m.message_id = mp.message_id WHERE m.conversation_id = ?`, len(input.Messages), result.ConversationID)
+}
+
+func TestBackfillImportStagesSeqRanges(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	messages := makeBackfillMessages(10)
+	input := func(start, end int) lcm.BackfillInput {
+		return lcm.BackfillInput{
+			Agent:       "agent-staged",
+			SessionID:   "session-staged",
+			Messages:    messages,
+			SessionPath: "/tmp/session-staged.jsonl",
+			SeqRange:    lcm.SeqRange{Set: true, Start: start, End: end},
+		}
+	}
+
+	if _, err := lcm.ApplyBackfillImport(ctx, db, input(3, 5)); err == nil || !strings.Contains(err.Error(), "gap") {
+		t.Fatalf("expected a first range past seq 0 to be refused, got %v", err)
+	}
+	first, err := lcm.ApplyBackfillImport(ctx, db, input(0, 3))
+	if err != nil {
+		t.Fatalf("import first range: %v", err)
+	}
+	if !first.Imported || first.FirstSeq != 0 || first.LastSeq != 3 || first.TotalMessages != 4 {
+		t.Fatalf("unexpected first range result %+v", first)
+	}
+	// Compaction between stages leaves context ordinals that do not match seqs.
+	mustExec(t, db, `DELETE FROM context_items WHERE ordinal < 2`)
+
+	// Overlapping the previous range only appends the unimported part.
+	second, err := lcm.ApplyBackfillImport(ctx, db, input(2, 6))
+	if err != nil {
+		t.Fatalf("import overlapping range: %v", err)
+	}
+	if second.ConversationID != first.ConversationID || second.FirstSeq != 4 || second.LastSeq != 6 || second.MessageCount != 3 || second.TotalMessages != 7 {
+		t.Fatalf("unexpected second range result %+v", second)
+	}
+	if _, err := lcm.ApplyBackfillImport(ctx, db, input(8, -1)); err == nil || !strings.Contains(err.Error(), "start at 7") {
+		t.Fatalf("expected a gap after seq 6 to be refused, got %v", err)
+	}
+	again, err := lcm.ApplyBackfillImport(ctx, db, input(0, 6))
+	if err != nil || again.Imported || again.HighestSeq != 6 {
+		t.Fatalf("expected an imported range to be a no-op, got %+v (%v)", again, err)
+	}
+	rest, err := lcm.ApplyBackfillImport(ctx, db, input(7, -1))
+	if err != nil || rest.LastSeq != 9 {
+		t.Fatalf("import rest of file: %+v (%v)", rest, err)
+	}
+
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, 10, first.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(DISTINCT seq) FROM messages WHERE conversation_id = ? AND seq BETWEEN 0 AND 9`, 10, first.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ?`, 8, first.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items ci JOIN messages m ON m.message_id = ci.message_id WHERE ci.conversation_id = ? AND ci.ordinal = 4 AND m.seq = 4`, 1, first.ConversationID)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'backfill' AND detail LIKE 'imported seq %'`, 3)
 }
 
 func TestBackfillDryRunMakesNoWrites(t *testing.T) {
@@ -85,14 +141,14 @@ func TestBackfillCompactionBuildsHierarchy(t *testing.T) {
 	db := newBackfillTestDB(t)
 	ctx := context.Background()
 
-	input := backfillSessionInput{
-		agent:       "agent-hierarchy",
-		sessionID:   "session-hierarchy",
-		title:       "Hierarchy",
-		messages:    makeBackfillMessages(10),
-		sessionPath: "/tmp/session-hierarchy.jsonl",
+	input := lcm.BackfillInput{
+		Agent:       "agent-hierarchy",
+		SessionID:   "session-hierarchy",
+		Title:       "Hierarchy",
+		Messages:    makeBackfillMessages(10),
+		SessionPath: "/tmp/session-hierarchy.jsonl",
 	}
-	result, err := applyBackfillImport(ctx, db, input)
+	result, err := lcm.ApplyBackfillImport(ctx, db, input)
 	if err != nil {
 		t.Fatalf("apply backfill import: %v", err)
 	}
@@ -107,20 +163,103 @@ func TestBackfillCompactionBuildsHierarchy(t *testing.T) {
 		hardFanout:           2,
 		freshTailCount:       0,
 	}
-	stats, err := runBackfillCompaction(ctx, db, result.conversationID, opts, summarizer.summarize)
+	stats, err := runBackfillCompaction(ctx, db, result.ConversationID, opts, summarizer.summarize)
 	if err != nil {
 		t.Fatalf("run compaction: %v", err)
 	}
-	if stats.leafPasses == 0 {
+	if stats.LeafPasses == 0 {
 		t.Fatalf("expected at least one leaf pass")
 	}
-	if stats.condensedPasses == 0 {
+	if stats.CondensedPasses == 0 {
 		t.Fatalf("expected at least one condensed pass")
 	}
 
-	assertCountAtLeast(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = ? AND depth = 0`, 1, result.conversationID)
-	assertCountAtLeast(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = ? AND depth >= 1`, 1, result.conversationID)
-	assertCountAtLeast(t, db, `SELECT COUNT(*) FROM summary_parents sp JOIN summaries s ON s.summary_id = sp.summary_id WHERE s.conversation_id = ?`, 1, result.conversationID)
+	assertCountAtLeast(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = ? AND depth = 0`, 1, result.ConversationID)
+	assertCountAtLeast(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = ? AND depth >= 1`, 1, result.ConversationID)
+	assertCountAtLeast(t, db, `SELECT COUNT(*) FROM summary_parents sp JOIN summaries s ON s.summary_id = sp.summary_id WHERE s.conversation_id = ?`, 1, result.ConversationID)
+}
+
+func TestBackfillCompactionSummarizesOversizedMessageInSegments(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	paragraphs := make([]string, 40)
+	for i := range paragraphs {
+		paragraphs[i] = fmt.Sprintf("para-%d %s", i, strings.Repeat("giant tool output line ", 8))
+	}
+	messages := makeBackfillMessages(6)
+	messages[2].Content = strings.Join(paragraphs, "\n\n")
+	result, err := lcm.ApplyBackfillImport(ctx, db, lcm.BackfillInput{
+		Agent:       "agent-giant",
+		SessionID:   "session-giant",
+		Title:       "Giant",
+		Messages:    messages,
+		SessionPath: "/tmp/session-giant.jsonl",
+	})
+	if err != nil {
+		t.Fatalf("apply backfill import: %v", err)
+	}
+
+	summarizer := &stubBackfillSummarizer{}
+	var prompts []string
+	record := func(ctx context.Context, prompt string, targetTokens int) (string, error) {
+		prompts = append(prompts, prompt)
+		return summarizer.summarize(ctx, prompt, targetTokens)
+	}
+	opts := backfillOptions{
+		leafChunkTokens:      220,
+		leafTargetTokens:     64,
+		condensedTargetToken: 96,
+		leafFanout:           2,
+		condensedFanout:      2,
+		hardFanout:           2,
+		freshTailCount:       0,
+	}
+	stats, err := runBackfillCompaction(ctx, db, result.ConversationID, opts, record)
+	if err != nil {
+		t.Fatalf("run compaction: %v", err)
+	}
+	if stats.LeafPasses == 0 {
+		t.Fatal("expected at least one leaf pass")
+	}
+	for _, prompt := range prompts {
+		if strings.Contains(prompt, "para-0 ") && strings.Contains(prompt, "para-39 ") {
+			t.Fatal("the oversized message was sent in a single prompt")
+		}
+	}
+
+	assertCountQuery(t, db, `
+		SELECT COUNT(*) FROM summary_messages sm
+		JOIN messages m ON m.message_id = sm.message_id
+		WHERE m.conversation_id = ? AND m.seq = 2
+	`, 1, result.ConversationID)
+	assertCountQuery(t, db, `
+		SELECT COUNT(*) FROM audit_log
+		WHERE conversation_id = ? AND command = 'compact' AND detail LIKE '%oversized message%'
+	`, 1, result.ConversationID)
+}
+
+func TestSplitBackfillSourceSegmentsRespectsBudget(t *testing.T) {
+	text := strings.Join([]string{
+		"short paragraph",
+		strings.Repeat("line in a long paragraph\n", 30),
+		strings.Repeat("word ", 400),
+	}, "\n\n")
+	segments := lcm.SplitSourceSegments(text, 50)
+	if len(segments) < 3 {
+		t.Fatalf("expected several segments, got %d", len(segments))
+	}
+	for i, segment := range segments {
+		if tokens := lcm.EstimateTokenCount(segment); tokens > 50 {
+			t.Fatalf("segment %d is %d tokens, over the 50-token budget", i, tokens)
+		}
+	}
+	if !strings.HasPrefix(segments[0], "short paragraph") {
+		t.Fatalf("expected the first segment to start at the first paragraph, got %q", segments[0])
+	}
+	if got := lcm.SplitSourceSegments("small", 50); len(got) != 1 || got[0] != "small" {
+		t.Fatalf("text under budget should be one segment, got %q", got)
+	}
 }
 
 func TestBackfillSingleRootForcedFold(t *testing.T) {
@@ -159,7 +298,7 @@ func TestBackfillSingleRootForcedFold(t *testing.T) {
 	if err != nil {
 		t.Fatalf("run compaction single-root: %v", err)
 	}
-	if stats.rootFoldPasses == 0 {
+	if stats.RootFoldPasses == 0 {
 		t.Fatalf("expected at least one forced single-root fold pass")
 	}
 
@@ -171,19 +310,19 @@ func TestBackfillTransplantIntegration(t *testing.T) {
 	db := newBackfillTestDB(t)
 	ctx := context.Background()
 
-	input := backfillSessionInput{
-		agent:       "agent-source",
-		sessionID:   "session-source",
-		title:       "Source",
-		messages:    makeBackfillMessages(8),
-		sessionPath: "/tmp/session-source.jsonl",
+	input := lcm.BackfillInput{
+		Agent:       "agent-source",
+		SessionID:   "session-source",
+		Title:       "Source",
+		Messages:    makeBackfillMessages(8),
+		SessionPath: "/tmp/session-source.jsonl",
 	}
-	importResult, err := applyBackfillImport(ctx, db, input)
+	importResult, err := lcm.ApplyBackfillImport(ctx, db, input)
 	if err != nil {
 		t.Fatalf("apply backfill import: %v", err)
 	}
 	summarizer := &stubBackfillSummarizer{}
-	_, err = runBackfillCompaction(ctx, db, importResult.conversationID, backfillOptions{
+	_, err = runBackfillCompaction(ctx, db, importResult.ConversationID, backfillOptions{
 		leafChunkTokens:      120,
 		leafTargetTokens:     64,
 		condensedTargetToken: 96,
@@ -235,50 +374,50 @@ func TestBackfillImportIdempotencyGuard(t *testing.T) {
 	db := newBackfillTestDB(t)
 	ctx := context.Background()
 
-	input := backfillSessionInput{
-		agent:       "agent-idempotent",
-		sessionID:   "session-idempotent",
-		title:       "Idempotent",
-		messages:    makeBackfillMessages(5),
-		sessionPath: "/tmp/session-idempotent.jsonl",
+	input := lcm.BackfillInput{
+		Agent:       "agent-idempotent",
+		SessionID:   "session-idempotent",
+		Title:       "Idempotent",
+		Messages:    makeBackfillMessages(5),
+		SessionPath: "/tmp/session-idempotent.jsonl",
 	}
 
-	first, err := applyBackfillImport(ctx, db, input)
+	first, err := lcm.ApplyBackfillImport(ctx, db, input)
 	if err != nil {
 		t.Fatalf("first import: %v", err)
 	}
-	if !first.imported {
+	if !first.Imported {
 		t.Fatalf("expected first import to write rows")
 	}
 
-	second, err := applyBackfillImport(ctx, db, input)
+	second, err := lcm.ApplyBackfillImport(ctx, db, input)
 	if err != nil {
 		t.Fatalf("second import: %v", err)
 	}
-	if second.imported {
+	if second.Imported {
 		t.Fatalf("expected second import to be skipped by idempotency guard")
 	}
-	if second.conversationID != first.conversationID {
-		t.Fatalf("expected idempotent import to reuse conversation %d, got %d", first.conversationID, second.conversationID)
+	if second.ConversationID != first.ConversationID {
+		t.Fatalf("expected idempotent import to reuse conversation %d, got %d", first.ConversationID, second.ConversationID)
 	}
 
 	assertCount(t, db, `SELECT COUNT(*) FROM conversations WHERE session_id = 'session-idempotent'`, 1)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, len(input.messages), first.conversationID)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ?`, len(input.messages), first.conversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, len(input.Messages), first.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ?`, len(input.Messages), first.ConversationID)
 }
 
 func TestBackfillWorkflowExistingImportedSessionSkipsCompactionWithoutRecompact(t *testing.T) {
 	db := newBackfillTestDB(t)
 	ctx := context.Background()
 
-	input := backfillSessionInput{
-		agent:       "agent-existing",
-		sessionID:   "session-existing",
-		title:       "Existing Session",
-		messages:    makeBackfillMessages(6),
-		sessionPath: "/tmp/session-existing.jsonl",
+	input := lcm.BackfillInput{
+		Agent:       "agent-existing",
+		SessionID:   "session-existing",
+		Title:       "Existing Session",
+		Messages:    makeBackfillMessages(6),
+		SessionPath: "/tmp/session-existing.jsonl",
 	}
-	importResult, err := applyBackfillImport(ctx, db, input)
+	importResult, err := lcm.ApplyBackfillImport(ctx, db, input)
 	if err != nil {
 		t.Fatalf("seed import: %v", err)
 	}
@@ -299,22 +438,22 @@ func TestBackfillWorkflowExistingImportedSessionSkipsCompactionWithoutRecompact(
 	if err != nil {
 		t.Fatalf("run workflow existing session without recompact: %v", err)
 	}
-	if result.imported {
+	if result.Imported {
 		t.Fatalf("expected idempotency guard to skip message import")
 	}
-	if result.conversationID != importResult.conversationID {
-		t.Fatalf("expected existing conversation ID %d, got %d", importResult.conversationID, result.conversationID)
+	if result.ConversationID != importResult.ConversationID {
+		t.Fatalf("expected existing conversation ID %d, got %d", importResult.ConversationID, result.ConversationID)
 	}
-	if stats.leafPasses != 0 || stats.condensedPasses != 0 || stats.rootFoldPasses != 0 {
+	if stats.LeafPasses != 0 || stats.CondensedPasses != 0 || stats.RootFoldPasses != 0 {
 		t.Fatalf("expected no compaction passes without --recompact, got %+v", stats)
 	}
 	if summarizer.counter != 0 {
 		t.Fatalf("expected summarizer not to run without --recompact")
 	}
 
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, len(input.messages), importResult.conversationID)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ? AND item_type = 'message'`, len(input.messages), importResult.conversationID)
-	assertCountQuery(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = ?`, 0, importResult.conversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = ?`, len(input.Messages), importResult.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ? AND item_type = 'message'`, len(input.Messages), importResult.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = ?`, 0, importResult.ConversationID)
 }
 
 func TestBackfillWorkflowRecompactSingleRootOnExistingSession(t *testing.T) {
@@ -338,12 +477,12 @@ func TestBackfillWorkflowRecompactSingleRootOnExistingSession(t *testing.T) {
 			(77, 1, 'summary', 'sum_recompact_b', datetime('now', '-1 hour'))
 	`)
 
-	input := backfillSessionInput{
-		agent:       "agent-recompact",
-		sessionID:   "session-recompact",
-		title:       "Recompact Session",
-		messages:    makeBackfillMessages(5),
-		sessionPath: "/tmp/session-recompact.jsonl",
+	input := lcm.BackfillInput{
+		Agent:       "agent-recompact",
+		SessionID:   "session-recompact",
+		Title:       "Recompact Session",
+		Messages:    makeBackfillMessages(5),
+		SessionPath: "/tmp/session-recompact.jsonl",
 	}
 	summarizer := &stubBackfillSummarizer{}
 	opts := backfillOptions{
@@ -363,10 +502,10 @@ func TestBackfillWorkflowRecompactSingleRootOnExistingSession(t *testing.T) {
 	if err != nil {
 		t.Fatalf("run workflow with recompact single-root: %v", err)
 	}
-	if result.imported {
+	if result.Imported {
 		t.Fatalf("expected idempotency guard to skip message import")
 	}
-	if stats.rootFoldPasses == 0 {
+	if stats.RootFoldPasses == 0 {
 		t.Fatalf("expected forced single-root fold pass with --recompact and --single-root")
 	}
 	if summarizer.counter == 0 {
@@ -391,15 +530,15 @@ func (s *stubBackfillSummarizer) summarize(_ context.Context, _ string, targetTo
 	return fmt.Sprintf("summary-%d %s", s.counter, strings.Repeat("x", targetTokens*4)), nil
 }
 
-func makeBackfillMessages(n int) []backfillMessage {
-	messages := make([]backfillMessage, 0, n)
+func makeBackfillMessages(n int) []lcm.BackfillMessage {
+	messages := make([]lcm.BackfillMessage, 0, n)
 	start := time.Date(2026, time.January, 1, 10, 0, 0, 0, time.UTC)
 	for i := 0; i < n; i++ {
-		messages = append(messages, backfillMessage{
-			seq:       i,
-			role:      []string{"user", "assistant"}[i%2],
-			content:   strings.Repeat(fmt.Sprintf("message-%d ", i), 10),
-			createdAt: start.Add(time.Duration(i) * time.Minute).Format("2006-01-02 15:04:05"),
+		messages = append(messages, lcm.BackfillMessage{
+			Seq:       i,
+			Role:      []string{"user", "assistant"}[i%2],
+			Content:   strings.Repeat(fmt.Sprintf("message-%d ", i), 10),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute).Format("2006-01-02 15:04:05"),
 		})
 	}
 	return messages
@@ -418,7 +557,7 @@ func backfillSessionJSONL(n int) string {
 	return strings.Join(lines, "\n") + "\n"
 }
 
-func newBackfillTestDB(t *testing.T) *sql.DB {
+func newBackfillTestDB(t testing.TB) *sql.DB {
 	t.Helper()
 	name := strings.ReplaceAll(strings.ToLower(t.Name()), "/", "_")
 	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", name)
@@ -430,7 +569,7 @@ func newBackfillTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
-func setupBackfillTestSchema(t *testing.T, db *sql.DB) {
+func setupBackfillTestSchema(t testing.TB, db *sql.DB) {
 	t.Helper()
 	mustExec(t, db, `PRAGMA foreign_keys = ON`)
 	mustExec(t, db, `
@@ -552,3 +691,442 @@ func assertCountQuery(t *testing.T, db *sql.DB, query string, want int, args ...
 		t.Fatalf("count mismatch: got=%d want=%d\nquery:\n%s", got, want, query)
 	}
 }
+
+func TestSimulateBackfillRecompactionLeavesDatabaseUntouched(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "lcm.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	setupBackfillTestSchema(t, db)
+
+	input := lcm.BackfillInput{
+		Agent:       "agent-simulate",
+		SessionID:   "session-simulate",
+		Messages:    makeBackfillMessages(12),
+		SessionPath: "/tmp/session-simulate.jsonl",
+	}
+	imported, err := lcm.ApplyBackfillImport(ctx, db, input)
+	if err != nil {
+		t.Fatalf("apply backfill import: %v", err)
+	}
+	// A second conversation must not leak into the in-memory copy.
+	other, err := lcm.ApplyBackfillImport(ctx, db, lcm.BackfillInput{
+		Agent: "agent-simulate", SessionID: "session-other", Messages: makeBackfillMessages(3), SessionPath: "/tmp/other.jsonl",
+	})
+	if err != nil {
+		t.Fatalf("apply second import: %v", err)
+	}
+
+	opts := backfillOptions{
+		recompact:            true,
+		singleRoot:           true,
+		leafChunkTokens:      300,
+		leafTargetTokens:     64,
+		condensedTargetToken: 96,
+		leafFanout:           2,
+		condensedFanout:      2,
+		hardFanout:           2,
+	}
+	sim, err := simulateBackfillRecompaction(ctx, db, dbPath, imported.ConversationID, opts, stubBackfillSummarize, false, nil)
+	if err != nil {
+		t.Fatalf("simulate recompaction: %v", err)
+	}
+
+	if sim.current.contextItems != 12 || len(sim.current.summariesByDepth) != 0 {
+		t.Fatalf("unexpected current shape %+v", sim.current)
+	}
+	if sim.stats.LeafPasses == 0 || sim.simulated.summariesByDepth[0] != sim.stats.LeafPasses {
+		t.Fatalf("expected one leaf per leaf pass, got stats %+v shape %+v", sim.stats, sim.simulated)
+	}
+	if sim.simulated.contextItems >= sim.current.contextItems || sim.simulated.contextSummaries == 0 {
+		t.Fatalf("expected the simulated context to shrink into summaries, got %+v", sim.simulated)
+	}
+
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries`, 0)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ?`, 12, imported.ConversationID)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = ?`, 3, other.ConversationID)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'compact'`, 0)
+}
+
+func TestParseBackfillArgsSimulateLiveRequiresRecompactDryRun(t *testing.T) {
+	if _, err := parseBackfillArgs([]string{"agent", "session", "--simulate-live"}); err == nil {
+		t.Fatal("expected --simulate-live without --recompact to be rejected")
+	}
+	if _, err := parseBackfillArgs([]string{"agent", "session", "--recompact", "--simulate-live", "--apply"}); err == nil {
+		t.Fatal("expected --simulate-live with --apply to be rejected")
+	}
+	opts, err := parseBackfillArgs([]string{"agent", "session", "--recompact", "--simulate-live"})
+	if err != nil || !opts.simulateLive || !opts.dryRun {
+		t.Fatalf("unexpected parse result %+v (%v)", opts, err)
+	}
+}
+
+func TestVerifyBackfillImportReportsDivergences(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	sessionPath := filepath.Join(t.TempDir(), "session-verify.jsonl")
+	if err := os.WriteFile(sessionPath, []byte(backfillSessionJSONL(4)), 0o644); err != nil {
+		t.Fatalf("write session jsonl: %v", err)
+	}
+	messages, err := parseBackfillSessionFile(sessionPath, false, nil, nil)
+	if err != nil {
+		t.Fatalf("parse session file: %v", err)
+	}
+	input := lcm.BackfillInput{
+		Agent:       "agent-a",
+		SessionID:   "session-verify",
+		SessionPath: sessionPath,
+		Messages:    messages,
+	}
+	result, err := lcm.ApplyBackfillImport(ctx, db, input)
+	if err != nil {
+		t.Fatalf("apply backfill import: %v", err)
+	}
+
+	report, err := verifyBackfillImport(ctx, db, input.SessionID, messages)
+	if err != nil {
+		t.Fatalf("verify clean import: %v", err)
+	}
+	if len(report.divergences) != 0 || len(report.roleRemaps) != 0 {
+		t.Fatalf("clean import: divergences=%+v remaps=%v, want none", report.divergences, report.roleRemaps)
+	}
+
+	mustExec(t, db, fmt.Sprintf(`UPDATE messages SET content = 'altered' WHERE conversation_id = %d AND seq = 1`, result.ConversationID))
+	mustExec(t, db, fmt.Sprintf(`DELETE FROM messages WHERE conversation_id = %d AND seq = 3`, result.ConversationID))
+
+	report, err = verifyBackfillImport(ctx, db, input.SessionID, messages)
+	if err != nil {
+		t.Fatalf("verify altered import: %v", err)
+	}
+	if report.sourceCount != 4 || report.importedCount != 3 {
+		t.Fatalf("counts source=%d imported=%d, want 4/3", report.sourceCount, report.importedCount)
+	}
+	if len(report.divergences) != 2 {
+		t.Fatalf("divergences = %+v, want 2", report.divergences)
+	}
+	if got := report.divergences[0]; got.seq != 1 || got.kind != "content" {
+		t.Fatalf("first divergence = %+v, want content at seq 1", got)
+	}
+	if got := report.divergences[1]; got.seq != 3 || got.kind != "missing" {
+		t.Fatalf("second divergence = %+v, want missing at seq 3", got)
+	}
+
+	var out strings.Builder
+	printBackfillVerifyReport(&out, report, input.SessionID, "session.jsonl", cliOutputStyle{})
+	if !strings.Contains(out.String(), "  seq  kind     detail") || strings.Contains(out.String(), "\x1b[") {
+		t.Fatalf("divergences should render as a plain table:\n%s", out.String())
+	}
+}
+
+func TestParseBackfillArgsRejectsVerifyWithApply(t *testing.T) {
+	_, err := parseBackfillArgs([]string{"agent-a", "session-a", "--verify", "--apply"})
+	if err == nil || !strings.Contains(err.Error(), "--verify is read-only") {
+		t.Fatalf("expected verify/apply conflict error, got %v", err)
+	}
+}
+
+func TestParseBackfillArgsSessionPath(t *testing.T) {
+	opts, err := parseBackfillArgs([]string{"agent-a", "--session-path", "/backups/old/session-x.jsonl"})
+	if err != nil {
+		t.Fatalf("parse --session-path: %v", err)
+	}
+	if opts.sessionID != "session-x" || opts.sessionPath != "/backups/old/session-x.jsonl" {
+		t.Fatalf("expected session ID from file name, got id=%q path=%q", opts.sessionID, opts.sessionPath)
+	}
+
+	opts, err = parseBackfillArgs([]string{"agent-a", "--session-path=/backups/export.jsonl", "--session-id", "session-y"})
+	if err != nil {
+		t.Fatalf("parse --session-id: %v", err)
+	}
+	if opts.sessionID != "session-y" {
+		t.Fatalf("expected explicit session ID, got %q", opts.sessionID)
+	}
+
+	if _, err := parseBackfillArgs([]string{"agent-a", "session-a", "--session-path", "/x.jsonl", "--session-id", "session-b"}); err == nil || !strings.Contains(err.Error(), "conflicts with --session-id") {
+		t.Fatalf("expected conflicting session ID error, got %v", err)
+	}
+	if _, err := parseBackfillArgs([]string{"agent-a"}); err == nil || !strings.Contains(err.Error(), "agent and session_id are required") {
+		t.Fatalf("expected missing session ID error without --session-path, got %v", err)
+	}
+}
+
+func TestCheckBackfillSessionFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	if err := os.WriteFile(path, []byte(backfillSessionJSONL(1)), 0o644); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+	if err := checkBackfillSessionFile(path); err != nil {
+		t.Fatalf("expected readable file to pass, got %v", err)
+	}
+	if err := checkBackfillSessionFile(filepath.Join(dir, "missing.jsonl")); err == nil {
+		t.Fatal("expected missing file to fail")
+	}
+	if err := checkBackfillSessionFile(dir); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Fatalf("expected directory error, got %v", err)
+	}
+}
+
+func TestNormalizeContentWhitespace(t *testing.T) {
+	t.Parallel()
+
+	got := normalizeContentWhitespace("first line  \r\n\r\n\r\n\r\n  indented\t\r\nlast\r\r")
+	if want := "first line\n\n  indented\nlast"; got != want {
+		t.Fatalf("normalizeContentWhitespace = %q, want %q", got, want)
+	}
+}
+
+func TestParseBackfillSessionFileNormalizesWhitespaceWhenRequested(t *testing.T) {
+	dir := t.TempDir()
+	crlfPath := filepath.Join(dir, "crlf.jsonl")
+	lfPath := filepath.Join(dir, "lf.jsonl")
+	crlf := `{"type":"message","timestamp":"2026-01-01T10:00:00Z","message":{"role":"user","content":"plan  \r\n\r\n\r\nship it"}}` + "\n"
+	lf := `{"type":"message","timestamp":"2026-01-01T10:00:00Z","message":{"role":"user","content":"plan\n\nship it"}}` + "\n"
+	if err := os.WriteFile(crlfPath, []byte(crlf), 0o644); err != nil {
+		t.Fatalf("write crlf session: %v", err)
+	}
+	if err := os.WriteFile(lfPath, []byte(lf), 0o644); err != nil {
+		t.Fatalf("write lf session: %v", err)
+	}
+
+	exact, err := parseBackfillSessionFile(crlfPath, false, nil, nil)
+	if err != nil {
+		t.Fatalf("parse crlf session: %v", err)
+	}
+	if !strings.Contains(exact[0].Content, "\r\n") {
+		t.Fatalf("exact parse should keep CRLF, got %q", exact[0].Content)
+	}
+
+	normalized, err := parseBackfillSessionFile(crlfPath, true, nil, nil)
+	if err != nil {
+		t.Fatalf("parse crlf session normalized: %v", err)
+	}
+	reference, err := parseBackfillSessionFile(lfPath, true, nil, nil)
+	if err != nil {
+		t.Fatalf("parse lf session normalized: %v", err)
+	}
+	if normalized[0].Content != reference[0].Content {
+		t.Fatalf("normalized content = %q, want %q", normalized[0].Content, reference[0].Content)
+	}
+	if lcm.MessageIdentityHash(normalized[0].Role, normalized[0].Content) != lcm.MessageIdentityHash(reference[0].Role, reference[0].Content) {
+		t.Fatalf("identity hashes differ after normalization")
+	}
+}
+
+// seedBackfillChunk inserts n messages and n leaf summaries into conversation
+// 1 and returns a chunk that references them in reverse ID order, so loaders
+// must reassemble rather than rely on rowid order.
+func seedBackfillChunk(t testing.TB, db *sql.DB, n int) []lcm.ContextItem {
+	t.Helper()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'chunk-session')`)
+	var chunk []lcm.ContextItem
+	for i := n; i >= 1; i-- {
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+			VALUES (%d, 1, %d, 'user', 'message %d', 2, '2026-03-01T10:00:00Z')
+		`, i, i, i))
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, descendant_count, created_at)
+			VALUES ('sum_%03d', 1, 'leaf', 0, 'summary %d', 2, %d, '2026-03-01T10:00:00Z')
+		`, i, i, i))
+		chunk = append(chunk,
+			lcm.ContextItem{ItemType: "message", MessageID: sql.NullInt64{Int64: int64(i), Valid: true}},
+			lcm.ContextItem{ItemType: "summary", SummaryID: sql.NullString{String: fmt.Sprintf("sum_%03d", i), Valid: true}},
+		)
+	}
+	return chunk
+}
+
+func TestBackfillChunkLoadersPreserveChunkOrder(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	// More than one batch exercises the batch boundary.
+	n := lcm.ChunkLoadBatchSize + 5
+	chunk := seedBackfillChunk(t, db, n)
+
+	messages, err := lcm.LoadChunkMessages(ctx, db, chunk)
+	if err != nil {
+		t.Fatalf("load messages: %v", err)
+	}
+	summaries, err := lcm.LoadChunkSummaries(ctx, db, chunk)
+	if err != nil {
+		t.Fatalf("load summaries: %v", err)
+	}
+	if len(messages) != n || len(summaries) != n {
+		t.Fatalf("got %d messages, %d summaries; want %d each", len(messages), len(summaries), n)
+	}
+	for i := 0; i < n; i++ {
+		want := n - i
+		if messages[i].MessageID != int64(want) || messages[i].Content != fmt.Sprintf("message %d", want) {
+			t.Fatalf("message %d out of order: %+v", i, messages[i])
+		}
+		if summaries[i].SummaryID != fmt.Sprintf("sum_%03d", want) || summaries[i].Descendants != want {
+			t.Fatalf("summary %d out of order: %+v", i, summaries[i])
+		}
+	}
+
+	missing := append(chunk[:2:2], lcm.ContextItem{ItemType: "message", MessageID: sql.NullInt64{Int64: 99999, Valid: true}})
+	if _, err := lcm.LoadChunkMessages(ctx, db, missing); err == nil || !strings.Contains(err.Error(), "load message 99999") {
+		t.Fatalf("expected missing message error, got %v", err)
+	}
+}
+
+// BenchmarkBackfillChunkLoaders compares the batched loaders against the
+// previous one-QueryRow-per-item pattern on a wide chunk.
+func BenchmarkBackfillChunkLoaders(b *testing.B) {
+	db := newBackfillTestDB(b)
+	ctx := context.Background()
+	chunk := seedBackfillChunk(b, db, 500)
+
+	b.Run("per-row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, item := range chunk {
+				var content, createdAt string
+				var err error
+				if item.MessageID.Valid {
+					err = db.QueryRowContext(ctx, `SELECT COALESCE(content, ''), COALESCE(created_at, '') FROM messages WHERE message_id = ?`, item.MessageID.Int64).Scan(&content, &createdAt)
+				} else {
+					err = db.QueryRowContext(ctx, `SELECT COALESCE(content, ''), COALESCE(created_at, '') FROM summaries WHERE summary_id = ?`, item.SummaryID.String).Scan(&content, &createdAt)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := lcm.LoadChunkMessages(ctx, db, chunk); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := lcm.LoadChunkSummaries(ctx, db, chunk); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestBackfillRoleMapOverridesAssistantFallback(t *testing.T) {
+	roleMap, err := parseBackfillRoleMap("Developer=user, function=TOOL")
+	if err != nil {
+		t.Fatalf("parse role map: %v", err)
+	}
+	if _, err := parseBackfillRoleMap("developer=operator"); err == nil {
+		t.Fatal("expected non-stored target role to be rejected")
+	}
+	if _, err := parseBackfillRoleMap("developer"); err == nil {
+		t.Fatal("expected entry without = to be rejected")
+	}
+
+	lines := []string{
+		`{"type":"message","id":"m1","message":{"role":"developer","content":"be terse"}}`,
+		`{"type":"message","id":"m2","message":{"role":"function","content":"42"}}`,
+		`{"type":"message","id":"m3","message":{"role":"critic","content":"hm"}}`,
+	}
+	sessionPath := filepath.Join(t.TempDir(), "session-roles.jsonl")
+	if err := os.WriteFile(sessionPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("write session jsonl: %v", err)
+	}
+
+	messages, err := parseBackfillSessionFile(sessionPath, false, nil, roleMap)
+	if err != nil {
+		t.Fatalf("parse session file: %v", err)
+	}
+	var stored []string
+	for _, msg := range messages {
+		stored = append(stored, msg.Role)
+	}
+	if got := strings.Join(stored, ","); got != "user,tool,assistant" {
+		t.Fatalf("stored roles = %s, want user,tool,assistant", got)
+	}
+
+	display, err := parseSessionMessages(sessionPath, nil, roleMap)
+	if err != nil {
+		t.Fatalf("parse session messages: %v", err)
+	}
+	var shown []string
+	for _, msg := range display {
+		shown = append(shown, msg.role)
+	}
+	if got := strings.Join(shown, ","); got != "user,tool,critic" {
+		t.Fatalf("displayed roles = %s, want user,tool,critic", got)
+	}
+}
+
+func TestSmartChunkAlignsLeafChunksToUserTurns(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'turns')`)
+	// Four exchanges of user, assistant, tool at 100 tokens each.
+	roles := []string{"user", "assistant", "tool"}
+	for i := 0; i < 12; i++ {
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+			VALUES (%d, 1, %d, '%s', 'message %d', 100, '2026-03-01T10:%02d:00Z')
+		`, i+1, i, roles[i%3], i, i))
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO context_items (conversation_id, ordinal, item_type, message_id) VALUES (1, %d, 'message', %d)
+		`, i, i+1))
+	}
+	items, err := lcm.LoadContextItems(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("load context items: %v", err)
+	}
+	if items[0].Role != "user" || items[0].CreatedAt != "2026-03-01T10:00:00Z" {
+		t.Fatalf("context items should carry role and created_at, got %+v", items[0])
+	}
+
+	chunks := func(smart bool) [][]string {
+		remaining := items
+		var out [][]string
+		for len(remaining) > 0 {
+			chunk := lcm.SelectLeafChunk(remaining, 400, 0)
+			if smart {
+				chunk = lcm.AlignLeafChunk(remaining, chunk, 400, 0)
+			}
+			var chunkRoles []string
+			for _, item := range chunk {
+				chunkRoles = append(chunkRoles, item.Role)
+			}
+			out = append(out, chunkRoles)
+			remaining = remaining[len(chunk):]
+		}
+		return out
+	}
+	if got := fmt.Sprint(chunks(false)); got != "[[user assistant tool user] [assistant tool user assistant] [tool user assistant tool]]" {
+		t.Fatalf("default chunking should fill the budget, got %s", got)
+	}
+	for i, chunk := range chunks(true) {
+		if fmt.Sprint(chunk) != "[user assistant tool]" {
+			t.Fatalf("--smart-chunk chunk %d = %v, want one whole exchange", i, chunk)
+		}
+	}
+
+	// A long pause beats a later user turn, and nothing is cut below the tolerance.
+	gap := []lcm.ContextItem{
+		{Ordinal: 0, ItemType: "message", MessageID: sql.NullInt64{Int64: 1, Valid: true}, TokenCount: 100, Role: "user", CreatedAt: "2026-03-01T10:00:00Z"},
+		{Ordinal: 1, ItemType: "message", MessageID: sql.NullInt64{Int64: 2, Valid: true}, TokenCount: 200, Role: "assistant", CreatedAt: "2026-03-01T10:01:00Z"},
+		{Ordinal: 2, ItemType: "message", MessageID: sql.NullInt64{Int64: 3, Valid: true}, TokenCount: 50, Role: "assistant", CreatedAt: "2026-03-01T12:00:00Z"},
+		{Ordinal: 3, ItemType: "message", MessageID: sql.NullInt64{Int64: 4, Valid: true}, TokenCount: 50, Role: "user", CreatedAt: "2026-03-01T12:01:00Z"},
+		{Ordinal: 4, ItemType: "message", MessageID: sql.NullInt64{Int64: 5, Valid: true}, TokenCount: 100, Role: "assistant", CreatedAt: "2026-03-01T12:02:00Z"},
+	}
+	if got := lcm.AlignLeafChunk(gap, lcm.SelectLeafChunk(gap, 400, 0), 400, 0); len(got) != 2 {
+		t.Fatalf("expected the chunk to end at the time gap, got %d messages", len(got))
+	}
+	if got := lcm.AlignLeafChunk(gap, lcm.SelectLeafChunk(gap, 1000, 0), 1000, 0); len(got) != 5 {
+		t.Fatalf("a chunk that ends before the budget should be unchanged, got %d messages", len(got))
+	}
+	gap[1].TokenCount = 10
+	if got := lcm.AlignLeafChunk(gap, lcm.SelectLeafChunk(gap, 210, 0), 210, 0); len(got) != 3 {
+		t.Fatalf("a gap under the tolerance should lose to the user turn inside it, got %d messages", len(got))
+	}
+
+	opts, err := parseBackfillArgs([]string{"main", "sess", "--smart-chunk"})
+	if err != nil || !opts.smartChunk {
+		t.Fatalf("backfill should accept --smart-chunk, got %+v (%v)", opts.smartChunk, err)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// backfillVerifyMaxDivergences caps how many per-message divergences are
+// printed; the total is always reported.
+const backfillVerifyMaxDivergences = 20
+
+// backfillVerifyDivergence describes one position where the imported messages
+// differ from the reparsed session JSONL.
+type backfillVerifyDivergence struct {
+	seq    int
+	kind   string
+	detail string
+}
+
+// backfillVerifyReport compares a reparsed session against its imported
+// conversation. roleRemaps counts source roles that normalizeBackfillRole
+// changed; they are informational rather than divergences.
+type backfillVerifyReport struct {
+	conversationID int64
+	sourceCount    int
+	importedCount  int
+	divergences    []backfillVerifyDivergence
+	roleRemaps     map[string]int
+	// foreignContext lists context_items that point at another
+	// conversation's summaries or messages.
+	foreignContext []foreignContextItem
+}
+
+type backfillImportedMessage struct {
+	seq     int
+	role    string
+	content string
+}
+
+// runBackfillVerify prints a fidelity report for an imported session and
+// returns an error when the import diverges from the JSONL.
+func runBackfillVerify(ctx context.Context, q sqlQueryer, sessionID, sessionPath string, messages []lcm.BackfillMessage) error {
+	report, err := verifyBackfillImport(ctx, q, sessionID, messages)
+	if err != nil {
+		return err
+	}
+	printBackfillVerifyReport(os.Stdout, report, sessionID, sessionPath, resolveCLIOutputStyle())
+	if len(report.divergences) > 0 {
+		return fmt.Errorf("verification failed: %d divergences between %s and conversation %d", len(report.divergences), sessionPath, report.conversationID)
+	}
+	if len(report.foreignContext) > 0 {
+		return fmt.Errorf("verification failed: conversation %d has %d context items from other conversations; run lcm-tui check-context %d --fix", report.conversationID, len(report.foreignContext), report.conversationID)
+	}
+	return nil
+}
+
+// verifyBackfillImport loads the conversation imported for sessionID and
+// compares message count, order, roles, and content hashes against messages.
+func verifyBackfillImport(ctx context.Context, q sqlQueryer, sessionID string, messages []lcm.BackfillMessage) (backfillVerifyReport, error) {
+	plan, err := lcm.InspectBackfillImport(ctx, q, sessionID)
+	if err != nil {
+		return backfillVerifyReport{}, err
+	}
+	if plan.ConversationID == 0 {
+		return backfillVerifyReport{}, fmt.Errorf("session %s has not been imported; run backfill --apply first", sessionID)
+	}
+
+	imported, err := loadBackfillImportedMessages(ctx, q, plan.ConversationID)
+	if err != nil {
+		return backfillVerifyReport{}, err
+	}
+
+	foreign, err := findForeignContextItems(ctx, q, plan.ConversationID)
+	if err != nil {
+		return backfillVerifyReport{}, err
+	}
+
+	report := backfillVerifyReport{
+		conversationID: plan.ConversationID,
+		sourceCount:    len(messages),
+		importedCount:  len(imported),
+		roleRemaps:     make(map[string]int),
+		foreignContext: foreign,
+	}
+	for _, msg := range messages {
+		if msg.RawRole != msg.Role {
+			report.roleRemaps[fmt.Sprintf("%q -> %s", msg.RawRole, msg.Role)]++
+		}
+	}
+
+	for i := 0; i < len(messages) || i < len(imported); i++ {
+		switch {
+		case i >= len(imported):
+			report.divergences = append(report.divergences, backfillVerifyDivergence{
+				seq:    i,
+				kind:   "missing",
+				detail: fmt.Sprintf("source %s message not imported: %s", messages[i].Role, truncateString(oneLine(messages[i].Content), 60)),
+			})
+		case i >= len(messages):
+			report.divergences = append(report.divergences, backfillVerifyDivergence{
+				seq:    imported[i].seq,
+				kind:   "extra",
+				detail: fmt.Sprintf("imported %s message has no source row: %s", imported[i].role, truncateString(oneLine(imported[i].content), 60)),
+			})
+		case imported[i].seq != i:
+			report.divergences = append(report.divergences, backfillVerifyDivergence{
+				seq:    i,
+				kind:   "order",
+				detail: fmt.Sprintf("imported row at position %d has seq=%d", i, imported[i].seq),
+			})
+		case imported[i].role != messages[i].Role:
+			report.divergences = append(report.divergences, backfillVerifyDivergence{
+				seq:    i,
+				kind:   "role",
+				detail: fmt.Sprintf("source=%s imported=%s", messages[i].Role, imported[i].role),
+			})
+		default:
+			sourceHash := lcm.ContentSHA256(messages[i].Content)
+			importedHash := lcm.ContentSHA256(imported[i].content)
+			if sourceHash != importedHash {
+				report.divergences = append(report.divergences, backfillVerifyDivergence{
+					seq:    i,
+					kind:   "content",
+					detail: fmt.Sprintf("source=%s (%d chars) imported=%s (%d chars)", sourceHash[:12], len(messages[i].Content), importedHash[:12], len(imported[i].content)),
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+func loadBackfillImportedMessages(ctx context.Context, q sqlQueryer, conversationID int64) ([]backfillImportedMessage, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT seq, role, content
+		FROM messages
+		WHERE conversation_id = ?
+		ORDER BY seq ASC, message_id ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query imported messages for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	messages := make([]backfillImportedMessage, 0, 512)
+	for rows.Next() {
+		var msg backfillImportedMessage
+		if err := rows.Scan(&msg.seq, &msg.role, &msg.content); err != nil {
+			return nil, fmt.Errorf("scan imported message row: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate imported message rows: %w", err)
+	}
+	return messages, nil
+}
+
+func printBackfillVerifyReport(w io.Writer, report backfillVerifyReport, sessionID, sessionPath string, style cliOutputStyle) {
+	fmt.Fprintf(w, "Backfill verify: session %s vs conversation %d\n", sessionID, report.conversationID)
+	fmt.Fprintf(w, "  source:   %s (%d messages)\n", sessionPath, report.sourceCount)
+	fmt.Fprintf(w, "  imported: %d messages\n", report.importedCount)
+
+	if len(report.roleRemaps) > 0 {
+		fmt.Fprintln(w, "\nRole normalization applied during parse:")
+		remaps := make([]string, 0, len(report.roleRemaps))
+		for remap := range report.roleRemaps {
+			remaps = append(remaps, remap)
+		}
+		sort.Strings(remaps)
+		table := cliTable{indent: "  ", columns: []cliTableColumn{
+			{header: "remap", flex: true},
+			{header: "messages", align: cliAlignRight},
+		}}
+		for _, remap := range remaps {
+			table.addRow(remap, strconv.Itoa(report.roleRemaps[remap]))
+		}
+		for _, line := range table.render(style) {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(report.foreignContext) > 0 {
+		fmt.Fprintf(w, "\nFound %d context items from other conversations:\n", len(report.foreignContext))
+		for _, item := range report.foreignContext {
+			fmt.Fprintf(w, "  %s\n", item.describe())
+		}
+	}
+
+	if len(report.divergences) == 0 {
+		if len(report.foreignContext) == 0 {
+			fmt.Fprintln(w, "\nOK: imported messages match the session JSONL (count, order, roles, content hashes).")
+		}
+		return
+	}
+
+	fmt.Fprintf(w, "\nFound %d divergences:\n", len(report.divergences))
+	table := cliTable{indent: "  ", columns: []cliTableColumn{
+		{header: "seq", align: cliAlignRight},
+		{header: "kind", sgr: cliSGRYellow},
+		{header: "detail", flex: true},
+	}}
+	for i, divergence := range report.divergences {
+		if i >= backfillVerifyMaxDivergences {
+			break
+		}
+		table.addRow(strconv.Itoa(divergence.seq), divergence.kind, divergence.detail)
+	}
+	for _, line := range table.render(style) {
+		fmt.Fprintln(w, line)
+	}
+	if extra := len(report.divergences) - backfillVerifyMaxDivergences; extra > 0 {
+		fmt.Fprintf(w, "  ... %d more\n", extra)
+	}
+}
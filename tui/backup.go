@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// resolveBackupDB reports whether an --apply run should back up lcm.db
+// first: --backup-db or LCM_TUI_BACKUP_DB=1 turns it on.
+func resolveBackupDB(cliBackup bool) (bool, error) {
+	if cliBackup {
+		return true, nil
+	}
+	value := strings.TrimSpace(os.Getenv("LCM_TUI_BACKUP_DB"))
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("LCM_TUI_BACKUP_DB must be a boolean, got %q", value)
+	}
+	return enabled, nil
+}
+
+// lcmBackupDir is where backups go: LCM_TUI_BACKUP_DIR, else a backups
+// directory next to the DB.
+func lcmBackupDir(dbPath string) string {
+	if dir := strings.TrimSpace(os.Getenv("LCM_TUI_BACKUP_DIR")); dir != "" {
+		return lcm.ExpandHomePath(dir)
+	}
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// backupLCMDB writes a consistent copy of db to a timestamped file named for
+// command, e.g. backups/lcm-repair-20260322T100000Z.db, and prints its path.
+// VACUUM INTO reads inside one transaction, so a gateway writing to the live
+// DB during the copy cannot leave the backup half-updated.
+func backupLCMDB(ctx context.Context, db *sql.DB, dbPath, command string) (string, error) {
+	dir := lcmBackupDir(dbPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create backup directory: %w", err)
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	base := fmt.Sprintf("lcm-%s-%s", strings.ReplaceAll(command, " ", "-"), stamp)
+	path := filepath.Join(dir, base+".db")
+	// VACUUM INTO refuses to overwrite, so two runs in the same second get
+	// numbered names.
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.db", base, n))
+	}
+
+	started := time.Now()
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return "", fmt.Errorf("back up %s to %s: %w", dbPath, path, err)
+	}
+	size := int64(0)
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	fmt.Printf("Backed up %s to %s (%s, %s). Restore by copying it over %s while the gateway is stopped.\n",
+		filepath.Base(dbPath), path, formatByteSizeCompact(size), time.Since(started).Round(time.Millisecond), dbPath)
+	return path, nil
+}
+
+// backupBeforeApply runs backupLCMDB when --backup-db is on, recording the
+// backup in report (which may be nil). A failed backup stops the run before
+// anything is written.
+func backupBeforeApply(ctx context.Context, db *sql.DB, dbPath, command string, enabled bool, report *runReport) error {
+	if !enabled {
+		return nil
+	}
+	path, err := backupLCMDB(ctx, db, dbPath, command)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		report.BackupPath = path
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupLCMDBWritesConsistentNumberedCopies(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LCM_TUI_BACKUP_DIR", "")
+	dbPath := filepath.Join(dir, "lcm.db")
+	db, err := openLCMDB(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	mustExec(t, db, `CREATE TABLE summaries (summary_id TEXT PRIMARY KEY, content TEXT)`)
+	mustExec(t, db, `INSERT INTO summaries VALUES ('sum_a', 'first'), ('sum_b', 'second')`)
+
+	ctx := context.Background()
+	report := &runReport{}
+	if err := backupBeforeApply(ctx, db, dbPath, "repair", false, report); err != nil || report.BackupPath != "" {
+		t.Fatalf("disabled backup should do nothing, got %q (%v)", report.BackupPath, err)
+	}
+	if err := backupBeforeApply(ctx, db, dbPath, "repair", true, report); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	first := report.BackupPath
+	if filepath.Dir(first) != filepath.Join(dir, "backups") || !strings.HasPrefix(filepath.Base(first), "lcm-repair-") {
+		t.Fatalf("unexpected backup path %q", first)
+	}
+
+	backup, err := openLCMDB(first)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer backup.Close()
+	assertCount(t, backup, `SELECT COUNT(*) FROM summaries`, 2)
+
+	// Two backups in the same second must not collide.
+	second, err := backupLCMDB(ctx, db, dbPath, "repair")
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+	third, err := backupLCMDB(ctx, db, dbPath, "repair")
+	if err != nil {
+		t.Fatalf("third backup: %v", err)
+	}
+	if second == first || third == second || third == first {
+		t.Fatalf("backups should get distinct names: %q %q %q", first, second, third)
+	}
+
+	custom := filepath.Join(dir, "elsewhere")
+	t.Setenv("LCM_TUI_BACKUP_DIR", custom)
+	path, err := backupLCMDB(ctx, db, dbPath, "transplant")
+	if err != nil {
+		t.Fatalf("backup to LCM_TUI_BACKUP_DIR: %v", err)
+	}
+	if filepath.Dir(path) != custom || !strings.HasPrefix(filepath.Base(path), "lcm-transplant-") {
+		t.Fatalf("LCM_TUI_BACKUP_DIR not honored: %q", path)
+	}
+}
+
+func TestResolveBackupDBReadsFlagAndEnv(t *testing.T) {
+	t.Setenv("LCM_TUI_BACKUP_DB", "")
+	if enabled, err := resolveBackupDB(false); err != nil || enabled {
+		t.Fatalf("expected backups off by default, got %v (%v)", enabled, err)
+	}
+	if enabled, err := resolveBackupDB(true); err != nil || !enabled {
+		t.Fatalf("--backup-db should enable backups, got %v (%v)", enabled, err)
+	}
+	t.Setenv("LCM_TUI_BACKUP_DB", "1")
+	if enabled, err := resolveBackupDB(false); err != nil || !enabled {
+		t.Fatalf("LCM_TUI_BACKUP_DB=1 should enable backups, got %v (%v)", enabled, err)
+	}
+	t.Setenv("LCM_TUI_BACKUP_DB", "maybe")
+	if _, err := resolveBackupDB(false); err == nil || !strings.Contains(err.Error(), "LCM_TUI_BACKUP_DB") {
+		t.Fatalf("expected an error for an invalid LCM_TUI_BACKUP_DB, got %v", err)
+	}
+
+	t.Setenv("LCM_TUI_BACKUP_DB", "")
+	opts, _, err := parsePruneArgs([]string{"7", "--apply", "--backup-db"})
+	if err != nil || !opts.backupDB {
+		t.Fatalf("prune should accept --backup-db, got %+v (%v)", opts, err)
+	}
+	// Metadata and context fixes back up the same way.
+	if opts, _, err := parseCheckContextArgs([]string{"7", "--fix", "--backup-db"}); err != nil || !opts.backupDB {
+		t.Fatalf("check-context should accept --backup-db, got %+v (%v)", opts, err)
+	}
+	if opts, _, _, err := parseTagArgs("untag", []string{"7", "keep", "--backup-db"}); err != nil || !opts.backupDB {
+		t.Fatalf("untag should accept --backup-db, got %+v (%v)", opts, err)
+	}
+	if opts, _, err := parseSettingsArgs([]string{"7", "--fresh-tail", "48", "--backup-db"}); err != nil || !opts.backupDB {
+		t.Fatalf("settings should accept --backup-db, got %+v (%v)", opts, err)
+	}
+}
@@ -53,7 +53,7 @@ func TestResolveInteractiveRewriteProviderModelUsesTUISummaryBaseURL(t *testing.
 	t.Setenv("LCM_TUI_SUMMARY_BASE_URL", "https://tui.example.com/openai/")
 	t.Setenv("LCM_SUMMARY_BASE_URL", "https://summary.example.com/openai/")
 
-	provider, model, baseURL := resolveInteractiveRewriteProviderModel(appDataPaths{})
+	provider, model, baseURL := resolveInteractiveRewriteProviderModel(appDataPaths{}, agentDefaults{})
 	if provider != "openai" {
 		t.Fatalf("expected provider openai, got %q", provider)
 	}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+)
+
+// cliOutputStyle is how tabular CLI output is drawn: the column budget and
+// whether ANSI color is allowed.
+type cliOutputStyle struct {
+	width int
+	color bool
+}
+
+// resolveCLIOutputStyle sizes tables to the terminal (else COLUMNS, else
+// defaultCLIWrapWidth) and enables color only when stdout is a terminal and
+// NO_COLOR is unset, so pipes and logs never receive escape sequences.
+func resolveCLIOutputStyle() cliOutputStyle {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return cliOutputStyle{
+		width: resolveCLIWrapWidth(true, 0),
+		color: !noColor && term.IsTerminal(os.Stdout.Fd()),
+	}
+}
+
+// SGR parameters used by CLI output.
+const (
+	cliSGRBold   = "1"
+	cliSGRDim    = "2"
+	cliSGRRed    = "31"
+	cliSGRGreen  = "32"
+	cliSGRYellow = "33"
+	cliSGRCyan   = "36"
+)
+
+// paint wraps text in the SGR sequence when color is enabled.
+func (s cliOutputStyle) paint(sgr, text string) string {
+	if !s.color || sgr == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + sgr + "m" + text + "\x1b[0m"
+}
+
+type cliTableAlign int
+
+const (
+	cliAlignLeft cliTableAlign = iota
+	cliAlignRight
+)
+
+// cliTableColumn describes one column. Flexible columns are truncated with
+// "…" when the table is wider than the terminal; fixed columns never shrink.
+type cliTableColumn struct {
+	header string
+	align  cliTableAlign
+	flex   bool
+	sgr    string // color for cells in this column; "" for plain
+}
+
+// cliTableMinFlexWidth is the narrowest a flexible column is squeezed to.
+const cliTableMinFlexWidth = 8
+
+// cliTable is the shared renderer for read-only tabular CLI output, so every
+// command aligns, truncates, and colors columns the same way.
+type cliTable struct {
+	columns []cliTableColumn
+	rows    [][]string
+	indent  string
+}
+
+func (t *cliTable) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// columnWidths measures display widths (not bytes) and then shrinks flexible
+// columns, rightmost first, until the row fits in width.
+func (t cliTable) columnWidths(width int) []int {
+	widths := make([]int, len(t.columns))
+	for i, col := range t.columns {
+		widths[i] = runewidth.StringWidth(col.header)
+	}
+	for _, row := range t.rows {
+		for i := range t.columns {
+			if i < len(row) {
+				widths[i] = max(widths[i], runewidth.StringWidth(row[i]))
+			}
+		}
+	}
+	if width <= 0 {
+		return widths
+	}
+
+	total := runewidth.StringWidth(t.indent) + 2*(len(widths)-1)
+	for _, w := range widths {
+		total += w
+	}
+	for i := len(t.columns) - 1; i >= 0 && total > width; i-- {
+		if !t.columns[i].flex {
+			continue
+		}
+		floor := min(widths[i], max(cliTableMinFlexWidth, runewidth.StringWidth(t.columns[i].header)))
+		shrink := min(total-width, widths[i]-floor)
+		widths[i] -= shrink
+		total -= shrink
+	}
+	return widths
+}
+
+// render returns the header, a rule, and one line per row. The last column is
+// not padded so lines carry no trailing spaces.
+func (t cliTable) render(style cliOutputStyle) []string {
+	widths := t.columnWidths(style.width)
+	lines := make([]string, 0, len(t.rows)+2)
+
+	headers := make([]string, len(t.columns))
+	rules := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		headers[i] = col.header
+		rules[i] = strings.Repeat("-", widths[i])
+	}
+	lines = append(lines, style.paint(cliSGRBold, t.renderLine(headers, widths, cliOutputStyle{})))
+	lines = append(lines, style.paint(cliSGRDim, t.renderLine(rules, widths, cliOutputStyle{})))
+	for _, row := range t.rows {
+		lines = append(lines, t.renderLine(row, widths, style))
+	}
+	return lines
+}
+
+func (t cliTable) renderLine(cells []string, widths []int, style cliOutputStyle) string {
+	var b strings.Builder
+	b.WriteString(t.indent)
+	for i, col := range t.columns {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		if runewidth.StringWidth(cell) > widths[i] {
+			cell = runewidth.Truncate(cell, widths[i], "…")
+		}
+		pad := strings.Repeat(" ", widths[i]-runewidth.StringWidth(cell))
+		last := i == len(t.columns)-1
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		switch {
+		case col.align == cliAlignRight:
+			b.WriteString(pad + style.paint(col.sgr, cell))
+		case last:
+			b.WriteString(style.paint(col.sgr, cell))
+		default:
+			b.WriteString(style.paint(col.sgr, cell) + pad)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func newTestCLITable() cliTable {
+	table := cliTable{
+		indent: "  ",
+		columns: []cliTableColumn{
+			{header: "id", align: cliAlignRight},
+			{header: "title", flex: true},
+			{header: "tokens", align: cliAlignRight, sgr: cliSGRYellow},
+		},
+	}
+	table.addRow("7", "a fairly long conversation title that will not fit", "120t")
+	table.addRow("12", "short", "8t")
+	return table
+}
+
+func TestCLITableAlignsColumnsWithoutColor(t *testing.T) {
+	lines := newTestCLITable().render(cliOutputStyle{})
+	want := []string{
+		"  id  title                                               tokens",
+		"  --  --------------------------------------------------  ------",
+		"   7  a fairly long conversation title that will not fit    120t",
+		"  12  short                                                   8t",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected table:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestCLITableTruncatesFlexColumnToWidth(t *testing.T) {
+	lines := newTestCLITable().render(cliOutputStyle{width: 30})
+	for _, line := range lines {
+		if w := runewidth.StringWidth(line); w > 30 {
+			t.Fatalf("line %q is %d columns, want <= 30", line, w)
+		}
+	}
+	if !strings.Contains(lines[2], "…") || !strings.HasSuffix(lines[2], "120t") {
+		t.Fatalf("expected truncated title with tokens kept, got %q", lines[2])
+	}
+	if strings.Contains(strings.Join(lines, ""), "\x1b[") {
+		t.Fatal("expected no escape sequences with color disabled")
+	}
+}
+
+func TestCLITableColorsHeaderAndColumns(t *testing.T) {
+	lines := newTestCLITable().render(cliOutputStyle{color: true})
+	if !strings.HasPrefix(lines[0], "\x1b[1m") {
+		t.Fatalf("expected bold header, got %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "\x1b[33m120t\x1b[0m") {
+		t.Fatalf("expected colored tokens cell, got %q", lines[2])
+	}
+}
+
+func TestPrintUnifiedDiffCLIColorsOnlyWithStyle(t *testing.T) {
+	diff := buildUnifiedDiff("old/sum_a", "new/sum_a", "one\ntwo", "one\nthree")
+	var plain, colored strings.Builder
+	printUnifiedDiffCLI(&plain, diff, cliOutputStyle{})
+	printUnifiedDiffCLI(&colored, diff, cliOutputStyle{color: true})
+	if strings.Contains(plain.String(), "\x1b[") || !strings.Contains(plain.String(), "+three") {
+		t.Fatalf("plain diff should have no escapes:\n%q", plain.String())
+	}
+	if !strings.Contains(colored.String(), "\x1b[32m+three\x1b[0m") {
+		t.Fatalf("colored diff should paint additions green:\n%q", colored.String())
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+	"github.com/charmbracelet/x/term"
+)
+
+// defaultCLIWrapWidth is used when stdout is not a terminal (logs, pipes)
+// and COLUMNS is unset.
+const defaultCLIWrapWidth = 100
+
+// resolveCLIWrapWidth picks the column width for printed summary content:
+// an explicit --width, else the terminal width, else COLUMNS, else 100.
+// Returns 0 when wrapping is disabled with --wrap=false.
+func resolveCLIWrapWidth(wrap bool, width int) int {
+	if !wrap {
+		return 0
+	}
+	if width > 0 {
+		return width
+	}
+	if cols, _, err := term.GetSize(os.Stdout.Fd()); err == nil && cols > 0 {
+		return cols
+	}
+	if cols, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COLUMNS"))); err == nil && cols > 0 {
+		return cols
+	}
+	return defaultCLIWrapWidth
+}
+
+// wrapCLIText word-wraps content for CLI output with the same wrapText the
+// TUI panes use. A width of 0 leaves lines as stored.
+func wrapCLIText(text string, width int) string {
+	if width <= 0 {
+		return strings.TrimSpace(text)
+	}
+	return wrapText(text, width)
+}
+
+// defaultPreviewTokens bounds source and prompt previews in CLI dry runs.
+const defaultPreviewTokens = 300
+
+// previewTokens returns roughly the first limit tokens of text (using the
+// same chars/4 estimate as lcm.EstimateTokenCount), cut back to a word
+// boundary, followed by a "… N more tokens" line. A limit of 0 returns the
+// whole text.
+func previewTokens(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	maxBytes := limit * 4
+	if limit <= 0 || len(text) <= maxBytes {
+		return text
+	}
+	cut := maxBytes
+	for cut > 0 && !isRuneStart(text[cut]) {
+		cut--
+	}
+	if space := strings.LastIndexAny(text[:cut], " \n\t"); space > maxBytes/2 {
+		cut = space
+	}
+	head := strings.TrimRight(text[:cut], " \n\t")
+	return fmt.Sprintf("%s\n… %d more tokens", head, lcm.EstimateTokenCount(text)-lcm.EstimateTokenCount(head))
+}
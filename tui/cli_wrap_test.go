@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveCLIWrapWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "72")
+	if got := resolveCLIWrapWidth(true, 60); got != 60 {
+		t.Fatalf("explicit width: got %d, want 60", got)
+	}
+	// go test's stdout is not a terminal, so COLUMNS applies.
+	if got := resolveCLIWrapWidth(true, 0); got != 72 {
+		t.Fatalf("COLUMNS width: got %d, want 72", got)
+	}
+	t.Setenv("COLUMNS", "")
+	if got := resolveCLIWrapWidth(true, 0); got != defaultCLIWrapWidth {
+		t.Fatalf("fallback width: got %d, want %d", got, defaultCLIWrapWidth)
+	}
+	if got := resolveCLIWrapWidth(false, 60); got != 0 {
+		t.Fatalf("--wrap=false: got %d, want 0", got)
+	}
+}
+
+func TestWrapCLIText(t *testing.T) {
+	text := "  alpha beta gamma delta epsilon  "
+	for _, line := range strings.Split(wrapCLIText(text, 12), "\n") {
+		if len(line) > 12 {
+			t.Fatalf("line %q exceeds width 12", line)
+		}
+	}
+	if got := wrapCLIText(text, 0); got != "alpha beta gamma delta epsilon" {
+		t.Fatalf("width 0 should only trim, got %q", got)
+	}
+}
+
+func TestParseRewriteArgsWidth(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"44", "--all", "--width", "80"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.wrapWidth != 80 {
+		t.Fatalf("wrapWidth = %d, want 80", opts.wrapWidth)
+	}
+	opts, _, err = parseRewriteArgs([]string{"44", "--all", "--wrap=false"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.wrapWidth != 0 {
+		t.Fatalf("wrapWidth = %d, want 0 with --wrap=false", opts.wrapWidth)
+	}
+	if _, _, err := parseRepairArgs([]string{"44", "--width", "-1"}); err == nil {
+		t.Fatal("expected negative --width to be rejected")
+	}
+}
+
+func TestPreviewTokens(t *testing.T) {
+	text := strings.Repeat("word ", 400) // 2000 chars, ~500 tokens
+	preview := previewTokens(text, 100)
+	head, tail, ok := strings.Cut(preview, "\n… ")
+	if !ok {
+		t.Fatalf("expected truncation indicator, got %q", preview)
+	}
+	if len(head) > 400 || strings.HasSuffix(head, " ") || !strings.HasSuffix(head, "word") {
+		t.Fatalf("expected ~100 tokens cut at a word boundary, got %d chars ending %q", len(head), head[len(head)-8:])
+	}
+	if !strings.HasSuffix(tail, "more tokens") {
+		t.Fatalf("unexpected indicator %q", tail)
+	}
+	if got := previewTokens(text, 0); got != strings.TrimSpace(text) {
+		t.Fatal("limit 0 should return the full text")
+	}
+	if got := previewTokens("short", 100); got != "short" {
+		t.Fatalf("short text should be unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const clipboardTimeout = 5 * time.Second
+
+// clipboardTool is an external command that reads text on stdin and places it
+// on the system clipboard.
+type clipboardTool struct {
+	name string
+	args []string
+}
+
+// clipboardCandidates lists clipboard tools in preference order for the
+// current platform. Wayland and X11 tools are both tried on Linux because a
+// session can expose either; clip.exe covers WSL.
+func clipboardCandidates() []clipboardTool {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardTool{{name: "pbcopy"}}
+	case "windows":
+		return []clipboardTool{{name: "clip.exe"}}
+	}
+	var tools []clipboardTool
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, clipboardTool{name: "wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		tools = append(tools,
+			clipboardTool{name: "xclip", args: []string{"-selection", "clipboard"}},
+			clipboardTool{name: "xsel", args: []string{"--clipboard", "--input"}},
+		)
+	}
+	return append(tools, clipboardTool{name: "clip.exe"})
+}
+
+// copyToClipboard writes text to the first available clipboard tool.
+func copyToClipboard(text string) error {
+	for _, tool := range clipboardCandidates() {
+		path, err := lookupCLIPath(tool.name)
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		cmd := execCLICommand(ctx, path, tool.args...)
+		cmd.Stdin = strings.NewReader(text)
+		output, err := cmd.CombinedOutput()
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", tool.name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return errors.New("no clipboard tool found (install pbcopy, wl-copy, xclip, or xsel)")
+}
+
+// cliOutput is where a read-only command writes its report. With --clipboard
+// the report is buffered and copied on flush; otherwise it goes to stdout.
+type cliOutput struct {
+	w      io.Writer
+	buffer *bytes.Buffer
+}
+
+func newCLIOutput(clipboard bool) *cliOutput {
+	if !clipboard {
+		return &cliOutput{w: os.Stdout}
+	}
+	buffer := &bytes.Buffer{}
+	return &cliOutput{w: buffer, buffer: buffer}
+}
+
+// style is resolveCLIOutputStyle without color when buffering, so pasted
+// output carries no escape sequences.
+func (o *cliOutput) style() cliOutputStyle {
+	style := resolveCLIOutputStyle()
+	if o.buffer != nil {
+		style.color = false
+	}
+	return style
+}
+
+// flush copies buffered output to the clipboard. When no clipboard is
+// available it warns on stderr and prints the output to stdout instead, so
+// the report is never lost.
+func (o *cliOutput) flush() error {
+	if o.buffer == nil {
+		return nil
+	}
+	text := o.buffer.String()
+	if err := copyToClipboard(text); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not copy to clipboard (%v); printing to stdout.\n", err)
+		_, err := os.Stdout.WriteString(text)
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Copied %d lines to the clipboard.\n", strings.Count(text, "\n"))
+	return nil
+}
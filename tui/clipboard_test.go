@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stubClipboardTool(t *testing.T, available bool) string {
+	t.Helper()
+
+	outPath := filepath.Join(t.TempDir(), "clipboard.txt")
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	t.Setenv("LCM_CLIPBOARD_OUT", outPath)
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+
+	originalLookup := lookupCLIPath
+	originalExec := execCLICommand
+	lookupCLIPath = func(file string) (string, error) {
+		if !available {
+			return "", exec.ErrNotFound
+		}
+		return "/tmp/fake-" + file, nil
+	}
+	execCLICommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		cmdArgs := append([]string{"-test.run=TestHelperProcessClipboard", "--", name}, args...)
+		return exec.CommandContext(ctx, os.Args[0], cmdArgs...)
+	}
+	t.Cleanup(func() {
+		lookupCLIPath = originalLookup
+		execCLICommand = originalExec
+	})
+	return outPath
+}
+
+func TestHelperProcessClipboard(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" || os.Getenv("LCM_CLIPBOARD_OUT") == "" {
+		return
+	}
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		os.Exit(2)
+	}
+	args := strings.Join(os.Args, " ")
+	record := fmt.Sprintf("%s\n%s", args[strings.Index(args, "-- ")+3:], input)
+	if err := os.WriteFile(os.Getenv("LCM_CLIPBOARD_OUT"), []byte(record), 0o644); err != nil {
+		os.Exit(3)
+	}
+	os.Exit(0)
+}
+
+func TestCLIOutputCopiesBufferedOutputToClipboard(t *testing.T) {
+	outPath := stubClipboardTool(t, true)
+
+	out := newCLIOutput(true)
+	if out.style().color {
+		t.Fatal("clipboard output should never be colored")
+	}
+	fmt.Fprintln(out.w, "Conversation 44 context")
+	if err := out.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read clipboard record: %v", err)
+	}
+	if tool := clipboardCandidates()[0]; tool.name == "xclip" {
+		if want := "/tmp/fake-xclip -selection clipboard\nConversation 44 context\n"; string(data) != want {
+			t.Fatalf("unexpected clipboard record %q, want %q", data, want)
+		}
+	} else if !strings.HasSuffix(string(data), "\nConversation 44 context\n") {
+		t.Fatalf("unexpected clipboard record %q", data)
+	}
+}
+
+func TestCopyToClipboardReportsMissingTool(t *testing.T) {
+	stubClipboardTool(t, false)
+
+	err := copyToClipboard("text")
+	if err == nil || !strings.Contains(err.Error(), "no clipboard tool found") {
+		t.Fatalf("expected a missing-tool error, got %v", err)
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		t.Fatal("lookup failures should be folded into the missing-tool error")
+	}
+	if err := newCLIOutput(false).flush(); err != nil {
+		t.Fatalf("stdout output should flush as a no-op: %v", err)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 func TestHasCodexOAuth(t *testing.T) {
@@ -313,8 +315,8 @@ func TestSummarizeOpenAICodexOAuthCapsOversizeCLIOutput(t *testing.T) {
 	if err != nil {
 		t.Fatalf("summarize returned error: %v", err)
 	}
-	if estimateTokenCount(summary) > 32+cliOutputTokenSlack {
-		t.Fatalf("expected capped summary within slack limit, got %d tokens", estimateTokenCount(summary))
+	if lcm.EstimateTokenCount(summary) > 32+cliOutputTokenSlack {
+		t.Fatalf("expected capped summary within slack limit, got %d tokens", lcm.EstimateTokenCount(summary))
 	}
 	if !strings.Contains(summary, "[Capped") {
 		t.Fatalf("expected capped marker, got %q", summary)
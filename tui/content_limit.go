@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultContentMaxChars caps each content body (a summary, message, or
+// file exploration summary) wherever lcm-tui shows it: the TUI's panes,
+// export-context, and grep snippets. A single multi-megabyte row would
+// otherwise flood a terminal or pipe.
+const defaultContentMaxChars = 100_000
+
+// resolveContentMaxChars reads the cap from LCM_TUI_CONTENT_MAX_CHARS; 0
+// disables it. CLI commands take it as the default for --content-max-chars.
+func resolveContentMaxChars() int {
+	value := strings.TrimSpace(os.Getenv("LCM_TUI_CONTENT_MAX_CHARS"))
+	if value == "" {
+		return defaultContentMaxChars
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Printf("[lcm-tui] invalid LCM_TUI_CONTENT_MAX_CHARS=%q, using default %d", value, defaultContentMaxChars)
+		return defaultContentMaxChars
+	}
+	return parsed
+}
+
+// displayContentMaxChars is the cap for content loaded into the TUI.
+var displayContentMaxChars = resolveContentMaxChars()
+
+// resolveContentLimitFlags turns --content-max-chars and --full into the
+// cap a command applies; --full wins and means no cap.
+func resolveContentLimitFlags(maxChars int, full bool) (int, error) {
+	if maxChars < 0 {
+		return 0, fmt.Errorf("--content-max-chars must be >= 0")
+	}
+	if full {
+		return 0, nil
+	}
+	return maxChars, nil
+}
+
+// truncateContent keeps the first limit characters of s, cut at a rune
+// boundary, and appends contentTruncationNotice. It reports whether s was
+// cut; limit <= 0 returns s unchanged.
+func truncateContent(s string, limit int) (string, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+	chars := 0
+	for i := range s {
+		if chars == limit {
+			return s[:i] + contentTruncationNotice(len(s)), true
+		}
+		chars++
+	}
+	return s, false
+}
+
+// contentTruncationNotice is the one marker every output path appends to
+// cut content, so truncation reads the same in the TUI, exports, and grep.
+func contentTruncationNotice(fullBytes int) string {
+	return fmt.Sprintf("\n\n[truncated — full content is %s]", formatByteSizeCompact(int64(fullBytes)))
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type checkContextOptions struct {
+	fix      bool
+	reorder  bool
+	backupDB bool // copy lcm.db before --fix or --reorder writes
+}
+
+// foreignContextItem is a context_items row whose summary or message is owned
+// by a different conversation. Assembly would splice another conversation's
+// content into this one's prompt.
+type foreignContextItem struct {
+	ordinal        int64
+	itemType       string
+	summaryID      string
+	messageID      int64
+	ownerID        int64
+	ownerIsMessage bool
+}
+
+func (item foreignContextItem) describe() string {
+	if item.ownerIsMessage {
+		return fmt.Sprintf("ordinal %d: %s message #%d belongs to conversation %d", item.ordinal, item.itemType, item.messageID, item.ownerID)
+	}
+	return fmt.Sprintf("ordinal %d: %s %s belongs to conversation %d", item.ordinal, item.itemType, item.summaryID, item.ownerID)
+}
+
+// runCheckContextCommand executes the standalone check-context CLI path.
+func runCheckContextCommand(args []string) error {
+	opts, conversationID, err := parseCheckContextArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	foreign, err := findForeignContextItems(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printForeignContextItems(conversationID, foreign)
+	items, err := lcm.LoadContextItems(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	layout := lcm.AnalyzeContextLayout(items)
+	printContextLayoutReport(conversationID, layout)
+	parentIssues, err := findParentOrdinalIssues(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printParentOrdinalIssues(conversationID, parentIssues)
+	kindMismatches, err := findSummaryKindMismatches(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printSummaryKindMismatches(conversationID, kindMismatches)
+	orphans, err := buildOrphanPrunePlan(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printOrphanSummaries(orphans)
+	if len(orphans.prunable) > 0 {
+		fmt.Printf("\nUse lcm-tui prune %d --orphans-only to preview deleting them, then add --apply.\n", conversationID)
+	}
+	if len(foreign) == 0 && !layout.Interleaved() && len(parentIssues) == 0 && len(kindMismatches) == 0 {
+		return nil
+	}
+
+	if len(foreign) > 0 && !opts.fix {
+		fmt.Println("\nDry run. Use --fix to remove foreign rows and resequence ordinals.")
+	}
+	if len(parentIssues) > 0 && !opts.fix {
+		fmt.Println("\nDry run. Use --fix to renumber summary_parents ordinals to 0..N-1 in current order.")
+	}
+	if len(kindMismatches) > 0 && !opts.fix {
+		fmt.Println("\nDry run. Use --fix to set each summary's kind from its depth (0 leaf, >0 condensed).")
+	}
+	if layout.Interleaved() && !opts.reorder {
+		fmt.Println("\nDry run. Use --reorder to move messages behind the summaries, keeping both in their current order.")
+	}
+	fixes := opts.fix && (len(foreign) > 0 || len(parentIssues) > 0 || len(kindMismatches) > 0)
+	if fixes || (opts.reorder && layout.Interleaved()) {
+		if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "check-context", opts.backupDB, nil); err != nil {
+			return err
+		}
+	}
+	if len(foreign) > 0 && opts.fix {
+		removed, err := fixForeignContextItems(ctx, db, conversationID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nDone. Removed %d context items; ordinals resequenced. Changes take effect on next conversation turn.\n", removed)
+	}
+	if len(parentIssues) > 0 && opts.fix {
+		renumbered, err := fixParentOrdinals(ctx, db, conversationID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nDone. Renumbered summary_parents ordinals for %d summaries.\n", renumbered)
+	}
+	if len(kindMismatches) > 0 && opts.fix {
+		fixed, err := fixSummaryKinds(ctx, db, conversationID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nDone. Reconciled kind with depth for %d summaries.\n", fixed)
+	}
+	if layout.Interleaved() && opts.reorder {
+		moved, err := reorderContextItems(ctx, db, conversationID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nDone. Reordered context; %d items moved. Changes take effect on next conversation turn.\n", moved)
+	}
+	return nil
+}
+
+// findForeignContextItems returns conversationID's context_items whose
+// summary_id or message_id points at a row owned by another conversation.
+// Dangling references (no matching row at all) are not reported here.
+func findForeignContextItems(ctx context.Context, q sqlQueryer, conversationID int64) ([]foreignContextItem, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT ci.ordinal, ci.item_type, ci.summary_id, 0, s.conversation_id, 0
+		FROM context_items ci
+		JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ? AND s.conversation_id != ci.conversation_id
+		UNION ALL
+		SELECT ci.ordinal, ci.item_type, '', ci.message_id, m.conversation_id, 1
+		FROM context_items ci
+		JOIN messages m ON m.message_id = ci.message_id
+		WHERE ci.conversation_id = ? AND m.conversation_id != ci.conversation_id
+		ORDER BY 1 ASC
+	`, conversationID, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query foreign context items for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var items []foreignContextItem
+	for rows.Next() {
+		var item foreignContextItem
+		if err := rows.Scan(&item.ordinal, &item.itemType, &item.summaryID, &item.messageID, &item.ownerID, &item.ownerIsMessage); err != nil {
+			return nil, fmt.Errorf("scan foreign context item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate foreign context items: %w", err)
+	}
+	return items, nil
+}
+
+func printForeignContextItems(conversationID int64, items []foreignContextItem) {
+	if len(items) == 0 {
+		fmt.Printf("Conversation %d: OK, every context item belongs to this conversation.\n", conversationID)
+		return
+	}
+	fmt.Printf("Conversation %d: %d context items point at another conversation:\n", conversationID, len(items))
+	for _, item := range items {
+		fmt.Printf("  %s\n", item.describe())
+	}
+}
+
+// fixForeignContextItems deletes the foreign rows and renumbers the remaining
+// ordinals in one transaction. The set is recomputed inside the transaction
+// so the fix matches the DB it commits to.
+func fixForeignContextItems(ctx context.Context, db *sql.DB, conversationID int64) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin check-context transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	foreign, err := findForeignContextItems(ctx, tx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range foreign {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM context_items
+			WHERE conversation_id = ? AND ordinal = ?
+		`, conversationID, item.ordinal); err != nil {
+			return 0, fmt.Errorf("delete context item at ordinal %d: %w", item.ordinal, err)
+		}
+	}
+	if err := lcm.ResequenceContextOrdinals(ctx, tx, conversationID); err != nil {
+		return 0, err
+	}
+	if len(foreign) > 0 {
+		var summaryIDs []string
+		for _, item := range foreign {
+			if item.summaryID != "" {
+				summaryIDs = append(summaryIDs, item.summaryID)
+			}
+		}
+		if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+			Command: "check-context --fix", ConversationID: conversationID, SummaryIDs: summaryIDs,
+			Detail: fmt.Sprintf("removed %d foreign context items", len(foreign)),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit check-context fix: %w", err)
+	}
+	rollback = false
+	return len(foreign), nil
+}
+
+func parseCheckContextArgs(args []string) (checkContextOptions, int64, error) {
+	fs := flag.NewFlagSet("check-context", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fix := fs.Bool("fix", false, "remove foreign context items, renumber context and summary_parents ordinals, and reconcile kind with depth")
+	reorder := fs.Bool("reorder", false, "reorder interleaved context items into summaries-then-messages order")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before --fix or --reorder writes")
+
+	normalized := normalizePruneArgs(args)
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return checkContextOptions{}, 0, errors.New(checkContextUsageText())
+		}
+		return checkContextOptions{}, 0, fmt.Errorf("%w\n%s", err, checkContextUsageText())
+	}
+	if fs.NArg() != 1 {
+		return checkContextOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", checkContextUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return checkContextOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), checkContextUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return checkContextOptions{}, 0, fmt.Errorf("%w\n%s", err, checkContextUsageText())
+	}
+	return checkContextOptions{fix: *fix, reorder: *reorder, backupDB: backup}, conversationID, nil
+}
+
+func checkContextUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui check-context <conversation_id> [--fix] [--reorder] [--backup-db]
+
+Report context_items rows whose summary_id or message_id belongs to a
+different conversation (left behind by a bad transplant or manual edit).
+Assembly would splice that content into this conversation's prompt.
+
+Also report an interleaved layout: summaries of different depths mixed
+together, or messages between summaries, after manual dissolves or
+transplants. Compaction only condenses contiguous same-depth runs, so it
+misses chunks in such a context.
+
+Also report condensed summaries whose summary_parents ordinals repeat or
+skip values. Rewrite orders source children by ordinal, so drifted ordinals
+silently reorder the text it summarizes.
+
+Also report summaries whose kind disagrees with their depth (a leaf above
+d0, or a condensed summary at d0). Rewrite and repair pick the prompt
+template and token target from these, so a mismatch is summarized with the
+wrong prompt.
+
+Also report orphaned summaries: summaries that no context item, focus
+brief, or summary_parents edge references. prune --orphans-only deletes
+them. Read-only unless --fix or --reorder is given.
+
+Flags:
+  --fix       Delete the foreign rows and resequence ordinals to 0..N-1;
+              renumber drifted summary_parents ordinals to 0..N-1;
+              set kind from depth (0 leaf, >0 condensed)
+  --reorder   Rewrite ordinals as summaries followed by messages, each in
+              their current relative order
+  --backup-db Back up lcm.db before --fix or --reorder writes anything
+`)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func TestForeignContextItemsDetectedAndFixed(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title)
+		VALUES (1, 'session-a', 'A'), (2, 'session-b', 'B')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(10, 1, 0, 'user', 'mine', 1, '2026-03-01T10:00:00Z'),
+			(20, 2, 0, 'user', 'theirs', 1, '2026-03-01T10:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_mine', 1, 'leaf', 0, 'mine', 1, '2026-03-01T10:01:00Z'),
+			('sum_theirs', 2, 'leaf', 0, 'theirs', 1, '2026-03-01T10:01:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES
+			(1, 0, 'summary', NULL, 'sum_mine'),
+			(1, 1, 'summary', NULL, 'sum_theirs'),
+			(1, 2, 'message', 20, NULL),
+			(1, 3, 'message', 10, NULL),
+			(2, 0, 'summary', NULL, 'sum_theirs')
+	`)
+	ctx := context.Background()
+
+	foreign, err := findForeignContextItems(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(foreign) != 2 || foreign[0].summaryID != "sum_theirs" || foreign[0].ownerID != 2 ||
+		!foreign[1].ownerIsMessage || foreign[1].messageID != 20 {
+		t.Fatalf("unexpected foreign items %+v", foreign)
+	}
+	if other, err := findForeignContextItems(ctx, db, 2); err != nil || len(other) != 0 {
+		t.Fatalf("conversation 2 should be clean, got %+v (%v)", other, err)
+	}
+
+	removed, err := fixForeignContextItems(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("fix: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("removed = %d, want 2", removed)
+	}
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 0 AND summary_id = 'sum_mine'`, 1)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 1 AND message_id = 10`, 1)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1`, 2)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 2`, 1)
+}
+
+func TestInterleavedContextLayoutDetectedAndReordered(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(10, 1, 0, 'user', 'early', 5, '2026-03-01T10:00:00Z'),
+			(11, 1, 1, 'user', 'late', 5, '2026-03-01T11:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_d1a', 1, 'condensed', 1, 'a', 10, '2026-03-01T10:01:00Z'),
+			('sum_d0a', 1, 'leaf', 0, 'b', 10, '2026-03-01T10:01:00Z'),
+			('sum_d1b', 1, 'condensed', 1, 'c', 10, '2026-03-01T10:01:00Z'),
+			('sum_d0b', 1, 'leaf', 0, 'd', 10, '2026-03-01T10:01:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES
+			(1, 0, 'summary', NULL, 'sum_d1a'),
+			(1, 1, 'summary', NULL, 'sum_d0a'),
+			(1, 2, 'message', 10, NULL),
+			(1, 3, 'summary', NULL, 'sum_d1b'),
+			(1, 4, 'summary', NULL, 'sum_d0b'),
+			(1, 5, 'message', 11, NULL)
+	`)
+	ctx := context.Background()
+
+	items, err := lcm.LoadContextItems(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("load items: %v", err)
+	}
+	report := lcm.AnalyzeContextLayout(items)
+	if !report.Interleaved() || report.DepthRuns[1] != 2 || report.DepthRuns[0] != 2 ||
+		report.MessagesBeforeSummary != 1 || report.LastSummaryOrdinal != 4 {
+		t.Fatalf("unexpected layout report %+v", report)
+	}
+	if chunk, _ := lcm.SelectChunkAtDepth(items, 1, 1000, 99); len(chunk) != 1 {
+		t.Fatalf("interleaved context should split the d1 run, got %d items", len(chunk))
+	}
+
+	moved, err := reorderContextItems(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	if moved != 3 {
+		t.Fatalf("moved = %d, want 3", moved)
+	}
+	items, err = lcm.LoadContextItems(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("reload items: %v", err)
+	}
+	var order []string
+	for _, item := range items {
+		if item.SummaryID.Valid {
+			order = append(order, item.SummaryID.String)
+		} else {
+			order = append(order, fmt.Sprintf("msg%d", item.MessageID.Int64))
+		}
+	}
+	if strings.Join(order, ",") != "sum_d1a,sum_d0a,sum_d1b,sum_d0b,msg10,msg11" {
+		t.Fatalf("unexpected canonical order %v", order)
+	}
+	if lcm.AnalyzeContextLayout(items).Interleaved() {
+		t.Fatal("reordered context should be canonical")
+	}
+	if chunk, _ := lcm.SelectChunkAtDepth(items, 0, 1000, 99); len(chunk) != 1 {
+		t.Fatalf("reorder must not regroup summaries by depth, got a %d-item d0 run", len(chunk))
+	}
+	if moved, err := reorderContextItems(ctx, db, 1); err != nil || moved != 0 {
+		t.Fatalf("second reorder should be a no-op, moved %d (%v)", moved, err)
+	}
+}
+
+func TestParentOrdinalDriftDetectedAndRenumbered(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_a', 1, 'leaf', 0, 'a', 1, '2026-03-01T10:00:00Z'),
+			('sum_b', 1, 'leaf', 0, 'b', 1, '2026-03-01T10:01:00Z'),
+			('sum_c', 1, 'leaf', 0, 'c', 1, '2026-03-01T10:02:00Z'),
+			('sum_d', 1, 'leaf', 0, 'd', 1, '2026-03-01T10:03:00Z'),
+			('sum_dup', 1, 'condensed', 1, 'dup', 1, '2026-03-01T11:00:00Z'),
+			('sum_gap', 1, 'condensed', 1, 'gap', 1, '2026-03-01T11:01:00Z'),
+			('sum_ok', 1, 'condensed', 2, 'ok', 1, '2026-03-01T12:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES
+			('sum_dup', 'sum_b', 0),
+			('sum_dup', 'sum_a', 0),
+			('sum_dup', 'sum_c', 1),
+			('sum_gap', 'sum_d', 3),
+			('sum_gap', 'sum_c', 1),
+			('sum_ok', 'sum_dup', 0),
+			('sum_ok', 'sum_gap', 1)
+	`)
+	ctx := context.Background()
+
+	issues, err := findParentOrdinalIssues(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 summaries with drifted ordinals, got %+v", issues)
+	}
+	if got := issues[0].describe(); got != "sum_dup (d1, 3 children): ordinals 0,0,1; duplicate 0" {
+		t.Fatalf("unexpected sum_dup report %q", got)
+	}
+	if got := issues[1].describe(); got != "sum_gap (d1, 2 children): ordinals 1,3; missing 0,2" {
+		t.Fatalf("unexpected sum_gap report %q", got)
+	}
+
+	renumbered, err := fixParentOrdinals(ctx, db, 1)
+	if err != nil || renumbered != 2 {
+		t.Fatalf("fix: renumbered=%d err=%v", renumbered, err)
+	}
+	// Duplicates keep the older parent first; gaps close up in current order.
+	for _, want := range []string{
+		`sum_dup:sum_a:0`, `sum_dup:sum_b:1`, `sum_dup:sum_c:2`,
+		`sum_gap:sum_c:0`, `sum_gap:sum_d:1`,
+		`sum_ok:sum_dup:0`, `sum_ok:sum_gap:1`,
+	} {
+		parts := strings.Split(want, ":")
+		assertCountQuery(t, db, fmt.Sprintf(
+			`SELECT COUNT(*) FROM summary_parents WHERE summary_id = '%s' AND parent_summary_id = '%s' AND ordinal = %s`,
+			parts[0], parts[1], parts[2]), 1)
+	}
+	if issues, err := findParentOrdinalIssues(ctx, db, 1); err != nil || len(issues) != 0 {
+		t.Fatalf("expected clean ordinals after fix, got %+v (%v)", issues, err)
+	}
+}
+
+func TestSummaryKindMismatchDetectedAndReconciled(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_leaf', 1, 'leaf', 0, 'a', 1, '2026-03-01T10:00:00Z'),
+			('sum_bad_leaf', 1, 'leaf', 2, 'b', 1, '2026-03-01T10:01:00Z'),
+			('sum_bad_cond', 1, 'condensed', 0, 'c', 1, '2026-03-01T10:02:00Z'),
+			('sum_cond', 1, 'condensed', 1, 'd', 1, '2026-03-01T10:03:00Z')
+	`)
+	ctx := context.Background()
+
+	mismatches, err := findSummaryKindMismatches(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("expected 2 mismatches, got %+v", mismatches)
+	}
+	if got := mismatches[0].describe(); got != `sum_bad_cond: kind "condensed" at depth 0, expected "leaf"` {
+		t.Fatalf("unexpected d0 report %q", got)
+	}
+	if got := mismatches[1].describe(); got != `sum_bad_leaf: kind "leaf" at depth 2, expected "condensed"` {
+		t.Fatalf("unexpected d2 report %q", got)
+	}
+
+	fixed, err := fixSummaryKinds(ctx, db, 1)
+	if err != nil || fixed != 2 {
+		t.Fatalf("fix: fixed=%d err=%v", fixed, err)
+	}
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_bad_cond' AND kind = 'leaf' AND depth = 0`, 1)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_bad_leaf' AND kind = 'condensed' AND depth = 2`, 1)
+	if mismatches, err := findSummaryKindMismatches(ctx, db, 1); err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected no mismatches after fix, got %+v (%v)", mismatches, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expectedFreshTailMessages is the raw-message tail compaction normally
+// leaves, matching backfill's --fresh-tail default. The grouped context view
+// flags tails far from it.
+const expectedFreshTailMessages = 32
+
+// contextGroup is a contiguous run of context items that share a segment:
+// summaries of one depth, focus briefs, or raw messages. The trailing
+// message run is the fresh tail.
+type contextGroup struct {
+	label     string
+	start     int // index into the item slice
+	end       int // exclusive
+	tokens    int
+	freshTail bool
+	warning   string // set when the fresh tail looks unusually large or small
+}
+
+// contextGroupRow is one line of the grouped view: a group header when
+// itemIndex is -1, otherwise the item at itemIndex.
+type contextGroupRow struct {
+	itemIndex int
+	group     int
+}
+
+// groupContextItems splits items (in ordinal order) into contiguous
+// segments and flags a fresh tail that is much larger or smaller than
+// expectedFreshTailMessages, or that holds most of the context's tokens.
+func groupContextItems(items []contextItemEntry) []contextGroup {
+	var groups []contextGroup
+	totalTokens := 0
+	for i, item := range items {
+		totalTokens += item.tokenCount
+		key := contextGroupLabel(item)
+		if n := len(groups); n > 0 && groups[n-1].label == key {
+			groups[n-1].end = i + 1
+			groups[n-1].tokens += item.tokenCount
+			continue
+		}
+		groups = append(groups, contextGroup{label: key, start: i, end: i + 1, tokens: item.tokenCount})
+	}
+
+	if n := len(groups); n > 0 && items[groups[n-1].start].itemType == "message" {
+		tail := &groups[n-1]
+		tail.freshTail = true
+		tail.label = "fresh tail"
+		messages := tail.end - tail.start
+		switch {
+		case n > 1 && messages < expectedFreshTailMessages/8:
+			tail.warning = fmt.Sprintf("small: %d raw messages, expected about %d", messages, expectedFreshTailMessages)
+		case messages > 2*expectedFreshTailMessages:
+			tail.warning = fmt.Sprintf("large: %d raw messages, expected about %d; compaction may be behind", messages, expectedFreshTailMessages)
+		case n > 1 && totalTokens > 0 && tail.tokens*100/totalTokens > 60:
+			tail.warning = fmt.Sprintf("large: %d%% of context tokens; compaction may be behind", tail.tokens*100/totalTokens)
+		}
+	} else if len(items) > 0 {
+		groups[len(groups)-1].warning = "no fresh tail: the context ends in a summary"
+	}
+	return groups
+}
+
+func contextGroupLabel(item contextItemEntry) string {
+	switch item.itemType {
+	case "summary":
+		if item.kind == "condensed" {
+			return fmt.Sprintf("d%d summaries", item.depth)
+		}
+		return "leaf summaries"
+	case "focus_brief":
+		return "focus briefs"
+	default:
+		return "raw messages"
+	}
+}
+
+// buildContextGroupRows interleaves a header row before each group's items.
+func buildContextGroupRows(groups []contextGroup) []contextGroupRow {
+	var rows []contextGroupRow
+	for g, group := range groups {
+		rows = append(rows, contextGroupRow{itemIndex: -1, group: g})
+		for i := group.start; i < group.end; i++ {
+			rows = append(rows, contextGroupRow{itemIndex: i, group: g})
+		}
+	}
+	return rows
+}
+
+// formatContextGroupHeader renders "── d2 summaries · 3 items · 4210t (21%) ──".
+func formatContextGroupHeader(group contextGroup, totalTokens int) string {
+	share := ""
+	if totalTokens > 0 {
+		share = fmt.Sprintf(" (%d%%)", group.tokens*100/totalTokens)
+	}
+	count := group.end - group.start
+	noun := "items"
+	if count == 1 {
+		noun = "item"
+	}
+	return strings.Join([]string{"──", group.label, "·", fmt.Sprintf("%d %s", count, noun), "·", fmt.Sprintf("%dt%s", group.tokens, share), "──"}, " ")
+}
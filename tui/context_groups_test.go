@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupContextItemsSeparatesDepthsAndFreshTail(t *testing.T) {
+	items := []contextItemEntry{
+		{ordinal: 0, itemType: "summary", kind: "condensed", depth: 2, tokenCount: 400},
+		{ordinal: 1, itemType: "summary", kind: "condensed", depth: 1, tokenCount: 200},
+		{ordinal: 2, itemType: "summary", kind: "condensed", depth: 1, tokenCount: 200},
+		{ordinal: 3, itemType: "summary", kind: "leaf", tokenCount: 100},
+		{ordinal: 4, itemType: "message", kind: "user", tokenCount: 50},
+		{ordinal: 5, itemType: "message", kind: "assistant", tokenCount: 50},
+	}
+	groups := groupContextItems(items)
+	var labels []string
+	for _, group := range groups {
+		labels = append(labels, group.label)
+	}
+	if got := strings.Join(labels, ","); got != "d2 summaries,d1 summaries,leaf summaries,fresh tail" {
+		t.Fatalf("unexpected groups %s", got)
+	}
+	if groups[1].tokens != 400 || groups[1].end-groups[1].start != 2 {
+		t.Fatalf("unexpected d1 group %+v", groups[1])
+	}
+	tail := groups[3]
+	if !tail.freshTail || !strings.HasPrefix(tail.warning, "small: 2 raw messages") {
+		t.Fatalf("expected a small fresh tail warning, got %+v", tail)
+	}
+	if got := formatContextGroupHeader(groups[1], 1000); got != "── d1 summaries · 2 items · 400t (40%) ──" {
+		t.Fatalf("unexpected header %q", got)
+	}
+
+	rows := buildContextGroupRows(groups)
+	if len(rows) != len(items)+len(groups) || rows[0].itemIndex != -1 || rows[1].itemIndex != 0 || rows[2].itemIndex != -1 {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+}
+
+func TestGroupContextItemsFlagsLargeOrMissingTail(t *testing.T) {
+	items := []contextItemEntry{{itemType: "summary", kind: "leaf", tokenCount: 100}}
+	for i := 0; i < 2*expectedFreshTailMessages+1; i++ {
+		items = append(items, contextItemEntry{itemType: "message", kind: "user", tokenCount: 10})
+	}
+	if warning := groupContextItems(items)[1].warning; !strings.HasPrefix(warning, "large: 65 raw messages") {
+		t.Fatalf("expected a large tail warning, got %q", warning)
+	}
+
+	items = items[:1]
+	if warning := groupContextItems(items)[0].warning; !strings.HasPrefix(warning, "no fresh tail") {
+		t.Fatalf("expected a missing tail warning, got %q", warning)
+	}
+	if groups := groupContextItems(nil); len(groups) != 0 {
+		t.Fatalf("expected no groups for an empty context, got %+v", groups)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func printContextLayoutReport(conversationID int64, report lcm.ContextLayout) {
+	if !report.Interleaved() {
+		fmt.Printf("Conversation %d: context layout OK (summaries, then messages).\n", conversationID)
+		return
+	}
+	fmt.Printf("Conversation %d: context layout is interleaved; %d of %d items are out of canonical order:\n", conversationID, report.Moved, report.Items)
+	depths := make([]int, 0, len(report.DepthRuns))
+	for depth := range report.DepthRuns {
+		depths = append(depths, depth)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(depths)))
+	for _, depth := range depths {
+		fmt.Printf("  d%d summaries are split into %d runs; condensation only sees one run at a time\n", depth, report.DepthRuns[depth])
+	}
+	if report.MessagesBeforeSummary > 0 {
+		fmt.Printf("  %d messages sit before the last summary (ordinal %d)\n", report.MessagesBeforeSummary, report.LastSummaryOrdinal)
+	}
+}
+
+// reorderContextItems rewrites conversationID's ordinals into the canonical
+// layout in one transaction and returns how many items moved. The layout is
+// recomputed inside the transaction so the reorder matches the DB it
+// commits to.
+func reorderContextItems(ctx context.Context, db *sql.DB, conversationID int64) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin context reorder transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	items, err := lcm.LoadContextItems(ctx, tx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	report := lcm.AnalyzeContextLayout(items)
+	if !report.Interleaved() {
+		return 0, nil
+	}
+
+	// Stage every row at a negative ordinal first so no final ordinal
+	// collides with a row that has not moved yet.
+	ordered := lcm.CanonicalContextOrder(items)
+	for i, item := range ordered {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE context_items
+			SET ordinal = ?
+			WHERE conversation_id = ? AND ordinal = ?
+		`, -int64(i+1), conversationID, item.Ordinal); err != nil {
+			return 0, fmt.Errorf("stage context ordinal %d: %w", item.Ordinal, err)
+		}
+	}
+	for i := range ordered {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE context_items
+			SET ordinal = ?
+			WHERE conversation_id = ? AND ordinal = ?
+		`, int64(i), conversationID, -int64(i+1)); err != nil {
+			return 0, fmt.Errorf("finalize context ordinal %d: %w", i, err)
+		}
+	}
+
+	ordinals, err := loadContextOrdinals(ctx, tx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkContiguousOrdinals(ordinals); err != nil {
+		return 0, err
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "check-context --reorder", ConversationID: conversationID,
+		Detail: fmt.Sprintf("moved %d of %d context items", report.Moved, report.Items),
+	}); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit context reorder: %w", err)
+	}
+	rollback = false
+	return report.Moved, nil
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
 )
 
 func TestConversationMessageDisplayTextTruncatesLargeToolOutput(t *testing.T) {
@@ -73,3 +76,45 @@ func TestRenderActiveFocusBannerShowsStaleDiagnostics(t *testing.T) {
 		t.Fatalf("inactive focus banner = %q, want empty", inactive)
 	}
 }
+
+func TestTruncateStringPrefersWordBoundary(t *testing.T) {
+	t.Parallel()
+
+	if got := truncateString("short text", 20); got != "short text" {
+		t.Fatalf("untruncated = %q, want unchanged", got)
+	}
+	if got, want := truncateString("the quick brown fox jumps", 16), "the quick..."; got != want {
+		t.Fatalf("word boundary = %q, want %q", got, want)
+	}
+	if got, want := truncateString("supercalifragilistic", 10), "superca..."; got != want {
+		t.Fatalf("single long word = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateStringHandlesWideRunes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{name: "cjk", text: "日本語のテキストです", width: 10, want: "日本語..."},
+		{name: "cjk odd width", text: "日本語のテキストです", width: 9, want: "日本語..."},
+		{name: "emoji", text: "🚀🚀🚀🚀🚀 launch", width: 9, want: "🚀🚀🚀..."},
+		{name: "narrow width", text: "日本語", width: 3, want: "日"},
+	}
+	for _, tc := range cases {
+		got := truncateString(tc.text, tc.width)
+		if got != tc.want {
+			t.Fatalf("%s: truncateString(%q, %d) = %q, want %q", tc.name, tc.text, tc.width, got, tc.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Fatalf("%s: result %q is not valid UTF-8", tc.name, got)
+		}
+		if w := runewidth.StringWidth(got); w > tc.width {
+			t.Fatalf("%s: result width %d exceeds %d", tc.name, w, tc.width)
+		}
+	}
+}
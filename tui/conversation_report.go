@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type reportOptions struct {
+	jsonOutput    bool
+	clipboard     bool
+	top           int
+	skipStats     bool
+	skipHistogram bool
+	skipVerify    bool
+	skipOverview  bool
+}
+
+// conversationReport is everything the read-only tools know about one
+// conversation, for attaching to a bug report. Skipped sections are nil.
+// The JSON tags are the --json output contract.
+type conversationReport struct {
+	ConversationID int64                       `json:"conversation_id"`
+	SessionID      string                      `json:"session_id"`
+	Title          string                      `json:"title,omitempty"`
+	GeneratedAt    string                      `json:"generated_at"`
+	ToolVersion    string                      `json:"tool_version"`
+	Stats          *conversationReportStats    `json:"stats,omitempty"`
+	Histogram      *conversationReportHist     `json:"histogram,omitempty"`
+	Verify         *conversationReportVerify   `json:"verify,omitempty"`
+	Overview       *conversationReportOverview `json:"overview,omitempty"`
+}
+
+// conversationReportStats are row counts, token totals, and compression per
+// depth: each depth's tokens against the tokens of what it summarizes
+// (linked messages for leaves, child summaries for condensed nodes).
+type conversationReportStats struct {
+	Messages         int                       `json:"messages"`
+	MessageTokens    int                       `json:"message_tokens"`
+	Summaries        int                       `json:"summaries"`
+	SummaryTokens    int                       `json:"summary_tokens"`
+	Depths           []conversationReportDepth `json:"depths"`
+	ContextItems     int                       `json:"context_items"`
+	ContextSummaries int                       `json:"context_summaries"`
+	ContextMessages  int                       `json:"context_messages"`
+	ContextTokens    int                       `json:"context_tokens"`
+	// ContextRatio is context tokens over message tokens: how much of the
+	// raw conversation the assembled context costs.
+	ContextRatio float64 `json:"context_ratio"`
+}
+
+type conversationReportDepth struct {
+	Depth            int     `json:"depth"`
+	Summaries        int     `json:"summaries"`
+	Tokens           int     `json:"tokens"`
+	InContext        int     `json:"in_context"`
+	SourceTokens     int     `json:"source_tokens"`
+	CompressionRatio float64 `json:"compression_ratio"`
+}
+
+type conversationReportHist struct {
+	Buckets []string                       `json:"buckets"`
+	Depths  []conversationReportHistDepth  `json:"depths"`
+	Largest []conversationReportSummaryRef `json:"largest"`
+	// lines is the rendered histogram for text output.
+	lines []string
+}
+
+type conversationReportHistDepth struct {
+	Depth       int   `json:"depth"`
+	Summaries   int   `json:"summaries"`
+	TotalTokens int   `json:"total_tokens"`
+	Counts      []int `json:"counts"`
+}
+
+type conversationReportSummaryRef struct {
+	SummaryID string `json:"summary_id"`
+	Kind      string `json:"kind"`
+	Depth     int    `json:"depth"`
+	Tokens    int    `json:"tokens"`
+}
+
+// conversationReportVerify runs the integrity checks of check-context,
+// recount, relink, prune --orphans-only, and doctor without fixing anything.
+type conversationReportVerify struct {
+	Issues int                       `json:"issues"`
+	Checks []conversationReportCheck `json:"checks"`
+}
+
+type conversationReportCheck struct {
+	Name   string   `json:"name"`
+	Issues []string `json:"issues,omitempty"`
+	// Fix is the command that previews a repair, when there is one.
+	Fix string `json:"fix,omitempty"`
+}
+
+// conversationReportOverview is the context window from the top: each
+// context summary, and each run of raw messages collapsed into one entry.
+type conversationReportOverview struct {
+	Items []conversationReportOverviewItem `json:"items"`
+}
+
+type conversationReportOverviewItem struct {
+	FirstOrdinal int64  `json:"first_ordinal"`
+	LastOrdinal  int64  `json:"last_ordinal"`
+	SummaryID    string `json:"summary_id,omitempty"`
+	Kind         string `json:"kind,omitempty"`
+	Depth        int    `json:"depth,omitempty"`
+	Messages     int    `json:"messages,omitempty"`
+	Tokens       int    `json:"tokens"`
+	Preview      string `json:"preview,omitempty"`
+}
+
+// runReportCommand executes the standalone report CLI path.
+func runReportCommand(args []string) error {
+	opts, conversationID, err := parseReportArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := buildConversationReport(context.Background(), db, conversationID, opts)
+	if err != nil {
+		return err
+	}
+	out := newCLIOutput(opts.clipboard)
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(out.w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("encode conversation report: %w", err)
+		}
+		return out.flush()
+	}
+	printConversationReport(out.w, report, out.style())
+	return out.flush()
+}
+
+func buildConversationReport(ctx context.Context, db *sql.DB, conversationID int64, opts reportOptions) (conversationReport, error) {
+	report := conversationReport{
+		ConversationID: conversationID,
+		GeneratedAt:    time.Now().UTC().Format(time.RFC3339),
+		ToolVersion:    version,
+	}
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(session_id, '') FROM conversations WHERE conversation_id = ?
+	`, conversationID).Scan(&report.SessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return conversationReport{}, fmt.Errorf("conversation %d not found", conversationID)
+	}
+	if err != nil {
+		return conversationReport{}, fmt.Errorf("query conversation %d: %w", conversationID, err)
+	}
+	if caps, err := lcm.SchemaCapabilitiesOf(ctx, db); err == nil && caps.HasColumn("conversations", "title") {
+		if report.Title, err = loadConversationTitle(ctx, db, conversationID); err != nil {
+			return conversationReport{}, err
+		}
+	}
+
+	items, err := lcm.LoadContextItems(ctx, db, conversationID)
+	if err != nil {
+		return conversationReport{}, err
+	}
+	if !opts.skipStats {
+		if report.Stats, err = buildConversationReportStats(ctx, db, conversationID, items); err != nil {
+			return conversationReport{}, err
+		}
+	}
+	if !opts.skipHistogram {
+		nodes, err := loadSummaryNodes(db, conversationID)
+		if err != nil {
+			return conversationReport{}, err
+		}
+		report.Histogram = buildConversationReportHist(nodes, opts.top)
+	}
+	if !opts.skipVerify {
+		if report.Verify, err = buildConversationReportVerify(ctx, db, conversationID, items); err != nil {
+			return conversationReport{}, err
+		}
+	}
+	if !opts.skipOverview {
+		if report.Overview, err = buildConversationReportOverview(ctx, db, items); err != nil {
+			return conversationReport{}, err
+		}
+	}
+	return report, nil
+}
+
+func buildConversationReportStats(ctx context.Context, q sqlQueryer, conversationID int64, items []lcm.ContextItem) (*conversationReportStats, error) {
+	stats := &conversationReportStats{Depths: []conversationReportDepth{}}
+	if err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(token_count), 0) FROM messages WHERE conversation_id = ?
+	`, conversationID).Scan(&stats.Messages, &stats.MessageTokens); err != nil {
+		return nil, fmt.Errorf("count messages for conversation %d: %w", conversationID, err)
+	}
+
+	byDepth := make(map[int]*conversationReportDepth)
+	depthStats := func(depth int) *conversationReportDepth {
+		if byDepth[depth] == nil {
+			byDepth[depth] = &conversationReportDepth{Depth: depth}
+		}
+		return byDepth[depth]
+	}
+	queries := []struct {
+		what  string
+		query string
+		scan  func(d *conversationReportDepth, count, tokens int)
+	}{
+		{"summaries", `
+			SELECT COALESCE(depth, 0), COUNT(*), COALESCE(SUM(token_count), 0)
+			FROM summaries WHERE conversation_id = ?
+			GROUP BY COALESCE(depth, 0)
+		`, func(d *conversationReportDepth, count, tokens int) { d.Summaries, d.Tokens = count, tokens }},
+		{"leaf source tokens", `
+			SELECT 0, COUNT(*), COALESCE(SUM(m.token_count), 0)
+			FROM summary_messages sm
+			JOIN summaries s ON s.summary_id = sm.summary_id
+			JOIN messages m ON m.message_id = sm.message_id
+			WHERE s.conversation_id = ? AND COALESCE(s.depth, 0) = 0
+		`, func(d *conversationReportDepth, _, tokens int) { d.SourceTokens = tokens }},
+		{"condensed source tokens", `
+			SELECT COALESCE(s.depth, 0), COUNT(*), COALESCE(SUM(p.token_count), 0)
+			FROM summary_parents sp
+			JOIN summaries s ON s.summary_id = sp.summary_id
+			JOIN summaries p ON p.summary_id = sp.parent_summary_id
+			WHERE s.conversation_id = ? AND COALESCE(s.depth, 0) > 0
+			GROUP BY COALESCE(s.depth, 0)
+		`, func(d *conversationReportDepth, _, tokens int) { d.SourceTokens = tokens }},
+	}
+	for _, query := range queries {
+		rows, err := q.QueryContext(ctx, query.query, conversationID)
+		if err != nil {
+			return nil, fmt.Errorf("query %s for conversation %d: %w", query.what, conversationID, err)
+		}
+		for rows.Next() {
+			var depth, count, tokens int
+			if err := rows.Scan(&depth, &count, &tokens); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan %s: %w", query.what, err)
+			}
+			if count > 0 {
+				query.scan(depthStats(depth), count, tokens)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("iterate %s: %w", query.what, err)
+		}
+		rows.Close()
+	}
+
+	stats.ContextItems = len(items)
+	for _, item := range items {
+		stats.ContextTokens += item.TokenCount
+		if lcm.IsContextSummary(item) {
+			stats.ContextSummaries++
+			depthStats(item.Depth).InContext++
+		} else {
+			stats.ContextMessages++
+		}
+	}
+	for _, d := range byDepth {
+		stats.Summaries += d.Summaries
+		stats.SummaryTokens += d.Tokens
+		d.CompressionRatio = compressionRatio(d.SourceTokens, d.Tokens)
+		stats.Depths = append(stats.Depths, *d)
+	}
+	sort.Slice(stats.Depths, func(i, j int) bool { return stats.Depths[i].Depth < stats.Depths[j].Depth })
+	stats.ContextRatio = compressionRatio(stats.MessageTokens, stats.ContextTokens)
+	return stats, nil
+}
+
+func buildConversationReportHist(nodes map[string]*summaryNode, top int) *conversationReportHist {
+	hist := buildSummaryTokenHistogram(nodes, top)
+	report := &conversationReportHist{
+		Depths:  []conversationReportHistDepth{},
+		Largest: []conversationReportSummaryRef{},
+	}
+	for idx := 0; idx <= len(summaryHistogramBounds); idx++ {
+		report.Buckets = append(report.Buckets, summaryHistogramBucketLabel(idx))
+	}
+	for _, depth := range hist.depths {
+		report.Depths = append(report.Depths, conversationReportHistDepth{
+			Depth: depth.depth, Summaries: depth.summaries, TotalTokens: depth.totalTokens, Counts: depth.counts,
+		})
+	}
+	for _, node := range hist.largest {
+		report.Largest = append(report.Largest, conversationReportSummaryRef{SummaryID: node.id, Kind: node.kind, Depth: node.depth, Tokens: node.tokenCount})
+	}
+	report.lines = renderSummaryTokenHistogram(hist, defaultCLIWrapWidth)
+	return report
+}
+
+func buildConversationReportVerify(ctx context.Context, db *sql.DB, conversationID int64, items []lcm.ContextItem) (*conversationReportVerify, error) {
+	verify := &conversationReportVerify{}
+	add := func(name, fix string, issues []string) {
+		check := conversationReportCheck{Name: name, Issues: issues}
+		if len(issues) > 0 {
+			check.Fix = fix
+		}
+		verify.Issues += len(issues)
+		verify.Checks = append(verify.Checks, check)
+	}
+	checkContext := fmt.Sprintf("lcm-tui check-context %d", conversationID)
+
+	foreign, err := findForeignContextItems(ctx, db, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	var issues []string
+	for _, item := range foreign {
+		issues = append(issues, item.describe())
+	}
+	add("foreign context items", checkContext+" --fix", issues)
+
+	issues = nil
+	if layout := lcm.AnalyzeContextLayout(items); layout.Interleaved() {
+		issues = append(issues, fmt.Sprintf("%d of %d items are out of canonical order", layout.Moved, layout.Items))
+	}
+	add("context layout", checkContext+" --reorder", issues)
+
+	parentIssues, err := findParentOrdinalIssues(ctx, db, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	issues = nil
+	for _, issue := range parentIssues {
+		issues = append(issues, issue.describe())
+	}
+	add("summary_parents ordinals", checkContext+" --fix", issues)
+
+	kindMismatches, err := findSummaryKindMismatches(ctx, db, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	issues = nil
+	for _, mismatch := range kindMismatches {
+		issues = append(issues, mismatch.describe())
+	}
+	add("summary kinds", checkContext+" --fix", issues)
+
+	zeroTokens, err := findZeroTokenSummaries(ctx, db, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	issues = nil
+	for _, s := range zeroTokens {
+		issues = append(issues, fmt.Sprintf("%s (%s, d%d): token_count 0, estimate %d", s.summaryID, s.kind, s.depth, s.estimated))
+	}
+	add("zero token counts", fmt.Sprintf("lcm-tui recount %d", conversationID), issues)
+
+	relink, err := buildRelinkPlan(ctx, db, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	issues = nil
+	for _, r := range relink.results {
+		if r.messages != nil {
+			issues = append(issues, fmt.Sprintf("%s: no summary_messages rows (relinkable, %s)", r.summaryID, r.basis))
+			continue
+		}
+		issues = append(issues, fmt.Sprintf("%s: no summary_messages rows (%s)", r.summaryID, r.reason))
+	}
+	add("unlinked leaves", fmt.Sprintf("lcm-tui relink %d", conversationID), issues)
+
+	orphans, err := buildOrphanPrunePlan(ctx, db, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	issues = nil
+	for _, s := range orphans.prunable {
+		issues = append(issues, fmt.Sprintf("%s (%s, d%d, %dt) is referenced by nothing", s.summaryID, s.kind, s.depth, s.tokenCount))
+	}
+	add("orphaned summaries", fmt.Sprintf("lcm-tui prune %d --orphans-only", conversationID), issues)
+
+	broken, err := loadDoctorTargets(ctx, db, &conversationID, lcm.TokenRange{})
+	if err != nil {
+		return nil, err
+	}
+	issues = nil
+	for _, target := range broken {
+		issues = append(issues, fmt.Sprintf("%s (%s, d%d): %s fallback marker", target.summaryID, target.kind, target.depth, target.markerKind))
+	}
+	add("broken summaries", fmt.Sprintf("lcm-tui doctor %d", conversationID), issues)
+	return verify, nil
+}
+
+func buildConversationReportOverview(ctx context.Context, q sqlQueryer, items []lcm.ContextItem) (*conversationReportOverview, error) {
+	overview := &conversationReportOverview{Items: []conversationReportOverviewItem{}}
+	var run *conversationReportOverviewItem
+	for _, item := range items {
+		if !lcm.IsContextSummary(item) {
+			if run == nil {
+				overview.Items = append(overview.Items, conversationReportOverviewItem{FirstOrdinal: item.Ordinal})
+				run = &overview.Items[len(overview.Items)-1]
+			}
+			run.LastOrdinal = item.Ordinal
+			run.Messages++
+			run.Tokens += item.TokenCount
+			continue
+		}
+		run = nil
+		entry := conversationReportOverviewItem{
+			FirstOrdinal: item.Ordinal, LastOrdinal: item.Ordinal,
+			SummaryID: item.SummaryID.String, Depth: item.Depth, Tokens: item.TokenCount,
+		}
+		var content string
+		if err := q.QueryRowContext(ctx, `
+			SELECT COALESCE(kind, ''), content FROM summaries WHERE summary_id = ?
+		`, entry.SummaryID).Scan(&entry.Kind, &content); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("query summary %s for overview: %w", entry.SummaryID, err)
+		}
+		entry.Preview = previewForLog(sanitizeForTerminal(oneLine(content)), 80)
+		overview.Items = append(overview.Items, entry)
+	}
+	return overview, nil
+}
+
+func printConversationReport(w io.Writer, report conversationReport, style cliOutputStyle) {
+	fmt.Fprintf(w, "Conversation %d report\n", report.ConversationID)
+	fmt.Fprintf(w, "Session: %s\n", report.SessionID)
+	if report.Title != "" {
+		fmt.Fprintf(w, "Title: %s\n", sanitizeForTerminal(report.Title))
+	}
+	fmt.Fprintf(w, "Generated: %s by lcm-tui %s\n", report.GeneratedAt, report.ToolVersion)
+
+	if stats := report.Stats; stats != nil {
+		fmt.Fprintln(w, "\n== Stats ==")
+		fmt.Fprintf(w, "Messages: %d (%dt)\n", stats.Messages, stats.MessageTokens)
+		fmt.Fprintf(w, "Summaries: %d (%dt)\n", stats.Summaries, stats.SummaryTokens)
+		fmt.Fprintf(w, "Context: %d items (%d summaries, %d messages), %dt = %.2f of message tokens\n",
+			stats.ContextItems, stats.ContextSummaries, stats.ContextMessages, stats.ContextTokens, stats.ContextRatio)
+		if len(stats.Depths) > 0 {
+			table := cliTable{indent: "  ", columns: []cliTableColumn{
+				{header: "depth"},
+				{header: "summaries", align: cliAlignRight},
+				{header: "in context", align: cliAlignRight},
+				{header: "tokens", align: cliAlignRight},
+				{header: "source", align: cliAlignRight},
+				{header: "ratio", align: cliAlignRight},
+			}}
+			for _, d := range stats.Depths {
+				table.addRow(fmt.Sprintf("d%d", d.Depth), strconv.Itoa(d.Summaries), strconv.Itoa(d.InContext),
+					strconv.Itoa(d.Tokens), strconv.Itoa(d.SourceTokens), fmt.Sprintf("%.2f", d.CompressionRatio))
+			}
+			for _, line := range table.render(style) {
+				fmt.Fprintln(w, line)
+			}
+		}
+	}
+
+	if report.Histogram != nil {
+		fmt.Fprintln(w, "\n== Token histogram ==")
+		for _, line := range report.Histogram.lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if verify := report.Verify; verify != nil {
+		fmt.Fprintf(w, "\n== Verify (%d issues) ==\n", verify.Issues)
+		for _, check := range verify.Checks {
+			if len(check.Issues) == 0 {
+				fmt.Fprintf(w, "OK    %s\n", check.Name)
+				continue
+			}
+			fmt.Fprintf(w, "FAIL  %s: %d (preview a fix with: %s)\n", check.Name, len(check.Issues), check.Fix)
+			for _, issue := range check.Issues {
+				fmt.Fprintf(w, "        %s\n", issue)
+			}
+		}
+	}
+
+	if overview := report.Overview; overview != nil {
+		fmt.Fprintln(w, "\n== Overview ==")
+		if len(overview.Items) == 0 {
+			fmt.Fprintln(w, "No context items.")
+		}
+		for _, item := range overview.Items {
+			if item.SummaryID == "" {
+				label := fmt.Sprintf("[%d-%d]", item.FirstOrdinal, item.LastOrdinal)
+				if item.FirstOrdinal == item.LastOrdinal {
+					label = fmt.Sprintf("[%d]", item.FirstOrdinal)
+				}
+				fmt.Fprintf(w, "%s %d raw messages  %dt\n", label, item.Messages, item.Tokens)
+				continue
+			}
+			fmt.Fprintf(w, "[%d] %s d%d %s  %dt  %s\n", item.FirstOrdinal, item.SummaryID, item.Depth, item.Kind, item.Tokens, item.Preview)
+		}
+	}
+}
+
+func parseReportArgs(args []string) (reportOptions, int64, error) {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOutput := fs.Bool("json", false, "print the report as JSON")
+	clipboard := fs.Bool("clipboard", false, "copy the output to the system clipboard instead of stdout")
+	top := fs.Int("top", defaultHistogramTop, "number of largest summaries to list in the histogram")
+	noStats := fs.Bool("no-stats", false, "skip the stats section")
+	noHistogram := fs.Bool("no-histogram", false, "skip the token histogram section")
+	noVerify := fs.Bool("no-verify", false, "skip the verify section")
+	noOverview := fs.Bool("no-overview", false, "skip the overview section")
+
+	normalizedArgs, err := normalizeHistogramArgs(args)
+	if err != nil {
+		return reportOptions{}, 0, fmt.Errorf("%w\n%s", err, reportUsageText())
+	}
+	if err := fs.Parse(normalizedArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return reportOptions{}, 0, errors.New(reportUsageText())
+		}
+		return reportOptions{}, 0, fmt.Errorf("%w\n%s", err, reportUsageText())
+	}
+	if fs.NArg() != 1 {
+		return reportOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", reportUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return reportOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), reportUsageText())
+	}
+	if *top < 0 {
+		return reportOptions{}, 0, fmt.Errorf("--top must be >= 0\n%s", reportUsageText())
+	}
+	return reportOptions{
+		jsonOutput:    *jsonOutput,
+		clipboard:     *clipboard,
+		top:           *top,
+		skipStats:     *noStats,
+		skipHistogram: *noHistogram,
+		skipVerify:    *noVerify,
+		skipOverview:  *noOverview,
+	}, conversationID, nil
+}
+
+func reportUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui report <conversation_id> [--json] [--clipboard] [--top <n>]
+                 [--no-stats] [--no-histogram] [--no-verify] [--no-overview]
+
+Print one document describing a conversation, for a health check or a bug
+report. Read-only. Sections:
+
+  stats       message, summary, and context counts and tokens, and each
+              depth's compression against what it summarizes
+  histogram   summary token_count buckets per depth and the largest summaries
+  verify      the check-context, recount, relink, prune --orphans-only, and
+              doctor scans, each with the command that previews a fix
+  overview    the context window from the top: summaries with a preview,
+              raw message runs collapsed
+
+Flags:
+  --json          print the report as JSON
+  --clipboard     copy the output to the system clipboard instead of stdout
+  --top <n>       number of largest summaries in the histogram (default 10)
+  --no-stats      skip the stats section
+  --no-histogram  skip the histogram section
+  --no-verify     skip the verify section
+  --no-overview   skip the overview section
+`)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConversationReportCombinesSections(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'Release')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(10, 1, 0, 'user', 'first', 40, '2026-03-01T10:00:00Z'),
+			(11, 1, 1, 'assistant', 'second', 60, '2026-03-01T10:01:00Z'),
+			(12, 1, 2, 'user', 'third', 5, '2026-03-01T10:02:00Z'),
+			(13, 1, 3, 'assistant', 'fourth', 5, '2026-03-01T10:03:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_leaf', 1, 'leaf', 0, 'leaf text', 20, '2026-03-01T11:00:00Z'),
+			('sum_root', 1, 'condensed', 1, 'root text', 10, '2026-03-01T12:00:00Z'),
+			('sum_zero', 1, 'leaf', 0, 'orphaned leaf', 0, '2026-03-01T12:00:00Z')
+	`)
+	mustExec(t, db, `INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('sum_leaf', 10, 0), ('sum_leaf', 11, 1)`)
+	mustExec(t, db, `INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal) VALUES ('sum_root', 'sum_leaf', 0)`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES (1, 0, 'summary', NULL, 'sum_root'), (1, 1, 'message', 12, NULL), (1, 2, 'message', 13, NULL)
+	`)
+
+	report, err := buildConversationReport(ctx, db, 1, reportOptions{top: defaultHistogramTop})
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	stats := report.Stats
+	if stats.Messages != 4 || stats.MessageTokens != 110 || stats.Summaries != 3 || stats.ContextTokens != 20 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if len(stats.Depths) != 2 || stats.Depths[0].SourceTokens != 100 || stats.Depths[0].CompressionRatio != 0.2 ||
+		stats.Depths[1].SourceTokens != 20 || stats.Depths[1].InContext != 1 {
+		t.Fatalf("depths = %+v", stats.Depths)
+	}
+	failing := map[string]bool{}
+	for _, check := range report.Verify.Checks {
+		if len(check.Issues) > 0 {
+			failing[check.Name] = true
+		}
+	}
+	for _, name := range []string{"zero token counts", "orphaned summaries", "unlinked leaves"} {
+		if !failing[name] {
+			t.Fatalf("expected %q to fail, got %+v", name, report.Verify.Checks)
+		}
+	}
+	if failing["foreign context items"] || failing["context layout"] {
+		t.Fatalf("unexpected failures: %+v", report.Verify.Checks)
+	}
+	overview := report.Overview.Items
+	if len(overview) != 2 || overview[0].SummaryID != "sum_root" || overview[1].Messages != 2 || overview[1].LastOrdinal != 2 {
+		t.Fatalf("overview = %+v", overview)
+	}
+
+	var out bytes.Buffer
+	printConversationReport(&out, report, cliOutputStyle{})
+	for _, want := range []string{"== Stats ==", "== Token histogram ==", "FAIL  zero token counts: 1 (preview a fix with: lcm-tui recount 1)", "[1-2] 2 raw messages  10t"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("report should contain %q:\n%s", want, out.String())
+		}
+	}
+
+	skipped, err := buildConversationReport(ctx, db, 1, reportOptions{skipStats: true, skipHistogram: true, skipVerify: true})
+	if err != nil {
+		t.Fatalf("build skipped report: %v", err)
+	}
+	encoded, err := json.Marshal(skipped)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(encoded), `"stats"`) || strings.Contains(string(encoded), `"verify"`) || !strings.Contains(string(encoded), `"overview"`) {
+		t.Fatalf("skipped sections should be omitted: %s", encoded)
+	}
+	if _, err := buildConversationReport(ctx, db, 9, reportOptions{}); err == nil || !strings.Contains(err.Error(), "conversation 9 not found") {
+		t.Fatalf("expected missing conversation error, got %v", err)
+	}
+}
+
+func TestParseReportArgs(t *testing.T) {
+	opts, id, err := parseReportArgs([]string{"44", "--json", "--top", "3", "--no-overview"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if id != 44 || !opts.jsonOutput || opts.top != 3 || !opts.skipOverview || opts.skipStats {
+		t.Fatalf("opts = %+v, id = %d", opts, id)
+	}
+	if _, _, err := parseReportArgs(nil); err == nil {
+		t.Fatal("expected conversation ID to be required")
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// conversationSettingsSchema is owned by lcm-tui, not the plugin: it pins
+// compaction tuning to a conversation so backfill --recompact, merge
+// --recompact, and fresh-tail pick it up without flags. The table is created
+// on the first settings write; readers treat a missing table as no settings.
+// NULL columns fall back to agents.json and then the built-in default.
+const conversationSettingsSchema = `
+	CREATE TABLE IF NOT EXISTS conversation_settings (
+		conversation_id INTEGER PRIMARY KEY,
+		fresh_tail INTEGER,
+		leaf_chunk_tokens INTEGER,
+		leaf_target_tokens INTEGER,
+		condensed_target_tokens INTEGER,
+		leaf_fanout INTEGER,
+		condensed_fanout INTEGER,
+		hard_fanout INTEGER,
+		updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+	)
+`
+
+// conversationSetting is one conversation_settings column and the backfill
+// flag it stands in for.
+type conversationSetting struct {
+	flag   string
+	column string
+	// min is the smallest accepted value, matching backfill's validation.
+	min int
+}
+
+var conversationSettingColumns = []conversationSetting{
+	{flag: "fresh-tail", column: "fresh_tail", min: 0},
+	{flag: "leaf-chunk-tokens", column: "leaf_chunk_tokens", min: 1},
+	{flag: "leaf-target-tokens", column: "leaf_target_tokens", min: 1},
+	{flag: "condensed-target-tokens", column: "condensed_target_tokens", min: 1},
+	{flag: "leaf-fanout", column: "leaf_fanout", min: 2},
+	{flag: "condensed-fanout", column: "condensed_fanout", min: 2},
+	{flag: "hard-fanout", column: "hard_fanout", min: 2},
+}
+
+// conversationSettings maps a setting's flag name to its stored value;
+// unset settings are absent.
+type conversationSettings map[string]int
+
+// String renders the set values in column order, e.g.
+// "fresh-tail=48, leaf-fanout=6".
+func (s conversationSettings) String() string {
+	parts := make([]string, 0, len(s))
+	for _, setting := range conversationSettingColumns {
+		if value, ok := s[setting.flag]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%d", setting.flag, value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// asAgentDefaults converts the settings so applyBackfillAgentDefaults can
+// layer them over agents.json with the same flags-win rule.
+func (s conversationSettings) asAgentDefaults() agentDefaults {
+	defaults := agentDefaults{
+		LeafChunkTokens:       s["leaf-chunk-tokens"],
+		LeafTargetTokens:      s["leaf-target-tokens"],
+		CondensedTargetTokens: s["condensed-target-tokens"],
+		LeafFanout:            s["leaf-fanout"],
+		CondensedFanout:       s["condensed-fanout"],
+		HardFanout:            s["hard-fanout"],
+	}
+	if value, ok := s["fresh-tail"]; ok {
+		defaults.FreshTail = &value
+	}
+	return defaults
+}
+
+// loadConversationSettings returns conversationID's stored settings. A DB
+// that never stored any yields an empty map.
+func loadConversationSettings(ctx context.Context, q sqlQueryer, conversationID int64) (conversationSettings, error) {
+	settings := conversationSettings{}
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return settings, fmt.Errorf("check conversation_settings table: %w", err)
+	}
+	if !caps.HasTable("conversation_settings") {
+		return settings, nil
+	}
+	columns := make([]string, len(conversationSettingColumns))
+	values := make([]sql.NullInt64, len(conversationSettingColumns))
+	dest := make([]any, len(conversationSettingColumns))
+	for i, setting := range conversationSettingColumns {
+		columns[i] = setting.column
+		dest[i] = &values[i]
+	}
+	err = q.QueryRowContext(ctx, `
+		SELECT `+strings.Join(columns, ", ")+`
+		FROM conversation_settings
+		WHERE conversation_id = ?
+	`, conversationID).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query settings for conversation %d: %w", conversationID, err)
+	}
+	for i, setting := range conversationSettingColumns {
+		if values[i].Valid {
+			settings[setting.flag] = int(values[i].Int64)
+		}
+	}
+	return settings, nil
+}
+
+// saveConversationSettings stores updates for conversationID, keeping
+// settings not named in updates, and records them in the audit log; run it
+// in a transaction so the entry commits with them.
+func saveConversationSettings(ctx context.Context, q sqlQueryer, conversationID int64, updates conversationSettings) error {
+	exists, err := lcm.ConversationExists(ctx, q, conversationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("conversation %d not found", conversationID)
+	}
+	if _, err := q.ExecContext(ctx, conversationSettingsSchema); err != nil {
+		return fmt.Errorf("create conversation_settings: %w", err)
+	}
+	lcm.ForgetSchemaCapabilities()
+	if _, err := q.ExecContext(ctx, `
+		INSERT OR IGNORE INTO conversation_settings (conversation_id) VALUES (?)
+	`, conversationID); err != nil {
+		return fmt.Errorf("create settings for conversation %d: %w", conversationID, err)
+	}
+	for _, setting := range conversationSettingColumns {
+		value, ok := updates[setting.flag]
+		if !ok {
+			continue
+		}
+		if _, err := q.ExecContext(ctx, `
+			UPDATE conversation_settings
+			SET `+setting.column+` = ?, updated_at = datetime('now')
+			WHERE conversation_id = ?
+		`, value, conversationID); err != nil {
+			return fmt.Errorf("set %s for conversation %d: %w", setting.flag, conversationID, err)
+		}
+	}
+	return lcm.RecordAudit(ctx, q, lcm.AuditEntry{
+		Command: "settings", ConversationID: conversationID,
+		Detail: "set " + updates.String(),
+	})
+}
+
+// clearConversationSettings deletes conversationID's settings and reports
+// whether it had any. Cleared settings are recorded in the audit log.
+func clearConversationSettings(ctx context.Context, q sqlQueryer, conversationID int64) (bool, error) {
+	previous, err := loadConversationSettings(ctx, q, conversationID)
+	if err != nil || len(previous) == 0 {
+		return false, err
+	}
+	if _, err := q.ExecContext(ctx, `DELETE FROM conversation_settings WHERE conversation_id = ?`, conversationID); err != nil {
+		return false, fmt.Errorf("clear settings for conversation %d: %w", conversationID, err)
+	}
+	if err := lcm.RecordAudit(ctx, q, lcm.AuditEntry{
+		Command: "settings", ConversationID: conversationID,
+		Detail: "cleared " + previous.String(),
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// applyConversationSettings layers conversationID's stored settings over
+// opts for flags the user did not give, and prints what it applied.
+func applyConversationSettings(ctx context.Context, q sqlQueryer, conversationID int64, opts *backfillOptions) error {
+	settings, err := loadConversationSettings(ctx, q, conversationID)
+	if err != nil || len(settings) == 0 {
+		return err
+	}
+	applyBackfillAgentDefaults(opts, settings.asAgentDefaults())
+	fmt.Printf("Conversation settings: %s (from lcm-tui settings %d; flags override)\n", settings, conversationID)
+	return nil
+}
+
+type settingsOptions struct {
+	updates  conversationSettings
+	clear    bool
+	backupDB bool // copy lcm.db before settings are written
+}
+
+// runSettingsCommand executes the standalone settings CLI path.
+func runSettingsCommand(args []string) error {
+	opts, conversationID, err := parseSettingsArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if opts.clear || len(opts.updates) > 0 {
+		if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "settings", opts.backupDB, nil); err != nil {
+			return err
+		}
+		if err := applySettingsChange(ctx, db, conversationID, opts); err != nil {
+			return err
+		}
+	}
+
+	settings, err := loadConversationSettings(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		fmt.Printf("Conversation %d has no settings; compaction uses agents.json and built-in defaults.\n", conversationID)
+		return nil
+	}
+	fmt.Printf("Conversation %d settings: %s\n", conversationID, settings)
+	return nil
+}
+
+// applySettingsChange saves or clears conversationID's settings and their
+// audit entry in one transaction, then reports the change.
+func applySettingsChange(ctx context.Context, db *sql.DB, conversationID int64, opts settingsOptions) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin settings transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	cleared := false
+	if opts.clear {
+		cleared, err = clearConversationSettings(ctx, tx, conversationID)
+	} else {
+		err = saveConversationSettings(ctx, tx, conversationID, opts.updates)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit settings for conversation %d: %w", conversationID, err)
+	}
+	rollback = false
+	switch {
+	case cleared:
+		fmt.Printf("Cleared settings for conversation %d.\n", conversationID)
+	case !opts.clear:
+		fmt.Printf("Updated %d settings for conversation %d.\n", len(opts.updates), conversationID)
+	}
+	return nil
+}
+
+func parseSettingsArgs(args []string) (settingsOptions, int64, error) {
+	fs := flag.NewFlagSet("settings", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	clear := fs.Bool("clear", false, "delete every stored setting for the conversation")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before writing settings")
+	values := make([]*int, len(conversationSettingColumns))
+	for i, setting := range conversationSettingColumns {
+		values[i] = fs.Int(setting.flag, 0, "stored default for backfill --"+setting.flag)
+	}
+
+	normalized, err := normalizeSettingsArgs(args)
+	if err != nil {
+		return settingsOptions{}, 0, fmt.Errorf("%w\n%s", err, settingsUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return settingsOptions{}, 0, errors.New(settingsUsageText())
+		}
+		return settingsOptions{}, 0, fmt.Errorf("%w\n%s", err, settingsUsageText())
+	}
+	if fs.NArg() != 1 {
+		return settingsOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", settingsUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return settingsOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), settingsUsageText())
+	}
+
+	set := explicitFlags(fs)
+	opts := settingsOptions{updates: conversationSettings{}, clear: *clear}
+	for i, setting := range conversationSettingColumns {
+		if !set[setting.flag] {
+			continue
+		}
+		if *values[i] < setting.min {
+			return settingsOptions{}, 0, fmt.Errorf("--%s must be >= %d", setting.flag, setting.min)
+		}
+		opts.updates[setting.flag] = *values[i]
+	}
+	if opts.clear && len(opts.updates) > 0 {
+		return settingsOptions{}, 0, fmt.Errorf("--clear cannot be combined with setting values\n%s", settingsUsageText())
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return settingsOptions{}, 0, fmt.Errorf("%w\n%s", err, settingsUsageText())
+	}
+	return opts, conversationID, nil
+}
+
+func normalizeSettingsArgs(args []string) ([]string, error) {
+	valueFlags := make(map[string]bool, len(conversationSettingColumns))
+	for _, setting := range conversationSettingColumns {
+		valueFlags["--"+setting.flag] = true
+	}
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case valueFlags[arg]:
+			if i+1 >= len(args) {
+				return nil, errors.New("missing value for " + arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func settingsUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui settings <conversation_id>
+  lcm-tui settings <conversation_id> [--fresh-tail <n>] [--leaf-chunk-tokens <n>] ...
+  lcm-tui settings <conversation_id> --clear
+
+Show or pin compaction tuning for one conversation. Stored values become the
+defaults for backfill (including --recompact), merge --recompact, and
+fresh-tail on that conversation, ahead of agents.json. Flags given to those
+commands still win. Settings not named are left unchanged.
+
+Flags:
+  --fresh-tail <n>               freshest raw messages to keep out of leaf compaction
+  --leaf-chunk-tokens <n>        max source tokens per leaf chunk
+  --leaf-target-tokens <n>       target tokens per leaf summary
+  --condensed-target-tokens <n>  target tokens per condensed summary
+  --leaf-fanout <n>              min leaves per d1 condensation
+  --condensed-fanout <n>         min summaries per d2+ condensation
+  --hard-fanout <n>              min summaries per forced single-root pass
+  --clear                        delete every stored setting
+  --backup-db                    back up lcm.db before writing settings
+`)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConversationSettingsLayerUnderFlags(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	ctx := context.Background()
+
+	if settings, err := loadConversationSettings(ctx, db, 1); err != nil || len(settings) != 0 {
+		t.Fatalf("expected no settings before the table exists, got %v (%v)", settings, err)
+	}
+	if err := saveConversationSettings(ctx, db, 2, conversationSettings{"fresh-tail": 48}); err == nil {
+		t.Fatal("expected saving settings for a missing conversation to fail")
+	}
+	if err := saveConversationSettings(ctx, db, 1, conversationSettings{"fresh-tail": 48, "leaf-fanout": 6}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := saveConversationSettings(ctx, db, 1, conversationSettings{"leaf-fanout": 5}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	settings, err := loadConversationSettings(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := settings.String(); got != "fresh-tail=48, leaf-fanout=5" {
+		t.Fatalf("settings = %q", got)
+	}
+
+	opts := defaultBackfillCompactionOptions()
+	opts.explicitFlags = map[string]bool{"leaf-fanout": true}
+	opts.leafFanout = 9
+	if err := applyConversationSettings(ctx, db, 1, &opts); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if opts.freshTailCount != 48 || opts.leafFanout != 9 || opts.condensedFanout != 4 {
+		t.Fatalf("expected fresh-tail from settings and the explicit leaf fanout kept, got tail=%d leaf=%d condensed=%d",
+			opts.freshTailCount, opts.leafFanout, opts.condensedFanout)
+	}
+
+	if opts, _, err := parseSettingsArgs([]string{"1", "--fresh-tail", "0", "--hard-fanout=3"}); err != nil || opts.updates.String() != "fresh-tail=0, hard-fanout=3" {
+		t.Fatalf("parse settings: %v (%v)", opts.updates, err)
+	}
+	if _, _, err := parseSettingsArgs([]string{"1", "--leaf-fanout", "1"}); err == nil {
+		t.Fatal("expected --leaf-fanout 1 to be rejected")
+	}
+
+	if cleared, err := clearConversationSettings(ctx, db, 1); err != nil || !cleared {
+		t.Fatalf("clear: %v %v", cleared, err)
+	}
+	if settings, err := loadConversationSettings(ctx, db, 1); err != nil || len(settings) != 0 {
+		t.Fatalf("expected no settings after clear, got %v (%v)", settings, err)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'settings' AND conversation_id = 1`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'settings' AND detail = 'cleared fresh-tail=48, leaf-fanout=5'`, 1)
+}
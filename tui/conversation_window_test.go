@@ -2,7 +2,10 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -68,6 +71,65 @@ func TestConversationWindowPagingBoundaries(t *testing.T) {
 	}
 }
 
+func TestSessionFileWindowPagesWithoutLoadingWholeFile(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	b.WriteString(`{"type":"session","id":"s1"}` + "\n")
+	for i := 1; i <= 7; i++ {
+		fmt.Fprintf(&b, `{"type":"message","id":"m%d","message":{"role":"user","content":"message %d"}}`+"\n", i, i)
+		if i == 3 {
+			b.WriteString("not json\n")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "large.jsonl")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+
+	m := model{conversationWindow: conversationWindowState{windowSize: 3}}
+	if err := m.openSessionFileWindow(sessionEntry{id: "large", filename: "large.jsonl", path: path}, "Loaded"); err != nil {
+		t.Fatalf("open file window: %v", err)
+	}
+	assertSessionMessageTexts(t, m.messages, "message 5", "message 6", "message 7")
+	if !strings.Contains(m.status, "window:5..7") || !strings.Contains(m.status, "1 parse errors") {
+		t.Fatalf("unexpected status %q", m.status)
+	}
+
+	for _, want := range [][]string{{"message 2", "message 3", "message 4"}, {"message 1", "message 2", "message 3"}} {
+		if err := m.loadOlderConversationWindow(); err != nil {
+			t.Fatalf("load older: %v", err)
+		}
+		assertSessionMessageTexts(t, m.messages, want...)
+	}
+	if err := m.loadOlderConversationWindow(); err != nil || m.status != "No older messages available" {
+		t.Fatalf("expected oldest boundary, got %q (%v)", m.status, err)
+	}
+
+	if err := m.loadNewerConversationWindow(); err != nil {
+		t.Fatalf("load newer: %v", err)
+	}
+	assertSessionMessageTexts(t, m.messages, "message 4", "message 5", "message 6")
+	if err := m.loadNewerConversationWindow(); err != nil {
+		t.Fatalf("load newer: %v", err)
+	}
+	assertSessionMessageTexts(t, m.messages, "message 7")
+	if err := m.loadNewerConversationWindow(); err != nil || m.status != "No newer messages available" {
+		t.Fatalf("expected newest boundary, got %q (%v)", m.status, err)
+	}
+}
+
+func assertSessionMessageTexts(t *testing.T, messages []sessionMessage, expected ...string) {
+	t.Helper()
+	got := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		got = append(got, msg.text)
+	}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Fatalf("unexpected window %q, want %q", got, expected)
+	}
+}
+
 func setupConversationWindowTestDB(t *testing.T) string {
 	t.Helper()
 
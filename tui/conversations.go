@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type conversationsOptions struct {
+	sessionID string
+	clipboard bool
+}
+
+// sessionConversation is one LCM conversation recorded for a session. A
+// session gains a new conversation on every reset.
+type sessionConversation struct {
+	conversationID int64
+	sessionKey     string
+	title          string
+	createdAt      string
+	updatedAt      string
+	messageCount   int
+	summaryCount   int
+	contextItems   int
+	tags           []string
+}
+
+// loadSessionConversations lists every conversation matching sessionID with
+// the same session_id/session_key rules the session browser uses, newest
+// first. The first entry is the one the TUI opens.
+func loadSessionConversations(db *sql.DB, sessionID string) ([]sessionConversation, error) {
+	normalizedSessionID, exactSessionKey := normalizeSessionLookupID(sessionID)
+	where := "session_id = ?"
+	args := []any{normalizedSessionID}
+	if exactSessionKey != "" {
+		where = "session_key = ? OR session_id = ?"
+		args = []any{exactSessionKey, normalizedSessionID}
+	}
+
+	// Older test and fixture schemas predate conversations.title.
+	titleColumn := "''"
+	if caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db); err == nil && caps.HasColumn("conversations", "title") {
+		titleColumn = "COALESCE(c.title, '')"
+	}
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT
+			c.conversation_id,
+			COALESCE(c.session_key, ''),
+			%s,
+			COALESCE(c.created_at, ''),
+			COALESCE(c.updated_at, ''),
+			(SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.conversation_id),
+			(SELECT COUNT(*) FROM summaries s WHERE s.conversation_id = c.conversation_id),
+			(SELECT COUNT(*) FROM context_items ci WHERE ci.conversation_id = c.conversation_id)
+		FROM conversations c
+		WHERE %s
+		ORDER BY c.conversation_id DESC
+	`, titleColumn, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query conversations for session %q: %w", sessionID, err)
+	}
+	defer rows.Close()
+
+	var conversations []sessionConversation
+	for rows.Next() {
+		var conv sessionConversation
+		if err := rows.Scan(&conv.conversationID, &conv.sessionKey, &conv.title, &conv.createdAt, &conv.updatedAt,
+			&conv.messageCount, &conv.summaryCount, &conv.contextItems); err != nil {
+			return nil, fmt.Errorf("scan session conversation: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate session conversations: %w", err)
+	}
+
+	conversationIDs := make([]int64, len(conversations))
+	for i, conv := range conversations {
+		conversationIDs[i] = conv.conversationID
+	}
+	tags, err := loadConversationTags(context.Background(), db, conversationIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conversations {
+		conversations[i].tags = tags[conversations[i].conversationID]
+	}
+	return conversations, nil
+}
+
+func printSessionConversations(w io.Writer, style cliOutputStyle, sessionID string, conversations []sessionConversation) {
+	if len(conversations) == 0 {
+		fmt.Fprintf(w, "No LCM conversations found for session %q.\n", sessionID)
+		return
+	}
+	fmt.Fprintf(w, "Session %s: %d conversations (newest first)\n\n", sessionID, len(conversations))
+	table := cliTable{
+		indent: "  ",
+		columns: []cliTableColumn{
+			{header: " "},
+			{header: "conv_id", align: cliAlignRight},
+			{header: "created"},
+			{header: "updated"},
+			{header: "msgs", align: cliAlignRight},
+			{header: "sums", align: cliAlignRight},
+			{header: "ctx", align: cliAlignRight},
+			{header: "title", flex: true},
+		},
+	}
+	for i, conv := range conversations {
+		marker := ""
+		if i == 0 {
+			marker = "*"
+		}
+		title := conv.title
+		if conv.sessionKey != "" {
+			title = strings.TrimSpace(title + "  key:" + conv.sessionKey)
+		}
+		if len(conv.tags) > 0 {
+			title = strings.TrimSpace(title + "  " + formatConversationTags(conv.tags))
+		}
+		table.addRow(marker, strconv.FormatInt(conv.conversationID, 10), conv.createdAt, conv.updatedAt,
+			strconv.Itoa(conv.messageCount), strconv.Itoa(conv.summaryCount), strconv.Itoa(conv.contextItems), sanitizeForTerminal(title))
+	}
+	for _, line := range table.render(style) {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "\n* is the conversation the TUI opens for this session.")
+	if len(conversations) > 1 {
+		fmt.Fprintln(w, "Pass an older conv_id to conversation-scoped commands (repair, rewrite, transplant, merge) to operate on it,")
+		fmt.Fprintln(w, "or --session <id> --conversation-index <n> (1 = newest) to repair, rewrite, or dissolve.")
+	}
+}
+
+// runConversationsCommand executes the standalone conversations CLI path.
+func runConversationsCommand(args []string) error {
+	opts, err := parseConversationsArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conversations, err := loadSessionConversations(db, opts.sessionID)
+	if err != nil {
+		return err
+	}
+	out := newCLIOutput(opts.clipboard)
+	printSessionConversations(out.w, out.style(), opts.sessionID, conversations)
+	return out.flush()
+}
+
+func parseConversationsArgs(args []string) (conversationsOptions, error) {
+	fs := flag.NewFlagSet("conversations", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	sessionID := fs.String("session", "", "session ID (or topic session filename) to list conversations for")
+	clipboard := fs.Bool("clipboard", false, "copy the output to the system clipboard instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return conversationsOptions{}, errors.New(conversationsUsageText())
+		}
+		return conversationsOptions{}, fmt.Errorf("%w\n%s", err, conversationsUsageText())
+	}
+	if fs.NArg() != 0 {
+		return conversationsOptions{}, fmt.Errorf("unexpected argument %q\n%s", fs.Arg(0), conversationsUsageText())
+	}
+	if strings.TrimSpace(*sessionID) == "" {
+		return conversationsOptions{}, fmt.Errorf("--session is required\n%s", conversationsUsageText())
+	}
+	return conversationsOptions{sessionID: strings.TrimSpace(*sessionID), clipboard: *clipboard}, nil
+}
+
+func conversationsUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui conversations --session <session_id> [--clipboard]
+
+Lists every LCM conversation recorded for a session, newest first, with
+created/updated times and message, summary, and context item counts. Each
+reset starts a new conversation; the TUI opens the newest one. Read-only.
+
+Flags:
+  --session <id>   session ID (a <session>-topic-<n> filename also matches its session_key)
+  --clipboard      copy the output to the system clipboard instead of stdout
+`)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSessionConversationsListsResetsNewestFirst(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `ALTER TABLE conversations ADD COLUMN session_key TEXT`)
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title, created_at, updated_at)
+		VALUES
+			(3, 'session-reset', 'Before reset', '2026-03-01 09:00:00', '2026-03-05 18:00:00'),
+			(8, 'session-reset', 'After reset', '2026-03-06 09:00:00', '2026-03-06 12:00:00'),
+			(9, 'session-other', 'Other', '2026-03-06 10:00:00', '2026-03-06 10:00:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 3, 1, 'user', 'old', 1, '2026-03-01 09:00:00'),
+			(2, 3, 2, 'assistant', 'old reply', 1, '2026-03-01 09:01:00'),
+			(3, 8, 1, 'user', 'new', 1, '2026-03-06 09:00:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_old', 3, 'leaf', 0, 'old work', 2, '2026-03-05 18:00:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+		VALUES (3, 0, 'summary', 'sum_old')
+	`)
+
+	conversations, err := loadSessionConversations(db, "session-reset")
+	if err != nil {
+		t.Fatalf("load session conversations: %v", err)
+	}
+	if len(conversations) != 2 {
+		t.Fatalf("got %d conversations, want 2", len(conversations))
+	}
+	latest, older := conversations[0], conversations[1]
+	if latest.conversationID != 8 || latest.messageCount != 1 || latest.summaryCount != 0 {
+		t.Fatalf("latest = %+v, want conversation 8 with 1 message", latest)
+	}
+	if older.conversationID != 3 || older.messageCount != 2 || older.summaryCount != 1 || older.contextItems != 1 || older.title != "Before reset" {
+		t.Fatalf("older = %+v, want conversation 3 with 2 messages and 1 summary", older)
+	}
+
+	metadata := loadConversationMetadataFromDB(db, []string{"session-reset", "session-other"})
+	if got := metadata["session-reset"]; got.conversationID != 8 || got.conversationCount != 2 {
+		t.Fatalf("session-reset metadata = %+v, want latest 8 of 2", got)
+	}
+	if got := metadata["session-other"].conversationCount; got != 1 {
+		t.Fatalf("session-other conversation count = %d, want 1", got)
+	}
+}
+
+func TestConversationSessionResolvesSessionIDs(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `ALTER TABLE conversations ADD COLUMN session_key TEXT`)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, created_at)
+		VALUES
+			(3, 'session-reset', '2026-03-01 09:00:00'),
+			(8, 'session-reset', '2026-03-06 09:00:00'),
+			(9, 'session-single', '2026-03-06 10:00:00')
+	`)
+
+	single := conversationSession{sessionID: "session-single"}
+	if id, err := single.resolve(db, 0); err != nil || id != 9 {
+		t.Fatalf("single-conversation session resolved to %d (%v), want 9", id, err)
+	}
+	if id, err := (conversationSession{}).resolve(db, 42); err != nil || id != 42 {
+		t.Fatalf("without --session the positional ID should pass through, got %d (%v)", id, err)
+	}
+
+	_, err := conversationSession{sessionID: "session-reset"}.resolve(db, 0)
+	if err == nil || !strings.Contains(err.Error(), "--conversation-index") || !strings.Contains(err.Error(), "1. conversation 8") || !strings.Contains(err.Error(), "2. conversation 3") {
+		t.Fatalf("ambiguous session should list its conversations newest first, got %v", err)
+	}
+	if id, err := (conversationSession{sessionID: "session-reset", index: 2}).resolve(db, 0); err != nil || id != 3 {
+		t.Fatalf("--conversation-index 2 resolved to %d (%v), want the older conversation 3", id, err)
+	}
+	if _, err := (conversationSession{sessionID: "session-reset", index: 3}).resolve(db, 0); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatalf("expected an out-of-range error, got %v", err)
+	}
+	if _, err := (conversationSession{sessionID: "missing"}).resolve(db, 0); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+}
+
+func TestSessionFlagReplacesConversationIDArgument(t *testing.T) {
+	opts, conversationID, err := parseDissolveArgs([]string{"--session", "abc-123", "--conversation-index", "2", "--summary-id", "sum_x"})
+	if err != nil || conversationID != 0 || opts.session.sessionID != "abc-123" || opts.session.index != 2 {
+		t.Fatalf("dissolve --session parsed to %+v, %d (%v)", opts.session, conversationID, err)
+	}
+	if _, conversationID, err := parseDissolveArgs([]string{"44", "--summary-id", "sum_x"}); err != nil || conversationID != 44 {
+		t.Fatalf("positional conversation ID should still work, got %d (%v)", conversationID, err)
+	}
+	if _, _, err := parseDissolveArgs([]string{"44", "--session", "abc-123", "--summary-id", "sum_x"}); err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Fatalf("expected an error for both a conversation ID and --session, got %v", err)
+	}
+	if _, _, err := parseRewriteArgs([]string{"44", "--all", "--conversation-index", "1"}); err == nil || !strings.Contains(err.Error(), "requires --session") {
+		t.Fatalf("expected --conversation-index to require --session, got %v", err)
+	}
+	if _, _, err := parseRepairArgs([]string{"--all", "--session", "abc-123"}); err == nil || !strings.Contains(err.Error(), "--all") {
+		t.Fatalf("expected --session to be refused with --all, got %v", err)
+	}
+	repairOpts, conversationID, err := parseRepairArgs([]string{"--session=abc-123"})
+	if err != nil || conversationID != 0 || !repairOpts.session.set() {
+		t.Fatalf("repair --session= parsed to %+v, %d (%v)", repairOpts.session, conversationID, err)
+	}
+}
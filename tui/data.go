@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
@@ -15,12 +16,15 @@ import (
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 // appDataPaths stores resolved locations for session files and the LCM DB.
 type appDataPaths struct {
 	agentsDir        string
 	lcmDBPath        string
+	lcmDBSource      string // "--db" or "LCM_DATABASE_PATH" when overridden
 	openclawDir      string
 	openclawConfig   string
 	openclawEnv      string
@@ -37,6 +41,7 @@ type agentEntry struct {
 type sessionEntry struct {
 	id                   string
 	sessionKey           string
+	title                string
 	filename             string
 	path                 string
 	updatedAt            time.Time
@@ -49,6 +54,8 @@ type sessionEntry struct {
 	codexEstimatedTokens int
 	summaryCount         int
 	fileCount            int
+	conversationCount    int // LCM conversations sharing this session; >1 after resets
+	tags                 []string
 }
 
 // sessionFileEntry stores lightweight metadata used for incremental loading.
@@ -85,8 +92,10 @@ type summaryNode struct {
 	depth      int
 	content    string
 	createdAt  string
+	createdSeq int64 // summaries.created_seq: insertion order among same-second created_at
 	tokenCount int
 	children   []string
+	parents    []string // condensed summaries built from this one; >1 means shared
 	expanded   bool
 }
 
@@ -175,10 +184,13 @@ type summaryGraph struct {
 	nodes          map[string]*summaryNode
 }
 
-// summaryRow is one visible row in the flattened summary tree.
+// summaryRow is one visible row in the flattened summary tree. A shared
+// summary is listed under every parent, but only its first row expands;
+// later rows set repeat.
 type summaryRow struct {
 	summaryID string
 	depth     int
+	repeat    bool
 }
 
 // contentBlock supports the JSONL message content block format.
@@ -189,6 +201,71 @@ type contentBlock struct {
 	Arguments json.RawMessage `json:"arguments"`
 	Reasoning string          `json:"reasoning"`
 	Content   json.RawMessage `json:"content"`
+
+	// Structured fields of patch, subtask, step-finish, and snapshot blocks.
+	// They stay raw until structuredPart reads the ones the block's type
+	// uses, so a field with an unexpected shape cannot fail the message.
+	Files       json.RawMessage `json:"files"`
+	Hash        json.RawMessage `json:"hash"`
+	Prompt      json.RawMessage `json:"prompt"`
+	Description json.RawMessage `json:"description"`
+	Agent       json.RawMessage `json:"agent"`
+	Reason      json.RawMessage `json:"reason"`
+	Cost        json.RawMessage `json:"cost"`
+	Tokens      json.RawMessage `json:"tokens"`
+	Snapshot    json.RawMessage `json:"snapshot"`
+}
+
+// structuredPart decodes the structured fields the block's type uses. A field
+// that does not decode is left empty rather than failing the block.
+func (b contentBlock) structuredPart() lcm.StructuredPart {
+	part := lcm.StructuredPart{Kind: b.Type}
+	switch b.Type {
+	case "patch":
+		part.PatchFiles = blockFiles(b.Files)
+		part.PatchHash = blockString(b.Hash)
+	case "subtask":
+		part.SubtaskPrompt = blockString(b.Prompt)
+		part.SubtaskDesc = blockString(b.Description)
+		part.SubtaskAgent = blockString(b.Agent)
+	case "step-finish", "step_finish":
+		part.StepReason = blockString(b.Reason)
+		json.Unmarshal(b.Cost, &part.StepCost)
+		var tokens struct {
+			Input  json.RawMessage `json:"input"`
+			Output json.RawMessage `json:"output"`
+		}
+		if json.Unmarshal(b.Tokens, &tokens) == nil {
+			json.Unmarshal(tokens.Input, &part.StepTokensIn)
+			json.Unmarshal(tokens.Output, &part.StepTokensOut)
+		}
+	case "snapshot":
+		part.SnapshotHash = blockString(b.Snapshot)
+	}
+	return part
+}
+
+// blockString decodes a raw string field; "" when it is missing or not a
+// string.
+func blockString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return ""
+	}
+	return s
+}
+
+// blockFiles decodes a patch block's file list, accepting a single path
+// string as well as an array.
+func blockFiles(raw json.RawMessage) []string {
+	var files []string
+	if json.Unmarshal(raw, &files) == nil {
+		return files
+	}
+	if file := blockString(raw); file != "" {
+		return []string{file}
+	}
+	return nil
 }
 
 // sessionLine is the top-level JSON object in each JSONL row.
@@ -224,9 +301,11 @@ func resolveDataPaths() (appDataPaths, error) {
 	if err != nil {
 		return appDataPaths{}, err
 	}
+	dbPath, dbSource := resolveLCMDBPath(base)
 	return appDataPaths{
 		agentsDir:        filepath.Join(base, "agents"),
-		lcmDBPath:        filepath.Join(base, "lcm.db"),
+		lcmDBPath:        dbPath,
+		lcmDBSource:      dbSource,
 		openclawDir:      base,
 		openclawConfig:   filepath.Join(base, "openclaw.json"),
 		openclawEnv:      filepath.Join(base, ".env"),
@@ -396,12 +475,16 @@ func loadSessionBatch(files []sessionFileEntry, offset, limit int, lcmDBPath str
 	}
 	summaryCounts := loadSummaryCounts(lcmDBPath, conversationIDs)
 	fileCounts := loadFileCounts(lcmDBPath, conversationIDs)
+	tags := loadSessionTags(lcmDBPath, conversationIDs)
 	for i := range sessions {
 		metadata := conversationMetadata[sessions[i].id]
 		sessions[i].conversationID = metadata.conversationID
 		sessions[i].sessionKey = metadata.sessionKey
+		sessions[i].title = metadata.title
 		sessions[i].summaryCount = summaryCounts[metadata.conversationID]
 		sessions[i].fileCount = fileCounts[metadata.conversationID]
+		sessions[i].conversationCount = metadata.conversationCount
+		sessions[i].tags = tags[metadata.conversationID]
 	}
 
 	return sessions, end, nil
@@ -463,46 +546,105 @@ func estimateTokenCountFromBytes(byteCount int64) int {
 	return int(byteCount / 4)
 }
 
-func parseSessionMessages(path string) ([]sessionMessage, error) {
-	file, err := os.Open(path)
+// parseSessionMessages reads displayable messages from a session JSONL,
+// converting it to UTF-8 with decoder first. Roles named in roleMap are shown
+// as backfill --role-map would store them; other roles are shown as written.
+func parseSessionMessages(path string, decoder *sessionDecoder, roleMap map[string]string) ([]sessionMessage, error) {
+	if decoder == nil {
+		decoder = &sessionDecoder{label: "auto"}
+	}
+	scanner, file, err := openSessionScanner(path, decoder)
 	if err != nil {
-		return nil, fmt.Errorf("open session %q: %w", path, err)
+		return nil, err
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 64*1024)
-	scanner.Buffer(buf, 16*1024*1024)
-
 	messages := make([]sessionMessage, 0, 256)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
+		item, msg, ok := decoder.messageLine(scanner.Bytes())
+		if !ok {
 			continue
 		}
+		messages = append(messages, newSessionMessage(item, msg, roleMap))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan session %q: %w", path, err)
+	}
+	return messages, nil
+}
 
-		var item sessionLine
-		if err := json.Unmarshal(line, &item); err != nil || item.Type != "message" {
-			continue
+func newSessionMessage(item sessionLine, msg lineMessage, roleMap map[string]string) sessionMessage {
+	role := msg.Role
+	if mapped, ok := roleMap[strings.ToLower(strings.TrimSpace(role))]; ok {
+		role = mapped
+	}
+	if role == "" {
+		role = "unknown"
+	}
+	return sessionMessage{
+		id:        item.ID,
+		parentID:  item.ParentID,
+		timestamp: pickTimestamp(item.Timestamp, msg.Timestamp),
+		role:      role,
+		text:      normalizeMessageContent(msg.Content),
+		messageID: 0,
+	}
+}
+
+// indexSessionMessages returns the line number of every displayable message
+// in a session JSONL without normalizing or keeping message content, so large
+// files can be paged with parseSessionMessageWindow. Line numbers count
+// scanned lines, which keeps them valid under any decoder transform.
+func indexSessionMessages(path string, decoder *sessionDecoder) ([]int, error) {
+	if decoder == nil {
+		decoder = &sessionDecoder{label: "auto"}
+	}
+	scanner, file, err := openSessionScanner(path, decoder)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := make([]int, 0, 1024)
+	for lineNo := 0; scanner.Scan(); lineNo++ {
+		if _, _, ok := decoder.messageLine(scanner.Bytes()); ok {
+			lines = append(lines, lineNo)
 		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan session %q: %w", path, err)
+	}
+	return lines, nil
+}
 
-		var msg lineMessage
-		if err := json.Unmarshal(item.Message, &msg); err != nil {
+// parseSessionMessageWindow parses only the messages on lines, an ascending
+// slice of indexSessionMessages output. Earlier lines are skipped without
+// decoding and scanning stops after the last one.
+func parseSessionMessageWindow(path string, decoder *sessionDecoder, roleMap map[string]string, lines []int) ([]sessionMessage, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if decoder == nil {
+		decoder = &sessionDecoder{label: "auto"}
+	}
+	scanner, file, err := openSessionScanner(path, decoder)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := make([]sessionMessage, 0, len(lines))
+	next := 0
+	for lineNo := 0; next < len(lines) && scanner.Scan(); lineNo++ {
+		if lineNo != lines[next] {
 			continue
 		}
-
-		role := msg.Role
-		if role == "" {
-			role = "unknown"
+		next++
+		item, msg, ok := decoder.messageLine(scanner.Bytes())
+		if !ok {
+			continue
 		}
-		messages = append(messages, sessionMessage{
-			id:        item.ID,
-			parentID:  item.ParentID,
-			timestamp: pickTimestamp(item.Timestamp, msg.Timestamp),
-			role:      role,
-			text:      normalizeMessageContent(msg.Content),
-			messageID: 0,
-		})
+		messages = append(messages, newSessionMessage(item, msg, roleMap))
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("scan session %q: %w", path, err)
@@ -728,11 +870,10 @@ func pickTimestamp(primary string, fallback any) string {
 	}
 }
 
-const maxDisplayBytes = 100_000 // truncate very long text content for display
-
 // sanitizeForTerminal strips non-printable characters that corrupt terminal output.
 // If more than 10% of the content is non-printable, it's treated as binary and replaced
-// with a placeholder showing the byte count. Very long text is truncated.
+// with a placeholder showing the byte count. Text longer than displayContentMaxChars is
+// truncated.
 func sanitizeForTerminal(s string) string {
 	if len(s) == 0 {
 		return s
@@ -749,25 +890,9 @@ func sanitizeForTerminal(s string) string {
 		return fmt.Sprintf("[binary content, %s]", formatByteSizeCompact(int64(len(s))))
 	}
 
-	truncated := false
-	if len(s) > maxDisplayBytes {
-		// Truncate at a rune boundary
-		count := 0
-		for i := range s {
-			if i >= maxDisplayBytes {
-				s = s[:i]
-				truncated = true
-				break
-			}
-			count++
-		}
-	}
-
 	// Strip individual non-printable characters
-	var result string
-	if nonPrintable == 0 {
-		result = s
-	} else {
+	result := s
+	if nonPrintable > 0 {
 		var b strings.Builder
 		b.Grow(len(s))
 		for _, r := range s {
@@ -777,9 +902,7 @@ func sanitizeForTerminal(s string) string {
 		}
 		result = b.String()
 	}
-	if truncated {
-		result += fmt.Sprintf("\n\n[truncated — full content is %s]", formatByteSizeCompact(int64(len(s))))
-	}
+	result, _ = truncateContent(result, displayContentMaxChars)
 	return result
 }
 
@@ -803,11 +926,19 @@ func normalizeMessageContent(raw json.RawMessage) string {
 		return sanitizeForTerminal(strings.TrimSpace(asString))
 	}
 
-	var blocks []contentBlock
+	// Blocks decode one at a time so a block that does not fit contentBlock
+	// renders on its own without losing the rest of the message.
+	var blocks []json.RawMessage
 	if err := json.Unmarshal(raw, &blocks); err == nil {
 		parts := make([]string, 0, len(blocks))
-		for _, block := range blocks {
-			part := formatContentBlock(block)
+		for _, rawBlock := range blocks {
+			var part string
+			var block contentBlock
+			if err := json.Unmarshal(rawBlock, &block); err == nil {
+				part = formatContentBlock(block)
+			} else {
+				part = formatUndecodedBlock(rawBlock)
+			}
 			if part != "" {
 				parts = append(parts, part)
 			}
@@ -824,6 +955,19 @@ func normalizeMessageContent(raw json.RawMessage) string {
 	return sanitizeForTerminal(strings.TrimSpace(string(raw)))
 }
 
+// formatUndecodedBlock renders an array element that is not a content block
+// object, or whose common fields have unexpected types.
+func formatUndecodedBlock(raw json.RawMessage) string {
+	var asAny any
+	if err := json.Unmarshal(raw, &asAny); err != nil {
+		return strings.TrimSpace(string(raw))
+	}
+	if s, ok := asAny.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", asAny))
+}
+
 func formatContentBlock(block contentBlock) string {
 	switch block.Type {
 	case "text":
@@ -858,6 +1002,12 @@ func formatContentBlock(block contentBlock) string {
 		}
 		return "[toolResult]"
 	default:
+		if structured := block.structuredPart().Format(); structured != "" {
+			if text := strings.TrimSpace(block.Text); text != "" {
+				return structured + "\n" + text
+			}
+			return structured
+		}
 		if strings.TrimSpace(block.Text) != "" {
 			return strings.TrimSpace(block.Text)
 		}
@@ -896,7 +1046,12 @@ func loadSummaryGraph(dbPath, sessionID string) (summaryGraph, error) {
 	if err != nil {
 		return summaryGraph{}, err
 	}
+	return loadConversationSummaryGraph(db, conversationID)
+}
 
+// loadConversationSummaryGraph loads a conversation's summary DAG from an open
+// DB, for callers that already know the conversation ID.
+func loadConversationSummaryGraph(db *sql.DB, conversationID int64) (summaryGraph, error) {
 	nodes, err := loadSummaryNodes(db, conversationID)
 	if err != nil {
 		return summaryGraph{}, err
@@ -914,6 +1069,7 @@ func loadSummaryGraph(dbPath, sessionID string) (summaryGraph, error) {
 	sortSummaryIDs(roots, nodes)
 	for _, node := range nodes {
 		sortSummaryIDs(node.children, nodes)
+		sortSummaryIDs(node.parents, nodes)
 	}
 
 	return summaryGraph{
@@ -925,14 +1081,11 @@ func loadSummaryGraph(dbPath, sessionID string) (summaryGraph, error) {
 
 func lookupConversationID(db *sql.DB, sessionID string) (int64, error) {
 	// Check if the conversations table exists (fresh install / LCM not yet initialized)
-	var tableExists int
-	if err := db.QueryRow(`
-		SELECT COUNT(*) FROM sqlite_master
-		WHERE type = 'table' AND name = 'conversations'
-	`).Scan(&tableExists); err != nil {
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
 		return 0, fmt.Errorf("check LCM schema: %w", err)
 	}
-	if tableExists == 0 {
+	if !caps.HasTable("conversations") {
 		return 0, fmt.Errorf("LCM database has no tables yet — start a conversation first so the plugin can initialize the schema")
 	}
 
@@ -944,8 +1097,12 @@ func lookupConversationID(db *sql.DB, sessionID string) (int64, error) {
 }
 
 func loadSummaryNodes(db *sql.DB, conversationID int64) (map[string]*summaryNode, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
+		return nil, fmt.Errorf("check summaries schema: %w", err)
+	}
 	rows, err := db.Query(`
-		SELECT summary_id, kind, COALESCE(depth, 0), content, created_at, token_count
+		SELECT summary_id, kind, COALESCE(depth, 0), content, created_at, `+caps.SummarySeqExpr("")+`, token_count
 		FROM summaries
 		WHERE conversation_id = ?
 	`, conversationID)
@@ -957,7 +1114,7 @@ func loadSummaryNodes(db *sql.DB, conversationID int64) (map[string]*summaryNode
 	nodes := make(map[string]*summaryNode)
 	for rows.Next() {
 		var node summaryNode
-		if err := rows.Scan(&node.id, &node.kind, &node.depth, &node.content, &node.createdAt, &node.tokenCount); err != nil {
+		if err := rows.Scan(&node.id, &node.kind, &node.depth, &node.content, &node.createdAt, &node.createdSeq, &node.tokenCount); err != nil {
 			return nil, fmt.Errorf("scan summary row: %w", err)
 		}
 		node.content = sanitizeForTerminal(node.content)
@@ -982,6 +1139,7 @@ func populateSummaryChildren(db *sql.DB, conversationID int64, nodes map[string]
 	defer rows.Close()
 
 	childSet := make(map[string]bool)
+	seenEdges := make(map[[2]string]bool)
 	for rows.Next() {
 		var sourceID, derivedID string
 		if err := rows.Scan(&sourceID, &derivedID); err != nil {
@@ -989,10 +1147,14 @@ func populateSummaryChildren(db *sql.DB, conversationID int64, nodes map[string]
 		}
 		// DB stores (parent=source, summary=derived). For the TUI tree,
 		// the derived (condensed) summary is the parent and sources are children.
+		// A source may feed several derived summaries, so nodes form a DAG.
 		derivedNode, hasDerived := nodes[derivedID]
-		_, hasSource := nodes[sourceID]
-		if hasDerived && hasSource {
+		sourceNode, hasSource := nodes[sourceID]
+		edge := [2]string{derivedID, sourceID}
+		if hasDerived && hasSource && !seenEdges[edge] {
+			seenEdges[edge] = true
 			derivedNode.children = append(derivedNode.children, sourceID)
+			sourceNode.parents = append(sourceNode.parents, derivedID)
 			childSet[sourceID] = true
 		}
 	}
@@ -1024,10 +1186,13 @@ func sortSummaryIDs(ids []string, nodes map[string]*summaryNode) {
 		if left == nil || right == nil {
 			return ids[i] < ids[j]
 		}
-		if left.createdAt == right.createdAt {
-			return left.id < right.id
+		if left.createdAt != right.createdAt {
+			return left.createdAt < right.createdAt
 		}
-		return left.createdAt < right.createdAt
+		if left.createdSeq != right.createdSeq {
+			return left.createdSeq < right.createdSeq
+		}
+		return left.id < right.id
 	})
 }
 
@@ -1106,6 +1271,25 @@ func loadSummaryCounts(dbPath string, conversationIDs []int64) map[int64]int {
 	return counts
 }
 
+// loadSessionTags is the best-effort variant of loadConversationTags used
+// while listing sessions; tags are optional decoration.
+func loadSessionTags(dbPath string, conversationIDs []int64) map[int64][]string {
+	if len(conversationIDs) == 0 {
+		return map[int64][]string{}
+	}
+	db, err := openLCMDB(dbPath)
+	if err != nil {
+		return map[int64][]string{}
+	}
+	defer db.Close()
+
+	tags, err := loadConversationTags(context.Background(), db, conversationIDs)
+	if err != nil {
+		return map[int64][]string{}
+	}
+	return tags
+}
+
 func loadLargeFiles(dbPath, sessionID string) ([]largeFileEntry, error) {
 	db, err := openLCMDB(dbPath)
 	if err != nil {
@@ -1193,6 +1377,10 @@ func loadFileCounts(dbPath string, conversationIDs []int64) map[int64]int {
 type conversationMetadata struct {
 	conversationID int64
 	sessionKey     string
+	title          string
+	// conversationCount is how many conversations match the session; more
+	// than one means resets created new ones and conversationID is the latest.
+	conversationCount int
 }
 
 // sessionLookupKey preserves the on-disk session filename while also tracking
@@ -1252,12 +1440,17 @@ func loadConversationMetadataFromDB(db *sql.DB, sessionIDs []string) map[string]
 		return metadata
 	}
 
+	// Older test and fixture schemas predate conversations.title.
+	titleColumn := "''"
+	if caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db); err == nil && caps.HasColumn("conversations", "title") {
+		titleColumn = "COALESCE(title, '')"
+	}
 	query := fmt.Sprintf(`
-		SELECT conversation_id, session_id, session_key
+		SELECT conversation_id, session_id, session_key, %s
 		FROM conversations
 		WHERE %s
 		ORDER BY conversation_id DESC
-	`, strings.Join(whereParts, " OR "))
+	`, titleColumn, strings.Join(whereParts, " OR "))
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -1267,22 +1460,28 @@ func loadConversationMetadataFromDB(db *sql.DB, sessionIDs []string) map[string]
 
 	bySessionKey := make(map[string]conversationMetadata, len(lookups))
 	bySessionID := make(map[string]conversationMetadata, len(lookups))
+	sessionKeyCounts := make(map[string]int, len(lookups))
+	sessionIDCounts := make(map[string]int, len(lookups))
 	for rows.Next() {
 		var conversationID int64
 		var sessionID string
 		var sessionKey sql.NullString
-		if err := rows.Scan(&conversationID, &sessionID, &sessionKey); err != nil {
+		var title string
+		if err := rows.Scan(&conversationID, &sessionID, &sessionKey, &title); err != nil {
 			continue
 		}
 		row := conversationMetadata{
 			conversationID: conversationID,
 			sessionKey:     sessionKey.String,
+			title:          title,
 		}
 		if row.sessionKey != "" {
+			sessionKeyCounts[row.sessionKey]++
 			if _, exists := bySessionKey[row.sessionKey]; !exists {
 				bySessionKey[row.sessionKey] = row
 			}
 		}
+		sessionIDCounts[sessionID]++
 		if _, exists := bySessionID[sessionID]; !exists {
 			bySessionID[sessionID] = row
 		}
@@ -1291,11 +1490,13 @@ func loadConversationMetadataFromDB(db *sql.DB, sessionIDs []string) map[string]
 	for _, lookup := range lookups {
 		if lookup.exactSessionKey != "" {
 			if row, ok := bySessionKey[lookup.exactSessionKey]; ok {
+				row.conversationCount = sessionKeyCounts[lookup.exactSessionKey]
 				metadata[lookup.requestedSessionID] = row
 				continue
 			}
 		}
 		if row, ok := bySessionID[lookup.normalizedSessionID]; ok {
+			row.conversationCount = sessionIDCounts[lookup.normalizedSessionID]
 			metadata[lookup.requestedSessionID] = row
 		}
 	}
@@ -1362,9 +1563,9 @@ func loadContextItems(dbPath, sessionID string) ([]contextItemEntry, error) {
 		return nil, err
 	}
 	summaryLatestAtExpr := "''"
-	if hasLatestAt, err := sqliteColumnExists(db, "summaries", "latest_at"); err != nil {
+	if caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db); err != nil {
 		return nil, fmt.Errorf("check summaries.latest_at schema: %w", err)
-	} else if hasLatestAt {
+	} else if caps.HasColumn("summaries", "latest_at") {
 		summaryLatestAtExpr = "COALESCE(s.latest_at, '')"
 	}
 
@@ -1458,25 +1659,11 @@ func populateContextSummarySourceSeq(db *sql.DB, item *contextItemEntry) error {
 	if item.summaryID == "" {
 		return nil
 	}
-	exists, err := sqliteTableExists(db, "summary_parents")
-	if err != nil {
-		return fmt.Errorf("check summary_parents schema for context item %s: %w", item.summaryID, err)
-	}
-	if !exists {
-		return nil
-	}
-	exists, err = sqliteTableExists(db, "summary_messages")
-	if err != nil {
-		return fmt.Errorf("check summary_messages schema for context item %s: %w", item.summaryID, err)
-	}
-	if !exists {
-		return nil
-	}
-	exists, err = sqliteTableExists(db, "messages")
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
 	if err != nil {
-		return fmt.Errorf("check messages schema for context item %s: %w", item.summaryID, err)
+		return fmt.Errorf("check schema for context item %s: %w", item.summaryID, err)
 	}
-	if !exists {
+	if !caps.HasTable("summary_parents") || !caps.HasTable("summary_messages") || !caps.HasTable("messages") {
 		return nil
 	}
 	var maxSeq sql.NullInt64
@@ -1504,11 +1691,11 @@ func populateContextSummarySourceSeq(db *sql.DB, item *contextItemEntry) error {
 
 // loadActiveFocusBriefForConversation returns the active focus overlay metadata.
 func loadActiveFocusBriefForConversation(db *sql.DB, conversationID int64) (*focusBriefEntry, error) {
-	exists, err := sqliteTableExists(db, "focus_briefs")
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
 	if err != nil {
 		return nil, fmt.Errorf("check focus brief schema: %w", err)
 	}
-	if !exists {
+	if !caps.HasTable("focus_briefs") {
 		return nil, nil
 	}
 	var brief focusBriefEntry
@@ -1591,7 +1778,7 @@ func applyFocusOverlayToContextItems(items []contextItemEntry, brief *focusBrief
 		itemType:     "focus_brief",
 		focusBriefID: brief.briefID,
 		kind:         "active",
-		tokenCount:   estimateTokenCount(focusContent),
+		tokenCount:   lcm.EstimateTokenCount(focusContent),
 		content:      sanitizeForTerminal(focusContent),
 		createdAt:    brief.createdAt,
 	}
@@ -1629,8 +1816,8 @@ func contextSummaryCoveredByFocus(item contextItemEntry, brief *focusBriefEntry)
 }
 
 func compareSQLiteTimes(left, right string) int {
-	leftTime, leftOK := parseContextItemTime(left)
-	rightTime, rightOK := parseContextItemTime(right)
+	leftTime, leftOK := lcm.ParseContextItemTime(left)
+	rightTime, rightOK := lcm.ParseContextItemTime(right)
 	if leftOK && rightOK {
 		if leftTime.Before(rightTime) {
 			return -1
@@ -1643,20 +1830,6 @@ func compareSQLiteTimes(left, right string) int {
 	return strings.Compare(strings.TrimSpace(left), strings.TrimSpace(right))
 }
 
-func parseContextItemTime(value string) (time.Time, bool) {
-	trimmed := strings.TrimSpace(value)
-	if trimmed == "" {
-		return time.Time{}, false
-	}
-	if parsed, err := time.Parse(time.RFC3339Nano, trimmed); err == nil {
-		return parsed, true
-	}
-	if parsed, err := time.Parse("2006-01-02 15:04:05", trimmed); err == nil {
-		return parsed, true
-	}
-	return time.Time{}, false
-}
-
 func formatFocusBriefContextContent(brief *focusBriefEntry) string {
 	attrs := []string{
 		fmt.Sprintf(`id="%s"`, escapeXMLAttribute(brief.briefID)),
@@ -1700,46 +1873,6 @@ func countCoveredContextItems(covered []bool) int {
 	return count
 }
 
-// sqliteTableExists checks optional feature tables without treating older DBs as broken.
-func sqliteTableExists(db *sql.DB, tableName string) (bool, error) {
-	var count int
-	if err := db.QueryRow(`
-		SELECT COUNT(*) FROM sqlite_master
-		WHERE type = 'table' AND name = ?
-	`, tableName).Scan(&count); err != nil {
-		return false, err
-	}
-	return count > 0, nil
-}
-
-// sqliteColumnExists checks optional columns across historical LCM schemas.
-func sqliteColumnExists(db *sql.DB, tableName, columnName string) (bool, error) {
-	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, tableName))
-	if err != nil {
-		return false, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var cid int
-		var name string
-		var columnType string
-		var notNull int
-		var defaultValue sql.NullString
-		var pk int
-		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
-			return false, err
-		}
-		if name == columnName {
-			return true, nil
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return false, err
-	}
-	return false, nil
-}
-
 // loadFocusBriefs returns persisted focus briefs for the selected LCM conversation.
 func loadFocusBriefs(dbPath, sessionID string) ([]focusBriefEntry, error) {
 	db, err := openLCMDB(dbPath)
@@ -1753,11 +1886,11 @@ func loadFocusBriefs(dbPath, sessionID string) ([]focusBriefEntry, error) {
 		return nil, err
 	}
 
-	exists, err := sqliteTableExists(db, "focus_briefs")
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
 	if err != nil {
 		return nil, fmt.Errorf("check focus brief schema: %w", err)
 	}
-	if !exists {
+	if !caps.HasTable("focus_briefs") {
 		return nil, nil
 	}
 
@@ -1871,12 +2004,12 @@ func focusBriefRawTruncated(raw string) bool {
 
 // populateFocusBriefDiagnostics adds post-focus drift and source freshness state.
 func populateFocusBriefDiagnostics(db *sql.DB, brief *focusBriefEntry) error {
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
+		return fmt.Errorf("check schema for focus diagnostics: %w", err)
+	}
 	if brief.hasCoveredMessageSeq {
-		exists, err := sqliteTableExists(db, "messages")
-		if err != nil {
-			return fmt.Errorf("check messages schema for focus diagnostics: %w", err)
-		}
-		if exists {
+		if caps.HasTable("messages") {
 			if err := db.QueryRow(`
 				SELECT COUNT(*), COALESCE(SUM(token_count), 0)
 				FROM messages
@@ -1899,11 +2032,7 @@ func populateFocusBriefDiagnostics(db *sql.DB, brief *focusBriefEntry) error {
 		summaryPredicate = "latest_at IS NOT NULL AND datetime(latest_at) > datetime(?)"
 	}
 	if summaryWatermark != "" {
-		exists, err := sqliteTableExists(db, "summaries")
-		if err != nil {
-			return fmt.Errorf("check summaries schema for focus diagnostics: %w", err)
-		}
-		if exists {
+		if caps.HasTable("summaries") {
 			var summaryTokens int
 			query := fmt.Sprintf(`
 				SELECT COUNT(*), COALESCE(SUM(token_count), 0)
@@ -1936,15 +2065,11 @@ func populateFocusBriefDiagnostics(db *sql.DB, brief *focusBriefEntry) error {
 
 // activeFocusSourceContextHash mirrors the runtime focus source fingerprint.
 func activeFocusSourceContextHash(db *sql.DB, conversationID int64) (string, error) {
-	contextExists, err := sqliteTableExists(db, "context_items")
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
 	if err != nil {
 		return "", fmt.Errorf("check context schema for focus diagnostics: %w", err)
 	}
-	summaryExists, err := sqliteTableExists(db, "summaries")
-	if err != nil {
-		return "", fmt.Errorf("check summary schema for focus diagnostics: %w", err)
-	}
-	if !contextExists || !summaryExists {
+	if !caps.HasTable("context_items") || !caps.HasTable("summaries") {
 		return "", nil
 	}
 
@@ -1989,11 +2114,11 @@ func activeFocusSourceContextHash(db *sql.DB, conversationID int64) (string, err
 
 // populateFocusBriefSourceStats adds source/citation counts and IDs to one brief.
 func populateFocusBriefSourceStats(db *sql.DB, brief *focusBriefEntry) error {
-	exists, err := sqliteTableExists(db, "focus_brief_sources")
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
 	if err != nil {
 		return fmt.Errorf("check focus brief source schema: %w", err)
 	}
-	if !exists {
+	if !caps.HasTable("focus_brief_sources") {
 		return nil
 	}
 
@@ -2039,16 +2164,63 @@ func formatTimeForList(ts time.Time) string {
 
 func formatTimestamp(ts string) string {
 	trimmed := strings.TrimSpace(ts)
-	if trimmed == "" {
-		return ""
+	if parsed, ok := parseDisplayTimestamp(trimmed); ok {
+		return formatTimeForList(parsed)
 	}
-	// Try RFC3339 first (with timezone info)
-	if parsed, err := time.Parse(time.RFC3339Nano, trimmed); err == nil {
-		return parsed.Local().Format("2006-01-02 15:04:05")
+	return trimmed
+}
+
+// parseDisplayTimestamp parses a stored timestamp: RFC3339, or a SQLite bare
+// datetime, which is stored as UTC without a timezone indicator.
+func parseDisplayTimestamp(ts string) (time.Time, bool) {
+	if ts == "" {
+		return time.Time{}, false
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		return parsed, true
+	}
+	if parsed, err := time.Parse("2006-01-02 15:04:05", ts); err == nil {
+		return parsed, true
+	}
+	return time.Time{}, false
+}
+
+// formatRelativeTime renders ts against now as "just now", "3m ago",
+// "5h ago", or "2d ago". Times more than 30 days back fall back to the
+// absolute local date.
+func formatRelativeTime(ts, now time.Time) string {
+	age := now.Sub(ts)
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age/time.Minute))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age/time.Hour))
+	case age < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(age/(24*time.Hour)))
+	default:
+		return ts.Local().Format("2006-01-02")
+	}
+}
+
+// displayListTime is displayTimestamp for an already-parsed time.
+func displayListTime(ts time.Time, relative bool) string {
+	if relative {
+		return formatRelativeTime(ts, time.Now())
 	}
-	// SQLite bare datetime (stored as UTC, no timezone indicator)
-	if parsed, err := time.Parse("2006-01-02 15:04:05", trimmed); err == nil {
-		return parsed.In(time.UTC).Local().Format("2006-01-02 15:04:05")
+	return formatTimeForList(ts)
+}
+
+// displayTimestamp formats a stored timestamp for the TUI, honoring the
+// relative-times toggle. Unparseable values are shown as stored.
+func displayTimestamp(ts string, relative bool) string {
+	if !relative {
+		return formatTimestamp(ts)
+	}
+	trimmed := strings.TrimSpace(ts)
+	if parsed, ok := parseDisplayTimestamp(trimmed); ok {
+		return formatRelativeTime(parsed, time.Now())
 	}
 	return trimmed
 }
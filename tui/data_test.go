@@ -73,6 +73,44 @@ func TestResolveDataPathsUsesOpenclawStateDir(t *testing.T) {
 	}
 }
 
+func TestResolveDataPathsHonorsDBOverride(t *testing.T) {
+	t.Setenv("OPENCLAW_STATE_DIR", "/custom/tui")
+	t.Setenv("LCM_DATABASE_PATH", "/archive/lcm-2025.db")
+	paths, err := resolveDataPaths()
+	if err != nil {
+		t.Fatalf("resolveDataPaths: %v", err)
+	}
+	if paths.lcmDBPath != "/archive/lcm-2025.db" || paths.lcmDBSource != "LCM_DATABASE_PATH" {
+		t.Fatalf("expected LCM_DATABASE_PATH db, got %q (%s)", paths.lcmDBPath, paths.lcmDBSource)
+	}
+
+	lcmDBPathOverride = "/other/agent.db"
+	t.Cleanup(func() { lcmDBPathOverride = "" })
+	paths, err = resolveDataPaths()
+	if err != nil {
+		t.Fatalf("resolveDataPaths: %v", err)
+	}
+	if paths.lcmDBPath != "/other/agent.db" || paths.lcmDBSource != "--db" {
+		t.Fatalf("expected --db to win, got %q (%s)", paths.lcmDBPath, paths.lcmDBSource)
+	}
+	if paths.agentsDir != "/custom/tui/agents" {
+		t.Fatalf("--db should not move the agents dir, got %q", paths.agentsDir)
+	}
+}
+
+func TestExtractDBPathFlag(t *testing.T) {
+	args, dbPath, err := extractDBPathFlag([]string{"--db", "/a.db", "report", "44", "--db=/b.db", "--json"})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if dbPath != "/b.db" || strings.Join(args, " ") != "report 44 --json" {
+		t.Fatalf("got args %q, db %q", args, dbPath)
+	}
+	if _, _, err := extractDBPathFlag([]string{"report", "--db"}); err == nil {
+		t.Fatal("expected missing --db value to fail")
+	}
+}
+
 func TestResolveDataPathsFallsBackToDotOpenclaw(t *testing.T) {
 	t.Setenv("OPENCLAW_STATE_DIR", "")
 	home, err := os.UserHomeDir()
@@ -88,3 +126,48 @@ func TestResolveDataPathsFallsBackToDotOpenclaw(t *testing.T) {
 		t.Fatalf("expected openclawDir %q, got %q", expected, paths.openclawDir)
 	}
 }
+
+func TestNormalizeMessageContentRendersStructuredBlocks(t *testing.T) {
+	raw := `[
+		{"type": "text", "text": "applied the fix"},
+		{"type": "patch", "hash": "0123456789abcdef0123", "files": ["src/a.go", "src/b.go"]},
+		{"type": "subtask", "agent": "explore", "description": "Find the config loader", "prompt": "Where is openclaw.json read?"},
+		{"type": "step-finish", "reason": "stop", "cost": 0.0123, "tokens": {"input": 1200, "output": 300}},
+		{"type": "snapshot", "snapshot": "fedcba9876543210"},
+		{"type": "step-start"}
+	]`
+	got := normalizeMessageContent([]byte(raw))
+	want := strings.Join([]string{
+		"applied the fix",
+		"[patch 0123456789ab] src/a.go, src/b.go",
+		"[subtask: explore] Find the config loader",
+		"Prompt: Where is openclaw.json read?",
+		"[step finish: stop, 1200 in / 300 out tokens, $0.0123]",
+		"[snapshot fedcba987654]",
+		"[step-start]",
+	}, "\n")
+	if got != want {
+		t.Fatalf("normalizeMessageContent = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeMessageContentToleratesOddBlockFields(t *testing.T) {
+	raw := `[
+		{"type": "text", "text": "kept"},
+		{"type": "step-finish", "reason": "stop", "cost": "0.01", "tokens": {"input": "many", "output": 300}},
+		{"type": "patch", "hash": 42, "files": "src/a.go"},
+		{"type": "text", "text": {"nested": true}},
+		{"type": "text", "text": "also kept"}
+	]`
+	got := normalizeMessageContent([]byte(raw))
+	want := strings.Join([]string{
+		"kept",
+		"[step finish: stop, 0 in / 300 out tokens]",
+		"[patch] src/a.go",
+		"map[text:map[nested:true] type:text]",
+		"also kept",
+	}, "\n")
+	if got != want {
+		t.Fatalf("normalizeMessageContent = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// defaultDBDiffLimit bounds how many conversations and summary changes
+// db-diff lists; the totals always cover everything.
+const defaultDBDiffLimit = 50
+
+type dbDiffOptions struct {
+	conversationID int64 // --conversation; 0 compares every conversation
+	jsonOutput     bool
+	limit          int // 0 lists everything
+}
+
+// dbDiffSnapshot is what db-diff compares from one DB: per-conversation row
+// counts and a content hash for every summary.
+type dbDiffSnapshot struct {
+	conversations map[int64]*dbDiffCounts
+	summaries     map[string]dbDiffSummary
+}
+
+type dbDiffCounts struct {
+	messages     int
+	contextItems int
+	summaries    int
+}
+
+type dbDiffSummary struct {
+	conversationID int64
+	kind           string
+	depth          int
+	tokenCount     int
+	hash           string
+}
+
+// dbDiffReport is the db-diff result, printed as a table or encoded as JSON.
+type dbDiffReport struct {
+	OldDB                string                `json:"old_db"`
+	NewDB                string                `json:"new_db"`
+	ConversationID       int64                 `json:"conversation_id,omitempty"`
+	Totals               dbDiffTotals          `json:"totals"`
+	Conversations        []dbDiffConversation  `json:"conversations"`
+	ConversationsOmitted int                   `json:"conversations_omitted,omitempty"`
+	Summaries            []dbDiffSummaryChange `json:"summaries"`
+	SummariesOmitted     int                   `json:"summaries_omitted,omitempty"`
+}
+
+type dbDiffTotals struct {
+	ConversationsBefore  int `json:"conversations_before"`
+	ConversationsAfter   int `json:"conversations_after"`
+	ConversationsAdded   int `json:"conversations_added"`
+	ConversationsRemoved int `json:"conversations_removed"`
+	ConversationsChanged int `json:"conversations_changed"`
+	SummariesBefore      int `json:"summaries_before"`
+	SummariesAfter       int `json:"summaries_after"`
+	SummariesAdded       int `json:"summaries_added"`
+	SummariesRemoved     int `json:"summaries_removed"`
+	SummariesChanged     int `json:"summaries_changed"`
+	SummariesMoved       int `json:"summaries_moved"`
+}
+
+// dbDiffConversation is one conversation whose rows differ. A summary moved
+// between conversations counts as removed from one and added to the other.
+type dbDiffConversation struct {
+	ConversationID     int64  `json:"conversation_id"`
+	Status             string `json:"status"` // added, removed, or changed
+	MessagesBefore     int    `json:"messages_before"`
+	MessagesAfter      int    `json:"messages_after"`
+	ContextItemsBefore int    `json:"context_items_before"`
+	ContextItemsAfter  int    `json:"context_items_after"`
+	SummariesBefore    int    `json:"summaries_before"`
+	SummariesAfter     int    `json:"summaries_after"`
+	SummariesAdded     int    `json:"summaries_added"`
+	SummariesRemoved   int    `json:"summaries_removed"`
+	SummariesChanged   int    `json:"summaries_changed"`
+}
+
+// dbDiffSummaryChange is one summary that was added, removed, rewritten
+// (content hash changed), or moved to another conversation.
+type dbDiffSummaryChange struct {
+	SummaryID              string `json:"summary_id"`
+	Change                 string `json:"change"` // added, removed, changed, or moved
+	ConversationID         int64  `json:"conversation_id"`
+	PreviousConversationID int64  `json:"previous_conversation_id,omitempty"`
+	Kind                   string `json:"kind"`
+	Depth                  int    `json:"depth"`
+	TokensBefore           int    `json:"tokens_before"`
+	TokensAfter            int    `json:"tokens_after"`
+	HashBefore             string `json:"hash_before,omitempty"`
+	HashAfter              string `json:"hash_after,omitempty"`
+}
+
+// runDBDiffCommand executes the standalone db-diff CLI path.
+func runDBDiffCommand(args []string) error {
+	opts, oldPath, newPath, err := parseDBDiffArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var snapshots [2]dbDiffSnapshot
+	for i, path := range []string{oldPath, newPath} {
+		snapshots[i], err = loadDBDiffSnapshotFile(ctx, path, opts.conversationID)
+		if err != nil {
+			return err
+		}
+	}
+
+	report := diffDBSnapshots(snapshots[0], snapshots[1])
+	report.OldDB = oldPath
+	report.NewDB = newPath
+	report.ConversationID = opts.conversationID
+	report.limit(opts.limit)
+
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("encode db diff: %w", err)
+		}
+		return nil
+	}
+	printDBDiffReport(os.Stdout, report, resolveCLIOutputStyle())
+	return nil
+}
+
+// openLCMDBReadOnly opens an lcm.db that must not be modified, such as a
+// backup: unlike openLCMDB it never switches the file to WAL mode.
+func openLCMDBReadOnly(path string) (*sql.DB, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %w", path, err)
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return nil, fmt.Errorf("stat LCM database %q: %w", path, err)
+	}
+	dsn := (&url.URL{Scheme: "file", Path: absPath, RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite db %q: %w", path, err)
+	}
+	db.Exec("PRAGMA busy_timeout=10000")
+	return db, nil
+}
+
+func loadDBDiffSnapshotFile(ctx context.Context, path string, conversationID int64) (dbDiffSnapshot, error) {
+	db, err := openLCMDBReadOnly(path)
+	if err != nil {
+		return dbDiffSnapshot{}, err
+	}
+	defer db.Close()
+	snapshot, err := loadDBDiffSnapshot(ctx, db, conversationID)
+	if err != nil {
+		return dbDiffSnapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// loadDBDiffSnapshot reads row counts and summary hashes, limited to one
+// conversation when conversationID is set. Tables the DB lacks count as
+// empty, so a DB from before the plugin initialized it still compares.
+func loadDBDiffSnapshot(ctx context.Context, db *sql.DB, conversationID int64) (dbDiffSnapshot, error) {
+	snapshot := dbDiffSnapshot{
+		conversations: make(map[int64]*dbDiffCounts),
+		summaries:     make(map[string]dbDiffSummary),
+	}
+	filter := func(column string) (string, []any) {
+		if conversationID == 0 {
+			return "", nil
+		}
+		return " WHERE " + column + " = ?", []any{conversationID}
+	}
+	counts := func(id int64) *dbDiffCounts {
+		c := snapshot.conversations[id]
+		if c == nil {
+			c = &dbDiffCounts{}
+			snapshot.conversations[id] = c
+		}
+		return c
+	}
+
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		return dbDiffSnapshot{}, fmt.Errorf("check schema: %w", err)
+	}
+	if caps.HasTable("conversations") {
+		where, args := filter("conversation_id")
+		rows, err := db.QueryContext(ctx, `SELECT conversation_id FROM conversations`+where, args...)
+		if err != nil {
+			return dbDiffSnapshot{}, fmt.Errorf("query conversations: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return dbDiffSnapshot{}, fmt.Errorf("scan conversation: %w", err)
+			}
+			counts(id)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return dbDiffSnapshot{}, fmt.Errorf("iterate conversations: %w", err)
+		}
+	}
+
+	for _, table := range []string{"messages", "context_items"} {
+		if !caps.HasTable(table) {
+			continue
+		}
+		where, args := filter("conversation_id")
+		rows, err := db.QueryContext(ctx, `SELECT conversation_id, COUNT(*) FROM `+table+where+` GROUP BY conversation_id`, args...)
+		if err != nil {
+			return dbDiffSnapshot{}, fmt.Errorf("count %s: %w", table, err)
+		}
+		for rows.Next() {
+			var id int64
+			var n int
+			if err := rows.Scan(&id, &n); err != nil {
+				rows.Close()
+				return dbDiffSnapshot{}, fmt.Errorf("scan %s count: %w", table, err)
+			}
+			if table == "messages" {
+				counts(id).messages = n
+			} else {
+				counts(id).contextItems = n
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return dbDiffSnapshot{}, fmt.Errorf("iterate %s counts: %w", table, err)
+		}
+	}
+
+	if caps.HasTable("summaries") {
+		// Content is hashed row by row and dropped, so memory stays
+		// proportional to the summary count, not the store size.
+		where, args := filter("conversation_id")
+		rows, err := db.QueryContext(ctx, `
+			SELECT summary_id, conversation_id, kind, COALESCE(depth, 0), token_count, content
+			FROM summaries`+where, args...)
+		if err != nil {
+			return dbDiffSnapshot{}, fmt.Errorf("query summaries: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, content string
+			var s dbDiffSummary
+			if err := rows.Scan(&id, &s.conversationID, &s.kind, &s.depth, &s.tokenCount, &content); err != nil {
+				return dbDiffSnapshot{}, fmt.Errorf("scan summary: %w", err)
+			}
+			s.hash = lcm.ContentSHA256(content)
+			snapshot.summaries[id] = s
+			counts(s.conversationID).summaries++
+		}
+		if err := rows.Err(); err != nil {
+			return dbDiffSnapshot{}, fmt.Errorf("iterate summaries: %w", err)
+		}
+	}
+	return snapshot, nil
+}
+
+// diffDBSnapshots compares two snapshots. Only conversations that differ
+// are listed; summaries are matched by ID and compared by content hash.
+func diffDBSnapshots(oldSnap, newSnap dbDiffSnapshot) dbDiffReport {
+	report := dbDiffReport{
+		Totals: dbDiffTotals{
+			ConversationsBefore: len(oldSnap.conversations),
+			ConversationsAfter:  len(newSnap.conversations),
+			SummariesBefore:     len(oldSnap.summaries),
+			SummariesAfter:      len(newSnap.summaries),
+		},
+		Conversations: []dbDiffConversation{},
+		Summaries:     []dbDiffSummaryChange{},
+	}
+
+	conversations := make(map[int64]*dbDiffConversation)
+	conversation := func(id int64) *dbDiffConversation {
+		c := conversations[id]
+		if c == nil {
+			c = &dbDiffConversation{ConversationID: id}
+			if counts := oldSnap.conversations[id]; counts != nil {
+				c.MessagesBefore, c.ContextItemsBefore, c.SummariesBefore = counts.messages, counts.contextItems, counts.summaries
+			}
+			if counts := newSnap.conversations[id]; counts != nil {
+				c.MessagesAfter, c.ContextItemsAfter, c.SummariesAfter = counts.messages, counts.contextItems, counts.summaries
+			}
+			conversations[id] = c
+		}
+		return c
+	}
+
+	for id, before := range oldSnap.summaries {
+		after, ok := newSnap.summaries[id]
+		if !ok {
+			conversation(before.conversationID).SummariesRemoved++
+			report.Totals.SummariesRemoved++
+			report.Summaries = append(report.Summaries, dbDiffSummaryChange{
+				SummaryID: id, Change: "removed", ConversationID: before.conversationID,
+				Kind: before.kind, Depth: before.depth, TokensBefore: before.tokenCount,
+				HashBefore: lcm.ShortPartHash(before.hash),
+			})
+			continue
+		}
+		change := dbDiffSummaryChange{
+			SummaryID: id, ConversationID: after.conversationID, Kind: after.kind, Depth: after.depth,
+			TokensBefore: before.tokenCount, TokensAfter: after.tokenCount,
+			HashBefore: lcm.ShortPartHash(before.hash), HashAfter: lcm.ShortPartHash(after.hash),
+		}
+		switch {
+		case before.hash != after.hash:
+			change.Change = "changed"
+			report.Totals.SummariesChanged++
+			conversation(after.conversationID).SummariesChanged++
+		case before.conversationID != after.conversationID:
+			change.Change = "moved"
+			report.Totals.SummariesMoved++
+		default:
+			continue
+		}
+		if before.conversationID != after.conversationID {
+			change.PreviousConversationID = before.conversationID
+			conversation(before.conversationID).SummariesRemoved++
+			conversation(after.conversationID).SummariesAdded++
+		}
+		report.Summaries = append(report.Summaries, change)
+	}
+	for id, after := range newSnap.summaries {
+		if _, ok := oldSnap.summaries[id]; ok {
+			continue
+		}
+		conversation(after.conversationID).SummariesAdded++
+		report.Totals.SummariesAdded++
+		report.Summaries = append(report.Summaries, dbDiffSummaryChange{
+			SummaryID: id, Change: "added", ConversationID: after.conversationID,
+			Kind: after.kind, Depth: after.depth, TokensAfter: after.tokenCount,
+			HashAfter: lcm.ShortPartHash(after.hash),
+		})
+	}
+
+	for id, before := range oldSnap.conversations {
+		after, ok := newSnap.conversations[id]
+		if !ok || *before != *after {
+			conversation(id)
+		}
+	}
+	for id := range newSnap.conversations {
+		if _, ok := oldSnap.conversations[id]; !ok {
+			conversation(id)
+		}
+	}
+	for id, c := range conversations {
+		_, inOld := oldSnap.conversations[id]
+		_, inNew := newSnap.conversations[id]
+		switch {
+		case !inOld:
+			c.Status = "added"
+			report.Totals.ConversationsAdded++
+		case !inNew:
+			c.Status = "removed"
+			report.Totals.ConversationsRemoved++
+		default:
+			c.Status = "changed"
+			report.Totals.ConversationsChanged++
+		}
+		report.Conversations = append(report.Conversations, *c)
+	}
+
+	sort.Slice(report.Conversations, func(i, j int) bool {
+		return report.Conversations[i].ConversationID < report.Conversations[j].ConversationID
+	})
+	sort.Slice(report.Summaries, func(i, j int) bool {
+		a, b := report.Summaries[i], report.Summaries[j]
+		if a.ConversationID != b.ConversationID {
+			return a.ConversationID < b.ConversationID
+		}
+		return a.SummaryID < b.SummaryID
+	})
+	return report
+}
+
+// limit keeps the first n conversations and summary changes, recording how
+// many were left out; n <= 0 keeps everything.
+func (r *dbDiffReport) limit(n int) {
+	if n <= 0 {
+		return
+	}
+	if len(r.Conversations) > n {
+		r.ConversationsOmitted = len(r.Conversations) - n
+		r.Conversations = r.Conversations[:n]
+	}
+	if len(r.Summaries) > n {
+		r.SummariesOmitted = len(r.Summaries) - n
+		r.Summaries = r.Summaries[:n]
+	}
+}
+
+func printDBDiffReport(w io.Writer, report dbDiffReport, style cliOutputStyle) {
+	t := report.Totals
+	fmt.Fprintf(w, "db-diff %s -> %s\n", report.OldDB, report.NewDB)
+	if report.ConversationID != 0 {
+		fmt.Fprintf(w, "Limited to conversation %d.\n", report.ConversationID)
+	}
+	fmt.Fprintf(w, "Conversations: %d -> %d (%d added, %d removed, %d changed)\n",
+		t.ConversationsBefore, t.ConversationsAfter, t.ConversationsAdded, t.ConversationsRemoved, t.ConversationsChanged)
+	fmt.Fprintf(w, "Summaries: %d -> %d (%d added, %d removed, %d content changed, %d moved)\n",
+		t.SummariesBefore, t.SummariesAfter, t.SummariesAdded, t.SummariesRemoved, t.SummariesChanged, t.SummariesMoved)
+	if len(report.Conversations) == 0 && len(report.Summaries) == 0 {
+		fmt.Fprintln(w, "\nNo differences.")
+		return
+	}
+
+	if len(report.Conversations) > 0 {
+		fmt.Fprintln(w)
+		table := cliTable{columns: []cliTableColumn{
+			{header: "conv_id", align: cliAlignRight},
+			{header: "status"},
+			{header: "msgs", align: cliAlignRight},
+			{header: "ctx", align: cliAlignRight},
+			{header: "sums", align: cliAlignRight},
+			{header: "+sum", align: cliAlignRight},
+			{header: "-sum", align: cliAlignRight},
+			{header: "~sum", align: cliAlignRight},
+		}}
+		for _, c := range report.Conversations {
+			table.addRow(
+				strconv.FormatInt(c.ConversationID, 10),
+				c.Status,
+				formatDBDiffCount(c.MessagesBefore, c.MessagesAfter),
+				formatDBDiffCount(c.ContextItemsBefore, c.ContextItemsAfter),
+				formatDBDiffCount(c.SummariesBefore, c.SummariesAfter),
+				strconv.Itoa(c.SummariesAdded),
+				strconv.Itoa(c.SummariesRemoved),
+				strconv.Itoa(c.SummariesChanged),
+			)
+		}
+		for _, line := range table.render(style) {
+			fmt.Fprintln(w, line)
+		}
+		if report.ConversationsOmitted > 0 {
+			fmt.Fprintf(w, "... %d more conversations (raise --limit, or --limit 0 for all)\n", report.ConversationsOmitted)
+		}
+	}
+
+	if len(report.Summaries) > 0 {
+		fmt.Fprintln(w)
+		table := cliTable{columns: []cliTableColumn{
+			{header: " "},
+			{header: "summary_id"},
+			{header: "conv_id", align: cliAlignRight},
+			{header: "depth", align: cliAlignRight},
+			{header: "tokens", align: cliAlignRight},
+			{header: "hash"},
+			{header: "note", flex: true},
+		}}
+		for _, s := range report.Summaries {
+			marker, tokens, hash, note := "~", formatDBDiffCount(s.TokensBefore, s.TokensAfter), s.HashBefore+" -> "+s.HashAfter, ""
+			switch s.Change {
+			case "added":
+				marker, tokens, hash = "+", strconv.Itoa(s.TokensAfter), s.HashAfter
+			case "removed":
+				marker, tokens, hash = "-", strconv.Itoa(s.TokensBefore), s.HashBefore
+			case "moved":
+				marker, hash = ">", s.HashAfter
+			}
+			if s.PreviousConversationID != 0 {
+				note = fmt.Sprintf("moved from conversation %d", s.PreviousConversationID)
+			}
+			table.addRow(marker, s.SummaryID, strconv.FormatInt(s.ConversationID, 10), strconv.Itoa(s.Depth), tokens, hash, note)
+		}
+		for _, line := range table.render(style) {
+			fmt.Fprintln(w, line)
+		}
+		if report.SummariesOmitted > 0 {
+			fmt.Fprintf(w, "... %d more summary changes (raise --limit, or --limit 0 for all)\n", report.SummariesOmitted)
+		}
+		fmt.Fprintln(w, "\n+ added  - removed  ~ content changed  > moved to another conversation")
+	}
+}
+
+// formatDBDiffCount shows a count as "n" when unchanged, else "old->new".
+func formatDBDiffCount(before, after int) string {
+	if before == after {
+		return strconv.Itoa(after)
+	}
+	return fmt.Sprintf("%d->%d", before, after)
+}
+
+func parseDBDiffArgs(args []string) (dbDiffOptions, string, string, error) {
+	fs := flag.NewFlagSet("db-diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conversationID := fs.Int64("conversation", 0, "only compare this conversation")
+	jsonOutput := fs.Bool("json", false, "print the diff as JSON")
+	limit := fs.Int("limit", defaultDBDiffLimit, "list at most n conversations and n summary changes (0 = all)")
+
+	normalized, err := normalizeDBDiffArgs(args)
+	if err != nil {
+		return dbDiffOptions{}, "", "", fmt.Errorf("%w\n%s", err, dbDiffUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return dbDiffOptions{}, "", "", errors.New(dbDiffUsageText())
+		}
+		return dbDiffOptions{}, "", "", fmt.Errorf("%w\n%s", err, dbDiffUsageText())
+	}
+	if fs.NArg() != 2 {
+		return dbDiffOptions{}, "", "", fmt.Errorf("old and new DB paths are required\n%s", dbDiffUsageText())
+	}
+	if *conversationID < 0 {
+		return dbDiffOptions{}, "", "", fmt.Errorf("--conversation must be a conversation ID\n%s", dbDiffUsageText())
+	}
+	if *limit < 0 {
+		return dbDiffOptions{}, "", "", fmt.Errorf("--limit must be >= 0\n%s", dbDiffUsageText())
+	}
+	oldPath := lcm.ExpandHomePath(strings.TrimSpace(fs.Arg(0)))
+	newPath := lcm.ExpandHomePath(strings.TrimSpace(fs.Arg(1)))
+	return dbDiffOptions{conversationID: *conversationID, jsonOutput: *jsonOutput, limit: *limit}, oldPath, newPath, nil
+}
+
+func normalizeDBDiffArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 2)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--conversation" || arg == "--limit":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func dbDiffUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui db-diff <old.db> <new.db> [--conversation <id>] [--limit <n>] [--json]
+
+Compares two lcm.db files, such as a --backup-db backup and the live DB, or
+copies taken before and after a plugin upgrade. Reports conversations added
+or removed, per-conversation message, context item, and summary counts, and
+every summary added, removed, moved to another conversation, or whose
+content changed (compared by SHA-256). Both files are opened read-only.
+
+Flags:
+  --conversation <id>   only compare this conversation
+  --limit <n>           list at most n conversations and n summary changes
+                        (default 50, 0 = all); totals always cover everything
+  --json                print the diff as JSON
+`)
+}
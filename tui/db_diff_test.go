@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDBDiffReportsSummaryAndCountChanges(t *testing.T) {
+	dir := t.TempDir()
+	makeDB := func(name string, statements ...string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatalf("open %s: %v", name, err)
+		}
+		defer db.Close()
+		setupBackfillTestSchema(t, db)
+		for _, statement := range statements {
+			mustExec(t, db, statement)
+		}
+		return path
+	}
+	base := []string{
+		`INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'one'), (2, 'two'), (3, 'three')`,
+		`INSERT INTO messages (conversation_id, seq, role, content, token_count, created_at)
+			VALUES (1, 1, 'user', 'hi', 1, '2026-01-01'), (2, 1, 'user', 'hey', 1, '2026-01-01')`,
+		`INSERT INTO context_items (conversation_id, ordinal, item_type, message_id) VALUES (1, 0, 'message', 1)`,
+	}
+	oldPath := makeDB("old.db", append(base,
+		`INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at) VALUES
+			('sum_keep', 1, 'leaf', 0, 'same', 10, '2026-01-01'),
+			('sum_edit', 1, 'leaf', 0, 'before', 10, '2026-01-01'),
+			('sum_gone', 1, 'leaf', 0, 'gone', 10, '2026-01-01'),
+			('sum_move', 2, 'leaf', 0, 'moving', 10, '2026-01-01')`)...)
+	newPath := makeDB("new.db", append(base,
+		`INSERT INTO conversations (conversation_id, session_id) VALUES (4, 'four')`,
+		`INSERT INTO messages (conversation_id, seq, role, content, token_count, created_at) VALUES (1, 2, 'assistant', 'yo', 1, '2026-01-01')`,
+		`INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at) VALUES
+			('sum_keep', 1, 'leaf', 0, 'same', 10, '2026-01-01'),
+			('sum_edit', 1, 'leaf', 0, 'after', 12, '2026-01-01'),
+			('sum_move', 3, 'leaf', 0, 'moving', 10, '2026-01-01'),
+			('sum_new', 4, 'condensed', 1, 'new', 20, '2026-01-01')`)...)
+
+	ctx := context.Background()
+	oldSnap, err := loadDBDiffSnapshotFile(ctx, oldPath, 0)
+	if err != nil {
+		t.Fatalf("load old snapshot: %v", err)
+	}
+	newSnap, err := loadDBDiffSnapshotFile(ctx, newPath, 0)
+	if err != nil {
+		t.Fatalf("load new snapshot: %v", err)
+	}
+	report := diffDBSnapshots(oldSnap, newSnap)
+
+	want := dbDiffTotals{
+		ConversationsBefore: 3, ConversationsAfter: 4, ConversationsAdded: 1, ConversationsChanged: 3,
+		SummariesBefore: 4, SummariesAfter: 4, SummariesAdded: 1, SummariesRemoved: 1, SummariesChanged: 1, SummariesMoved: 1,
+	}
+	if report.Totals != want {
+		t.Fatalf("totals = %+v, want %+v", report.Totals, want)
+	}
+	changes := make(map[string]string)
+	for _, s := range report.Summaries {
+		changes[s.SummaryID] = s.Change
+	}
+	if changes["sum_edit"] != "changed" || changes["sum_gone"] != "removed" || changes["sum_move"] != "moved" || changes["sum_new"] != "added" || changes["sum_keep"] != "" {
+		t.Fatalf("unexpected summary changes: %v", changes)
+	}
+	first := report.Conversations[0]
+	if first.ConversationID != 1 || first.MessagesBefore != 1 || first.MessagesAfter != 2 || first.SummariesChanged != 1 || first.SummariesRemoved != 1 {
+		t.Fatalf("conversation 1 = %+v", first)
+	}
+
+	report.limit(2)
+	if len(report.Summaries) != 2 || report.SummariesOmitted != 2 || len(report.Conversations) != 2 || report.ConversationsOmitted != 2 {
+		t.Fatalf("limit not applied: %d summaries (+%d), %d conversations (+%d)",
+			len(report.Summaries), report.SummariesOmitted, len(report.Conversations), report.ConversationsOmitted)
+	}
+	var out bytes.Buffer
+	printDBDiffReport(&out, report, cliOutputStyle{})
+	if !strings.Contains(out.String(), "2 more summary changes") {
+		t.Fatalf("expected an omitted-rows note, got:\n%s", out.String())
+	}
+
+	only, err := loadDBDiffSnapshotFile(ctx, newPath, 4)
+	if err != nil {
+		t.Fatalf("load filtered snapshot: %v", err)
+	}
+	if len(only.conversations) != 1 || len(only.summaries) != 1 {
+		t.Fatalf("--conversation 4 loaded %d conversations and %d summaries", len(only.conversations), len(only.summaries))
+	}
+
+	same := diffDBSnapshots(oldSnap, oldSnap)
+	if len(same.Conversations) != 0 || len(same.Summaries) != 0 {
+		t.Fatalf("a DB diffed against itself should show no changes, got %+v", same)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// lcmDBPathOverride is the global --db value, set once in main before any
+// command runs. Empty means no override.
+var lcmDBPathOverride string
+
+// extractDBPathFlag removes the global --db <path> (or --db=<path>) from args
+// so every command and the TUI accept it in any position without declaring it.
+// The last occurrence wins.
+func extractDBPathFlag(args []string) ([]string, string, error) {
+	rest := make([]string, 0, len(args))
+	dbPath := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--db" || arg == "-db":
+			if i+1 >= len(args) || strings.TrimSpace(args[i+1]) == "" {
+				return nil, "", fmt.Errorf("missing value for %s", arg)
+			}
+			dbPath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--db="), strings.HasPrefix(arg, "-db="):
+			_, value, _ := strings.Cut(arg, "=")
+			if strings.TrimSpace(value) == "" {
+				return nil, "", fmt.Errorf("missing value for --db")
+			}
+			dbPath = value
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, strings.TrimSpace(dbPath), nil
+}
+
+// resolveLCMDBPath picks the summary DB independently of the state directory:
+// --db, then LCM_DATABASE_PATH (as the plugin reads it), then
+// <stateDir>/lcm.db. source names the override, or "" for the default.
+func resolveLCMDBPath(stateDir string) (path, source string) {
+	if lcmDBPathOverride != "" {
+		return lcm.ExpandHomePath(lcmDBPathOverride), "--db"
+	}
+	if value := strings.TrimSpace(os.Getenv("LCM_DATABASE_PATH")); value != "" {
+		return lcm.ExpandHomePath(value), "LCM_DATABASE_PATH"
+	}
+	return filepath.Join(stateDir, "lcm.db"), ""
+}
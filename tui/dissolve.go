@@ -7,14 +7,25 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"strconv"
+	"sort"
 	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 type dissolveOptions struct {
 	summaryID string
 	apply     bool
 	purge     bool // delete the condensed summary record too
+	// simulate previews dissolving summaryIDs (or every condensed summary
+	// at depth) in sequence without writing.
+	simulate   bool
+	summaryIDs []string
+	depth      int  // -1 when unset
+	backupDB   bool // copy lcm.db before --apply writes
+	// session is --session: the conversation named by its session ID,
+	// resolved once the DB is open.
+	session conversationSession
 }
 
 type dissolveTarget struct {
@@ -41,8 +52,15 @@ type dissolvePlan struct {
 	totalParentTokens int
 	itemsToShift      int
 	shift             int
+	// ordinalCheck is the result of simulating the apply against current
+	// context_items ordinals; nil means they stay exactly 0..N-1.
+	ordinalCheck error
 }
 
+// errContextOrdinalGap marks context_items ordinals that are not exactly
+// 0..N-1 for a conversation.
+var errContextOrdinalGap = errors.New("context_items ordinals are not contiguous")
+
 // runDissolveCommand executes the standalone dissolve CLI path.
 func runDissolveCommand(args []string) error {
 	opts, conversationID, err := parseDissolveArgs(args)
@@ -62,6 +80,26 @@ func runDissolveCommand(args []string) error {
 	defer db.Close()
 
 	ctx := context.Background()
+	conversationID, err = opts.session.resolve(db, conversationID)
+	if err != nil {
+		return err
+	}
+
+	if opts.simulate {
+		summaryIDs := opts.summaryIDs
+		if opts.depth >= 0 {
+			summaryIDs, err = loadDissolveDepthTargets(ctx, db, conversationID, opts.depth)
+			if err != nil {
+				return err
+			}
+		}
+		sim, err := simulateDissolveChain(ctx, db, conversationID, summaryIDs)
+		if err != nil {
+			return err
+		}
+		printDissolveChainSimulation(conversationID, sim)
+		return nil
+	}
 
 	plan, err := buildDissolvePlan(ctx, db, conversationID, opts.summaryID)
 	if err != nil {
@@ -81,12 +119,16 @@ func runDissolveCommand(args []string) error {
 	fmt.Printf("\nToken impact: %dt condensed → %dt restored (%+dt)\n",
 		plan.target.tokenCount, plan.totalParentTokens, plan.totalParentTokens-plan.target.tokenCount)
 	fmt.Printf("Ordinal shift: %d items after ordinal %d will shift by +%d\n", plan.itemsToShift, plan.target.ordinal, plan.shift)
+	fmt.Println("Ordinal check: " + formatDissolveOrdinalCheck(plan))
 
 	if !opts.apply {
 		fmt.Println("\nDry run. Use --apply to execute.")
 		return nil
 	}
 
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "dissolve", opts.backupDB, nil); err != nil {
+		return err
+	}
 	fmt.Println("\nApplying...")
 	newCount, err := applyDissolvePlan(ctx, db, plan, opts.purge)
 	if err != nil {
@@ -98,7 +140,7 @@ func runDissolveCommand(args []string) error {
 
 // buildDissolvePlan validates a condensed target and computes preview stats
 // (restored parents, token impact, and ordinal shifts) without mutating DB state.
-func buildDissolvePlan(ctx context.Context, db *sql.DB, conversationID int64, summaryID string) (dissolvePlan, error) {
+func buildDissolvePlan(ctx context.Context, db sqlQueryer, conversationID int64, summaryID string) (dissolvePlan, error) {
 	target, err := loadDissolveTarget(ctx, db, conversationID, summaryID)
 	if err != nil {
 		return dissolvePlan{}, err
@@ -126,13 +168,88 @@ func buildDissolvePlan(ctx context.Context, db *sql.DB, conversationID int64, su
 		return dissolvePlan{}, fmt.Errorf("count items to shift: %w", err)
 	}
 
-	return dissolvePlan{
+	ordinals, err := loadContextOrdinals(ctx, db, conversationID)
+	if err != nil {
+		return dissolvePlan{}, err
+	}
+
+	plan := dissolvePlan{
 		target:            target,
 		parents:           parents,
 		totalParentTokens: totalParentTokens,
 		itemsToShift:      itemsToShift,
 		shift:             len(parents) - 1,
-	}, nil
+	}
+	plan.ordinalCheck = checkContiguousOrdinals(simulateDissolveOrdinals(ordinals, plan))
+	return plan, nil
+}
+
+// simulateDissolveOrdinals replays applyDissolvePlan's ordinal math on a copy
+// of the current ordinals: drop the target, shift later items, and insert the
+// restored parents at the target's position.
+func simulateDissolveOrdinals(ordinals []int64, plan dissolvePlan) []int64 {
+	result := make([]int64, 0, len(ordinals)+len(plan.parents))
+	for _, ordinal := range ordinals {
+		switch {
+		case ordinal == plan.target.ordinal:
+			continue
+		case ordinal > plan.target.ordinal:
+			result = append(result, ordinal+int64(plan.shift))
+		default:
+			result = append(result, ordinal)
+		}
+	}
+	for i := range plan.parents {
+		result = append(result, plan.target.ordinal+int64(i))
+	}
+	return result
+}
+
+// checkContiguousOrdinals verifies ordinals are exactly 0..N-1 in any order.
+func checkContiguousOrdinals(ordinals []int64) error {
+	sorted := append([]int64(nil), ordinals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, ordinal := range sorted {
+		if ordinal != int64(i) {
+			if i > 0 && ordinal == sorted[i-1] {
+				return fmt.Errorf("%w: duplicate ordinal %d", errContextOrdinalGap, ordinal)
+			}
+			return fmt.Errorf("%w: expected ordinal %d, found %d", errContextOrdinalGap, i, ordinal)
+		}
+	}
+	return nil
+}
+
+func loadContextOrdinals(ctx context.Context, q sqlQueryer, conversationID int64) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT ordinal FROM context_items
+		WHERE conversation_id = ?
+		ORDER BY ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query context ordinals for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var ordinals []int64
+	for rows.Next() {
+		var ordinal int64
+		if err := rows.Scan(&ordinal); err != nil {
+			return nil, fmt.Errorf("scan context ordinal: %w", err)
+		}
+		ordinals = append(ordinals, ordinal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate context ordinals: %w", err)
+	}
+	return ordinals, nil
+}
+
+func formatDissolveOrdinalCheck(plan dissolvePlan) string {
+	if plan.ordinalCheck != nil {
+		return fmt.Sprintf("WARNING: %v; --apply will roll back", plan.ordinalCheck)
+	}
+	return fmt.Sprintf("ok (ordinals stay contiguous, %d items after dissolve)", plan.itemsToShift+int(plan.target.ordinal)+len(plan.parents))
 }
 
 // applyDissolvePlan performs the transactional context rewrite from a dry-run plan.
@@ -148,16 +265,66 @@ func applyDissolvePlan(ctx context.Context, db *sql.DB, plan dissolvePlan, purge
 		}
 	}()
 
+	if err := replaceDissolveContextItem(ctx, tx, plan); err != nil {
+		return 0, err
+	}
+
+	if purge {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM summary_parents WHERE summary_id = ?
+		`, plan.target.summaryID)
+		if err != nil {
+			return 0, fmt.Errorf("delete summary_parents for %s: %w", plan.target.summaryID, err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM summaries WHERE summary_id = ?
+		`, plan.target.summaryID)
+		if err != nil {
+			return 0, fmt.Errorf("delete summary record %s: %w", plan.target.summaryID, err)
+		}
+	}
+
+	summaryIDs := []string{plan.target.summaryID}
+	for _, parent := range plan.parents {
+		summaryIDs = append(summaryIDs, parent.summaryID)
+	}
+	detail := fmt.Sprintf("ordinal %d -> %d parents", plan.target.ordinal, len(plan.parents))
+	if purge {
+		detail += "; purged " + plan.target.summaryID
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "dissolve", ConversationID: plan.target.conversationID, SummaryIDs: summaryIDs,
+		TokensBefore: plan.target.tokenCount, TokensAfter: plan.totalParentTokens, Detail: detail,
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	rollback = false
+
+	var newCount int
+	_ = db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM context_items WHERE conversation_id = ?
+	`, plan.target.conversationID).Scan(&newCount)
+	return newCount, nil
+}
+
+// replaceDissolveContextItem swaps the target's context item for its parents
+// inside tx and refuses a result whose ordinals are not contiguous. --apply
+// and --simulate both run it, so a simulated chain sees each step's effect.
+func replaceDissolveContextItem(ctx context.Context, tx sqlQueryer, plan dissolvePlan) error {
 	res, err := tx.ExecContext(ctx, `
 		DELETE FROM context_items
 		WHERE conversation_id = ? AND ordinal = ? AND summary_id = ?
 	`, plan.target.conversationID, plan.target.ordinal, plan.target.summaryID)
 	if err != nil {
-		return 0, fmt.Errorf("delete condensed context_item: %w", err)
+		return fmt.Errorf("delete condensed context_item: %w", err)
 	}
 	deleted, _ := res.RowsAffected()
 	if deleted != 1 {
-		return 0, fmt.Errorf("expected to delete 1 context_item, deleted %d", deleted)
+		return fmt.Errorf("expected to delete 1 context_item, deleted %d", deleted)
 	}
 
 	if plan.shift > 0 {
@@ -168,7 +335,7 @@ func applyDissolvePlan(ctx context.Context, db *sql.DB, plan dissolvePlan, purge
 			WHERE conversation_id = ? AND ordinal > ?
 		`, tempOffset, plan.target.conversationID, plan.target.ordinal)
 		if err != nil {
-			return 0, fmt.Errorf("shift items to temp ordinals: %w", err)
+			return fmt.Errorf("shift items to temp ordinals: %w", err)
 		}
 
 		_, err = tx.ExecContext(ctx, `
@@ -177,7 +344,7 @@ func applyDissolvePlan(ctx context.Context, db *sql.DB, plan dissolvePlan, purge
 			WHERE conversation_id = ? AND ordinal >= ?
 		`, tempOffset, plan.shift, plan.target.conversationID, tempOffset)
 		if err != nil {
-			return 0, fmt.Errorf("shift items to final ordinals: %w", err)
+			return fmt.Errorf("shift items to final ordinals: %w", err)
 		}
 	}
 
@@ -188,35 +355,19 @@ func applyDissolvePlan(ctx context.Context, db *sql.DB, plan dissolvePlan, purge
 			VALUES (?, ?, 'summary', ?, datetime('now'))
 		`, plan.target.conversationID, newOrdinal, parent.summaryID)
 		if err != nil {
-			return 0, fmt.Errorf("insert parent %s at ordinal %d: %w", parent.summaryID, newOrdinal, err)
+			return fmt.Errorf("insert parent %s at ordinal %d: %w", parent.summaryID, newOrdinal, err)
 		}
 	}
 
-	if purge {
-		_, err = tx.ExecContext(ctx, `
-			DELETE FROM summary_parents WHERE summary_id = ?
-		`, plan.target.summaryID)
-		if err != nil {
-			return 0, fmt.Errorf("delete summary_parents for %s: %w", plan.target.summaryID, err)
-		}
-		_, err = tx.ExecContext(ctx, `
-			DELETE FROM summaries WHERE summary_id = ?
-		`, plan.target.summaryID)
-		if err != nil {
-			return 0, fmt.Errorf("delete summary record %s: %w", plan.target.summaryID, err)
-		}
+	// Guardrail: never commit a context with gaps or duplicate ordinals.
+	ordinals, err := loadContextOrdinals(ctx, tx, plan.target.conversationID)
+	if err != nil {
+		return err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("commit: %w", err)
+	if err := checkContiguousOrdinals(ordinals); err != nil {
+		return fmt.Errorf("dissolve of %s rolled back: %w", plan.target.summaryID, err)
 	}
-	rollback = false
-
-	var newCount int
-	_ = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM context_items WHERE conversation_id = ?
-	`, plan.target.conversationID).Scan(&newCount)
-	return newCount, nil
+	return nil
 }
 
 func parseDissolveArgs(args []string) (dissolveOptions, int64, error) {
@@ -226,6 +377,11 @@ func parseDissolveArgs(args []string) (dissolveOptions, int64, error) {
 	summaryID := fs.String("summary-id", "", "summary ID to dissolve (required)")
 	apply := fs.Bool("apply", false, "apply changes to the DB")
 	purge := fs.Bool("purge", true, "delete the condensed summary record from DB (use --purge=false to keep)")
+	simulate := fs.Bool("simulate", false, "preview dissolving several summaries in sequence without writing")
+	depth := fs.Int("depth", -1, "with --simulate, dissolve every condensed summary at this depth in context")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+	session := fs.String("session", "", "dissolve in the conversation recorded under this session ID")
+	conversationIndex := fs.Int("conversation-index", 0, "with --session, which of the session's conversations (1 = newest)")
 
 	// Normalize: pull positional args out so flags parse correctly regardless of order
 	normalized, err := normalizeDissolveArgs(args)
@@ -236,24 +392,49 @@ func parseDissolveArgs(args []string) (dissolveOptions, int64, error) {
 		return dissolveOptions{}, 0, fmt.Errorf("%w\n%s", err, dissolveUsageText())
 	}
 
-	if strings.TrimSpace(*summaryID) == "" {
+	if *simulate {
+		if *apply {
+			return dissolveOptions{}, 0, fmt.Errorf("--simulate cannot be combined with --apply\n%s", dissolveUsageText())
+		}
+		if (strings.TrimSpace(*summaryID) == "") == (*depth < 0) {
+			return dissolveOptions{}, 0, fmt.Errorf("--simulate requires exactly one of --summary-id or --depth\n%s", dissolveUsageText())
+		}
+	} else if *depth >= 0 {
+		return dissolveOptions{}, 0, fmt.Errorf("--depth requires --simulate\n%s", dissolveUsageText())
+	}
+	if strings.TrimSpace(*summaryID) == "" && !*simulate {
 		return dissolveOptions{}, 0, fmt.Errorf("--summary-id is required\n%s", dissolveUsageText())
 	}
 
-	if fs.NArg() != 1 {
-		return dissolveOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", dissolveUsageText())
+	sessionTarget, err := newConversationSession(*session, *conversationIndex)
+	if err != nil {
+		return dissolveOptions{}, 0, fmt.Errorf("%w\n%s", err, dissolveUsageText())
 	}
-
-	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	conversationID, err := sessionTarget.conversationArg(fs.Args())
 	if err != nil {
-		return dissolveOptions{}, 0, fmt.Errorf("parse conversation ID %q: %w\n%s", fs.Arg(0), err, dissolveUsageText())
+		return dissolveOptions{}, 0, fmt.Errorf("%w\n%s", err, dissolveUsageText())
 	}
 
-	return dissolveOptions{
+	opts := dissolveOptions{
 		summaryID: strings.TrimSpace(*summaryID),
 		apply:     *apply,
 		purge:     *purge,
-	}, conversationID, nil
+		simulate:  *simulate,
+		depth:     *depth,
+		session:   sessionTarget,
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return dissolveOptions{}, 0, err
+	}
+	if opts.simulate {
+		for _, id := range strings.Split(opts.summaryID, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				opts.summaryIDs = append(opts.summaryIDs, id)
+			}
+		}
+	}
+	return opts, conversationID, nil
 }
 
 func normalizeDissolveArgs(args []string) ([]string, error) {
@@ -263,13 +444,14 @@ func normalizeDissolveArgs(args []string) ([]string, error) {
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch {
-		case arg == "--apply" || arg == "--purge":
+		case arg == "--apply" || arg == "--purge" || arg == "--simulate" || arg == "--backup-db":
 			flags = append(flags, arg)
-		case strings.HasPrefix(arg, "--summary-id="):
+		case strings.HasPrefix(arg, "--summary-id="), strings.HasPrefix(arg, "--depth="),
+			strings.HasPrefix(arg, "--session="), strings.HasPrefix(arg, "--conversation-index="):
 			flags = append(flags, arg)
-		case arg == "--summary-id":
+		case arg == "--summary-id" || arg == "--depth" || arg == "--session" || arg == "--conversation-index":
 			if i+1 >= len(args) {
-				return nil, errors.New("missing value for --summary-id")
+				return nil, errors.New("missing value for " + arg)
 			}
 			flags = append(flags, arg, args[i+1])
 			i++
@@ -285,7 +467,10 @@ func normalizeDissolveArgs(args []string) ([]string, error) {
 func dissolveUsageText() string {
 	return strings.TrimSpace(`
 Usage:
-  lcm-tui dissolve <conversation_id> --summary-id <id> [--apply] [--purge]
+  lcm-tui dissolve <conversation_id> --summary-id <id> [--apply] [--purge] [--backup-db]
+  lcm-tui dissolve <conversation_id> --simulate --summary-id <id>[,<id>...]
+  lcm-tui dissolve <conversation_id> --simulate --depth <n>
+  lcm-tui dissolve --session <session_id> [--conversation-index <n>] --summary-id <id> [--apply]
 
 Dissolve a condensed summary back into its constituent parent summaries
 in the active context. Restores the parents as individual context_items
@@ -295,10 +480,21 @@ Flags:
   --summary-id <id>   Condensed summary to dissolve (required)
   --apply             Execute changes (default: dry run)
   --purge             Also delete the condensed summary record from DB
+  --simulate          Preview dissolving the comma-separated --summary-id list
+                      (or --depth) in sequence: per-node and running token and
+                      context item totals. Never writes.
+  --depth <n>         With --simulate, every condensed summary at depth n in context
+  --backup-db         With --apply, copy lcm.db to a timestamped backup first
+  --session <id>      Use the conversation recorded under this session ID (the
+                      JSONL file name) instead of a conversation ID
+  --conversation-index <n>
+                      With --session, the nth of the session's conversations,
+                      newest first as lcm-tui conversations lists them; required
+                      when a reset left several
 `)
 }
 
-func loadDissolveTarget(ctx context.Context, db *sql.DB, conversationID int64, summaryID string) (dissolveTarget, error) {
+func loadDissolveTarget(ctx context.Context, db sqlQueryer, conversationID int64, summaryID string) (dissolveTarget, error) {
 	var target dissolveTarget
 	err := db.QueryRowContext(ctx, `
 		SELECT
@@ -334,7 +530,7 @@ func loadDissolveTarget(ctx context.Context, db *sql.DB, conversationID int64, s
 	return target, nil
 }
 
-func loadDissolveParents(ctx context.Context, db *sql.DB, summaryID string) ([]dissolveParent, error) {
+func loadDissolveParents(ctx context.Context, db sqlQueryer, summaryID string) ([]dissolveParent, error) {
 	rows, err := db.QueryContext(ctx, `
 		SELECT
 			sp.parent_summary_id,
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// dissolveChainStep is one simulated dissolve with its running totals.
+type dissolveChainStep struct {
+	summaryID      string
+	depth          int
+	tokenCount     int
+	parents        int
+	restoredTokens int
+	items          int // context items after this step
+	tokens         int // context tokens after this step
+}
+
+func (s dissolveChainStep) delta() int {
+	return s.restoredTokens - s.tokenCount
+}
+
+// dissolveChainSimulation is the cumulative effect of dissolving several
+// condensed summaries in sequence.
+type dissolveChainSimulation struct {
+	startItems  int
+	startTokens int
+	steps       []dissolveChainStep
+}
+
+// simulateDissolveChain runs buildDissolvePlan and the dissolve context
+// rewrite for each summary in turn inside a transaction that is always rolled
+// back, so a later step may dissolve a parent restored by an earlier one and
+// every step is validated exactly as --apply would. Nothing is written.
+func simulateDissolveChain(ctx context.Context, db *sql.DB, conversationID int64, summaryIDs []string) (dissolveChainSimulation, error) {
+	items, err := lcm.LoadContextItems(ctx, db, conversationID)
+	if err != nil {
+		return dissolveChainSimulation{}, err
+	}
+	sim := dissolveChainSimulation{startItems: len(items)}
+	for _, item := range items {
+		sim.startTokens += item.TokenCount
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return dissolveChainSimulation{}, fmt.Errorf("begin simulation transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	itemCount, tokens := sim.startItems, sim.startTokens
+	for i, summaryID := range summaryIDs {
+		plan, err := buildDissolvePlan(ctx, tx, conversationID, summaryID)
+		if err != nil {
+			return dissolveChainSimulation{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if err := replaceDissolveContextItem(ctx, tx, plan); err != nil {
+			return dissolveChainSimulation{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+
+		step := dissolveChainStep{
+			summaryID:      summaryID,
+			depth:          plan.target.depth,
+			tokenCount:     plan.target.tokenCount,
+			parents:        len(plan.parents),
+			restoredTokens: plan.totalParentTokens,
+		}
+		itemCount += step.parents - 1
+		tokens += step.delta()
+		step.items = itemCount
+		step.tokens = tokens
+		sim.steps = append(sim.steps, step)
+	}
+	return sim, nil
+}
+
+// loadDissolveDepthTargets lists the condensed summaries at depth currently in
+// context, in ordinal order.
+func loadDissolveDepthTargets(ctx context.Context, q sqlQueryer, conversationID int64, depth int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT ci.summary_id
+		FROM context_items ci
+		JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ? AND s.kind = 'condensed' AND s.depth = ?
+		ORDER BY ci.ordinal ASC
+	`, conversationID, depth)
+	if err != nil {
+		return nil, fmt.Errorf("query d%d context summaries for conversation %d: %w", depth, conversationID, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan d%d context summary: %w", depth, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate d%d context summaries: %w", depth, err)
+	}
+	return ids, nil
+}
+
+func printDissolveChainSimulation(conversationID int64, sim dissolveChainSimulation) {
+	fmt.Printf("Simulated dissolve chain for conversation %d (nothing written)\n", conversationID)
+	fmt.Printf("Start: %d context items, %d tokens\n\n", sim.startItems, sim.startTokens)
+	table := cliTable{
+		indent: "  ",
+		columns: []cliTableColumn{
+			{header: "#", align: cliAlignRight},
+			{header: "summary", flex: true},
+			{header: "depth"},
+			{header: "tokens", align: cliAlignRight},
+			{header: "parents", align: cliAlignRight},
+			{header: "restored", align: cliAlignRight},
+			{header: "delta", align: cliAlignRight, sgr: cliSGRYellow},
+			{header: "items", align: cliAlignRight},
+			{header: "total", align: cliAlignRight},
+		},
+	}
+	for i, step := range sim.steps {
+		table.addRow(strconv.Itoa(i+1), step.summaryID, fmt.Sprintf("d%d", step.depth), fmt.Sprintf("%dt", step.tokenCount),
+			strconv.Itoa(step.parents), fmt.Sprintf("%dt", step.restoredTokens), fmt.Sprintf("%+dt", step.delta()),
+			strconv.Itoa(step.items), fmt.Sprintf("%dt", step.tokens))
+	}
+	for _, line := range table.render(resolveCLIOutputStyle()) {
+		fmt.Println(line)
+	}
+	if len(sim.steps) == 0 {
+		fmt.Println("  (no summaries to dissolve)")
+		return
+	}
+	last := sim.steps[len(sim.steps)-1]
+	fmt.Printf("\nTotal: %d items (%+d), %d tokens (%+dt)\n", last.items, last.items-sim.startItems, last.tokens, last.tokens-sim.startTokens)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// seedDissolveConversation puts condensed sum_top (built from three leaves)
+// in context between a message and another summary, at the given ordinals.
+func seedDissolveConversation(t *testing.T, db *sql.DB, ordinals [3]int) {
+	t.Helper()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-dissolve', 'Dissolve')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES (1, 1, 1, 'user', 'hello', 1, '2026-03-22T10:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_a', 1, 'leaf', 0, 'a', 10, '2026-03-22T10:00:00Z'),
+			('sum_b', 1, 'leaf', 0, 'b', 10, '2026-03-22T10:01:00Z'),
+			('sum_c', 1, 'leaf', 0, 'c', 10, '2026-03-22T10:02:00Z'),
+			('sum_top', 1, 'condensed', 1, 'top', 12, '2026-03-22T10:03:00Z'),
+			('sum_next', 1, 'leaf', 0, 'next', 10, '2026-03-22T10:04:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_top', 'sum_a', 0), ('sum_top', 'sum_b', 1), ('sum_top', 'sum_c', 2)
+	`)
+	if _, err := db.Exec(`
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES (1, ?, 'message', 1, NULL), (1, ?, 'summary', NULL, 'sum_top'), (1, ?, 'summary', NULL, 'sum_next')
+	`, ordinals[0], ordinals[1], ordinals[2]); err != nil {
+		t.Fatalf("seed context items: %v", err)
+	}
+}
+
+func TestApplyDissolvePlanKeepsOrdinalsContiguous(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	seedDissolveConversation(t, db, [3]int{0, 1, 2})
+
+	plan, err := buildDissolvePlan(ctx, db, 1, "sum_top")
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if plan.ordinalCheck != nil {
+		t.Fatalf("expected simulated ordinals to be contiguous, got %v", plan.ordinalCheck)
+	}
+	if !strings.Contains(formatDissolveOrdinalCheck(plan), "5 items") {
+		t.Fatalf("unexpected ordinal check summary %q", formatDissolveOrdinalCheck(plan))
+	}
+
+	newCount, err := applyDissolvePlan(ctx, db, plan, true)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if newCount != 5 {
+		t.Fatalf("new count = %d, want 5", newCount)
+	}
+	ordinals, err := loadContextOrdinals(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("load ordinals: %v", err)
+	}
+	if err := checkContiguousOrdinals(ordinals); err != nil {
+		t.Fatalf("ordinals after dissolve: %v (%v)", err, ordinals)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 4 AND summary_id = 'sum_next'`, 1)
+}
+
+func TestApplyDissolvePlanRollsBackOnOrdinalGap(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	// A pre-existing gap after the target survives the shift.
+	seedDissolveConversation(t, db, [3]int{0, 1, 3})
+
+	plan, err := buildDissolvePlan(ctx, db, 1, "sum_top")
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if !errors.Is(plan.ordinalCheck, errContextOrdinalGap) {
+		t.Fatalf("expected simulated gap, got %v", plan.ordinalCheck)
+	}
+
+	if _, err := applyDissolvePlan(ctx, db, plan, true); !errors.Is(err, errContextOrdinalGap) {
+		t.Fatalf("expected ordinal gap error, got %v", err)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE summary_id = 'sum_top' AND ordinal = 1`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_top'`, 1)
+}
+
+func TestCheckContiguousOrdinals(t *testing.T) {
+	if err := checkContiguousOrdinals([]int64{2, 0, 1}); err != nil {
+		t.Fatalf("expected unordered 0..2 to pass, got %v", err)
+	}
+	if err := checkContiguousOrdinals(nil); err != nil {
+		t.Fatalf("expected empty context to pass, got %v", err)
+	}
+	if err := checkContiguousOrdinals([]int64{0, 1, 1}); err == nil || !strings.Contains(err.Error(), "duplicate ordinal 1") {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+	if err := checkContiguousOrdinals([]int64{1, 2}); err == nil || !strings.Contains(err.Error(), "expected ordinal 0, found 1") {
+		t.Fatalf("expected gap error, got %v", err)
+	}
+}
+
+func TestSimulateDissolveChainTracksRunningTotals(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	seedDissolveConversation(t, db, [3]int{0, 1, 2})
+
+	// Fold sum_top and sum_next into a d2 node that alone sits in context
+	// after the message.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_d2', 1, 'condensed', 2, 'arc', 20, '2026-03-22T10:05:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_d2', 'sum_top', 0), ('sum_d2', 'sum_next', 1)
+	`)
+	mustExec(t, db, `DELETE FROM context_items WHERE conversation_id = 1 AND ordinal > 0`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+		VALUES (1, 1, 'summary', 'sum_d2')
+	`)
+
+	targets, err := loadDissolveDepthTargets(ctx, db, 1, 2)
+	if err != nil {
+		t.Fatalf("load depth targets: %v", err)
+	}
+	if strings.Join(targets, ",") != "sum_d2" {
+		t.Fatalf("d2 targets = %v, want [sum_d2]", targets)
+	}
+
+	sim, err := simulateDissolveChain(ctx, db, 1, []string{"sum_d2", "sum_top"})
+	if err != nil {
+		t.Fatalf("simulate: %v", err)
+	}
+	if sim.startItems != 2 || sim.startTokens != 21 || len(sim.steps) != 2 {
+		t.Fatalf("unexpected simulation %+v", sim)
+	}
+	first, second := sim.steps[0], sim.steps[1]
+	if first.delta() != 2 || first.items != 3 || first.tokens != 23 {
+		t.Fatalf("first step = %+v, want +2t to 3 items / 23 tokens", first)
+	}
+	if second.parents != 3 || second.delta() != 18 || second.items != 5 || second.tokens != 41 {
+		t.Fatalf("second step = %+v, want +18t to 5 items / 41 tokens", second)
+	}
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1`, 2)
+
+	if _, err := simulateDissolveChain(ctx, db, 1, []string{"sum_top"}); err == nil || !strings.Contains(err.Error(), "not found in active context") {
+		t.Fatalf("expected sum_top to be outside the starting context, got %v", err)
+	}
+}
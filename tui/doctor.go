@@ -6,15 +6,17 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 const (
-	doctorOldMarker          = corruptedSummaryMarker
+	doctorOldMarker          = lcm.CorruptedSummaryMarker
 	doctorNewMarkerPrefix    = "[Truncated from "
 	doctorLCMFallbackMarker  = "[LCM fallback summary; truncated for context management]"
 	doctorNewMarkerWindow    = 40
@@ -33,6 +35,17 @@ type doctorOptions struct {
 	baseURL    string
 	showDiff   bool
 	timestamps bool
+	limit      int
+	offset     int
+	wrapWidth  int
+	redactor   *sourceRedactor
+	backupDB   bool
+	// tokens is --min-tokens/--max-tokens; only broken summaries whose
+	// token_count falls in the range are scanned or repaired.
+	tokens lcm.TokenRange
+	// overallTimeout is --overall-timeout: the wall-clock budget for an
+	// --all scan; 0 means no limit.
+	overallTimeout time.Duration
 }
 
 type doctorTarget struct {
@@ -59,6 +72,9 @@ type doctorScanReport struct {
 	totalCount    int
 	oldCount      int
 	newCount      int
+	// scannedCount is how many conversations scanDoctorConversationsWithin
+	// got through, with or without findings.
+	scannedCount int
 }
 
 type doctorSummarizer interface {
@@ -98,18 +114,44 @@ func runDoctorCommand(args []string) error {
 		if hasConversationID {
 			conversationFilter = &conversationID
 		}
-		report, err := scanDoctorConversations(ctx, db, conversationFilter)
+		var (
+			report     doctorScanReport
+			unfinished []int64
+		)
+		if opts.overallTimeout > 0 {
+			scanCtx, cancel := context.WithTimeout(ctx, opts.overallTimeout)
+			defer cancel()
+			report, unfinished, err = scanDoctorConversationsWithin(scanCtx, db, opts.tokens)
+		} else {
+			report, err = scanDoctorConversations(ctx, db, conversationFilter, opts.tokens)
+		}
 		if err != nil {
 			return err
 		}
+		scanned := report.scannedCount
+		if opts.limit > 0 || opts.offset > 0 {
+			ids := make([]int64, 0, len(report.conversations))
+			for _, row := range report.conversations {
+				ids = append(ids, row.conversationID)
+			}
+			batch := selectConversationBatch(ids, opts.offset, opts.limit)
+			fmt.Println(formatConversationBatch(batch, len(ids), opts.offset, false))
+			report = limitDoctorScanReport(report, opts.offset, opts.limit)
+		}
 		printDoctorScanReport(report, hasConversationID)
+		if len(unfinished) > 0 {
+			printOverallTimeoutStop(os.Stdout, opts.overallTimeout, scanned, unfinished)
+		}
 		return nil
 	}
 
-	plan, err := buildDoctorPlan(ctx, db, conversationID)
+	plan, err := buildDoctorPlan(ctx, db, conversationID, opts.tokens)
 	if err != nil {
 		return err
 	}
+	if opts.tokens.Active() {
+		fmt.Printf("Token filter %s matched %d broken summaries.\n", opts.tokens, len(plan.targets))
+	}
 	if len(plan.targets) == 0 {
 		fmt.Printf("No broken summaries found in conversation %d.\n", conversationID)
 		return nil
@@ -119,6 +161,7 @@ func runDoctorCommand(args []string) error {
 	opts.provider = settings.provider
 	opts.model = settings.model
 	opts.baseURL = settings.baseURL
+	printStubSummarizerNotice(opts.provider)
 
 	fmt.Printf("Doctor found %d broken summaries in conversation %d.\n", len(plan.targets), conversationID)
 	printDoctorPlan(plan)
@@ -145,12 +188,15 @@ func runDoctorCommand(args []string) error {
 		summarizer = &anthropicClient{
 			provider: opts.provider,
 			apiKey:   apiKey,
-			http:     &http.Client{Timeout: defaultHTTPTimeout},
+			http:     newSummaryHTTPClient(defaultHTTPTimeout),
 			model:    opts.model,
 			baseURL:  opts.baseURL,
 		}
 	}
 
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "doctor", opts.apply && opts.backupDB, nil); err != nil {
+		return err
+	}
 	rewritten, err := executeDoctorPlan(ctx, db, plan, opts, summarizer)
 	if err != nil {
 		return err
@@ -173,8 +219,17 @@ func parseDoctorArgs(args []string) (doctorOptions, int64, bool, error) {
 	provider := fs.String("provider", "", "provider id (e.g. anthropic, openai)")
 	model := fs.String("model", "", "summary model id")
 	baseURL := fs.String("base-url", "", "custom API base URL")
+	stub := fs.Bool("stub", false, "use the deterministic stub summarizer (demos/tests only)")
 	showDiff := fs.Bool("show-diff", false, "show unified diff for each fix")
 	timestamps := fs.Bool("timestamps", false, "inject timestamps into the rewrite source")
+	limit := fs.Int("limit", 0, "with --all, report at most n conversations")
+	offset := fs.Int("offset", 0, "with --all, skip the first n conversations with findings")
+	minTokens, maxTokens := addSummaryTokenRangeFlags(fs, "fix")
+	wrap := fs.Bool("wrap", true, "word-wrap printed OLD/NEW content")
+	width := fs.Int("width", 0, "wrap width for printed content (default: terminal width, else 100)")
+	redact := fs.Bool("redact", false, "redact secrets from source text before it is sent to the summary API")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+	overallTimeout := fs.Duration("overall-timeout", 0, "with --all, stop the scan cleanly after this much wall-clock time (0 = no limit)")
 
 	normalizedArgs, err := normalizeDoctorArgs(args)
 	if err != nil {
@@ -184,6 +239,11 @@ func parseDoctorArgs(args []string) (doctorOptions, int64, bool, error) {
 		return doctorOptions{}, 0, false, fmt.Errorf("%w\n%s", err, doctorUsageText())
 	}
 
+	stubProvider, err := resolveStubProviderFlag(*stub, strings.TrimSpace(*provider))
+	if err != nil {
+		return doctorOptions{}, 0, false, fmt.Errorf("%w\n%s", err, doctorUsageText())
+	}
+
 	opts := doctorOptions{
 		apply:      *apply,
 		summary:    *summary || *all,
@@ -191,13 +251,45 @@ func parseDoctorArgs(args []string) (doctorOptions, int64, bool, error) {
 		baseURL:    strings.TrimSpace(*baseURL),
 		showDiff:   *showDiff,
 		timestamps: *timestamps,
+		limit:      *limit,
+		offset:     *offset,
+		tokens:     lcm.TokenRange{Min: *minTokens, Max: *maxTokens},
+
+		overallTimeout: *overallTimeout,
 	}
-	opts.provider = strings.TrimSpace(*provider)
+	opts.provider = stubProvider
 	opts.model = strings.TrimSpace(*model)
 
+	if opts.limit < 0 || opts.offset < 0 {
+		return doctorOptions{}, 0, false, fmt.Errorf("--limit and --offset must be >= 0\n%s", doctorUsageText())
+	}
+	if err := validateSummaryTokenRange(opts.tokens); err != nil {
+		return doctorOptions{}, 0, false, fmt.Errorf("%w\n%s", err, doctorUsageText())
+	}
+	if *width < 0 {
+		return doctorOptions{}, 0, false, fmt.Errorf("--width must be >= 0\n%s", doctorUsageText())
+	}
+	opts.wrapWidth = resolveCLIWrapWidth(*wrap, *width)
+	opts.redactor, err = resolveSourceRedactor(*redact)
+	if err != nil {
+		return doctorOptions{}, 0, false, fmt.Errorf("%w\n%s", err, doctorUsageText())
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return doctorOptions{}, 0, false, fmt.Errorf("%w\n%s", err, doctorUsageText())
+	}
+	if !opts.all && (opts.limit > 0 || opts.offset > 0) {
+		return doctorOptions{}, 0, false, fmt.Errorf("--limit and --offset require --all\n%s", doctorUsageText())
+	}
 	if opts.apply && opts.summary {
 		return doctorOptions{}, 0, false, fmt.Errorf("--apply cannot be combined with scan-only flags\n%s", doctorUsageText())
 	}
+	if opts.overallTimeout < 0 {
+		return doctorOptions{}, 0, false, fmt.Errorf("--overall-timeout must be >= 0\n%s", doctorUsageText())
+	}
+	if opts.overallTimeout > 0 && !opts.all {
+		return doctorOptions{}, 0, false, fmt.Errorf("--overall-timeout requires --all\n%s", doctorUsageText())
+	}
 
 	hasConversationID := fs.NArg() == 1
 	if fs.NArg() > 1 {
@@ -226,7 +318,8 @@ func normalizeDoctorArgs(args []string) ([]string, error) {
 
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
-		takesValue := arg == "--provider" || arg == "--model" || arg == "--base-url"
+		takesValue := arg == "--provider" || arg == "--model" || arg == "--base-url" || arg == "--limit" || arg == "--offset" || arg == "--width" ||
+			arg == "--min-tokens" || arg == "--max-tokens" || arg == "--overall-timeout"
 		if takesValue {
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("missing value for %s", arg)
@@ -235,11 +328,13 @@ func normalizeDoctorArgs(args []string) ([]string, error) {
 			i++
 			continue
 		}
-		if strings.HasPrefix(arg, "--provider=") || strings.HasPrefix(arg, "--model=") || strings.HasPrefix(arg, "--base-url=") {
+		if strings.HasPrefix(arg, "--provider=") || strings.HasPrefix(arg, "--model=") || strings.HasPrefix(arg, "--base-url=") ||
+			strings.HasPrefix(arg, "--limit=") || strings.HasPrefix(arg, "--offset=") || strings.HasPrefix(arg, "--width=") ||
+			strings.HasPrefix(arg, "--min-tokens=") || strings.HasPrefix(arg, "--max-tokens=") || strings.HasPrefix(arg, "--overall-timeout=") {
 			flags = append(flags, arg)
 			continue
 		}
-		if arg == "--apply" || arg == "--summary" || arg == "--all" || arg == "--show-diff" || arg == "--timestamps" {
+		if arg == "--apply" || arg == "--summary" || arg == "--all" || arg == "--show-diff" || arg == "--timestamps" || arg == "--redact" || arg == "--backup-db" {
 			flags = append(flags, arg)
 			continue
 		}
@@ -263,21 +358,36 @@ Flags:
   --apply             write repaired summaries to the DB
   --summary           scan only and show counts
   --all               scan all conversations (discovery mode only)
+  --limit <n>         with --all, report at most n conversations
+  --offset <n>        with --all, skip the first n conversations with findings
+  --min-tokens <n>    only scan or fix broken summaries with token_count >= n
+  --max-tokens <n>    only scan or fix broken summaries with token_count <= n
+  --overall-timeout <d>
+                      with --all, wall-clock budget for the scan, e.g. 10m; stops cleanly
+                      and lists the conversations not yet scanned
   --provider <id>     API provider (default: anthropic)
   --model <model>     API model (default: claude-haiku-4-5)
   --base-url <url>    custom API base URL (overrides config and env)
+  --stub              use the deterministic stub summarizer (demos/tests only)
   --show-diff         show unified diff for each fix
+  --width <n>         wrap printed OLD/NEW content at n columns (default: terminal width, else 100)
+  --wrap=false        print OLD/NEW content without wrapping
   --timestamps        inject timestamps into rewrite source text
+  --redact            replace secrets in source text with [REDACTED:<kind>] before it is sent
+  --backup-db         with --apply, copy lcm.db to a timestamped backup first
 
 Env:
   LCM_TUI_SUMMARY_PROVIDER / LCM_TUI_SUMMARY_MODEL / LCM_TUI_SUMMARY_BASE_URL
   fall back to LCM_SUMMARY_PROVIDER / LCM_SUMMARY_MODEL / LCM_SUMMARY_BASE_URL
+  LCM_SUMMARIZER=stub uses the deterministic stub summarizer (no API calls)
+  LCM_TUI_REDACT=1 turns on --redact for every command
+  LCM_TUI_BACKUP_DB=1 turns on --backup-db for every command
 `)
 }
 
 // buildDoctorPlan keeps the repair order bottom-up so parent rewrites can consume repaired children.
-func buildDoctorPlan(ctx context.Context, q sqlQueryer, conversationID int64) (doctorPlan, error) {
-	targets, err := loadDoctorTargets(ctx, q, &conversationID)
+func buildDoctorPlan(ctx context.Context, q sqlQueryer, conversationID int64, tokens lcm.TokenRange) (doctorPlan, error) {
+	targets, err := loadDoctorTargets(ctx, q, &conversationID, tokens)
 	if err != nil {
 		return doctorPlan{}, err
 	}
@@ -323,6 +433,9 @@ func buildDoctorPlan(ctx context.Context, q sqlQueryer, conversationID int64) (d
 		if orphanLeaves[i].createdAt != orphanLeaves[j].createdAt {
 			return orphanLeaves[i].createdAt < orphanLeaves[j].createdAt
 		}
+		if orphanLeaves[i].createdSeq != orphanLeaves[j].createdSeq {
+			return orphanLeaves[i].createdSeq < orphanLeaves[j].createdSeq
+		}
 		return orphanLeaves[i].summaryID < orphanLeaves[j].summaryID
 	})
 	sort.Slice(condensed, func(i, j int) bool {
@@ -334,6 +447,9 @@ func buildDoctorPlan(ctx context.Context, q sqlQueryer, conversationID int64) (d
 		if left.createdAt != right.createdAt {
 			return left.createdAt < right.createdAt
 		}
+		if left.createdSeq != right.createdSeq {
+			return left.createdSeq < right.createdSeq
+		}
 		return left.summaryID < right.summaryID
 	})
 
@@ -348,8 +464,8 @@ func buildDoctorPlan(ctx context.Context, q sqlQueryer, conversationID int64) (d
 	}, nil
 }
 
-func scanDoctorConversations(ctx context.Context, q sqlQueryer, conversationID *int64) (doctorScanReport, error) {
-	targets, err := loadDoctorTargets(ctx, q, conversationID)
+func scanDoctorConversations(ctx context.Context, q sqlQueryer, conversationID *int64, tokens lcm.TokenRange) (doctorScanReport, error) {
+	targets, err := loadDoctorTargets(ctx, q, conversationID, tokens)
 	if err != nil {
 		return doctorScanReport{}, err
 	}
@@ -383,7 +499,57 @@ func scanDoctorConversations(ctx context.Context, q sqlQueryer, conversationID *
 	return report, nil
 }
 
-func loadDoctorTargets(ctx context.Context, q sqlQueryer, conversationID *int64) ([]doctorTarget, error) {
+// scanDoctorConversationsWithin scans the conversations that have summaries
+// one at a time, in ID order, until ctx is done. It returns the findings so
+// far and the conversations it did not reach, so an --overall-timeout scan
+// stops cleanly instead of failing.
+func scanDoctorConversationsWithin(ctx context.Context, q sqlQueryer, tokens lcm.TokenRange) (doctorScanReport, []int64, error) {
+	rows, err := q.QueryContext(ctx, `SELECT DISTINCT conversation_id FROM summaries ORDER BY conversation_id ASC`)
+	if err != nil {
+		return doctorScanReport{}, nil, fmt.Errorf("query doctor conversations: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return doctorScanReport{}, nil, fmt.Errorf("scan doctor conversation ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return doctorScanReport{}, nil, fmt.Errorf("iterate doctor conversations: %w", err)
+	}
+
+	report := doctorScanReport{conversations: make([]doctorConversationScan, 0, 8)}
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			return report, ids[i:], nil
+		}
+		part, err := scanDoctorConversations(ctx, q, &id, tokens)
+		if err != nil {
+			if ctx.Err() != nil {
+				return report, ids[i:], nil
+			}
+			return doctorScanReport{}, nil, err
+		}
+		report.conversations = append(report.conversations, part.conversations...)
+		report.totalCount += part.totalCount
+		report.oldCount += part.oldCount
+		report.newCount += part.newCount
+		report.scannedCount++
+	}
+	return report, nil, nil
+}
+
+func loadDoctorTargets(ctx context.Context, q sqlQueryer, conversationID *int64, tokens lcm.TokenRange) ([]doctorTarget, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	seq := caps.SummarySeqExpr("s")
 	query := `
 		SELECT
 			s.summary_id,
@@ -393,6 +559,7 @@ func loadDoctorTargets(ctx context.Context, q sqlQueryer, conversationID *int64)
 			COALESCE(s.token_count, 0),
 			COALESCE(s.content, ''),
 			COALESCE(s.created_at, ''),
+			` + seq + `,
 			COALESCE(spc.child_count, 0),
 			CASE
 				WHEN INSTR(COALESCE(s.content, ''), ?) = 1 THEN 'old'
@@ -427,9 +594,13 @@ func loadDoctorTargets(ctx context.Context, q sqlQueryer, conversationID *int64)
 				AND LENGTH(COALESCE(s.content, '')) - INSTR(COALESCE(s.content, ''), ?) < ` + strconv.Itoa(doctorFallbackWindow) + `
 			)
 		)
-		ORDER BY s.conversation_id ASC, COALESCE(s.depth, 0) ASC, s.created_at ASC, s.summary_id ASC
 	`
 	args = append(args, doctorOldMarker, doctorNewMarkerPrefix, doctorNewMarkerPrefix, doctorLCMFallbackMarker, doctorLCMFallbackMarker)
+	tokenClause, tokenArgs := tokens.Clause("s.token_count")
+	query += tokenClause + `
+		ORDER BY s.conversation_id ASC, COALESCE(s.depth, 0) ASC, s.created_at ASC, ` + seq + ` ASC, s.summary_id ASC
+	`
+	args = append(args, tokenArgs...)
 
 	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -451,6 +622,7 @@ func loadDoctorTargets(ctx context.Context, q sqlQueryer, conversationID *int64)
 			&item.tokenCount,
 			&item.content,
 			&item.createdAt,
+			&item.createdSeq,
 			&item.childCount,
 			&item.markerKind,
 		); err != nil {
@@ -539,30 +711,36 @@ func executeDoctorPlan(ctx context.Context, db *sql.DB, plan doctorPlan, opts do
 	}()
 
 	rewritten := 0
+	redactions := lcm.RedactionCounts{}
 	for idx, item := range plan.ordered {
 		fmt.Printf("\n[%d/%d] %s (%s marker, d%d, %s)\n", idx+1, len(plan.ordered), item.summaryID, item.markerKind, item.depth, item.kind)
 
-		source, err := buildSummaryRewriteSource(ctx, tx, item.rewriteSummary, opts.timestamps, time.Local)
+		leaf := item.depth == 0 || strings.EqualFold(item.kind, "leaf")
+		source, err := lcm.BuildRewriteSource(ctx, tx, item.summaryID, leaf, lcm.RewriteSourceOptions{Timestamps: opts.timestamps, Location: time.Local})
 		if err != nil {
 			return rewritten, fmt.Errorf("build source for %s: %w", item.summaryID, err)
 		}
+		if counts := source.Redact(opts.redactor); counts.Total() > 0 {
+			fmt.Printf("Redacted: %s\n", counts)
+			redactions.Add(counts)
+		}
 		previousContext, err := resolveRewritePreviousContext(ctx, tx, item.rewriteSummary)
 		if err != nil {
 			return rewritten, fmt.Errorf("resolve previous context for %s: %w", item.summaryID, err)
 		}
 
-		targetTokens := condensedTargetTokens
+		targetTokens := lcm.CondensedTargetTokens
 		if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-			targetTokens = calculateLeafTargetTokens(source.estimatedTokens)
+			targetTokens = lcm.LeafTargetTokens(source.EstimatedTokens)
 		}
 
-		prompt, err := renderPrompt(item.depth, PromptVars{
+		prompt, err := lcm.RenderPrompt(item.depth, lcm.PromptVars{
 			TargetTokens:    targetTokens,
 			PreviousContext: previousContext,
-			ChildCount:      source.itemCount,
-			TimeRange:       source.timeRange,
+			ChildCount:      source.ItemCount,
+			TimeRange:       source.TimeRange,
 			Depth:           item.depth,
-			SourceText:      source.text,
+			SourceText:      source.Text,
 		}, doctorDefaultApplyPrompt)
 		if err != nil {
 			return rewritten, fmt.Errorf("render prompt for %s: %w", item.summaryID, err)
@@ -572,17 +750,15 @@ func executeDoctorPlan(ctx context.Context, db *sql.DB, plan doctorPlan, opts do
 		if err != nil {
 			return rewritten, fmt.Errorf("rewrite %s: %w", item.summaryID, err)
 		}
-		newTokens := estimateTokenCount(newContent)
+		newTokens := lcm.EstimateTokenCount(newContent)
 		if newTokens == 0 && strings.TrimSpace(newContent) != "" {
 			newTokens = 1
 		}
 
-		printRewriteReport(item.rewriteSummary, source, item.content, newContent, item.tokenCount, newTokens)
+		printRewriteReport(item.rewriteSummary, source, item.content, newContent, item.tokenCount, newTokens, opts.wrapWidth)
 		if opts.showDiff {
 			diff := buildUnifiedDiff("old/"+item.summaryID, "new/"+item.summaryID, item.content, newContent)
-			for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
-				fmt.Println(colorizeDiffLineCLI(line))
-			}
+			printUnifiedDiffCLI(os.Stdout, diff, resolveCLIOutputStyle())
 		}
 
 		if _, err := tx.ExecContext(ctx, `
@@ -592,6 +768,12 @@ func executeDoctorPlan(ctx context.Context, db *sql.DB, plan doctorPlan, opts do
 		`, newContent, newTokens, item.summaryID); err != nil {
 			return rewritten, fmt.Errorf("update summary %s: %w", item.summaryID, err)
 		}
+		if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+			Command: "doctor", ConversationID: item.conversationID, SummaryIDs: []string{item.summaryID},
+			TokensBefore: item.tokenCount, TokensAfter: newTokens, Detail: item.markerKind + " marker",
+		}); err != nil {
+			return rewritten, err
+		}
 		rewritten++
 	}
 
@@ -601,6 +783,7 @@ func executeDoctorPlan(ctx context.Context, db *sql.DB, plan doctorPlan, opts do
 		}
 		rollbackNeeded = false
 	}
+	printRedactionTotal(opts.redactor, redactions)
 	return rewritten, nil
 }
 
@@ -622,6 +805,26 @@ func printDoctorPlan(plan doctorPlan) {
 	}
 }
 
+// limitDoctorScanReport keeps the same offset/limit window of conversations
+// as selectConversationBatch and recomputes the report totals for it.
+func limitDoctorScanReport(report doctorScanReport, offset, limit int) doctorScanReport {
+	limited := doctorScanReport{}
+	if offset >= len(report.conversations) {
+		return limited
+	}
+	end := len(report.conversations)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	limited.conversations = report.conversations[offset:end]
+	for _, row := range limited.conversations {
+		limited.totalCount += row.totalCount
+		limited.oldCount += row.oldCount
+		limited.newCount += row.newCount
+	}
+	return limited
+}
+
 func printDoctorScanReport(report doctorScanReport, scoped bool) {
 	if report.totalCount == 0 {
 		fmt.Println("No broken summaries found.")
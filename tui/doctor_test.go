@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 func TestLoadDoctorTargetsUsesPositionAwareDetection(t *testing.T) {
@@ -26,7 +33,7 @@ func TestLoadDoctorTargetsUsesPositionAwareDetection(t *testing.T) {
 	`, doctorOldMarker, doctorOldMarker, doctorNewMarkerPrefix, doctorNewMarkerPrefix))
 
 	conversationID := int64(1)
-	targets, err := loadDoctorTargets(ctx, db, &conversationID)
+	targets, err := loadDoctorTargets(ctx, db, &conversationID, lcm.TokenRange{})
 	if err != nil {
 		t.Fatalf("loadDoctorTargets: %v", err)
 	}
@@ -52,6 +59,58 @@ func TestLoadDoctorTargetsUsesPositionAwareDetection(t *testing.T) {
 	}
 }
 
+func TestRepairAndDoctorLoadersHonorTokenRange(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title, created_at, updated_at)
+		VALUES (1, 'session-token-range', 'Tokens', datetime('now'), datetime('now'))
+	`)
+	mustExec(t, db, fmt.Sprintf(`
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES
+			('sum_small', 1, 'leaf', 0, '%[1]s small', 100, '2026-03-22T10:00:00Z', '[]'),
+			('sum_mid', 1, 'leaf', 0, '%[1]s mid', 1500, '2026-03-22T10:01:00Z', '[]'),
+			('sum_big', 1, 'leaf', 0, '%[1]s big', 5000, '2026-03-22T10:02:00Z', '[]')
+	`, doctorOldMarker))
+	mustExec(t, db, `UPDATE summaries SET content = content || ' [LCM fallback summary; truncated for context management]'`)
+
+	opts, _, _, err := parseDoctorArgs([]string{"1", "--min-tokens", "1000", "--max-tokens", "2000"})
+	if err != nil {
+		t.Fatalf("parse doctor args: %v", err)
+	}
+	conversationID := int64(1)
+	targets, err := loadDoctorTargets(ctx, db, &conversationID, opts.tokens)
+	if err != nil {
+		t.Fatalf("loadDoctorTargets: %v", err)
+	}
+	if len(targets) != 1 || targets[0].summaryID != "sum_mid" {
+		t.Fatalf("doctor targets = %+v, want only sum_mid", targets)
+	}
+
+	repairOpts, _, err := parseRepairArgs([]string{"1", "--min-tokens=1000"})
+	if err != nil {
+		t.Fatalf("parse repair args: %v", err)
+	}
+	plan, err := lcm.BuildRepairPlan(ctx, db, 1, "", repairOpts.markers, repairOpts.tokens)
+	if err != nil {
+		t.Fatalf("build repair plan: %v", err)
+	}
+	summaries := plan.Summaries
+	got := make([]string, 0, len(summaries))
+	for _, item := range summaries {
+		got = append(got, item.SummaryID)
+	}
+	if strings.Join(got, ",") != "sum_mid,sum_big" {
+		t.Fatalf("repair targets = %v, want sum_mid,sum_big", got)
+	}
+
+	if _, _, err := parseRepairArgs([]string{"1", "--min-tokens", "3000", "--max-tokens", "2000"}); err == nil || !strings.Contains(err.Error(), "--min-tokens must be <= --max-tokens") {
+		t.Fatalf("expected range validation error, got %v", err)
+	}
+}
+
 func TestBuildDoctorPlanOrdersBottomUp(t *testing.T) {
 	db := newBackfillTestDB(t)
 	ctx := context.Background()
@@ -79,7 +138,7 @@ func TestBuildDoctorPlanOrdersBottomUp(t *testing.T) {
 			('condensed_d2', 'condensed_d1', 0)
 	`)
 
-	plan, err := buildDoctorPlan(ctx, db, 7)
+	plan, err := buildDoctorPlan(ctx, db, 7, lcm.TokenRange{})
 	if err != nil {
 		t.Fatalf("buildDoctorPlan: %v", err)
 	}
@@ -128,7 +187,7 @@ func TestExecuteDoctorPlanDryRunDoesNotModifyDB(t *testing.T) {
 			(11, 1, 'summary', 'parent_fix', '2026-03-22T11:11:00Z')
 	`)
 
-	plan, err := buildDoctorPlan(ctx, db, 11)
+	plan, err := buildDoctorPlan(ctx, db, 11, lcm.TokenRange{})
 	if err != nil {
 		t.Fatalf("buildDoctorPlan: %v", err)
 	}
@@ -163,7 +222,7 @@ func TestScanDoctorConversationsAggregatesAllConversations(t *testing.T) {
 			('conv23_false', 23, 'leaf', 0, 'This summary talks about %s but is healthy.', 90, '2026-03-22T12:02:00Z', '[]')
 	`, doctorNewMarkerPrefix, doctorOldMarker, doctorOldMarker))
 
-	report, err := scanDoctorConversations(ctx, db, nil)
+	report, err := scanDoctorConversations(ctx, db, nil, lcm.TokenRange{})
 	if err != nil {
 		t.Fatalf("scanDoctorConversations: %v", err)
 	}
@@ -178,6 +237,58 @@ func TestScanDoctorConversationsAggregatesAllConversations(t *testing.T) {
 	}
 }
 
+func TestScanDoctorConversationsWithinStopsAtDeadline(t *testing.T) {
+	db := newBackfillTestDB(t)
+
+	for _, id := range []int64{21, 22, 23} {
+		seedDoctorConversation(t, db, id)
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+			VALUES ('conv%d_old', %d, 'leaf', 0, '%s standalone corruption', 90, '2026-03-22T12:00:00Z', '[]')
+		`, id, id, doctorOldMarker))
+	}
+
+	report, unfinished, err := scanDoctorConversationsWithin(context.Background(), db, lcm.TokenRange{})
+	if err != nil {
+		t.Fatalf("scan without deadline: %v", err)
+	}
+	if len(unfinished) != 0 || report.totalCount != 3 || report.scannedCount != 3 {
+		t.Fatalf("full scan: total %d, scanned %d, unfinished %v", report.totalCount, report.scannedCount, unfinished)
+	}
+
+	// The deadline passes once conversation 21 has been scanned: the
+	// conversation list, then its schema probe and target query.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := &cancelAfterQueryer{sqlQueryer: db, remaining: 3, cancel: cancel}
+	report, unfinished, err = scanDoctorConversationsWithin(ctx, q, lcm.TokenRange{})
+	if err != nil {
+		t.Fatalf("scan with deadline: %v", err)
+	}
+	if fmt.Sprint(unfinished) != "[22 23]" {
+		t.Fatalf("unfinished = %v, want [22 23]", unfinished)
+	}
+	if report.scannedCount != 1 || report.totalCount != 1 || report.conversations[0].conversationID != 21 {
+		t.Fatalf("partial scan: scanned %d, total %d, conversations %+v", report.scannedCount, report.totalCount, report.conversations)
+	}
+}
+
+// cancelAfterQueryer lets remaining queries run, then cancels the context
+// as the next one starts, as if the deadline passed between them.
+type cancelAfterQueryer struct {
+	sqlQueryer
+	remaining int
+	cancel    context.CancelFunc
+}
+
+func (q *cancelAfterQueryer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if q.remaining == 0 {
+		q.cancel()
+	}
+	q.remaining--
+	return q.sqlQueryer.QueryContext(ctx, query, args...)
+}
+
 type stubDoctorSummarizer struct {
 	results []string
 	calls   int
@@ -207,3 +318,279 @@ func assertSummaryContent(t *testing.T, db *sql.DB, summaryID, want string) {
 		t.Fatalf("unexpected summary content for %s: got=%q want=%q", summaryID, got, want)
 	}
 }
+
+func TestSelectConversationBatchWindowsAndReportsResumeOffset(t *testing.T) {
+	t.Parallel()
+
+	ids := []int64{3, 7, 9, 12, 20}
+	batch := selectConversationBatch(ids, 1, 2)
+	if fmt.Sprint(batch) != "[7 9]" {
+		t.Fatalf("batch = %v, want [7 9]", batch)
+	}
+	if got, want := formatConversationBatch(batch, len(ids), 1, false), "Batch: conversations 7-9 (items 2-3 of 5 matching); resume with --offset 3"; got != want {
+		t.Fatalf("batch report = %q, want %q", got, want)
+	}
+	if got := formatConversationBatch(batch, len(ids), 1, true); !strings.HasSuffix(got, "resume with --offset 1") {
+		t.Fatalf("consumed batch report = %q, want resume at the same offset", got)
+	}
+	if tail := selectConversationBatch(ids, 3, 0); fmt.Sprint(tail) != "[12 20]" {
+		t.Fatalf("unlimited tail = %v, want [12 20]", tail)
+	}
+	if past := selectConversationBatch(ids, 9, 2); len(past) != 0 {
+		t.Fatalf("offset past end = %v, want empty", past)
+	}
+
+	report := doctorScanReport{conversations: []doctorConversationScan{
+		{conversationID: 3, totalCount: 2, oldCount: 2},
+		{conversationID: 7, totalCount: 1, newCount: 1},
+		{conversationID: 9, totalCount: 4, oldCount: 1, newCount: 3},
+	}}
+	limited := limitDoctorScanReport(report, 1, 5)
+	if len(limited.conversations) != 2 || limited.totalCount != 5 || limited.oldCount != 1 || limited.newCount != 4 {
+		t.Fatalf("limited report = %+v, want conversations 7 and 9 with totals 5/1/4", limited)
+	}
+}
+
+func TestParseRepairArgsRequiresAllForBatchFlags(t *testing.T) {
+	t.Parallel()
+
+	if _, _, err := parseRepairArgs([]string{"44", "--limit", "10"}); err == nil || !strings.Contains(err.Error(), "--limit and --offset require --all") {
+		t.Fatalf("expected --all requirement error, got %v", err)
+	}
+	opts, _, err := parseRepairArgs([]string{"--all", "--limit=10", "--offset", "20"})
+	if err != nil {
+		t.Fatalf("parse repair batch args: %v", err)
+	}
+	if opts.limit != 10 || opts.offset != 20 {
+		t.Fatalf("limit/offset = %d/%d, want 10/20", opts.limit, opts.offset)
+	}
+}
+
+func TestParseRepairArgsRejectsJSONWithApply(t *testing.T) {
+	t.Parallel()
+
+	if _, _, err := parseRepairArgs([]string{"44", "--apply", "--json"}); err == nil || !strings.Contains(err.Error(), "--json is only supported for dry runs") {
+		t.Fatalf("expected --json/--apply error, got %v", err)
+	}
+	opts, _, err := parseRepairArgs([]string{"--json", "44"})
+	if err != nil || !opts.json || !opts.dryRun {
+		t.Fatalf("parse --json = %+v (%v), want json dry run", opts, err)
+	}
+}
+
+func TestWriteRepairDryRunJSONListsSummariesAndOrder(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title, created_at, updated_at)
+		VALUES (1, 'session-repair-json', 'Repair JSON', datetime('now'), datetime('now'))
+	`)
+	mustExec(t, db, fmt.Sprintf(`
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES
+			('sum_leaf', 1, 'leaf', 0, 'leaf %s', 40, '2026-03-22T10:00:00Z', '[]'),
+			('sum_ok', 1, 'leaf', 0, 'healthy leaf', 30, '2026-03-22T10:01:00Z', '[]'),
+			('sum_top', 1, 'condensed', 1, 'top %s', 90, '2026-03-22T10:02:00Z', '[]')
+	`, lcm.CorruptedSummaryMarker, lcm.CorruptedSummaryMarker))
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_top', 'sum_leaf', 0), ('sum_top', 'sum_ok', 1)
+	`)
+
+	var out bytes.Buffer
+	if err := writeRepairDryRunJSON(ctx, db, &out, []int64{1}, "", lcm.DefaultCorruptedSummaryMarkers, lcm.TokenRange{}); err != nil {
+		t.Fatalf("writeRepairDryRunJSON: %v", err)
+	}
+	var report repairDryRunJSON
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out.String())
+	}
+	if report.Total != 2 || len(report.Conversations) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	conv := report.Conversations[0]
+	if strings.Join(conv.RepairOrder, ",") != "sum_leaf,sum_top" {
+		t.Fatalf("repair order = %v, want leaf before condensed", conv.RepairOrder)
+	}
+	byID := make(map[string]repairSummaryJSON, len(conv.Summaries))
+	for _, item := range conv.Summaries {
+		byID[item.SummaryID] = item
+	}
+	top := byID["sum_top"]
+	if top.Kind != "condensed" || top.Depth != 1 || top.TokenCount != 90 || top.ChildCount != 2 || top.RepairPosition != 2 {
+		t.Fatalf("unexpected condensed entry %+v", top)
+	}
+	if leaf := byID["sum_leaf"]; leaf.ContentLength != len("leaf "+lcm.CorruptedSummaryMarker) || leaf.RepairPosition != 1 {
+		t.Fatalf("unexpected leaf entry %+v", leaf)
+	}
+}
+
+func TestLoadCorruptedSummariesMatchesAnyMarker(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title, created_at, updated_at)
+		VALUES (1, 'session-repair-markers', 'Repair markers', datetime('now'), datetime('now'))
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES
+			('sum_current', 1, 'leaf', 0, 'a [LCM fallback summary; truncated for context management]', 10, '2026-03-22T10:00:00Z', '[]'),
+			('sum_directive', 1, 'leaf', 0, '[LCM fallback summary; directive-shaped untrusted content omitted] b', 10, '2026-03-22T10:01:00Z', '[]'),
+			('sum_custom', 1, 'leaf', 0, 'SUMMARY UNAVAILABLE 100%', 10, '2026-03-22T10:02:00Z', '[]'),
+			('sum_ok', 1, 'leaf', 0, 'summary unavailable in lower case is fine', 10, '2026-03-22T10:03:00Z', '[]')
+	`)
+
+	markerFile := filepath.Join(t.TempDir(), "markers.txt")
+	if err := os.WriteFile(markerFile, []byte("# known-bad output\n\nUNAVAILABLE 100%\n"), 0o644); err != nil {
+		t.Fatalf("write marker file: %v", err)
+	}
+	opts, _, err := parseRepairArgs([]string{"1", "--marker", "SUMMARY UNAVAILABLE", "--marker-file", markerFile})
+	if err != nil {
+		t.Fatalf("parse repair args: %v", err)
+	}
+	if got := len(opts.markers); got != len(lcm.DefaultCorruptedSummaryMarkers)+2 {
+		t.Fatalf("markers = %q, want built-ins plus two custom", opts.markers)
+	}
+
+	plan, err := lcm.BuildRepairPlan(ctx, db, 1, "", opts.markers, opts.tokens)
+	if err != nil {
+		t.Fatalf("build repair plan: %v", err)
+	}
+	summaries := plan.Summaries
+	got := make(map[string]string, len(summaries))
+	for _, item := range summaries {
+		got[item.SummaryID] = item.Marker
+	}
+	want := map[string]string{
+		"sum_current":   lcm.CorruptedSummaryMarker,
+		"sum_directive": "[LCM fallback summary; directive-shaped untrusted content omitted]",
+		"sum_custom":    "SUMMARY UNAVAILABLE",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("matched %v, want %v", got, want)
+	}
+	for id, marker := range want {
+		if got[id] != marker {
+			t.Fatalf("%s matched marker %q, want %q", id, got[id], marker)
+		}
+	}
+
+	ids, err := resolveRepairConversationIDs(ctx, db, repairOptions{all: true, markers: []string{"UNAVAILABLE 100%"}}, 0)
+	if err != nil {
+		t.Fatalf("resolve conversations: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("conversation ids = %v, want [1]", ids)
+	}
+}
+
+func TestValidateCondensedHeadingsRequiresAllInOrder(t *testing.T) {
+	valid := "## Goals & Context\nship it\n**Key Decisions:**\nuse sqlite\nProgress\ndone\nConstraints\nnone\nCritical Details\nids\nFiles: none"
+	if err := validateCondensedHeadings(valid); err != nil {
+		t.Fatalf("expected decorated headings to validate, got %v", err)
+	}
+
+	missing := "Goals & Context\nx\nKey Decisions\nx\nProgress\nx\nFiles: none"
+	if err := validateCondensedHeadings(missing); err == nil || !strings.Contains(err.Error(), "Constraints, Critical Details") {
+		t.Fatalf("expected missing headings error, got %v", err)
+	}
+
+	reordered := "Key Decisions\nx\nGoals & Context\nx\nProgress\nConstraints\nCritical Details\nFiles"
+	if err := validateCondensedHeadings(reordered); err == nil || !strings.Contains(err.Error(), "appears before") {
+		t.Fatalf("expected order error, got %v", err)
+	}
+
+	if err := validateCondensedHeadings("Progress on Goals & Context was slow\nKey Decisions\nProgress\nConstraints\nCritical Details\nFiles"); err == nil {
+		t.Fatal("expected prose mentioning a heading not to count as the heading")
+	}
+}
+
+func TestSummarizeCondensedRepairRetriesThenHonorsStrict(t *testing.T) {
+	valid := strings.Join(condensedSummaryHeadings, "\n")
+	calls := 0
+	summarize := func(context.Context, string, int) (string, error) {
+		calls++
+		if calls == 2 {
+			return valid, nil
+		}
+		return "no structure", nil
+	}
+	content, err := summarizeCondensedRepair(context.Background(), io.Discard, summarize, "prompt", 100, true)
+	if err != nil || content != valid || calls != 2 {
+		t.Fatalf("expected retry to recover, got content=%q calls=%d err=%v", content, calls, err)
+	}
+
+	calls = 0
+	alwaysBad := func(context.Context, string, int) (string, error) {
+		calls++
+		return "no structure", nil
+	}
+	if _, err := summarizeCondensedRepair(context.Background(), io.Discard, alwaysBad, "prompt", 100, true); err == nil {
+		t.Fatal("expected strict mode to fail")
+	}
+	if calls != condensedHeadingRetries+1 {
+		t.Fatalf("expected %d attempts, got %d", condensedHeadingRetries+1, calls)
+	}
+
+	content, err = summarizeCondensedRepair(context.Background(), io.Discard, alwaysBad, "prompt", 100, false)
+	if err != nil || content != "no structure" {
+		t.Fatalf("expected lenient mode to return last attempt, got %q, %v", content, err)
+	}
+}
+
+func TestApplyRepairsCommitEachKeepsEarlierRepairs(t *testing.T) {
+	for _, commitEach := range []bool{false, true} {
+		t.Run(fmt.Sprintf("commitEach=%v", commitEach), func(t *testing.T) {
+			db := newBackfillTestDB(t)
+			ctx := context.Background()
+
+			mustExec(t, db, `
+				INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-commit-each', 'Commit each')
+			`)
+			mustExec(t, db, `
+				INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+				VALUES (1, 1, 1, 'user', 'please ship the release notes', 6, '2026-03-22T10:00:00Z')
+			`)
+			// The condensed summary has no linked children, so its repair fails
+			// after the leaf has been repaired.
+			mustExec(t, db, fmt.Sprintf(`
+				INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+				VALUES
+					('sum_leaf', 1, 'leaf', 0, '%s', 10, '2026-03-22T10:00:00Z', '[]'),
+					('sum_top', 1, 'condensed', 1, '%s', 10, '2026-03-22T10:05:00Z', '[]')
+			`, lcm.CorruptedSummaryMarker, lcm.CorruptedSummaryMarker))
+			mustExec(t, db, `INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('sum_leaf', 1, 0)`)
+
+			plan, err := lcm.BuildRepairPlan(ctx, db, 1, "", lcm.DefaultCorruptedSummaryMarkers, lcm.TokenRange{})
+			if err != nil {
+				t.Fatalf("build plan: %v", err)
+			}
+			client := &anthropicClient{provider: stubSummaryProvider}
+			report := newRunReport(filepath.Join(t.TempDir(), "report.json"), "repair", nil, true, 1)
+			repaired, err := applyRepairs(ctx, db, plan, repairOptions{commitEach: commitEach, report: report}, client)
+			if err == nil || !strings.Contains(err.Error(), "no child summaries") {
+				t.Fatalf("expected condensed repair failure, got %v", err)
+			}
+			if repaired != 1 {
+				t.Fatalf("repaired = %d, want 1", repaired)
+			}
+
+			want := 0
+			if commitEach {
+				want = 1
+			}
+			assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_leaf' AND content LIKE '[STUB SUMMARY%'`, want)
+
+			wantAction := "rolled_back"
+			if commitEach {
+				wantAction = "repaired"
+			}
+			if len(report.Summaries) != 1 || report.Summaries[0].Action != wantAction || report.StoppedAt != "sum_top" {
+				t.Fatalf("unexpected report entries %+v stopped at %q", report.Summaries, report.StoppedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// summaryExplainNode is one summary in the lineage tree below a context item.
+type summaryExplainNode struct {
+	summaryID  string
+	kind       string
+	depth      int
+	tokenCount int
+	preview    string
+	children   []*summaryExplainNode
+	messages   []summaryExplainMessage
+}
+
+// summaryExplainMessage is a raw source message linked to a leaf summary.
+type summaryExplainMessage struct {
+	messageID  int64
+	role       string
+	tokenCount int
+	createdAt  string
+	preview    string
+}
+
+// summaryExplainStats counts what a context item ultimately compresses.
+type summaryExplainStats struct {
+	summaries     int
+	leaves        int
+	messages      int
+	messageTokens int
+}
+
+// loadSummaryExplainTree walks summary_parents from a summary down to its
+// leaves and attaches each leaf's summary_messages, using the same recursive
+// walk as lookupSummaryLeafTimeRange.
+func loadSummaryExplainTree(dbPath, summaryID string) (*summaryExplainNode, error) {
+	db, err := openLCMDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	const walkCTE = `
+		WITH RECURSIVE walk(summary_id) AS (
+			SELECT ?
+			UNION
+			SELECT sp.parent_summary_id
+			FROM summary_parents sp
+			JOIN walk w ON w.summary_id = sp.summary_id
+		)
+	`
+
+	nodes := make(map[string]*summaryExplainNode)
+	rows, err := db.Query(walkCTE+`
+		SELECT s.summary_id, COALESCE(s.kind, ''), COALESCE(s.depth, 0), COALESCE(s.token_count, 0), COALESCE(s.content, '')
+		FROM walk w
+		JOIN summaries s ON s.summary_id = w.summary_id
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query explain summaries for %s: %w", summaryID, err)
+	}
+	for rows.Next() {
+		var node summaryExplainNode
+		var content string
+		if err := rows.Scan(&node.summaryID, &node.kind, &node.depth, &node.tokenCount, &content); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan explain summary row: %w", err)
+		}
+		node.preview = oneLine(sanitizeForTerminal(content))
+		nodes[node.summaryID] = &node
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("iterate explain summary rows: %w", err)
+	}
+	rows.Close()
+
+	root, ok := nodes[summaryID]
+	if !ok {
+		return nil, fmt.Errorf("summary %s not found", summaryID)
+	}
+
+	rows, err = db.Query(walkCTE+`
+		SELECT sp.summary_id, sp.parent_summary_id
+		FROM summary_parents sp
+		JOIN walk w ON w.summary_id = sp.summary_id
+		ORDER BY sp.summary_id ASC, sp.ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query explain edges for %s: %w", summaryID, err)
+	}
+	for rows.Next() {
+		var derivedID, sourceID string
+		if err := rows.Scan(&derivedID, &sourceID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan explain edge row: %w", err)
+		}
+		derived, ok := nodes[derivedID]
+		if !ok {
+			continue
+		}
+		source, ok := nodes[sourceID]
+		if !ok {
+			// Dangling edge: keep it visible rather than silently dropping it.
+			source = &summaryExplainNode{summaryID: sourceID, kind: "missing", preview: "(summary row not found)"}
+			nodes[sourceID] = source
+		}
+		derived.children = append(derived.children, source)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("iterate explain edge rows: %w", err)
+	}
+	rows.Close()
+
+	rows, err = db.Query(walkCTE+`
+		SELECT sm.summary_id, m.message_id, COALESCE(m.role, ''), COALESCE(m.token_count, 0), COALESCE(m.created_at, ''), COALESCE(m.content, '')
+		FROM walk w
+		JOIN summary_messages sm ON sm.summary_id = w.summary_id
+		JOIN messages m ON m.message_id = sm.message_id
+		ORDER BY sm.summary_id ASC, sm.ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query explain messages for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ownerID, content string
+		var message summaryExplainMessage
+		if err := rows.Scan(&ownerID, &message.messageID, &message.role, &message.tokenCount, &message.createdAt, &content); err != nil {
+			return nil, fmt.Errorf("scan explain message row: %w", err)
+		}
+		message.preview = oneLine(sanitizeForTerminal(content))
+		if owner, ok := nodes[ownerID]; ok {
+			owner.messages = append(owner.messages, message)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate explain message rows: %w", err)
+	}
+	return root, nil
+}
+
+// explainStats counts distinct summaries, leaves, and source messages below
+// node. Shared descendants in a multi-parent DAG are counted once.
+func explainStats(node *summaryExplainNode) summaryExplainStats {
+	stats := summaryExplainStats{}
+	seenSummaries := make(map[string]bool)
+	seenMessages := make(map[int64]bool)
+	var walk func(n *summaryExplainNode)
+	walk = func(n *summaryExplainNode) {
+		if seenSummaries[n.summaryID] {
+			return
+		}
+		seenSummaries[n.summaryID] = true
+		stats.summaries++
+		if len(n.children) == 0 {
+			stats.leaves++
+		}
+		for _, message := range n.messages {
+			if seenMessages[message.messageID] {
+				continue
+			}
+			seenMessages[message.messageID] = true
+			stats.messages++
+			stats.messageTokens += message.tokenCount
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return stats
+}
+
+// renderSummaryExplainLines flattens the explain tree into indented lines.
+// Summaries already shown elsewhere in the tree are printed once and then
+// referenced, which also guards against cyclic edges.
+func renderSummaryExplainLines(root *summaryExplainNode, width int) []string {
+	stats := explainStats(root)
+	lines := []string{
+		fmt.Sprintf("Explain: %s compresses %d summaries (%d leaves) over %d source messages (%dt raw)",
+			root.summaryID, stats.summaries, stats.leaves, stats.messages, stats.messageTokens),
+		"",
+	}
+
+	shown := make(map[string]bool)
+	var walk func(n *summaryExplainNode, level int)
+	walk = func(n *summaryExplainNode, level int) {
+		indent := strings.Repeat("  ", level)
+		label := n.kind
+		if n.kind == "condensed" {
+			label = fmt.Sprintf("d%d", n.depth)
+		}
+		if shown[n.summaryID] {
+			lines = append(lines, fmt.Sprintf("%s%s [%s] (shown above)", indent, n.summaryID, label))
+			return
+		}
+		shown[n.summaryID] = true
+
+		header := fmt.Sprintf("%s%s [%s, %dt", indent, n.summaryID, label, n.tokenCount)
+		if len(n.messages) > 0 {
+			header += fmt.Sprintf(", %d msgs", len(n.messages))
+		}
+		header += "] "
+		lines = append(lines, header+truncateString(n.preview, max(8, width-len(header))))
+
+		for _, child := range n.children {
+			walk(child, level+1)
+		}
+
+		messageIndent := strings.Repeat("  ", level+1)
+		for _, message := range n.messages {
+			prefix := fmt.Sprintf("%s#%d %-9s %s %dt ", messageIndent, message.messageID, message.role, formatTimestamp(message.createdAt), message.tokenCount)
+			lines = append(lines, prefix+truncateString(message.preview, max(8, width-len(prefix))))
+		}
+	}
+	walk(root, 0)
+	return lines
+}
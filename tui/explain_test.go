@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestLoadSummaryExplainTreeWalksToSourceMessages(t *testing.T) {
+	t.Parallel()
+
+	dbPath := setupContextItemsTestDB(t)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO conversations (conversation_id, session_id) VALUES (7, 'session-explain');
+
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(101, 7, 1, 'user', 'deploy the api', 10, '2026-05-14 22:00:00'),
+			(102, 7, 2, 'assistant', 'deployed to staging', 12, '2026-05-14 22:01:00'),
+			(103, 7, 3, 'user', 'now production', 8, '2026-05-14 22:02:00');
+
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_d2', 7, 'condensed', 2, 'release arc', 40, '2026-05-14 23:00:00'),
+			('sum_d1', 7, 'condensed', 1, 'deploy session', 60, '2026-05-14 22:30:00'),
+			('sum_leaf_a', 7, 'leaf', 0, 'staging deploy', 80, '2026-05-14 22:10:00'),
+			('sum_leaf_b', 7, 'leaf', 0, 'prod request', 50, '2026-05-14 22:20:00');
+
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES
+			('sum_d2', 'sum_d1', 0),
+			('sum_d1', 'sum_leaf_a', 0),
+			('sum_d1', 'sum_leaf_b', 1);
+
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES
+			('sum_leaf_a', 101, 0),
+			('sum_leaf_a', 102, 1),
+			('sum_leaf_b', 103, 0);
+	`); err != nil {
+		t.Fatalf("seed summary DAG: %v", err)
+	}
+
+	root, err := loadSummaryExplainTree(dbPath, "sum_d2")
+	if err != nil {
+		t.Fatalf("load explain tree: %v", err)
+	}
+	stats := explainStats(root)
+	if stats.summaries != 4 || stats.leaves != 2 || stats.messages != 3 || stats.messageTokens != 30 {
+		t.Fatalf("stats = %+v, want 4 summaries, 2 leaves, 3 messages, 30 tokens", stats)
+	}
+
+	lines := renderSummaryExplainLines(root, 120)
+	rendered := strings.Join(lines, "\n")
+	order := []string{"sum_d2 [d2", "  sum_d1 [d1", "    sum_leaf_a [leaf", "      #101 user", "      #102 assistant", "    sum_leaf_b [leaf", "      #103 user"}
+	last := -1
+	for _, want := range order {
+		idx := strings.Index(rendered, want)
+		if idx < 0 || idx < last {
+			t.Fatalf("expected %q after previous entries in explain output, got:\n%s", want, rendered)
+		}
+		last = idx
+	}
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type exportContextOptions struct {
+	asAssembled bool
+	jsonOutput  bool
+	clipboard   bool
+	tz          *time.Location
+	// contentMaxChars caps each item's content; 0 exports it whole.
+	contentMaxChars int
+	ignorePatterns  ignorePatternList   // --ignore globs, added to ignoreSessionPatterns
+	ignore          *contextIgnoreRules // resolved patterns; nil ignores nothing
+}
+
+// exportedContextItem is one entry of the exported context. The JSON tags are
+// the --json output contract.
+type exportedContextItem struct {
+	Ordinal    int    `json:"ordinal"`
+	Role       string `json:"role"`
+	Source     string `json:"source"` // "summary", "message", or "focus_brief"
+	SourceID   string `json:"source_id"`
+	StoredRole string `json:"stored_role,omitempty"`
+	Tokens     int    `json:"tokens"`
+	Content    string `json:"content"`
+	// Truncated is set when Content was cut at --content-max-chars; Tokens
+	// still counts the whole item.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type exportedContext struct {
+	ConversationID int64                 `json:"conversation_id"`
+	AsAssembled    bool                  `json:"as_assembled"`
+	Items          []exportedContextItem `json:"items"`
+	TotalTokens    int                   `json:"total_tokens"`
+	// Skipped lists context items the assembler would drop: missing rows and
+	// empty assistant messages.
+	Skipped []string `json:"skipped,omitempty"`
+	// Ignored lists messages left out because their content matches an
+	// ignore pattern; IgnorePatterns are the patterns that applied.
+	Ignored        []string `json:"ignored,omitempty"`
+	IgnorePatterns []string `json:"ignore_patterns,omitempty"`
+	// SessionIgnoredBy is the pattern matching the conversation's session
+	// key. The live plugin stores nothing for such a session.
+	SessionIgnoredBy string `json:"session_ignored_by,omitempty"`
+}
+
+// exportSummaryColumns records which optional summaries columns exist so
+// older schemas export without failing.
+type exportSummaryColumns struct {
+	earliestAt      bool
+	latestAt        bool
+	descendantCount bool
+}
+
+// runExportContextCommand prints a conversation's context window, either as
+// stored or, with --as-assembled, with the roles and XML wrapping the
+// plugin's ContextAssembler emits.
+func runExportContextCommand(args []string) error {
+	opts, conversationID, err := parseExportContextArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if opts.ignore, err = resolveContextIgnoreRules(paths.openclawConfig, opts.ignorePatterns); err != nil {
+		return err
+	}
+	exported, err := buildExportedContext(context.Background(), db, conversationID, opts)
+	if err != nil {
+		return err
+	}
+	out := newCLIOutput(opts.clipboard)
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(out.w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(exported); err != nil {
+			return fmt.Errorf("encode exported context: %w", err)
+		}
+		return out.flush()
+	}
+	printExportedContext(out.w, exported)
+	return out.flush()
+}
+
+// buildExportedContext walks context_items in ordinal order. In assembled
+// mode it applies the active focus brief overlay, maps message roles the way
+// toRuntimeRole does, drops empty assistant messages, and wraps summaries in
+// the <summary trust="untrusted"> envelope as role "user".
+func buildExportedContext(ctx context.Context, db *sql.DB, conversationID int64, opts exportContextOptions) (exportedContext, error) {
+	exported := exportedContext{ConversationID: conversationID, AsAssembled: opts.asAssembled, Items: []exportedContextItem{}}
+	if opts.ignore != nil {
+		exported.IgnorePatterns = opts.ignore.patterns
+		sessionKey, err := loadConversationSessionKey(ctx, db, conversationID)
+		if err != nil {
+			return exportedContext{}, err
+		}
+		if sessionKey != "" {
+			exported.SessionIgnoredBy = opts.ignore.matchSessionKey(sessionKey)
+		}
+	}
+
+	columns, err := probeExportSummaryColumns(db)
+	if err != nil {
+		return exportedContext{}, err
+	}
+	entries, err := loadExportContextEntries(ctx, db, conversationID, columns)
+	if err != nil {
+		return exportedContext{}, err
+	}
+	if opts.asAssembled {
+		brief, err := loadActiveFocusBriefForConversation(db, conversationID)
+		if err != nil {
+			return exportedContext{}, err
+		}
+		entries = applyFocusOverlayToContextItems(entries, brief)
+	}
+
+	for _, entry := range entries {
+		var (
+			item exportedContextItem
+			ok   bool
+		)
+		switch entry.itemType {
+		case "summary":
+			item, ok, err = exportSummaryItem(ctx, db, entry, columns, opts)
+		case "message":
+			item, ok, err = exportMessageItem(ctx, db, entry, opts)
+		case "focus_brief":
+			item, ok = exportedContextItem{Ordinal: entry.ordinal, Role: "user", Source: "focus_brief", SourceID: entry.focusBriefID, Content: entry.content}, true
+		default:
+			exported.Skipped = append(exported.Skipped, fmt.Sprintf("ordinal %d: unknown item_type %q", entry.ordinal, entry.itemType))
+			continue
+		}
+		if err != nil {
+			return exportedContext{}, err
+		}
+		if !ok {
+			exported.Skipped = append(exported.Skipped, describeSkippedContextEntry(entry))
+			continue
+		}
+		if item.Source == "message" {
+			if pattern := opts.ignore.matchContent(item.Content); pattern != "" {
+				exported.Ignored = append(exported.Ignored, fmt.Sprintf("ordinal %d: message #%s matches %s", entry.ordinal, item.SourceID, pattern))
+				continue
+			}
+		}
+		item.Tokens = lcm.EstimateTokenCount(item.Content)
+		item.Content, item.Truncated = truncateContent(item.Content, opts.contentMaxChars)
+		exported.TotalTokens += item.Tokens
+		exported.Items = append(exported.Items, item)
+	}
+	return exported, nil
+}
+
+func probeExportSummaryColumns(db *sql.DB) (exportSummaryColumns, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
+		return exportSummaryColumns{}, fmt.Errorf("check summaries schema: %w", err)
+	}
+	return exportSummaryColumns{
+		earliestAt:      caps.HasColumn("summaries", "earliest_at"),
+		latestAt:        caps.HasColumn("summaries", "latest_at"),
+		descendantCount: caps.HasColumn("summaries", "descendant_count"),
+	}, nil
+}
+
+// loadExportContextEntries loads the raw context rows with the fields the
+// focus overlay needs. Content is filled in per item, unsanitized.
+func loadExportContextEntries(ctx context.Context, db *sql.DB, conversationID int64, columns exportSummaryColumns) ([]contextItemEntry, error) {
+	latestAtExpr := "''"
+	if columns.latestAt {
+		latestAtExpr = "COALESCE(s.latest_at, '')"
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT ci.ordinal, ci.item_type, COALESCE(ci.summary_id, ''), COALESCE(ci.message_id, 0), `+latestAtExpr+`
+		FROM context_items ci
+		LEFT JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ?
+		ORDER BY ci.ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query context items for conversation %d: %w", conversationID, err)
+	}
+	var entries []contextItemEntry
+	for rows.Next() {
+		var entry contextItemEntry
+		if err := rows.Scan(&entry.ordinal, &entry.itemType, &entry.summaryID, &entry.messageID, &entry.summaryLatestAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan context item: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("iterate context items: %w", err)
+	}
+	rows.Close()
+
+	for i := range entries {
+		if err := populateContextSummarySourceSeq(db, &entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func exportSummaryItem(ctx context.Context, db *sql.DB, entry contextItemEntry, columns exportSummaryColumns, opts exportContextOptions) (exportedContextItem, bool, error) {
+	optional := func(enabled bool, expr, fallback string) string {
+		if enabled {
+			return expr
+		}
+		return fallback
+	}
+	var (
+		kind, content, earliestAt, latestAt string
+		depth, descendantCount              int
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT kind, depth, content, `+
+		optional(columns.earliestAt, "COALESCE(earliest_at, '')", "''")+`, `+
+		optional(columns.latestAt, "COALESCE(latest_at, '')", "''")+`, `+
+		optional(columns.descendantCount, "COALESCE(descendant_count, 0)", "0")+`
+		FROM summaries
+		WHERE summary_id = ?
+	`, entry.summaryID).Scan(&kind, &depth, &content, &earliestAt, &latestAt, &descendantCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return exportedContextItem{}, false, nil
+	}
+	if err != nil {
+		return exportedContextItem{}, false, fmt.Errorf("query summary %s for export: %w", entry.summaryID, err)
+	}
+
+	item := exportedContextItem{Ordinal: entry.ordinal, Role: "summary", Source: "summary", SourceID: entry.summaryID, Content: content}
+	if !opts.asAssembled {
+		return item, true, nil
+	}
+	var parents []string
+	if kind == "condensed" {
+		if parents, err = loadSummaryParentIDs(ctx, db, entry.summaryID); err != nil {
+			return exportedContextItem{}, false, err
+		}
+	}
+	item.Role = "user"
+	item.Content = formatAssembledSummary(entry.summaryID, kind, depth, descendantCount, earliestAt, latestAt, parents, content, opts.tz)
+	return item, true, nil
+}
+
+func loadSummaryParentIDs(ctx context.Context, q sqlQueryer, summaryID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT parent_summary_id
+		FROM summary_parents
+		WHERE summary_id = ?
+		ORDER BY ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query parents for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+	var parents []string
+	for rows.Next() {
+		var parentID string
+		if err := rows.Scan(&parentID); err != nil {
+			return nil, fmt.Errorf("scan parent for %s: %w", summaryID, err)
+		}
+		parents = append(parents, parentID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate parents for %s: %w", summaryID, err)
+	}
+	return parents, nil
+}
+
+// formatAssembledSummary mirrors formatSummaryContent in src/assembler.ts:
+// same attribute order, trust="untrusted" taint label, parent refs for
+// condensed summaries, and XML-escaped content.
+func formatAssembledSummary(summaryID, kind string, depth, descendantCount int, earliestAt, latestAt string, parents []string, content string, loc *time.Location) string {
+	attrs := []string{
+		fmt.Sprintf(`id="%s"`, escapeXMLAttribute(summaryID)),
+		fmt.Sprintf(`kind="%s"`, escapeXMLAttribute(kind)),
+		fmt.Sprintf(`depth="%d"`, depth),
+		fmt.Sprintf(`descendant_count="%d"`, descendantCount),
+		`trust="untrusted"`,
+	}
+	if formatted := formatAssembledTimestamp(earliestAt, loc); formatted != "" {
+		attrs = append(attrs, fmt.Sprintf(`earliest_at="%s"`, formatted))
+	}
+	if formatted := formatAssembledTimestamp(latestAt, loc); formatted != "" {
+		attrs = append(attrs, fmt.Sprintf(`latest_at="%s"`, formatted))
+	}
+
+	lines := []string{fmt.Sprintf("<summary %s>", strings.Join(attrs, " "))}
+	if len(parents) > 0 {
+		lines = append(lines, "  <parents>")
+		for _, parentID := range parents {
+			lines = append(lines, fmt.Sprintf(`    <summary_ref id="%s" />`, escapeXMLAttribute(parentID)))
+		}
+		lines = append(lines, "  </parents>")
+	}
+	lines = append(lines, "  <content>", escapeXMLText(content), "  </content>", "</summary>")
+	return strings.Join(lines, "\n")
+}
+
+// formatAssembledTimestamp matches formatDateForAttribute: local wall time in
+// the agent timezone, without an offset. Stored times are UTC.
+func formatAssembledTimestamp(raw string, loc *time.Location) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	parsed, err := lcm.ParseSQLiteTime(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	return parsed.In(loc).Format("2006-01-02T15:04:05")
+}
+
+func escapeXMLText(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
+
+func exportMessageItem(ctx context.Context, db *sql.DB, entry contextItemEntry, opts exportContextOptions) (exportedContextItem, bool, error) {
+	var storedRole, rawContent, displayContent string
+	err := db.QueryRowContext(ctx, `
+		SELECT m.role, COALESCE(m.content, ''), `+messageDisplayContentSQL("m")+`
+		FROM messages m
+		WHERE m.message_id = ?
+	`, entry.messageID).Scan(&storedRole, &rawContent, &displayContent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return exportedContextItem{}, false, nil
+	}
+	if err != nil {
+		return exportedContextItem{}, false, fmt.Errorf("query message %d for export: %w", entry.messageID, err)
+	}
+
+	item := exportedContextItem{
+		Ordinal:  entry.ordinal,
+		Role:     storedRole,
+		Source:   "message",
+		SourceID: strconv.FormatInt(entry.messageID, 10),
+		Content:  displayContent,
+	}
+	if !opts.asAssembled {
+		return item, true, nil
+	}
+
+	parts, err := loadAssembledPartMeta(ctx, db, entry.messageID)
+	if err != nil {
+		return exportedContextItem{}, false, err
+	}
+	if storedRole == "assistant" && strings.TrimSpace(rawContent) == "" && parts.count == 0 {
+		return exportedContextItem{}, false, nil
+	}
+	item.StoredRole = storedRole
+	item.Role = assembledMessageRole(storedRole, parts)
+	return item, true, nil
+}
+
+// assembledPartMeta is what the assembler reads from message_parts to pick a
+// runtime role.
+type assembledPartMeta struct {
+	count        int
+	originalRole string
+	toolCallID   string
+}
+
+func loadAssembledPartMeta(ctx context.Context, q sqlQueryer, messageID int64) (assembledPartMeta, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT COALESCE(tool_call_id, ''), COALESCE(metadata, '')
+		FROM message_parts
+		WHERE message_id = ?
+		ORDER BY ordinal ASC
+	`, messageID)
+	if err != nil {
+		return assembledPartMeta{}, fmt.Errorf("query message parts for %d: %w", messageID, err)
+	}
+	defer rows.Close()
+
+	var meta assembledPartMeta
+	for rows.Next() {
+		var toolCallID, rawMetadata string
+		if err := rows.Scan(&toolCallID, &rawMetadata); err != nil {
+			return assembledPartMeta{}, fmt.Errorf("scan message part for %d: %w", messageID, err)
+		}
+		meta.count++
+		var decoded struct {
+			OriginalRole string `json:"originalRole"`
+			ToolCallID   string `json:"toolCallId"`
+			Raw          struct {
+				ToolCallID      string `json:"toolCallId"`
+				ToolCallIDSnake string `json:"tool_call_id"`
+			} `json:"raw"`
+		}
+		_ = json.Unmarshal([]byte(rawMetadata), &decoded)
+		if meta.originalRole == "" {
+			meta.originalRole = decoded.OriginalRole
+		}
+		if meta.toolCallID == "" {
+			for _, candidate := range []string{toolCallID, decoded.ToolCallID, decoded.Raw.ToolCallID, decoded.Raw.ToolCallIDSnake} {
+				if candidate != "" {
+					meta.toolCallID = candidate
+					break
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return assembledPartMeta{}, fmt.Errorf("iterate message parts for %d: %w", messageID, err)
+	}
+	return meta, nil
+}
+
+// assembledMessageRole mirrors toRuntimeRole plus resolveMessageItem's
+// downgrade: the originalRole part metadata wins, system becomes user, tool
+// becomes toolResult, and a toolResult without a call id becomes assistant.
+func assembledMessageRole(storedRole string, parts assembledPartMeta) string {
+	role := "user"
+	switch {
+	case parts.originalRole == "toolResult" || parts.originalRole == "assistant" || parts.originalRole == "user":
+		role = parts.originalRole
+	case parts.originalRole == "system":
+		role = "user"
+	case storedRole == "tool":
+		role = "toolResult"
+	case storedRole == "assistant":
+		role = "assistant"
+	}
+	if role == "toolResult" && parts.toolCallID == "" {
+		return "assistant"
+	}
+	return role
+}
+
+func describeSkippedContextEntry(entry contextItemEntry) string {
+	if entry.itemType == "summary" {
+		return fmt.Sprintf("ordinal %d: summary %s not found", entry.ordinal, entry.summaryID)
+	}
+	return fmt.Sprintf("ordinal %d: message #%d missing or empty assistant turn", entry.ordinal, entry.messageID)
+}
+
+func printExportedContext(w io.Writer, exported exportedContext) {
+	mode := "as stored"
+	if exported.AsAssembled {
+		mode = "as assembled"
+	}
+	fmt.Fprintf(w, "Conversation %d context (%s): %d items, ~%d tokens\n", exported.ConversationID, mode, len(exported.Items), exported.TotalTokens)
+	if exported.SessionIgnoredBy != "" {
+		fmt.Fprintf(w, "Note: the session key matches ignore pattern %s; the live plugin stores nothing for this session.\n", exported.SessionIgnoredBy)
+	}
+	for _, item := range exported.Items {
+		source := item.Source + " " + item.SourceID
+		if item.Source == "message" {
+			source = "message #" + item.SourceID
+		}
+		if item.StoredRole != "" && item.StoredRole != item.Role {
+			source += " (stored role: " + item.StoredRole + ")"
+		}
+		header := fmt.Sprintf("[%d] %s  %s", item.Ordinal, item.Role, source)
+		if item.Role == item.Source {
+			header = fmt.Sprintf("[%d] %s", item.Ordinal, source)
+		}
+		fmt.Fprintf(w, "\n--- %s  ~%dt\n", header, item.Tokens)
+		fmt.Fprintln(w, item.Content)
+	}
+	if len(exported.Skipped) > 0 {
+		fmt.Fprintf(w, "\nSkipped %d context items:\n", len(exported.Skipped))
+		for _, skipped := range exported.Skipped {
+			fmt.Fprintf(w, "  %s\n", skipped)
+		}
+	}
+	if len(exported.Ignored) > 0 {
+		fmt.Fprintf(w, "\nIgnored %d context items matching ignore patterns:\n", len(exported.Ignored))
+		for _, ignored := range exported.Ignored {
+			fmt.Fprintf(w, "  %s\n", ignored)
+		}
+	}
+}
+
+func parseExportContextArgs(args []string) (exportContextOptions, int64, error) {
+	fs := flag.NewFlagSet("export-context", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asAssembled := fs.Bool("as-assembled", false, "reproduce the assembler's roles and summary wrapping")
+	jsonOutput := fs.Bool("json", false, "print the context as JSON")
+	clipboard := fs.Bool("clipboard", false, "copy the output to the system clipboard instead of stdout")
+	tzName := fs.String("tz", "UTC", "timezone for summary time attributes (the agent's configured timezone)")
+	contentMaxChars := fs.Int("content-max-chars", resolveContentMaxChars(), "characters of each item's content to print (0 = no limit)")
+	full := fs.Bool("full", false, "print every item's content in full")
+	var ignorePatterns ignorePatternList
+	fs.Var(&ignorePatterns, "ignore", "leave out messages whose content matches this glob (repeatable)")
+
+	normalizedArgs, err := normalizeExportContextArgs(args)
+	if err != nil {
+		return exportContextOptions{}, 0, fmt.Errorf("%w\n%s", err, exportContextUsageText())
+	}
+	if err := fs.Parse(normalizedArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exportContextOptions{}, 0, errors.New(exportContextUsageText())
+		}
+		return exportContextOptions{}, 0, fmt.Errorf("%w\n%s", err, exportContextUsageText())
+	}
+	if fs.NArg() != 1 {
+		return exportContextOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", exportContextUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return exportContextOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), exportContextUsageText())
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(*tzName))
+	if err != nil {
+		return exportContextOptions{}, 0, fmt.Errorf("invalid timezone %q: %w", *tzName, err)
+	}
+	limit, err := resolveContentLimitFlags(*contentMaxChars, *full)
+	if err != nil {
+		return exportContextOptions{}, 0, fmt.Errorf("%w\n%s", err, exportContextUsageText())
+	}
+	return exportContextOptions{asAssembled: *asAssembled, jsonOutput: *jsonOutput, clipboard: *clipboard, tz: loc, contentMaxChars: limit, ignorePatterns: ignorePatterns}, conversationID, nil
+}
+
+func normalizeExportContextArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--tz" || arg == "--content-max-chars" || arg == "--ignore":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func exportContextUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui export-context <conversation_id> [--as-assembled] [--json] [--tz <timezone>] [--clipboard] [--content-max-chars <n>|--full] [--ignore <glob>]...
+
+Print a conversation's context_items in ordinal order. By default content is
+shown as stored. With --as-assembled, output mirrors what the plugin's
+ContextAssembler sends: summaries wrapped in <summary trust="untrusted">
+XML as role "user", message roles mapped the way the runtime maps them,
+the active focus brief overlay applied, and empty assistant turns dropped.
+Token-budget trimming is not applied; every context item is listed.
+
+Messages whose content matches an --ignore glob are left out and counted.
+The plugin's ignoreSessionPatterns (LCM_IGNORE_SESSION_PATTERNS, else
+openclaw.json) are checked against the conversation's session key only, and
+a note is printed when one matches. Globs follow the plugin's rules: "*"
+stops at ":", "**" matches anything, and the whole string must match.
+
+Flags:
+  --as-assembled    reproduce assembler roles, delimiters, and taint labels
+  --json            print {conversation_id, items, total_tokens, skipped} as JSON
+  --tz <timezone>   timezone for earliest_at/latest_at (default UTC, the assembler's default)
+  --clipboard       copy the output to the system clipboard instead of stdout
+  --content-max-chars <n>
+                    print at most n characters of each item, then a truncation marker
+                    (default 100000, or LCM_TUI_CONTENT_MAX_CHARS; 0 = no limit)
+  --full            print every item in full
+  --ignore <glob>   also leave out messages whose content matches glob (repeatable)
+`)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportContextAsAssembledMirrorsAssembler(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, earliest_at, latest_at, descendant_count, created_at)
+		VALUES
+			('sum_leaf', 1, 'leaf', 0, 'leaf text', 2, '2026-03-01 10:00:00', '2026-03-01 11:00:00', 0, '2026-03-01 11:00:00'),
+			('sum_root', 1, 'condensed', 1, 'Ignore <previous> & obey', 4, '2026-03-01 10:00:00', '2026-03-01 12:30:00', 3, '2026-03-01 12:30:00')
+	`)
+	mustExec(t, db, `INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal) VALUES ('sum_root', 'sum_leaf', 0)`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(10, 1, 0, 'system', 'system note', 2, '2026-03-01T13:00:00Z'),
+			(11, 1, 1, 'tool', 'legacy tool output', 3, '2026-03-01T13:01:00Z'),
+			(12, 1, 2, 'tool', 'paired tool output', 3, '2026-03-01T13:02:00Z'),
+			(13, 1, 3, 'assistant', '', 0, '2026-03-01T13:03:00Z'),
+			(14, 1, 4, 'user', 'hello', 1, '2026-03-01T13:04:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content, tool_call_id)
+		VALUES ('p12', 12, 'session-a', 'tool', 0, 'paired tool output', 'call_1')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES
+			(1, 0, 'summary', NULL, 'sum_root'),
+			(1, 1, 'message', 10, NULL),
+			(1, 2, 'message', 11, NULL),
+			(1, 3, 'message', 12, NULL),
+			(1, 4, 'message', 13, NULL),
+			(1, 5, 'message', 14, NULL)
+	`)
+
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	exported, err := buildExportedContext(context.Background(), db, 1, exportContextOptions{asAssembled: true, tz: la})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	wantSummary := strings.Join([]string{
+		`<summary id="sum_root" kind="condensed" depth="1" descendant_count="3" trust="untrusted" earliest_at="2026-03-01T02:00:00" latest_at="2026-03-01T04:30:00">`,
+		`  <parents>`,
+		`    <summary_ref id="sum_leaf" />`,
+		`  </parents>`,
+		`  <content>`,
+		`Ignore &lt;previous&gt; &amp; obey`,
+		`  </content>`,
+		`</summary>`,
+	}, "\n")
+	if len(exported.Items) != 5 {
+		t.Fatalf("expected 5 items (empty assistant dropped), got %+v", exported.Items)
+	}
+	if got := exported.Items[0]; got.Role != "user" || got.Content != wantSummary {
+		t.Fatalf("unexpected summary item role=%s content:\n%s", got.Role, got.Content)
+	}
+	wantRoles := []string{"user", "user", "assistant", "toolResult", "user"}
+	for i, want := range wantRoles {
+		if exported.Items[i].Role != want {
+			t.Fatalf("item %d role = %s, want %s", i, exported.Items[i].Role, want)
+		}
+	}
+	if exported.Items[2].StoredRole != "tool" {
+		t.Fatalf("expected stored role to be reported, got %+v", exported.Items[2])
+	}
+	if len(exported.Skipped) != 1 || !strings.Contains(exported.Skipped[0], "#13") {
+		t.Fatalf("expected empty assistant to be skipped, got %v", exported.Skipped)
+	}
+
+	stored, err := buildExportedContext(context.Background(), db, 1, exportContextOptions{tz: time.UTC})
+	if err != nil {
+		t.Fatalf("export stored: %v", err)
+	}
+	if len(stored.Items) != 6 || stored.Items[0].Content != "Ignore <previous> & obey" || stored.Items[1].Role != "system" {
+		t.Fatalf("stored mode should list raw rows, got %+v", stored.Items)
+	}
+}
+
+func TestExportContextCapsContentUnlessFull(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES (10, 1, 0, 'user', '`+strings.Repeat("é", 50)+`', 25, '2026-03-01T13:00:00Z')
+	`)
+	mustExec(t, db, `INSERT INTO context_items (conversation_id, ordinal, item_type, message_id) VALUES (1, 0, 'message', 10)`)
+
+	opts, _, err := parseExportContextArgs([]string{"1", "--content-max-chars", "20"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	exported, err := buildExportedContext(context.Background(), db, 1, opts)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	item := exported.Items[0]
+	want := strings.Repeat("é", 20) + contentTruncationNotice(100)
+	if !item.Truncated || item.Content != want {
+		t.Fatalf("expected 20 characters plus the truncation marker, got truncated=%v %q", item.Truncated, item.Content)
+	}
+	if item.Tokens != exported.TotalTokens || item.Tokens < 25 {
+		t.Fatalf("tokens should count the whole item, got %d", item.Tokens)
+	}
+
+	opts, _, err = parseExportContextArgs([]string{"1", "--content-max-chars", "20", "--full"})
+	if err != nil {
+		t.Fatalf("parse --full: %v", err)
+	}
+	exported, err = buildExportedContext(context.Background(), db, 1, opts)
+	if err != nil {
+		t.Fatalf("export --full: %v", err)
+	}
+	if exported.Items[0].Truncated || exported.Items[0].Content != strings.Repeat("é", 50) {
+		t.Fatalf("--full should export the whole item, got %q", exported.Items[0].Content)
+	}
+	if _, _, err := parseExportContextArgs([]string{"1", "--content-max-chars", "-1"}); err == nil {
+		t.Fatal("expected negative --content-max-chars to be rejected")
+	}
+}
+
+func TestExportContextLeavesOutIgnoredMessages(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `ALTER TABLE conversations ADD COLUMN session_key TEXT`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, session_key) VALUES (1, 'session-a', 'agent:main:cron:nightly')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(10, 1, 0, 'user', '[cron:nightly] heartbeat
+all ok', 3, '2026-03-01T13:00:00Z'),
+			(11, 1, 1, 'user', 'real question', 2, '2026-03-01T13:01:00Z'),
+			(12, 1, 2, 'assistant', 'HEARTBEAT_OK', 2, '2026-03-01T13:02:00Z'),
+			(13, 1, 3, 'user', 'agent:main:cron:nightly', 2, '2026-03-01T13:03:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id)
+		VALUES (1, 0, 'message', 10), (1, 1, 'message', 11), (1, 2, 'message', 12), (1, 3, 'message', 13)
+	`)
+
+	configPath := filepath.Join(t.TempDir(), "openclaw.json")
+	config := `{"plugins": {"entries": {"lossless-claw": {"config": {"ignoreSessionPatterns": ["agent:*:cron:**"]}}}}}`
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	opts, _, err := parseExportContextArgs([]string{"1", "--ignore", "[cron:*] **", "--ignore=HEARTBEAT_OK"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.ignore, err = resolveContextIgnoreRules(configPath, opts.ignorePatterns); err != nil {
+		t.Fatalf("resolve ignore rules: %v", err)
+	}
+	if got := opts.ignore.describe(); got != "3 patterns (openclaw.json + --ignore): agent:*:cron:**, [cron:*] **, HEARTBEAT_OK" {
+		t.Fatalf("describe = %q", got)
+	}
+	exported, err := buildExportedContext(context.Background(), db, 1, opts)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	// The configured pattern only applies to the session key, so message 13
+	// stays even though its content matches it.
+	if len(exported.Items) != 2 || exported.Items[0].SourceID != "11" || exported.Items[1].SourceID != "13" || len(exported.Ignored) != 2 {
+		t.Fatalf("expected messages 11 and 13 kept and two ignored, got %+v ignored %v", exported.Items, exported.Ignored)
+	}
+	if exported.SessionIgnoredBy != "agent:*:cron:**" {
+		t.Fatalf("session key should match the configured pattern, got %q", exported.SessionIgnoredBy)
+	}
+
+	// "*" stops at ":" like the plugin's session patterns.
+	if compileIgnorePattern("agent:*").MatchString("agent:main:cron") || !compileIgnorePattern("agent:**").MatchString("agent:main:cron") {
+		t.Fatal("single and double stars should match like src/session-patterns.ts")
+	}
+	t.Setenv("LCM_IGNORE_SESSION_PATTERNS", "")
+	if rules, err := resolveContextIgnoreRules(configPath, nil); err != nil || rules != nil {
+		t.Fatalf("an empty LCM_IGNORE_SESSION_PATTERNS overrides the config, got %+v (%v)", rules, err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fileSortMode orders the large files screen. The zero value keeps the
+// created_at order files are loaded in.
+type fileSortMode int
+
+const (
+	fileSortDate fileSortMode = iota
+	fileSortSize
+	fileSortName
+	fileSortMime
+)
+
+func (s fileSortMode) label() string {
+	switch s {
+	case fileSortSize:
+		return "size"
+	case fileSortName:
+		return "name"
+	case fileSortMime:
+		return "mime"
+	default:
+		return "date"
+	}
+}
+
+// next cycles date -> size -> name -> mime -> date.
+func (s fileSortMode) next() fileSortMode {
+	return (s + 1) % (fileSortMime + 1)
+}
+
+// sortLargeFiles orders files in place. Size sorts largest first; name and
+// mime sort case-insensitively; ties fall back to creation time.
+func sortLargeFiles(files []largeFileEntry, mode fileSortMode) {
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		switch mode {
+		case fileSortSize:
+			if a.byteSize != b.byteSize {
+				return a.byteSize > b.byteSize
+			}
+		case fileSortName:
+			if an, bn := strings.ToLower(a.displayName()), strings.ToLower(b.displayName()); an != bn {
+				return an < bn
+			}
+		case fileSortMime:
+			if am, bm := strings.ToLower(a.mimeType), strings.ToLower(b.mimeType); am != bm {
+				return am < bm
+			}
+			if a.byteSize != b.byteSize {
+				return a.byteSize > b.byteSize
+			}
+		}
+		if a.createdAt != b.createdAt {
+			return a.createdAt < b.createdAt
+		}
+		return a.fileID < b.fileID
+	})
+}
+
+// filterLargeFiles keeps files whose name or MIME type contains filter,
+// ignoring case. An empty filter returns files unchanged.
+func filterLargeFiles(files []largeFileEntry, filter string) []largeFileEntry {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return files
+	}
+	out := make([]largeFileEntry, 0, len(files))
+	for _, f := range files {
+		if strings.Contains(strings.ToLower(f.fileName), filter) || strings.Contains(strings.ToLower(f.mimeType), filter) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func totalLargeFileBytes(files []largeFileEntry) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.byteSize
+	}
+	return total
+}
+
+// visibleLargeFiles is the filtered view the files screen cursor indexes.
+func (m model) visibleLargeFiles() []largeFileEntry {
+	return filterLargeFiles(m.largeFiles, m.fileFilter)
+}
+
+// setLargeFiles replaces the loaded files, applying the current sort.
+func (m *model) setLargeFiles(files []largeFileEntry) {
+	sortLargeFiles(files, m.fileSort)
+	m.largeFiles = files
+}
+
+// refreshFileView re-sorts and re-filters, keeping the cursor on the same
+// file when it is still visible.
+func (m *model) refreshFileView() {
+	selectedID := ""
+	if visible := m.visibleLargeFiles(); m.fileCursor >= 0 && m.fileCursor < len(visible) {
+		selectedID = visible[m.fileCursor].fileID
+	}
+	sortLargeFiles(m.largeFiles, m.fileSort)
+	visible := m.visibleLargeFiles()
+	m.fileCursor = 0
+	for i, f := range visible {
+		if f.fileID == selectedID {
+			m.fileCursor = i
+			break
+		}
+	}
+}
+
+// handleFileFilterKey edits the files screen filter in place; the list
+// narrows as the user types.
+func (m model) handleFileFilterKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.fileFilterEditing = false
+		m.fileFilter = ""
+		m.status = "Filter cleared"
+	case tea.KeyEnter:
+		m.fileFilterEditing = false
+		m.status = m.fileViewStatus()
+		return m, nil
+	case tea.KeyBackspace:
+		if runes := []rune(m.fileFilter); len(runes) > 0 {
+			m.fileFilter = string(runes[:len(runes)-1])
+		}
+	case tea.KeyCtrlU:
+		m.fileFilter = ""
+	case tea.KeySpace:
+		m.fileFilter += " "
+	case tea.KeyRunes:
+		m.fileFilter += string(msg.Runes)
+	}
+	m.refreshFileView()
+	return m, nil
+}
+
+// fileViewStatus summarizes the current sort and filter for the status line.
+func (m model) fileViewStatus() string {
+	visible := m.visibleLargeFiles()
+	if strings.TrimSpace(m.fileFilter) == "" {
+		return fmt.Sprintf("%d large files sorted by %s", len(visible), m.fileSort.label())
+	}
+	return fmt.Sprintf("%d of %d large files match %q, sorted by %s", len(visible), len(m.largeFiles), m.fileFilter, m.fileSort.label())
+}
+
+// fileHeaderSummary is the count and byte total shown in the files header.
+func (m model) fileHeaderSummary() string {
+	visible := m.visibleLargeFiles()
+	summary := fmt.Sprintf("%d files, %s", len(m.largeFiles), formatByteSizeCompact(totalLargeFileBytes(m.largeFiles)))
+	if strings.TrimSpace(m.fileFilter) != "" {
+		summary = fmt.Sprintf("%d/%d files, %s of %s",
+			len(visible), len(m.largeFiles),
+			formatByteSizeCompact(totalLargeFileBytes(visible)),
+			formatByteSizeCompact(totalLargeFileBytes(m.largeFiles)))
+	}
+	return summary + " | sort:" + m.fileSort.label()
+}
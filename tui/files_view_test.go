@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func sampleLargeFiles() []largeFileEntry {
+	return []largeFileEntry{
+		{fileID: "file_1", fileName: "notes.md", mimeType: "text/markdown", byteSize: 40 * 1024, createdAt: "2026-03-01 09:00:00"},
+		{fileID: "file_2", fileName: "Export.csv", mimeType: "text/csv", byteSize: 50 * 1024 * 1024, createdAt: "2026-03-01 10:00:00"},
+		{fileID: "file_3", fileName: "app.log", mimeType: "text/plain", byteSize: 2 * 1024 * 1024, createdAt: "2026-03-01 11:00:00"},
+	}
+}
+
+func largeFileIDs(files []largeFileEntry) string {
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, f.fileID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSortLargeFiles(t *testing.T) {
+	for _, tc := range []struct {
+		mode fileSortMode
+		want string
+	}{
+		{fileSortDate, "file_1,file_2,file_3"},
+		{fileSortSize, "file_2,file_3,file_1"},
+		{fileSortName, "file_3,file_2,file_1"},
+		{fileSortMime, "file_2,file_1,file_3"},
+	} {
+		files := sampleLargeFiles()
+		sortLargeFiles(files, tc.mode)
+		if got := largeFileIDs(files); got != tc.want {
+			t.Fatalf("sort by %s: got %s, want %s", tc.mode.label(), got, tc.want)
+		}
+	}
+	if fileSortMime.next() != fileSortDate {
+		t.Fatal("expected sort modes to cycle back to date")
+	}
+}
+
+func TestFilterLargeFilesMatchesNameOrMime(t *testing.T) {
+	files := sampleLargeFiles()
+	if got := largeFileIDs(filterLargeFiles(files, "CSV")); got != "file_2" {
+		t.Fatalf("expected case-insensitive match, got %s", got)
+	}
+	if got := largeFileIDs(filterLargeFiles(files, "text/")); got != "file_1,file_2,file_3" {
+		t.Fatalf("expected MIME match, got %s", got)
+	}
+	if got := largeFileIDs(filterLargeFiles(files, "  ")); got != "file_1,file_2,file_3" {
+		t.Fatalf("expected blank filter to keep all, got %s", got)
+	}
+	if total := totalLargeFileBytes(files); total != 40*1024+52*1024*1024 {
+		t.Fatalf("unexpected total %d", total)
+	}
+}
+
+func TestFilesScreenSortAndFilterKeys(t *testing.T) {
+	m := model{screen: screenFiles}
+	m.setLargeFiles(sampleLargeFiles())
+	m.fileCursor = 2
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = updated.(model)
+	if m.fileSort != fileSortSize || largeFileIDs(m.largeFiles) != "file_2,file_3,file_1" {
+		t.Fatalf("expected size sort, got %s", largeFileIDs(m.largeFiles))
+	}
+	if m.visibleLargeFiles()[m.fileCursor].fileID != "file_3" {
+		t.Fatalf("expected cursor to follow file_3, got %d", m.fileCursor)
+	}
+
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("/")},
+		{Type: tea.KeyRunes, Runes: []rune("q")},
+		{Type: tea.KeyBackspace},
+		{Type: tea.KeyRunes, Runes: []rune("csv")},
+		{Type: tea.KeyEnter},
+	} {
+		updated, _ = m.Update(msg)
+		m = updated.(model)
+	}
+	if m.fileFilterEditing || m.fileFilter != "csv" {
+		t.Fatalf("expected closed prompt with filter, got editing=%v filter=%q", m.fileFilterEditing, m.fileFilter)
+	}
+	if got := largeFileIDs(m.visibleLargeFiles()); got != "file_2" || m.fileCursor != 0 {
+		t.Fatalf("unexpected filtered view %s (cursor %d)", got, m.fileCursor)
+	}
+	if header := m.fileHeaderSummary(); !strings.Contains(header, "1/3 files, 50.0 MB of 52.0 MB") {
+		t.Fatalf("unexpected header %q", header)
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type foldOptions struct {
+	into     string // condensed summary; "" resolves the one shared by every item
+	from     int64
+	to       int64
+	apply    bool
+	backupDB bool // copy lcm.db before --apply writes
+}
+
+// foldItem is one context summary covered by a fold.
+type foldItem struct {
+	ordinal    int64
+	summaryID  string
+	tokenCount int
+}
+
+// foldPlan re-condenses a contiguous context range into an existing
+// condensed summary that already lists every item in the range as a source:
+// the reverse of dissolve, without generating a new summary.
+type foldPlan struct {
+	conversationID int64
+	target         dissolveTarget // the existing condensed summary
+	items          []foldItem
+	targetInCtx    bool  // target already has its own context item
+	targetOrdinal  int64 // its current ordinal when targetInCtx
+	removedTokens  int
+	addedTokens    int
+	// strays are other sources of target still in context outside the range;
+	// after the fold their content appears twice.
+	strays []foldItem
+}
+
+func (p foldPlan) tokenDelta() int {
+	return p.addedTokens - p.removedTokens
+}
+
+// buildFoldPlan validates that every context item in [from, to] is a summary
+// listed in summary_parents under one condensed summary, and computes the
+// token change. Nothing is written.
+func buildFoldPlan(ctx context.Context, q sqlQueryer, conversationID int64, into string, from, to int64) (foldPlan, error) {
+	contextItems, err := lcm.LoadContextItems(ctx, q, conversationID)
+	if err != nil {
+		return foldPlan{}, err
+	}
+	plan := foldPlan{conversationID: conversationID}
+	inRange := make(map[string]bool)
+	for _, item := range contextItems {
+		if item.Ordinal < from || item.Ordinal > to {
+			continue
+		}
+		if item.ItemType != "summary" || !item.SummaryID.Valid {
+			return foldPlan{}, fmt.Errorf("context item at ordinal %d is a %s; only summaries can be folded", item.Ordinal, item.ItemType)
+		}
+		plan.items = append(plan.items, foldItem{ordinal: item.Ordinal, summaryID: item.SummaryID.String, tokenCount: item.TokenCount})
+		plan.removedTokens += item.TokenCount
+		inRange[item.SummaryID.String] = true
+	}
+	if len(plan.items) == 0 {
+		return foldPlan{}, fmt.Errorf("no context items between ordinals %d and %d in conversation %d", from, to, conversationID)
+	}
+
+	if into == "" {
+		into, err = resolveFoldTarget(ctx, q, conversationID, plan.items)
+		if err != nil {
+			return foldPlan{}, err
+		}
+	}
+	err = q.QueryRowContext(ctx, `
+		SELECT summary_id, conversation_id, kind, depth, token_count
+		FROM summaries
+		WHERE summary_id = ?
+	`, into).Scan(&plan.target.summaryID, &plan.target.conversationID, &plan.target.kind, &plan.target.depth, &plan.target.tokenCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return foldPlan{}, fmt.Errorf("summary %s not found", into)
+	}
+	if err != nil {
+		return foldPlan{}, fmt.Errorf("load fold target %s: %w", into, err)
+	}
+	if plan.target.conversationID != conversationID {
+		return foldPlan{}, fmt.Errorf("summary %s belongs to conversation %d, not %d", into, plan.target.conversationID, conversationID)
+	}
+	if plan.target.kind != "condensed" {
+		return foldPlan{}, fmt.Errorf("summary %s is a %s (depth %d), not condensed — items can only be folded into a condensed summary", into, plan.target.kind, plan.target.depth)
+	}
+
+	sources, err := loadFoldTargetSources(ctx, q, into)
+	if err != nil {
+		return foldPlan{}, err
+	}
+	for _, item := range plan.items {
+		if item.summaryID == into {
+			return foldPlan{}, fmt.Errorf("range includes %s itself at ordinal %d", into, item.ordinal)
+		}
+		if !sources[item.summaryID] {
+			return foldPlan{}, fmt.Errorf("context item at ordinal %d (%s) is not a source of %s", item.ordinal, item.summaryID, into)
+		}
+	}
+
+	for _, item := range contextItems {
+		if !item.SummaryID.Valid {
+			continue
+		}
+		id := item.SummaryID.String
+		switch {
+		case id == into:
+			plan.targetInCtx = true
+			plan.targetOrdinal = item.Ordinal
+		case sources[id] && !inRange[id]:
+			plan.strays = append(plan.strays, foldItem{ordinal: item.Ordinal, summaryID: id, tokenCount: item.TokenCount})
+		}
+	}
+	if !plan.targetInCtx {
+		plan.addedTokens = plan.target.tokenCount
+	}
+	return plan, nil
+}
+
+// resolveFoldTarget finds the single condensed summary in the conversation
+// whose sources include every item.
+func resolveFoldTarget(ctx context.Context, q sqlQueryer, conversationID int64, items []foldItem) (string, error) {
+	args := make([]any, 0, len(items)+2)
+	args = append(args, conversationID)
+	for _, item := range items {
+		args = append(args, item.summaryID)
+	}
+	args = append(args, len(items))
+	rows, err := q.QueryContext(ctx, `
+		SELECT sp.summary_id
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.summary_id
+		WHERE s.conversation_id = ? AND s.kind = 'condensed'
+		  AND sp.parent_summary_id IN (`+lcm.SQLPlaceholders(len(items))+`)
+		GROUP BY sp.summary_id
+		HAVING COUNT(DISTINCT sp.parent_summary_id) = ?
+		ORDER BY sp.summary_id ASC
+	`, args...)
+	if err != nil {
+		return "", fmt.Errorf("query shared condensed summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return "", fmt.Errorf("scan shared condensed summary: %w", err)
+		}
+		candidates = append(candidates, id)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("iterate shared condensed summaries: %w", err)
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no condensed summary lists all %d items as sources; nothing to fold into", len(items))
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", fmt.Errorf("several condensed summaries share these items (%s); choose one with --into", strings.Join(candidates, ", "))
+	}
+}
+
+func loadFoldTargetSources(ctx context.Context, q sqlQueryer, summaryID string) (map[string]bool, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT parent_summary_id FROM summary_parents WHERE summary_id = ?
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query sources for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+
+	sources := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan source row: %w", err)
+		}
+		sources[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sources: %w", err)
+	}
+	return sources, nil
+}
+
+// applyFoldPlan removes the range from context, inserts the target at the
+// range start when it is not already in context, and resequences ordinals in
+// one transaction. The plan is rebuilt inside the transaction so the fold
+// matches the DB it commits to. It returns the new context item count.
+func applyFoldPlan(ctx context.Context, db *sql.DB, conversationID int64, into string, from, to int64) (foldPlan, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return foldPlan{}, 0, fmt.Errorf("begin fold transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	plan, err := buildFoldPlan(ctx, tx, conversationID, into, from, to)
+	if err != nil {
+		return foldPlan{}, 0, err
+	}
+	for _, item := range plan.items {
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM context_items
+			WHERE conversation_id = ? AND ordinal = ? AND summary_id = ?
+		`, conversationID, item.ordinal, item.summaryID)
+		if err != nil {
+			return foldPlan{}, 0, fmt.Errorf("delete context item at ordinal %d: %w", item.ordinal, err)
+		}
+		if deleted, _ := res.RowsAffected(); deleted != 1 {
+			return foldPlan{}, 0, fmt.Errorf("expected to delete 1 context item at ordinal %d, deleted %d", item.ordinal, deleted)
+		}
+	}
+	if !plan.targetInCtx {
+		start := plan.items[0].ordinal
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id, created_at)
+			VALUES (?, ?, 'summary', ?, datetime('now'))
+		`, conversationID, start, plan.target.summaryID); err != nil {
+			return foldPlan{}, 0, fmt.Errorf("insert %s at ordinal %d: %w", plan.target.summaryID, start, err)
+		}
+	}
+	if err := lcm.ResequenceContextOrdinals(ctx, tx, conversationID); err != nil {
+		return foldPlan{}, 0, err
+	}
+
+	// Guardrail: never commit a context with gaps or duplicate ordinals.
+	ordinals, err := loadContextOrdinals(ctx, tx, conversationID)
+	if err != nil {
+		return foldPlan{}, 0, err
+	}
+	if err := checkContiguousOrdinals(ordinals); err != nil {
+		return foldPlan{}, 0, fmt.Errorf("fold into %s rolled back: %w", plan.target.summaryID, err)
+	}
+	summaryIDs := []string{plan.target.summaryID}
+	for _, item := range plan.items {
+		summaryIDs = append(summaryIDs, item.summaryID)
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "fold", ConversationID: conversationID, SummaryIDs: summaryIDs,
+		TokensBefore: plan.removedTokens, TokensAfter: plan.addedTokens,
+		Detail: fmt.Sprintf("ordinals %d-%d into %s", from, to, plan.target.summaryID),
+	}); err != nil {
+		return foldPlan{}, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return foldPlan{}, 0, fmt.Errorf("commit fold: %w", err)
+	}
+	rollback = false
+	return plan, len(ordinals), nil
+}
+
+func printFoldPlan(plan foldPlan) {
+	fmt.Printf("Fold %d context items into %s (%s, d%d, %dt)\n",
+		len(plan.items), plan.target.summaryID, plan.target.kind, plan.target.depth, plan.target.tokenCount)
+	for _, item := range plan.items {
+		fmt.Printf("  [%d] %s (%dt)\n", item.ordinal, item.summaryID, item.tokenCount)
+	}
+	if plan.targetInCtx {
+		fmt.Printf("%s is already in context at ordinal %d; the range is removed.\n", plan.target.summaryID, plan.targetOrdinal)
+	} else {
+		fmt.Printf("%s will be inserted at ordinal %d.\n", plan.target.summaryID, plan.items[0].ordinal)
+	}
+	fmt.Printf("\nToken impact: %dt removed, %dt added (%+dt)\n", plan.removedTokens, plan.addedTokens, plan.tokenDelta())
+	if len(plan.strays) > 0 {
+		fmt.Printf("Warning: %d other sources of %s remain in context and will duplicate its content:\n", len(plan.strays), plan.target.summaryID)
+		for _, item := range plan.strays {
+			fmt.Printf("  [%d] %s (%dt)\n", item.ordinal, item.summaryID, item.tokenCount)
+		}
+	}
+}
+
+// runFoldCommand executes the standalone fold CLI path.
+func runFoldCommand(args []string) error {
+	opts, conversationID, err := parseFoldArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildFoldPlan(ctx, db, conversationID, opts.into, opts.from, opts.to)
+	if err != nil {
+		return err
+	}
+	printFoldPlan(plan)
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to execute.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "fold", opts.backupDB, nil); err != nil {
+		return err
+	}
+	_, newCount, err := applyFoldPlan(ctx, db, conversationID, plan.target.summaryID, opts.from, opts.to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Context now has %d items. Changes take effect on next conversation turn.\n", newCount)
+	return nil
+}
+
+func parseFoldArgs(args []string) (foldOptions, int64, error) {
+	fs := flag.NewFlagSet("fold", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	into := fs.String("into", "", "existing condensed summary to fold into (default: the one shared by every item)")
+	from := fs.Int64("from", -1, "first context ordinal of the range")
+	to := fs.Int64("to", -1, "last context ordinal of the range")
+	apply := fs.Bool("apply", false, "apply changes to the DB")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized, err := normalizeFoldArgs(args)
+	if err != nil {
+		return foldOptions{}, 0, fmt.Errorf("%w\n%s", err, foldUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return foldOptions{}, 0, errors.New(foldUsageText())
+		}
+		return foldOptions{}, 0, fmt.Errorf("%w\n%s", err, foldUsageText())
+	}
+	if fs.NArg() != 1 {
+		return foldOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", foldUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return foldOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), foldUsageText())
+	}
+	if *from < 0 || *to < 0 {
+		return foldOptions{}, 0, fmt.Errorf("--from and --to are required\n%s", foldUsageText())
+	}
+	if *from > *to {
+		return foldOptions{}, 0, fmt.Errorf("--from %d is after --to %d\n%s", *from, *to, foldUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return foldOptions{}, 0, err
+	}
+	return foldOptions{
+		into:     strings.TrimSpace(*into),
+		from:     *from,
+		to:       *to,
+		apply:    *apply,
+		backupDB: backup,
+	}, conversationID, nil
+}
+
+func normalizeFoldArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--into" || arg == "--from" || arg == "--to":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func foldUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui fold <conversation_id> --from <ordinal> --to <ordinal> [--into <summary_id>] [--apply] [--backup-db]
+
+Folds a contiguous range of context summaries back into an existing condensed
+summary that already lists each of them as a source: the range is removed from
+context_items and the condensed summary is inserted at the range start unless
+it is already in context. Ordinals are resequenced. No new summary is
+generated. Dry run unless --apply is given.
+
+Flags:
+  --from <n>        first context ordinal of the range (inclusive)
+  --to <n>          last context ordinal of the range (inclusive)
+  --into <id>       condensed summary to fold into (default: the one shared by every item)
+  --apply           Execute changes (default: dry run)
+  --backup-db       With --apply, copy lcm.db to a timestamped backup first
+`)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestApplyFoldPlanReinsertsCondensedParent(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	seedDissolveConversation(t, db, [3]int{0, 1, 2})
+	plan, err := buildDissolvePlan(ctx, db, 1, "sum_top")
+	if err != nil {
+		t.Fatalf("build dissolve plan: %v", err)
+	}
+	if _, err := applyDissolvePlan(ctx, db, plan, false); err != nil {
+		t.Fatalf("dissolve: %v", err)
+	}
+
+	fold, err := buildFoldPlan(ctx, db, 1, "", 1, 3)
+	if err != nil {
+		t.Fatalf("build fold plan: %v", err)
+	}
+	if fold.target.summaryID != "sum_top" {
+		t.Fatalf("resolved target = %q, want sum_top", fold.target.summaryID)
+	}
+	if fold.targetInCtx {
+		t.Fatalf("expected sum_top to be absent from context before fold")
+	}
+	if fold.tokenDelta() != 12-30 {
+		t.Fatalf("token delta = %d, want %d", fold.tokenDelta(), 12-30)
+	}
+
+	_, newCount, err := applyFoldPlan(ctx, db, 1, "", 1, 3)
+	if err != nil {
+		t.Fatalf("apply fold: %v", err)
+	}
+	if newCount != 3 {
+		t.Fatalf("new count = %d, want 3", newCount)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 1 AND summary_id = 'sum_top'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 2 AND summary_id = 'sum_next'`, 1)
+}
+
+func TestBuildFoldPlanRejectsNonSources(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	seedDissolveConversation(t, db, [3]int{0, 1, 2})
+
+	// sum_top and sum_next share no condensed parent.
+	if _, err := buildFoldPlan(ctx, db, 1, "", 1, 2); err == nil || !strings.Contains(err.Error(), "no condensed summary") {
+		t.Fatalf("expected missing shared parent error, got %v", err)
+	}
+	// sum_next is not one of sum_top's sources.
+	mustExec(t, db, `UPDATE context_items SET summary_id = 'sum_a' WHERE conversation_id = 1 AND ordinal = 1`)
+	if _, err := buildFoldPlan(ctx, db, 1, "sum_top", 1, 2); err == nil || !strings.Contains(err.Error(), "not a source of sum_top") {
+		t.Fatalf("expected non-source error, got %v", err)
+	}
+	// Messages cannot be folded.
+	if _, err := buildFoldPlan(ctx, db, 1, "sum_top", 0, 1); err == nil || !strings.Contains(err.Error(), "only summaries") {
+		t.Fatalf("expected message rejection, got %v", err)
+	}
+}
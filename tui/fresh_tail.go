@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+const freshTailPreviewChars = 80
+
+type freshTailOptions struct {
+	count int
+	// countSet is true when --count was given; otherwise a fresh-tail
+	// pinned with lcm-tui settings replaces the default.
+	countSet bool
+}
+
+// freshTailItem is one context item at or after the fresh-tail cutoff.
+type freshTailItem struct {
+	ordinal    int64
+	itemType   string
+	messageID  int64
+	summaryID  string
+	depth      int
+	tokenCount int
+	role       string
+	createdAt  string
+	preview    string
+}
+
+// freshTailReport shows where backfill compaction would stop for a
+// conversation: everything before cutoff is eligible, the tail stays raw.
+type freshTailReport struct {
+	count         int
+	cutoff        int64
+	hasCutoff     bool
+	contextItems  int
+	contextMsgs   int
+	items         []freshTailItem
+	tailMessages  int
+	tailSummaries int
+	tailTokens    int
+}
+
+// buildFreshTailReport applies lcm.FreshTailOrdinal to the
+// conversation's current context items, exactly as compaction would.
+func buildFreshTailReport(ctx context.Context, q sqlQueryer, conversationID int64, count int) (freshTailReport, error) {
+	items, err := lcm.LoadContextItems(ctx, q, conversationID)
+	if err != nil {
+		return freshTailReport{}, err
+	}
+	report := freshTailReport{count: count, contextItems: len(items)}
+	for _, item := range items {
+		if item.ItemType == "message" && item.MessageID.Valid {
+			report.contextMsgs++
+		}
+	}
+	if count <= 0 || report.contextMsgs == 0 {
+		return report, nil
+	}
+	report.cutoff = lcm.FreshTailOrdinal(items, count)
+	report.hasCutoff = true
+
+	var messageIDs []int64
+	for _, item := range items {
+		if item.Ordinal < report.cutoff {
+			continue
+		}
+		tail := freshTailItem{
+			ordinal:    item.Ordinal,
+			itemType:   item.ItemType,
+			depth:      item.Depth,
+			tokenCount: item.TokenCount,
+		}
+		if item.MessageID.Valid {
+			tail.messageID = item.MessageID.Int64
+			messageIDs = append(messageIDs, tail.messageID)
+			report.tailMessages++
+		}
+		if item.SummaryID.Valid {
+			tail.summaryID = item.SummaryID.String
+			report.tailSummaries++
+		}
+		report.tailTokens += item.TokenCount
+		report.items = append(report.items, tail)
+	}
+
+	details, err := loadFreshTailMessageDetails(ctx, q, messageIDs)
+	if err != nil {
+		return freshTailReport{}, err
+	}
+	for i := range report.items {
+		if detail, ok := details[report.items[i].messageID]; ok {
+			report.items[i].role = detail.role
+			report.items[i].createdAt = detail.createdAt
+			report.items[i].preview = detail.preview
+		}
+	}
+	return report, nil
+}
+
+// loadFreshTailMessageDetails fetches role, timestamp, and a one-line preview
+// for the tail messages in lcm.ChunkLoadBatchSize IN batches.
+func loadFreshTailMessageDetails(ctx context.Context, q sqlQueryer, messageIDs []int64) (map[int64]freshTailItem, error) {
+	details := make(map[int64]freshTailItem, len(messageIDs))
+	for start := 0; start < len(messageIDs); start += lcm.ChunkLoadBatchSize {
+		batch := messageIDs[start:min(len(messageIDs), start+lcm.ChunkLoadBatchSize)]
+		args := make([]any, 0, len(batch))
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		rows, err := q.QueryContext(ctx, `
+			SELECT message_id, role, content, created_at
+			FROM messages
+			WHERE message_id IN (`+lcm.SQLPlaceholders(len(batch))+`)
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query fresh-tail messages: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			var detail freshTailItem
+			var content string
+			if err := rows.Scan(&id, &detail.role, &content, &detail.createdAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan fresh-tail message: %w", err)
+			}
+			detail.preview = previewForLog(sanitizeForTerminal(oneLine(content)), freshTailPreviewChars)
+			details[id] = detail
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("iterate fresh-tail messages: %w", err)
+		}
+		rows.Close()
+	}
+	return details, nil
+}
+
+func printFreshTailReport(conversationID int64, report freshTailReport) {
+	fmt.Printf("Fresh tail for conversation %d (--fresh-tail %d)\n", conversationID, report.count)
+	fmt.Printf("Context: %d items, %d messages\n", report.contextItems, report.contextMsgs)
+	if !report.hasCutoff {
+		if report.count <= 0 {
+			fmt.Println("Fresh tail disabled: every context message is eligible for leaf compaction.")
+		} else {
+			fmt.Println("No context messages; nothing to preserve.")
+		}
+		return
+	}
+	eligible := report.contextMsgs - report.tailMessages
+	fmt.Printf("Cutoff: ordinal %d (%d messages before it are eligible for compaction)\n", report.cutoff, eligible)
+	fmt.Printf("Preserved raw: %d messages, %d summaries, %d tokens\n\n", report.tailMessages, report.tailSummaries, report.tailTokens)
+	for _, item := range report.items {
+		if item.itemType == "summary" {
+			fmt.Printf("  %5d  summary %s (d%d, %dt)\n", item.ordinal, item.summaryID, item.depth, item.tokenCount)
+			continue
+		}
+		fmt.Printf("  %5d  #%-8d %-9s %5dt  %s  %s\n",
+			item.ordinal, item.messageID, strings.ToUpper(item.role), item.tokenCount, item.createdAt, item.preview)
+	}
+}
+
+// runFreshTailCommand executes the standalone fresh-tail CLI path.
+func runFreshTailCommand(args []string) error {
+	opts, conversationID, err := parseFreshTailArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if !opts.countSet {
+		settings, err := loadConversationSettings(ctx, db, conversationID)
+		if err != nil {
+			return err
+		}
+		if value, ok := settings["fresh-tail"]; ok {
+			opts.count = value
+			fmt.Printf("Using fresh-tail %d from lcm-tui settings %d.\n", value, conversationID)
+		}
+	}
+	report, err := buildFreshTailReport(ctx, db, conversationID, opts.count)
+	if err != nil {
+		return err
+	}
+	printFreshTailReport(conversationID, report)
+	return nil
+}
+
+func parseFreshTailArgs(args []string) (freshTailOptions, int64, error) {
+	fs := flag.NewFlagSet("fresh-tail", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	count := fs.Int("count", defaultBackfillCompactionOptions().freshTailCount, "number of freshest raw messages backfill preserves")
+
+	normalized, err := normalizeFreshTailArgs(args)
+	if err != nil {
+		return freshTailOptions{}, 0, fmt.Errorf("%w\n%s", err, freshTailUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return freshTailOptions{}, 0, errors.New(freshTailUsageText())
+		}
+		return freshTailOptions{}, 0, fmt.Errorf("%w\n%s", err, freshTailUsageText())
+	}
+	if fs.NArg() != 1 {
+		return freshTailOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", freshTailUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return freshTailOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), freshTailUsageText())
+	}
+	if *count < 0 {
+		return freshTailOptions{}, 0, fmt.Errorf("--count must be >= 0\n%s", freshTailUsageText())
+	}
+	return freshTailOptions{count: *count, countSet: explicitFlags(fs)["count"]}, conversationID, nil
+}
+
+func normalizeFreshTailArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--count":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func freshTailUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui fresh-tail <conversation_id> [--count <n>]
+
+Lists the context items backfill compaction leaves raw with --fresh-tail <n>:
+the cutoff ordinal, then each preserved message with its ordinal, role,
+tokens, timestamp, and a preview. Uses the same cutoff rule as backfill.
+Read-only.
+
+Flags:
+  --count <n>   fresh-tail size to preview (default 32, same as backfill)
+`)
+}
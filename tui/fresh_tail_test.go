@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFreshTailReportMatchesCompactionCutoff(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-tail', 'Tail')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 0, 'user', 'old question', 5, '2026-03-01 09:00:00'),
+			(2, 1, 1, 'assistant', 'old answer', 7, '2026-03-01 09:01:00'),
+			(3, 1, 2, 'user', 'recent
+question', 11, '2026-03-01 09:02:00'),
+			(4, 1, 3, 'assistant', 'recent answer', 13, '2026-03-01 09:03:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_mid', 1, 'leaf', 0, 'mid', 3, '2026-03-01 09:02:30')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES
+			(1, 0, 'message', 1, NULL),
+			(1, 1, 'message', 2, NULL),
+			(1, 2, 'message', 3, NULL),
+			(1, 3, 'summary', NULL, 'sum_mid'),
+			(1, 4, 'message', 4, NULL)
+	`)
+	ctx := context.Background()
+
+	report, err := buildFreshTailReport(ctx, db, 1, 2)
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	if !report.hasCutoff || report.cutoff != 2 {
+		t.Fatalf("cutoff = %d (has=%v), want ordinal 2", report.cutoff, report.hasCutoff)
+	}
+	if report.tailMessages != 2 || report.tailSummaries != 1 || report.tailTokens != 27 || len(report.items) != 3 {
+		t.Fatalf("unexpected tail totals %+v", report)
+	}
+	first := report.items[0]
+	if first.messageID != 3 || first.role != "user" || first.preview != "recent question" || first.createdAt != "2026-03-01 09:02:00" {
+		t.Fatalf("unexpected first tail item %+v", first)
+	}
+	if report.items[1].summaryID != "sum_mid" {
+		t.Fatalf("expected summary inside the tail, got %+v", report.items[1])
+	}
+
+	all, err := buildFreshTailReport(ctx, db, 1, 10)
+	if err != nil {
+		t.Fatalf("build oversized report: %v", err)
+	}
+	if all.cutoff != 0 || all.tailMessages != 4 {
+		t.Fatalf("oversized tail should keep every message, got cutoff=%d messages=%d", all.cutoff, all.tailMessages)
+	}
+
+	disabled, err := buildFreshTailReport(ctx, db, 1, 0)
+	if err != nil {
+		t.Fatalf("build disabled report: %v", err)
+	}
+	if disabled.hasCutoff || len(disabled.items) != 0 || disabled.contextMsgs != 4 {
+		t.Fatalf("--count 0 should preserve nothing, got %+v", disabled)
+	}
+}
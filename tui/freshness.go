@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type freshnessOptions struct {
+	staleOnly bool
+}
+
+// summaryFreshness compares when a summary was written with the newest leaf
+// message beneath it. A source message newer than the summary means the
+// summary was generated before its segment was complete.
+type summaryFreshness struct {
+	summaryID    string
+	kind         string
+	depth        int
+	createdAt    string
+	latestSource string // MAX(messages.created_at) under the summary; "" when none linked
+	lag          time.Duration
+	known        bool // both timestamps present and parseable
+}
+
+// stale reports whether any linked source message postdates the summary.
+func (f summaryFreshness) stale() bool {
+	return f.known && f.lag < 0
+}
+
+// describe renders the freshness verdict for the detail pane and CLI.
+func (f summaryFreshness) describe() string {
+	switch {
+	case f.latestSource == "":
+		return "unknown (no linked source messages)"
+	case !f.known:
+		return fmt.Sprintf("unknown (cannot compare created_at %q with newest source %q)", f.createdAt, f.latestSource)
+	case f.stale():
+		return fmt.Sprintf("STALE: newest source is %s newer than the summary", formatFreshnessGap(-f.lag))
+	default:
+		return fmt.Sprintf("written %s after newest source", formatFreshnessGap(f.lag))
+	}
+}
+
+func formatFreshnessGap(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	text := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// buildSummaryFreshness finds the newest leaf message under node with the
+// same recursive walk rewrite uses for prompt time ranges.
+func buildSummaryFreshness(ctx context.Context, q sqlQueryer, node *summaryNode) (summaryFreshness, error) {
+	f := summaryFreshness{
+		summaryID: node.id,
+		kind:      node.kind,
+		depth:     node.depth,
+		createdAt: strings.TrimSpace(node.createdAt),
+	}
+	_, latest, err := lcm.SummaryLeafTimeRange(ctx, q, node.id)
+	if err != nil {
+		return summaryFreshness{}, fmt.Errorf("derive newest source for %s: %w", node.id, err)
+	}
+	f.latestSource = latest
+	if f.latestSource == "" || f.createdAt == "" {
+		return f, nil
+	}
+	created, err := lcm.ParseSQLiteTime(f.createdAt)
+	if err != nil {
+		return f, nil
+	}
+	newest, err := lcm.ParseSQLiteTime(f.latestSource)
+	if err != nil {
+		return f, nil
+	}
+	f.lag = created.Sub(newest)
+	f.known = true
+	return f, nil
+}
+
+// loadSummaryFreshness opens the DB for a single detail-pane lookup.
+func loadSummaryFreshness(dbPath string, node *summaryNode) (summaryFreshness, error) {
+	db, err := openLCMDB(dbPath)
+	if err != nil {
+		return summaryFreshness{}, err
+	}
+	defer db.Close()
+	return buildSummaryFreshness(context.Background(), db, node)
+}
+
+// runFreshnessCommand audits every summary in one conversation.
+func runFreshnessCommand(args []string) error {
+	opts, conversationID, err := parseFreshnessArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	nodes, err := loadSummaryNodes(db, conversationID)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		fmt.Printf("No summaries found in conversation %d.\n", conversationID)
+		return nil
+	}
+
+	ctx := context.Background()
+	results := make([]summaryFreshness, 0, len(nodes))
+	stale := 0
+	for _, node := range nodes {
+		f, err := buildSummaryFreshness(ctx, db, node)
+		if err != nil {
+			return err
+		}
+		if f.stale() {
+			stale++
+		}
+		if opts.staleOnly && !f.stale() {
+			continue
+		}
+		results = append(results, f)
+	}
+	sortSummaryFreshness(results)
+
+	fmt.Printf("Summary freshness for conversation %d: %d summaries, %d stale\n\n", conversationID, len(nodes), stale)
+	for _, f := range results {
+		fmt.Printf("  %-28s d%d %-9s created %s  %s\n", f.summaryID, f.depth, f.kind, f.createdAt, f.describe())
+	}
+	if stale > 0 {
+		fmt.Println("\nStale summaries were generated before some of their source messages; consider `lcm-tui rewrite`.")
+	}
+	return nil
+}
+
+// sortSummaryFreshness puts stale summaries first, most stale at the top,
+// then the rest by depth and id.
+func sortSummaryFreshness(results []summaryFreshness) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.stale() != b.stale() {
+			return a.stale()
+		}
+		if a.stale() && a.lag != b.lag {
+			return a.lag < b.lag
+		}
+		if a.depth != b.depth {
+			return a.depth < b.depth
+		}
+		return a.summaryID < b.summaryID
+	})
+}
+
+func parseFreshnessArgs(args []string) (freshnessOptions, int64, error) {
+	fs := flag.NewFlagSet("freshness", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	staleOnly := fs.Bool("stale-only", false, "list only summaries with newer source messages")
+
+	normalized := normalizePruneArgs(args)
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return freshnessOptions{}, 0, errors.New(freshnessUsageText())
+		}
+		return freshnessOptions{}, 0, fmt.Errorf("%w\n%s", err, freshnessUsageText())
+	}
+	if fs.NArg() != 1 {
+		return freshnessOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", freshnessUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return freshnessOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), freshnessUsageText())
+	}
+	return freshnessOptions{staleOnly: *staleOnly}, conversationID, nil
+}
+
+func freshnessUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui freshness <conversation_id> [--stale-only]
+
+Compares each summary's created_at with the newest leaf message beneath it.
+A summary is STALE when a linked source message was created after the
+summary, so it may not reflect its full segment. Read-only.
+
+Flags:
+  --stale-only   list only stale summaries
+`)
+}
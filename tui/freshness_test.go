@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSummaryFreshnessFlagsNewerSourceMessages(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-fresh', 'Fresh')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 1, 'user', 'a', 1, '2026-03-01 09:00:00'),
+			(2, 1, 2, 'assistant', 'b', 1, '2026-03-01 10:00:00'),
+			(3, 1, 3, 'user', 'c', 1, '2026-03-02 08:30:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_leaf_a', 1, 'leaf', 0, 'a', 1, '2026-03-01 10:05:00'),
+			('sum_leaf_b', 1, 'leaf', 0, 'b', 1, '2026-03-02 06:30:00'),
+			('sum_top', 1, 'condensed', 1, 'top', 1, '2026-03-02 07:00:00'),
+			('sum_empty', 1, 'leaf', 0, 'none', 1, '2026-03-02 09:00:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_leaf_a', 1, 0), ('sum_leaf_a', 2, 1), ('sum_leaf_b', 3, 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_top', 'sum_leaf_a', 0), ('sum_top', 'sum_leaf_b', 1)
+	`)
+
+	nodes, err := loadSummaryNodes(db, 1)
+	if err != nil {
+		t.Fatalf("load nodes: %v", err)
+	}
+	results := make([]summaryFreshness, 0, len(nodes))
+	for _, node := range nodes {
+		f, err := buildSummaryFreshness(ctx, db, node)
+		if err != nil {
+			t.Fatalf("build freshness for %s: %v", node.id, err)
+		}
+		results = append(results, f)
+	}
+	sortSummaryFreshness(results)
+
+	var order []string
+	for _, f := range results {
+		order = append(order, f.summaryID)
+	}
+	if got := strings.Join(order, ","); got != "sum_leaf_b,sum_top,sum_empty,sum_leaf_a" {
+		t.Fatalf("order = %s, want most stale first", got)
+	}
+	if got := results[0].describe(); got != "STALE: newest source is 2h newer than the summary" {
+		t.Fatalf("leaf_b verdict = %q", got)
+	}
+	if got := results[1].describe(); got != "STALE: newest source is 1h30m newer than the summary" {
+		t.Fatalf("top verdict = %q", got)
+	}
+	if results[2].stale() || !strings.Contains(results[2].describe(), "no linked source") {
+		t.Fatalf("empty verdict = %q", results[2].describe())
+	}
+	if results[3].stale() || results[3].describe() != "written 5m after newest source" {
+		t.Fatalf("fresh verdict = %q", results[3].describe())
+	}
+}
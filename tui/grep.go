@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+const (
+	defaultGrepLimit   = 50
+	defaultGrepSnippet = 80
+)
+
+type grepOptions struct {
+	pattern        string
+	conversationID int64
+	all            bool
+	ignoreCase     bool
+	regex          bool
+	messages       bool
+	jsonOutput     bool
+	limit          int
+	snippet        int
+	// contentMaxChars caps each snippet, which a broad regex match can make
+	// as long as the whole row; 0 disables.
+	contentMaxChars int
+}
+
+// grepMatch is one summary or message whose content matched. The JSON tags
+// are the --json output contract.
+type grepMatch struct {
+	Source         string `json:"source"` // "summary" or "message"
+	ConversationID int64  `json:"conversation_id"`
+	SummaryID      string `json:"summary_id,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+	Depth          *int   `json:"depth,omitempty"`
+	MessageID      int64  `json:"message_id,omitempty"`
+	Role           string `json:"role,omitempty"`
+	Seq            *int64 `json:"seq,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	MatchCount     int    `json:"match_count"`
+	Snippet        string `json:"snippet"`
+}
+
+type grepReport struct {
+	Pattern   string      `json:"pattern"`
+	Matches   []grepMatch `json:"matches"`
+	Total     int         `json:"total"`
+	Truncated bool        `json:"truncated"`
+}
+
+// runGrepCommand searches summary (and optionally message) content, the CLI
+// counterpart of the plugin's lcm_grep tool. Read-only.
+func runGrepCommand(args []string) error {
+	opts, err := parseGrepArgs(args)
+	if err != nil {
+		return err
+	}
+	matcher, err := compileGrepPattern(opts)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := searchLCM(context.Background(), db, opts, matcher)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("encode grep report: %w", err)
+		}
+		return nil
+	}
+	printGrepReport(report, opts)
+	return nil
+}
+
+// compileGrepPattern turns the pattern into a Go regexp. Plain patterns are
+// quoted so they match literally.
+func compileGrepPattern(opts grepOptions) (*regexp.Regexp, error) {
+	expr := opts.pattern
+	if !opts.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if opts.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	matcher, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --regex pattern %q: %w", opts.pattern, err)
+	}
+	return matcher, nil
+}
+
+// searchLCM scans summaries, then messages when requested, stopping once
+// opts.limit matches are collected. Literal patterns are prefiltered in SQL;
+// regex patterns are matched in Go because SQLite has no REGEXP by default.
+func searchLCM(ctx context.Context, q sqlQueryer, opts grepOptions, matcher *regexp.Regexp) (grepReport, error) {
+	report := grepReport{Pattern: opts.pattern, Matches: []grepMatch{}}
+
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return grepReport{}, err
+	}
+	where, args := grepScopeClause(opts, "s")
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.conversation_id, s.summary_id, s.kind, s.depth, s.content, s.created_at
+		FROM summaries s
+		WHERE `+where+`
+		ORDER BY s.conversation_id ASC, s.created_at ASC, `+caps.SummarySeqExpr("s")+` ASC, s.summary_id ASC
+	`, args...)
+	if err != nil {
+		return grepReport{}, fmt.Errorf("query summaries for grep: %w", err)
+	}
+	for rows.Next() {
+		var (
+			match   grepMatch
+			depth   int
+			content string
+		)
+		if err := rows.Scan(&match.ConversationID, &match.SummaryID, &match.Kind, &depth, &content, &match.CreatedAt); err != nil {
+			rows.Close()
+			return grepReport{}, fmt.Errorf("scan summary for grep: %w", err)
+		}
+		match.Source = "summary"
+		match.Depth = &depth
+		if addGrepMatch(&report, match, content, matcher, opts) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return grepReport{}, fmt.Errorf("iterate summaries for grep: %w", err)
+	}
+	rows.Close()
+
+	if !opts.messages || report.Truncated {
+		return report, nil
+	}
+
+	where, args = grepScopeClause(opts, "m")
+	rows, err = q.QueryContext(ctx, `
+		SELECT m.conversation_id, m.message_id, m.role, m.seq, m.content, m.created_at
+		FROM messages m
+		WHERE `+where+`
+		ORDER BY m.conversation_id ASC, m.seq ASC
+	`, args...)
+	if err != nil {
+		return grepReport{}, fmt.Errorf("query messages for grep: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			match   grepMatch
+			seq     int64
+			content string
+		)
+		if err := rows.Scan(&match.ConversationID, &match.MessageID, &match.Role, &seq, &content, &match.CreatedAt); err != nil {
+			return grepReport{}, fmt.Errorf("scan message for grep: %w", err)
+		}
+		match.Source = "message"
+		match.Seq = &seq
+		if addGrepMatch(&report, match, content, matcher, opts) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return grepReport{}, fmt.Errorf("iterate messages for grep: %w", err)
+	}
+	return report, nil
+}
+
+// grepScopeClause builds the WHERE clause for the conversation scope plus,
+// for literal patterns, a content prefilter that the Go matcher then
+// confirms. instr is exact. LIKE folds ASCII case only, so with -i it is
+// used only when every case variant of the pattern is ASCII; otherwise rows
+// whose only match is a non-ASCII variant (É for é, K for the Kelvin sign)
+// would be dropped before the matcher sees them.
+func grepScopeClause(opts grepOptions, alias string) (string, []any) {
+	column := alias + ".content"
+	clauses := []string{"1 = 1"}
+	var args []any
+	if !opts.all {
+		clauses = append(clauses, alias+".conversation_id = ?")
+		args = append(args, opts.conversationID)
+	}
+	if !opts.regex {
+		if opts.ignoreCase {
+			if asciiCaseFolds(opts.pattern) {
+				clauses = append(clauses, column+` LIKE ? ESCAPE '\'`)
+				args = append(args, "%"+escapeLikePattern(opts.pattern)+"%")
+			}
+		} else {
+			clauses = append(clauses, "instr("+column+", ?) > 0")
+			args = append(args, opts.pattern)
+		}
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// asciiCaseFolds reports whether every case variant of every rune in pattern
+// is ASCII, i.e. whether SQLite's LIKE folds it the way (?i) does.
+func asciiCaseFolds(pattern string) bool {
+	for _, r := range pattern {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f >= utf8.RuneSelf {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// escapeLikePattern escapes LIKE wildcards so the pattern matches literally.
+func escapeLikePattern(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+}
+
+// addGrepMatch records content if matcher finds it and reports whether the
+// limit has been reached.
+func addGrepMatch(report *grepReport, match grepMatch, content string, matcher *regexp.Regexp, opts grepOptions) bool {
+	locs := matcher.FindAllStringIndex(content, -1)
+	if len(locs) == 0 {
+		return false
+	}
+	if opts.limit > 0 && report.Total >= opts.limit {
+		report.Truncated = true
+		return true
+	}
+	match.MatchCount = len(locs)
+	match.Snippet, _ = truncateContent(grepSnippet(content, locs[0][0], locs[0][1], opts.snippet), opts.contentMaxChars)
+	report.Matches = append(report.Matches, match)
+	report.Total++
+	return false
+}
+
+// grepSnippet returns about width characters of context on each side of the
+// first match, on one line, with ellipses where content was cut.
+func grepSnippet(content string, start, end, width int) string {
+	from := max(0, start-width)
+	to := min(len(content), end+width)
+	for from > 0 && !isRuneStart(content[from]) {
+		from--
+	}
+	for to < len(content) && !isRuneStart(content[to]) {
+		to++
+	}
+	snippet := strings.Join(strings.Fields(content[from:start]+"["+content[start:end]+"]"+content[end:to]), " ")
+	if from > 0 {
+		snippet = "..." + snippet
+	}
+	if to < len(content) {
+		snippet += "..."
+	}
+	return snippet
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func printGrepReport(report grepReport, opts grepOptions) {
+	if report.Total == 0 {
+		fmt.Printf("No matches for %q.\n", opts.pattern)
+		return
+	}
+	for _, match := range report.Matches {
+		if match.Source == "summary" {
+			fmt.Printf("conv %d  %s  d%d %s  (%d)\n", match.ConversationID, match.SummaryID, *match.Depth, match.Kind, match.MatchCount)
+		} else {
+			fmt.Printf("conv %d  msg #%d  %s seq %d  (%d)\n", match.ConversationID, match.MessageID, match.Role, *match.Seq, match.MatchCount)
+		}
+		fmt.Printf("  %s\n", match.Snippet)
+	}
+	fmt.Println()
+	if report.Truncated {
+		fmt.Printf("%d matches shown (limit reached; raise --limit to see more).\n", report.Total)
+		return
+	}
+	fmt.Printf("%d matches.\n", report.Total)
+}
+
+func parseGrepArgs(args []string) (grepOptions, error) {
+	fs := flag.NewFlagSet("grep", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conversation := fs.Int64("conversation", 0, "conversation ID to search")
+	all := fs.Bool("all-conversations", false, "search every conversation")
+	ignoreCase := fs.Bool("ignore-case", false, "case-insensitive match")
+	fs.BoolVar(ignoreCase, "i", false, "case-insensitive match")
+	regex := fs.Bool("regex", false, "treat the pattern as a Go regular expression")
+	messages := fs.Bool("messages", false, "also search raw message content")
+	jsonOutput := fs.Bool("json", false, "print matches as JSON")
+	limit := fs.Int("limit", defaultGrepLimit, "maximum matches to print (0 = no limit)")
+	snippet := fs.Int("snippet", defaultGrepSnippet, "characters of context on each side of a match")
+	contentMaxChars := fs.Int("content-max-chars", resolveContentMaxChars(), "characters of each snippet to print (0 = no limit)")
+	full := fs.Bool("full", false, "print snippets in full")
+
+	normalizedArgs, err := normalizeGrepArgs(args)
+	if err != nil {
+		return grepOptions{}, fmt.Errorf("%w\n%s", err, grepUsageText())
+	}
+	if err := fs.Parse(normalizedArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return grepOptions{}, errors.New(grepUsageText())
+		}
+		return grepOptions{}, fmt.Errorf("%w\n%s", err, grepUsageText())
+	}
+	if fs.NArg() != 1 || fs.Arg(0) == "" {
+		return grepOptions{}, fmt.Errorf("pattern is required\n%s", grepUsageText())
+	}
+	if *all == (*conversation > 0) {
+		return grepOptions{}, fmt.Errorf("pass exactly one of --conversation <id> or --all-conversations\n%s", grepUsageText())
+	}
+	if *conversation < 0 {
+		return grepOptions{}, fmt.Errorf("invalid conversation ID %s\n%s", strconv.FormatInt(*conversation, 10), grepUsageText())
+	}
+	if *limit < 0 {
+		return grepOptions{}, fmt.Errorf("--limit must be >= 0\n%s", grepUsageText())
+	}
+	if *snippet < 0 {
+		return grepOptions{}, fmt.Errorf("--snippet must be >= 0\n%s", grepUsageText())
+	}
+	contentLimit, err := resolveContentLimitFlags(*contentMaxChars, *full)
+	if err != nil {
+		return grepOptions{}, fmt.Errorf("%w\n%s", err, grepUsageText())
+	}
+	return grepOptions{
+		pattern:        fs.Arg(0),
+		conversationID: *conversation,
+		all:            *all,
+		ignoreCase:     *ignoreCase,
+		regex:          *regex,
+		messages:       *messages,
+		jsonOutput:     *jsonOutput,
+		limit:          *limit,
+		snippet:        *snippet,
+
+		contentMaxChars: contentLimit,
+	}, nil
+}
+
+func normalizeGrepArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	takesValue := map[string]bool{
+		"--conversation": true,
+		"--limit":        true,
+		"--snippet":      true,
+
+		"--content-max-chars": true,
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case takesValue[arg]:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case arg == "--":
+			positionals = append(positionals, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func grepUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui grep <pattern> --conversation <id> [flags]
+  lcm-tui grep <pattern> --all-conversations [flags]
+
+Searches summary content (and raw messages with --messages) and prints each
+match with its conversation, summary ID, depth, and a snippet. Read-only.
+Use -- before a pattern that starts with a dash.
+
+Flags:
+  --conversation <id>    search one conversation
+  --all-conversations    search every conversation
+  -i, --ignore-case      case-insensitive match
+  --regex                treat the pattern as a Go regular expression (RE2)
+  --messages             also search messages.content
+  --json                 print matches as JSON
+  --limit <n>            maximum matches to print (default 50, 0 = no limit)
+  --snippet <n>          characters of context on each side of a match (default 80)
+  --content-max-chars <n>
+                         cut each snippet after n characters (default 100000, or
+                         LCM_TUI_CONTENT_MAX_CHARS; 0 = no limit)
+  --full                 print snippets in full
+`)
+}
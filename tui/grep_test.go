@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSearchLCMFindsSummariesAndMessages(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title)
+		VALUES (1, 'session-a', 'A'), (2, 'session-b', 'B')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_a', 1, 'leaf', 0, 'Migrated the Postgres pool to pgx.', 8, '2026-03-01T10:00:00Z'),
+			('sum_b', 1, 'condensed', 1, 'No database work this week; 100% docs.', 8, '2026-03-02T10:00:00Z'),
+			('sum_c', 2, 'leaf', 0, 'postgres upgrade planned', 4, '2026-03-01T11:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES (10, 1, 0, 'user', 'can you look at postgres?', 5, '2026-03-01T09:00:00Z')
+	`)
+	ctx := context.Background()
+
+	search := func(opts grepOptions) grepReport {
+		t.Helper()
+		matcher, err := compileGrepPattern(opts)
+		if err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+		report, err := searchLCM(ctx, db, opts, matcher)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		return report
+	}
+
+	report := search(grepOptions{pattern: "Postgres", conversationID: 1, snippet: 10})
+	if report.Total != 1 || report.Matches[0].SummaryID != "sum_a" || *report.Matches[0].Depth != 0 {
+		t.Fatalf("expected only sum_a, got %+v", report.Matches)
+	}
+	if got := report.Matches[0].Snippet; got != "...rated the [Postgres] pool to p..." {
+		t.Fatalf("unexpected snippet %q", got)
+	}
+
+	report = search(grepOptions{pattern: "postgres", all: true, ignoreCase: true, messages: true})
+	if report.Total != 3 || report.Matches[2].Source != "message" || report.Matches[2].MessageID != 10 {
+		t.Fatalf("expected two summaries and one message, got %+v", report.Matches)
+	}
+
+	// LIKE wildcards in a literal pattern must not match everything.
+	report = search(grepOptions{pattern: "100%", all: true, ignoreCase: true})
+	if report.Total != 1 || report.Matches[0].SummaryID != "sum_b" {
+		t.Fatalf("expected literal %% match on sum_b, got %+v", report.Matches)
+	}
+
+	// -i must find non-ASCII case variants that LIKE does not fold.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_d', 2, 'leaf', 0, 'Met at the CAFÉ downtown', 4, '2026-03-03T10:00:00Z')
+	`)
+	report = search(grepOptions{pattern: "café", all: true, ignoreCase: true})
+	if report.Total != 1 || report.Matches[0].SummaryID != "sum_d" {
+		t.Fatalf("expected case-insensitive non-ASCII match on sum_d, got %+v", report.Matches)
+	}
+	if asciiCaseFolds("kelvin") || asciiCaseFolds("é") || !asciiCaseFolds("100%") {
+		t.Fatal("asciiCaseFolds should reject patterns with non-ASCII case variants only")
+	}
+
+	report = search(grepOptions{pattern: `pg[x]|upgrade`, all: true, regex: true, limit: 1})
+	if report.Total != 1 || !report.Truncated || report.Matches[0].SummaryID != "sum_a" {
+		t.Fatalf("expected regex hit truncated at limit 1, got %+v", report)
+	}
+}
+
+func TestParseGrepArgsRequiresScope(t *testing.T) {
+	if _, err := parseGrepArgs([]string{"needle"}); err == nil || !strings.Contains(err.Error(), "--all-conversations") {
+		t.Fatalf("expected scope error, got %v", err)
+	}
+	opts, err := parseGrepArgs([]string{"needle", "--conversation", "7", "-i", "--json"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.pattern != "needle" || opts.conversationID != 7 || !opts.ignoreCase || !opts.jsonOutput || opts.limit != defaultGrepLimit {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if _, err := parseGrepArgs([]string{"(", "--all-conversations", "--regex"}); err != nil {
+		t.Fatalf("parse should defer regex validation: %v", err)
+	}
+	if _, err := compileGrepPattern(grepOptions{pattern: "(", regex: true}); err == nil {
+		t.Fatal("expected invalid regex error")
+	}
+}
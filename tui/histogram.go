@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// summaryHistogramBounds are the exclusive upper token bounds of each bucket;
+// anything at or above the last bound lands in a final open-ended bucket.
+var summaryHistogramBounds = []int{256, 512, 1024, 2048, 4096, 8192}
+
+const (
+	defaultHistogramTop  = 10
+	histogramMinBarWidth = 10
+)
+
+type histogramOptions struct {
+	top       int
+	clipboard bool
+}
+
+// summaryDepthHistogram holds bucket counts for summaries at one depth.
+type summaryDepthHistogram struct {
+	depth       int
+	counts      []int
+	summaries   int
+	totalTokens int
+}
+
+// summaryTokenHistogram buckets a conversation's summaries by token_count per
+// depth and keeps the largest summaries for follow-up rewrite or dissolve.
+type summaryTokenHistogram struct {
+	depths  []summaryDepthHistogram
+	largest []*summaryNode
+}
+
+// summaryHistogramBucketLabel names bucket idx, e.g. "<256" or "8192+".
+func summaryHistogramBucketLabel(idx int) string {
+	switch {
+	case idx == 0:
+		return fmt.Sprintf("<%d", summaryHistogramBounds[0])
+	case idx >= len(summaryHistogramBounds):
+		return fmt.Sprintf("%d+", summaryHistogramBounds[len(summaryHistogramBounds)-1])
+	default:
+		return fmt.Sprintf("%d-%d", summaryHistogramBounds[idx-1], summaryHistogramBounds[idx]-1)
+	}
+}
+
+func summaryHistogramBucket(tokens int) int {
+	for idx, bound := range summaryHistogramBounds {
+		if tokens < bound {
+			return idx
+		}
+	}
+	return len(summaryHistogramBounds)
+}
+
+// buildSummaryTokenHistogram works from already-loaded summary nodes so the
+// TUI overlay and the CLI share one implementation.
+func buildSummaryTokenHistogram(nodes map[string]*summaryNode, top int) summaryTokenHistogram {
+	byDepth := make(map[int]*summaryDepthHistogram)
+	all := make([]*summaryNode, 0, len(nodes))
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		hist := byDepth[node.depth]
+		if hist == nil {
+			hist = &summaryDepthHistogram{depth: node.depth, counts: make([]int, len(summaryHistogramBounds)+1)}
+			byDepth[node.depth] = hist
+		}
+		hist.counts[summaryHistogramBucket(node.tokenCount)]++
+		hist.summaries++
+		hist.totalTokens += node.tokenCount
+		all = append(all, node)
+	}
+
+	result := summaryTokenHistogram{}
+	for _, hist := range byDepth {
+		result.depths = append(result.depths, *hist)
+	}
+	sort.Slice(result.depths, func(i, j int) bool {
+		return result.depths[i].depth < result.depths[j].depth
+	})
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].tokenCount != all[j].tokenCount {
+			return all[i].tokenCount > all[j].tokenCount
+		}
+		return all[i].id < all[j].id
+	})
+	if top < len(all) {
+		all = all[:top]
+	}
+	result.largest = all
+	return result
+}
+
+// renderSummaryTokenHistogram draws one ASCII histogram per depth followed by
+// the largest summaries. Bars scale to the fullest bucket within each depth.
+func renderSummaryTokenHistogram(hist summaryTokenHistogram, width int) []string {
+	return renderStyledSummaryTokenHistogram(hist, cliOutputStyle{width: width})
+}
+
+// renderStyledSummaryTokenHistogram is renderSummaryTokenHistogram with CLI
+// color; the TUI overlay uses the plain form.
+func renderStyledSummaryTokenHistogram(hist summaryTokenHistogram, style cliOutputStyle) []string {
+	if len(hist.depths) == 0 {
+		return []string{"No summaries."}
+	}
+	barWidth := max(histogramMinBarWidth, style.width-24)
+	labelWidth := len(summaryHistogramBucketLabel(len(summaryHistogramBounds) - 1))
+
+	lines := make([]string, 0, len(hist.depths)*(len(summaryHistogramBounds)+3)+len(hist.largest)+4)
+	for _, depth := range hist.depths {
+		lines = append(lines, style.paint(cliSGRBold, fmt.Sprintf("d%d: %d summaries, %d tokens", depth.depth, depth.summaries, depth.totalTokens)))
+		peak := 0
+		for _, count := range depth.counts {
+			peak = max(peak, count)
+		}
+		for idx, count := range depth.counts {
+			bar := 0
+			if peak > 0 {
+				bar = count * barWidth / peak
+			}
+			if count > 0 && bar == 0 {
+				bar = 1
+			}
+			lines = append(lines, fmt.Sprintf("  %*s | %s %d", labelWidth, summaryHistogramBucketLabel(idx), style.paint(cliSGRCyan, strings.Repeat("#", bar)), count))
+		}
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, fmt.Sprintf("Top %d largest summaries:", len(hist.largest)))
+	table := cliTable{
+		indent: "  ",
+		columns: []cliTableColumn{
+			{header: "summary", flex: true},
+			{header: "depth"},
+			{header: "kind"},
+			{header: "tokens", align: cliAlignRight, sgr: cliSGRYellow},
+		},
+	}
+	for _, node := range hist.largest {
+		table.addRow(node.id, fmt.Sprintf("d%d", node.depth), node.kind, fmt.Sprintf("%dt", node.tokenCount))
+	}
+	return append(lines, table.render(style)...)
+}
+
+// runHistogramCommand prints the token histogram for one conversation.
+func runHistogramCommand(args []string) error {
+	opts, conversationID, err := parseHistogramArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	nodes, err := loadSummaryNodes(db, conversationID)
+	if err != nil {
+		return err
+	}
+	out := newCLIOutput(opts.clipboard)
+	if len(nodes) == 0 {
+		fmt.Fprintf(out.w, "No summaries found in conversation %d.\n", conversationID)
+		return out.flush()
+	}
+
+	fmt.Fprintf(out.w, "Summary token histogram for conversation %d (%d summaries)\n\n", conversationID, len(nodes))
+	for _, line := range renderStyledSummaryTokenHistogram(buildSummaryTokenHistogram(nodes, opts.top), out.style()) {
+		fmt.Fprintln(out.w, line)
+	}
+	return out.flush()
+}
+
+func parseHistogramArgs(args []string) (histogramOptions, int64, error) {
+	fs := flag.NewFlagSet("histogram", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	top := fs.Int("top", defaultHistogramTop, "number of largest summaries to list")
+	clipboard := fs.Bool("clipboard", false, "copy the output to the system clipboard instead of stdout")
+
+	normalizedArgs, err := normalizeHistogramArgs(args)
+	if err != nil {
+		return histogramOptions{}, 0, fmt.Errorf("%w\n%s", err, histogramUsageText())
+	}
+	if err := fs.Parse(normalizedArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return histogramOptions{}, 0, errors.New(histogramUsageText())
+		}
+		return histogramOptions{}, 0, fmt.Errorf("%w\n%s", err, histogramUsageText())
+	}
+	if fs.NArg() != 1 {
+		return histogramOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", histogramUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return histogramOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), histogramUsageText())
+	}
+	if *top < 0 {
+		return histogramOptions{}, 0, fmt.Errorf("--top must be >= 0\n%s", histogramUsageText())
+	}
+	return histogramOptions{top: *top, clipboard: *clipboard}, conversationID, nil
+}
+
+func normalizeHistogramArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--top":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func histogramUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui histogram <conversation_id> [--top <n>] [--clipboard]
+
+Buckets summaries by token_count per depth and lists the largest summaries,
+to show whether context size comes from many medium leaves or a few giant
+condensed nodes. Read-only.
+
+Flags:
+  --top <n>     number of largest summaries to list (default 10)
+  --clipboard   copy the output to the system clipboard instead of stdout
+`)
+}
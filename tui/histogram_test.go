@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSummaryTokenHistogramBucketsPerDepth(t *testing.T) {
+	nodes := map[string]*summaryNode{
+		"sum_a": {id: "sum_a", kind: "leaf", depth: 0, tokenCount: 100},
+		"sum_b": {id: "sum_b", kind: "leaf", depth: 0, tokenCount: 300},
+		"sum_c": {id: "sum_c", kind: "leaf", depth: 0, tokenCount: 310},
+		"sum_d": {id: "sum_d", kind: "condensed", depth: 1, tokenCount: 9000},
+		"sum_e": {id: "sum_e", kind: "condensed", depth: 1, tokenCount: 256},
+	}
+
+	hist := buildSummaryTokenHistogram(nodes, 2)
+	if len(hist.depths) != 2 || hist.depths[0].depth != 0 || hist.depths[1].depth != 1 {
+		t.Fatalf("expected depths [0 1], got %+v", hist.depths)
+	}
+	leaves := hist.depths[0]
+	if leaves.summaries != 3 || leaves.totalTokens != 710 || leaves.counts[0] != 1 || leaves.counts[1] != 2 {
+		t.Fatalf("unexpected d0 buckets %+v", leaves)
+	}
+	condensed := hist.depths[1]
+	if condensed.counts[1] != 1 || condensed.counts[len(summaryHistogramBounds)] != 1 {
+		t.Fatalf("expected 256 in second bucket and 9000 in open bucket, got %+v", condensed.counts)
+	}
+	if len(hist.largest) != 2 || hist.largest[0].id != "sum_d" || hist.largest[1].id != "sum_c" {
+		t.Fatalf("unexpected largest summaries %+v", hist.largest)
+	}
+}
+
+func TestRenderSummaryTokenHistogramScalesBars(t *testing.T) {
+	nodes := map[string]*summaryNode{
+		"sum_a": {id: "sum_a", kind: "leaf", depth: 0, tokenCount: 10},
+		"sum_b": {id: "sum_b", kind: "leaf", depth: 0, tokenCount: 20},
+		"sum_c": {id: "sum_c", kind: "leaf", depth: 0, tokenCount: 5000},
+	}
+	lines := renderSummaryTokenHistogram(buildSummaryTokenHistogram(nodes, defaultHistogramTop), 34)
+	text := strings.Join(lines, "\n")
+
+	if !strings.Contains(text, "d0: 3 summaries, 5030 tokens") {
+		t.Fatalf("missing depth header:\n%s", text)
+	}
+	if !strings.Contains(text, "<256 | "+strings.Repeat("#", histogramMinBarWidth)+" 2") {
+		t.Fatalf("expected full-width bar for peak bucket:\n%s", text)
+	}
+	if !strings.Contains(text, "4096-8191 | ##### 1") {
+		t.Fatalf("expected half-width bar for single outlier:\n%s", text)
+	}
+	if !strings.Contains(text, "Top 3 largest summaries:") || !strings.Contains(lines[len(lines)-3], "sum_c") {
+		t.Fatalf("expected largest summary listed first:\n%s", text)
+	}
+}
+
+func TestParseHistogramArgs(t *testing.T) {
+	opts, conversationID, err := parseHistogramArgs([]string{"--top", "3", "44"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if conversationID != 44 || opts.top != 3 {
+		t.Fatalf("unexpected parse result id=%d opts=%+v", conversationID, opts)
+	}
+	if _, _, err := parseHistogramArgs(nil); err == nil || !strings.Contains(err.Error(), "conversation ID is required") {
+		t.Fatalf("expected missing ID error, got %v", err)
+	}
+	if _, _, err := parseHistogramArgs([]string{"44", "--top", "-1"}); err == nil {
+		t.Fatal("expected negative --top to fail")
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// ignorePatternList is a repeatable --ignore <glob> flag.
+type ignorePatternList []string
+
+func (l *ignorePatternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *ignorePatternList) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("--ignore pattern is empty")
+	}
+	*l = append(*l, value)
+	return nil
+}
+
+// contextIgnoreRules mirror the plugin's ignoreSessionPatterns for the
+// context preview and compaction simulation. The configured patterns are
+// matched against the conversation's session key only, as in the plugin,
+// which never stores a session whose key matches. --ignore globs are matched
+// against message content instead, to drop noise such as cron heartbeats
+// that reached a conversation anyway. A nil value ignores nothing.
+type contextIgnoreRules struct {
+	// patterns is every glob, configured first; the first sessionCount
+	// are the configured ones and the rest came from --ignore.
+	patterns     []string
+	res          []*regexp.Regexp
+	sessionCount int
+	// source says where the configured patterns came from:
+	// LCM_IGNORE_SESSION_PATTERNS, openclaw.json, or "" for --ignore only.
+	source string
+}
+
+// compileIgnorePattern compiles a glob with the plugin's rules from
+// src/session-patterns.ts: "*" matches anything but ":", "**" matches
+// anything, and the whole string must match. "**" also spans newlines so
+// content patterns work on multi-line messages.
+func compileIgnorePattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "**")
+	for i, part := range parts {
+		segments := strings.Split(part, "*")
+		for j, segment := range segments {
+			segments[j] = regexp.QuoteMeta(segment)
+		}
+		parts[i] = strings.Join(segments, "[^:]*")
+	}
+	return regexp.MustCompile(`(?s)^` + strings.Join(parts, ".*") + `$`)
+}
+
+func newContextIgnoreRules(sessionPatterns, contentPatterns []string, source string) *contextIgnoreRules {
+	if len(sessionPatterns)+len(contentPatterns) == 0 {
+		return nil
+	}
+	rules := &contextIgnoreRules{
+		patterns:     append(append([]string(nil), sessionPatterns...), contentPatterns...),
+		sessionCount: len(sessionPatterns),
+		source:       source,
+	}
+	for _, pattern := range rules.patterns {
+		rules.res = append(rules.res, compileIgnorePattern(pattern))
+	}
+	return rules
+}
+
+// matchSessionKey returns the first configured pattern that matches the
+// session key, or "".
+func (r *contextIgnoreRules) matchSessionKey(sessionKey string) string {
+	if r == nil {
+		return ""
+	}
+	return r.matchFrom(0, r.sessionCount, sessionKey)
+}
+
+// matchContent returns the first --ignore glob that matches a message's
+// content, or "".
+func (r *contextIgnoreRules) matchContent(content string) string {
+	if r == nil {
+		return ""
+	}
+	return r.matchFrom(r.sessionCount, len(r.patterns), content)
+}
+
+func (r *contextIgnoreRules) matchFrom(start, end int, text string) string {
+	text = strings.TrimSpace(text)
+	for i := start; i < end; i++ {
+		if r.res[i].MatchString(text) {
+			return r.patterns[i]
+		}
+	}
+	return ""
+}
+
+// contentPatterns are the --ignore globs.
+func (r *contextIgnoreRules) contentPatterns() []string {
+	if r == nil {
+		return nil
+	}
+	return r.patterns[r.sessionCount:]
+}
+
+// describe is "2 patterns (openclaw.json + --ignore): a, b".
+func (r *contextIgnoreRules) describe() string {
+	noun := "patterns"
+	if len(r.patterns) == 1 {
+		noun = "pattern"
+	}
+	source := r.source
+	if source == "" {
+		source = "--ignore"
+	}
+	return fmt.Sprintf("%d %s (%s): %s", len(r.patterns), noun, source, strings.Join(r.patterns, ", "))
+}
+
+// resolveContextIgnoreRules combines the configured ignoreSessionPatterns
+// with --ignore globs. Configuration follows the plugin's precedence:
+// LCM_IGNORE_SESSION_PATTERNS (comma-separated) replaces the plugin config in
+// openclaw.json when set. It returns nil when no patterns apply.
+func resolveContextIgnoreRules(configPath string, cliPatterns []string) (*contextIgnoreRules, error) {
+	var patterns []string
+	source := ""
+	if value, ok := os.LookupEnv("LCM_IGNORE_SESSION_PATTERNS"); ok {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+		source = "LCM_IGNORE_SESSION_PATTERNS"
+	} else {
+		configured, err := readIgnoreSessionPatterns(configPath)
+		if err != nil {
+			return nil, err
+		}
+		patterns = configured
+		source = "openclaw.json"
+	}
+	if len(patterns) == 0 {
+		source = ""
+	}
+	if len(cliPatterns) > 0 && source != "" {
+		source += " + --ignore"
+	}
+	return newContextIgnoreRules(patterns, cliPatterns, source), nil
+}
+
+// readIgnoreSessionPatterns reads
+// plugins.entries.lossless-claw.config.ignoreSessionPatterns. A missing file
+// or key yields no patterns.
+func readIgnoreSessionPatterns(configPath string) ([]string, error) {
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", configPath, err)
+	}
+	var parsed struct {
+		Plugins struct {
+			Entries map[string]struct {
+				Config struct {
+					IgnoreSessionPatterns []string `json:"ignoreSessionPatterns"`
+				} `json:"config"`
+			} `json:"entries"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("parse %s for ignoreSessionPatterns: %w", configPath, err)
+	}
+	var patterns []string
+	for _, pattern := range parsed.Plugins.Entries["lossless-claw"].Config.IgnoreSessionPatterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, nil
+}
+
+// loadConversationSessionKey returns the conversation's session_key, or ""
+// on schemas without the column.
+func loadConversationSessionKey(ctx context.Context, db *sql.DB, conversationID int64) (string, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil || !caps.HasColumn("conversations", "session_key") {
+		return "", err
+	}
+	var sessionKey string
+	err = db.QueryRowContext(ctx, `
+		SELECT COALESCE(session_key, '') FROM conversations WHERE conversation_id = ?
+	`, conversationID).Scan(&sessionKey)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("query session key for conversation %d: %w", conversationID, err)
+	}
+	return sessionKey, nil
+}
+
+// dropIgnoredContextMessages deletes the context items of messages whose
+// content matches an --ignore glob in rules and resequences the rest. It is only run against the
+// in-memory simulation copy. It returns the number of items dropped.
+func dropIgnoredContextMessages(ctx context.Context, q sqlQueryer, conversationID int64, rules *contextIgnoreRules) (int, error) {
+	if len(rules.contentPatterns()) == 0 {
+		return 0, nil
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT ci.ordinal, COALESCE(m.content, '')
+		FROM context_items ci
+		JOIN messages m ON m.message_id = ci.message_id
+		WHERE ci.conversation_id = ? AND ci.item_type = 'message'
+		ORDER BY ci.ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return 0, fmt.Errorf("query context messages for ignore patterns: %w", err)
+	}
+	var ignored []int64
+	for rows.Next() {
+		var ordinal int64
+		var content string
+		if err := rows.Scan(&ordinal, &content); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan context message for ignore patterns: %w", err)
+		}
+		if rules.matchContent(content) != "" {
+			ignored = append(ignored, ordinal)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("iterate context messages for ignore patterns: %w", err)
+	}
+	rows.Close()
+	if len(ignored) == 0 {
+		return 0, nil
+	}
+
+	for _, ordinal := range ignored {
+		if _, err := q.ExecContext(ctx, `
+			DELETE FROM context_items WHERE conversation_id = ? AND ordinal = ?
+		`, conversationID, ordinal); err != nil {
+			return 0, fmt.Errorf("drop ignored context item %d: %w", ordinal, err)
+		}
+	}
+	if err := lcm.ResequenceContextOrdinals(ctx, q, conversationID); err != nil {
+		return 0, err
+	}
+	return len(ignored), nil
+}
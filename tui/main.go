@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 	"github.com/muesli/reflow/wordwrap"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 type screen int
@@ -36,6 +43,15 @@ const (
 	defaultConversationWindowSize = 200
 	minConversationWindowSize     = 1
 	maxConversationWindowSize     = 10_000
+
+	// Session files without an LCM conversation at or above this size are
+	// paged in conversation-window-sized chunks instead of loaded whole.
+	sessionFileWindowMinBytes = 8 << 20
+
+	// Below this size the split-pane layouts compute zero or negative pane
+	// heights, so View shows a resize hint instead.
+	minViewWidth  = 40
+	minViewHeight = 10
 )
 
 type conversationViewportMode int
@@ -55,12 +71,21 @@ type conversationWindowState struct {
 	hasNewer        bool
 }
 
+// sessionFileWindowState pages a large session JSONL that has no LCM
+// conversation. Only the current window's messages are parsed and held.
+type sessionFileWindowState struct {
+	session sessionEntry
+	lines   []int // scanned line number of each displayable message
+	start   int   // index into lines of the window's first message
+}
+
 type rewritePhase int
 
 const (
 	rewritePreview rewritePhase = iota
 	rewriteInflight
 	rewriteReview
+	rewriteConfirm
 )
 
 var rewriteSpinnerFrames = []string{"|", "/", "-", `\`}
@@ -89,6 +114,8 @@ type rewriteState struct {
 	model           string
 	baseURL         string
 	err             error
+	suspect         error // why the new content looks like junk; nil when it passed the guard
+	force           bool  // apply even though suspect is set
 }
 
 type rewriteResultMsg struct {
@@ -96,6 +123,8 @@ type rewriteResultMsg struct {
 	content   string
 	tokens    int
 	err       error
+
+	usageTokens int // estimated prompt + output tokens billed for this call
 }
 
 type rewriteSpinnerTickMsg struct{}
@@ -113,11 +142,24 @@ type model struct {
 	summary           summaryGraph
 	summaryRows       []summaryRow
 
-	largeFiles []largeFileEntry
-	fileCursor int
+	largeFiles        []largeFileEntry
+	fileCursor        int          // index into visibleLargeFiles()
+	fileSort          fileSortMode // s cycles date/size/name/mime
+	fileFilter        string       // name or MIME substring, set with /
+	fileFilterEditing bool         // filter prompt is open and captures keys
 
 	contextItems  []contextItemEntry
 	contextCursor int
+	// contextGrouped shows the context screen as depth/segment groups with
+	// token subtotals instead of a flat ordinal list.
+	contextGrouped bool
+
+	// relativeTimes renders timestamps as "3m ago" instead of absolute local
+	// times. Toggled with R and remembered in tui.json.
+	relativeTimes bool
+
+	contextExplainID    string   // summary whose lineage is shown in the detail pane
+	contextExplainLines []string // rendered explain tree for contextExplainID
 
 	focusBriefs       []focusBriefEntry
 	focusBriefCursor  int
@@ -125,7 +167,9 @@ type model struct {
 	activeFocusBrief  *focusBriefEntry
 
 	agentCursor         int
+	agentDefaults       agentDefaults // agents.json entry for the agent whose sessions are open
 	sessionCursor       int
+	sessionTagFilter    string // only sessions carrying this tag are listed; "" lists all
 	summaryCursor       int
 	summaryDetailScroll int
 	contextDetailScroll int
@@ -135,18 +179,53 @@ type model struct {
 	height       int
 
 	conversationWindow conversationWindowState
+	fileWindow         *sessionFileWindowState // set while paging a large session file
 
 	summarySources   map[string][]summarySource
 	summarySourceErr map[string]string
+	summaryFreshness map[string]string // detail-pane freshness line, refreshed with summarySources
 	pendingDissolve  *dissolvePlan
+	pendingMove      *movePlan
+	moveInput        *moveInputState // target conversation prompt for m, captures all keys
+	pendingTimeRange *summaryTimeRangeFix
+	pendingRecount   *zeroTokenSummary
 	pendingRewrite   *rewriteState
 	subtreeQueue     []rewriteSummary // remaining nodes for W subtree rewrite
 	subtreeTotal     int              // original queue length for progress display
 	autoAccept       bool             // auto-apply rewrites without waiting for confirmation
 
+	subtreeAudit []rewriteAuditEntry // nodes applied so far in the active subtree run
+	subtreeUsage int                 // estimated API tokens spent by the active subtree run
+	rewriteAudit []rewriteAuditEntry // finished subtree run shown until dismissed
+
+	autoAcceptMaxTokens int // pause auto-accept after this many estimated API tokens; 0 disables
+	autoAcceptBaseline  int // subtreeUsage when auto-accept was last (re)started
+
+	// summaryGeneration holds LCM_TUI_SUMMARY_TEMPERATURE and
+	// LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS for interactive rewrites.
+	summaryGeneration summaryGenerationSettings
+	// sourceRedactor is non-nil when LCM_TUI_REDACT or redact.json turns
+	// on secret redaction for interactive rewrite sources.
+	sourceRedactor *sourceRedactor
+
+	summaryFollow    bool             // auto-reload the summaries screen on a timer
+	summaryFollowSeq int              // generation of the active follow tick chain
+	summaryFlash     map[string]bool  // summaries added or changed by the last follow reload
+	summaryHistogram bool             // show the token histogram overlay instead of the DAG
+	promptView       *promptViewState // read-only P overlay with the selected summary's rewrite prompt
+
+	titleEdit *titleEditState // inline conversation rename, captures all keys
+
+	sessionEncoding string            // --encoding for session JSONL fallback loads
+	sessionRoleMap  map[string]string // --role-map for session JSONL fallback loads
+	markdownView    bool              // style summary/context detail content as markdown
+
 	status string
 }
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 var (
 	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("69"))
 	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
@@ -165,6 +244,14 @@ var (
 )
 
 func main() {
+	args, dbPath, err := extractDBPathFlag(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lcm-tui failed: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], args...)
+	lcmDBPathOverride = dbPath
+
 	if len(os.Args) > 1 && os.Args[1] == "repair" {
 		if err := runRepairCommand(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "lcm-tui repair failed: %v\n", err)
@@ -186,6 +273,13 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "transplant-many" {
+		if err := runTransplantManyCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui transplant-many failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if len(os.Args) > 1 && os.Args[1] == "dissolve" {
 		if err := runDissolveCommand(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "lcm-tui dissolve failed: %v\n", err)
@@ -193,6 +287,132 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "move" {
+		if err := runMoveCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui move failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		if err := runMergeCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui merge failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "grep" {
+		if err := runGrepCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui grep failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "scan-injection" {
+		if err := runScanInjectionCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui scan-injection failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check-context" {
+		if err := runCheckContextCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui check-context failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "settings" {
+		if err := runSettingsCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui settings failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-context" {
+		if err := runRebuildContextCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui rebuild-context failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "recount" {
+		if err := runRecountCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui recount failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "relink" {
+		if err := runRelinkCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui relink failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "set-content" {
+		if err := runSetContentCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui set-content failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export-context" {
+		if err := runExportContextCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui export-context failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "freshness" {
+		if err := runFreshnessCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui freshness failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fresh-tail" {
+		if err := runFreshTailCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui fresh-tail failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "conversations" {
+		if err := runConversationsCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui conversations failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fold" {
+		if err := runFoldCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui fold failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && (os.Args[1] == "tag" || os.Args[1] == "untag") {
+		if err := runTagCommand(os.Args[2:], os.Args[1] == "untag"); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui %s failed: %v\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		if err := runAuditCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui audit failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "prune" {
+		if err := runPruneCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui prune failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if len(os.Args) > 1 && os.Args[1] == "rewrite" {
 		if err := runRewriteCommand(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "lcm-tui rewrite failed: %v\n", err)
@@ -214,8 +434,68 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		if err := runWatchCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui watch failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		if err := runReportCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui report failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "histogram" {
+		if err := runHistogramCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui histogram failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "title" {
+		if err := runTitleCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui title failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "db-diff" {
+		if err := runDBDiffCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui db-diff failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		if err := runSchemaCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lcm-tui schema failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	m := newModel()
+	launch := parseTUILaunchArgs(os.Args[1:])
+	if _, err := newSessionDecoder(launch.encoding); err != nil {
+		fmt.Fprintf(os.Stderr, "openclaw-tui failed: %v\n", err)
+		os.Exit(1)
+	}
+	roleMap, err := parseBackfillRoleMap(launch.roleMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "openclaw-tui failed: %v\n", err)
+		os.Exit(1)
+	}
+	m, err := newModel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "openclaw-tui failed: %v\n", err)
+		os.Exit(1)
+	}
+	m.summaryFollow = launch.follow
+	m.sessionEncoding = launch.encoding
+	m.sessionRoleMap = roleMap
+	m.markdownView = launch.markdown
 	program := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := program.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "openclaw-tui failed: %v\n", err)
@@ -223,7 +503,9 @@ func main() {
 	}
 }
 
-func newModel() model {
+// newModel builds the initial TUI state. Only an invalid redaction config is
+// fatal: starting anyway would send unredacted text on the next rewrite.
+func newModel() (model, error) {
 	m := model{
 		screen:           screenAgents,
 		summarySources:   make(map[string][]summarySource),
@@ -231,26 +513,46 @@ func newModel() model {
 		conversationWindow: conversationWindowState{
 			windowSize: resolveConversationWindowSize(),
 		},
+		autoAcceptMaxTokens: resolveAutoAcceptMaxTokens(),
+	}
+	generation, err := resolveSummaryGenerationSettings("", 0)
+	if err != nil {
+		log.Printf("[lcm-tui] invalid summary generation env (%v), using defaults", err)
+	}
+	m.summaryGeneration = generation
+	redactor, err := resolveSourceRedactor(false)
+	if err != nil {
+		return model{}, err
+	}
+	m.sourceRedactor = redactor
+	prefs, err := loadTUIPrefs(resolveTUIPrefsPath())
+	if err != nil {
+		log.Printf("[lcm-tui] invalid TUI preferences (%v), using defaults", err)
 	}
+	m.relativeTimes = prefs.RelativeTimes
 
 	paths, err := resolveDataPaths()
 	if err != nil {
 		m.status = "Error: " + err.Error()
-		return m
+		return m, nil
 	}
 	m.paths = paths
+	logLCMSchemaCapabilities(paths.lcmDBPath)
 
 	agents, err := loadAgents(paths.agentsDir)
 	if err != nil {
 		m.status = "Error: " + err.Error()
-		return m
+		return m, nil
 	}
 	m.agents = agents
 	m.status = fmt.Sprintf("Loaded %d agents from %s", len(agents), paths.agentsDir)
-	return m
+	return m, nil
 }
 
 func (m model) Init() tea.Cmd {
+	if m.summaryFollow {
+		return summaryFollowTickCmd(m.summaryFollowSeq)
+	}
 	return nil
 }
 
@@ -285,15 +587,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 		}
+		if m.subtreeTotal > 0 {
+			m.subtreeUsage += msg.usageTokens
+		}
 		m.pendingRewrite.newContent = msg.content
 		m.pendingRewrite.newTokens = msg.tokens
 		m.pendingRewrite.phase = rewriteReview
+		m.pendingRewrite.suspect = defaultRewriteGuard().check(msg.content, m.pendingRewrite.targetTokens)
 		progress := m.subtreeTotal - len(m.subtreeQueue)
 		m.status = fmt.Sprintf("Rewrite complete for %s: %dt -> %dt (%+dt)",
 			msg.summaryID,
 			m.pendingRewrite.oldTokens,
 			msg.tokens,
 			msg.tokens-m.pendingRewrite.oldTokens)
+		if m.pendingRewrite.suspect != nil {
+			m.status = fmt.Sprintf("Suspicious rewrite for %s: %v", msg.summaryID, m.pendingRewrite.suspect)
+			if m.autoAccept {
+				m.autoAccept = false
+				m.status += " (auto-accept paused)"
+			}
+			return m, nil
+		}
 
 		if m.autoAccept {
 			oldTokens := m.pendingRewrite.oldTokens
@@ -304,6 +618,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					progress, m.subtreeTotal,
 					msg.summaryID,
 					msg.tokens-oldTokens)
+				if m.autoAcceptOverBudget() {
+					// Budget ceiling: leave the next node in preview until the user opts back in
+					m.autoAccept = false
+					m.status = fmt.Sprintf("Auto-accept paused [%d/%d]: ~%dt spent reached the %dt ceiling (A: continue, Enter: rewrite next only, Esc: abort)",
+						progress, m.subtreeTotal, m.autoAcceptSpent(), m.autoAcceptMaxTokens)
+					return m, nil
+				}
 				// Auto-start the next one
 				if m.pendingRewrite != nil && m.pendingRewrite.phase == rewritePreview {
 					m.pendingRewrite.phase = rewriteInflight
@@ -311,8 +632,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, tea.Batch(m.startPendingRewriteAPI(), rewriteSpinnerTickCmd())
 				}
 			} else {
-				m.autoAccept = false
-				m.status = fmt.Sprintf("Subtree rewrite complete (%d nodes, auto-accepted)", m.subtreeTotal)
+				m.finishSubtreeRewrite(fmt.Sprintf("Subtree rewrite complete (%d nodes, auto-accepted)", m.subtreeTotal))
 			}
 		}
 		return m, nil
@@ -322,10 +642,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.pendingRewrite.spinnerFrame = (m.pendingRewrite.spinnerFrame + 1) % len(rewriteSpinnerFrames)
 		return m, rewriteSpinnerTickCmd()
+	case summaryFollowTickMsg:
+		if !m.summaryFollow || msg.seq != m.summaryFollowSeq {
+			return m, nil
+		}
+		// Keep ticking off-screen and under overlays, but only reload when the
+		// DAG is visible and not mid-rewrite or mid-dissolve.
+		if m.screen == screenSummaries && m.pendingRewrite == nil && m.pendingDissolve == nil && m.pendingMove == nil && m.moveInput == nil && m.pendingTimeRange == nil && m.pendingRecount == nil {
+			m.refreshFollowedSummaries()
+		}
+		return m, summaryFollowTickCmd(m.summaryFollowSeq)
 	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" || msg.String() == "q" {
+		if msg.String() == "ctrl+c" {
 			return m, tea.Quit
 		}
+		if m.titleEdit != nil {
+			return m.handleTitleEditKey(msg)
+		}
+		if m.moveInput != nil {
+			return m.handleMoveInputKey(msg)
+		}
+		if m.fileFilterEditing {
+			return m.handleFileFilterKey(msg)
+		}
+		if msg.String() == "q" {
+			return m, tea.Quit
+		}
+		if msg.String() == "R" {
+			m.toggleRelativeTimes()
+			return m, nil
+		}
 		return m.handleKey(msg)
 	}
 	return m, nil
@@ -376,6 +722,14 @@ func (m model) handleAgentsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.summaryRows = nil
 		m.screen = screenSessions
 		m.status = fmt.Sprintf("Loaded %d of %d sessions for agent %s", len(m.sessions), len(m.sessionFiles), agent.name)
+		defaults, hasDefaults, err := resolveAgentDefaults(agent.name)
+		m.agentDefaults = defaults
+		switch {
+		case err != nil:
+			m.status += " (agent defaults ignored: " + err.Error() + ")"
+		case hasDefaults:
+			m.status += " (agent defaults applied)"
+		}
 	case "r":
 		agents, err := loadAgents(m.paths.agentsDir)
 		if err != nil {
@@ -392,8 +746,16 @@ func (m model) handleAgentsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) handleSessionsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "up", "k":
+		if m.sessionTagFilter != "" {
+			m.moveFilteredSessionCursor(-1)
+			return m, nil
+		}
 		m.sessionCursor = clamp(m.sessionCursor-1, 0, len(m.sessions)-1)
 	case "down", "j":
+		if m.sessionTagFilter != "" {
+			m.moveFilteredSessionCursor(1)
+			return m, nil
+		}
 		previousLoaded := len(m.sessions)
 		m.sessionCursor = clamp(m.sessionCursor+1, 0, len(m.sessions)-1)
 		loaded := m.maybeLoadMoreSessions()
@@ -433,12 +795,15 @@ func (m model) handleSessionsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.screen = screenCodexContextCompare
+	case "t":
+		m.cycleSessionTagFilter()
 	case "b", "backspace":
 		m.screen = screenAgents
 		m.sessionFiles = nil
 		m.sessionFileCursor = 0
 		m.sessions = nil
 		m.sessionCursor = 0
+		m.sessionTagFilter = ""
 		m.status = "Back to agents"
 	case "r":
 		agent, ok := m.currentAgent()
@@ -481,6 +846,8 @@ func (m model) handleConversationKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "b", "backspace":
 		m.screen = screenSessions
 		m.status = "Back to sessions"
+	case "T":
+		m.startTitleEdit()
 	case "r":
 		if err := m.reloadConversationWindow(); err != nil {
 			m.status = "Error: " + err.Error()
@@ -515,7 +882,8 @@ func (m model) handleConversationKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.status = "Error: " + err.Error()
 			return m, nil
 		}
-		m.largeFiles = files
+		m.setLargeFiles(files)
+		m.fileFilter = ""
 		m.fileCursor = 0
 		m.screen = screenFiles
 		if len(files) == 0 {
@@ -604,6 +972,10 @@ func (m model) handleConversationKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.rewriteAudit != nil {
+		m.rewriteAudit = nil
+		return m, nil
+	}
 	if m.pendingRewrite != nil {
 		switch m.pendingRewrite.phase {
 		case rewritePreview:
@@ -611,7 +983,7 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			case "A":
 				// Auto-accept from preview: start this rewrite and auto-apply all subsequent
 				if len(m.subtreeQueue) > 0 {
-					m.autoAccept = true
+					m.startAutoAccept()
 				}
 				m.pendingRewrite.phase = rewriteInflight
 				m.pendingRewrite.spinnerFrame = 0
@@ -641,9 +1013,7 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.pendingRewrite = nil
 				m.autoAccept = false
 				if len(m.subtreeQueue) > 0 {
-					m.subtreeQueue = nil
-					m.subtreeTotal = 0
-					m.status = "Subtree rewrite aborted"
+					m.finishSubtreeRewrite("Subtree rewrite aborted")
 				} else {
 					m.status = "Rewrite canceled"
 				}
@@ -670,8 +1040,10 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			case "A":
 				// Auto-accept: apply this one and all remaining in subtree
 				if len(m.subtreeQueue) > 0 {
-					m.autoAccept = true
-					m.confirmPendingRewrite()
+					if !m.confirmPendingRewrite() {
+						return m, nil
+					}
+					m.startAutoAccept()
 					m.advanceSubtreeQueue()
 					progress := m.subtreeTotal - len(m.subtreeQueue)
 					m.status = fmt.Sprintf("Auto-accept [%d/%d]: starting...", progress, m.subtreeTotal)
@@ -682,14 +1054,22 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					}
 				} else {
 					// Last node — just apply it
-					m.confirmPendingRewrite()
+					if m.confirmPendingRewrite() && m.subtreeTotal > 0 {
+						m.advanceSubtreeQueue()
+					}
 				}
 				return m, nil
 			case "y", "enter":
-				m.confirmPendingRewrite()
-				if len(m.subtreeQueue) > 0 {
-					m.advanceSubtreeQueue()
+				if m.pendingRewrite.suspect != nil && !m.pendingRewrite.force {
+					m.status = fmt.Sprintf("Refused to apply %s: %v (press ! to apply anyway)", m.pendingRewrite.summaryID, m.pendingRewrite.suspect)
+					return m, nil
 				}
+				m.pendingRewrite.phase = rewriteConfirm
+				m.status = "Press Y to apply, n to go back"
+			case "!":
+				m.pendingRewrite.force = true
+				m.pendingRewrite.phase = rewriteConfirm
+				m.status = "Press Y to force-apply, n to go back"
 			case "d":
 				m.pendingRewrite.diffView = !m.pendingRewrite.diffView
 				m.pendingRewrite.scrollOffset = 0
@@ -705,6 +1085,8 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				if len(m.subtreeQueue) > 0 {
 					m.status = "Skipped, advancing to next..."
 					m.advanceSubtreeQueue()
+				} else if m.subtreeTotal > 0 {
+					m.advanceSubtreeQueue()
 				} else {
 					m.status = "Rewrite discarded"
 				}
@@ -712,14 +1094,25 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.pendingRewrite = nil
 				m.autoAccept = false
 				if len(m.subtreeQueue) > 0 {
-					m.subtreeQueue = nil
-					m.subtreeTotal = 0
-					m.status = "Subtree rewrite aborted"
+					m.finishSubtreeRewrite("Subtree rewrite aborted")
 				} else {
 					m.status = "Rewrite discarded"
 				}
 			}
 			return m, nil
+		case rewriteConfirm:
+			switch msg.String() {
+			case "Y":
+				if m.confirmPendingRewrite() && m.subtreeTotal > 0 {
+					m.advanceSubtreeQueue()
+				} else if m.pendingRewrite != nil {
+					m.pendingRewrite.phase = rewriteReview
+				}
+			case "n", "esc", "b", "backspace":
+				m.pendingRewrite.phase = rewriteReview
+				m.status = "Back to review; nothing applied"
+			}
+			return m, nil
 		}
 	}
 
@@ -734,6 +1127,54 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.pendingMove != nil {
+		switch msg.String() {
+		case "Y":
+			m.confirmPendingMove()
+		case "n", "esc", "b", "backspace", "m":
+			m.pendingMove = nil
+			m.status = "Move canceled"
+		}
+		return m, nil
+	}
+
+	if m.pendingTimeRange != nil {
+		switch msg.String() {
+		case "y", "enter":
+			m.confirmPendingTimeRange()
+		case "n", "esc", "b", "backspace", "t":
+			m.pendingTimeRange = nil
+			m.status = "Time range update canceled"
+		}
+		return m, nil
+	}
+
+	if m.pendingRecount != nil {
+		switch msg.String() {
+		case "y", "enter":
+			m.confirmPendingRecount()
+		case "n", "esc", "b", "backspace", "z":
+			m.pendingRecount = nil
+			m.status = "Token recount canceled"
+		}
+		return m, nil
+	}
+
+	if m.promptView != nil {
+		m.handlePromptViewKey(msg.String())
+		return m, nil
+	}
+
+	if m.summaryHistogram {
+		switch msg.String() {
+		case "H", "esc", "b", "backspace":
+			m.summaryHistogram = false
+		case "F":
+			return m, m.toggleSummaryFollow()
+		}
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "up", "k":
 		m.summaryCursor = clamp(m.summaryCursor-1, 0, len(m.summaryRows)-1)
@@ -763,8 +1204,25 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.startPendingRewrite()
 	case "W":
 		m.startSubtreeRewrite()
+	case "P":
+		m.startPromptView()
 	case "d":
 		m.startPendingDissolve()
+	case "m":
+		m.startMoveInput()
+	case "t":
+		m.startPendingTimeRange()
+	case "z":
+		m.startPendingRecount()
+	case "F":
+		return m, m.toggleSummaryFollow()
+	case "H":
+		m.summaryHistogram = true
+		m.status = fmt.Sprintf("Token histogram for %d summaries", len(m.summary.nodes))
+	case "T":
+		m.startTitleEdit()
+	case "M":
+		m.toggleMarkdownView()
 	case "r":
 		session, ok := m.currentSession()
 		if !ok {
@@ -793,13 +1251,20 @@ func (m model) handleSummariesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) handleFilesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "up", "k":
-		m.fileCursor = clamp(m.fileCursor-1, 0, len(m.largeFiles)-1)
+		m.fileCursor = clamp(m.fileCursor-1, 0, len(m.visibleLargeFiles())-1)
 	case "down", "j":
-		m.fileCursor = clamp(m.fileCursor+1, 0, len(m.largeFiles)-1)
+		m.fileCursor = clamp(m.fileCursor+1, 0, len(m.visibleLargeFiles())-1)
 	case "g":
 		m.fileCursor = 0
 	case "G":
-		m.fileCursor = max(0, len(m.largeFiles)-1)
+		m.fileCursor = max(0, len(m.visibleLargeFiles())-1)
+	case "s":
+		m.fileSort = m.fileSort.next()
+		m.refreshFileView()
+		m.status = m.fileViewStatus()
+	case "/":
+		m.fileFilterEditing = true
+		m.status = "Filter by name or MIME type"
 	case "r":
 		session, ok := m.currentSession()
 		if !ok {
@@ -811,8 +1276,8 @@ func (m model) handleFilesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.status = "Error: " + err.Error()
 			return m, nil
 		}
-		m.largeFiles = files
-		m.fileCursor = clamp(m.fileCursor, 0, len(m.largeFiles)-1)
+		m.setLargeFiles(files)
+		m.fileCursor = clamp(m.fileCursor, 0, len(m.visibleLargeFiles())-1)
 		m.status = fmt.Sprintf("Reloaded %d large files", len(files))
 	case "f":
 		session, ok := m.currentSession()
@@ -825,7 +1290,8 @@ func (m model) handleFilesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.status = "Error: " + err.Error()
 			return m, nil
 		}
-		m.largeFiles = files
+		m.setLargeFiles(files)
+		m.fileFilter = ""
 		m.fileCursor = 0
 		m.screen = screenFiles
 		if len(files) == 0 {
@@ -845,15 +1311,60 @@ func (m model) handleContextKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "up", "k":
 		m.contextCursor = clamp(m.contextCursor-1, 0, len(m.contextItems)-1)
 		m.contextDetailScroll = 0
+		m.clearContextExplain()
 	case "down", "j":
 		m.contextCursor = clamp(m.contextCursor+1, 0, len(m.contextItems)-1)
 		m.contextDetailScroll = 0
+		m.clearContextExplain()
 	case "g":
 		m.contextCursor = 0
 		m.contextDetailScroll = 0
+		m.clearContextExplain()
 	case "G":
 		m.contextCursor = max(0, len(m.contextItems)-1)
 		m.contextDetailScroll = 0
+		m.clearContextExplain()
+	case "enter", "x":
+		if m.contextCursor < 0 || m.contextCursor >= len(m.contextItems) {
+			m.status = "No item selected"
+			return m, nil
+		}
+		item := m.contextItems[m.contextCursor]
+		if item.itemType != "summary" {
+			m.status = "Only summary items can be explained; messages are already raw context"
+			return m, nil
+		}
+		if m.contextExplainID == item.summaryID {
+			m.clearContextExplain()
+			m.contextDetailScroll = 0
+			m.status = "Explain closed"
+			return m, nil
+		}
+		root, err := loadSummaryExplainTree(m.paths.lcmDBPath, item.summaryID)
+		if err != nil {
+			m.status = "Error: " + err.Error()
+			return m, nil
+		}
+		m.contextExplainID = item.summaryID
+		m.contextExplainLines = renderSummaryExplainLines(root, max(40, m.width-4))
+		m.contextDetailScroll = 0
+		stats := explainStats(root)
+		m.status = fmt.Sprintf("Explaining %s: %d summaries, %d source messages", item.summaryID, stats.summaries, stats.messages)
+	case "esc":
+		if m.contextExplainID != "" {
+			m.clearContextExplain()
+			m.contextDetailScroll = 0
+			m.status = "Explain closed"
+		}
+	case "M":
+		m.toggleMarkdownView()
+	case "s":
+		m.contextGrouped = !m.contextGrouped
+		if m.contextGrouped {
+			m.status = fmt.Sprintf("Grouped %d context items into %d segments", len(m.contextItems), len(groupContextItems(m.contextItems)))
+		} else {
+			m.status = "Flat context view"
+		}
 	case "J":
 		m.contextDetailScroll++
 	case "K":
@@ -875,6 +1386,7 @@ func (m model) handleContextKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		m.contextItems = items
 		m.contextCursor = clamp(m.contextCursor, 0, len(m.contextItems)-1)
+		m.clearContextExplain()
 		m.status = fmt.Sprintf("Reloaded %d context items", len(items))
 	case "b", "backspace":
 		m.screen = screenConversation
@@ -883,6 +1395,12 @@ func (m model) handleContextKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// clearContextExplain drops the explain tree shown in the context detail pane.
+func (m *model) clearContextExplain() {
+	m.contextExplainID = ""
+	m.contextExplainLines = nil
+}
+
 // handleFocusBriefsKey navigates the read-only focus brief browser.
 func (m model) handleFocusBriefsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -962,12 +1480,20 @@ func (m *model) openConversationForSession(session sessionEntry) error {
 	m.conversationWindow.newestMessageID = 0
 	m.conversationWindow.hasOlder = false
 	m.conversationWindow.hasNewer = false
+	m.fileWindow = nil
 
 	if session.conversationID > 0 {
 		if err := m.refreshActiveFocusForSession(session); err != nil {
 			return err
 		}
-		return m.loadLatestConversationWindowForSession(session, "Loaded")
+		if err := m.loadLatestConversationWindowForSession(session, "Loaded"); err != nil {
+			return err
+		}
+		if session.conversationCount > 1 {
+			m.status += fmt.Sprintf(" | session has %d LCM conversations, showing latest conv_id %d (lcm-tui conversations --session %s)",
+				session.conversationCount, session.conversationID, session.id)
+		}
+		return nil
 	}
 	m.activeFocusBrief = nil
 	return m.loadConversationFromSessionFile(session, "Loaded")
@@ -988,6 +1514,7 @@ func (m *model) openCodexBackendForSession(session sessionEntry) error {
 	m.conversationWindow.newestMessageID = 0
 	m.conversationWindow.hasOlder = false
 	m.conversationWindow.hasNewer = false
+	m.fileWindow = nil
 
 	parseStart := time.Now()
 	messages, err := parseCodexBackendMessages(session.codexBackendPath)
@@ -1069,6 +1596,9 @@ func (m *model) reloadConversationWindow() error {
 
 // loadOlderConversationWindow pages to an older keyset window in the active conversation.
 func (m *model) loadOlderConversationWindow() error {
+	if m.fileWindow != nil {
+		return m.pageSessionFileWindow(-1)
+	}
 	if !m.conversationWindow.enabled || m.conversationWindow.conversationID <= 0 {
 		m.status = "Older/newer paging requires an LCM-tracked conversation (conv_id)"
 		return nil
@@ -1095,6 +1625,9 @@ func (m *model) loadOlderConversationWindow() error {
 
 // loadNewerConversationWindow pages to a newer keyset window in the active conversation.
 func (m *model) loadNewerConversationWindow() error {
+	if m.fileWindow != nil {
+		return m.pageSessionFileWindow(1)
+	}
 	if !m.conversationWindow.enabled || m.conversationWindow.conversationID <= 0 {
 		m.status = "Older/newer paging requires an LCM-tracked conversation (conv_id)"
 		return nil
@@ -1135,8 +1668,16 @@ func (m *model) loadLatestConversationWindowForSession(session sessionEntry, act
 
 // loadConversationFromSessionFile is a fallback path for sessions without LCM conversation IDs.
 func (m *model) loadConversationFromSessionFile(session sessionEntry, action string) error {
+	m.fileWindow = nil
+	if info, err := os.Stat(session.path); err == nil && info.Size() >= sessionFileWindowMinBytes {
+		return m.openSessionFileWindow(session, action)
+	}
+	decoder, err := newSessionDecoder(m.sessionEncoding)
+	if err != nil {
+		return err
+	}
 	parseStart := time.Now()
-	messages, err := parseSessionMessages(session.path)
+	messages, err := parseSessionMessages(session.path, decoder, m.sessionRoleMap)
 	parseDuration := time.Since(parseStart)
 	if err != nil {
 		return err
@@ -1159,6 +1700,117 @@ func (m *model) loadConversationFromSessionFile(session sessionEntry, action str
 		formatDuration(parseDuration),
 		formatDuration(renderDuration),
 	)
+	if len(messages) == 0 || decoder.parseErrors > 0 {
+		diagnostic := decoder.parseDiagnostic()
+		m.status += " | " + diagnostic
+		log.Printf("[lcm-tui] %s: %s", session.filename, diagnostic)
+	}
+	if note := decoder.summary(session.filename); note != "" {
+		m.status += " | " + note
+		log.Printf("[lcm-tui] %s", note)
+	}
+	return nil
+}
+
+// openSessionFileWindow indexes a large session file and shows its newest
+// window; [ and ] then page through it like an LCM conversation window.
+func (m *model) openSessionFileWindow(session sessionEntry, action string) error {
+	decoder, err := newSessionDecoder(m.sessionEncoding)
+	if err != nil {
+		return err
+	}
+	indexStart := time.Now()
+	lines, err := indexSessionMessages(session.path, decoder)
+	indexDuration := time.Since(indexStart)
+	if err != nil {
+		return err
+	}
+	m.fileWindow = &sessionFileWindowState{
+		session: session,
+		lines:   lines,
+		start:   max(0, len(lines)-m.conversationWindow.windowSize),
+	}
+	if err := m.loadSessionFileWindow(conversationViewportBottom, action); err != nil {
+		return err
+	}
+	m.status += fmt.Sprintf(" | index:%s", formatDuration(indexDuration))
+	if len(lines) == 0 || decoder.parseErrors > 0 {
+		diagnostic := decoder.parseDiagnostic()
+		m.status += " | " + diagnostic
+		log.Printf("[lcm-tui] %s: %s", session.filename, diagnostic)
+	}
+	if note := decoder.summary(session.filename); note != "" {
+		m.status += " | " + note
+		log.Printf("[lcm-tui] %s", note)
+	}
+	return nil
+}
+
+// pageSessionFileWindow moves the session file window one window older
+// (direction < 0) or newer.
+func (m *model) pageSessionFileWindow(direction int) error {
+	window := m.fileWindow
+	size := m.conversationWindow.windowSize
+	if direction < 0 {
+		if window.start == 0 {
+			m.status = "No older messages available"
+			return nil
+		}
+		window.start = max(0, window.start-size)
+		return m.loadSessionFileWindow(conversationViewportBottom, "Loaded older window")
+	}
+	if window.start+size >= len(window.lines) {
+		m.status = "No newer messages available"
+		return nil
+	}
+	window.start += size
+	return m.loadSessionFileWindow(conversationViewportTop, "Loaded newer window")
+}
+
+// loadSessionFileWindow parses the current session file window into the viewport.
+func (m *model) loadSessionFileWindow(viewportMode conversationViewportMode, action string) error {
+	window := m.fileWindow
+	decoder, err := newSessionDecoder(m.sessionEncoding)
+	if err != nil {
+		return err
+	}
+	end := min(len(window.lines), window.start+m.conversationWindow.windowSize)
+	parseStart := time.Now()
+	messages, err := parseSessionMessageWindow(window.session.path, decoder, m.sessionRoleMap, window.lines[window.start:end])
+	parseDuration := time.Since(parseStart)
+	if err != nil {
+		return err
+	}
+	m.messages = messages
+	renderDuration := m.refreshConversationViewportWithMode(viewportMode)
+
+	windowRange := "empty"
+	if end > window.start {
+		windowRange = fmt.Sprintf("%d..%d", window.start+1, end)
+	}
+	m.status = fmt.Sprintf(
+		"%s %d of %d messages from %s (window:%s size:%d older:%t newer:%t file parse:%s render:%s)",
+		action,
+		len(messages),
+		len(window.lines),
+		window.session.filename,
+		windowRange,
+		m.conversationWindow.windowSize,
+		window.start > 0,
+		end < len(window.lines),
+		formatDuration(parseDuration),
+		formatDuration(renderDuration),
+	)
+	log.Printf(
+		"[lcm-tui] conversation file-window action=%s session=%s messages=%d total=%d range=%s parse=%s render=%s",
+		strings.ToLower(action),
+		window.session.id,
+		len(messages),
+		len(window.lines),
+		windowRange,
+		formatDuration(parseDuration),
+		formatDuration(renderDuration),
+	)
 	return nil
 }
 
@@ -1211,6 +1863,16 @@ func (m *model) expandOrToggleSelectedSummary() {
 		m.status = "Missing summary node"
 		return
 	}
+	if m.summaryRows[m.summaryCursor].repeat {
+		// A repeated row of a shared summary jumps to its expandable first row.
+		for idx, row := range m.summaryRows {
+			if row.summaryID == id && !row.repeat {
+				m.summaryCursor = idx
+				m.status = fmt.Sprintf("Jumped to first listing of shared summary %s", id)
+				return
+			}
+		}
+	}
 	if len(node.children) == 0 {
 		m.status = "Summary has no children"
 		return
@@ -1242,15 +1904,238 @@ func (m *model) collapseSelectedSummary() {
 	m.status = "Summary already collapsed"
 }
 
-// startPendingDissolve builds a dry-run dissolve preview for the selected node.
-func (m *model) startPendingDissolve() {
+// startPendingDissolve builds a dry-run dissolve preview for the selected node.
+func (m *model) startPendingDissolve() {
+	summaryID, ok := m.currentSummaryID()
+	if !ok {
+		m.status = "No summary selected"
+		return
+	}
+	if m.summary.conversationID <= 0 {
+		m.status = "Missing conversation ID for current summary graph"
+		return
+	}
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+
+	plan, err := buildDissolvePlan(context.Background(), db, m.summary.conversationID, summaryID)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+
+	m.pendingDissolve = &plan
+	m.status = fmt.Sprintf("Ready to dissolve %s", summaryID)
+	if plan.ordinalCheck != nil {
+		m.status = fmt.Sprintf("Warning: dissolving %s would break context ordering: %v", summaryID, plan.ordinalCheck)
+	}
+}
+
+// startPendingTimeRange recomputes the selected summary's leaf time range and
+// opens a confirmation when it differs from the stored earliest_at/latest_at.
+func (m *model) startPendingTimeRange() {
+	summaryID, ok := m.currentSummaryID()
+	if !ok {
+		m.status = "No summary selected"
+		return
+	}
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+
+	fix, err := buildSummaryTimeRangeFix(context.Background(), db, summaryID)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	switch {
+	case !fix.computed():
+		m.status = fmt.Sprintf("No leaf messages found under %s; time range left unchanged", summaryID)
+	case !fix.changed():
+		m.status = fmt.Sprintf("Time range for %s already matches its leaves", summaryID)
+	default:
+		m.pendingTimeRange = &fix
+		m.status = fmt.Sprintf("Stored time range for %s differs from its leaves", summaryID)
+	}
+}
+
+// confirmPendingTimeRange writes the recomputed range for the pending fix.
+func (m *model) confirmPendingTimeRange() {
+	if m.pendingTimeRange == nil {
+		return
+	}
+	fix := *m.pendingTimeRange
+	m.pendingTimeRange = nil
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+
+	if err := applySummaryTimeRangeFix(context.Background(), db, fix); err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("Updated time range for %s: %s", fix.summaryID, formatStoredTimeRange(fix.computedEarliest, fix.computedLatest))
+}
+
+// startPendingRecount opens a confirmation to set a zero token_count from the
+// selected summary's content.
+func (m *model) startPendingRecount() {
+	summaryID, ok := m.currentSummaryID()
+	node := m.summary.nodes[summaryID]
+	if !ok || node == nil {
+		m.status = "No summary selected"
+		return
+	}
+	if node.tokenCount != 0 {
+		m.status = fmt.Sprintf("%s already has token_count %d; nothing to recount", node.id, node.tokenCount)
+		return
+	}
+	m.pendingRecount = &zeroTokenSummary{
+		summaryID: node.id,
+		kind:      node.kind,
+		depth:     node.depth,
+		estimated: lcm.EstimateTokenCount(node.content),
+	}
+	m.status = fmt.Sprintf("%s has token_count 0", node.id)
+}
+
+// confirmPendingRecount writes the estimated token_count and updates the
+// loaded node so the list reflects it without a reload.
+func (m *model) confirmPendingRecount() {
+	if m.pendingRecount == nil {
+		return
+	}
+	pending := *m.pendingRecount
+	m.pendingRecount = nil
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+
+	updated, err := recountSummaryTokens(context.Background(), db, []string{pending.summaryID})
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	if updated == 0 {
+		m.status = fmt.Sprintf("%s no longer has token_count 0; press r to reload", pending.summaryID)
+		return
+	}
+	if node := m.summary.nodes[pending.summaryID]; node != nil {
+		node.tokenCount = pending.estimated
+	}
+	m.status = fmt.Sprintf("Recounted %s: 0 -> %d tokens", pending.summaryID, pending.estimated)
+}
+
+// confirmPendingDissolve applies the pending dissolve and refreshes the DAG view.
+func (m *model) confirmPendingDissolve() {
+	if m.pendingDissolve == nil {
+		return
+	}
+	plan := *m.pendingDissolve
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.pendingDissolve = nil
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+
+	newCount, err := applyDissolvePlan(context.Background(), db, plan, true)
+	if err != nil {
+		m.pendingDissolve = nil
+		m.status = "Error: " + err.Error()
+		return
+	}
+
+	session, ok := m.currentSession()
+	if !ok {
+		m.pendingDissolve = nil
+		m.status = fmt.Sprintf("Dissolved %s, but no session is selected for reload", plan.target.summaryID)
+		return
+	}
+
+	summary, err := loadSummaryGraph(m.paths.lcmDBPath, session.id)
+	if err != nil {
+		m.pendingDissolve = nil
+		m.status = fmt.Sprintf("Dissolved %s, but reload failed: %v", plan.target.summaryID, err)
+		return
+	}
+
+	m.summary = summary
+	m.summaryRows = buildSummaryRows(summary)
+	m.summaryCursor = clamp(m.summaryCursor, 0, len(m.summaryRows)-1)
+	m.summaryDetailScroll = 0
+	m.summarySources = make(map[string][]summarySource)
+	m.summarySourceErr = make(map[string]string)
+	m.loadCurrentSummarySources()
+	m.pendingDissolve = nil
+	m.status = fmt.Sprintf("Dissolved %s: restored %d parents (%dt → %dt, %+dt). Context items: %d",
+		plan.target.summaryID,
+		len(plan.parents),
+		plan.target.tokenCount,
+		plan.totalParentTokens,
+		plan.totalParentTokens-plan.target.tokenCount,
+		newCount)
+}
+
+// moveInputState is the target conversation prompt opened with m.
+type moveInputState struct {
+	summaryID string
+	input     []rune
+}
+
+func (m *model) startMoveInput() {
 	summaryID, ok := m.currentSummaryID()
 	if !ok {
 		m.status = "No summary selected"
 		return
 	}
-	if m.summary.conversationID <= 0 {
-		m.status = "Missing conversation ID for current summary graph"
+	m.moveInput = &moveInputState{summaryID: summaryID}
+	m.status = fmt.Sprintf("Moving %s: enter the target conversation ID", summaryID)
+}
+
+func (m model) handleMoveInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.moveInput = nil
+		m.status = "Move canceled"
+	case tea.KeyEnter:
+		m.startPendingMove()
+	case tea.KeyBackspace:
+		if n := len(m.moveInput.input); n > 0 {
+			m.moveInput.input = m.moveInput.input[:n-1]
+		}
+	case tea.KeyRunes:
+		m.moveInput.input = append(m.moveInput.input, msg.Runes...)
+	}
+	return m, nil
+}
+
+// startPendingMove builds the move plan for the prompt's target and opens
+// the preview. The prompt stays open when the target is invalid.
+func (m *model) startPendingMove() {
+	input := m.moveInput
+	targetID, err := parseMoveTargetInput(string(input.input))
+	if err != nil {
+		m.status = "Error: " + err.Error()
 		return
 	}
 
@@ -1261,52 +2146,50 @@ func (m *model) startPendingDissolve() {
 	}
 	defer db.Close()
 
-	plan, err := buildDissolvePlan(context.Background(), db, m.summary.conversationID, summaryID)
+	plan, err := buildMovePlan(context.Background(), db, input.summaryID, targetID)
 	if err != nil {
 		m.status = "Error: " + err.Error()
 		return
 	}
-
-	m.pendingDissolve = &plan
-	m.status = fmt.Sprintf("Ready to dissolve %s", summaryID)
+	m.moveInput = nil
+	m.pendingMove = &plan
+	m.status = fmt.Sprintf("Ready to move %d summaries to conversation %d", len(plan.summaries), targetID)
+	if len(plan.blockers) > 0 {
+		m.status = fmt.Sprintf("Move of %s blocked: %s", input.summaryID, plan.blockers[0])
+	}
 }
 
-// confirmPendingDissolve applies the pending dissolve and refreshes the DAG view.
-func (m *model) confirmPendingDissolve() {
-	if m.pendingDissolve == nil {
+// confirmPendingMove applies the pending move and reloads the DAG, from
+// which the moved subtree disappears.
+func (m *model) confirmPendingMove() {
+	plan := m.pendingMove
+	if plan == nil || len(plan.blockers) > 0 {
 		return
 	}
-	plan := *m.pendingDissolve
+	m.pendingMove = nil
 
 	db, err := openLCMDB(m.paths.lcmDBPath)
 	if err != nil {
-		m.pendingDissolve = nil
 		m.status = "Error: " + err.Error()
 		return
 	}
 	defer db.Close()
-
-	newCount, err := applyDissolvePlan(context.Background(), db, plan, true)
-	if err != nil {
-		m.pendingDissolve = nil
+	if err := applyMovePlan(context.Background(), db, *plan); err != nil {
 		m.status = "Error: " + err.Error()
 		return
 	}
 
+	done := fmt.Sprintf("Moved %d summaries and %d messages to conversation %d", len(plan.summaries), len(plan.messages), plan.targetConversationID)
 	session, ok := m.currentSession()
 	if !ok {
-		m.pendingDissolve = nil
-		m.status = fmt.Sprintf("Dissolved %s, but no session is selected for reload", plan.target.summaryID)
+		m.status = done + ", but no session is selected for reload"
 		return
 	}
-
 	summary, err := loadSummaryGraph(m.paths.lcmDBPath, session.id)
 	if err != nil {
-		m.pendingDissolve = nil
-		m.status = fmt.Sprintf("Dissolved %s, but reload failed: %v", plan.target.summaryID, err)
+		m.status = fmt.Sprintf("%s, but reload failed: %v", done, err)
 		return
 	}
-
 	m.summary = summary
 	m.summaryRows = buildSummaryRows(summary)
 	m.summaryCursor = clamp(m.summaryCursor, 0, len(m.summaryRows)-1)
@@ -1314,18 +2197,12 @@ func (m *model) confirmPendingDissolve() {
 	m.summarySources = make(map[string][]summarySource)
 	m.summarySourceErr = make(map[string]string)
 	m.loadCurrentSummarySources()
-	m.pendingDissolve = nil
-	m.status = fmt.Sprintf("Dissolved %s: restored %d parents (%dt → %dt, %+dt). Context items: %d",
-		plan.target.summaryID,
-		len(plan.parents),
-		plan.target.tokenCount,
-		plan.totalParentTokens,
-		plan.totalParentTokens-plan.target.tokenCount,
-		newCount)
+	m.status = done
 }
 
 // collectSubtreeBottomUp walks the DAG from a root node and returns all
 // descendants (including root) ordered bottom-up: deepest leaves first.
+// Shared descendants reachable through several parents are queued once.
 func (m *model) collectSubtreeBottomUp(rootID string) []rewriteSummary {
 	var result []rewriteSummary
 	visited := map[string]bool{}
@@ -1386,6 +2263,8 @@ func (m *model) startSubtreeRewrite() {
 
 	m.subtreeQueue = queue
 	m.subtreeTotal = len(queue)
+	m.subtreeAudit = nil
+	m.subtreeUsage = 0
 	m.status = fmt.Sprintf("Subtree rewrite: %d nodes (bottom-up)", len(queue))
 	m.advanceSubtreeQueue()
 }
@@ -1394,8 +2273,7 @@ func (m *model) startSubtreeRewrite() {
 // a pending rewrite for it. Called after each node is applied (or skipped).
 func (m *model) advanceSubtreeQueue() {
 	if len(m.subtreeQueue) == 0 {
-		m.status = fmt.Sprintf("Subtree rewrite complete (%d nodes)", m.subtreeTotal)
-		m.subtreeTotal = 0
+		m.finishSubtreeRewrite(fmt.Sprintf("Subtree rewrite complete (%d nodes)", m.subtreeTotal))
 		return
 	}
 
@@ -1425,37 +2303,14 @@ func (m *model) advanceSubtreeQueue() {
 	item.content = currentContent
 	item.tokenCount = currentTokens
 
-	source, err := buildSummaryRewriteSource(ctx, db, item, true, time.Local)
-	if err != nil {
-		m.status = fmt.Sprintf("Error building source for %s: %v", item.summaryID, err)
-		m.subtreeQueue = nil
-		return
-	}
-	previousContext, err := resolveRewritePreviousContext(ctx, db, item)
-	if err != nil {
-		m.status = fmt.Sprintf("Error resolving context for %s: %v", item.summaryID, err)
-		m.subtreeQueue = nil
-		return
-	}
-	targetTokens := condensedTargetTokens
-	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-		targetTokens = calculateLeafTargetTokens(source.estimatedTokens)
-	}
-	prompt, err := renderPrompt(item.depth, PromptVars{
-		TargetTokens:    targetTokens,
-		PreviousContext: previousContext,
-		ChildCount:      source.itemCount,
-		TimeRange:       source.timeRange,
-		Depth:           item.depth,
-		SourceText:      source.text,
-	}, "")
+	built, err := m.buildInteractiveRewritePrompt(ctx, db, item)
 	if err != nil {
-		m.status = fmt.Sprintf("Error rendering prompt for %s: %v", item.summaryID, err)
+		m.status = "Error: " + err.Error()
 		m.subtreeQueue = nil
 		return
 	}
 
-	provider, model, baseURL := resolveInteractiveRewriteProviderModel(m.paths)
+	provider, model, baseURL := resolveInteractiveRewriteProviderModel(m.paths, m.agentDefaults)
 	apiKey, err := resolveProviderAPIKey(m.paths, provider)
 	if err != nil {
 		m.status = "Error: " + err.Error()
@@ -1469,20 +2324,20 @@ func (m *model) advanceSubtreeQueue() {
 		depth:           item.depth,
 		oldContent:      item.content,
 		oldTokens:       item.tokenCount,
-		sourceText:      source.text,
-		sourceLabel:     source.label,
-		sourceCount:     source.itemCount,
-		timeRange:       source.timeRange,
-		prompt:          prompt,
-		targetTokens:    targetTokens,
-		previousContext: previousContext,
+		sourceText:      built.source.Text,
+		sourceLabel:     built.source.Label,
+		sourceCount:     built.source.ItemCount,
+		timeRange:       built.source.TimeRange,
+		prompt:          built.prompt,
+		targetTokens:    built.targetTokens,
+		previousContext: built.previousContext,
 		phase:           rewritePreview,
 		provider:        provider,
 		apiKey:          apiKey,
 		model:           model,
 		baseURL:         baseURL,
 	}
-	m.status = fmt.Sprintf("Subtree rewrite [%d/%d]: %s (d%d)", progress, m.subtreeTotal, item.summaryID, item.depth)
+	m.status = fmt.Sprintf("Subtree rewrite [%d/%d]: %s (d%d)", progress, m.subtreeTotal, item.summaryID, item.depth) + formatRedactionStatus(built.redactions)
 }
 
 // startPendingRewrite builds a dry-run rewrite preview for the selected summary.
@@ -1520,34 +2375,13 @@ func (m *model) startPendingRewrite() {
 		createdAt:      node.createdAt,
 	}
 
-	source, err := buildSummaryRewriteSource(ctx, db, item, true, time.Local)
-	if err != nil {
-		m.status = "Error: " + err.Error()
-		return
-	}
-	previousContext, err := resolveRewritePreviousContext(ctx, db, item)
-	if err != nil {
-		m.status = "Error: " + err.Error()
-		return
-	}
-	targetTokens := condensedTargetTokens
-	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-		targetTokens = calculateLeafTargetTokens(source.estimatedTokens)
-	}
-	prompt, err := renderPrompt(item.depth, PromptVars{
-		TargetTokens:    targetTokens,
-		PreviousContext: previousContext,
-		ChildCount:      source.itemCount,
-		TimeRange:       source.timeRange,
-		Depth:           item.depth,
-		SourceText:      source.text,
-	}, "")
+	built, err := m.buildInteractiveRewritePrompt(ctx, db, item)
 	if err != nil {
 		m.status = "Error: " + err.Error()
 		return
 	}
 
-	provider, model, baseURL := resolveInteractiveRewriteProviderModel(m.paths)
+	provider, model, baseURL := resolveInteractiveRewriteProviderModel(m.paths, m.agentDefaults)
 	apiKey, err := resolveProviderAPIKey(m.paths, provider)
 	if err != nil {
 		m.status = "Error: " + err.Error()
@@ -1560,20 +2394,20 @@ func (m *model) startPendingRewrite() {
 		depth:           item.depth,
 		oldContent:      item.content,
 		oldTokens:       item.tokenCount,
-		sourceText:      source.text,
-		sourceLabel:     source.label,
-		sourceCount:     source.itemCount,
-		timeRange:       source.timeRange,
-		prompt:          prompt,
-		targetTokens:    targetTokens,
-		previousContext: previousContext,
+		sourceText:      built.source.Text,
+		sourceLabel:     built.source.Label,
+		sourceCount:     built.source.ItemCount,
+		timeRange:       built.source.TimeRange,
+		prompt:          built.prompt,
+		targetTokens:    built.targetTokens,
+		previousContext: built.previousContext,
 		phase:           rewritePreview,
 		provider:        provider,
 		apiKey:          apiKey,
 		model:           model,
 		baseURL:         baseURL,
 	}
-	m.status = fmt.Sprintf("Ready to rewrite %s", summaryID)
+	m.status = fmt.Sprintf("Ready to rewrite %s", summaryID) + formatRedactionStatus(built.redactions)
 }
 
 func (m model) startPendingRewriteAPI() tea.Cmd {
@@ -1581,28 +2415,37 @@ func (m model) startPendingRewriteAPI() tea.Cmd {
 		return nil
 	}
 	pending := *m.pendingRewrite
+	generation := m.summaryGeneration
 	return func() tea.Msg {
+		if err := generation.checkProvider(pending.provider); err != nil {
+			return rewriteResultMsg{summaryID: pending.summaryID, err: err}
+		}
 		client := &anthropicClient{
 			provider: pending.provider,
 			apiKey:   pending.apiKey,
-			http:     &http.Client{Timeout: defaultHTTPTimeout},
+			http:     newSummaryHTTPClient(defaultHTTPTimeout),
 			model:    pending.model,
 			baseURL:  pending.baseURL,
+
+			temperature:     generation.temperature,
+			maxOutputTokens: generation.maxOutputTokens,
 		}
 		content, err := client.summarize(context.Background(), pending.prompt, pending.targetTokens)
 		if err != nil {
 			return rewriteResultMsg{summaryID: pending.summaryID, err: err}
 		}
+		tokens := lcm.EstimateTokenCount(content)
 		return rewriteResultMsg{
-			summaryID: pending.summaryID,
-			content:   content,
-			tokens:    estimateTokenCount(content),
+			summaryID:   pending.summaryID,
+			content:     content,
+			tokens:      tokens,
+			usageTokens: lcm.EstimateTokenCount(pending.prompt) + tokens,
 		}
 	}
 }
 
-func resolveInteractiveRewriteProviderModel(paths appDataPaths) (string, string, string) {
-	settings := resolveTUISummaryRuntimeSettings(paths, "", "", "", "", "")
+func resolveInteractiveRewriteProviderModel(paths appDataPaths, defaults agentDefaults) (string, string, string) {
+	settings := resolveTUISummaryRuntimeSettings(paths, defaults.Provider, defaults.Model, defaults.BaseURL, "", "")
 	return settings.provider, settings.model, settings.baseURL
 }
 
@@ -1612,9 +2455,14 @@ func rewriteSpinnerTickCmd() tea.Cmd {
 	})
 }
 
-func (m *model) confirmPendingRewrite() {
-	if m.pendingRewrite == nil || m.pendingRewrite.phase != rewriteReview || m.pendingRewrite.err != nil {
-		return
+// confirmPendingRewrite applies the reviewed rewrite and reports whether it
+// was written. Suspicious output stays in review until forced with "!".
+func (m *model) confirmPendingRewrite() bool {
+	if m.pendingRewrite == nil || m.pendingRewrite.err != nil {
+		return false
+	}
+	if m.pendingRewrite.phase != rewriteReview && m.pendingRewrite.phase != rewriteConfirm {
+		return false
 	}
 	plan := *m.pendingRewrite
 
@@ -1622,31 +2470,34 @@ func (m *model) confirmPendingRewrite() {
 	if err != nil {
 		m.pendingRewrite = nil
 		m.status = "Error: " + err.Error()
-		return
+		return false
 	}
 	defer db.Close()
 
-	if _, err := db.ExecContext(context.Background(), `
-		UPDATE summaries
-		SET content = ?, token_count = ?
-		WHERE summary_id = ?
-	`, plan.newContent, plan.newTokens, plan.summaryID); err != nil {
+	guard := defaultRewriteGuard()
+	guard.force = plan.force
+	if err := applySummaryRewrite(context.Background(), db, plan.summaryID, plan.newContent, plan.newTokens, plan.targetTokens, guard); err != nil {
+		if errors.Is(err, errSuspectRewrite) {
+			m.status = fmt.Sprintf("Refused to apply %s: %v (press ! to apply anyway)", plan.summaryID, err)
+			return false
+		}
 		m.pendingRewrite = nil
 		m.status = "Error: " + err.Error()
-		return
+		return false
 	}
+	m.recordRewriteAudit(plan)
 
 	session, ok := m.currentSession()
 	if !ok {
 		m.pendingRewrite = nil
 		m.status = fmt.Sprintf("Rewrote %s, but no session is selected for reload", plan.summaryID)
-		return
+		return true
 	}
 	summary, err := loadSummaryGraph(m.paths.lcmDBPath, session.id)
 	if err != nil {
 		m.pendingRewrite = nil
 		m.status = fmt.Sprintf("Rewrote %s, but reload failed: %v", plan.summaryID, err)
-		return
+		return true
 	}
 
 	m.summary = summary
@@ -1662,6 +2513,7 @@ func (m *model) confirmPendingRewrite() {
 		plan.oldTokens,
 		plan.newTokens,
 		plan.newTokens-plan.oldTokens)
+	return true
 }
 
 func (m *model) loadCurrentSummarySources() {
@@ -1682,10 +2534,26 @@ func (m *model) loadCurrentSummarySources() {
 		return
 	}
 	m.summarySources[id] = sources
+
+	if node := m.summary.nodes[id]; node != nil {
+		if m.summaryFreshness == nil {
+			m.summaryFreshness = make(map[string]string)
+		}
+		freshness, err := loadSummaryFreshness(m.paths.lcmDBPath, node)
+		if err != nil {
+			m.summaryFreshness[id] = "error: " + err.Error()
+		} else {
+			m.summaryFreshness[id] = freshness.describe()
+		}
+	}
 }
 
+// buildSummaryRows flattens the DAG for display. Shared summaries appear under
+// each parent, but only the first occurrence expands so a subtree is never
+// listed twice.
 func buildSummaryRows(graph summaryGraph) []summaryRow {
 	rows := make([]summaryRow, 0, len(graph.nodes))
+	listed := make(map[string]bool, len(graph.nodes))
 	var walk func(summaryID string, depth int, path map[string]bool)
 
 	walk = func(summaryID string, depth int, path map[string]bool) {
@@ -1696,6 +2564,11 @@ func buildSummaryRows(graph summaryGraph) []summaryRow {
 		if node == nil {
 			return
 		}
+		if listed[summaryID] {
+			rows = append(rows, summaryRow{summaryID: summaryID, depth: depth, repeat: true})
+			return
+		}
+		listed[summaryID] = true
 		rows = append(rows, summaryRow{summaryID: summaryID, depth: depth})
 		if !node.expanded {
 			return
@@ -1718,6 +2591,9 @@ func (m model) View() string {
 	if m.width <= 0 || m.height <= 0 {
 		return "Initializing openclaw-tui..."
 	}
+	if m.width < minViewWidth || m.height < minViewHeight {
+		return m.renderTooSmall()
+	}
 
 	header := m.renderHeader()
 	body := m.renderBody()
@@ -1725,19 +2601,43 @@ func (m model) View() string {
 	return header + "\n" + body + "\n" + footer
 }
 
+// renderTooSmall replaces the whole layout until the terminal is resized;
+// the next WindowSizeMsg restores the normal view. The hint is wrapped and
+// clipped so it never draws past the terminal itself.
+func (m model) renderTooSmall() string {
+	hint := fmt.Sprintf("Terminal too small (%d×%d) — resize to at least %d×%d", m.width, m.height, minViewWidth, minViewHeight)
+	lines := strings.Split(wrapText(hint, m.width), "\n")
+	if len(lines) > m.height {
+		lines = lines[:m.height]
+	}
+	for i, line := range lines {
+		lines[i] = truncateString(line, m.width)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (m model) renderHeader() string {
 	title := "openclaw-tui"
 	switch m.screen {
 	case screenAgents:
 		title += " | Agents"
+		if m.paths.lcmDBSource != "" {
+			title += " | db:" + m.paths.lcmDBPath
+		}
 	case screenSessions:
 		agentName := ""
 		if agent, ok := m.currentAgent(); ok {
 			agentName = " | " + agent.name
 		}
 		title += " | Sessions" + agentName
+		if m.sessionTagFilter != "" {
+			title += " | tag:#" + m.sessionTagFilter
+		}
 	case screenConversation:
 		title += " | Conversation"
+		if conversationTitle := m.currentConversationTitle(); conversationTitle != "" {
+			title += " | " + conversationTitle
+		}
 		if session, ok := m.currentSession(); ok {
 			title += fmt.Sprintf(" | session:%s", session.id)
 			if session.sessionKey != "" {
@@ -1747,11 +2647,17 @@ func (m model) renderHeader() string {
 		if conversationID, ok := m.currentConversationID(); ok {
 			title += fmt.Sprintf(" | conv_id:%d", conversationID)
 		}
+		if session, ok := m.currentSession(); ok && len(session.tags) > 0 {
+			title += " | " + formatConversationTags(session.tags)
+		}
 		if m.activeFocusBrief != nil {
 			title += fmt.Sprintf(" | focus:%s", shortFocusBriefID(m.activeFocusBrief.briefID))
 		}
 	case screenSummaries:
 		title += " | LCM Summary DAG"
+		if conversationTitle := m.currentConversationTitle(); conversationTitle != "" {
+			title += " | " + conversationTitle
+		}
 		if m.summary.conversationID > 0 {
 			title += fmt.Sprintf(" | conv_id:%d", m.summary.conversationID)
 		}
@@ -1760,6 +2666,9 @@ func (m model) renderHeader() string {
 		if conversationID, ok := m.currentConversationID(); ok {
 			title += fmt.Sprintf(" | conv_id:%d", conversationID)
 		}
+		if len(m.largeFiles) > 0 {
+			title += " | " + m.fileHeaderSummary()
+		}
 	case screenContext:
 		title += " | LCM Active Context"
 		if conversationID, ok := m.currentConversationID(); ok {
@@ -1788,13 +2697,22 @@ func (m model) renderHeader() string {
 }
 
 func (m model) renderHelp() string {
+	if m.titleEdit != nil {
+		return fmt.Sprintf("Rename conversation %d: %s_ | enter: save | esc: cancel", m.titleEdit.conversationID, string(m.titleEdit.input))
+	}
+	if m.moveInput != nil {
+		return fmt.Sprintf("Move %s to conversation: %s_ | enter: preview | esc: cancel", m.moveInput.summaryID, string(m.moveInput.input))
+	}
+	if m.fileFilterEditing {
+		return fmt.Sprintf("Filter files: %s_ | enter: done | esc: clear", m.fileFilter)
+	}
 	switch m.screen {
 	case screenAgents:
 		return "up/down: move | enter: open agent sessions | r: reload | q: quit"
 	case screenSessions:
-		return "up/down: move | enter: open conversation | x: Codex backend | v: Codex↔LCM compare | b: back | r: reload | q: quit"
+		return "up/down: move | enter: open conversation | x: Codex backend | v: Codex↔LCM compare | t: tag filter | " + m.timesToggleLabel() + " | b: back | r: reload | q: quit"
 	case screenConversation:
-		return "j/k/up/down: scroll | pgup/pgdown | g/G: top/bottom | [ / ]: older/newer window | r: reload | l: LCM summaries | c: context | o: focus briefs | f: LCM files | v: compare | b: back | q: quit"
+		return "j/k/up/down: scroll | pgup/pgdown | g/G: top/bottom | [ / ]: older/newer window | r: reload | l: LCM summaries | c: context | o: focus briefs | f: LCM files | v: compare | T: rename | " + m.timesToggleLabel() + " | b: back | q: quit"
 	case screenSummaries:
 		if m.pendingRewrite != nil {
 			switch m.pendingRewrite.phase {
@@ -1807,21 +2725,52 @@ func (m model) renderHelp() string {
 					return "Rewrite failed | enter/esc: close | q: quit"
 				}
 				if len(m.subtreeQueue) > 0 {
-					return fmt.Sprintf("Subtree rewrite [%d remaining] | y: apply & next | n: skip | esc: abort | d: diff | j/k: scroll", len(m.subtreeQueue))
+					return fmt.Sprintf("Subtree rewrite [%d remaining] | y: confirm & next | n: skip | esc: abort | d: diff | j/k: scroll", len(m.subtreeQueue))
+				}
+				if m.pendingRewrite.suspect != nil {
+					return "Rewrite review (suspicious) | !: force apply | n/esc: discard | d: toggle diff | j/k: scroll"
 				}
-				return "Rewrite review | y/enter: apply | n/esc: discard | d: toggle diff | j/k: scroll"
+				return "Rewrite review | y/enter: confirm | n/esc: discard | d: toggle diff | j/k: scroll"
+			case rewriteConfirm:
+				return "Confirm rewrite | Y: apply | n/esc: back to review | q: quit"
 			}
 		}
 		if m.pendingDissolve != nil {
 			return "Dissolve confirmation | y/enter: confirm | n/esc: cancel | q: quit"
 		}
+		if m.pendingMove != nil {
+			if len(m.pendingMove.blockers) > 0 {
+				return "Move blocked | n/esc: close | q: quit"
+			}
+			return "Move preview | Y: apply | n/esc: cancel | q: quit"
+		}
+		if m.pendingTimeRange != nil {
+			return "Time range update | y/enter: apply | n/esc: cancel | q: quit"
+		}
+		if m.pendingRecount != nil {
+			return "Token recount | y/enter: apply | n/esc: cancel | q: quit"
+		}
+		if m.promptView != nil {
+			return "Prompt view | j/k: scroll | pgup/pgdown | g/G: top/bottom | P/esc: close | q: quit"
+		}
+		if m.summaryHistogram {
+			return "Token histogram | H/esc: back to DAG | F: follow | q: quit"
+		}
 		nav := "↑↓: move  ⏎/l: expand  h: collapse  g/G: top/bottom  J/K: scroll detail"
-		actions := "w: rewrite  W: subtree rewrite  d: dissolve  f: files  r: reload  b: back  q: quit"
+		follow := "F: follow"
+		if m.summaryFollow {
+			follow = "F: follow [on]"
+		}
+		actions := fmt.Sprintf("w: rewrite  W: subtree rewrite  P: prompt  d: dissolve  m: move  t: time range  z: recount 0t  H: histogram  M: %s  T: rename  f: files  r: reload  %s  b: back  q: quit", m.markdownToggleLabel(), follow)
 		return nav + "\n" + actions
 	case screenFiles:
-		return "up/down: move | g/G: top/bottom | r: reload | b: back | q: quit"
+		return "up/down: move | g/G: top/bottom | s: sort | /: filter | r: reload | b: back | q: quit"
 	case screenContext:
-		return "up/down: move | g/G: top/bottom | r: reload | b: back | q: quit"
+		group := "s: group by segment"
+		if m.contextGrouped {
+			group = "s: flat list"
+		}
+		return "up/down: move | g/G: top/bottom | enter/x: explain summary | J/K: scroll detail | M: " + m.markdownToggleLabel() + " | " + group + " | r: reload | b: back | q: quit"
 	case screenFocusBriefs:
 		return "up/down: move | g/G: top/bottom | J/K: scroll detail | r: reload | b: back | q: quit"
 	case screenCodexContextCompare:
@@ -1860,6 +2809,13 @@ func (m model) renderStatus() string {
 	}
 	total := len(m.sessionFiles)
 	showing := len(m.sessions)
+	if m.sessionTagFilter != "" {
+		matching := len(m.visibleSessionIndexes())
+		if m.status == "" {
+			return fmt.Sprintf("showing %d tagged #%s of %d loaded (%d total)", matching, m.sessionTagFilter, showing, total)
+		}
+		return fmt.Sprintf("showing %d tagged #%s of %d loaded (%d total) | %s", matching, m.sessionTagFilter, showing, total, m.status)
+	}
 	if m.status == "" {
 		return fmt.Sprintf("showing %d of %d", showing, total)
 	}
@@ -1888,29 +2844,47 @@ func (m model) renderSessions() string {
 	if len(m.sessions) == 0 {
 		return "No session JSONL files found for this agent"
 	}
+	indexes := m.visibleSessionIndexes()
+	if len(indexes) == 0 {
+		return fmt.Sprintf("No loaded sessions are tagged #%s (t: next tag)", m.sessionTagFilter)
+	}
+	cursor := -1
+	for pos, idx := range indexes {
+		if idx == m.sessionCursor {
+			cursor = pos
+			break
+		}
+	}
 	visible := max(1, m.height-4)
-	offset := listOffset(m.sessionCursor, len(m.sessions), visible)
-	end := min(len(m.sessions), offset+visible)
-	labelWidth := m.sessionListLabelWidth(offset, end)
+	offset := listOffset(cursor, len(indexes), visible)
+	indexes = indexes[offset:min(len(indexes), offset+visible)]
+	labelWidth := m.sessionListLabelWidth(indexes)
 
 	lines := make([]string, 0, visible)
-	for idx := offset; idx < end; idx++ {
+	for _, idx := range indexes {
 		session := m.sessions[idx]
 		label := session.id
+		if title := conversationDisplayTitle(session); title != "" {
+			label += fmt.Sprintf("  %q", title)
+		}
 		if session.sessionKey != "" {
 			label += fmt.Sprintf("  key:%s", session.sessionKey)
 		}
+		if len(session.tags) > 0 {
+			label += "  " + formatConversationTags(session.tags)
+		}
 		line := fmt.Sprintf(
-			"  %-*s  %-19s  %-9s  %-12s  %-12s  %-14s  %-8s  %-9s",
+			"  %-*s  %-19s  %-9s  %-12s  %-12s  %-14s  %-8s  %-9s  %-8s",
 			labelWidth,
 			truncateString(label, labelWidth),
-			formatTimeForList(session.updatedAt),
+			displayListTime(session.updatedAt, m.relativeTimes),
 			fmt.Sprintf("msgs:%s", formatMessageCount(session.messageCount)),
 			fmt.Sprintf("est:%dt", session.estimatedTokens),
 			formatCodexSessionMetric(session),
 			formatOptionalSessionMetric("conv_id", session.conversationID > 0, session.conversationID),
 			formatOptionalSessionMetric("sums", session.summaryCount > 0, session.summaryCount),
 			formatOptionalSessionMetric("files", session.fileCount > 0, session.fileCount),
+			formatOptionalSessionMetric("convs", session.conversationCount > 1, session.conversationCount),
 		)
 		if idx == m.sessionCursor {
 			line = selectedStyle.Render("> " + line[2:])
@@ -1920,7 +2894,7 @@ func (m model) renderSessions() string {
 	return strings.Join(lines, "\n")
 }
 
-func (m model) sessionListLabelWidth(offset, end int) int {
+func (m model) sessionListLabelWidth(indexes []int) int {
 	const (
 		minLabelWidth = 24
 		maxLabelWidth = 72
@@ -1932,7 +2906,7 @@ func (m model) sessionListLabelWidth(offset, end int) int {
 	width := max(minLabelWidth, available)
 	width = min(width, maxLabelWidth)
 
-	for idx := offset; idx < end; idx++ {
+	for _, idx := range indexes {
 		label := m.sessions[idx].id
 		if m.sessions[idx].sessionKey != "" {
 			label += fmt.Sprintf("  key:%s", m.sessions[idx].sessionKey)
@@ -1983,12 +2957,31 @@ func (m model) renderSummaries() string {
 	if len(m.summary.nodes) == 0 {
 		return "No LCM summaries found for this session"
 	}
+	if m.rewriteAudit != nil {
+		return m.renderRewriteAudit()
+	}
 	if m.pendingRewrite != nil {
 		return m.renderRewriteOverlay()
 	}
 	if m.pendingDissolve != nil {
 		return m.renderDissolveConfirmation()
 	}
+	if m.pendingMove != nil {
+		return m.renderMoveConfirmation()
+	}
+	if m.pendingTimeRange != nil {
+		return m.renderTimeRangeConfirmation()
+	}
+	if m.pendingRecount != nil {
+		return m.renderRecountConfirmation()
+	}
+	if m.promptView != nil {
+		return m.renderPromptView()
+	}
+	if m.summaryHistogram {
+		lines := renderSummaryTokenHistogram(buildSummaryTokenHistogram(m.summary.nodes, defaultHistogramTop), m.width)
+		return strings.Join(lines[:min(len(lines), max(4, m.height-5))], "\n")
+	}
 	if len(m.summaryRows) == 0 {
 		return "Summary graph is empty"
 	}
@@ -2006,7 +2999,9 @@ func (m model) renderSummaries() string {
 			continue
 		}
 		marker := "-"
-		if len(node.children) > 0 {
+		if row.repeat {
+			marker = "="
+		} else if len(node.children) > 0 {
 			if node.expanded {
 				marker = "v"
 			} else {
@@ -2014,14 +3009,28 @@ func (m model) renderSummaries() string {
 			}
 		}
 		preview := oneLine(node.content)
+		if row.repeat {
+			preview = "(shared, listed above)"
+		}
 		preview = truncateString(preview, max(8, m.width-50))
 		kindLabel := node.kind
 		if node.kind == "condensed" {
 			kindLabel = fmt.Sprintf("d%d", node.depth)
 		}
-		line := fmt.Sprintf("%s%s %s [%s, %dt] %s", strings.Repeat("  ", row.depth), marker, node.id, kindLabel, node.tokenCount, preview)
+		if len(node.parents) > 1 {
+			kindLabel += fmt.Sprintf(", %d parents", len(node.parents))
+		}
+		tokens := fmt.Sprintf("%dt", node.tokenCount)
+		if node.tokenCount == 0 {
+			tokens = "0t!"
+		}
+		line := fmt.Sprintf("%s%s %s [%s, %s] %s", strings.Repeat("  ", row.depth), marker, node.id, kindLabel, tokens, preview)
 		if idx == m.summaryCursor {
 			line = selectedStyle.Render(line)
+		} else if m.summaryFlash[node.id] {
+			line = summaryFlashStyle.Render(line)
+		} else if node.tokenCount == 0 {
+			line = diffRemStyle.Render(line)
 		}
 		listLines = append(listLines, line)
 	}
@@ -2042,6 +3051,7 @@ func (m model) renderDissolveConfirmation() string {
 		fmt.Sprintf("Target: kind=%s depth=%d tokens=%d context_ordinal=%d", plan.target.kind, plan.target.depth, plan.target.tokenCount, plan.target.ordinal),
 		fmt.Sprintf("Token impact: %d -> %d (%+d)", plan.target.tokenCount, plan.totalParentTokens, plan.totalParentTokens-plan.target.tokenCount),
 		fmt.Sprintf("Ordinal shift: %d item(s) will shift by +%d", plan.itemsToShift, plan.shift),
+		"Ordinal check: " + formatDissolveOrdinalCheck(*plan),
 		"",
 		"Parent summaries to restore:",
 	}
@@ -2064,6 +3074,65 @@ func (m model) renderDissolveConfirmation() string {
 	return strings.Join(lines, "\n")
 }
 
+// renderMoveConfirmation lists every row the pending move would change.
+func (m model) renderMoveConfirmation() string {
+	plan := m.pendingMove
+	if plan == nil {
+		return "No move pending"
+	}
+	lines := formatMovePlanLines(*plan)
+	availableHeight := max(10, m.height-6)
+	if len(lines) > availableHeight {
+		hidden := len(lines) - availableHeight + 1
+		lines = append(lines[:availableHeight-1:availableHeight-1], fmt.Sprintf("  ... %d more lines; run lcm-tui move %s --to %d for the full list", hidden, plan.rootID, plan.targetConversationID))
+	}
+	lines = append(lines, "")
+	if len(plan.blockers) > 0 {
+		lines = append(lines, "This move cannot be applied. Press n or Esc to close.")
+	} else {
+		lines = append(lines, "Press Y (shift+y) to apply the move. Press n or Esc to cancel.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTimeRangeConfirmation shows stored vs recomputed summary time ranges.
+func (m model) renderTimeRangeConfirmation() string {
+	fix := m.pendingTimeRange
+	if fix == nil {
+		return "No time range update pending"
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("Recompute time range: %s", fix.summaryID),
+		"",
+		fmt.Sprintf("Stored:   %s", formatStoredTimeRange(fix.storedEarliest, fix.storedLatest)),
+		fmt.Sprintf("Computed: %s", formatStoredTimeRange(fix.computedEarliest, fix.computedLatest)),
+		"",
+		"Computed from the earliest and latest leaf messages beneath this summary.",
+		"Press y or Enter to update earliest_at/latest_at. Press n or Esc to cancel.",
+	}, "\n")
+}
+
+// renderRecountConfirmation shows the estimate for a zero-token summary.
+func (m model) renderRecountConfirmation() string {
+	pending := m.pendingRecount
+	if pending == nil {
+		return "No token recount pending"
+	}
+	lines := []string{
+		fmt.Sprintf("Recount tokens: %s (%s, d%d)", pending.summaryID, pending.kind, pending.depth),
+		"",
+		"Stored:    0",
+		fmt.Sprintf("Estimated: %d", pending.estimated),
+		"",
+		"A zero token_count skews per-depth totals and context budget math.",
+	}
+	if pending.badInsert() {
+		lines = append(lines, "Condensed summaries are never legitimately empty; this was likely a bad insert.")
+	}
+	lines = append(lines, "Press y or Enter to update token_count. Press n or Esc to cancel.")
+	return strings.Join(lines, "\n")
+}
+
 func (m model) renderRewriteOverlay() string {
 	if m.pendingRewrite == nil {
 		return "No rewrite preview pending"
@@ -2118,6 +3187,9 @@ func (m model) renderRewriteOverlay() string {
 		if rw.timeRange != "" {
 			lines = append(lines, "Time range: "+rw.timeRange)
 		}
+		if spent := m.renderSubtreeUsage(); spent != "" {
+			lines = append(lines, spent)
+		}
 		lines = append(lines, "")
 		lines = append(lines, "Waiting for API response...")
 		if m.autoAccept {
@@ -2137,6 +3209,9 @@ func (m model) renderRewriteOverlay() string {
 			lines = append(lines, "Time range: "+rw.timeRange)
 		}
 		lines = append(lines, fmt.Sprintf("Δ tokens: %+d (%d -> %d)", rw.newTokens-rw.oldTokens, rw.oldTokens, rw.newTokens))
+		if rw.suspect != nil {
+			lines = append(lines, diffRemStyle.Render(fmt.Sprintf("WARNING: %v. y/enter will refuse; press ! to confirm anyway.", rw.suspect)))
+		}
 		lines = append(lines, "")
 		// Build scrollable content lines
 		var contentLines []string
@@ -2175,11 +3250,13 @@ func (m model) renderRewriteOverlay() string {
 
 		lines = append(lines, "")
 		if len(m.subtreeQueue) > 0 {
-			lines = append(lines, fmt.Sprintf("y: apply & next | A: accept all remaining | n: skip | esc: abort | d: diff | j/k: scroll  [%d remaining]", len(m.subtreeQueue)))
+			lines = append(lines, fmt.Sprintf("y: confirm & next | A: accept all remaining | n: skip | esc: abort | d: diff | j/k: scroll  [%d remaining]", len(m.subtreeQueue)))
 		} else {
-			lines = append(lines, "y/enter: apply | n/esc: discard | d: toggle diff | j/k: scroll")
+			lines = append(lines, "y/enter: confirm | n/esc: discard | d: toggle diff | j/k: scroll")
 		}
 		return strings.Join(lines, "\n")
+	case rewriteConfirm:
+		return m.renderRewriteConfirm()
 	default:
 		return "Unknown rewrite state"
 	}
@@ -2198,10 +3275,19 @@ func (m *model) renderSummaryDetail(detailHeight int) []string {
 	// Build ALL lines (no height limit)
 	var allLines []string
 	allLines = append(allLines, fmt.Sprintf("Summary: %s", id))
-	allLines = append(allLines, fmt.Sprintf("Created: %s  Tokens: %d", formatTimestamp(node.createdAt), node.tokenCount))
+	allLines = append(allLines, fmt.Sprintf("Created: %s  Tokens: %d", displayTimestamp(node.createdAt, m.relativeTimes), node.tokenCount))
+	if freshness, ok := m.summaryFreshness[id]; ok {
+		line := "Freshness: " + freshness
+		if strings.HasPrefix(freshness, "STALE") {
+			line = diffRemStyle.Render(line)
+		}
+		allLines = append(allLines, line)
+	}
+	if len(node.parents) > 1 {
+		allLines = append(allLines, fmt.Sprintf("Parents (%d, shared): %s", len(node.parents), strings.Join(node.parents, ", ")))
+	}
 	allLines = append(allLines, "Content:")
-	wrappedContent := wrapText(node.content, max(20, m.width-4))
-	for _, line := range strings.Split(wrappedContent, "\n") {
+	for _, line := range m.renderDetailContent(node.content, max(20, m.width-4)) {
 		allLines = append(allLines, "  "+line)
 	}
 
@@ -2251,29 +3337,33 @@ func (m model) renderFiles() string {
 	if len(m.largeFiles) == 0 {
 		return "No large files found for this session"
 	}
+	files := m.visibleLargeFiles()
+	if len(files) == 0 {
+		return fmt.Sprintf("No large files match %q (/ to edit, esc in the prompt to clear)", m.fileFilter)
+	}
 
 	available := max(4, m.height-4)
 	detailHeight := max(7, available/2)
 	listHeight := max(3, available-detailHeight-1)
 
-	listOffsetValue := listOffset(m.fileCursor, len(m.largeFiles), listHeight)
+	listOffsetValue := listOffset(m.fileCursor, len(files), listHeight)
 	listLines := make([]string, 0, listHeight)
-	for idx := listOffsetValue; idx < min(len(m.largeFiles), listOffsetValue+listHeight); idx++ {
-		f := m.largeFiles[idx]
+	for idx := listOffsetValue; idx < min(len(files), listOffsetValue+listHeight); idx++ {
+		f := files[idx]
 		sizeStr := formatByteSizeCompact(f.byteSize)
 		line := fmt.Sprintf("  %s  %s  %s  %s  %s",
 			fileIDStyle.Render(f.fileID),
 			f.displayName(),
 			fileMimeStyle.Render(f.mimeType),
 			sizeStr,
-			formatTimestamp(f.createdAt))
+			displayTimestamp(f.createdAt, m.relativeTimes))
 		if idx == m.fileCursor {
 			line = selectedStyle.Render(fmt.Sprintf("> %s  %s  %s  %s  %s",
 				f.fileID,
 				f.displayName(),
 				f.mimeType,
 				sizeStr,
-				formatTimestamp(f.createdAt)))
+				displayTimestamp(f.createdAt, m.relativeTimes)))
 		}
 		listLines = append(listLines, line)
 	}
@@ -2284,14 +3374,15 @@ func (m model) renderFiles() string {
 
 func (m model) renderFileDetail(detailHeight int) []string {
 	lines := make([]string, 0, detailHeight)
-	if m.fileCursor < 0 || m.fileCursor >= len(m.largeFiles) {
+	files := m.visibleLargeFiles()
+	if m.fileCursor < 0 || m.fileCursor >= len(files) {
 		return append(lines, "No file selected")
 	}
-	f := m.largeFiles[m.fileCursor]
+	f := files[m.fileCursor]
 
 	lines = append(lines, fmt.Sprintf("File: %s", f.fileID))
 	lines = append(lines, fmt.Sprintf("Name: %s  MIME: %s  Size: %s  Created: %s",
-		f.displayName(), f.mimeType, formatByteSizeCompact(f.byteSize), formatTimestamp(f.createdAt)))
+		f.displayName(), f.mimeType, formatByteSizeCompact(f.byteSize), displayTimestamp(f.createdAt, m.relativeTimes)))
 	if f.storageURI != "" {
 		lines = append(lines, fmt.Sprintf("Storage: %s", f.storageURI))
 	}
@@ -2324,15 +3415,20 @@ func (m model) renderContext() string {
 	detailHeight := max(7, available/3)
 	listHeight := max(3, available-detailHeight-1)
 
-	listOffsetValue := listOffset(m.contextCursor, len(m.contextItems), listHeight)
-	listLines := make([]string, 0, listHeight)
-	for idx := listOffsetValue; idx < min(len(m.contextItems), listOffsetValue+listHeight); idx++ {
-		item := m.contextItems[idx]
-		line := m.formatContextItemLine(item)
-		if idx == m.contextCursor {
-			line = selectedStyle.Render(line)
+	var listLines []string
+	if m.contextGrouped {
+		listLines = m.renderGroupedContextList(listHeight)
+	} else {
+		listOffsetValue := listOffset(m.contextCursor, len(m.contextItems), listHeight)
+		listLines = make([]string, 0, listHeight)
+		for idx := listOffsetValue; idx < min(len(m.contextItems), listOffsetValue+listHeight); idx++ {
+			item := m.contextItems[idx]
+			line := m.formatContextItemLine(item)
+			if idx == m.contextCursor {
+				line = selectedStyle.Render(line)
+			}
+			listLines = append(listLines, line)
 		}
-		listLines = append(listLines, line)
 	}
 
 	detailLines := m.renderContextDetail(detailHeight)
@@ -2343,6 +3439,50 @@ func (m model) renderContext() string {
 	return rendered
 }
 
+// renderGroupedContextList draws the context list with a header and token
+// subtotal before each depth/segment group, scrolled to keep the cursor item
+// visible.
+func (m model) renderGroupedContextList(listHeight int) []string {
+	groups := groupContextItems(m.contextItems)
+	rows := buildContextGroupRows(groups)
+	totalTokens := 0
+	for _, item := range m.contextItems {
+		totalTokens += item.tokenCount
+	}
+	cursorRow := 0
+	for i, row := range rows {
+		if row.itemIndex == m.contextCursor {
+			cursorRow = i
+			break
+		}
+	}
+	// Keep the header of the cursor's group on screen when it fits.
+	offset := listOffset(cursorRow, len(rows), listHeight)
+	if header := cursorRow - (m.contextCursor - groups[rows[cursorRow].group].start) - 1; header < offset && cursorRow-header < listHeight {
+		offset = header
+	}
+
+	lines := make([]string, 0, listHeight)
+	for idx := offset; idx < min(len(rows), offset+listHeight); idx++ {
+		row := rows[idx]
+		if row.itemIndex < 0 {
+			group := groups[row.group]
+			line := helpStyle.Render(formatContextGroupHeader(group, totalTokens))
+			if group.warning != "" {
+				line += "  " + diffRemStyle.Render("! "+group.warning)
+			}
+			lines = append(lines, line)
+			continue
+		}
+		line := m.formatContextItemLine(m.contextItems[row.itemIndex])
+		if row.itemIndex == m.contextCursor {
+			line = selectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
 func (m model) formatContextItemLine(item contextItemEntry) string {
 	maxPreview := max(8, m.width-60)
 	preview := truncateString(item.preview, maxPreview)
@@ -2380,28 +3520,32 @@ func (m *model) renderContextDetail(detailHeight int) []string {
 	item := m.contextItems[m.contextCursor]
 
 	var allLines []string
-	if item.itemType == "summary" {
+	explaining := item.itemType == "summary" && m.contextExplainID == item.summaryID
+	if explaining {
+		allLines = append(allLines, m.contextExplainLines...)
+	} else if item.itemType == "summary" {
 		kindLabel := item.kind
 		if item.kind == "condensed" {
 			kindLabel = fmt.Sprintf("condensed d%d", item.depth)
 		}
 		allLines = append(allLines, fmt.Sprintf("Summary: %s [%s]", item.summaryID, kindLabel))
-		allLines = append(allLines, fmt.Sprintf("Tokens: %d  Created: %s", item.tokenCount, formatTimestamp(item.createdAt)))
+		allLines = append(allLines, fmt.Sprintf("Tokens: %d  Created: %s", item.tokenCount, displayTimestamp(item.createdAt, m.relativeTimes)))
 	} else if item.itemType == "focus_brief" {
 		allLines = append(allLines, fmt.Sprintf("Focus brief: %s", item.focusBriefID))
-		allLines = append(allLines, fmt.Sprintf("Tokens: %d  Created: %s", item.tokenCount, formatTimestamp(item.createdAt)))
+		allLines = append(allLines, fmt.Sprintf("Tokens: %d  Created: %s", item.tokenCount, displayTimestamp(item.createdAt, m.relativeTimes)))
 	} else {
 		allLines = append(allLines, fmt.Sprintf("Message: #%d [%s]", item.messageID, item.kind))
-		allLines = append(allLines, fmt.Sprintf("Tokens: %d  Created: %s", item.tokenCount, formatTimestamp(item.createdAt)))
-	}
-	allLines = append(allLines, "")
-	content := strings.TrimSpace(item.content)
-	if content == "" {
-		content = "(empty)"
+		allLines = append(allLines, fmt.Sprintf("Tokens: %d  Created: %s", item.tokenCount, displayTimestamp(item.createdAt, m.relativeTimes)))
 	}
-	wrapped := wrapText(content, max(20, m.width-4))
-	for _, line := range strings.Split(wrapped, "\n") {
-		allLines = append(allLines, "  "+line)
+	if !explaining {
+		allLines = append(allLines, "")
+		content := strings.TrimSpace(item.content)
+		if content == "" {
+			content = "(empty)"
+		}
+		for _, line := range m.renderDetailContent(content, max(20, m.width-4)) {
+			allLines = append(allLines, "  "+line)
+		}
 	}
 
 	// Clamp scroll offset
@@ -2459,7 +3603,7 @@ func (m model) formatFocusBriefLine(brief focusBriefEntry) string {
 		tokenLabel = fmt.Sprintf("%d/%dt", brief.tokenCount, brief.targetTokens)
 	}
 	return fmt.Sprintf("  %-10s %-19s [%s, %s] %s",
-		brief.status, formatTimestamp(brief.createdAt), id, tokenLabel, prompt)
+		brief.status, displayTimestamp(brief.createdAt, m.relativeTimes), id, tokenLabel, prompt)
 }
 
 // shortFocusBriefID returns a compact identifier for status chrome.
@@ -2522,7 +3666,7 @@ func (m *model) renderFocusBriefDetail(detailHeight int) []string {
 
 	allLines := []string{
 		fmt.Sprintf("Focus brief: %s [%s]", brief.briefID, brief.status),
-		fmt.Sprintf("Created: %s  Updated: %s", formatTimestamp(brief.createdAt), formatTimestamp(brief.updatedAt)),
+		fmt.Sprintf("Created: %s  Updated: %s", displayTimestamp(brief.createdAt, m.relativeTimes), displayTimestamp(brief.updatedAt, m.relativeTimes)),
 		fmt.Sprintf("Tokens: %d / %d  Sources: active=%d cited=%d expanded=%d irrelevant=%d",
 			brief.tokenCount, brief.targetTokens, brief.sourceCount, brief.citedCount, brief.expandedCount, brief.irrelevantCount),
 		fmt.Sprintf("Delta since focus: %d messages, %d summaries, ~%d tokens",
@@ -2595,7 +3739,7 @@ func (m *model) refreshConversationViewportWithMode(mode conversationViewportMod
 		m.convViewport.GotoTop()
 		return time.Since(start)
 	}
-	content := renderConversationText(m.messages, m.convViewport.Width)
+	content := renderConversationText(m.messages, m.convViewport.Width, m.relativeTimes)
 	if banner := renderActiveFocusBanner(m.activeFocusBrief, m.convViewport.Width); banner != "" {
 		content = banner + "\n\n" + content
 	}
@@ -2608,11 +3752,11 @@ func (m *model) refreshConversationViewportWithMode(mode conversationViewportMod
 	return time.Since(start)
 }
 
-func renderConversationText(messages []sessionMessage, width int) string {
+func renderConversationText(messages []sessionMessage, width int, relativeTimes bool) string {
 	maxWidth := max(20, width-2)
 	chunks := make([]string, 0, len(messages))
 	for _, msg := range messages {
-		timestamp := formatTimestamp(msg.timestamp)
+		timestamp := displayTimestamp(msg.timestamp, relativeTimes)
 		header := strings.TrimSpace(fmt.Sprintf("%s  %s", timestamp, strings.ToUpper(msg.role)))
 		if header == "" {
 			header = strings.ToUpper(msg.role)
@@ -2740,6 +3884,94 @@ func (m model) currentConversationID() (int64, bool) {
 	return session.conversationID, true
 }
 
+// titleEditState is the inline rename prompt opened with T.
+type titleEditState struct {
+	conversationID int64
+	input          []rune
+}
+
+// conversationDisplayTitle hides titles that just repeat the session ID, the
+// backfill default, so only meaningful names appear in lists and headers.
+func conversationDisplayTitle(session sessionEntry) string {
+	title := strings.TrimSpace(session.title)
+	if title == "" || title == session.id {
+		return ""
+	}
+	return title
+}
+
+func (m model) currentConversationTitle() string {
+	session, ok := m.currentSession()
+	if !ok {
+		return ""
+	}
+	return conversationDisplayTitle(session)
+}
+
+func (m *model) startTitleEdit() {
+	session, ok := m.currentSession()
+	if !ok || session.conversationID <= 0 {
+		m.status = "No LCM conversation for this session"
+		return
+	}
+	m.titleEdit = &titleEditState{
+		conversationID: session.conversationID,
+		input:          []rune(conversationDisplayTitle(session)),
+	}
+	m.status = fmt.Sprintf("Renaming conversation %d", session.conversationID)
+}
+
+func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.titleEdit = nil
+		m.status = "Rename canceled"
+	case tea.KeyEnter:
+		m.confirmTitleEdit()
+	case tea.KeyBackspace:
+		if n := len(m.titleEdit.input); n > 0 {
+			m.titleEdit.input = m.titleEdit.input[:n-1]
+		}
+	case tea.KeyCtrlU:
+		m.titleEdit.input = nil
+	case tea.KeySpace:
+		m.titleEdit.input = append(m.titleEdit.input, ' ')
+	case tea.KeyRunes:
+		m.titleEdit.input = append(m.titleEdit.input, msg.Runes...)
+	}
+	return m, nil
+}
+
+// confirmTitleEdit saves the prompt's title and updates the cached session so
+// headers and the session list reflect it without a reload.
+func (m *model) confirmTitleEdit() {
+	edit := m.titleEdit
+	title := normalizeConversationTitle(string(edit.input))
+	if title == "" {
+		m.status = "Title must not be empty (esc to cancel)"
+		return
+	}
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+	if err := setConversationTitle(context.Background(), db, edit.conversationID, title); err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+
+	for i := range m.sessions {
+		if m.sessions[i].conversationID == edit.conversationID {
+			m.sessions[i].title = title
+		}
+	}
+	m.titleEdit = nil
+	m.status = fmt.Sprintf("Conversation %d renamed to %q", edit.conversationID, title)
+}
+
 // refreshActiveFocusForSession loads the active focus overlay for TUI chrome.
 func (m *model) refreshActiveFocusForSession(session sessionEntry) error {
 	if session.conversationID <= 0 {
@@ -2791,20 +4023,45 @@ func oneLine(text string) string {
 	return strings.Join(fields, " ")
 }
 
+// truncateString shortens text to at most width terminal cells, measuring
+// runes by display width so CJK and emoji never split mid-character. When it
+// must cut, it prefers the last word boundary in the back half of the kept
+// text and appends "...".
 func truncateString(text string, width int) string {
 	if width <= 0 {
 		return ""
 	}
-	if len(text) <= width {
+	if runewidth.StringWidth(text) <= width {
 		return text
 	}
-	if width <= 1 {
-		return text[:width]
-	}
 	if width <= 3 {
-		return text[:width]
+		return runewidth.Truncate(text, width, "")
+	}
+
+	budget := width - 3
+	used := 0
+	cut := len(text)
+	wordCut := -1
+	wordCutWidth := 0
+	for i, r := range text {
+		w := runewidth.RuneWidth(r)
+		if used+w > budget {
+			cut = i
+			break
+		}
+		if unicode.IsSpace(r) {
+			wordCut = i
+			wordCutWidth = used
+		}
+		used += w
 	}
-	return text[:width-3] + "..."
+
+	kept := text[:cut]
+	next, _ := utf8.DecodeRuneInString(text[cut:])
+	if !unicode.IsSpace(next) && wordCut > 0 && wordCutWidth >= budget/2 {
+		kept = text[:wordCut]
+	}
+	return strings.TrimRightFunc(kept, unicode.IsSpace) + "..."
 }
 
 func padLines(lines []string, minHeight int) []string {
@@ -2894,6 +4151,87 @@ func (m *model) appendSessionBatch(limit int) (int, error) {
 	return len(batch), nil
 }
 
+// visibleSessionIndexes returns the indexes into m.sessions that pass the tag
+// filter. The cursor stays an index into m.sessions so currentSession works
+// unchanged while filtering.
+func (m model) visibleSessionIndexes() []int {
+	indexes := make([]int, 0, len(m.sessions))
+	for idx, session := range m.sessions {
+		if m.sessionTagFilter == "" || slices.Contains(session.tags, m.sessionTagFilter) {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexes
+}
+
+// moveFilteredSessionCursor steps to the next session in direction delta that
+// carries the filter tag, loading further batches when moving down past the
+// loaded sessions.
+func (m *model) moveFilteredSessionCursor(delta int) {
+	for idx := m.sessionCursor + delta; idx >= 0; idx += delta {
+		if idx >= len(m.sessions) {
+			if delta < 0 || m.sessionFileCursor >= len(m.sessionFiles) {
+				return
+			}
+			loaded, err := m.appendSessionBatch(sessionBatchLoadSize)
+			if err != nil {
+				m.status = "Error: " + err.Error()
+				return
+			}
+			if loaded == 0 {
+				return
+			}
+		}
+		if slices.Contains(m.sessions[idx].tags, m.sessionTagFilter) {
+			m.sessionCursor = idx
+			return
+		}
+	}
+}
+
+// cycleSessionTagFilter advances the filter through the tags on loaded
+// sessions in sorted order, then back to no filter.
+func (m *model) cycleSessionTagFilter() {
+	var tags []string
+	for _, session := range m.sessions {
+		for _, tag := range session.tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	if len(tags) == 0 {
+		m.sessionTagFilter = ""
+		m.status = "No loaded sessions are tagged (lcm-tui tag <conversation_id> <tag>)"
+		return
+	}
+
+	next := tags[0]
+	if m.sessionTagFilter != "" {
+		pos := sort.SearchStrings(tags, m.sessionTagFilter)
+		switch {
+		case pos < len(tags) && tags[pos] == m.sessionTagFilter && pos+1 < len(tags):
+			next = tags[pos+1]
+		case pos < len(tags) && tags[pos] == m.sessionTagFilter:
+			next = ""
+		case pos < len(tags):
+			next = tags[pos]
+		default:
+			next = ""
+		}
+	}
+	m.sessionTagFilter = next
+	if next == "" {
+		m.status = "Tag filter cleared"
+		return
+	}
+	m.status = "Filtering by #" + next
+	if indexes := m.visibleSessionIndexes(); !slices.Contains(indexes, m.sessionCursor) {
+		m.sessionCursor = indexes[0]
+	}
+}
+
 func (m *model) maybeLoadMoreSessions() int {
 	if len(m.sessions)-m.sessionCursor > 3 {
 		return 0
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+var (
+	mdHeadingStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("69"))
+	mdBoldStyle    = lipgloss.NewStyle().Bold(true)
+	mdCodeStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("180"))
+	mdQuoteStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	mdHeadingPattern = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	mdBulletPattern  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdNumberPattern  = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdBoldPattern    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdCodePattern    = regexp.MustCompile("`([^`]+)`")
+)
+
+// renderMarkdownLines is the markdown-aware alternative to wrapText for the
+// detail panes. It handles the subset summaries actually use: headings,
+// bullet and numbered lists with hanging indents, block quotes, fenced code,
+// and inline **bold** / `code`. Anything else is wrapped as plain text.
+func renderMarkdownLines(text string, width int) []string {
+	width = max(20, width)
+	var out []string
+	inFence := false
+	blank := false
+	for _, raw := range strings.Split(strings.ReplaceAll(strings.TrimSpace(text), "\r", ""), "\n") {
+		trimmed := strings.TrimSpace(raw)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, mdCodeStyle.Render("  "+raw))
+			blank = false
+			continue
+		}
+		if trimmed == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+
+		if match := mdHeadingPattern.FindStringSubmatch(trimmed); match != nil {
+			for _, line := range strings.Split(wordwrap.String(stripInlineMarkdown(match[1]), width), "\n") {
+				out = append(out, mdHeadingStyle.Render(line))
+			}
+			continue
+		}
+		if match := mdBulletPattern.FindStringSubmatch(raw); match != nil {
+			out = append(out, renderMarkdownListItem(match[1]+"• ", match[2], width)...)
+			continue
+		}
+		if match := mdNumberPattern.FindStringSubmatch(raw); match != nil {
+			out = append(out, renderMarkdownListItem(match[1]+match[2]+" ", match[3], width)...)
+			continue
+		}
+		if quote, ok := strings.CutPrefix(trimmed, ">"); ok {
+			for _, line := range strings.Split(wordwrap.String(strings.TrimSpace(quote), width-2), "\n") {
+				out = append(out, mdQuoteStyle.Render("│ "+line))
+			}
+			continue
+		}
+		for _, line := range strings.Split(wordwrap.String(trimmed, width), "\n") {
+			out = append(out, styleInlineMarkdown(line))
+		}
+	}
+	return out
+}
+
+// renderMarkdownListItem wraps one list item so continuation lines align with
+// the text after the marker.
+func renderMarkdownListItem(marker, body string, width int) []string {
+	indent := strings.Repeat(" ", lipgloss.Width(marker))
+	wrapped := strings.Split(wordwrap.String(body, max(10, width-len(indent))), "\n")
+	lines := make([]string, 0, len(wrapped))
+	for i, line := range wrapped {
+		prefix := indent
+		if i == 0 {
+			prefix = marker
+		}
+		lines = append(lines, prefix+styleInlineMarkdown(line))
+	}
+	return lines
+}
+
+// styleInlineMarkdown renders **bold** and `code` spans that fit on one
+// wrapped line. Spans split by wrapping are left as typed.
+func styleInlineMarkdown(line string) string {
+	line = mdBoldPattern.ReplaceAllStringFunc(line, func(span string) string {
+		return mdBoldStyle.Render(span[2 : len(span)-2])
+	})
+	return mdCodePattern.ReplaceAllStringFunc(line, func(span string) string {
+		return mdCodeStyle.Render(span[1 : len(span)-1])
+	})
+}
+
+// stripInlineMarkdown drops emphasis markers inside headings, which are
+// already styled as a whole.
+func stripInlineMarkdown(text string) string {
+	text = mdBoldPattern.ReplaceAllString(text, "$1$2")
+	return mdCodePattern.ReplaceAllString(text, "$1")
+}
+
+// renderDetailContent wraps summary or context content for a detail pane,
+// using markdown styling when the toggle is on.
+func (m model) renderDetailContent(content string, width int) []string {
+	if m.markdownView {
+		return renderMarkdownLines(content, width)
+	}
+	return strings.Split(wrapText(content, width), "\n")
+}
+
+func (m *model) toggleMarkdownView() {
+	m.markdownView = !m.markdownView
+	if m.markdownView {
+		m.status = "Markdown rendering on"
+	} else {
+		m.status = "Markdown rendering off (plain text)"
+	}
+}
+
+func (m model) markdownToggleLabel() string {
+	if m.markdownView {
+		return "markdown [on]"
+	}
+	return "markdown"
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownLinesStructure(t *testing.T) {
+	content := strings.Join([]string{
+		"## Goals & Context",
+		"",
+		"",
+		"- Ship the **merge** command with a long bullet that has to wrap onto another line",
+		"  - nested `flag`",
+		"2. second step",
+		"> quoted decision",
+		"```",
+		"  keep   spacing",
+		"```",
+		"Plain paragraph.",
+	}, "\n")
+
+	lines := renderMarkdownLines(content, 40)
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"Goals & Context",
+		"• Ship the merge command with a long",
+		"  bullet that has to wrap onto another",
+		"  • nested flag",
+		"2. second step",
+		"│ quoted decision",
+		"    keep   spacing",
+		"Plain paragraph.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("rendered markdown missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "##") || strings.Contains(got, "**") || strings.Contains(got, "```") {
+		t.Fatalf("expected markdown markers to be consumed:\n%s", got)
+	}
+	if lines[1] != "" || lines[2] == "" {
+		t.Fatalf("expected blank-line runs collapsed to one, got %q", lines[:3])
+	}
+}
+
+func TestRenderDetailContentHonorsToggle(t *testing.T) {
+	m := model{}
+	if got := strings.Join(m.renderDetailContent("# Title\n- item", 40), "\n"); !strings.Contains(got, "# Title") {
+		t.Fatalf("expected plain path to keep markdown as typed, got %q", got)
+	}
+	m.toggleMarkdownView()
+	if got := strings.Join(m.renderDetailContent("# Title\n- item", 40), "\n"); strings.Contains(got, "#") || !strings.Contains(got, "• item") {
+		t.Fatalf("expected markdown path to style content, got %q", got)
+	}
+	if !strings.Contains(m.status, "on") || m.markdownToggleLabel() != "markdown [on]" {
+		t.Fatalf("unexpected toggle state status=%q", m.status)
+	}
+	if opts := parseTUILaunchArgs([]string{"--markdown"}); !opts.markdown {
+		t.Fatal("expected --markdown to enable markdown view at launch")
+	}
+}
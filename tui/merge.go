@@ -0,0 +1,650 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type mergeOptions struct {
+	apply          bool
+	keepDuplicates bool
+	recompact      bool
+	provider       string
+	model          string
+	baseURL        string
+	httpTimeout    time.Duration
+	generation     summaryGenerationSettings
+	backupDB       bool
+}
+
+// mergeDuplicate is a from-conversation message whose role and content
+// already exist in the into conversation.
+type mergeDuplicate struct {
+	message         lcm.Message
+	matchingMessage int64
+}
+
+// mergeTimelineMessage is one into-conversation message in seq order.
+type mergeTimelineMessage struct {
+	messageID int64
+	seq       int64
+	createdAt string
+}
+
+type mergePlan struct {
+	intoConversationID int64
+	fromConversationID int64
+	intoMessages       []mergeTimelineMessage
+	fromMessageCount   int
+	copies             []lcm.Message // from messages to append, in seq order
+	duplicates         []mergeDuplicate
+	contextMessages    map[int64]bool // from message IDs that are raw items in from's context
+	interleaved        int            // copies older than into's newest message
+}
+
+// summaryOnlyCount is the number of copied messages that from's context only
+// reaches through summaries, so they land in into without a context item.
+func (p mergePlan) summaryOnlyCount() int {
+	count := 0
+	for _, message := range p.copies {
+		if !p.contextMessages[message.MessageID] {
+			count++
+		}
+	}
+	return count
+}
+
+// contextCopyCount is the number of context message items the merge adds.
+func (p mergePlan) contextCopyCount() int {
+	return len(p.copies) - p.summaryOnlyCount()
+}
+
+type mergeResult struct {
+	copiedMessages int
+	copiedParts    int
+	contextItems   int
+	resequenced    bool
+}
+
+// runMergeCommand appends one conversation's raw message timeline onto another.
+func runMergeCommand(args []string) error {
+	opts, intoConversationID, fromConversationID, err := parseMergeArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildMergePlan(ctx, db, intoConversationID, fromConversationID, opts.keepDuplicates)
+	if err != nil {
+		return err
+	}
+
+	printMergePlan(plan, opts)
+	if len(plan.copies) == 0 {
+		fmt.Println("Nothing to merge.")
+		return nil
+	}
+	if !opts.apply {
+		fmt.Println()
+		fmt.Println("Run with --apply to execute.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "merge", opts.backupDB, nil); err != nil {
+		return err
+	}
+	result, err := applyMerge(ctx, db, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. %d messages (%d message parts) and %d context items merged into conversation %d.\n",
+		result.copiedMessages, result.copiedParts, result.contextItems, intoConversationID)
+	if result.resequenced {
+		fmt.Printf("Renumbered conversation %d message seq values to keep chronological order.\n", intoConversationID)
+	}
+
+	if !opts.recompact {
+		return nil
+	}
+	return runMergeRecompaction(ctx, db, paths, intoConversationID, opts)
+}
+
+// runMergeRecompaction compacts the merged conversation with backfill's
+// default settings so the appended raw items are folded into summaries.
+func runMergeRecompaction(ctx context.Context, db *sql.DB, paths appDataPaths, conversationID int64, opts mergeOptions) error {
+	settings := resolveTUISummaryRuntimeSettings(paths, opts.provider, opts.model, opts.baseURL, "", "")
+	if err := opts.generation.checkProvider(settings.provider); err != nil {
+		return err
+	}
+	printStubSummarizerNotice(settings.provider)
+	apiKey, err := resolveProviderAPIKey(paths, settings.provider)
+	if err != nil {
+		return err
+	}
+	client := &anthropicClient{
+		provider: settings.provider,
+		apiKey:   apiKey,
+		http:     newSummaryHTTPClient(opts.httpTimeout),
+		model:    settings.model,
+		baseURL:  settings.baseURL,
+
+		temperature:     opts.generation.temperature,
+		maxOutputTokens: opts.generation.maxOutputTokens,
+	}
+
+	compaction := defaultBackfillCompactionOptions()
+	// merge has no --redact flag; LCM_TUI_REDACT and redact.json still apply.
+	if compaction.redactor, err = resolveSourceRedactor(false); err != nil {
+		return err
+	}
+	if err := applyConversationSettings(ctx, db, conversationID, &compaction); err != nil {
+		return err
+	}
+	stats, err := runBackfillCompaction(ctx, db, conversationID, compaction, client.summarize)
+	if err != nil {
+		return fmt.Errorf("recompact conversation %d: %w", conversationID, err)
+	}
+	fmt.Printf("Compaction passes: leaf=%d condensed=%d\n", stats.LeafPasses, stats.CondensedPasses)
+	printRedactionTotal(compaction.redactor, stats.Redactions)
+	return nil
+}
+
+// buildMergePlan loads both timelines and decides which from messages to
+// copy. Messages whose identity hash (role + content) already exists in into
+// are reported as duplicates and skipped unless keepDuplicates is set.
+func buildMergePlan(ctx context.Context, q sqlQueryer, intoConversationID, fromConversationID int64, keepDuplicates bool) (mergePlan, error) {
+	if intoConversationID == fromConversationID {
+		return mergePlan{}, errors.New("into and from conversation IDs must be different")
+	}
+	for _, id := range []int64{intoConversationID, fromConversationID} {
+		exists, err := lcm.ConversationExists(ctx, q, id)
+		if err != nil {
+			return mergePlan{}, err
+		}
+		if !exists {
+			return mergePlan{}, fmt.Errorf("conversation %d not found", id)
+		}
+	}
+
+	intoMessages, err := lcm.ConversationMessages(ctx, q, intoConversationID)
+	if err != nil {
+		return mergePlan{}, err
+	}
+	fromMessages, err := lcm.ConversationMessages(ctx, q, fromConversationID)
+	if err != nil {
+		return mergePlan{}, err
+	}
+	contextMessages, err := loadContextMessageIDs(ctx, q, fromConversationID)
+	if err != nil {
+		return mergePlan{}, err
+	}
+
+	plan := mergePlan{
+		intoConversationID: intoConversationID,
+		fromConversationID: fromConversationID,
+		intoMessages:       make([]mergeTimelineMessage, 0, len(intoMessages)),
+		fromMessageCount:   len(fromMessages),
+		contextMessages:    contextMessages,
+	}
+	intoHashes := make(map[string]int64, len(intoMessages))
+	newest := ""
+	for _, message := range intoMessages {
+		plan.intoMessages = append(plan.intoMessages, mergeTimelineMessage{
+			messageID: message.MessageID,
+			seq:       message.Seq,
+			createdAt: message.CreatedAt,
+		})
+		hash := lcm.MessageIdentityHash(message.Role, message.Content)
+		if _, ok := intoHashes[hash]; !ok {
+			intoHashes[hash] = message.MessageID
+		}
+		if message.CreatedAt > newest {
+			newest = message.CreatedAt
+		}
+	}
+
+	for _, message := range fromMessages {
+		if match, ok := intoHashes[lcm.MessageIdentityHash(message.Role, message.Content)]; ok && !keepDuplicates {
+			plan.duplicates = append(plan.duplicates, mergeDuplicate{message: message, matchingMessage: match})
+			continue
+		}
+		plan.copies = append(plan.copies, message)
+		if message.CreatedAt < newest {
+			plan.interleaved++
+		}
+	}
+	return plan, nil
+}
+
+// loadContextMessageIDs returns message IDs that sit in a conversation's
+// context as raw message items.
+func loadContextMessageIDs(ctx context.Context, q sqlQueryer, conversationID int64) (map[int64]bool, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT message_id
+		FROM context_items
+		WHERE conversation_id = ? AND item_type = 'message' AND message_id IS NOT NULL
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query context messages for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan context message for conversation %d: %w", conversationID, err)
+		}
+		ids[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate context messages for conversation %d: %w", conversationID, err)
+	}
+	return ids, nil
+}
+
+func printMergePlan(plan mergePlan, opts mergeOptions) {
+	fmt.Printf("Merge: conversation %d -> conversation %d\n\n", plan.fromConversationID, plan.intoConversationID)
+	fmt.Printf("Into conversation %d: %d messages\n", plan.intoConversationID, len(plan.intoMessages))
+	fmt.Printf("From conversation %d: %d messages\n\n", plan.fromConversationID, plan.fromMessageCount)
+
+	fmt.Printf("Append %d messages (with message parts) to conversation %d\n", len(plan.copies), plan.intoConversationID)
+	if plan.interleaved > 0 {
+		fmt.Printf("  %d are older than conversation %d's newest message; its seq values will be renumbered chronologically\n", plan.interleaved, plan.intoConversationID)
+	}
+	fmt.Printf("Add %d context message items\n", plan.contextCopyCount())
+	if summaryOnly := plan.summaryOnlyCount(); summaryOnly > 0 {
+		fmt.Printf("  %d copied messages are only covered by conversation %d's summaries; run `lcm-tui transplant %d %d` to carry those summaries over\n",
+			summaryOnly, plan.fromConversationID, plan.fromConversationID, plan.intoConversationID)
+	}
+
+	if len(plan.duplicates) > 0 {
+		fmt.Println()
+		fmt.Printf("Skipping %d duplicate messages (same role and content already in conversation %d):\n", len(plan.duplicates), plan.intoConversationID)
+		limit := min(len(plan.duplicates), 5)
+		for _, duplicate := range plan.duplicates[:limit] {
+			fmt.Printf("  seq %d  %s  message %d matches %d  %q\n",
+				duplicate.message.Seq, duplicate.message.Role, duplicate.message.MessageID, duplicate.matchingMessage, previewForLog(duplicate.message.Content, 48))
+		}
+		if len(plan.duplicates) > limit {
+			fmt.Printf("  ... and %d more\n", len(plan.duplicates)-limit)
+		}
+		fmt.Println("Pass --keep-duplicates to copy them anyway.")
+	}
+	if opts.recompact {
+		fmt.Println()
+		fmt.Printf("After --apply, conversation %d will be recompacted with backfill defaults.\n", plan.intoConversationID)
+	}
+}
+
+// applyMerge copies the planned messages, their parts, and their context
+// items into the into conversation in one transaction. When copies
+// interleave with existing messages, seq values and context ordinals are
+// renumbered so both follow created_at order; otherwise copies are appended.
+func applyMerge(ctx context.Context, db *sql.DB, plan mergePlan) (mergeResult, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return mergeResult{}, fmt.Errorf("begin merge transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	targetSessionID, err := lcm.ConversationSessionID(ctx, tx, plan.intoConversationID)
+	if err != nil {
+		return mergeResult{}, err
+	}
+	nextSeq, err := lcm.NextMessageSeq(ctx, tx, plan.intoConversationID)
+	if err != nil {
+		return mergeResult{}, err
+	}
+
+	result := mergeResult{}
+	copiedIDs := make([]int64, len(plan.copies))
+	for i, source := range plan.copies {
+		newMessageID, err := lcm.InsertCopiedMessage(ctx, tx, plan.intoConversationID, nextSeq+int64(i), source)
+		if err != nil {
+			return mergeResult{}, err
+		}
+		copiedIDs[i] = newMessageID
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO messages_fts (rowid, content)
+			VALUES (?, ?)
+		`, newMessageID, source.Content); err != nil {
+			return mergeResult{}, fmt.Errorf("insert messages_fts row for merged message %d: %w", newMessageID, err)
+		}
+		parts, err := lcm.CopyMessageParts(ctx, tx, source.MessageID, newMessageID, targetSessionID)
+		if err != nil {
+			return mergeResult{}, err
+		}
+		result.copiedMessages++
+		result.copiedParts += parts
+	}
+
+	finalSeq := make(map[int64]int64, len(plan.intoMessages)+len(copiedIDs))
+	for i, id := range mergeTimelineOrder(plan, copiedIDs) {
+		finalSeq[id] = int64(i)
+	}
+	if plan.interleaved > 0 {
+		if err := resequenceMergedMessages(ctx, tx, plan.intoConversationID, nextSeq+int64(len(copiedIDs)), finalSeq); err != nil {
+			return mergeResult{}, err
+		}
+		result.resequenced = true
+	}
+
+	var contextCopies []mergeContextCopy
+	for i, source := range plan.copies {
+		if plan.contextMessages[source.MessageID] {
+			contextCopies = append(contextCopies, mergeContextCopy{messageID: copiedIDs[i], seq: finalSeq[copiedIDs[i]], createdAt: source.CreatedAt})
+		}
+	}
+	if err := mergeContextMessageItems(ctx, tx, plan.intoConversationID, contextCopies, finalSeq); err != nil {
+		return mergeResult{}, err
+	}
+	result.contextItems = len(contextCopies)
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE conversations
+		SET updated_at = datetime('now')
+		WHERE conversation_id = ?
+	`, plan.intoConversationID); err != nil {
+		return mergeResult{}, fmt.Errorf("touch conversation %d: %w", plan.intoConversationID, err)
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "merge", ConversationID: plan.intoConversationID,
+		Detail: fmt.Sprintf("copied %d messages (%d context items) from conversation %d",
+			result.copiedMessages, result.contextItems, plan.fromConversationID),
+	}); err != nil {
+		return mergeResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return mergeResult{}, fmt.Errorf("commit merge transaction: %w", err)
+	}
+	rollback = false
+	return result, nil
+}
+
+// mergeTimelineOrder interleaves into's existing messages with the copied
+// ones by created_at. Each side keeps its own seq order, and into wins ties.
+func mergeTimelineOrder(plan mergePlan, copiedIDs []int64) []int64 {
+	order := make([]int64, 0, len(plan.intoMessages)+len(copiedIDs))
+	i, j := 0, 0
+	for i < len(plan.intoMessages) || j < len(copiedIDs) {
+		takeInto := j >= len(copiedIDs) ||
+			(i < len(plan.intoMessages) && plan.intoMessages[i].createdAt <= plan.copies[j].CreatedAt)
+		if takeInto {
+			order = append(order, plan.intoMessages[i].messageID)
+			i++
+			continue
+		}
+		order = append(order, copiedIDs[j])
+		j++
+	}
+	return order
+}
+
+// resequenceMergedMessages rewrites seq for every message in the conversation.
+// All rows move above maxSeq first so the UNIQUE (conversation_id, seq)
+// constraint never sees a collision mid-update.
+func resequenceMergedMessages(ctx context.Context, q sqlQueryer, conversationID, maxSeq int64, finalSeq map[int64]int64) error {
+	if _, err := q.ExecContext(ctx, `
+		UPDATE messages
+		SET seq = seq + ?
+		WHERE conversation_id = ?
+	`, maxSeq+1, conversationID); err != nil {
+		return fmt.Errorf("temporarily shift message seq for conversation %d: %w", conversationID, err)
+	}
+	for messageID, seq := range finalSeq {
+		if _, err := q.ExecContext(ctx, `
+			UPDATE messages
+			SET seq = ?
+			WHERE message_id = ? AND conversation_id = ?
+		`, seq, messageID, conversationID); err != nil {
+			return fmt.Errorf("renumber message %d in conversation %d: %w", messageID, conversationID, err)
+		}
+	}
+	return nil
+}
+
+type mergeContextCopy struct {
+	messageID int64
+	seq       int64
+	createdAt string
+}
+
+type mergeContextSlot struct {
+	rowID     int64 // 0 for a new item
+	messageID int64
+	createdAt string
+}
+
+// mergeContextMessageItems adds context items for copied messages. Existing
+// items keep their relative order; each new item goes right before the first
+// existing message item with a later seq, or at the end.
+func mergeContextMessageItems(ctx context.Context, q sqlQueryer, conversationID int64, copies []mergeContextCopy, finalSeq map[int64]int64) error {
+	if len(copies) == 0 {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT rowid, item_type, COALESCE(message_id, 0)
+		FROM context_items
+		WHERE conversation_id = ?
+		ORDER BY ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return fmt.Errorf("query context items for conversation %d: %w", conversationID, err)
+	}
+	type existingItem struct {
+		rowID     int64
+		itemType  string
+		messageID int64
+	}
+	var existing []existingItem
+	for rows.Next() {
+		var item existingItem
+		if err := rows.Scan(&item.rowID, &item.itemType, &item.messageID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan context item for conversation %d: %w", conversationID, err)
+		}
+		existing = append(existing, item)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate context items for conversation %d: %w", conversationID, err)
+	}
+	rows.Close()
+
+	slots := make([]mergeContextSlot, 0, len(existing)+len(copies))
+	next := 0
+	for _, item := range existing {
+		if item.itemType == "message" {
+			seq, ok := finalSeq[item.messageID]
+			for ok && next < len(copies) && copies[next].seq < seq {
+				slots = append(slots, mergeContextSlot{messageID: copies[next].messageID, createdAt: copies[next].createdAt})
+				next++
+			}
+		}
+		slots = append(slots, mergeContextSlot{rowID: item.rowID})
+	}
+	for ; next < len(copies); next++ {
+		slots = append(slots, mergeContextSlot{messageID: copies[next].messageID, createdAt: copies[next].createdAt})
+	}
+
+	// Park existing rows above every final ordinal before renumbering.
+	var maxOrdinal sql.NullInt64
+	if err := q.QueryRowContext(ctx, `
+		SELECT MAX(ordinal)
+		FROM context_items
+		WHERE conversation_id = ?
+	`, conversationID).Scan(&maxOrdinal); err != nil {
+		return fmt.Errorf("query max context ordinal for conversation %d: %w", conversationID, err)
+	}
+	if _, err := q.ExecContext(ctx, `
+		UPDATE context_items
+		SET ordinal = ordinal + ?
+		WHERE conversation_id = ?
+	`, int64(len(slots))+maxOrdinal.Int64+1, conversationID); err != nil {
+		return fmt.Errorf("temporarily shift context ordinals for conversation %d: %w", conversationID, err)
+	}
+	for ordinal, slot := range slots {
+		if slot.rowID != 0 {
+			if _, err := q.ExecContext(ctx, `
+				UPDATE context_items
+				SET ordinal = ?
+				WHERE rowid = ?
+			`, ordinal, slot.rowID); err != nil {
+				return fmt.Errorf("renumber context item for conversation %d: %w", conversationID, err)
+			}
+			continue
+		}
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, created_at)
+			VALUES (?, ?, 'message', ?, ?)
+		`, conversationID, ordinal, slot.messageID, slot.createdAt); err != nil {
+			return fmt.Errorf("insert merged context item for message %d: %w", slot.messageID, err)
+		}
+	}
+	return nil
+}
+
+func parseMergeArgs(args []string) (mergeOptions, int64, int64, error) {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	apply := fs.Bool("apply", false, "apply the merge to the DB")
+	_ = fs.Bool("dry-run", true, "show what would be merged")
+	keepDuplicates := fs.Bool("keep-duplicates", false, "copy messages even when identical content already exists in the target")
+	recompact := fs.Bool("recompact", false, "run compaction on the merged conversation after --apply")
+	provider := fs.String("provider", "", "provider id for --recompact")
+	model := fs.String("model", "", "summary model id for --recompact")
+	baseURL := fs.String("base-url", "", "custom API base URL for --recompact")
+	stub := fs.Bool("stub", false, "use the deterministic stub summarizer (demos/tests only)")
+	httpTimeout := fs.Duration("http-timeout", defaultHTTPTimeout, "timeout for each summary API call")
+	temperature := fs.String("temperature", "", "sampling temperature for --recompact summary calls")
+	maxOutputTokens := fs.Int("max-output-tokens", 0, "output token ceiling for --recompact summary calls")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized, err := normalizeMergeArgs(args)
+	if err != nil {
+		return mergeOptions{}, 0, 0, fmt.Errorf("%w\n%s", err, mergeUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		return mergeOptions{}, 0, 0, fmt.Errorf("%w\n%s", err, mergeUsageText())
+	}
+	if fs.NArg() != 2 {
+		return mergeOptions{}, 0, 0, fmt.Errorf("into and from conversation IDs are required\n%s", mergeUsageText())
+	}
+	intoConversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return mergeOptions{}, 0, 0, fmt.Errorf("parse into conversation ID %q: %w", fs.Arg(0), err)
+	}
+	fromConversationID, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+	if err != nil {
+		return mergeOptions{}, 0, 0, fmt.Errorf("parse from conversation ID %q: %w", fs.Arg(1), err)
+	}
+	stubProvider, err := resolveStubProviderFlag(*stub, strings.TrimSpace(*provider))
+	if err != nil {
+		return mergeOptions{}, 0, 0, fmt.Errorf("%w\n%s", err, mergeUsageText())
+	}
+
+	opts := mergeOptions{
+		apply:          *apply,
+		keepDuplicates: *keepDuplicates,
+		recompact:      *recompact,
+		provider:       stubProvider,
+		model:          strings.TrimSpace(*model),
+		baseURL:        strings.TrimSpace(*baseURL),
+		httpTimeout:    *httpTimeout,
+	}
+	if opts.httpTimeout <= 0 {
+		return mergeOptions{}, 0, 0, fmt.Errorf("--http-timeout must be > 0")
+	}
+	opts.generation, err = resolveSummaryGenerationSettings(*temperature, *maxOutputTokens)
+	if err != nil {
+		return mergeOptions{}, 0, 0, err
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return mergeOptions{}, 0, 0, err
+	}
+	return opts, intoConversationID, fromConversationID, nil
+}
+
+func normalizeMergeArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 2)
+
+	takesValue := map[string]bool{
+		"--provider":          true,
+		"--model":             true,
+		"--base-url":          true,
+		"--http-timeout":      true,
+		"--temperature":       true,
+		"--max-output-tokens": true,
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if takesValue[arg] {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--") || arg == "-h" {
+			flags = append(flags, arg)
+			continue
+		}
+		positionals = append(positionals, arg)
+	}
+	return append(flags, positionals...), nil
+}
+
+func mergeUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui merge <into_conversation_id> <from_conversation_id> [--dry-run]
+  lcm-tui merge <into_conversation_id> <from_conversation_id> --apply [--recompact] [--backup-db]
+
+Flags:
+  --dry-run               show the merge plan without writes (default)
+  --apply                 copy messages, message parts, and context message items
+  --keep-duplicates       copy messages whose role and content already exist in the into conversation
+  --recompact             run backfill-style compaction on the into conversation after --apply
+  --provider <id>         API provider for --recompact
+  --model <id>            API model for --recompact
+  --base-url <url>        custom API base URL for --recompact
+  --stub                  use the deterministic stub summarizer (demos/tests only)
+  --http-timeout <dur>    timeout for each summary API call (default 3m0s)
+  --temperature <t>       sampling temperature for --recompact, 0-2 (0-1 on Anthropic; default: provider default)
+  --max-output-tokens <n> output token ceiling for --recompact calls
+  --backup-db             with --apply, copy lcm.db to a timestamped backup first
+`)
+}
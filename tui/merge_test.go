@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func seedMergeConversations(t *testing.T) *sql.DB {
+	t.Helper()
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title)
+		VALUES (1, 'session-before-reset', 'Before'), (2, 'session-after-reset', 'After')
+	`)
+	// Conversation 1 was compacted up to message 10; conversation 2 replays
+	// message 10's content after the reset and then continues.
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(10, 1, 0, 'user', 'start the refactor', 4, '2026-03-01T10:00:00Z'),
+			(11, 1, 1, 'assistant', 'refactor underway', 4, '2026-03-01T10:05:00Z'),
+			(12, 1, 2, 'user', 'late note', 2, '2026-03-01T10:30:00Z'),
+			(20, 2, 0, 'user', 'start the refactor', 4, '2026-03-01T10:00:00Z'),
+			(21, 2, 1, 'assistant', 'picked up after reset', 5, '2026-03-01T10:10:00Z'),
+			(22, 2, 2, 'user', 'ship it', 2, '2026-03-01T10:40:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content)
+		VALUES
+			('p21', 21, 'session-after-reset', 'text', 0, 'picked up after reset'),
+			('p22', 22, 'session-after-reset', 'text', 0, 'ship it')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_before', 1, 'leaf', 0, 'started refactor', 3, '2026-03-01T10:06:00Z'),
+		       ('sum_after', 2, 'leaf', 0, 'picked up', 3, '2026-03-01T10:11:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES
+			(1, 0, 'summary', NULL, 'sum_before'),
+			(1, 1, 'message', 12, NULL),
+			(2, 0, 'summary', NULL, 'sum_after'),
+			(2, 1, 'message', 21, NULL),
+			(2, 2, 'message', 22, NULL)
+	`)
+	return db
+}
+
+func TestMergeConversationsInterleavesChronologically(t *testing.T) {
+	db := seedMergeConversations(t)
+	ctx := context.Background()
+
+	plan, err := buildMergePlan(ctx, db, 1, 2, false)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if len(plan.duplicates) != 1 || plan.duplicates[0].message.MessageID != 20 || plan.duplicates[0].matchingMessage != 10 {
+		t.Fatalf("expected message 20 to be flagged as a duplicate of 10, got %+v", plan.duplicates)
+	}
+	if len(plan.copies) != 2 || plan.interleaved != 1 || plan.contextCopyCount() != 2 {
+		t.Fatalf("unexpected plan copies=%d interleaved=%d context=%d", len(plan.copies), plan.interleaved, plan.contextCopyCount())
+	}
+
+	result, err := applyMerge(ctx, db, plan)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if result.copiedMessages != 2 || result.copiedParts != 2 || result.contextItems != 2 || !result.resequenced {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	rows, err := db.Query(`SELECT content FROM messages WHERE conversation_id = 1 ORDER BY seq`)
+	if err != nil {
+		t.Fatalf("query merged messages: %v", err)
+	}
+	defer rows.Close()
+	var timeline []string
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		timeline = append(timeline, content)
+	}
+	want := "start the refactor|refactor underway|picked up after reset|late note|ship it"
+	if got := strings.Join(timeline, "|"); got != want {
+		t.Fatalf("merged timeline = %s, want %s", got, want)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 1 AND seq BETWEEN 0 AND 4`, 5)
+
+	ctxRows, err := db.Query(`
+		SELECT COALESCE(ci.summary_id, m.content)
+		FROM context_items ci
+		LEFT JOIN messages m ON m.message_id = ci.message_id
+		WHERE ci.conversation_id = 1
+		ORDER BY ci.ordinal
+	`)
+	if err != nil {
+		t.Fatalf("query merged context: %v", err)
+	}
+	defer ctxRows.Close()
+	var contextOrder []string
+	for ctxRows.Next() {
+		var label string
+		if err := ctxRows.Scan(&label); err != nil {
+			t.Fatalf("scan context: %v", err)
+		}
+		contextOrder = append(contextOrder, label)
+	}
+	wantContext := "sum_before|picked up after reset|late note|ship it"
+	if got := strings.Join(contextOrder, "|"); got != wantContext {
+		t.Fatalf("merged context = %s, want %s", got, wantContext)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal BETWEEN 0 AND 3`, 4)
+	assertCount(t, db, `SELECT COUNT(*) FROM message_parts mp JOIN messages m ON m.message_id = mp.message_id WHERE m.conversation_id = 1 AND mp.session_id = 'session-before-reset'`, 2)
+	// The source conversation is copied, not moved.
+	assertCount(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 2`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 2`, 3)
+}
+
+func TestMergeKeepDuplicatesAndArgs(t *testing.T) {
+	db := seedMergeConversations(t)
+	plan, err := buildMergePlan(context.Background(), db, 1, 2, true)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if len(plan.duplicates) != 0 || len(plan.copies) != 3 || plan.summaryOnlyCount() != 1 {
+		t.Fatalf("unexpected keep-duplicates plan %+v", plan)
+	}
+
+	if _, _, _, err := parseMergeArgs([]string{"1"}); err == nil {
+		t.Fatal("expected missing from conversation to fail")
+	}
+	opts, into, from, err := parseMergeArgs([]string{"--apply", "7", "12", "--model", "m"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !opts.apply || into != 7 || from != 12 || opts.model != "m" {
+		t.Fatalf("unexpected parse result %+v %d %d", opts, into, from)
+	}
+	if _, err := buildMergePlan(context.Background(), db, 1, 1, false); err == nil {
+		t.Fatal("expected merging a conversation into itself to fail")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// summaryAPIError is a non-2xx response from a summary provider. It keeps the
+// status and provider error type so callers can tell model errors apart from
+// everything else.
+type summaryAPIError struct {
+	api     string // "Anthropic" or "OpenAI"
+	status  int
+	errType string
+	code    string
+	message string
+}
+
+func (e *summaryAPIError) Error() string {
+	if e.errType != "" || e.code != "" {
+		kind := e.errType
+		if kind == "" {
+			kind = e.code
+		}
+		return fmt.Sprintf("%s API %d %s: %s", e.api, e.status, kind, e.message)
+	}
+	return fmt.Sprintf("%s API %d: %s", e.api, e.status, e.message)
+}
+
+// modelUnavailableMarkers are message fragments providers use when a model
+// id is unknown, retired, or not served to this key.
+var modelUnavailableMarkers = []string{
+	"model not found",
+	"model_not_found",
+	"does not exist",
+	"deprecated",
+	"not available",
+	"not supported",
+	"unsupported model",
+	"no longer",
+}
+
+// isModelUnavailableError reports whether err means the requested model can't
+// serve the request right now (unknown, retired, or overloaded), as opposed to
+// a rate limit, auth problem, or bad prompt. Only these errors advance the
+// --model-fallback chain.
+func isModelUnavailableError(err error) bool {
+	var apiErr *summaryAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.status {
+	case http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	case http.StatusNotFound, http.StatusServiceUnavailable, 529:
+		return true
+	}
+	if apiErr.errType == "not_found_error" || apiErr.errType == "overloaded_error" || apiErr.code == "model_not_found" {
+		return true
+	}
+	message := strings.ToLower(apiErr.message)
+	if !strings.Contains(message, "model") {
+		return false
+	}
+	for _, marker := range modelUnavailableMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseModelFallbackList splits a comma-separated --model-fallback value,
+// dropping blanks and repeats.
+func parseModelFallbackList(raw string) []string {
+	var models []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		model := strings.TrimSpace(part)
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		models = append(models, model)
+	}
+	return models
+}
+
+// formatModelUsage renders per-model summary counts, e.g. "a (3), b (1)".
+func formatModelUsage(usage map[string]int) string {
+	models := make([]string, 0, len(usage))
+	for model := range usage {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	parts := make([]string, 0, len(models))
+	for _, model := range models {
+		parts = append(parts, fmt.Sprintf("%s (%d)", model, usage[model]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stdoutLogf prints CLI progress lines such as fallback substitutions.
+func stdoutLogf(format string, args ...any) {
+	fmt.Printf(format, args...)
+}
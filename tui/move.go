@@ -0,0 +1,659 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type moveOptions struct {
+	summaryID            string
+	targetConversationID int64
+	apply                bool
+	backupDB             bool
+}
+
+// moveSummaryRow is one summary whose conversation_id changes.
+type moveSummaryRow struct {
+	summaryID  string
+	kind       string
+	depth      int
+	tokenCount int
+}
+
+// moveMessageRow is one message that only moved leaves link to. It follows
+// the leaves to the target conversation with a new seq.
+type moveMessageRow struct {
+	messageID int64
+	oldSeq    int64
+	newSeq    int64
+	partIDs   []string
+}
+
+// moveContextRef is a context_items row that references a moved summary.
+type moveContextRef struct {
+	conversationID int64
+	ordinal        int64
+	summaryID      string
+}
+
+type moveOrdinalChange struct {
+	oldOrdinal int64
+	newOrdinal int64
+}
+
+// movePlan is every row a summary move would change. A plan with blockers
+// would orphan edges and cannot be applied.
+type movePlan struct {
+	rootID               string
+	sourceConversationID int64
+	targetConversationID int64
+	sourceSessionID      string
+	targetSessionID      string
+	summaries            []moveSummaryRow
+	messages             []moveMessageRow
+	parentEdges          int
+	messageEdges         int
+	contextRemovals      []moveContextRef // source conversation rows deleted
+	contextRenumbers     []moveOrdinalChange
+	targetContextRefs    []moveContextRef // target rows that become local
+	otherContextRefs     []moveContextRef // rows in third conversations, left foreign
+	blockers             []string
+}
+
+func (plan movePlan) summaryIDs() []string {
+	ids := make([]string, len(plan.summaries))
+	for i, summary := range plan.summaries {
+		ids[i] = summary.summaryID
+	}
+	return ids
+}
+
+// runMoveCommand executes the standalone move CLI path.
+func runMoveCommand(args []string) error {
+	opts, err := parseMoveArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildMovePlan(ctx, db, opts.summaryID, opts.targetConversationID)
+	if err != nil {
+		return err
+	}
+	for _, line := range formatMovePlanLines(plan) {
+		fmt.Println(line)
+	}
+	if len(plan.blockers) > 0 {
+		return fmt.Errorf("move of %s would orphan %d edges; nothing changed", plan.rootID, len(plan.blockers))
+	}
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to execute.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "move", opts.backupDB, nil); err != nil {
+		return err
+	}
+	fmt.Println("\nApplying...")
+	if err := applyMovePlan(ctx, db, plan); err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Moved %d summaries and %d messages from conversation %d to %d. Changes take effect on next conversation turn.\n",
+		len(plan.summaries), len(plan.messages), plan.sourceConversationID, plan.targetConversationID)
+	return nil
+}
+
+// buildMovePlan computes the rows that moving rootID and its source subtree
+// to targetConversationID would change, without writing. Only subtree
+// summaries owned by the root's conversation move; the messages their leaves
+// link to move with them when nothing else references those messages.
+// References that would be left pointing across conversations are recorded
+// as blockers.
+func buildMovePlan(ctx context.Context, q sqlQueryer, rootID string, targetConversationID int64) (movePlan, error) {
+	plan := movePlan{rootID: rootID, targetConversationID: targetConversationID}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT conversation_id FROM summaries WHERE summary_id = ?
+	`, rootID).Scan(&plan.sourceConversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return movePlan{}, fmt.Errorf("summary %s not found", rootID)
+	}
+	if err != nil {
+		return movePlan{}, fmt.Errorf("load summary %s: %w", rootID, err)
+	}
+	if plan.sourceConversationID == targetConversationID {
+		return movePlan{}, fmt.Errorf("summary %s already belongs to conversation %d", rootID, targetConversationID)
+	}
+	exists, err := lcm.ConversationExists(ctx, q, targetConversationID)
+	if err != nil {
+		return movePlan{}, err
+	}
+	if !exists {
+		return movePlan{}, fmt.Errorf("target conversation %d not found", targetConversationID)
+	}
+	if plan.sourceSessionID, err = lcm.ConversationSessionID(ctx, q, plan.sourceConversationID); err != nil {
+		return movePlan{}, err
+	}
+	if plan.targetSessionID, err = lcm.ConversationSessionID(ctx, q, targetConversationID); err != nil {
+		return movePlan{}, err
+	}
+
+	subtreeIDs, err := lcm.SummaryDAGIDs(ctx, q, []string{rootID})
+	if err != nil {
+		return movePlan{}, err
+	}
+	subtree, err := lcm.LoadSummaries(ctx, q, subtreeIDs)
+	if err != nil {
+		return movePlan{}, err
+	}
+	moving := make(map[string]bool, len(subtree))
+	for _, summary := range subtree {
+		if summary.ConversationID != plan.sourceConversationID {
+			continue
+		}
+		moving[summary.SummaryID] = true
+		plan.summaries = append(plan.summaries, moveSummaryRow{
+			summaryID: summary.SummaryID, kind: summary.Kind, depth: summary.Depth, tokenCount: summary.TokenCount,
+		})
+	}
+	sort.Slice(plan.summaries, func(i, j int) bool {
+		if plan.summaries[i].depth != plan.summaries[j].depth {
+			return plan.summaries[i].depth > plan.summaries[j].depth
+		}
+		return plan.summaries[i].summaryID < plan.summaries[j].summaryID
+	})
+
+	for _, summary := range plan.summaries {
+		if err := addMoveSummaryEdges(ctx, q, &plan, summary.summaryID, moving); err != nil {
+			return movePlan{}, err
+		}
+		if err := addMoveContextRefs(ctx, q, &plan, summary.summaryID); err != nil {
+			return movePlan{}, err
+		}
+	}
+	if err := addMoveMessages(ctx, q, &plan, moving); err != nil {
+		return movePlan{}, err
+	}
+	if err := addMoveOrdinalChanges(ctx, q, &plan); err != nil {
+		return movePlan{}, err
+	}
+	return plan, nil
+}
+
+// addMoveSummaryEdges counts summaryID's summary_parents edges and records a
+// blocker for each summary outside the move that uses summaryID as a source.
+func addMoveSummaryEdges(ctx context.Context, q sqlQueryer, plan *movePlan, summaryID string, moving map[string]bool) error {
+	sources, err := lcm.ParentSummaryIDs(ctx, q, summaryID)
+	if err != nil {
+		return err
+	}
+	plan.parentEdges += len(sources)
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT sp.summary_id, COALESCE(s.conversation_id, 0)
+		FROM summary_parents sp
+		LEFT JOIN summaries s ON s.summary_id = sp.summary_id
+		WHERE sp.parent_summary_id = ?
+		ORDER BY sp.summary_id ASC
+	`, summaryID)
+	if err != nil {
+		return fmt.Errorf("query summaries built from %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var consumerID string
+		var consumerConversationID int64
+		if err := rows.Scan(&consumerID, &consumerConversationID); err != nil {
+			return fmt.Errorf("scan summary built from %s: %w", summaryID, err)
+		}
+		if moving[consumerID] || consumerConversationID == plan.targetConversationID {
+			continue
+		}
+		plan.blockers = append(plan.blockers, fmt.Sprintf("summary %s (conversation %d) uses %s as a source", consumerID, consumerConversationID, summaryID))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate summaries built from %s: %w", summaryID, err)
+	}
+	return nil
+}
+
+// addMoveContextRefs sorts the context_items rows that reference summaryID
+// by conversation: source rows are deleted, target rows become local, and
+// rows elsewhere stay as they are.
+func addMoveContextRefs(ctx context.Context, q sqlQueryer, plan *movePlan, summaryID string) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT conversation_id, ordinal
+		FROM context_items
+		WHERE summary_id = ?
+		ORDER BY conversation_id ASC, ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return fmt.Errorf("query context items for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ref := moveContextRef{summaryID: summaryID}
+		if err := rows.Scan(&ref.conversationID, &ref.ordinal); err != nil {
+			return fmt.Errorf("scan context item for %s: %w", summaryID, err)
+		}
+		switch ref.conversationID {
+		case plan.sourceConversationID:
+			plan.contextRemovals = append(plan.contextRemovals, ref)
+		case plan.targetConversationID:
+			plan.targetContextRefs = append(plan.targetContextRefs, ref)
+		default:
+			plan.otherContextRefs = append(plan.otherContextRefs, ref)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate context items for %s: %w", summaryID, err)
+	}
+	return nil
+}
+
+// addMoveMessages collects the source-conversation messages linked from
+// moving leaves, assigns them seqs after the target's last message, and
+// records a blocker for each message something outside the move still uses.
+func addMoveMessages(ctx context.Context, q sqlQueryer, plan *movePlan, moving map[string]bool) error {
+	seen := make(map[int64]bool)
+	for _, summary := range plan.summaries {
+		rows, err := q.QueryContext(ctx, `
+			SELECT sm.message_id, m.conversation_id, m.seq
+			FROM summary_messages sm
+			JOIN messages m ON m.message_id = sm.message_id
+			WHERE sm.summary_id = ?
+			ORDER BY sm.ordinal ASC
+		`, summary.summaryID)
+		if err != nil {
+			return fmt.Errorf("query messages for %s: %w", summary.summaryID, err)
+		}
+		for rows.Next() {
+			var message moveMessageRow
+			var conversationID int64
+			if err := rows.Scan(&message.messageID, &conversationID, &message.oldSeq); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan message for %s: %w", summary.summaryID, err)
+			}
+			plan.messageEdges++
+			if conversationID != plan.sourceConversationID || seen[message.messageID] {
+				continue
+			}
+			seen[message.messageID] = true
+			plan.messages = append(plan.messages, message)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("iterate messages for %s: %w", summary.summaryID, err)
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("close message rows for %s: %w", summary.summaryID, err)
+		}
+	}
+	sort.Slice(plan.messages, func(i, j int) bool { return plan.messages[i].oldSeq < plan.messages[j].oldSeq })
+
+	nextSeq, err := lcm.NextMessageSeq(ctx, q, plan.targetConversationID)
+	if err != nil {
+		return err
+	}
+	for i := range plan.messages {
+		message := &plan.messages[i]
+		message.newSeq = nextSeq + int64(i)
+		if err := checkMoveMessageRefs(ctx, q, plan, message.messageID, moving); err != nil {
+			return err
+		}
+		partIDs, err := loadMessagePartIDs(ctx, q, message.messageID)
+		if err != nil {
+			return err
+		}
+		message.partIDs = partIDs
+	}
+	return nil
+}
+
+// checkMoveMessageRefs records a blocker for each summary or context item
+// outside the move and the target conversation that references messageID.
+func checkMoveMessageRefs(ctx context.Context, q sqlQueryer, plan *movePlan, messageID int64, moving map[string]bool) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT 'summary', sm.summary_id, COALESCE(s.conversation_id, 0), 0
+		FROM summary_messages sm
+		LEFT JOIN summaries s ON s.summary_id = sm.summary_id
+		WHERE sm.message_id = ?
+		UNION ALL
+		SELECT 'context', '', conversation_id, ordinal
+		FROM context_items
+		WHERE message_id = ?
+		ORDER BY 1 DESC, 3 ASC, 2 ASC, 4 ASC
+	`, messageID, messageID)
+	if err != nil {
+		return fmt.Errorf("query references to message %d: %w", messageID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var refType, summaryID string
+		var conversationID, ordinal int64
+		if err := rows.Scan(&refType, &summaryID, &conversationID, &ordinal); err != nil {
+			return fmt.Errorf("scan reference to message %d: %w", messageID, err)
+		}
+		if moving[summaryID] || conversationID == plan.targetConversationID {
+			continue
+		}
+		if refType == "summary" {
+			plan.blockers = append(plan.blockers, fmt.Sprintf("summary %s (conversation %d) also covers message #%d", summaryID, conversationID, messageID))
+		} else {
+			plan.blockers = append(plan.blockers, fmt.Sprintf("message #%d is in conversation %d's context at ordinal %d", messageID, conversationID, ordinal))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate references to message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+func loadMessagePartIDs(ctx context.Context, q sqlQueryer, messageID int64) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT part_id FROM message_parts WHERE message_id = ? ORDER BY ordinal ASC
+	`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("query message parts for %d: %w", messageID, err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan message part for %d: %w", messageID, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate message parts for %d: %w", messageID, err)
+	}
+	return ids, nil
+}
+
+// addMoveOrdinalChanges replays the source context after its removals are
+// deleted and resequenced, recording each ordinal that shifts.
+func addMoveOrdinalChanges(ctx context.Context, q sqlQueryer, plan *movePlan) error {
+	ordinals, err := loadContextOrdinals(ctx, q, plan.sourceConversationID)
+	if err != nil {
+		return err
+	}
+	if len(plan.contextRemovals) == 0 {
+		return nil
+	}
+	removed := make(map[int64]bool, len(plan.contextRemovals))
+	for _, ref := range plan.contextRemovals {
+		removed[ref.ordinal] = true
+	}
+	next := int64(0)
+	for _, ordinal := range ordinals {
+		if removed[ordinal] {
+			continue
+		}
+		if ordinal != next {
+			plan.contextRenumbers = append(plan.contextRenumbers, moveOrdinalChange{oldOrdinal: ordinal, newOrdinal: next})
+		}
+		next++
+	}
+	return nil
+}
+
+// formatMovePlanLines renders every row the move would change, for the CLI
+// dry run and the TUI confirmation.
+func formatMovePlanLines(plan movePlan) []string {
+	lines := []string{
+		fmt.Sprintf("Move %s and its source subtree from conversation %d to %d", plan.rootID, plan.sourceConversationID, plan.targetConversationID),
+		"",
+		fmt.Sprintf("summaries.conversation_id %d -> %d (%d rows):", plan.sourceConversationID, plan.targetConversationID, len(plan.summaries)),
+	}
+	for _, summary := range plan.summaries {
+		kindLabel := summary.kind
+		if summary.kind == "condensed" {
+			kindLabel = fmt.Sprintf("d%d", summary.depth)
+		}
+		lines = append(lines, fmt.Sprintf("  %s (%s, %dt)", summary.summaryID, kindLabel, summary.tokenCount))
+	}
+
+	if len(plan.messages) > 0 {
+		lines = append(lines, fmt.Sprintf("messages conversation_id %d -> %d (%d rows):", plan.sourceConversationID, plan.targetConversationID, len(plan.messages)))
+		for _, message := range plan.messages {
+			lines = append(lines, fmt.Sprintf("  #%d seq %d -> %d", message.messageID, message.oldSeq, message.newSeq))
+		}
+		parts := 0
+		for _, message := range plan.messages {
+			parts += len(message.partIDs)
+		}
+		if parts > 0 {
+			lines = append(lines, fmt.Sprintf("message_parts.session_id %s -> %s (%d rows):", plan.sourceSessionID, plan.targetSessionID, parts))
+			for _, message := range plan.messages {
+				for _, partID := range message.partIDs {
+					lines = append(lines, fmt.Sprintf("  %s (message #%d)", partID, message.messageID))
+				}
+			}
+		}
+	}
+
+	if len(plan.contextRemovals) > 0 {
+		lines = append(lines, fmt.Sprintf("context_items deleted from conversation %d (%d rows):", plan.sourceConversationID, len(plan.contextRemovals)))
+		for _, ref := range plan.contextRemovals {
+			lines = append(lines, fmt.Sprintf("  ordinal %d: %s", ref.ordinal, ref.summaryID))
+		}
+	}
+	if len(plan.contextRenumbers) > 0 {
+		lines = append(lines, fmt.Sprintf("context_items renumbered in conversation %d (%d rows):", plan.sourceConversationID, len(plan.contextRenumbers)))
+		for _, change := range plan.contextRenumbers {
+			lines = append(lines, fmt.Sprintf("  ordinal %d -> %d", change.oldOrdinal, change.newOrdinal))
+		}
+	}
+
+	lines = append(lines, "", fmt.Sprintf("Unchanged: %d summary_parents and %d summary_messages edges keep their IDs and move with the subtree.", plan.parentEdges, plan.messageEdges))
+	for _, ref := range plan.targetContextRefs {
+		lines = append(lines, fmt.Sprintf("Conversation %d's context ordinal %d (%s) stops being foreign.", ref.conversationID, ref.ordinal, ref.summaryID))
+	}
+	for _, ref := range plan.otherContextRefs {
+		lines = append(lines, fmt.Sprintf("Note: conversation %d's context ordinal %d (%s) stays foreign; see lcm-tui check-context.", ref.conversationID, ref.ordinal, ref.summaryID))
+	}
+
+	if len(plan.blockers) > 0 {
+		lines = append(lines, "", fmt.Sprintf("BLOCKED: moving would orphan %d edges:", len(plan.blockers)))
+		for _, blocker := range plan.blockers {
+			lines = append(lines, "  "+blocker)
+		}
+	}
+	return lines
+}
+
+// applyMovePlan performs the move in one transaction. The plan is rebuilt
+// inside the transaction and must match the previewed one, so the move
+// never commits rows the preview did not show.
+func applyMovePlan(ctx context.Context, db *sql.DB, previewed movePlan) error {
+	if len(previewed.blockers) > 0 {
+		return fmt.Errorf("move of %s is blocked: %s", previewed.rootID, previewed.blockers[0])
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin move transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	plan, err := buildMovePlan(ctx, tx, previewed.rootID, previewed.targetConversationID)
+	if err != nil {
+		return err
+	}
+	if len(plan.blockers) > 0 {
+		return fmt.Errorf("move of %s is blocked: %s", plan.rootID, plan.blockers[0])
+	}
+	if strings.Join(formatMovePlanLines(plan), "\n") != strings.Join(formatMovePlanLines(previewed), "\n") {
+		return errors.New("the database changed since the preview; run the move again to review the new plan")
+	}
+
+	tokens := 0
+	for _, summary := range plan.summaries {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE summaries SET conversation_id = ? WHERE summary_id = ?
+		`, plan.targetConversationID, summary.summaryID); err != nil {
+			return fmt.Errorf("move summary %s: %w", summary.summaryID, err)
+		}
+		tokens += summary.tokenCount
+	}
+	for _, message := range plan.messages {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE messages SET conversation_id = ?, seq = ? WHERE message_id = ?
+		`, plan.targetConversationID, message.newSeq, message.messageID); err != nil {
+			return fmt.Errorf("move message %d: %w", message.messageID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE message_parts SET session_id = ? WHERE message_id = ?
+		`, plan.targetSessionID, message.messageID); err != nil {
+			return fmt.Errorf("move parts of message %d: %w", message.messageID, err)
+		}
+	}
+	for _, ref := range plan.contextRemovals {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM context_items WHERE conversation_id = ? AND ordinal = ?
+		`, ref.conversationID, ref.ordinal); err != nil {
+			return fmt.Errorf("delete context item at ordinal %d: %w", ref.ordinal, err)
+		}
+	}
+	if len(plan.contextRemovals) > 0 {
+		if err := lcm.ResequenceContextOrdinals(ctx, tx, plan.sourceConversationID); err != nil {
+			return err
+		}
+	}
+
+	detail := fmt.Sprintf("moved %d summaries and %d messages from conversation %d to %d; removed %d context items",
+		len(plan.summaries), len(plan.messages), plan.sourceConversationID, plan.targetConversationID, len(plan.contextRemovals))
+	for _, conversationID := range []int64{plan.sourceConversationID, plan.targetConversationID} {
+		if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+			Command: "move", ConversationID: conversationID, SummaryIDs: plan.summaryIDs(),
+			TokensBefore: tokens, TokensAfter: tokens, Detail: detail,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit move transaction: %w", err)
+	}
+	rollback = false
+	return nil
+}
+
+func parseMoveArgs(args []string) (moveOptions, error) {
+	fs := flag.NewFlagSet("move", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	to := fs.Int64("to", 0, "target conversation ID (required)")
+	apply := fs.Bool("apply", false, "apply changes to the DB")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized, err := normalizeMoveArgs(args)
+	if err != nil {
+		return moveOptions{}, fmt.Errorf("%w\n%s", err, moveUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return moveOptions{}, errors.New(moveUsageText())
+		}
+		return moveOptions{}, fmt.Errorf("%w\n%s", err, moveUsageText())
+	}
+	if fs.NArg() != 1 || strings.TrimSpace(fs.Arg(0)) == "" {
+		return moveOptions{}, fmt.Errorf("summary ID is required\n%s", moveUsageText())
+	}
+	if *to <= 0 {
+		return moveOptions{}, fmt.Errorf("--to <conversation_id> is required\n%s", moveUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return moveOptions{}, err
+	}
+	return moveOptions{
+		summaryID:            strings.TrimSpace(fs.Arg(0)),
+		targetConversationID: *to,
+		apply:                *apply,
+		backupDB:             backup,
+	}, nil
+}
+
+func normalizeMoveArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--apply" || arg == "--backup-db":
+			flags = append(flags, arg)
+		case strings.HasPrefix(arg, "--to="):
+			flags = append(flags, arg)
+		case arg == "--to":
+			if i+1 >= len(args) {
+				return nil, errors.New("missing value for " + arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func moveUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui move <summary_id> --to <conversation_id> [--apply] [--backup-db]
+
+Reassign a summary that ended up under the wrong conversation, for example
+after a bad transplant. Unlike transplant, which copies, move changes the
+rows in place: the summary and every source summary beneath it that its
+conversation owns, plus the messages only those leaves cover (new seqs
+after the target's last message). The summary's context_items rows in the
+old conversation are deleted and its ordinals resequenced.
+
+The dry run lists every row that would change. The move is refused when it
+would orphan an edge: a summary outside the subtree that uses a moved
+summary as a source, or a moved message that another summary or a context
+item still references.
+
+Flags:
+  --to <id>      Target conversation ID (required)
+  --apply        Execute changes (default: dry run)
+  --backup-db    With --apply, copy lcm.db to a timestamped backup first
+`)
+}
+
+// parseMoveTargetInput parses the TUI prompt's conversation ID.
+func parseMoveTargetInput(input string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid conversation ID %q", strings.TrimSpace(input))
+	}
+	return id, nil
+}
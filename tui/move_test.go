@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// seedMoveConversations puts condensed sum_top (leaves sum_a and sum_b over
+// messages 1-3) in conversation 1's context between message 4 and sum_keep.
+// Conversation 2's context already points at sum_top, as a bad transplant
+// would leave it.
+func seedMoveConversations(t *testing.T, db *sql.DB) {
+	t.Helper()
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title)
+		VALUES (1, 'session-source', 'Source'), (2, 'session-target', 'Target')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 0, 'user', 'one', 1, '2026-03-22T10:00:00Z'),
+			(2, 1, 1, 'assistant', 'two', 1, '2026-03-22T10:01:00Z'),
+			(3, 1, 2, 'user', 'three', 1, '2026-03-22T10:02:00Z'),
+			(4, 1, 3, 'assistant', 'four', 1, '2026-03-22T10:03:00Z'),
+			(10, 2, 0, 'user', 'target seed', 2, '2026-03-22T11:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content)
+		VALUES ('part_1', 1, 'session-source', 'text', 0, 'one')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_a', 1, 'leaf', 0, 'a', 10, '2026-03-22T10:00:00Z'),
+			('sum_b', 1, 'leaf', 0, 'b', 10, '2026-03-22T10:02:00Z'),
+			('sum_top', 1, 'condensed', 1, 'top', 12, '2026-03-22T10:03:00Z'),
+			('sum_keep', 1, 'leaf', 0, 'keep', 10, '2026-03-22T10:04:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_top', 'sum_a', 0), ('sum_top', 'sum_b', 1)
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_a', 1, 0), ('sum_a', 2, 1), ('sum_b', 3, 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES
+			(1, 0, 'message', 4, NULL),
+			(1, 1, 'summary', NULL, 'sum_top'),
+			(1, 2, 'summary', NULL, 'sum_keep'),
+			(2, 0, 'message', 10, NULL),
+			(2, 1, 'summary', NULL, 'sum_top')
+	`)
+}
+
+func TestApplyMovePlanMovesSubtreeAndMessages(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	seedMoveConversations(t, db)
+
+	plan, err := buildMovePlan(ctx, db, "sum_top", 2)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if len(plan.blockers) != 0 {
+		t.Fatalf("unexpected blockers: %v", plan.blockers)
+	}
+	if got := strings.Join(plan.summaryIDs(), ","); got != "sum_top,sum_a,sum_b" {
+		t.Fatalf("moved summaries = %s", got)
+	}
+	if len(plan.messages) != 3 || plan.messages[0].newSeq != 1 || plan.messages[2].newSeq != 3 {
+		t.Fatalf("unexpected message moves %+v", plan.messages)
+	}
+	preview := strings.Join(formatMovePlanLines(plan), "\n")
+	for _, want := range []string{"#1 seq 0 -> 1", "part_1 (message #1)", "ordinal 1: sum_top", "ordinal 2 -> 1", "stops being foreign"} {
+		if !strings.Contains(preview, want) {
+			t.Fatalf("preview missing %q:\n%s", want, preview)
+		}
+	}
+
+	if err := applyMovePlan(ctx, db, plan); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = 2`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 2`, 4)
+	assertCount(t, db, `SELECT COUNT(*) FROM message_parts WHERE session_id = 'session-target'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM summary_parents`, 2)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 1 AND summary_id = 'sum_keep'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1`, 2)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'move'`, 2)
+	for _, conversationID := range []int64{1, 2} {
+		foreign, err := findForeignContextItems(ctx, db, conversationID)
+		if err != nil {
+			t.Fatalf("check context %d: %v", conversationID, err)
+		}
+		if len(foreign) != 0 {
+			t.Fatalf("conversation %d still has foreign context items: %+v", conversationID, foreign)
+		}
+	}
+}
+
+func TestBuildMovePlanBlocksOrphanedEdges(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	seedMoveConversations(t, db)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_other', 1, 'condensed', 1, 'other', 12, '2026-03-22T10:05:00Z')
+	`)
+	mustExec(t, db, `INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal) VALUES ('sum_other', 'sum_b', 0)`)
+	mustExec(t, db, `INSERT INTO context_items (conversation_id, ordinal, item_type, message_id) VALUES (1, 3, 'message', 2)`)
+
+	plan, err := buildMovePlan(ctx, db, "sum_top", 2)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	blockers := strings.Join(plan.blockers, "\n")
+	for _, want := range []string{"summary sum_other (conversation 1) uses sum_b as a source", "message #2 is in conversation 1's context at ordinal 3"} {
+		if !strings.Contains(blockers, want) {
+			t.Fatalf("blockers missing %q:\n%s", want, blockers)
+		}
+	}
+	if err := applyMovePlan(ctx, db, plan); err == nil {
+		t.Fatal("expected a blocked move to be refused")
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = 2`, 0)
+
+	if _, err := buildMovePlan(ctx, db, "sum_top", 1); err == nil || !strings.Contains(err.Error(), "already belongs") {
+		t.Fatalf("expected same-conversation move to be rejected, got %v", err)
+	}
+	if _, err := buildMovePlan(ctx, db, "sum_top", 99); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected missing target to be rejected, got %v", err)
+	}
+	if _, err := parseMoveArgs([]string{"sum_top"}); err == nil || !strings.Contains(err.Error(), "--to") {
+		t.Fatalf("expected --to to be required, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// parentOrdinalIssue is a condensed summary whose summary_parents ordinals
+// are not exactly 0..N-1. Rewrite and the DAG view order source children by
+// ordinal, so duplicates leave their order up to SQLite and gaps usually mean
+// a child was removed or inserted by hand.
+type parentOrdinalIssue struct {
+	summaryID string
+	depth     int
+	// parents lists parent_summary_id in current order: ordinal, then the
+	// parent's created_at and ID to break ties between duplicates.
+	parents    []string
+	ordinals   []int64
+	duplicates []int64
+	gaps       []int64
+}
+
+func (issue parentOrdinalIssue) describe() string {
+	var problems []string
+	if issue.ordinals[0] < 0 {
+		problems = append(problems, "negative")
+	}
+	if len(issue.duplicates) > 0 {
+		problems = append(problems, "duplicate "+joinInt64s(issue.duplicates))
+	}
+	if len(issue.gaps) > 0 {
+		problems = append(problems, "missing "+joinInt64s(issue.gaps))
+	}
+	return fmt.Sprintf("%s (d%d, %d children): ordinals %s; %s",
+		issue.summaryID, issue.depth, len(issue.ordinals), joinInt64s(issue.ordinals), strings.Join(problems, ", "))
+}
+
+func joinInt64s(values []int64) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// findParentOrdinalIssues checks summary_parents for every summary owned by
+// conversationID and returns those whose ordinals have duplicates or gaps.
+func findParentOrdinalIssues(ctx context.Context, q sqlQueryer, conversationID int64) ([]parentOrdinalIssue, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT sp.summary_id, COALESCE(s.depth, 0), sp.parent_summary_id, sp.ordinal
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.summary_id
+		LEFT JOIN summaries parent ON parent.summary_id = sp.parent_summary_id
+		WHERE s.conversation_id = ?
+		ORDER BY sp.summary_id ASC, sp.ordinal ASC, COALESCE(parent.created_at, '') ASC, `+caps.SummarySeqExpr("parent")+` ASC, sp.parent_summary_id ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query summary_parents ordinals for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var (
+		issues  []parentOrdinalIssue
+		current *parentOrdinalIssue
+	)
+	flush := func() {
+		if current != nil && classifyParentOrdinals(current) {
+			issues = append(issues, *current)
+		}
+	}
+	for rows.Next() {
+		var (
+			summaryID, parentID string
+			depth               int
+			ordinal             int64
+		)
+		if err := rows.Scan(&summaryID, &depth, &parentID, &ordinal); err != nil {
+			return nil, fmt.Errorf("scan summary_parents ordinal: %w", err)
+		}
+		if current == nil || current.summaryID != summaryID {
+			flush()
+			current = &parentOrdinalIssue{summaryID: summaryID, depth: depth}
+		}
+		current.parents = append(current.parents, parentID)
+		current.ordinals = append(current.ordinals, ordinal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate summary_parents ordinals: %w", err)
+	}
+	flush()
+	return issues, nil
+}
+
+// classifyParentOrdinals fills in duplicates and gaps from issue.ordinals,
+// which must be sorted, and reports whether the ordinals differ from 0..N-1.
+func classifyParentOrdinals(issue *parentOrdinalIssue) bool {
+	seen := make(map[int64]int, len(issue.ordinals))
+	for _, ordinal := range issue.ordinals {
+		seen[ordinal]++
+		if seen[ordinal] == 2 {
+			issue.duplicates = append(issue.duplicates, ordinal)
+		}
+	}
+	last := issue.ordinals[len(issue.ordinals)-1]
+	for ordinal := int64(0); ordinal <= last; ordinal++ {
+		if seen[ordinal] == 0 {
+			issue.gaps = append(issue.gaps, ordinal)
+		}
+	}
+	return len(issue.duplicates) > 0 || len(issue.gaps) > 0 || issue.ordinals[0] < 0
+}
+
+func printParentOrdinalIssues(conversationID int64, issues []parentOrdinalIssue) {
+	if len(issues) == 0 {
+		fmt.Printf("Conversation %d: summary_parents ordinals OK (0..N-1 for every condensed summary).\n", conversationID)
+		return
+	}
+	fmt.Printf("Conversation %d: %d condensed summaries have duplicate or gapped summary_parents ordinals:\n", conversationID, len(issues))
+	for _, issue := range issues {
+		fmt.Printf("  %s\n", issue.describe())
+	}
+}
+
+// fixParentOrdinals renumbers the summary_parents ordinals of every reported
+// summary to 0..N-1 in current order, in one transaction, and returns how
+// many summaries were renumbered. The set is recomputed inside the
+// transaction so the fix matches the DB it commits to.
+func fixParentOrdinals(ctx context.Context, db *sql.DB, conversationID int64) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin parent ordinal transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	issues, err := findParentOrdinalIssues(ctx, tx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	for _, issue := range issues {
+		for i, parentID := range issue.parents {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE summary_parents
+				SET ordinal = ?
+				WHERE summary_id = ? AND parent_summary_id = ?
+			`, i, issue.summaryID, parentID); err != nil {
+				return 0, fmt.Errorf("renumber %s parent %s: %w", issue.summaryID, parentID, err)
+			}
+		}
+	}
+	if len(issues) > 0 {
+		summaryIDs := make([]string, 0, len(issues))
+		for _, issue := range issues {
+			summaryIDs = append(summaryIDs, issue.summaryID)
+		}
+		if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+			Command: "check-context --fix", ConversationID: conversationID, SummaryIDs: summaryIDs,
+			Detail: fmt.Sprintf("renumbered summary_parents ordinals for %d summaries", len(issues)),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit parent ordinal fix: %w", err)
+	}
+	rollback = false
+	return len(issues), nil
+}
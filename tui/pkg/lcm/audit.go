@@ -0,0 +1,91 @@
+package lcm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ToolVersion is written to audit_log.tool_version by RecordAudit when an
+// entry leaves ToolVersion empty. Programs set it to their own version.
+var ToolVersion = "dev"
+
+// auditLogSchema is owned by lcm-tui, not the plugin. Mutating commands
+// insert a row inside the same transaction as the change it describes, so a
+// rolled-back change leaves no entry and a committed one always has one. The
+// triggers keep the table append-only.
+var auditLogSchema = []string{`
+	CREATE TABLE IF NOT EXISTS audit_log (
+		audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		command TEXT NOT NULL,
+		conversation_id INTEGER NOT NULL,
+		summary_ids TEXT NOT NULL DEFAULT '[]',
+		tokens_before INTEGER NOT NULL DEFAULT 0,
+		tokens_after INTEGER NOT NULL DEFAULT 0,
+		tool_version TEXT NOT NULL DEFAULT '',
+		detail TEXT NOT NULL DEFAULT ''
+	)
+`, `
+	CREATE INDEX IF NOT EXISTS audit_log_conversation_idx ON audit_log (conversation_id, audit_id)
+`, `
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END
+`, `
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END
+`}
+
+// AuditEntry is one mutation of a conversation. Token counts are the
+// token_count of what the change replaced and what it left in its place.
+type AuditEntry struct {
+	ID             int64    `json:"audit_id"`
+	CreatedAt      string   `json:"created_at"`
+	Command        string   `json:"command"`
+	ConversationID int64    `json:"conversation_id"`
+	SummaryIDs     []string `json:"summary_ids"`
+	TokensBefore   int      `json:"tokens_before"`
+	TokensAfter    int      `json:"tokens_after"`
+	ToolVersion    string   `json:"tool_version"`
+	Detail         string   `json:"detail,omitempty"`
+}
+
+// EnsureAuditLogTable creates audit_log and its append-only triggers when
+// they are missing.
+func EnsureAuditLogTable(ctx context.Context, q Queryer) error {
+	for _, stmt := range auditLogSchema {
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("create audit_log: %w", err)
+		}
+	}
+	ForgetSchemaCapabilities()
+	return nil
+}
+
+// RecordAudit appends entry to audit_log through q, which should be the
+// transaction making the change. ID and CreatedAt are assigned by the
+// database; an empty ToolVersion is filled from the package's ToolVersion.
+func RecordAudit(ctx context.Context, q Queryer, entry AuditEntry) error {
+	if err := EnsureAuditLogTable(ctx, q); err != nil {
+		return err
+	}
+	summaryIDs := entry.SummaryIDs
+	if summaryIDs == nil {
+		summaryIDs = []string{}
+	}
+	encoded, err := json.Marshal(summaryIDs)
+	if err != nil {
+		return fmt.Errorf("encode audit summary IDs: %w", err)
+	}
+	toolVersion := entry.ToolVersion
+	if toolVersion == "" {
+		toolVersion = ToolVersion
+	}
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO audit_log (command, conversation_id, summary_ids, tokens_before, tokens_after, tool_version, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.Command, entry.ConversationID, string(encoded), entry.TokensBefore, entry.TokensAfter, toolVersion, entry.Detail); err != nil {
+		return fmt.Errorf("record %s in audit_log: %w", entry.Command, err)
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SeqRange is the --start-seq/--end-seq window of a staged import.
+// Seqs are message positions in the session file, which backfill also
+// stores as messages.seq, so the highest stored seq marks how far earlier
+// runs got.
+type SeqRange struct {
+	Set   bool
+	Start int
+	End   int // inclusive; -1 imports through the end of the file
+}
+
+// Pending returns the messages in the range that are not imported yet,
+// given the conversation's highest stored seq (-1 when it has none).
+// Messages at or below highestSeq are skipped, so rerunning a range or
+// overlapping the previous one is safe. A start past highestSeq+1 is
+// refused because it would leave a gap in the conversation.
+func (r SeqRange) Pending(messages []BackfillMessage, highestSeq int) ([]BackfillMessage, error) {
+	if r.Start >= len(messages) {
+		return nil, fmt.Errorf("--start-seq %d is past the end of the session file (%d messages, last seq %d)", r.Start, len(messages), len(messages)-1)
+	}
+	if r.Start > highestSeq+1 {
+		if highestSeq < 0 {
+			return nil, fmt.Errorf("--start-seq %d would leave a gap: nothing is imported yet, so the first range must start at seq 0", r.Start)
+		}
+		return nil, fmt.Errorf("--start-seq %d would leave a gap: messages are imported through seq %d, so start at %d or lower", r.Start, highestSeq, highestSeq+1)
+	}
+	end := len(messages) - 1
+	if r.End >= 0 && r.End < end {
+		end = r.End
+	}
+	start := r.Start
+	if start <= highestSeq {
+		start = highestSeq + 1
+	}
+	if start > end {
+		return nil, nil
+	}
+	return messages[start : end+1], nil
+}
+
+// Describe renders the requested window, e.g. "seq 0-9999" or "seq 10000-end".
+func (r SeqRange) Describe() string {
+	if r.End < 0 {
+		return fmt.Sprintf("seq %d-end", r.Start)
+	}
+	return fmt.Sprintf("seq %d-%d", r.Start, r.End)
+}
+
+// BackfillMessage is one message parsed from a session file.
+type BackfillMessage struct {
+	Seq       int
+	Role      string
+	RawRole   string
+	Content   string
+	CreatedAt string
+}
+
+// BackfillInput is a parsed session to import.
+type BackfillInput struct {
+	Agent       string
+	SessionID   string
+	Title       string
+	SessionPath string
+	Messages    []BackfillMessage
+	SeqRange    SeqRange // zero value imports the whole file once
+}
+
+// BackfillOptions configures RunBackfill.
+type BackfillOptions struct {
+	Compaction CompactionOptions
+	// Recompact compacts a session that was already imported.
+	Recompact bool
+	// TransplantTo, when Transplant is set, is the conversation the
+	// backfilled summaries are transplanted into afterwards.
+	Transplant   bool
+	TransplantTo int64
+	// Configure, when set, is called before compaction with the imported
+	// conversation and may adjust the compaction options, e.g. from settings
+	// stored for that conversation.
+	Configure func(ctx context.Context, conversationID int64, opts *CompactionOptions) error
+	// ReviewTransplant, when set, is shown the transplant plan before it is
+	// applied. Plans with duplicate summaries are never applied.
+	ReviewTransplant func(plan TransplantPlan)
+}
+
+// RunBackfill imports input unless the session is already imported, compacts
+// the conversation when it imported anything (or opts.Recompact is set), and
+// then transplants it when opts.Transplant is set. summarize may be nil only
+// when nothing will be compacted.
+func RunBackfill(ctx context.Context, db TxBeginner, input BackfillInput, opts BackfillOptions, summarize Summarizer) (BackfillResult, CompactionStats, error) {
+	plan, err := InspectBackfillImport(ctx, db, input.SessionID)
+	if err != nil {
+		return BackfillResult{}, CompactionStats{}, err
+	}
+
+	result := BackfillResult{}
+	stats := CompactionStats{}
+	if plan.HasData && !input.SeqRange.Set {
+		result = BackfillResult{
+			ConversationID: plan.ConversationID,
+			Imported:       false,
+			MessageCount:   plan.MessageCount,
+		}
+	} else {
+		result, err = ApplyBackfillImport(ctx, db, input)
+		if err != nil {
+			return BackfillResult{}, CompactionStats{}, err
+		}
+	}
+
+	if result.Imported || opts.Recompact {
+		if summarize == nil {
+			return BackfillResult{}, CompactionStats{}, errors.New("backfill summarize function is required for apply mode")
+		}
+		compaction := opts.Compaction
+		if opts.Configure != nil {
+			if err := opts.Configure(ctx, result.ConversationID, &compaction); err != nil {
+				return BackfillResult{}, CompactionStats{}, err
+			}
+		}
+		stats, err = RunCompaction(ctx, db, result.ConversationID, compaction, summarize)
+		if err != nil {
+			return BackfillResult{}, CompactionStats{}, err
+		}
+	}
+
+	if opts.Transplant {
+		transplantPlan, err := BuildTransplantPlan(ctx, db, result.ConversationID, opts.TransplantTo)
+		if err != nil {
+			return BackfillResult{}, CompactionStats{}, err
+		}
+		if opts.ReviewTransplant != nil {
+			opts.ReviewTransplant(transplantPlan)
+		}
+		if len(transplantPlan.Duplicates) > 0 {
+			return BackfillResult{}, CompactionStats{}, fmt.Errorf("aborting transplant: target conversation %d already contains %d matching summary content hashes", opts.TransplantTo, len(transplantPlan.Duplicates))
+		}
+		if _, err := ApplyTransplant(ctx, db, transplantPlan, opts.Compaction.Log); err != nil {
+			return BackfillResult{}, CompactionStats{}, err
+		}
+	}
+
+	return result, stats, nil
+}
+
+// BackfillImportPlan is what a session already has in the database.
+type BackfillImportPlan struct {
+	ConversationID int64
+	HasData        bool
+	MessageCount   int
+	ContextCount   int
+	SummaryCount   int
+	HighestSeq     int   // -1 when no messages are stored
+	NextOrdinal    int64 // first free context ordinal
+}
+
+// BackfillResult describes an import.
+type BackfillResult struct {
+	ConversationID int64
+	Imported       bool
+	MessageCount   int
+	// FirstSeq/LastSeq bound the messages this run imported; TotalMessages
+	// and HighestSeq describe the conversation afterwards.
+	FirstSeq      int
+	LastSeq       int
+	TotalMessages int
+	HighestSeq    int
+}
+
+// InspectBackfillImport finds the newest conversation for sessionID and
+// counts what it already holds. A session never imported has HighestSeq -1.
+func InspectBackfillImport(ctx context.Context, q Queryer, sessionID string) (BackfillImportPlan, error) {
+	var conversationID int64
+	err := q.QueryRowContext(ctx, `
+		SELECT conversation_id
+		FROM conversations
+		WHERE session_id = ?
+		ORDER BY conversation_id DESC
+		LIMIT 1
+	`, sessionID).Scan(&conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BackfillImportPlan{HighestSeq: -1}, nil
+	}
+	if err != nil {
+		return BackfillImportPlan{}, fmt.Errorf("query existing conversation for session %s: %w", sessionID, err)
+	}
+
+	var messageCount int
+	if err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM messages WHERE conversation_id = ?
+	`, conversationID).Scan(&messageCount); err != nil {
+		return BackfillImportPlan{}, fmt.Errorf("count messages for conversation %d: %w", conversationID, err)
+	}
+
+	var contextCount int
+	if err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM context_items WHERE conversation_id = ?
+	`, conversationID).Scan(&contextCount); err != nil {
+		return BackfillImportPlan{}, fmt.Errorf("count context items for conversation %d: %w", conversationID, err)
+	}
+
+	var summaryCount int
+	if err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM summaries WHERE conversation_id = ?
+	`, conversationID).Scan(&summaryCount); err != nil {
+		return BackfillImportPlan{}, fmt.Errorf("count summaries for conversation %d: %w", conversationID, err)
+	}
+
+	var highestSeq int
+	if err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(seq), -1) FROM messages WHERE conversation_id = ?
+	`, conversationID).Scan(&highestSeq); err != nil {
+		return BackfillImportPlan{}, fmt.Errorf("read highest message seq for conversation %d: %w", conversationID, err)
+	}
+
+	var nextOrdinal int64
+	if err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(ordinal) + 1, 0) FROM context_items WHERE conversation_id = ?
+	`, conversationID).Scan(&nextOrdinal); err != nil {
+		return BackfillImportPlan{}, fmt.Errorf("read next context ordinal for conversation %d: %w", conversationID, err)
+	}
+
+	return BackfillImportPlan{
+		ConversationID: conversationID,
+		HasData:        messageCount > 0 || contextCount > 0 || summaryCount > 0,
+		MessageCount:   messageCount,
+		ContextCount:   contextCount,
+		SummaryCount:   summaryCount,
+		HighestSeq:     highestSeq,
+		NextOrdinal:    nextOrdinal,
+	}, nil
+}
+
+// ApplyBackfillImport inserts input's messages with their context items,
+// search rows, and text parts in one transaction, creating the conversation
+// when needed. A session already imported is left alone unless input.SeqRange
+// is set, in which case only the messages past its highest seq are appended.
+func ApplyBackfillImport(ctx context.Context, db TxBeginner, input BackfillInput) (BackfillResult, error) {
+	plan, err := InspectBackfillImport(ctx, db, input.SessionID)
+	if err != nil {
+		return BackfillResult{}, err
+	}
+	// A staged import appends only the unimported part of its seq range;
+	// a whole-file import runs once per session.
+	messages := input.Messages
+	firstOrdinal := int64(0)
+	if input.SeqRange.Set {
+		messages, err = input.SeqRange.Pending(input.Messages, plan.HighestSeq)
+		if err != nil {
+			return BackfillResult{}, err
+		}
+		firstOrdinal = plan.NextOrdinal
+	}
+	if (plan.HasData && !input.SeqRange.Set) || len(messages) == 0 {
+		return BackfillResult{
+			ConversationID: plan.ConversationID,
+			Imported:       false,
+			MessageCount:   plan.MessageCount,
+			TotalMessages:  plan.MessageCount,
+			HighestSeq:     plan.HighestSeq,
+		}, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return BackfillResult{}, fmt.Errorf("begin backfill import transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	conversationID := plan.ConversationID
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		title = input.SessionID
+	}
+
+	if conversationID == 0 {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO conversations (session_id, title, bootstrapped_at)
+			VALUES (?, ?, datetime('now'))
+		`, input.SessionID, title)
+		if err != nil {
+			return BackfillResult{}, fmt.Errorf("insert conversation for session %s: %w", input.SessionID, err)
+		}
+		conversationID, err = result.LastInsertId()
+		if err != nil {
+			return BackfillResult{}, fmt.Errorf("read conversation ID for session %s: %w", input.SessionID, err)
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE conversations
+			SET title = COALESCE(NULLIF(title, ''), ?),
+			    bootstrapped_at = COALESCE(bootstrapped_at, datetime('now')),
+			    updated_at = datetime('now')
+			WHERE conversation_id = ?
+		`, title, conversationID); err != nil {
+			return BackfillResult{}, fmt.Errorf("update existing conversation %d: %w", conversationID, err)
+		}
+	}
+
+	for idx, msg := range messages {
+		seq := idx
+		if input.SeqRange.Set {
+			seq = msg.Seq
+		}
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO messages (conversation_id, seq, role, content, token_count, identity_hash, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, conversationID, seq, msg.Role, msg.Content, EstimateTokenCount(msg.Content), MessageIdentityHash(msg.Role, msg.Content), msg.CreatedAt)
+		if err != nil {
+			return BackfillResult{}, fmt.Errorf("insert backfill message seq=%d: %w", seq, err)
+		}
+		messageID, err := result.LastInsertId()
+		if err != nil {
+			return BackfillResult{}, fmt.Errorf("read message ID for seq=%d: %w", seq, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, created_at)
+			VALUES (?, ?, 'message', ?, ?)
+		`, conversationID, firstOrdinal+int64(idx), messageID, msg.CreatedAt); err != nil {
+			return BackfillResult{}, fmt.Errorf("insert context item seq=%d: %w", seq, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO messages_fts (rowid, content)
+			VALUES (?, ?)
+		`, messageID, msg.Content); err != nil {
+			return BackfillResult{}, fmt.Errorf("insert messages_fts row for message %d: %w", messageID, err)
+		}
+
+		partID, err := NewMessagePartID()
+		if err != nil {
+			return BackfillResult{}, err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content)
+			VALUES (?, ?, ?, 'text', 0, ?)
+		`, partID, messageID, input.SessionID, msg.Content); err != nil {
+			return BackfillResult{}, fmt.Errorf("insert message_part for message %d: %w", messageID, err)
+		}
+	}
+	firstSeq, lastSeq := 0, len(messages)-1
+	detail := fmt.Sprintf("imported %d messages from session %s", len(messages), input.SessionID)
+	if input.SeqRange.Set {
+		firstSeq, lastSeq = messages[0].Seq, messages[len(messages)-1].Seq
+		detail = fmt.Sprintf("imported seq %d-%d (%d messages) from session %s", firstSeq, lastSeq, len(messages), input.SessionID)
+	}
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		Command: "backfill", ConversationID: conversationID,
+		Detail: detail,
+	}); err != nil {
+		return BackfillResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return BackfillResult{}, fmt.Errorf("commit backfill import transaction: %w", err)
+	}
+	rollback = false
+
+	return BackfillResult{
+		ConversationID: conversationID,
+		Imported:       true,
+		MessageCount:   len(messages),
+		FirstSeq:       firstSeq,
+		LastSeq:        lastSeq,
+		TotalMessages:  plan.MessageCount + len(messages),
+		HighestSeq:     lastSeq,
+	}, nil
+}
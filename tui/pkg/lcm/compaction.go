@@ -0,0 +1,1058 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CondensedTargetTokens is the default output target for condensed summaries.
+const CondensedTargetTokens = 2000
+
+// CompactionOptions configures RunCompaction. Zero token targets fall back to
+// LeafTargetTokens and CondensedTargetTokens.
+type CompactionOptions struct {
+	LeafChunkTokens       int // token budget of the messages folded into one leaf
+	LeafTargetTokens      int
+	CondensedTargetTokens int
+	LeafFanout            int // d0 summaries needed before they condense
+	CondensedFanout       int // dN summaries needed before they condense
+	HardFanout            int // fanout for the SingleRoot fold
+	FreshTailCount        int // newest messages left out of compaction
+	// SingleRoot keeps folding until one summary is left.
+	SingleRoot bool
+	// SmartChunk ends leaf chunks at user turns or time gaps.
+	SmartChunk bool
+	// PromptDir overrides the built-in prompt templates; see RenderPrompt.
+	PromptDir string
+	// PrevContext selects prior summaries placed in each leaf and d1
+	// prompt. Depth -1 means any depth for leaves and the condensed depth
+	// for d1.
+	PrevContext PreviousContextOptions
+	// Redactor, when set, replaces secrets in summary sources.
+	Redactor Redactor
+	// Log receives progress notes and warnings; nil discards them.
+	Log io.Writer
+}
+
+func (o CompactionOptions) log() io.Writer {
+	if o.Log == nil {
+		return io.Discard
+	}
+	return o.Log
+}
+
+// CompactionStats counts the passes RunCompaction made.
+type CompactionStats struct {
+	LeafPasses      int
+	CondensedPasses int
+	RootFoldPasses  int
+	Redactions      RedactionCounts // secrets replaced in leaf and condensed sources
+}
+
+// LeafTargetTokens is the default leaf output target for inputTokens of
+// source: 35%, clamped to 192..1200.
+func LeafTargetTokens(inputTokens int) int {
+	target := int(math.Floor(float64(inputTokens) * 0.35))
+	if target < 192 {
+		return 192
+	}
+	if target > 1200 {
+		return 1200
+	}
+	return target
+}
+
+// RunCompaction folds conversationID's context the way the plugin does:
+// leaf passes over raw messages outside the fresh tail, then condensed passes
+// over runs of same-depth summaries, committing each pass on its own. With
+// opts.SingleRoot it then forces folds until one summary remains.
+func RunCompaction(ctx context.Context, db TxBeginner, conversationID int64, opts CompactionOptions, summarize Summarizer) (CompactionStats, error) {
+	stats := CompactionStats{Redactions: RedactionCounts{}}
+	if err := warnInterleavedContext(ctx, db, conversationID, opts.log()); err != nil {
+		return stats, err
+	}
+
+	for {
+		items, err := LoadContextItems(ctx, db, conversationID)
+		if err != nil {
+			return stats, err
+		}
+
+		leafChunk := SelectLeafChunk(items, opts.LeafChunkTokens, opts.FreshTailCount)
+		if opts.SmartChunk {
+			leafChunk = AlignLeafChunk(items, leafChunk, opts.LeafChunkTokens, opts.FreshTailCount)
+		}
+		if len(leafChunk) > 0 {
+			if err := applyLeafPass(ctx, db, conversationID, leafChunk, opts, summarize, stats.Redactions); err != nil {
+				return stats, err
+			}
+			stats.LeafPasses++
+			continue
+		}
+
+		candidate, ok := selectCondensedCandidate(items, opts, false)
+		if ok {
+			if err := applyCondensedPass(ctx, db, conversationID, candidate, opts, summarize, stats.Redactions); err != nil {
+				return stats, err
+			}
+			stats.CondensedPasses++
+			continue
+		}
+
+		break
+	}
+
+	if opts.SingleRoot {
+		for {
+			items, err := LoadContextItems(ctx, db, conversationID)
+			if err != nil {
+				return stats, err
+			}
+			if !canForceSingleRoot(items) {
+				break
+			}
+			candidate, ok := selectCondensedCandidate(items, opts, true)
+			if !ok {
+				break
+			}
+			if err := applyCondensedPass(ctx, db, conversationID, candidate, opts, summarize, stats.Redactions); err != nil {
+				return stats, err
+			}
+			stats.RootFoldPasses++
+		}
+	}
+
+	return stats, nil
+}
+
+// SelectLeafChunk returns the oldest run of raw messages outside the fresh
+// tail, up to chunkTokens. It always takes at least one message.
+func SelectLeafChunk(items []ContextItem, chunkTokens, freshTail int) []ContextItem {
+	if len(items) == 0 {
+		return nil
+	}
+	if chunkTokens <= 0 {
+		chunkTokens = 1
+	}
+	freshTailOrdinal := FreshTailOrdinal(items, freshTail)
+	chunk := make([]ContextItem, 0, 32)
+	tokens := 0
+	started := false
+	for _, item := range items {
+		if item.Ordinal >= freshTailOrdinal {
+			break
+		}
+		if !started {
+			if item.ItemType != "message" || !item.MessageID.Valid {
+				continue
+			}
+			started = true
+		} else if item.ItemType != "message" || !item.MessageID.Valid {
+			break
+		}
+
+		messageTokens := leafItemTokens(item)
+		if len(chunk) > 0 && tokens+messageTokens > chunkTokens {
+			break
+		}
+		chunk = append(chunk, item)
+		tokens += messageTokens
+		if tokens >= chunkTokens {
+			break
+		}
+	}
+	return chunk
+}
+
+// FreshTailOrdinal returns the ordinal of the first of the freshTail newest
+// messages, or the largest int64 when there is no fresh tail.
+func FreshTailOrdinal(items []ContextItem, freshTail int) int64 {
+	if freshTail <= 0 {
+		return int64(^uint64(0) >> 1)
+	}
+	messageItems := make([]ContextItem, 0, len(items))
+	for _, item := range items {
+		if item.ItemType == "message" && item.MessageID.Valid {
+			messageItems = append(messageItems, item)
+		}
+	}
+	if len(messageItems) == 0 {
+		return int64(^uint64(0) >> 1)
+	}
+	tailStart := len(messageItems) - freshTail
+	if tailStart < 0 {
+		tailStart = 0
+	}
+	return messageItems[tailStart].Ordinal
+}
+
+type condensedCandidate struct {
+	targetDepth int
+	chunk       []ContextItem
+}
+
+func selectCondensedCandidate(items []ContextItem, opts CompactionOptions, hard bool) (condensedCandidate, bool) {
+	freshTailOrdinal := FreshTailOrdinal(items, opts.FreshTailCount)
+	depthSet := make(map[int]bool)
+	for _, item := range items {
+		if item.Ordinal >= freshTailOrdinal {
+			break
+		}
+		if item.ItemType == "summary" && item.SummaryID.Valid {
+			depthSet[item.Depth] = true
+		}
+	}
+	if len(depthSet) == 0 {
+		return condensedCandidate{}, false
+	}
+	depths := make([]int, 0, len(depthSet))
+	for depth := range depthSet {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+
+	minChunkTokens := opts.CondensedTargetTokens
+	floor := opts.LeafChunkTokens / 2
+	if floor > minChunkTokens {
+		minChunkTokens = floor
+	}
+	if hard {
+		minChunkTokens = 0
+	}
+
+	for _, depth := range depths {
+		chunk, tokenSum := SelectChunkAtDepth(items, depth, opts.LeafChunkTokens, freshTailOrdinal)
+		if len(chunk) == 0 {
+			continue
+		}
+		fanout := opts.CondensedFanout
+		if depth == 0 {
+			fanout = opts.LeafFanout
+		}
+		if hard {
+			fanout = opts.HardFanout
+		}
+		if len(chunk) < fanout {
+			continue
+		}
+		if tokenSum < minChunkTokens {
+			continue
+		}
+		return condensedCandidate{targetDepth: depth, chunk: chunk}, true
+	}
+	return condensedCandidate{}, false
+}
+
+// SelectChunkAtDepth returns the first contiguous run of depth summaries
+// before freshTailOrdinal, up to chunkTokenBudget, and its token sum.
+func SelectChunkAtDepth(items []ContextItem, depth, chunkTokenBudget int, freshTailOrdinal int64) ([]ContextItem, int) {
+	if chunkTokenBudget <= 0 {
+		chunkTokenBudget = 1
+	}
+	chunk := make([]ContextItem, 0, 16)
+	tokenSum := 0
+	for _, item := range items {
+		if item.Ordinal >= freshTailOrdinal {
+			break
+		}
+		if item.ItemType != "summary" || !item.SummaryID.Valid {
+			if len(chunk) > 0 {
+				break
+			}
+			continue
+		}
+		if item.Depth != depth {
+			if len(chunk) > 0 {
+				break
+			}
+			continue
+		}
+		tokens := item.TokenCount
+		if tokens <= 0 {
+			tokens = 1
+		}
+		if len(chunk) > 0 && tokenSum+tokens > chunkTokenBudget {
+			break
+		}
+		chunk = append(chunk, item)
+		tokenSum += tokens
+		if tokenSum >= chunkTokenBudget {
+			break
+		}
+	}
+	return chunk, tokenSum
+}
+
+func canForceSingleRoot(items []ContextItem) bool {
+	summaryCount := 0
+	for _, item := range items {
+		if item.ItemType == "message" && item.MessageID.Valid {
+			return false
+		}
+		if item.ItemType == "summary" && item.SummaryID.Valid {
+			summaryCount++
+		}
+	}
+	return summaryCount > 1
+}
+
+// smartChunkTolerance is how far under --leaf-chunk-tokens --smart-chunk may
+// end a leaf chunk to cut at a natural boundary: a chunk keeps at least
+// 75% of the budget it would otherwise fill.
+const smartChunkTolerance = 0.25
+
+// smartChunkTimeGap is the pause between two messages that --smart-chunk
+// treats as a topic boundary.
+const smartChunkTimeGap = 30 * time.Minute
+
+// AlignLeafChunk moves the end of a budget-limited leaf chunk back to
+// the latest natural boundary within smartChunkTolerance of chunkTokens, so
+// a summary does not stop mid-exchange. A long gap in message timestamps is
+// preferred over a user-turn start. Chunks that already end at a boundary,
+// or that stop before the budget (fresh tail, a summary, the end of
+// context), are returned unchanged, and at least one message is kept.
+func AlignLeafChunk(items, chunk []ContextItem, chunkTokens, freshTail int) []ContextItem {
+	if len(chunk) < 2 {
+		return chunk
+	}
+	next, ok := nextLeafChunkItem(items, chunk, freshTail)
+	if !ok || smartChunkBoundary(chunk[len(chunk)-1], next) != smartChunkNoBoundary {
+		return chunk
+	}
+
+	minTokens := int(float64(chunkTokens) * (1 - smartChunkTolerance))
+	cut, cutKind := 0, smartChunkNoBoundary
+	tokens := 0
+	for i := 1; i < len(chunk); i++ {
+		tokens += leafItemTokens(chunk[i-1])
+		if tokens < minTokens {
+			continue
+		}
+		if kind := smartChunkBoundary(chunk[i-1], chunk[i]); kind >= cutKind && kind != smartChunkNoBoundary {
+			cut, cutKind = i, kind
+		}
+	}
+	if cut == 0 {
+		return chunk
+	}
+	return chunk[:cut]
+}
+
+type smartChunkBoundaryKind int
+
+const (
+	smartChunkNoBoundary smartChunkBoundaryKind = iota
+	smartChunkUserTurn
+	smartChunkTimeGapBoundary
+)
+
+// smartChunkBoundary reports whether a chunk may end between prev and next.
+func smartChunkBoundary(prev, next ContextItem) smartChunkBoundaryKind {
+	prevAt, prevOK := ParseContextItemTime(prev.CreatedAt)
+	nextAt, nextOK := ParseContextItemTime(next.CreatedAt)
+	if prevOK && nextOK && nextAt.Sub(prevAt) >= smartChunkTimeGap {
+		return smartChunkTimeGapBoundary
+	}
+	if next.Role == "user" {
+		return smartChunkUserTurn
+	}
+	return smartChunkNoBoundary
+}
+
+// nextLeafChunkItem returns the message that would have extended
+// chunk had the token budget allowed it; ok is false when the chunk stopped
+// for another reason.
+func nextLeafChunkItem(items, chunk []ContextItem, freshTail int) (ContextItem, bool) {
+	freshTailOrdinal := FreshTailOrdinal(items, freshTail)
+	last := chunk[len(chunk)-1].Ordinal
+	for i, item := range items {
+		if item.Ordinal != last || i+1 >= len(items) {
+			continue
+		}
+		next := items[i+1]
+		if next.Ordinal >= freshTailOrdinal || next.ItemType != "message" || !next.MessageID.Valid {
+			return ContextItem{}, false
+		}
+		return next, true
+	}
+	return ContextItem{}, false
+}
+
+// leafItemTokens is a message's weight against the leaf chunk
+// budget; unknown counts weigh one token, as in SelectLeafChunk.
+func leafItemTokens(item ContextItem) int {
+	if item.TokenCount <= 0 {
+		return 1
+	}
+	return item.TokenCount
+}
+
+// applyLeafPass summarizes chunk into one leaf summary. Secrets that
+// opts.Redactor replaces in the source are added to redactions.
+func applyLeafPass(ctx context.Context, db TxBeginner, conversationID int64, chunk []ContextItem, opts CompactionOptions, summarize Summarizer, redactions RedactionCounts) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+
+	messages, err := LoadChunkMessages(ctx, db, chunk)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	loc := time.Local
+	sourceParts := make([]string, 0, len(messages))
+	earliest := ""
+	latest := ""
+	for _, message := range messages {
+		ts := FormatTimestamp(message.CreatedAt, loc)
+		if ts != "" {
+			sourceParts = append(sourceParts, "["+ts+"]\n"+message.Content)
+		} else {
+			sourceParts = append(sourceParts, message.Content)
+		}
+		if earliest == "" || message.CreatedAt < earliest {
+			earliest = message.CreatedAt
+		}
+		if latest == "" || message.CreatedAt > latest {
+			latest = message.CreatedAt
+		}
+	}
+
+	previous, err := PriorSummaries(ctx, db, conversationID, chunk[0].Ordinal, opts.PrevContext.Depth, opts.PrevContext.Count)
+	if err != nil {
+		return err
+	}
+	previousContext, counts := redact(opts.Redactor, JoinPreviousSummaries(previous))
+	redactions.Add(counts)
+	sourceText, counts := redact(opts.Redactor, strings.Join(sourceParts, "\n\n"))
+	redactions.Add(counts)
+	// SelectLeafChunk always takes at least one message, so a single
+	// message over the chunk budget arrives here alone. Summarize it in
+	// segments first rather than sending one prompt the model may not accept.
+	segmentNote := ""
+	if sourceTokens := EstimateTokenCount(sourceText); len(messages) == 1 && opts.LeafChunkTokens > 0 && sourceTokens > opts.LeafChunkTokens {
+		reduced, calls, err := reduceOversizedSource(ctx, sourceText, opts, summarize)
+		if err != nil {
+			return fmt.Errorf("message %d is %d tokens, over --leaf-chunk-tokens %d: %w", messages[0].MessageID, sourceTokens, opts.LeafChunkTokens, err)
+		}
+		fmt.Fprintf(opts.log(), "Message %d is %d tokens, over --leaf-chunk-tokens %d: summarized in %d segment calls first.\n",
+			messages[0].MessageID, sourceTokens, opts.LeafChunkTokens, calls)
+		sourceText = reduced
+		segmentNote = fmt.Sprintf(", oversized message summarized in %d segment calls", calls)
+	}
+	targetTokens := opts.LeafTargetTokens
+	if targetTokens <= 0 {
+		targetTokens = LeafTargetTokens(EstimateTokenCount(sourceText))
+	}
+	prompt, err := RenderPrompt(0, PromptVars{
+		TargetTokens:    targetTokens,
+		PreviousContext: previousContext,
+		ChildCount:      len(messages),
+		TimeRange:       FormatTimeRange(FormatTimestamp(earliest, loc), FormatTimestamp(latest, loc)),
+		Depth:           0,
+		SourceText:      sourceText,
+	}, opts.PromptDir)
+	if err != nil {
+		return fmt.Errorf("render leaf prompt: %w", err)
+	}
+
+	newContent, err := summarize.Summarize(ctx, prompt, targetTokens)
+	if err != nil {
+		return fmt.Errorf("summarize leaf chunk: %w", err)
+	}
+	newContent = strings.TrimSpace(newContent)
+	if newContent == "" {
+		return errors.New("leaf summarization returned empty content")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin leaf compaction transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	summaryID, err := GenerateSummaryID(ctx, tx)
+	if err != nil {
+		return err
+	}
+	summaryCreatedAt := messages[len(messages)-1].CreatedAt
+	caps, err := SchemaCapabilitiesOf(ctx, tx)
+	if err != nil {
+		return err
+	}
+	seqColumn, seqValue := caps.NextSummarySeq()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth`+seqColumn+`)
+		VALUES (?, ?, 'leaf', ?, ?, ?, '[]', 0`+seqValue+`)
+	`, summaryID, conversationID, newContent, EstimateTokenCount(newContent), summaryCreatedAt); err != nil {
+		return fmt.Errorf("insert leaf summary %s: %w", summaryID, err)
+	}
+
+	for i, msg := range messages {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO summary_messages (summary_id, message_id, ordinal)
+			VALUES (?, ?, ?)
+		`, summaryID, msg.MessageID, i); err != nil {
+			return fmt.Errorf("insert summary_message for %s: %w", summaryID, err)
+		}
+	}
+
+	startOrdinal := chunk[0].Ordinal
+	endOrdinal := chunk[len(chunk)-1].Ordinal
+	if err := replaceContextRangeWithSummary(ctx, tx, conversationID, startOrdinal, endOrdinal, summaryID); err != nil {
+		return err
+	}
+	chunkTokens := 0
+	for _, item := range chunk {
+		chunkTokens += item.TokenCount
+	}
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		Command: "compact", ConversationID: conversationID, SummaryIDs: []string{summaryID},
+		TokensBefore: chunkTokens, TokensAfter: EstimateTokenCount(newContent),
+		Detail: fmt.Sprintf("leaf summary of %d messages (ordinals %d-%d%s), previous context %s", len(messages), startOrdinal, endOrdinal, segmentNote, DescribePreviousContext(previous, opts.PrevContext)),
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit leaf compaction transaction: %w", err)
+	}
+	rollback = false
+	return nil
+}
+
+// maxReduceRounds bounds how many times the partial summaries of an
+// oversized message are summarized again to fit the leaf chunk budget.
+const maxReduceRounds = 4
+
+// SplitSourceSegments splits text into segments of at most
+// maxTokens estimated tokens. It cuts at blank lines first, then at line
+// breaks, and splits a single line only when it is over the limit by itself.
+func SplitSourceSegments(text string, maxTokens int) []string {
+	if maxTokens <= 0 || EstimateTokenCount(text) <= maxTokens {
+		return []string{text}
+	}
+	var segments []string
+	var current strings.Builder
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			segments = append(segments, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+	}
+	add := func(piece, sep string) {
+		if current.Len() > 0 && EstimateTokenCount(current.String()+sep+piece) > maxTokens {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if EstimateTokenCount(paragraph) <= maxTokens {
+			add(paragraph, "\n\n")
+			continue
+		}
+		for _, line := range strings.Split(paragraph, "\n") {
+			if EstimateTokenCount(line) <= maxTokens {
+				add(line, "\n")
+				continue
+			}
+			flush()
+			segments = append(segments, splitSourceLine(line, maxTokens)...)
+		}
+		flush()
+	}
+	flush()
+	return segments
+}
+
+// splitSourceLine cuts one over-budget line into rune-safe pieces, at the
+// last space before the limit when there is one.
+func splitSourceLine(line string, maxTokens int) []string {
+	runes := []rune(line)
+	// EstimateTokenCount is about four characters per token.
+	limit := maxTokens * 4
+	if limit < 1 {
+		limit = 1
+	}
+	var pieces []string
+	for len(runes) > limit {
+		cut := limit
+		if space := strings.LastIndex(string(runes[:limit]), " "); space > limit/2 {
+			cut = len([]rune(string(runes[:limit])[:space]))
+		}
+		pieces = append(pieces, strings.TrimSpace(string(runes[:cut])))
+		runes = runes[cut:]
+	}
+	if rest := strings.TrimSpace(string(runes)); rest != "" {
+		pieces = append(pieces, rest)
+	}
+	return pieces
+}
+
+// reduceOversizedSource handles a leaf source larger than the chunk
+// budget, which happens when a single message exceeds --leaf-chunk-tokens.
+// The source is split into segments, each segment is summarized with the
+// previous segment's summary as context (map), and the labeled partial
+// summaries are joined (reduce). The joined text is split and summarized
+// again while it still exceeds the budget. It returns the text to use as the
+// leaf source and the number of summarize calls made.
+func reduceOversizedSource(ctx context.Context, sourceText string, opts CompactionOptions, summarize Summarizer) (string, int, error) {
+	calls := 0
+	for round := 0; round < maxReduceRounds; round++ {
+		segments := SplitSourceSegments(sourceText, opts.LeafChunkTokens)
+		if len(segments) <= 1 {
+			return sourceText, calls, nil
+		}
+		partials := make([]string, 0, len(segments))
+		previous := ""
+		for i, segment := range segments {
+			targetTokens := opts.LeafTargetTokens
+			if targetTokens <= 0 {
+				targetTokens = LeafTargetTokens(EstimateTokenCount(segment))
+			}
+			prompt, err := RenderPrompt(0, PromptVars{
+				TargetTokens:    targetTokens,
+				PreviousContext: previous,
+				ChildCount:      1,
+				Depth:           0,
+				SourceText:      segment,
+			}, opts.PromptDir)
+			if err != nil {
+				return "", calls, fmt.Errorf("render segment prompt: %w", err)
+			}
+			summary, err := summarize.Summarize(ctx, prompt, targetTokens)
+			calls++
+			if err != nil {
+				return "", calls, fmt.Errorf("summarize segment %d of %d: %w", i+1, len(segments), err)
+			}
+			summary = strings.TrimSpace(summary)
+			if summary == "" {
+				return "", calls, fmt.Errorf("segment %d of %d summarized to empty content", i+1, len(segments))
+			}
+			partials = append(partials, fmt.Sprintf("[Part %d of %d]\n%s", i+1, len(segments), summary))
+			previous = summary
+		}
+		reduced := strings.Join(partials, "\n\n")
+		if EstimateTokenCount(reduced) >= EstimateTokenCount(sourceText) {
+			return "", calls, errors.New("partial summaries are no smaller than the source; lower --leaf-target-tokens or raise --leaf-chunk-tokens")
+		}
+		sourceText = reduced
+	}
+	if EstimateTokenCount(sourceText) > opts.LeafChunkTokens {
+		return "", calls, fmt.Errorf("still over --leaf-chunk-tokens %d after %d reduce rounds", opts.LeafChunkTokens, maxReduceRounds)
+	}
+	return sourceText, calls, nil
+}
+
+// PriorSummaries returns up to take summaries in the context
+// before beforeOrdinal, oldest first. A negative depthFilter accepts any
+// depth.
+func PriorSummaries(ctx context.Context, q Queryer, conversationID int64, beforeOrdinal int64, depthFilter int, take int) ([]PreviousSummary, error) {
+	if take <= 0 {
+		return nil, nil
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT ci.summary_id, s.content
+		FROM context_items ci
+		JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ?
+		  AND ci.item_type = 'summary'
+		  AND ci.ordinal < ?
+		  AND (? < 0 OR COALESCE(s.depth, 0) = ?)
+		  AND TRIM(COALESCE(s.content, '')) != ''
+		ORDER BY ci.ordinal DESC
+		LIMIT ?
+	`, conversationID, beforeOrdinal, depthFilter, depthFilter, take)
+	if err != nil {
+		return nil, fmt.Errorf("query prior summary context: %w", err)
+	}
+	defer rows.Close()
+
+	parts := make([]PreviousSummary, 0, take)
+	for rows.Next() {
+		var summary PreviousSummary
+		if err := rows.Scan(&summary.SummaryID, &summary.Content); err != nil {
+			return nil, fmt.Errorf("scan prior summary context row: %w", err)
+		}
+		summary.Content = strings.TrimSpace(summary.Content)
+		parts = append(parts, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate prior summary context rows: %w", err)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return parts, nil
+}
+
+// applyCondensedPass folds candidate's summaries into one condensed
+// summary. Secrets that opts.Redactor replaces are added to redactions.
+func applyCondensedPass(ctx context.Context, db TxBeginner, conversationID int64, candidate condensedCandidate, opts CompactionOptions, summarize Summarizer, redactions RedactionCounts) error {
+	if len(candidate.chunk) == 0 {
+		return nil
+	}
+
+	summaries, err := LoadChunkSummaries(ctx, db, candidate.chunk)
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	loc := time.Local
+	sourceParts := make([]string, 0, len(summaries))
+	earliest := ""
+	latest := ""
+	totalDescendants := 0
+	for _, summary := range summaries {
+		rangeText := FormatTimeRange(FormatTimestamp(summary.EarliestAt, loc), FormatTimestamp(summary.LatestAt, loc))
+		if rangeText == "" {
+			rangeText = FormatTimestamp(summary.CreatedAt, loc)
+		}
+		if rangeText != "" {
+			sourceParts = append(sourceParts, "["+rangeText+"]\n"+summary.Content)
+		} else {
+			sourceParts = append(sourceParts, summary.Content)
+		}
+
+		start := summary.EarliestAt
+		if strings.TrimSpace(start) == "" {
+			start = summary.CreatedAt
+		}
+		end := summary.LatestAt
+		if strings.TrimSpace(end) == "" {
+			end = summary.CreatedAt
+		}
+		if earliest == "" || (start != "" && start < earliest) {
+			earliest = start
+		}
+		if latest == "" || (end != "" && end > latest) {
+			latest = end
+		}
+
+		if summary.Descendants < 0 {
+			summary.Descendants = 0
+		}
+		totalDescendants += summary.Descendants + 1
+	}
+
+	// Only d1 prompts take previous context; deeper folds cover enough
+	// history on their own.
+	var previous []PreviousSummary
+	previousNote := ""
+	if candidate.targetDepth == 0 {
+		depthFilter := candidate.targetDepth
+		if opts.PrevContext.Depth >= 0 {
+			depthFilter = opts.PrevContext.Depth
+		}
+		previous, err = PriorSummaries(ctx, db, conversationID, candidate.chunk[0].Ordinal, depthFilter, opts.PrevContext.Count)
+		if err != nil {
+			return err
+		}
+		previousNote = ", previous context " + DescribePreviousContext(previous, opts.PrevContext)
+	}
+	previousContext, counts := redact(opts.Redactor, JoinPreviousSummaries(previous))
+	redactions.Add(counts)
+
+	targetTokens := opts.CondensedTargetTokens
+	if targetTokens <= 0 {
+		targetTokens = CondensedTargetTokens
+	}
+	sourceText, counts := redact(opts.Redactor, strings.Join(sourceParts, "\n\n"))
+	redactions.Add(counts)
+	prompt, err := RenderPrompt(candidate.targetDepth+1, PromptVars{
+		TargetTokens:    targetTokens,
+		PreviousContext: previousContext,
+		ChildCount:      len(summaries),
+		TimeRange:       FormatTimeRange(FormatTimestamp(earliest, loc), FormatTimestamp(latest, loc)),
+		Depth:           candidate.targetDepth + 1,
+		SourceText:      sourceText,
+	}, opts.PromptDir)
+	if err != nil {
+		return fmt.Errorf("render condensed prompt: %w", err)
+	}
+
+	newContent, err := summarize.Summarize(ctx, prompt, targetTokens)
+	if err != nil {
+		return fmt.Errorf("summarize condensed chunk: %w", err)
+	}
+	newContent = strings.TrimSpace(newContent)
+	if newContent == "" {
+		return errors.New("condensed summarization returned empty content")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin condensed compaction transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	summaryID, err := GenerateSummaryID(ctx, tx)
+	if err != nil {
+		return err
+	}
+	summaryCreatedAt := latest
+	if strings.TrimSpace(summaryCreatedAt) == "" {
+		summaryCreatedAt = time.Now().UTC().Format("2006-01-02 15:04:05")
+	}
+	caps, err := SchemaCapabilitiesOf(ctx, tx)
+	if err != nil {
+		return err
+	}
+	columns := "summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth"
+	values := "?, ?, 'condensed', ?, ?, ?, '[]', ?"
+	args := []any{summaryID, conversationID, newContent, EstimateTokenCount(newContent), summaryCreatedAt, candidate.targetDepth + 1}
+	// DBs that have not yet migrated the metadata columns get the core row.
+	if caps.HasColumn("summaries", "earliest_at") && caps.HasColumn("summaries", "latest_at") && caps.HasColumn("summaries", "descendant_count") {
+		columns += ", earliest_at, latest_at, descendant_count"
+		values += ", ?, ?, ?"
+		args = append(args, earliest, latest, totalDescendants)
+	}
+	seqColumn, seqValue := caps.NextSummarySeq()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO summaries (`+columns+seqColumn+`)
+		VALUES (`+values+seqValue+`)
+	`, args...); err != nil {
+		return fmt.Errorf("insert condensed summary %s: %w", summaryID, err)
+	}
+
+	for i, summary := range summaries {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+			VALUES (?, ?, ?)
+		`, summaryID, summary.SummaryID, i); err != nil {
+			return fmt.Errorf("insert summary_parent %s -> %s: %w", summaryID, summary.SummaryID, err)
+		}
+	}
+
+	startOrdinal := candidate.chunk[0].Ordinal
+	endOrdinal := candidate.chunk[len(candidate.chunk)-1].Ordinal
+	if err := replaceContextRangeWithSummary(ctx, tx, conversationID, startOrdinal, endOrdinal, summaryID); err != nil {
+		return err
+	}
+	summaryIDs := []string{summaryID}
+	childTokens := 0
+	for _, summary := range summaries {
+		summaryIDs = append(summaryIDs, summary.SummaryID)
+		childTokens += summary.TokenCount
+	}
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		Command: "compact", ConversationID: conversationID, SummaryIDs: summaryIDs,
+		TokensBefore: childTokens, TokensAfter: EstimateTokenCount(newContent),
+		Detail: fmt.Sprintf("d%d summary of %d d%d summaries (ordinals %d-%d)%s", candidate.targetDepth+1, len(summaries), candidate.targetDepth, startOrdinal, endOrdinal, previousNote),
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit condensed compaction transaction: %w", err)
+	}
+	rollback = false
+	return nil
+}
+
+func replaceContextRangeWithSummary(ctx context.Context, q Queryer, conversationID, startOrdinal, endOrdinal int64, summaryID string) error {
+	if _, err := q.ExecContext(ctx, `
+		DELETE FROM context_items
+		WHERE conversation_id = ?
+		  AND ordinal >= ?
+		  AND ordinal <= ?
+	`, conversationID, startOrdinal, endOrdinal); err != nil {
+		return fmt.Errorf("delete context range [%d,%d] for conversation %d: %w", startOrdinal, endOrdinal, conversationID, err)
+	}
+
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id, created_at)
+		VALUES (?, ?, 'summary', ?, datetime('now'))
+	`, conversationID, startOrdinal, summaryID); err != nil {
+		return fmt.Errorf("insert replacement summary %s at ordinal %d: %w", summaryID, startOrdinal, err)
+	}
+
+	return ResequenceContextOrdinals(ctx, q, conversationID)
+}
+
+// ChunkMessage is a message loaded for a leaf pass.
+type ChunkMessage struct {
+	MessageID int64
+	Content   string
+	CreatedAt string
+}
+
+// ChunkSummary is a summary loaded for a condensed pass.
+type ChunkSummary struct {
+	SummaryID   string
+	Content     string
+	TokenCount  int
+	Depth       int
+	Kind        string
+	CreatedAt   string
+	EarliestAt  string
+	LatestAt    string
+	Descendants int
+}
+
+// ChunkLoadBatchSize caps the IN (...) list of the chunk loaders, well
+// under SQLite's default host parameter limit.
+const ChunkLoadBatchSize = 200
+
+// LoadChunkMessages loads the chunk's messages with batched
+// IN queries and returns them in chunk order.
+func LoadChunkMessages(ctx context.Context, q Queryer, chunk []ContextItem) ([]ChunkMessage, error) {
+	ids := make([]int64, 0, len(chunk))
+	for _, item := range chunk {
+		if item.MessageID.Valid {
+			ids = append(ids, item.MessageID.Int64)
+		}
+	}
+
+	byID := make(map[int64]ChunkMessage, len(ids))
+	for start := 0; start < len(ids); start += ChunkLoadBatchSize {
+		batch := ids[start:min(start+ChunkLoadBatchSize, len(ids))]
+		args := make([]any, 0, len(batch))
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		rows, err := q.QueryContext(ctx, `
+			SELECT message_id, COALESCE(content, ''), COALESCE(created_at, '')
+			FROM messages
+			WHERE message_id IN (`+SQLPlaceholders(len(batch))+`)
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("load messages for backfill chunk: %w", err)
+		}
+		for rows.Next() {
+			var row ChunkMessage
+			if err := rows.Scan(&row.MessageID, &row.Content, &row.CreatedAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan message for backfill chunk: %w", err)
+			}
+			byID[row.MessageID] = row
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("iterate messages for backfill chunk: %w", err)
+		}
+		rows.Close()
+	}
+
+	messages := make([]ChunkMessage, 0, len(ids))
+	for _, id := range ids {
+		row, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("load message %d for backfill chunk: %w", id, sql.ErrNoRows)
+		}
+		messages = append(messages, row)
+	}
+	return messages, nil
+}
+
+// LoadChunkSummaries loads the chunk's summaries with batched IN
+// queries and returns them in chunk order. Databases that predate the
+// earliest_at/latest_at/descendant_count columns load the core columns only.
+func LoadChunkSummaries(ctx context.Context, q Queryer, chunk []ContextItem) ([]ChunkSummary, error) {
+	ids := make([]string, 0, len(chunk))
+	for _, item := range chunk {
+		if item.SummaryID.Valid {
+			ids = append(ids, item.SummaryID.String)
+		}
+	}
+
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	withMetadata := caps.HasColumn("summaries", "earliest_at") &&
+		caps.HasColumn("summaries", "latest_at") &&
+		caps.HasColumn("summaries", "descendant_count")
+	byID, err := queryChunkSummaryBatches(ctx, q, ids, withMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]ChunkSummary, 0, len(ids))
+	for _, id := range ids {
+		row, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("load summary %s for condensed pass: %w", id, sql.ErrNoRows)
+		}
+		summaries = append(summaries, row)
+	}
+	return summaries, nil
+}
+
+func queryChunkSummaryBatches(ctx context.Context, q Queryer, ids []string, withMetadata bool) (map[string]ChunkSummary, error) {
+	metadataColumns := ""
+	if withMetadata {
+		metadataColumns = `,
+				COALESCE(earliest_at, ''),
+				COALESCE(latest_at, ''),
+				COALESCE(descendant_count, 0)`
+	}
+
+	byID := make(map[string]ChunkSummary, len(ids))
+	for start := 0; start < len(ids); start += ChunkLoadBatchSize {
+		batch := ids[start:min(start+ChunkLoadBatchSize, len(ids))]
+		args := make([]any, 0, len(batch))
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		rows, err := q.QueryContext(ctx, `
+			SELECT
+				summary_id,
+				COALESCE(content, ''),
+				COALESCE(token_count, 0),
+				COALESCE(depth, 0),
+				COALESCE(kind, 'leaf'),
+				COALESCE(created_at, '')`+metadataColumns+`
+			FROM summaries
+			WHERE summary_id IN (`+SQLPlaceholders(len(batch))+`)
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("load summaries for condensed pass: %w", err)
+		}
+		for rows.Next() {
+			var row ChunkSummary
+			dest := []any{&row.SummaryID, &row.Content, &row.TokenCount, &row.Depth, &row.Kind, &row.CreatedAt}
+			if withMetadata {
+				dest = append(dest, &row.EarliestAt, &row.LatestAt, &row.Descendants)
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan summary for condensed pass: %w", err)
+			}
+			byID[row.SummaryID] = row
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("iterate summaries for condensed pass: %w", err)
+		}
+		rows.Close()
+	}
+	return byID, nil
+}
@@ -0,0 +1,214 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ContextItem is one context_items row with the token count, depth, role,
+// and time of the message or summary it points at.
+type ContextItem struct {
+	Ordinal    int64
+	ItemType   string
+	MessageID  sql.NullInt64
+	SummaryID  sql.NullString
+	TokenCount int
+	Depth      int
+	Role       string // message role; "" for summaries
+	CreatedAt  string // message created_at; "" for summaries
+}
+
+// LoadContextItems returns conversationID's context in ordinal order.
+func LoadContextItems(ctx context.Context, q Queryer, conversationID int64) ([]ContextItem, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			ci.ordinal,
+			ci.item_type,
+			ci.message_id,
+			ci.summary_id,
+			COALESCE(m.token_count, s.token_count, 0) AS token_count,
+			COALESCE(s.depth, 0) AS depth,
+			COALESCE(m.role, '') AS role,
+			COALESCE(m.created_at, '') AS created_at
+		FROM context_items ci
+		LEFT JOIN messages m ON m.message_id = ci.message_id
+		LEFT JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ?
+		ORDER BY ci.ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query context items for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	items := make([]ContextItem, 0, 256)
+	for rows.Next() {
+		var item ContextItem
+		if err := rows.Scan(&item.Ordinal, &item.ItemType, &item.MessageID, &item.SummaryID, &item.TokenCount, &item.Depth, &item.Role, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan context item row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate context items: %w", err)
+	}
+	return items, nil
+}
+
+// ResequenceContextOrdinals renumbers a conversation's context_items to
+// 0..N-1 in their current order, staging through negative ordinals so the
+// (conversation_id, ordinal) primary key never collides.
+func ResequenceContextOrdinals(ctx context.Context, q Queryer, conversationID int64) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT ordinal
+		FROM context_items
+		WHERE conversation_id = ?
+		ORDER BY ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return fmt.Errorf("query context ordinals for resequence: %w", err)
+	}
+	ordinals := make([]int64, 0, 256)
+	for rows.Next() {
+		var ordinal int64
+		if err := rows.Scan(&ordinal); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan context ordinal for resequence: %w", err)
+		}
+		ordinals = append(ordinals, ordinal)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate context ordinals for resequence: %w", err)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("close context ordinal rows: %w", err)
+	}
+
+	for i, oldOrdinal := range ordinals {
+		tmpOrdinal := -int64(i + 1)
+		if _, err := q.ExecContext(ctx, `
+			UPDATE context_items
+			SET ordinal = ?
+			WHERE conversation_id = ? AND ordinal = ?
+		`, tmpOrdinal, conversationID, oldOrdinal); err != nil {
+			return fmt.Errorf("stage context ordinal %d -> %d: %w", oldOrdinal, tmpOrdinal, err)
+		}
+	}
+	for i := range ordinals {
+		finalOrdinal := int64(i)
+		tmpOrdinal := -int64(i + 1)
+		if _, err := q.ExecContext(ctx, `
+			UPDATE context_items
+			SET ordinal = ?
+			WHERE conversation_id = ? AND ordinal = ?
+		`, finalOrdinal, conversationID, tmpOrdinal); err != nil {
+			return fmt.Errorf("finalize context ordinal %d -> %d: %w", tmpOrdinal, finalOrdinal, err)
+		}
+	}
+	return nil
+}
+
+// SQLPlaceholders returns "?,?,...,?" with n placeholders.
+func SQLPlaceholders(n int) string {
+	return strings.TrimRight(strings.Repeat("?,", n), ",")
+}
+
+// ContextLayout describes how far a conversation's context_items are
+// from the layout compaction expects: every summary, then raw messages.
+// Compaction only condenses contiguous same-depth runs, so manual dissolves
+// and transplants that leave messages between summaries make it miss chunks
+// it could condense.
+type ContextLayout struct {
+	Items int
+	// moved counts items whose position differs in the canonical layout.
+	Moved int
+	// messagesBeforeSummary counts messages ordered before the last summary;
+	// lastSummaryOrdinal is that summary's ordinal.
+	MessagesBeforeSummary int
+	LastSummaryOrdinal    int64
+	// depthRuns maps each summary depth split across more than one
+	// contiguous run to its run count.
+	DepthRuns map[int]int
+}
+
+// Interleaved reports whether any item is out of canonical order.
+func (r ContextLayout) Interleaved() bool {
+	return r.Moved > 0
+}
+
+// AnalyzeContextLayout compares items (ordered by ordinal) with
+// CanonicalContextOrder.
+func AnalyzeContextLayout(items []ContextItem) ContextLayout {
+	report := ContextLayout{Items: len(items), LastSummaryOrdinal: -1}
+	for i, item := range CanonicalContextOrder(items) {
+		if item.Ordinal != items[i].Ordinal {
+			report.Moved++
+		}
+	}
+
+	runs := make(map[int]int)
+	runDepth := -1 // depth of the current contiguous run; -1 after a message
+	for _, item := range items {
+		if !IsContextSummary(item) {
+			runDepth = -1
+			continue
+		}
+		report.LastSummaryOrdinal = item.Ordinal
+		if item.Depth != runDepth {
+			runs[item.Depth]++
+		}
+		runDepth = item.Depth
+	}
+	for _, item := range items {
+		if !IsContextSummary(item) && item.Ordinal < report.LastSummaryOrdinal {
+			report.MessagesBeforeSummary++
+		}
+	}
+	for depth, count := range runs {
+		if count > 1 {
+			if report.DepthRuns == nil {
+				report.DepthRuns = make(map[int]int)
+			}
+			report.DepthRuns[depth] = count
+		}
+	}
+	return report
+}
+
+// IsContextSummary reports whether item points at a summary.
+func IsContextSummary(item ContextItem) bool {
+	return item.ItemType == "summary" && item.SummaryID.Valid
+}
+
+// CanonicalContextOrder returns items in the layout the plugin's compaction
+// produces: every summary, then every other item. Summaries keep their
+// current relative order, which is chronological, and only messages move
+// behind them, so an already canonical context is returned unchanged.
+func CanonicalContextOrder(items []ContextItem) []ContextItem {
+	ordered := append([]ContextItem(nil), items...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return IsContextSummary(ordered[i]) && !IsContextSummary(ordered[j])
+	})
+	return ordered
+}
+
+// warnInterleavedContext writes a note to w before compaction when the context
+// layout will hide condensable runs from it.
+func warnInterleavedContext(ctx context.Context, q Queryer, conversationID int64, w io.Writer) error {
+	items, err := LoadContextItems(ctx, q, conversationID)
+	if err != nil {
+		return err
+	}
+	report := AnalyzeContextLayout(items)
+	if !report.Interleaved() {
+		return nil
+	}
+	fmt.Fprintf(w, "Warning: conversation %d context is interleaved (%d of %d items out of order); condensed passes may miss chunks.\n", conversationID, report.Moved, report.Items)
+	fmt.Fprintf(w, "  Run `lcm-tui check-context %d --reorder` to restore summaries-then-messages order.\n", conversationID)
+	return nil
+}
@@ -0,0 +1,525 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ContextSummary is a summary context item with the summary row it points at.
+type ContextSummary struct {
+	Ordinal    int64
+	SummaryID  string
+	Kind       string
+	Depth      int
+	TokenCount int
+	Content    string
+	CreatedAt  string
+	FileIDs    string
+}
+
+// Summary is one summaries row.
+type Summary struct {
+	SummaryID      string
+	ConversationID int64
+	Kind           string
+	Content        string
+	TokenCount     int
+	CreatedAt      string
+	CreatedSeq     int64 // summaries.created_seq: insertion order among same-second created_at
+	FileIDs        string
+	Depth          int
+}
+
+// Message is one messages row.
+type Message struct {
+	MessageID      int64
+	ConversationID int64
+	Seq            int64
+	Role           string
+	Content        string
+	TokenCount     int
+	CreatedAt      string
+}
+
+// ContextStats counts a conversation's context items by type.
+type ContextStats struct {
+	Total     int
+	Summaries int
+	Messages  int
+}
+
+// ConversationExists reports whether conversationID has a conversations row.
+func ConversationExists(ctx context.Context, q Queryer, conversationID int64) (bool, error) {
+	var count int
+	if err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM conversations
+		WHERE conversation_id = ?
+	`, conversationID).Scan(&count); err != nil {
+		return false, fmt.Errorf("query conversation %d: %w", conversationID, err)
+	}
+	return count > 0, nil
+}
+
+// ConversationMessages returns all messages for a conversation in seq order.
+func ConversationMessages(ctx context.Context, q Queryer, conversationID int64) ([]Message, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT message_id, conversation_id, seq, role, content, token_count, created_at
+		FROM messages
+		WHERE conversation_id = ?
+		ORDER BY seq ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query messages for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var message Message
+		if err := rows.Scan(
+			&message.MessageID,
+			&message.ConversationID,
+			&message.Seq,
+			&message.Role,
+			&message.Content,
+			&message.TokenCount,
+			&message.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan message for conversation %d: %w", conversationID, err)
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages for conversation %d: %w", conversationID, err)
+	}
+	return messages, nil
+}
+
+// ConversationSessionID returns the session_id of a conversation.
+func ConversationSessionID(ctx context.Context, q Queryer, conversationID int64) (string, error) {
+	var sessionID string
+	if err := q.QueryRowContext(ctx, `
+		SELECT session_id
+		FROM conversations
+		WHERE conversation_id = ?
+	`, conversationID).Scan(&sessionID); err != nil {
+		return "", fmt.Errorf("query session ID for conversation %d: %w", conversationID, err)
+	}
+	if strings.TrimSpace(sessionID) == "" {
+		return "", fmt.Errorf("conversation %d has empty session_id", conversationID)
+	}
+	return sessionID, nil
+}
+
+// NextMessageSeq returns the seq after the conversation's highest message.
+func NextMessageSeq(ctx context.Context, q Queryer, conversationID int64) (int64, error) {
+	var maxSeq sql.NullInt64
+	if err := q.QueryRowContext(ctx, `
+		SELECT MAX(seq)
+		FROM messages
+		WHERE conversation_id = ?
+	`, conversationID).Scan(&maxSeq); err != nil {
+		return 0, fmt.Errorf("query max message seq for conversation %d: %w", conversationID, err)
+	}
+	if !maxSeq.Valid {
+		return 0, nil
+	}
+	return maxSeq.Int64 + 1, nil
+}
+
+// LoadContextStats counts the context items of a conversation.
+func LoadContextStats(ctx context.Context, q Queryer, conversationID int64) (ContextStats, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT item_type, COUNT(*)
+		FROM context_items
+		WHERE conversation_id = ?
+		GROUP BY item_type
+	`, conversationID)
+	if err != nil {
+		return ContextStats{}, fmt.Errorf("query context stats for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	stats := ContextStats{}
+	for rows.Next() {
+		var itemType string
+		var count int
+		if err := rows.Scan(&itemType, &count); err != nil {
+			return ContextStats{}, fmt.Errorf("scan context stats row: %w", err)
+		}
+		stats.Total += count
+		switch itemType {
+		case "summary":
+			stats.Summaries = count
+		case "message":
+			stats.Messages = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ContextStats{}, fmt.Errorf("iterate context stats for conversation %d: %w", conversationID, err)
+	}
+	return stats, nil
+}
+
+// loadContextSummaries returns the summary context items of a conversation in
+// ordinal order.
+func loadContextSummaries(ctx context.Context, q Queryer, conversationID int64) ([]ContextSummary, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			ci.ordinal,
+			ci.summary_id,
+			s.kind,
+			s.depth,
+			s.token_count,
+			s.content,
+			s.created_at,
+			s.file_ids
+		FROM context_items ci
+		JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ?
+		  AND ci.item_type = 'summary'
+		ORDER BY ci.ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query context summaries for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var items []ContextSummary
+	for rows.Next() {
+		var item ContextSummary
+		if err := rows.Scan(
+			&item.Ordinal,
+			&item.SummaryID,
+			&item.Kind,
+			&item.Depth,
+			&item.TokenCount,
+			&item.Content,
+			&item.CreatedAt,
+			&item.FileIDs,
+		); err != nil {
+			return nil, fmt.Errorf("scan context summary row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate context summaries for conversation %d: %w", conversationID, err)
+	}
+	return items, nil
+}
+
+// SummaryDAGIDs traverses summary_parents recursively from roots and
+// returns the deduplicated set of summary IDs to copy.
+func SummaryDAGIDs(ctx context.Context, q Queryer, rootIDs []string) ([]string, error) {
+	seen := make(map[string]bool, len(rootIDs))
+	queue := append([]string(nil), rootIDs...)
+
+	for idx := 0; idx < len(queue); idx++ {
+		summaryID := queue[idx]
+		if seen[summaryID] {
+			continue
+		}
+		seen[summaryID] = true
+
+		parents, err := ParentSummaryIDs(ctx, q, summaryID)
+		if err != nil {
+			return nil, err
+		}
+		for _, parentID := range parents {
+			if !seen[parentID] {
+				queue = append(queue, parentID)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for summaryID := range seen {
+		ids = append(ids, summaryID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// ParentSummaryIDs returns the summaries that summaryID was condensed from.
+func ParentSummaryIDs(ctx context.Context, q Queryer, summaryID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT parent_summary_id
+		FROM summary_parents
+		WHERE summary_id = ?
+		ORDER BY ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query parent summaries for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+
+	var parents []string
+	for rows.Next() {
+		var parentID string
+		if err := rows.Scan(&parentID); err != nil {
+			return nil, fmt.Errorf("scan parent summary row for %s: %w", summaryID, err)
+		}
+		parents = append(parents, parentID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate parent summary rows for %s: %w", summaryID, err)
+	}
+	return parents, nil
+}
+
+// LoadSummaries loads the summaries rows for summaryIDs.
+func LoadSummaries(ctx context.Context, q Queryer, summaryIDs []string) ([]Summary, error) {
+	if len(summaryIDs) == 0 {
+		return nil, nil
+	}
+
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	const batchSize = 200
+	result := make([]Summary, 0, len(summaryIDs))
+
+	for start := 0; start < len(summaryIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(summaryIDs) {
+			end = len(summaryIDs)
+		}
+
+		batch := summaryIDs[start:end]
+		placeholders := strings.TrimRight(strings.Repeat("?,", len(batch)), ",")
+		query := fmt.Sprintf(`
+			SELECT
+				summary_id,
+				conversation_id,
+				kind,
+				content,
+				token_count,
+				created_at,
+				%s,
+				file_ids,
+				depth
+			FROM summaries
+			WHERE summary_id IN (%s)
+		`, caps.SummarySeqExpr(""), placeholders)
+
+		args := make([]any, 0, len(batch))
+		for _, summaryID := range batch {
+			args = append(args, summaryID)
+		}
+
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query summaries for transplant batch: %w", err)
+		}
+
+		for rows.Next() {
+			var summary Summary
+			if err := rows.Scan(
+				&summary.SummaryID,
+				&summary.ConversationID,
+				&summary.Kind,
+				&summary.Content,
+				&summary.TokenCount,
+				&summary.CreatedAt,
+				&summary.CreatedSeq,
+				&summary.FileIDs,
+				&summary.Depth,
+			); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan summary row for transplant: %w", err)
+			}
+			result = append(result, summary)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("iterate transplant summary rows: %w", err)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, fmt.Errorf("close transplant summary rows: %w", err)
+		}
+	}
+
+	if len(result) != len(summaryIDs) {
+		return nil, fmt.Errorf("resolved %d summary rows for %d IDs", len(result), len(summaryIDs))
+	}
+	return result, nil
+}
+
+// InsertCopiedMessage inserts a copy of source into the target conversation at
+// seq and returns the new message ID.
+func InsertCopiedMessage(ctx context.Context, q Queryer, targetConversationID, seq int64, source Message) (int64, error) {
+	result, err := q.ExecContext(ctx, `
+		INSERT INTO messages (conversation_id, seq, role, content, token_count, identity_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, targetConversationID, seq, source.Role, source.Content, source.TokenCount, MessageIdentityHash(source.Role, source.Content), source.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("insert copied message from %d: %w", source.MessageID, err)
+	}
+
+	newMessageID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("read copied message ID for source message %d: %w", source.MessageID, err)
+	}
+	return newMessageID, nil
+}
+
+type messagePart struct {
+	partType       string
+	ordinal        int64
+	textContent    sql.NullString
+	isIgnored      sql.NullInt64
+	isSynthetic    sql.NullInt64
+	toolCallID     sql.NullString
+	toolName       sql.NullString
+	toolStatus     sql.NullString
+	toolInput      sql.NullString
+	toolOutput     sql.NullString
+	toolError      sql.NullString
+	toolTitle      sql.NullString
+	patchHash      sql.NullString
+	patchFiles     sql.NullString
+	fileMime       sql.NullString
+	fileName       sql.NullString
+	fileURL        sql.NullString
+	subtaskPrompt  sql.NullString
+	subtaskDesc    sql.NullString
+	subtaskAgent   sql.NullString
+	stepReason     sql.NullString
+	stepCost       sql.NullFloat64
+	stepTokensIn   sql.NullInt64
+	stepTokensOut  sql.NullInt64
+	snapshotHash   sql.NullString
+	compactionAuto sql.NullInt64
+	metadata       sql.NullString
+}
+
+// CopyMessageParts duplicates all parts for one message and rewires message_id.
+func CopyMessageParts(ctx context.Context, q Queryer, sourceMessageID, newMessageID int64, targetSessionID string) (int, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			part_type,
+			ordinal,
+			text_content,
+			is_ignored,
+			is_synthetic,
+			tool_call_id,
+			tool_name,
+			tool_status,
+			tool_input,
+			tool_output,
+			tool_error,
+			tool_title,
+			patch_hash,
+			patch_files,
+			file_mime,
+			file_name,
+			file_url,
+			subtask_prompt,
+			subtask_desc,
+			subtask_agent,
+			step_reason,
+			step_cost,
+			step_tokens_in,
+			step_tokens_out,
+			snapshot_hash,
+			compaction_auto,
+			metadata
+		FROM message_parts
+		WHERE message_id = ?
+		ORDER BY ordinal ASC
+	`, sourceMessageID)
+	if err != nil {
+		return 0, fmt.Errorf("query message_parts for message %d: %w", sourceMessageID, err)
+	}
+	defer rows.Close()
+
+	parts := make([]messagePart, 0, 8)
+	for rows.Next() {
+		var part messagePart
+		if err := rows.Scan(
+			&part.partType,
+			&part.ordinal,
+			&part.textContent,
+			&part.isIgnored,
+			&part.isSynthetic,
+			&part.toolCallID,
+			&part.toolName,
+			&part.toolStatus,
+			&part.toolInput,
+			&part.toolOutput,
+			&part.toolError,
+			&part.toolTitle,
+			&part.patchHash,
+			&part.patchFiles,
+			&part.fileMime,
+			&part.fileName,
+			&part.fileURL,
+			&part.subtaskPrompt,
+			&part.subtaskDesc,
+			&part.subtaskAgent,
+			&part.stepReason,
+			&part.stepCost,
+			&part.stepTokensIn,
+			&part.stepTokensOut,
+			&part.snapshotHash,
+			&part.compactionAuto,
+			&part.metadata,
+		); err != nil {
+			return 0, fmt.Errorf("scan message_part row for message %d: %w", sourceMessageID, err)
+		}
+		parts = append(parts, part)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate message_parts for message %d: %w", sourceMessageID, err)
+	}
+
+	for _, part := range parts {
+		partID, err := NewMessagePartID()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO message_parts (
+				part_id,
+				message_id,
+				session_id,
+				part_type,
+				ordinal,
+				text_content,
+				is_ignored,
+				is_synthetic,
+				tool_call_id,
+				tool_name,
+				tool_status,
+				tool_input,
+				tool_output,
+				tool_error,
+				tool_title,
+				patch_hash,
+				patch_files,
+				file_mime,
+				file_name,
+				file_url,
+				subtask_prompt,
+				subtask_desc,
+				subtask_agent,
+				step_reason,
+				step_cost,
+				step_tokens_in,
+				step_tokens_out,
+				snapshot_hash,
+				compaction_auto,
+				metadata
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, partID, newMessageID, targetSessionID, part.partType, part.ordinal, part.textContent, part.isIgnored, part.isSynthetic, part.toolCallID, part.toolName, part.toolStatus, part.toolInput, part.toolOutput, part.toolError, part.toolTitle, part.patchHash, part.patchFiles, part.fileMime, part.fileName, part.fileURL, part.subtaskPrompt, part.subtaskDesc, part.subtaskAgent, part.stepReason, part.stepCost, part.stepTokensIn, part.stepTokensOut, part.snapshotHash, part.compactionAuto, part.metadata); err != nil {
+			return 0, fmt.Errorf("insert copied message_part for source message %d: %w", sourceMessageID, err)
+		}
+	}
+
+	return len(parts), nil
+}
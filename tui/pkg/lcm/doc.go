@@ -0,0 +1,14 @@
+// Package lcm holds the database and prompt logic behind lcm-tui in a form
+// other Go programs can import: summary prompt templates and rendering,
+// previous-context lookup, schema capability probing, token estimation, the
+// content hashes used for deduplication, and the backfill, compaction,
+// transplant, and repair workflows along with the summary source builders
+// rewrite uses.
+//
+// Functions take a Queryer, satisfied by *sql.DB, *sql.Tx, and *sql.Conn, so
+// callers control connections and transactions. Workflows that commit in
+// stages take a TxBeginner instead. Summarization is abstracted behind
+// Summarizer so any model client can drive RenderAndSummarize and the
+// workflows. Progress goes to an optional io.Writer or callback; nothing in
+// the package prints on its own.
+package lcm
@@ -0,0 +1,61 @@
+package lcm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// GenerateSummaryID returns a random summary ID not yet used in summaries.
+func GenerateSummaryID(ctx context.Context, q Queryer) (string, error) {
+	const maxAttempts = 32
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		candidate, err := newSummaryID()
+		if err != nil {
+			return "", err
+		}
+
+		var count int
+		if err := q.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM summaries
+			WHERE summary_id = ?
+		`, candidate).Scan(&count); err != nil {
+			return "", fmt.Errorf("check generated summary ID %s: %w", candidate, err)
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("unable to generate unique summary ID after 32 attempts")
+}
+
+func newSummaryID() (string, error) {
+	var raw [8]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("generate summary ID bytes: %w", err)
+	}
+	return "sum_" + hex.EncodeToString(raw[:]), nil
+}
+
+// NewMessagePartID returns a random UUIDv4 for a message_parts row.
+func NewMessagePartID() (string, error) {
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("generate message part ID bytes: %w", err)
+	}
+
+	// Set UUIDv4 + RFC4122 variant bits.
+	raw[6] = (raw[6] & 0x0f) | 0x40
+	raw[8] = (raw[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s",
+		hex.EncodeToString(raw[0:4]),
+		hex.EncodeToString(raw[4:6]),
+		hex.EncodeToString(raw[6:8]),
+		hex.EncodeToString(raw[8:10]),
+		hex.EncodeToString(raw[10:16]),
+	), nil
+}
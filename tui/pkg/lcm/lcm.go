@@ -0,0 +1,64 @@
+package lcm
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+)
+
+// Queryer is the subset of *sql.DB / *sql.Tx used by LCM database helpers.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// TxBeginner is a Queryer that can also begin transactions, satisfied by
+// *sql.DB and *sql.Conn. Workflows that commit in several steps, such as
+// compaction passes, take one; single-transaction writes take a Queryer so
+// the caller owns the transaction.
+type TxBeginner interface {
+	Queryer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Summarizer produces a summary for a rendered prompt, aiming for roughly
+// targetTokens of output.
+type Summarizer interface {
+	Summarize(ctx context.Context, prompt string, targetTokens int) (string, error)
+}
+
+// SummarizerFunc adapts a plain function to Summarizer.
+type SummarizerFunc func(ctx context.Context, prompt string, targetTokens int) (string, error)
+
+// Summarize calls f.
+func (f SummarizerFunc) Summarize(ctx context.Context, prompt string, targetTokens int) (string, error) {
+	return f(ctx, prompt, targetTokens)
+}
+
+// EstimateTokenCount approximates tokens as one per four bytes. It is the
+// coarse estimate lcm-tui stores in token_count columns.
+func EstimateTokenCount(s string) int {
+	if len(s) == 0 {
+		return 0
+	}
+	return len(s) / 4
+}
+
+// ContentSHA256 returns the hex SHA-256 of content, used to detect duplicate
+// summaries across conversations.
+func ContentSHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// MessageIdentityHash returns the messages.identity_hash value for a message:
+// SHA-256 over role, a NUL separator, and content.
+func MessageIdentityHash(role, content string) string {
+	sum := sha256.New()
+	sum.Write([]byte(role))
+	sum.Write([]byte{0})
+	sum.Write([]byte(content))
+	return hex.EncodeToString(sum.Sum(nil))
+}
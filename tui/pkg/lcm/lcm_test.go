@@ -0,0 +1,78 @@
+package lcm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderAndSummarizeUsesDepthTemplateAndTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var gotPrompt string
+	var gotTarget int
+	summarizer := SummarizerFunc(func(_ context.Context, prompt string, targetTokens int) (string, error) {
+		gotPrompt = prompt
+		gotTarget = targetTokens
+		return "summary", nil
+	})
+
+	out, err := RenderAndSummarize(context.Background(), summarizer, 0, PromptVars{TargetTokens: 900, SourceText: "[user] ship the fix"}, "")
+	if err != nil {
+		t.Fatalf("render and summarize: %v", err)
+	}
+	if out != "summary" || gotTarget != 900 {
+		t.Fatalf("summary=%q target=%d, want summary/900", out, gotTarget)
+	}
+	if !strings.Contains(gotPrompt, "[user] ship the fix") {
+		t.Fatalf("leaf prompt missing source text:\n%s", gotPrompt)
+	}
+}
+
+func TestLoadPromptTemplateContentPrefersOverrideDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	overrideDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(overrideDir, "condensed-d2.tmpl"), []byte("custom {{.Depth}}"), 0o644); err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+
+	content, source, err := LoadPromptTemplateContent("condensed-d2.tmpl", overrideDir)
+	if err != nil {
+		t.Fatalf("load override: %v", err)
+	}
+	if source.Kind != PromptSourceFilesystem || content != "custom {{.Depth}}" {
+		t.Fatalf("source=%+v content=%q, want filesystem override", source, content)
+	}
+
+	rendered, err := RenderPrompt(2, PromptVars{Depth: 2}, overrideDir)
+	if err != nil {
+		t.Fatalf("render override: %v", err)
+	}
+	if rendered != "custom 2" {
+		t.Fatalf("rendered = %q, want custom 2", rendered)
+	}
+
+	if _, source, err = LoadPromptTemplateContent("leaf.tmpl", overrideDir); err != nil || source.Kind != PromptSourceEmbedded {
+		t.Fatalf("leaf source = %+v, %v; want embedded fallback", source, err)
+	}
+}
+
+func TestPromptNameDepthRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for depth := 0; depth <= 3; depth++ {
+		got, err := DepthForPromptName(PromptNameForDepth(depth))
+		if err != nil || got != depth {
+			t.Fatalf("depth %d round trip = %d, %v", depth, got, err)
+		}
+	}
+	if PromptNameForDepth(7) != "condensed-d3.tmpl" {
+		t.Fatalf("depth 7 should use the d3+ template")
+	}
+	if _, err := NormalizePromptTemplateName("bogus"); err == nil {
+		t.Fatalf("expected unknown template error")
+	}
+}
@@ -0,0 +1,230 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PreviousContextOptions controls how much preceding narrative
+// PreviousSummaries returns.
+type PreviousContextOptions struct {
+	// Count is the number of previous summaries to return; 0 returns none.
+	Count int
+	// Depth draws the summaries from this depth instead of the target's own
+	// (0 for leaves). Negative keeps the target's depth.
+	Depth int
+}
+
+// DefaultPreviousContextOptions returns the single same-depth predecessor
+// that PreviousContext uses.
+func DefaultPreviousContextOptions() PreviousContextOptions {
+	return PreviousContextOptions{Count: 1, Depth: -1}
+}
+
+// PreviousSummary is one summary included as previous context.
+type PreviousSummary struct {
+	SummaryID string
+	Content   string
+}
+
+// JoinPreviousSummaries joins summaries' content, oldest first, the way it
+// is placed in a prompt's previous context.
+func JoinPreviousSummaries(summaries []PreviousSummary) string {
+	parts := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		parts = append(parts, summary.Content)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// PreviousContext finds the content of the chronologically previous
+// summary at the same depth. Works for:
+//   - Leaves still in context_items (uses ordinal ordering)
+//   - Absorbed leaves (uses summary_parents sibling ordering)
+//   - Condensed nodes in context_items (uses ordinal + depth filter)
+//   - Absorbed condensed nodes (uses summary_parents sibling ordering)
+//
+// Falls back to timestamp ordering as a last resort.
+// Returns empty string (not "(none)") when no previous context exists.
+func PreviousContext(ctx context.Context, q Queryer, summaryID string, conversationID int64, depth int, kind, createdAt string) (string, error) {
+	summaries, err := PreviousSummaries(ctx, q, summaryID, conversationID, depth, kind, createdAt, DefaultPreviousContextOptions())
+	if err != nil {
+		return "", err
+	}
+	return JoinPreviousSummaries(summaries), nil
+}
+
+// PreviousSummaries is PreviousContext for up to opts.Count predecessors,
+// optionally drawn from another depth. It tries the same three strategies in
+// order and returns the first that finds any, oldest first.
+func PreviousSummaries(ctx context.Context, q Queryer, summaryID string, conversationID int64, depth int, kind, createdAt string, opts PreviousContextOptions) ([]PreviousSummary, error) {
+	if opts.Count <= 0 {
+		return nil, nil
+	}
+	isLeaf := depth == 0 || strings.EqualFold(kind, "leaf")
+
+	// Strategy 1: look up via context_items (still-active nodes)
+	summaries, err := previousViaContextItems(ctx, q, summaryID, conversationID, depth, isLeaf, opts)
+	if err != nil || len(summaries) > 0 {
+		return summaries, err
+	}
+
+	// Strategy 2: look up via summary_parents (absorbed nodes)
+	summaries, err = previousViaSummaryParents(ctx, q, summaryID, opts)
+	if err != nil || len(summaries) > 0 {
+		return summaries, err
+	}
+
+	// Strategy 3: timestamp ordering (catches edge cases)
+	return previousViaTimestamp(ctx, q, summaryID, conversationID, depth, createdAt, opts)
+}
+
+// previousViaContextItems finds previous siblings using context_items ordering.
+func previousViaContextItems(ctx context.Context, q Queryer, summaryID string, conversationID int64, depth int, isLeaf bool, opts PreviousContextOptions) ([]PreviousSummary, error) {
+	var targetOrdinal int64
+	err := q.QueryRowContext(ctx, `
+		SELECT ci.ordinal
+		FROM context_items ci
+		WHERE ci.conversation_id = ?
+		  AND ci.item_type = 'summary'
+		  AND ci.summary_id = ?
+		LIMIT 1
+	`, conversationID, summaryID).Scan(&targetOrdinal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // not in context_items
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query context ordinal for %s: %w", summaryID, err)
+	}
+
+	// For leaves, match depth 0; for condensed, match same depth
+	depthFilter := depth
+	if isLeaf {
+		depthFilter = 0
+	}
+	if opts.Depth >= 0 {
+		depthFilter = opts.Depth
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.summary_id, s.content
+		FROM context_items ci
+		JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE ci.conversation_id = ?
+		  AND ci.item_type = 'summary'
+		  AND COALESCE(s.depth, 0) = ?
+		  AND ci.ordinal < ?
+		ORDER BY ci.ordinal DESC
+		LIMIT ?
+	`, conversationID, depthFilter, targetOrdinal, opts.Count)
+	if err != nil {
+		return nil, fmt.Errorf("query previous via context_items: %w", err)
+	}
+	return scanPreviousSummaries(rows, "context_items")
+}
+
+// previousViaSummaryParents finds the previous siblings of a node that has
+// been absorbed into a condensed parent.
+func previousViaSummaryParents(ctx context.Context, q Queryer, summaryID string, opts PreviousContextOptions) ([]PreviousSummary, error) {
+	var parentID string
+	var myOrdinal int64
+	err := q.QueryRowContext(ctx, `
+		SELECT sp.summary_id, sp.ordinal
+		FROM summary_parents sp
+		WHERE sp.parent_summary_id = ?
+		LIMIT 1
+	`, summaryID).Scan(&parentID, &myOrdinal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // no parent
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query parent of %s: %w", summaryID, err)
+	}
+
+	// Siblings share the node's depth, so a different requested depth falls
+	// through to the timestamp strategy.
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.summary_id, s.content
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.parent_summary_id
+		WHERE sp.summary_id = ?
+		  AND sp.ordinal < ?
+		  AND (? < 0 OR COALESCE(s.depth, 0) = ?)
+		ORDER BY sp.ordinal DESC
+		LIMIT ?
+	`, parentID, myOrdinal, opts.Depth, opts.Depth, opts.Count)
+	if err != nil {
+		return nil, fmt.Errorf("query previous siblings of %s: %w", summaryID, err)
+	}
+	return scanPreviousSummaries(rows, "summary_parents")
+}
+
+// previousViaTimestamp finds previous summaries at the same depth by
+// timestamp ordering. Last resort fallback. Summaries created in the same
+// second are ordered by insertion; see SchemaCapabilities.SummarySeqExpr.
+func previousViaTimestamp(ctx context.Context, q Queryer, summaryID string, conversationID int64, depth int, createdAt string, opts PreviousContextOptions) ([]PreviousSummary, error) {
+	if createdAt == "" {
+		return nil, nil
+	}
+	if opts.Depth >= 0 {
+		depth = opts.Depth
+	}
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	seq := caps.SummarySeqExpr("")
+	rows, err := q.QueryContext(ctx, `
+		SELECT summary_id, content
+		FROM summaries
+		WHERE conversation_id = ?
+		  AND COALESCE(depth, 0) = ?
+		  AND (created_at < ? OR (created_at = ? AND `+seq+` < (SELECT `+seq+` FROM summaries WHERE summary_id = ?)))
+		ORDER BY created_at DESC, `+seq+` DESC
+		LIMIT ?
+	`, conversationID, depth, createdAt, createdAt, summaryID, opts.Count)
+	if err != nil {
+		return nil, fmt.Errorf("query previous via timestamp for %s: %w", summaryID, err)
+	}
+	return scanPreviousSummaries(rows, "timestamp")
+}
+
+// scanPreviousSummaries reads newest-first (summary_id, content) rows,
+// skips empty content, and returns them oldest first.
+func scanPreviousSummaries(rows *sql.Rows, strategy string) ([]PreviousSummary, error) {
+	defer rows.Close()
+	var summaries []PreviousSummary
+	for rows.Next() {
+		var summaryID string
+		var content sql.NullString
+		if err := rows.Scan(&summaryID, &content); err != nil {
+			return nil, fmt.Errorf("scan previous summary via %s: %w", strategy, err)
+		}
+		if trimmed := strings.TrimSpace(content.String); trimmed != "" {
+			summaries = append(summaries, PreviousSummary{SummaryID: summaryID, Content: trimmed})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate previous summaries via %s: %w", strategy, err)
+	}
+	for i, j := 0, len(summaries)-1; i < j; i, j = i+1, j-1 {
+		summaries[i], summaries[j] = summaries[j], summaries[i]
+	}
+	return summaries, nil
+}
+
+// DescribePreviousContext renders what a prompt's previous context held,
+// e.g. "2 of 3 (sum_a, sum_b)" or "none of 1".
+func DescribePreviousContext(summaries []PreviousSummary, opts PreviousContextOptions) string {
+	if len(summaries) == 0 {
+		return fmt.Sprintf("none of %d", opts.Count)
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.SummaryID)
+	}
+	return fmt.Sprintf("%d of %d (%s)", len(summaries), opts.Count, strings.Join(ids, ", "))
+}
@@ -0,0 +1,293 @@
+package lcm
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptOverrideDir is checked for template overrides after any
+// caller-supplied directory.
+const DefaultPromptOverrideDir = "~/.config/lcm-tui/prompts"
+
+// Prompt source kinds reported in PromptSource.Kind.
+const (
+	PromptSourceFilesystem = "filesystem"
+	PromptSourceEmbedded   = "embedded"
+)
+
+var promptTemplateNames = []string{
+	"leaf.tmpl",
+	"condensed-d1.tmpl",
+	"condensed-d2.tmpl",
+	"condensed-d3.tmpl",
+}
+
+// defaultPromptFS stores the built-in prompt templates.
+//
+//go:embed prompts/*.tmpl
+var defaultPromptFS embed.FS
+
+// PromptVars is the template data passed into depth-aware prompt templates.
+type PromptVars struct {
+	TargetTokens    int
+	PreviousContext string
+	ChildCount      int
+	TimeRange       string
+	Depth           int
+	SourceText      string
+	// FreshTailCount is the number of trailing source messages marked
+	// [most recent]; zero disables the marker guidance.
+	FreshTailCount int
+	// Examples are existing summaries at the same depth, shown as style
+	// exemplars; empty leaves the examples block out.
+	Examples []string
+	// TargetChars, when positive, asks for about that many characters in
+	// place of TargetTokens (rewrite and repair --target-chars).
+	TargetChars int
+}
+
+// PromptSource records where a template was loaded from.
+type PromptSource struct {
+	Name string
+	Kind string // PromptSourceFilesystem or PromptSourceEmbedded
+	Path string
+}
+
+// PromptTemplateNames returns the built-in template file names, leaf first.
+func PromptTemplateNames() []string {
+	return append([]string(nil), promptTemplateNames...)
+}
+
+// PromptNameForDepth maps summary depth to prompt filename.
+func PromptNameForDepth(depth int) string {
+	switch {
+	case depth <= 0:
+		return "leaf.tmpl"
+	case depth == 1:
+		return "condensed-d1.tmpl"
+	case depth == 2:
+		return "condensed-d2.tmpl"
+	default:
+		return "condensed-d3.tmpl"
+	}
+}
+
+// RenderPrompt loads the depth-mapped template and executes it with vars.
+func RenderPrompt(depth int, vars PromptVars, overrideDir string) (string, error) {
+	return RenderPromptByName(PromptNameForDepth(depth), vars, overrideDir)
+}
+
+// RenderPromptByName executes a named template, preferring overrides in
+// overrideDir and DefaultPromptOverrideDir over the embedded default.
+func RenderPromptByName(name string, vars PromptVars, overrideDir string) (string, error) {
+	tmpl, err := loadPromptTemplate(name, overrideDir)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("execute prompt template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// RenderAndSummarize renders the prompt for depth and sends it to s with
+// vars.TargetTokens as the output target.
+func RenderAndSummarize(ctx context.Context, s Summarizer, depth int, vars PromptVars, overrideDir string) (string, error) {
+	prompt, err := RenderPrompt(depth, vars, overrideDir)
+	if err != nil {
+		return "", err
+	}
+	return s.Summarize(ctx, prompt, vars.TargetTokens)
+}
+
+// ValidatePromptTemplate parses content as the named template and executes
+// it with sample PromptVars, so syntax errors and references to fields
+// PromptVars does not have are caught before the template is used.
+func ValidatePromptTemplate(name, content string) error {
+	normalized, err := NormalizePromptTemplateName(name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(normalized).Parse(content)
+	if err != nil {
+		return fmt.Errorf("parse prompt template %s: %w", normalized, err)
+	}
+	depth, err := DepthForPromptName(normalized)
+	if err != nil {
+		return err
+	}
+	sample := PromptVars{
+		TargetTokens:    1200,
+		PreviousContext: "previous summary",
+		ChildCount:      2,
+		TimeRange:       "2026-01-01 10:00 - 2026-01-01 11:00 UTC",
+		Depth:           depth,
+		SourceText:      "[user] sample source",
+		FreshTailCount:  1,
+		Examples:        []string{"example summary"},
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("execute prompt template %s: %w", normalized, err)
+	}
+	return nil
+}
+
+// loadPromptTemplate resolves prompt source and parses a template.
+func loadPromptTemplate(name, overrideDir string) (*template.Template, error) {
+	normalized, err := NormalizePromptTemplateName(name)
+	if err != nil {
+		return nil, err
+	}
+	content, _, err := LoadPromptTemplateContent(normalized, overrideDir)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(normalized).Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template %s: %w", normalized, err)
+	}
+	return tmpl, nil
+}
+
+// ResolvePromptSource reports which file or embedded default a template name
+// would load from.
+func ResolvePromptSource(name, overrideDir string) (PromptSource, error) {
+	normalized, err := NormalizePromptTemplateName(name)
+	if err != nil {
+		return PromptSource{}, err
+	}
+	_, source, err := LoadPromptTemplateContent(normalized, overrideDir)
+	if err != nil {
+		return PromptSource{}, err
+	}
+	return source, nil
+}
+
+// LoadPromptTemplateContent returns the raw template text for a normalized
+// name along with its source.
+func LoadPromptTemplateContent(name, overrideDir string) (string, PromptSource, error) {
+	for _, path := range PromptCandidatePaths(name, overrideDir) {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data), PromptSource{Name: name, Kind: PromptSourceFilesystem, Path: path}, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", PromptSource{}, fmt.Errorf("read prompt template %s: %w", path, err)
+		}
+	}
+	content, err := DefaultPromptTemplate(name)
+	if err != nil {
+		return "", PromptSource{}, err
+	}
+	return content, PromptSource{Name: name, Kind: PromptSourceEmbedded, Path: "prompts/" + name}, nil
+}
+
+// LoadPromptOverride returns the first filesystem override for name, if any.
+func LoadPromptOverride(name, overrideDir string) (path, content string, found bool, err error) {
+	for _, candidate := range PromptCandidatePaths(name, overrideDir) {
+		data, readErr := os.ReadFile(candidate)
+		if readErr == nil {
+			return candidate, string(data), true, nil
+		}
+		if !errors.Is(readErr, os.ErrNotExist) {
+			return "", "", false, fmt.Errorf("read prompt override %s: %w", candidate, readErr)
+		}
+	}
+	return "", "", false, nil
+}
+
+// DefaultPromptTemplate returns the embedded template text for name.
+func DefaultPromptTemplate(name string) (string, error) {
+	normalized, err := NormalizePromptTemplateName(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := defaultPromptFS.ReadFile("prompts/" + normalized)
+	if err != nil {
+		return "", fmt.Errorf("read embedded prompt template %s: %w", normalized, err)
+	}
+	return string(data), nil
+}
+
+// PromptCandidatePaths lists override paths checked for name, in order.
+func PromptCandidatePaths(name, overrideDir string) []string {
+	paths := make([]string, 0, 2)
+	if strings.TrimSpace(overrideDir) != "" {
+		paths = append(paths, filepath.Join(ExpandHomePath(overrideDir), name))
+	}
+	defaultPath := filepath.Join(ExpandHomePath(DefaultPromptOverrideDir), name)
+	if len(paths) == 0 || paths[0] != defaultPath {
+		paths = append(paths, defaultPath)
+	}
+	return paths
+}
+
+// NormalizePromptTemplateName lowercases name, adds the .tmpl suffix, and
+// rejects names that are not built-in templates.
+func NormalizePromptTemplateName(name string) (string, error) {
+	trimmed := strings.TrimSpace(strings.ToLower(name))
+	if trimmed == "" {
+		return "", fmt.Errorf("template name is required")
+	}
+	if !strings.HasSuffix(trimmed, ".tmpl") {
+		trimmed += ".tmpl"
+	}
+	for _, candidate := range promptTemplateNames {
+		if candidate == trimmed {
+			return trimmed, nil
+		}
+	}
+	return "", fmt.Errorf("unknown prompt template %q", name)
+}
+
+// DepthForPromptName is the inverse of PromptNameForDepth; d3 stands for d3+.
+func DepthForPromptName(name string) (int, error) {
+	normalized, err := NormalizePromptTemplateName(name)
+	if err != nil {
+		return 0, err
+	}
+	switch normalized {
+	case "leaf.tmpl":
+		return 0, nil
+	case "condensed-d1.tmpl":
+		return 1, nil
+	case "condensed-d2.tmpl":
+		return 2, nil
+	case "condensed-d3.tmpl":
+		return 3, nil
+	default:
+		return 0, fmt.Errorf("unsupported prompt template %s", normalized)
+	}
+}
+
+// ExpandHomePath expands a leading "~" or "~/" to the user's home directory.
+func ExpandHomePath(path string) string {
+	trimmed := strings.TrimSpace(path)
+	if trimmed == "" {
+		return trimmed
+	}
+	if trimmed == "~" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return trimmed
+		}
+		return home
+	}
+	if !strings.HasPrefix(trimmed, "~/") {
+		return trimmed
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return trimmed
+	}
+	return filepath.Join(home, strings.TrimPrefix(trimmed, "~/"))
+}
@@ -0,0 +1,55 @@
+package lcm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RedactionCounts maps a pattern name to the number of matches replaced.
+type RedactionCounts map[string]int
+
+// Total is the number of replacements across all patterns.
+func (c RedactionCounts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// Add accumulates other into c.
+func (c RedactionCounts) Add(other RedactionCounts) {
+	for name, n := range other {
+		c[name] += n
+	}
+}
+
+// String renders the counts as "3 (anthropic-key 2, aws-access-key 1)".
+func (c RedactionCounts) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + " " + strconv.Itoa(c[name])
+	}
+	return fmt.Sprintf("%d (%s)", c.Total(), strings.Join(parts, ", "))
+}
+
+// Redactor replaces secrets in summary source text before it is sent to a
+// summary API, reporting how many matches of each pattern it replaced.
+type Redactor interface {
+	Redact(text string) (string, RedactionCounts)
+}
+
+// redact applies r to text; a nil Redactor leaves it unchanged.
+func redact(r Redactor, text string) (string, RedactionCounts) {
+	if r == nil {
+		return text, RedactionCounts{}
+	}
+	return r.Redact(text)
+}
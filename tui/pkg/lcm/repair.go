@@ -0,0 +1,590 @@
+package lcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CorruptedSummaryMarker is the fallback text the plugin writes when
+// summarization fails and it truncates the source instead.
+const CorruptedSummaryMarker = "[LCM fallback summary; truncated for context management]"
+
+// DefaultCorruptedSummaryMarkers are the fallback phrasings the plugin has
+// written when summarization failed. Any one of them marks a summary as a
+// repair candidate.
+var DefaultCorruptedSummaryMarkers = []string{
+	CorruptedSummaryMarker,
+	"[LCM fallback summary; directive-shaped untrusted content omitted]",
+	"[LCM fallback summary omitted directive-shaped untrusted content]",
+}
+
+// CorruptedMarkerClause builds a SQL condition that matches column against
+// any marker as a literal, case-sensitive substring. instr avoids LIKE's
+// wildcard and case-folding rules for user-supplied patterns.
+func CorruptedMarkerClause(column string, markers []string) (string, []any) {
+	if len(markers) == 0 {
+		return "0", nil
+	}
+	terms := make([]string, 0, len(markers))
+	args := make([]any, 0, len(markers))
+	for _, marker := range markers {
+		terms = append(terms, "instr("+column+", ?) > 0")
+		args = append(args, marker)
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+// MatchCorruptedSummaryMarker returns the first marker found in content.
+func MatchCorruptedSummaryMarker(content string, markers []string) string {
+	for _, marker := range markers {
+		if strings.Contains(content, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// TokenRange filters summaries on their stored token_count. Zero leaves that
+// bound open.
+type TokenRange struct {
+	Min int
+	Max int
+}
+
+// Active reports whether either bound is set.
+func (r TokenRange) Active() bool {
+	return r.Min > 0 || r.Max > 0
+}
+
+// Clause returns the SQL predicate for column, starting with " AND ", and its
+// arguments; both are empty when the range is open.
+func (r TokenRange) Clause(column string) (string, []any) {
+	var (
+		clause string
+		args   []any
+	)
+	if r.Min > 0 {
+		clause += " AND COALESCE(" + column + ", 0) >= ?"
+		args = append(args, r.Min)
+	}
+	if r.Max > 0 {
+		clause += " AND COALESCE(" + column + ", 0) <= ?"
+		args = append(args, r.Max)
+	}
+	return clause, args
+}
+
+// String describes the range for reports.
+func (r TokenRange) String() string {
+	switch {
+	case r.Min > 0 && r.Max > 0:
+		return fmt.Sprintf("%d-%d tokens", r.Min, r.Max)
+	case r.Min > 0:
+		return fmt.Sprintf(">= %d tokens", r.Min)
+	case r.Max > 0:
+		return fmt.Sprintf("<= %d tokens", r.Max)
+	default:
+		return "any size"
+	}
+}
+
+// RepairSummary is a corrupted summary selected for repair.
+type RepairSummary struct {
+	SummaryID         string
+	ConversationID    int64
+	Kind              string
+	Depth             int
+	TokenCount        int
+	Content           string
+	CreatedAt         string
+	CreatedSeq        int64 // summaries.created_seq: insertion order among same-second created_at
+	ChildCount        int
+	ContextOrdinal    int64
+	HasContextOrdinal bool
+	// Marker is the corrupted-summary marker that matched Content.
+	Marker string
+}
+
+// IsLeaf reports whether s is a leaf summary.
+func (s RepairSummary) IsLeaf() bool {
+	return s.Depth == 0 || strings.EqualFold(s.Kind, "leaf")
+}
+
+// LeafSequenceEntry is one leaf summary in context order.
+type LeafSequenceEntry struct {
+	Ordinal   int64
+	SummaryID string
+	Content   string
+	Corrupted bool
+}
+
+// RepairPlan is a conversation's corrupted summaries. Summaries is in report
+// order (deepest first); Ordered is the order they are repaired in.
+type RepairPlan struct {
+	Summaries    []RepairSummary
+	Ordered      []RepairSummary
+	LeafSequence []LeafSequenceEntry
+}
+
+// BuildRepairPlan computes both the scan output and bottom-up repair order.
+// Leaves are repaired in context_items ordinal order so each repaired leaf can
+// feed previous_context into the next corrupted leaf. An empty summaryID
+// selects every corrupted summary in the conversation.
+func BuildRepairPlan(ctx context.Context, q Queryer, conversationID int64, summaryID string, markers []string, tokens TokenRange) (RepairPlan, error) {
+	summaries, err := loadCorruptedSummaries(ctx, q, conversationID, summaryID, markers, tokens)
+	if err != nil {
+		return RepairPlan{}, err
+	}
+	if len(summaries) == 0 {
+		return RepairPlan{}, nil
+	}
+
+	leafSeq, err := loadLeafSequence(ctx, q, conversationID, markers)
+	if err != nil {
+		return RepairPlan{}, err
+	}
+
+	byID := make(map[string]*RepairSummary, len(summaries))
+	for i := range summaries {
+		byID[summaries[i].SummaryID] = &summaries[i]
+	}
+	for _, leaf := range leafSeq {
+		if item, ok := byID[leaf.SummaryID]; ok {
+			item.ContextOrdinal = leaf.Ordinal
+			item.HasContextOrdinal = true
+		}
+	}
+
+	ordered := make([]RepairSummary, 0, len(summaries))
+	seen := make(map[string]bool, len(summaries))
+
+	for _, leaf := range leafSeq {
+		item, ok := byID[leaf.SummaryID]
+		if !ok || seen[leaf.SummaryID] {
+			continue
+		}
+		if item.IsLeaf() {
+			ordered = append(ordered, *item)
+			seen[leaf.SummaryID] = true
+		}
+	}
+
+	var orphanLeaves []RepairSummary
+	var condensed []RepairSummary
+	for _, item := range summaries {
+		if seen[item.SummaryID] {
+			continue
+		}
+		if item.IsLeaf() {
+			orphanLeaves = append(orphanLeaves, item)
+			continue
+		}
+		condensed = append(condensed, item)
+	}
+
+	sort.Slice(orphanLeaves, func(i, j int) bool {
+		left := orphanLeaves[i]
+		right := orphanLeaves[j]
+		if left.CreatedAt != right.CreatedAt {
+			return left.CreatedAt < right.CreatedAt
+		}
+		if left.CreatedSeq != right.CreatedSeq {
+			return left.CreatedSeq < right.CreatedSeq
+		}
+		return left.SummaryID < right.SummaryID
+	})
+	sort.Slice(condensed, func(i, j int) bool {
+		left := condensed[i]
+		right := condensed[j]
+		if left.Depth != right.Depth {
+			return left.Depth < right.Depth
+		}
+		if left.CreatedAt != right.CreatedAt {
+			return left.CreatedAt < right.CreatedAt
+		}
+		if left.CreatedSeq != right.CreatedSeq {
+			return left.CreatedSeq < right.CreatedSeq
+		}
+		return left.SummaryID < right.SummaryID
+	})
+
+	ordered = append(ordered, orphanLeaves...)
+	ordered = append(ordered, condensed...)
+
+	sort.Slice(summaries, func(i, j int) bool {
+		left := summaries[i]
+		right := summaries[j]
+		if left.Depth != right.Depth {
+			return left.Depth > right.Depth
+		}
+		if left.CreatedAt != right.CreatedAt {
+			return left.CreatedAt < right.CreatedAt
+		}
+		if left.CreatedSeq != right.CreatedSeq {
+			return left.CreatedSeq < right.CreatedSeq
+		}
+		return left.SummaryID < right.SummaryID
+	})
+
+	return RepairPlan{
+		Summaries:    summaries,
+		Ordered:      ordered,
+		LeafSequence: leafSeq,
+	}, nil
+}
+
+func loadCorruptedSummaries(ctx context.Context, q Queryer, conversationID int64, summaryID string, markers []string, tokens TokenRange) ([]RepairSummary, error) {
+	markerClause, markerArgs := CorruptedMarkerClause("s.content", markers)
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	seq := caps.SummarySeqExpr("s")
+	query := `
+		SELECT
+			s.summary_id,
+			s.conversation_id,
+			s.kind,
+			s.depth,
+			s.token_count,
+			s.content,
+			s.created_at,
+			` + seq + `,
+			COALESCE(spc.child_count, 0)
+		FROM summaries s
+		LEFT JOIN (
+			SELECT summary_id, COUNT(*) AS child_count
+			FROM summary_parents
+			GROUP BY summary_id
+		) spc ON spc.summary_id = s.summary_id
+		WHERE s.conversation_id = ?
+		  AND ` + markerClause + `
+	`
+	args := append([]any{conversationID}, markerArgs...)
+	if summaryID != "" {
+		query += " AND s.summary_id = ?"
+		args = append(args, summaryID)
+	}
+	tokenClause, tokenArgs := tokens.Clause("s.token_count")
+	query += tokenClause
+	args = append(args, tokenArgs...)
+	query += " ORDER BY s.depth DESC, s.created_at ASC, " + seq + " ASC, s.summary_id ASC"
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query corrupted summaries for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var summaries []RepairSummary
+	for rows.Next() {
+		var item RepairSummary
+		if err := rows.Scan(
+			&item.SummaryID,
+			&item.ConversationID,
+			&item.Kind,
+			&item.Depth,
+			&item.TokenCount,
+			&item.Content,
+			&item.CreatedAt,
+			&item.CreatedSeq,
+			&item.ChildCount,
+		); err != nil {
+			return nil, fmt.Errorf("scan corrupted summary row: %w", err)
+		}
+		item.Marker = MatchCorruptedSummaryMarker(item.Content, markers)
+		summaries = append(summaries, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate corrupted summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+func loadLeafSequence(ctx context.Context, q Queryer, conversationID int64, markers []string) ([]LeafSequenceEntry, error) {
+	markerClause, markerArgs := CorruptedMarkerClause("s.content", markers)
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			ci.ordinal,
+			ci.summary_id,
+			s.content,
+			CASE WHEN `+markerClause+` THEN 1 ELSE 0 END AS corrupted
+		FROM context_items ci
+		JOIN summaries s ON ci.summary_id = s.summary_id
+		WHERE ci.conversation_id = ?
+		  AND ci.item_type = 'summary'
+		  AND s.depth = 0
+		ORDER BY ci.ordinal ASC
+	`, append(markerArgs, conversationID)...)
+	if err != nil {
+		return nil, fmt.Errorf("query ordered leaves for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var items []LeafSequenceEntry
+	for rows.Next() {
+		var (
+			item         LeafSequenceEntry
+			corruptedInt int
+		)
+		if err := rows.Scan(&item.Ordinal, &item.SummaryID, &item.Content, &corruptedInt); err != nil {
+			return nil, fmt.Errorf("scan ordered leaf row: %w", err)
+		}
+		item.Corrupted = corruptedInt == 1
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ordered leaf rows: %w", err)
+	}
+	return items, nil
+}
+
+// SummaryExists reports whether conversationID has a summary summaryID.
+func SummaryExists(ctx context.Context, q Queryer, conversationID int64, summaryID string) (bool, error) {
+	var count int
+	if err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM summaries
+		WHERE conversation_id = ? AND summary_id = ?
+	`, conversationID, summaryID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check summary existence for %q: %w", summaryID, err)
+	}
+	return count > 0, nil
+}
+
+// Repair is one regenerated summary, ready to be written.
+type Repair struct {
+	Summary RepairSummary
+	Content string
+	Tokens  int
+	// Model is the model that produced Content, recorded in the audit log.
+	Model   string
+	Started time.Time
+}
+
+// RepairOptions configures ApplyRepairs.
+type RepairOptions struct {
+	// Generate regenerates item from the database read through q. pending
+	// holds content regenerated earlier in the run but not yet written,
+	// keyed by summary ID, so condensed sources and previous context see
+	// it; it is nil when each repair is written before the next is
+	// generated.
+	Generate func(ctx context.Context, q Queryer, item RepairSummary, pending map[string]string) (Repair, error)
+	// CommitEach commits every repair on its own, so a failure keeps the
+	// repairs before it.
+	CommitEach bool
+	// WriteMu, when set, stages the run for callers sharing the database
+	// across goroutines: every summary is regenerated outside a transaction
+	// and the repairs are written afterwards in one short transaction held
+	// under WriteMu (one per summary with CommitEach). A summary whose
+	// content changed since the plan was built fails the transaction rather
+	// than being overwritten.
+	WriteMu *sync.Mutex
+
+	// Progress, when set, is called before each summary is regenerated with
+	// its 1-based position in the plan.
+	Progress func(i, total int, item RepairSummary)
+	// Written, when set, is called for each repair once it is written:
+	// inside the open transaction for unstaged runs, after the commit for
+	// staged ones.
+	Written func(r Repair)
+	// RolledBack, when set, is called with the repairs passed to Written
+	// whose transaction a failure discarded.
+	RolledBack func(discarded []Repair)
+	// Stopped, when set, is called with the summary a failed run was
+	// working on.
+	Stopped func(item RepairSummary)
+}
+
+// ApplyRepairs regenerates every summary in plan.Ordered with opts.Generate
+// and writes the results with a "repair" audit entry. It returns how many
+// repairs were committed. Without CommitEach or WriteMu the whole plan is
+// one transaction, so each repair reads the ones before it.
+func ApplyRepairs(ctx context.Context, db TxBeginner, plan RepairPlan, opts RepairOptions) (int, error) {
+	if opts.Generate == nil {
+		return 0, errors.New("repair needs a Generate function")
+	}
+	if opts.WriteMu != nil {
+		return applyRepairsStaged(ctx, db, plan, opts)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin repair transaction: %w", err)
+	}
+
+	repaired := 0
+	var (
+		uncommitted []Repair
+		current     RepairSummary
+	)
+	rollbackNeeded := true
+	defer func() {
+		if rollbackNeeded {
+			_ = tx.Rollback()
+			if opts.RolledBack != nil && len(uncommitted) > 0 {
+				opts.RolledBack(uncommitted)
+			}
+			if opts.Stopped != nil {
+				opts.Stopped(current)
+			}
+		}
+	}()
+
+	for i, item := range plan.Ordered {
+		current = item
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(plan.Ordered), item)
+		}
+
+		result, err := opts.Generate(ctx, tx, item, nil)
+		if err != nil {
+			return repaired, err
+		}
+		result.Summary = item
+		if err := writeRepair(ctx, tx, result); err != nil {
+			return repaired, err
+		}
+		repaired++
+		uncommitted = append(uncommitted, result)
+		if opts.Written != nil {
+			opts.Written(result)
+		}
+
+		if opts.CommitEach {
+			if err := tx.Commit(); err != nil {
+				return repaired - 1, fmt.Errorf("commit repair of %s: %w", item.SummaryID, err)
+			}
+			uncommitted = uncommitted[:0]
+			if tx, err = db.BeginTx(ctx, nil); err != nil {
+				rollbackNeeded = false
+				if opts.Stopped != nil {
+					opts.Stopped(item)
+				}
+				return repaired, fmt.Errorf("begin repair transaction: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return repaired, fmt.Errorf("commit repair transaction: %w", err)
+	}
+	rollbackNeeded = false
+	return repaired, nil
+}
+
+// applyRepairsStaged is ApplyRepairs when opts.WriteMu is set. Every summary
+// is regenerated before anything is written, reading the DB outside a
+// transaction and feeding earlier results forward in memory, so no write
+// lock is held across API calls.
+func applyRepairsStaged(ctx context.Context, db TxBeginner, plan RepairPlan, opts RepairOptions) (int, error) {
+	repaired := 0
+	pending := make(map[string]string)
+	var staged []Repair
+
+	flush := func() error {
+		opts.WriteMu.Lock()
+		defer opts.WriteMu.Unlock()
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin repair transaction: %w", err)
+		}
+		rollback := true
+		defer func() {
+			if rollback {
+				_ = tx.Rollback()
+			}
+		}()
+		for _, result := range staged {
+			var current string
+			if err := tx.QueryRowContext(ctx, `
+				SELECT content FROM summaries WHERE summary_id = ?
+			`, result.Summary.SummaryID).Scan(&current); err != nil {
+				return fmt.Errorf("reload summary %s: %w", result.Summary.SummaryID, err)
+			}
+			if current != result.Summary.Content {
+				return fmt.Errorf("summary %s changed while it was being repaired; rerun to repair the current content", result.Summary.SummaryID)
+			}
+			if err := writeRepair(ctx, tx, result); err != nil {
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit repair transaction: %w", err)
+		}
+		rollback = false
+		if opts.Written != nil {
+			for _, result := range staged {
+				opts.Written(result)
+			}
+		}
+		repaired += len(staged)
+		staged = staged[:0]
+		return nil
+	}
+
+	stop := func(item RepairSummary) {
+		if opts.Stopped != nil {
+			opts.Stopped(item)
+		}
+	}
+	for i, item := range plan.Ordered {
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(plan.Ordered), item)
+		}
+		result, err := opts.Generate(ctx, db, item, pending)
+		if err != nil {
+			stop(item)
+			return repaired, err
+		}
+		result.Summary = item
+		pending[item.SummaryID] = result.Content
+		staged = append(staged, result)
+		if opts.CommitEach {
+			if err := flush(); err != nil {
+				stop(item)
+				return repaired, err
+			}
+		}
+	}
+	if len(staged) > 0 {
+		if err := flush(); err != nil {
+			return repaired, err
+		}
+	}
+	return repaired, nil
+}
+
+// writeRepair stores a regenerated summary and its audit entry.
+func writeRepair(ctx context.Context, q Queryer, r Repair) error {
+	if _, err := q.ExecContext(ctx, `
+		UPDATE summaries
+		SET content = ?, token_count = ?
+		WHERE summary_id = ?
+	`, r.Content, r.Tokens, r.Summary.SummaryID); err != nil {
+		return fmt.Errorf("update summary %s: %w", r.Summary.SummaryID, err)
+	}
+	return RecordAudit(ctx, q, AuditEntry{
+		Command: "repair", ConversationID: r.Summary.ConversationID, SummaryIDs: []string{r.Summary.SummaryID},
+		TokensBefore: r.Summary.TokenCount, TokensAfter: r.Tokens, Detail: repairAuditDetail(r.Summary.Marker, r.Model),
+	})
+}
+
+// repairAuditDetail notes the marker that flagged a summary and the model
+// that regenerated it.
+func repairAuditDetail(marker, model string) string {
+	var parts []string
+	if marker != "" {
+		parts = append(parts, fmt.Sprintf("marker %q", marker))
+	}
+	if model != "" {
+		parts = append(parts, "model "+model)
+	}
+	return strings.Join(parts, "; ")
+}
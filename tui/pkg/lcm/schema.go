@@ -0,0 +1,153 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// SchemaCapabilities records which tables and columns an LCM database has, so
+// callers can degrade predictably on older schemas instead of failing on
+// "no such column". Every table and column is recorded.
+type SchemaCapabilities struct {
+	UserVersion    int
+	MigrationSteps int
+	tables         map[string]bool
+	columns        map[string]bool
+}
+
+// HasTable reports whether the database has a table (or virtual table) name.
+func (c SchemaCapabilities) HasTable(name string) bool {
+	return c.tables[name]
+}
+
+// HasColumn reports whether table has column. Columns of virtual tables are
+// not recorded.
+func (c SchemaCapabilities) HasColumn(table, column string) bool {
+	return c.columns[table+"."+column]
+}
+
+// ProbeSchemaCapabilities reads PRAGMA user_version and every table and
+// column in one pass. Most callers want SchemaCapabilitiesOf, which caches
+// the result.
+func ProbeSchemaCapabilities(ctx context.Context, q Queryer) (SchemaCapabilities, error) {
+	caps := SchemaCapabilities{
+		tables:  make(map[string]bool),
+		columns: make(map[string]bool),
+	}
+	if err := q.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&caps.UserVersion); err != nil {
+		return SchemaCapabilities{}, fmt.Errorf("read schema user_version: %w", err)
+	}
+	// Virtual tables are recorded by name only: reading their columns needs
+	// the module (e.g. fts5), which this build may lack.
+	rows, err := q.QueryContext(ctx, `
+		SELECT m.name, COALESCE(p.name, '')
+		FROM sqlite_master m
+		LEFT JOIN pragma_table_info(
+			CASE WHEN COALESCE(m.sql, '') LIKE 'CREATE VIRTUAL TABLE%' THEN '' ELSE m.name END
+		) p
+		WHERE m.type = 'table'
+	`)
+	if err != nil {
+		return SchemaCapabilities{}, fmt.Errorf("read schema tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return SchemaCapabilities{}, fmt.Errorf("scan schema column: %w", err)
+		}
+		caps.tables[table] = true
+		if column != "" {
+			caps.columns[table+"."+column] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return SchemaCapabilities{}, fmt.Errorf("iterate schema columns: %w", err)
+	}
+	if caps.tables["lcm_migration_state"] {
+		if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM lcm_migration_state`).Scan(&caps.MigrationSteps); err != nil {
+			return SchemaCapabilities{}, fmt.Errorf("count migration steps: %w", err)
+		}
+	}
+	return caps, nil
+}
+
+// schemaCache holds one probe per open *sql.DB. Keys are weak so the cache
+// does not keep closed handles alive; a cleanup drops the entry once the
+// handle is collected.
+var schemaCache = struct {
+	sync.Mutex
+	byDB map[weak.Pointer[sql.DB]]SchemaCapabilities
+}{byDB: make(map[weak.Pointer[sql.DB]]SchemaCapabilities)}
+
+// SchemaCapabilitiesOf returns the capabilities of the database behind q. A
+// *sql.DB is probed once and cached. Any other Queryer, such as a
+// transaction, is probed through itself so it sees tables it created before
+// committing.
+func SchemaCapabilitiesOf(ctx context.Context, q Queryer) (SchemaCapabilities, error) {
+	db, ok := q.(*sql.DB)
+	if !ok {
+		return ProbeSchemaCapabilities(ctx, q)
+	}
+	key := weak.Make(db)
+	schemaCache.Lock()
+	caps, cached := schemaCache.byDB[key]
+	schemaCache.Unlock()
+	if cached {
+		return caps, nil
+	}
+
+	caps, err := ProbeSchemaCapabilities(ctx, db)
+	if err != nil {
+		return SchemaCapabilities{}, err
+	}
+	schemaCache.Lock()
+	defer schemaCache.Unlock()
+	if _, cached := schemaCache.byDB[key]; !cached {
+		schemaCache.byDB[key] = caps
+		runtime.AddCleanup(db, forgetSchemaCapabilitiesOf, key)
+	}
+	return caps, nil
+}
+
+func forgetSchemaCapabilitiesOf(key weak.Pointer[sql.DB]) {
+	schemaCache.Lock()
+	defer schemaCache.Unlock()
+	delete(schemaCache.byDB, key)
+}
+
+// ForgetSchemaCapabilities drops every cached probe. Call it after creating
+// or altering a table so later checks see the change.
+func ForgetSchemaCapabilities() {
+	schemaCache.Lock()
+	defer schemaCache.Unlock()
+	clear(schemaCache.byDB)
+}
+
+// SummarySeqExpr returns the SQL expression that orders summaries created in
+// the same created_at second: the created_seq column, or rowid on databases
+// that predate it. alias qualifies the column, e.g. "s"; "" leaves it bare.
+func (c SchemaCapabilities) SummarySeqExpr(alias string) string {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	if c.HasColumn("summaries", "created_seq") {
+		return prefix + "created_seq"
+	}
+	return prefix + "rowid"
+}
+
+// NextSummarySeq returns the column and value fragments that give a new
+// summaries row the next created_seq, e.g. appended to an INSERT's column and
+// VALUES lists. Both are empty on databases without the column.
+func (c SchemaCapabilities) NextSummarySeq() (column, value string) {
+	if !c.HasColumn("summaries", "created_seq") {
+		return "", ""
+	}
+	return ", created_seq", ", (SELECT COALESCE(MAX(created_seq), 0) + 1 FROM summaries)"
+}
@@ -0,0 +1,650 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// SummarySource is the material a summary is regenerated from, rendered as
+// prompt source text.
+type SummarySource struct {
+	Text            string
+	ItemCount       int
+	EstimatedTokens int
+	// TimeRange spans the source's messages; rewrite sources only.
+	TimeRange string
+	// Label names what ItemCount counts, e.g. "messages".
+	Label string
+	// FreshCount is how many lines carry the [most recent] marker.
+	FreshCount int
+}
+
+// Redact applies r to the source text and re-estimates its tokens. A nil r
+// leaves the source unchanged.
+func (s *SummarySource) Redact(r Redactor) RedactionCounts {
+	text, counts := redact(r, s.Text)
+	if counts.Total() > 0 {
+		s.Text = text
+		s.EstimatedTokens = EstimateTokenCount(text)
+	}
+	return counts
+}
+
+// BuildRepairSource reconstructs item's source for repair: a leaf's linked
+// messages, or a condensed summary's children. A child in pending, keyed by
+// summary ID, contributes that content instead of the stored one.
+func BuildRepairSource(ctx context.Context, q Queryer, item RepairSummary, pending map[string]string) (SummarySource, error) {
+	if item.IsLeaf() {
+		return buildLeafRepairSource(ctx, q, item.SummaryID)
+	}
+	return buildCondensedRepairSource(ctx, q, item.SummaryID, pending)
+}
+
+// RewriteSourceOptions shapes the source BuildRewriteSource renders.
+type RewriteSourceOptions struct {
+	// Timestamps prefixes messages and child summaries with their times,
+	// rendered in Location (local time when nil).
+	Timestamps bool
+	Location   *time.Location
+	// FreshTail marks the last N leaf messages as most recent; it is
+	// ignored for condensed summaries.
+	FreshTail int
+	// Deep rebuilds a condensed summary from the raw messages under every
+	// leaf it covers instead of its children's text.
+	Deep bool
+}
+
+// BuildRewriteSource reconstructs the prompt source for summaryID; leaf
+// selects the leaf or condensed layout.
+func BuildRewriteSource(ctx context.Context, q Queryer, summaryID string, leaf bool, opts RewriteSourceOptions) (SummarySource, error) {
+	switch {
+	case leaf:
+		return buildLeafRewriteSource(ctx, q, summaryID, opts.Timestamps, opts.Location, opts.FreshTail)
+	case opts.Deep:
+		return buildDeepCondensedRewriteSource(ctx, q, summaryID, opts.Timestamps, opts.Location)
+	default:
+		return buildCondensedRewriteSource(ctx, q, summaryID, opts.Timestamps, opts.Location)
+	}
+}
+
+// leafTimeRange is the formatted message time span under a summary.
+type leafTimeRange struct {
+	earliest string
+	latest   string
+	valid    bool
+}
+
+// leafSourceMessage is one message under a leaf summary, with its body
+// assembled from curated message parts.
+type leafSourceMessage struct {
+	role      string
+	body      string
+	createdAt string
+}
+
+// loadLeafSourceMessages reads the messages linked to a leaf summary in
+// ordinal order. Bodies are assembled from message_parts, skipping ignored and
+// synthetic parts, and fall back to messages.content when no part has text.
+// File and image parts become a textual stand-in with the file's name, MIME
+// type, and exploration summary from large_files when one can be linked.
+// Repair and rewrite both build leaf sources from it so they see the same
+// material.
+func loadLeafSourceMessages(ctx context.Context, q Queryer, summaryID string) ([]leafSourceMessage, error) {
+	explorationExpr, err := leafFileExplorationSummaryExpr(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			sm.ordinal,
+			m.message_id,
+			m.role,
+			COALESCE(m.content, ''),
+			COALESCE(m.created_at, ''),
+			mp.ordinal,
+			mp.part_type,
+			mp.text_content,
+			mp.tool_input,
+			mp.tool_output,
+			mp.file_name,
+			mp.file_mime,
+			mp.file_url,
+			mp.patch_hash,
+			mp.patch_files,
+			mp.subtask_prompt,
+			mp.subtask_desc,
+			mp.subtask_agent,
+			mp.step_reason,
+			mp.step_cost,
+			mp.step_tokens_in,
+			mp.step_tokens_out,
+			mp.snapshot_hash,
+			`+explorationExpr+`
+		FROM summary_messages sm
+		JOIN messages m ON m.message_id = sm.message_id
+		LEFT JOIN message_parts mp
+			ON mp.message_id = m.message_id
+			AND COALESCE(mp.is_ignored, 0) = 0
+			AND COALESCE(mp.is_synthetic, 0) = 0
+		WHERE sm.summary_id = ?
+		ORDER BY sm.ordinal ASC, mp.ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("query summary messages for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+
+	type messageChunk struct {
+		role      string
+		fallback  string
+		createdAt string
+		parts     []string
+	}
+
+	var (
+		messages         []leafSourceMessage
+		currentMessageID int64
+		active           bool
+		current          messageChunk
+	)
+
+	flushCurrent := func() {
+		if !active {
+			return
+		}
+		role := strings.TrimSpace(current.role)
+		if role == "" {
+			role = "unknown"
+		}
+		body := strings.TrimSpace(strings.Join(current.parts, "\n"))
+		if body == "" {
+			body = strings.TrimSpace(current.fallback)
+		}
+		if body == "" {
+			body = "(empty)"
+		}
+		messages = append(messages, leafSourceMessage{role: role, body: body, createdAt: current.createdAt})
+	}
+
+	for rows.Next() {
+		var (
+			summaryOrdinal int64
+			messageID      int64
+			role           string
+			content        string
+			createdAt      string
+			partOrdinal    sql.NullInt64
+			partType       sql.NullString
+			partTextValue  sql.NullString
+			toolInput      sql.NullString
+			toolOutput     sql.NullString
+			fileName       sql.NullString
+			fileMime       sql.NullString
+			fileURL        sql.NullString
+			patchHash      sql.NullString
+			patchFiles     sql.NullString
+			subtaskPrompt  sql.NullString
+			subtaskDesc    sql.NullString
+			subtaskAgent   sql.NullString
+			stepReason     sql.NullString
+			stepCost       sql.NullFloat64
+			stepTokensIn   sql.NullInt64
+			stepTokensOut  sql.NullInt64
+			snapshotHash   sql.NullString
+			fileSummary    sql.NullString
+		)
+		if err := rows.Scan(&summaryOrdinal, &messageID, &role, &content, &createdAt, &partOrdinal, &partType, &partTextValue, &toolInput, &toolOutput,
+			&fileName, &fileMime, &fileURL, &patchHash, &patchFiles, &subtaskPrompt, &subtaskDesc, &subtaskAgent,
+			&stepReason, &stepCost, &stepTokensIn, &stepTokensOut, &snapshotHash, &fileSummary); err != nil {
+			return nil, fmt.Errorf("scan summary message row: %w", err)
+		}
+
+		if !active || currentMessageID != messageID {
+			flushCurrent()
+			currentMessageID = messageID
+			current = messageChunk{role: role, fallback: content, createdAt: createdAt}
+			active = true
+		}
+
+		// Mirror messageDisplayContentSQL: text first, then labeled tool I/O.
+		partText := strings.TrimSpace(partTextValue.String)
+		if input := strings.TrimSpace(toolInput.String); input != "" {
+			partText += "\nTool input: " + input
+		}
+		if output := strings.TrimSpace(toolOutput.String); output != "" {
+			partText += "\nTool output: " + output
+		}
+		if standIn := formatLeafFilePart(partType.String, fileName.String, fileMime.String, fileURL.String, fileSummary.String); standIn != "" {
+			partText += "\n" + standIn
+		}
+		structured := StructuredPart{
+			Kind:          strings.TrimSpace(partType.String),
+			PatchFiles:    ParsePatchFiles(patchFiles.String),
+			PatchHash:     patchHash.String,
+			SubtaskPrompt: subtaskPrompt.String,
+			SubtaskDesc:   subtaskDesc.String,
+			SubtaskAgent:  subtaskAgent.String,
+			StepReason:    stepReason.String,
+			StepCost:      stepCost.Float64,
+			StepTokensIn:  stepTokensIn.Int64,
+			StepTokensOut: stepTokensOut.Int64,
+			SnapshotHash:  snapshotHash.String,
+		}
+		if text := structured.Format(); text != "" {
+			partText += "\n" + text
+		}
+		if partText = strings.TrimSpace(partText); partText != "" {
+			current.parts = append(current.parts, partText)
+			continue
+		}
+
+		kind := strings.TrimSpace(partType.String)
+		if kind == "toolCall" || kind == "toolResult" || kind == "tool_call" || kind == "tool_result" {
+			current.parts = append(current.parts, "["+kind+"]")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate summary message rows: %w", err)
+	}
+	flushCurrent()
+	return messages, nil
+}
+
+// leafFileExplorationSummaryExpr returns the SELECT expression that links a
+// file part to its large_files exploration summary by storage URI or file ID.
+// Databases without large_files get a NULL column.
+func leafFileExplorationSummaryExpr(ctx context.Context, q Queryer) (string, error) {
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return "", fmt.Errorf("check large_files table: %w", err)
+	}
+	if !caps.HasTable("large_files") {
+		return "NULL", nil
+	}
+	return `(
+			SELECT lf.exploration_summary
+			FROM large_files lf
+			WHERE lf.conversation_id = m.conversation_id
+			  AND COALESCE(mp.file_url, '') != ''
+			  AND (lf.storage_uri = mp.file_url OR instr(mp.file_url, lf.file_id) > 0)
+			ORDER BY lf.created_at ASC
+			LIMIT 1
+		)`, nil
+}
+
+// formatLeafFilePart renders a file or image part as a one-line stand-in so
+// summarizers know an attachment was present and, when explored, what it held.
+// It returns "" for parts without file metadata.
+func formatLeafFilePart(partType, fileName, fileMime, fileURL, explorationSummary string) string {
+	partType = strings.TrimSpace(partType)
+	fileName = strings.TrimSpace(fileName)
+	fileMime = strings.TrimSpace(fileMime)
+	fileURL = strings.TrimSpace(fileURL)
+	isFilePart := partType == "file" || partType == "image"
+	if !isFilePart && fileName == "" && fileMime == "" && fileURL == "" {
+		return ""
+	}
+
+	label := "file"
+	if partType == "image" || strings.HasPrefix(fileMime, "image/") {
+		label = "image"
+	}
+	name := fileName
+	if name == "" && fileURL != "" && !strings.HasPrefix(fileURL, "data:") {
+		name = path.Base(fileURL)
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "unnamed"
+	}
+	standIn := "[" + label + ": " + name
+	if fileMime != "" {
+		standIn += " (" + fileMime + ")"
+	}
+	standIn += "]"
+	if summary := strings.TrimSpace(explorationSummary); summary != "" {
+		standIn += "\nFile summary: " + summary
+	}
+	return standIn
+}
+
+// buildLeafRepairSource reconstructs a summary's source segment from linked messages and parts.
+func buildLeafRepairSource(ctx context.Context, q Queryer, summaryID string) (SummarySource, error) {
+	messages, err := loadLeafSourceMessages(ctx, q, summaryID)
+	if err != nil {
+		return SummarySource{}, err
+	}
+	if len(messages) == 0 {
+		return SummarySource{}, fmt.Errorf("no source messages linked to summary %s", summaryID)
+	}
+
+	lines := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		lines = append(lines, fmt.Sprintf("[%s] %s", msg.role, msg.body))
+	}
+	text := strings.Join(lines, "\n")
+	return SummarySource{
+		Text:            text,
+		ItemCount:       len(lines),
+		EstimatedTokens: EstimateTokenCount(text),
+		Label:           "messages",
+	}, nil
+}
+
+// buildCondensedRepairSource joins a condensed summary's children. A child
+// in pending contributes that content instead of the stored one.
+func buildCondensedRepairSource(ctx context.Context, q Queryer, summaryID string, pending map[string]string) (SummarySource, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sp.parent_summary_id, s.content
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.parent_summary_id
+		WHERE sp.summary_id = ?
+		ORDER BY sp.ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return SummarySource{}, fmt.Errorf("query child summaries for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+
+	var parts []string
+	for rows.Next() {
+		var childID string
+		var content string
+		if err := rows.Scan(&childID, &content); err != nil {
+			return SummarySource{}, fmt.Errorf("scan child summary row: %w", err)
+		}
+		if replacement, ok := pending[childID]; ok {
+			content = replacement
+		}
+		content = strings.TrimSpace(content)
+		if content == "" {
+			continue
+		}
+		parts = append(parts, content)
+	}
+	if err := rows.Err(); err != nil {
+		return SummarySource{}, fmt.Errorf("iterate child summary rows: %w", err)
+	}
+	if len(parts) == 0 {
+		return SummarySource{}, fmt.Errorf("no child summaries linked to %s", summaryID)
+	}
+
+	text := strings.Join(parts, "\n\n")
+	return SummarySource{
+		Text:            text,
+		ItemCount:       len(parts),
+		EstimatedTokens: EstimateTokenCount(text),
+		Label:           "child summaries",
+	}, nil
+}
+
+// buildLeafRewriteSource assembles a leaf summary's messages the same way
+// repair does, so ignored and synthetic parts never reach the prompt.
+func buildLeafRewriteSource(ctx context.Context, q Queryer, summaryID string, includeTimestamps bool, loc *time.Location, freshTail int) (SummarySource, error) {
+	messages, err := loadLeafSourceMessages(ctx, q, summaryID)
+	if err != nil {
+		return SummarySource{}, err
+	}
+
+	parts := make([]string, 0, len(messages))
+	var earliest, latest string
+	for _, msg := range messages {
+		formattedTime := FormatTimestamp(msg.createdAt, loc)
+		if formattedTime != "" {
+			if earliest == "" || formattedTime < earliest {
+				earliest = formattedTime
+			}
+			if latest == "" || formattedTime > latest {
+				latest = formattedTime
+			}
+		}
+		if includeTimestamps && formattedTime != "" {
+			parts = append(parts, fmt.Sprintf("[%s] [%s] %s", formattedTime, msg.role, msg.body))
+		} else {
+			parts = append(parts, fmt.Sprintf("[%s] %s", msg.role, msg.body))
+		}
+	}
+	if len(parts) == 0 {
+		return SummarySource{}, fmt.Errorf("no messages linked to summary %s", summaryID)
+	}
+	freshCount := markFreshTailParts(parts, freshTail)
+
+	text := strings.Join(parts, "\n")
+	return SummarySource{
+		Text:            text,
+		ItemCount:       len(parts),
+		EstimatedTokens: EstimateTokenCount(text),
+		TimeRange:       FormatTimeRange(earliest, latest),
+		Label:           "messages",
+		FreshCount:      freshCount,
+	}, nil
+}
+
+// markFreshTailParts prefixes the last freshTail source lines with a
+// [most recent] marker, mirroring the fresh-tail window used by compaction.
+// It returns the number of lines marked.
+func markFreshTailParts(parts []string, freshTail int) int {
+	if freshTail <= 0 {
+		return 0
+	}
+	count := freshTail
+	if count > len(parts) {
+		count = len(parts)
+	}
+	for i := len(parts) - count; i < len(parts); i++ {
+		parts[i] = "[most recent] " + parts[i]
+	}
+	return count
+}
+
+func buildCondensedRewriteSource(ctx context.Context, q Queryer, summaryID string, includeTimestamps bool, loc *time.Location) (SummarySource, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sp.parent_summary_id, COALESCE(s.content, '')
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.parent_summary_id
+		WHERE sp.summary_id = ?
+		ORDER BY sp.ordinal ASC
+	`, summaryID)
+	if err != nil {
+		return SummarySource{}, fmt.Errorf("query condensed source for %s: %w", summaryID, err)
+	}
+	defer rows.Close()
+
+	parts := make([]string, 0, 32)
+	var minRange, maxRange string
+	for rows.Next() {
+		var childID, content string
+		if err := rows.Scan(&childID, &content); err != nil {
+			return SummarySource{}, fmt.Errorf("scan condensed source row: %w", err)
+		}
+		content = strings.TrimSpace(content)
+		if content == "" {
+			continue
+		}
+		timeRange, err := summaryLeafTimeRange(ctx, q, childID, loc)
+		if err != nil {
+			return SummarySource{}, fmt.Errorf("derive time range for child %s: %w", childID, err)
+		}
+		if timeRange.valid {
+			if minRange == "" || timeRange.earliest < minRange {
+				minRange = timeRange.earliest
+			}
+			if maxRange == "" || timeRange.latest > maxRange {
+				maxRange = timeRange.latest
+			}
+		}
+		if includeTimestamps && timeRange.valid {
+			header := fmt.Sprintf("[%s]", FormatTimeRange(timeRange.earliest, timeRange.latest))
+			parts = append(parts, header+"\n"+content)
+			continue
+		}
+		parts = append(parts, content)
+	}
+	if err := rows.Err(); err != nil {
+		return SummarySource{}, fmt.Errorf("iterate condensed source rows: %w", err)
+	}
+	if len(parts) == 0 {
+		return SummarySource{}, fmt.Errorf("no child summaries linked to %s", summaryID)
+	}
+
+	text := strings.Join(parts, "\n\n")
+	return SummarySource{
+		Text:            text,
+		ItemCount:       len(parts),
+		EstimatedTokens: EstimateTokenCount(text),
+		TimeRange:       FormatTimeRange(minRange, maxRange),
+		Label:           "child summaries",
+	}, nil
+}
+
+// collectLeafSummaries walks summary_parents from summaryID down to leaf
+// summaries in source order. A leaf reachable by more than one path is
+// listed once, at its first position.
+func collectLeafSummaries(ctx context.Context, q Queryer, summaryID string) ([]string, error) {
+	var leaves []string
+	seen := make(map[string]bool)
+	var walk func(id string) error
+	walk = func(id string) error {
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+
+		rows, err := q.QueryContext(ctx, `
+			SELECT sp.parent_summary_id
+			FROM summary_parents sp
+			WHERE sp.summary_id = ?
+			ORDER BY sp.ordinal ASC
+		`, id)
+		if err != nil {
+			return fmt.Errorf("query sources for %s: %w", id, err)
+		}
+		var children []string
+		for rows.Next() {
+			var childID string
+			if err := rows.Scan(&childID); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan source row: %w", err)
+			}
+			children = append(children, childID)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("iterate sources for %s: %w", id, err)
+		}
+
+		if len(children) == 0 {
+			if id != summaryID {
+				leaves = append(leaves, id)
+			}
+			return nil
+		}
+		for _, childID := range children {
+			if err := walk(childID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(summaryID); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// buildDeepCondensedRewriteSource rebuilds a condensed summary's source from
+// the raw messages under every leaf it covers instead of its child summaries'
+// already-lossy text. Each leaf's messages are grouped under its time range.
+func buildDeepCondensedRewriteSource(ctx context.Context, q Queryer, summaryID string, includeTimestamps bool, loc *time.Location) (SummarySource, error) {
+	leaves, err := collectLeafSummaries(ctx, q, summaryID)
+	if err != nil {
+		return SummarySource{}, err
+	}
+	if len(leaves) == 0 {
+		return SummarySource{}, fmt.Errorf("no leaf summaries under %s", summaryID)
+	}
+
+	blocks := make([]string, 0, len(leaves))
+	messages := 0
+	var minRange, maxRange string
+	for _, leafID := range leaves {
+		leaf, err := buildLeafRewriteSource(ctx, q, leafID, includeTimestamps, loc, 0)
+		if err != nil {
+			return SummarySource{}, fmt.Errorf("deep source for leaf %s: %w", leafID, err)
+		}
+		messages += leaf.ItemCount
+		timeRange, err := summaryLeafTimeRange(ctx, q, leafID, loc)
+		if err != nil {
+			return SummarySource{}, fmt.Errorf("derive time range for leaf %s: %w", leafID, err)
+		}
+		if !timeRange.valid {
+			blocks = append(blocks, leaf.Text)
+			continue
+		}
+		if minRange == "" || timeRange.earliest < minRange {
+			minRange = timeRange.earliest
+		}
+		if maxRange == "" || timeRange.latest > maxRange {
+			maxRange = timeRange.latest
+		}
+		blocks = append(blocks, fmt.Sprintf("[%s]\n%s", FormatTimeRange(timeRange.earliest, timeRange.latest), leaf.Text))
+	}
+
+	text := strings.Join(blocks, "\n\n")
+	return SummarySource{
+		Text:            text,
+		ItemCount:       messages,
+		EstimatedTokens: EstimateTokenCount(text),
+		TimeRange:       FormatTimeRange(minRange, maxRange),
+		Label:           fmt.Sprintf("messages from %d leaves", len(leaves)),
+	}, nil
+}
+
+// summaryLeafTimeRange is SummaryLeafTimeRange formatted in loc; it is not
+// valid when either end is missing.
+func summaryLeafTimeRange(ctx context.Context, q Queryer, summaryID string, loc *time.Location) (leafTimeRange, error) {
+	earliest, latest, err := SummaryLeafTimeRange(ctx, q, summaryID)
+	if err != nil {
+		return leafTimeRange{}, err
+	}
+	e := FormatTimestamp(earliest, loc)
+	l := FormatTimestamp(latest, loc)
+	if e == "" || l == "" {
+		return leafTimeRange{}, nil
+	}
+	return leafTimeRange{earliest: e, latest: l, valid: true}, nil
+}
+
+// SummaryLeafTimeRange returns the unformatted MIN/MAX message
+// created_at across the leaves under summaryID, in the form earliest_at and
+// latest_at store. Both are "" when no leaf messages are linked.
+func SummaryLeafTimeRange(ctx context.Context, q Queryer, summaryID string) (string, string, error) {
+	var earliest, latest sql.NullString
+	err := q.QueryRowContext(ctx, `
+		WITH RECURSIVE walk(summary_id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT sp.parent_summary_id
+			FROM summary_parents sp
+			JOIN walk w ON w.summary_id = sp.summary_id
+		)
+		SELECT MIN(m.created_at), MAX(m.created_at)
+		FROM walk w
+		JOIN summaries s ON s.summary_id = w.summary_id
+		JOIN summary_messages sm ON sm.summary_id = s.summary_id
+		JOIN messages m ON m.message_id = sm.message_id
+		WHERE COALESCE(s.depth, 0) = 0
+	`, summaryID).Scan(&earliest, &latest)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("query summary time range: %w", err)
+	}
+	return strings.TrimSpace(earliest.String), strings.TrimSpace(latest.String), nil
+}
@@ -0,0 +1,136 @@
+package lcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StructuredPart holds the fields of the block types that carry structured
+// data instead of text. They mirror the message_parts columns the plugin
+// stores for each type, so a session JSONL block and its DB part render the
+// same way in the conversation viewer and in leaf rewrite sources.
+type StructuredPart struct {
+	Kind          string
+	PatchFiles    []string
+	PatchHash     string
+	SubtaskPrompt string
+	SubtaskDesc   string
+	SubtaskAgent  string
+	StepReason    string
+	StepCost      float64
+	StepTokensIn  int64
+	StepTokensOut int64
+	SnapshotHash  string
+}
+
+// structuredPartFormatters maps a block or part type to its text rendering.
+// Types are listed under both their JSONL spelling and the part_type the
+// plugin stores. A formatter returns "" when the part has nothing to show.
+var structuredPartFormatters = map[string]func(StructuredPart) string{
+	"patch":       formatPatchPart,
+	"subtask":     formatSubtaskPart,
+	"step-finish": formatStepFinishPart,
+	"step_finish": formatStepFinishPart,
+	"snapshot":    formatSnapshotPart,
+}
+
+// Format renders p with its type's formatter; "" when the type has none.
+func (p StructuredPart) Format() string {
+	formatter, ok := structuredPartFormatters[p.Kind]
+	if !ok {
+		return ""
+	}
+	return formatter(p)
+}
+
+// formatPatchPart lists the files a patch touched, e.g.
+// "[patch a1b2c3d] src/a.go, src/b.go".
+func formatPatchPart(p StructuredPart) string {
+	if strings.TrimSpace(p.PatchHash) == "" && len(p.PatchFiles) == 0 {
+		return ""
+	}
+	label := "[patch"
+	if hash := strings.TrimSpace(p.PatchHash); hash != "" {
+		label += " " + ShortPartHash(hash)
+	}
+	label += "]"
+	files := make([]string, 0, len(p.PatchFiles))
+	for _, file := range p.PatchFiles {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return label
+	}
+	return label + " " + strings.Join(files, ", ")
+}
+
+// formatSubtaskPart shows the delegated agent and description, then the
+// prompt it was given.
+func formatSubtaskPart(p StructuredPart) string {
+	if strings.TrimSpace(p.SubtaskAgent+p.SubtaskDesc+p.SubtaskPrompt) == "" {
+		return ""
+	}
+	label := "[subtask]"
+	if agent := strings.TrimSpace(p.SubtaskAgent); agent != "" {
+		label = "[subtask: " + agent + "]"
+	}
+	if desc := strings.TrimSpace(p.SubtaskDesc); desc != "" {
+		label += " " + desc
+	}
+	if prompt := strings.TrimSpace(p.SubtaskPrompt); prompt != "" {
+		label += "\nPrompt: " + prompt
+	}
+	return label
+}
+
+// formatStepFinishPart summarizes why a step ended and what it cost, e.g.
+// "[step finish: stop, 1200 in / 300 out tokens, $0.0123]".
+func formatStepFinishPart(p StructuredPart) string {
+	var details []string
+	if reason := strings.TrimSpace(p.StepReason); reason != "" {
+		details = append(details, reason)
+	}
+	if p.StepTokensIn > 0 || p.StepTokensOut > 0 {
+		details = append(details, fmt.Sprintf("%d in / %d out tokens", p.StepTokensIn, p.StepTokensOut))
+	}
+	if p.StepCost > 0 {
+		details = append(details, fmt.Sprintf("$%.4f", p.StepCost))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return "[step finish: " + strings.Join(details, ", ") + "]"
+}
+
+// formatSnapshotPart names the workspace snapshot a part points at.
+func formatSnapshotPart(p StructuredPart) string {
+	if hash := strings.TrimSpace(p.SnapshotHash); hash != "" {
+		return "[snapshot " + ShortPartHash(hash) + "]"
+	}
+	return ""
+}
+
+// ShortPartHash abbreviates a content hash the way git does.
+func ShortPartHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// ParsePatchFiles reads message_parts.patch_files, a JSON array of paths.
+// Anything else is kept as a single entry so no file name is lost.
+func ParsePatchFiles(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	var files []string
+	if err := json.Unmarshal([]byte(raw), &files); err == nil {
+		return files
+	}
+	return []string{raw}
+}
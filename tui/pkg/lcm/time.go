@@ -0,0 +1,87 @@
+package lcm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatTimestamp renders a stored timestamp as "2006-01-02 15:04 MST" in
+// loc, or time.Local when loc is nil. Unparseable values render as "".
+func FormatTimestamp(raw string, loc *time.Location) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return ""
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if parsed, err := ParseSQLiteTime(trimmed); err == nil {
+		t := parsed.In(loc)
+		zone := t.Format("MST")
+		return t.Format("2006-01-02 15:04 ") + zone
+	}
+	return ""
+}
+
+// ParseSQLiteTime parses the timestamp layouts SQLite and the plugin write.
+func ParseSQLiteTime(raw string) (time.Time, error) {
+	layouts := []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04:05.000",
+		"2006-01-02 15:04:05.000000",
+		"2006-01-02T15:04:05Z",
+		"2006-01-02T15:04:05.000Z",
+		"2006-01-02T15:04:05.000000Z",
+		"2006-01-02T15:04:05-07:00",
+		"2006-01-02T15:04:05.000-07:00",
+		"2006-01-02T15:04:05.000000-07:00",
+	}
+	for _, layout := range layouts {
+		parsed, parseErr := timeParse(layout, raw)
+		if parseErr == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format %q", raw)
+}
+
+// FormatTimeRange joins two formatted timestamps as "earliest - latest",
+// collapsing empty or equal ends.
+func FormatTimeRange(earliest, latest string) string {
+	e := strings.TrimSpace(earliest)
+	l := strings.TrimSpace(latest)
+	if e == "" && l == "" {
+		return ""
+	}
+	if l == "" {
+		return e
+	}
+	if e == "" {
+		return l
+	}
+	if e == l {
+		return e
+	}
+	return e + " - " + l
+}
+
+// timeParse exists to keep timestamp parsing logic testable and isolated.
+func timeParse(layout, value string) (time.Time, error) {
+	return time.Parse(layout, value)
+}
+
+// ParseContextItemTime parses an RFC 3339 or SQLite datetime timestamp.
+func ParseContextItemTime(value string) (time.Time, bool) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return time.Time{}, false
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, trimmed); err == nil {
+		return parsed, true
+	}
+	if parsed, err := time.Parse("2006-01-02 15:04:05", trimmed); err == nil {
+		return parsed, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,773 @@
+package lcm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TransplantDuplicate is a source summary whose content hash already appears
+// in the target conversation.
+type TransplantDuplicate struct {
+	SummaryID   string
+	ContentHash string
+	TargetCount int
+}
+
+// TransplantPlan is what BuildTransplantPlan found: the source context, the
+// full DAG in copy order, and the target state it will be merged into.
+type TransplantPlan struct {
+	SourceConversationID int64
+	TargetConversationID int64
+	SourceContext        []ContextSummary
+	Ordered              []Summary
+	DepthCounts          map[int]int
+	TargetContext        ContextStats
+	ContextTokenOverhead int
+	Duplicates           []TransplantDuplicate
+
+	// KeepMessages links source messages to target messages with the same
+	// identity hash instead of deep-copying them (transplant --keep-messages).
+	KeepMessages bool
+}
+
+// BuildTransplantPlan gathers source context summaries, recursively resolves the
+// full parent DAG, and computes a deterministic copy order (d0 -> dN).
+func BuildTransplantPlan(ctx context.Context, q Queryer, sourceConversationID, targetConversationID int64) (TransplantPlan, error) {
+	if sourceConversationID == targetConversationID {
+		return TransplantPlan{}, errors.New("source and target conversation IDs must be different")
+	}
+
+	sourceExists, err := ConversationExists(ctx, q, sourceConversationID)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+	if !sourceExists {
+		return TransplantPlan{}, fmt.Errorf("source conversation %d not found", sourceConversationID)
+	}
+
+	targetExists, err := ConversationExists(ctx, q, targetConversationID)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+	if !targetExists {
+		return TransplantPlan{}, fmt.Errorf("target conversation %d not found", targetConversationID)
+	}
+
+	sourceContext, err := loadContextSummaries(ctx, q, sourceConversationID)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+	if len(sourceContext) == 0 {
+		return TransplantPlan{
+			SourceConversationID: sourceConversationID,
+			TargetConversationID: targetConversationID,
+		}, nil
+	}
+
+	rootIDs := make([]string, 0, len(sourceContext))
+	contextTokenOverhead := 0
+	for _, item := range sourceContext {
+		rootIDs = append(rootIDs, item.SummaryID)
+		contextTokenOverhead += item.TokenCount
+	}
+
+	allSummaryIDs, err := SummaryDAGIDs(ctx, q, rootIDs)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+	allSummaries, err := LoadSummaries(ctx, q, allSummaryIDs)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+
+	for _, summary := range allSummaries {
+		if summary.ConversationID != sourceConversationID {
+			return TransplantPlan{}, fmt.Errorf("summary %s belongs to conversation %d, expected %d", summary.SummaryID, summary.ConversationID, sourceConversationID)
+		}
+	}
+
+	ordered := append([]Summary(nil), allSummaries...)
+	sort.Slice(ordered, func(i, j int) bool {
+		left := ordered[i]
+		right := ordered[j]
+		if left.Depth != right.Depth {
+			return left.Depth < right.Depth
+		}
+		if left.CreatedAt != right.CreatedAt {
+			return left.CreatedAt < right.CreatedAt
+		}
+		if left.CreatedSeq != right.CreatedSeq {
+			return left.CreatedSeq < right.CreatedSeq
+		}
+		return left.SummaryID < right.SummaryID
+	})
+
+	depthCounts := make(map[int]int)
+	for _, summary := range ordered {
+		depthCounts[summary.Depth]++
+	}
+
+	targetContext, err := LoadContextStats(ctx, q, targetConversationID)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+
+	duplicates, err := detectSummaryContentDuplicates(ctx, q, targetConversationID, ordered)
+	if err != nil {
+		return TransplantPlan{}, err
+	}
+
+	return TransplantPlan{
+		SourceConversationID: sourceConversationID,
+		TargetConversationID: targetConversationID,
+		SourceContext:        sourceContext,
+		Ordered:              ordered,
+		DepthCounts:          depthCounts,
+		TargetContext:        targetContext,
+		ContextTokenOverhead: contextTokenOverhead,
+		Duplicates:           duplicates,
+	}, nil
+}
+
+func detectSummaryContentDuplicates(ctx context.Context, q Queryer, targetConversationID int64, sourceSummaries []Summary) ([]TransplantDuplicate, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT content
+		FROM summaries
+		WHERE conversation_id = ?
+	`, targetConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query target summaries for conversation %d: %w", targetConversationID, err)
+	}
+	defer rows.Close()
+
+	targetHashes := make(map[string]int)
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("scan target summary content: %w", err)
+		}
+		hash := ContentSHA256(content)
+		targetHashes[hash]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate target summaries for conversation %d: %w", targetConversationID, err)
+	}
+
+	duplicates := make([]TransplantDuplicate, 0)
+	seen := make(map[string]bool)
+	for _, summary := range sourceSummaries {
+		hash := ContentSHA256(summary.Content)
+		targetCount := targetHashes[hash]
+		if targetCount == 0 {
+			continue
+		}
+		if seen[summary.SummaryID] {
+			continue
+		}
+		seen[summary.SummaryID] = true
+		duplicates = append(duplicates, TransplantDuplicate{
+			SummaryID:   summary.SummaryID,
+			ContentHash: hash,
+			TargetCount: targetCount,
+		})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].SummaryID < duplicates[j].SummaryID
+	})
+	return duplicates, nil
+}
+
+// ApplyTransplant copies summaries, remaps DAG edges, deep-copies linked
+// messages (or reuses matching target messages with plan.KeepMessages),
+// rewires summary_messages, and prepends context items in one transaction.
+// New summaries and copied messages are owned by the target conversation.
+// Progress lines go to w, which may be nil.
+func ApplyTransplant(ctx context.Context, db TxBeginner, plan TransplantPlan, w io.Writer) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transplant transaction: %w", err)
+	}
+
+	rollbackNeeded := true
+	defer func() {
+		if rollbackNeeded {
+			_ = tx.Rollback()
+		}
+	}()
+
+	copied, _, err := CopyTransplantPlan(ctx, tx, plan, nil, w)
+	if err != nil {
+		return copied, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return copied, fmt.Errorf("commit transplant transaction: %w", err)
+	}
+	rollbackNeeded = false
+	return copied, nil
+}
+
+// TransplantContentIndex maps summary content hashes to copies already made in
+// the target during a multi-source transplant, so identical summaries from a
+// later source reuse the earlier copy instead of being duplicated.
+type TransplantContentIndex map[string]transplantCopiedSummary
+
+type transplantCopiedSummary struct {
+	newSummaryID         string
+	sourceConversationID int64
+}
+
+// CopyTransplantPlan performs the writes for one transplant plan inside the
+// caller's transaction. When index is non-nil, summaries whose content was
+// already copied from a different source conversation are reused rather than
+// re-inserted. It returns the number of summaries copied and reused. Errors
+// are *TransplantFailure values naming the failing row and how much had been
+// remapped; the caller still rolls back the whole transaction. Progress lines
+// go to w, which may be nil.
+func CopyTransplantPlan(ctx context.Context, q Queryer, plan TransplantPlan, index TransplantContentIndex, w io.Writer) (int, int, error) {
+	if w == nil {
+		w = io.Discard
+	}
+	oldToNew := make(map[string]string, len(plan.Ordered))
+	reused := make(map[string]bool)
+	copied := 0
+	progress := TransplantFailure{SummariesTotal: len(plan.Ordered)}
+	fail := func(step, sourceSummaryID string, err error) error {
+		var failure *TransplantFailure
+		if errors.As(err, &failure) {
+			failure.SourceSummaryID = sourceSummaryID
+			failure.SummariesRemapped = progress.SummariesRemapped
+			failure.SummariesTotal = progress.SummariesTotal
+			failure.LastSummaryRemap = progress.LastSummaryRemap
+			return err
+		}
+		failure = &TransplantFailure{}
+		*failure = progress
+		failure.Step = step
+		failure.SourceSummaryID = sourceSummaryID
+		failure.Err = err
+		return failure
+	}
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return 0, 0, fail("copy summary", "", err)
+	}
+	seqColumn, seqValue := caps.NextSummarySeq()
+	for i, source := range plan.Ordered {
+		hash := ContentSHA256(source.Content)
+		if index != nil {
+			if existing, ok := index[hash]; ok && existing.sourceConversationID != plan.SourceConversationID {
+				oldToNew[source.SummaryID] = existing.newSummaryID
+				reused[source.SummaryID] = true
+				progress.SummariesRemapped++
+				progress.LastSummaryRemap = source.SummaryID + " -> " + existing.newSummaryID
+				fmt.Fprintf(w, "[%d/%d] %s -> %s (%s, d%d, shared with conversation %d)\n", i+1, len(plan.Ordered), source.SummaryID, existing.newSummaryID, source.Kind, source.Depth, existing.sourceConversationID)
+				continue
+			}
+		}
+
+		newSummaryID, err := GenerateSummaryID(ctx, q)
+		if err != nil {
+			return copied, len(reused), fail("copy summary", source.SummaryID, err)
+		}
+
+		// plan.ordered is in creation order, so each copy takes the next
+		// created_seq and same-second copies keep their relative order.
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth`+seqColumn+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?`+seqValue+`)
+		`, newSummaryID, plan.TargetConversationID, source.Kind, source.Content, source.TokenCount, source.CreatedAt, source.FileIDs, source.Depth); err != nil {
+			return copied, len(reused), fail("copy summary", source.SummaryID, fmt.Errorf("insert summary %s: %w", newSummaryID, err))
+		}
+
+		if err := copyRemappedParentEdges(ctx, q, source.SummaryID, newSummaryID, oldToNew); err != nil {
+			return copied, len(reused), fail("copy summary", source.SummaryID, err)
+		}
+
+		oldToNew[source.SummaryID] = newSummaryID
+		progress.SummariesRemapped++
+		progress.LastSummaryRemap = source.SummaryID + " -> " + newSummaryID
+		if index != nil {
+			if _, ok := index[hash]; !ok {
+				index[hash] = transplantCopiedSummary{newSummaryID: newSummaryID, sourceConversationID: plan.SourceConversationID}
+			}
+		}
+		copied++
+		fmt.Fprintf(w, "[%d/%d] %s -> %s (%s, d%d)\n", i+1, len(plan.Ordered), source.SummaryID, newSummaryID, source.Kind, source.Depth)
+	}
+
+	sourceSummaryIDs := make([]string, 0, len(plan.Ordered))
+	for _, source := range plan.Ordered {
+		if reused[source.SummaryID] {
+			continue
+		}
+		sourceSummaryIDs = append(sourceSummaryIDs, source.SummaryID)
+	}
+
+	oldToNewMessage, copiedMessages, linkedMessages, copiedParts, err := copyTransplantedMessages(ctx, q, plan.TargetConversationID, sourceSummaryIDs, plan.KeepMessages)
+	if err != nil {
+		return copied, len(reused), fail("copy messages", "", err)
+	}
+	progress.MessagesRemapped = len(oldToNewMessage)
+	progress.MessagesTotal = len(oldToNewMessage)
+	fmt.Fprintf(w, "Copied %d linked messages (%d message parts)\n", copiedMessages, copiedParts)
+	if plan.KeepMessages {
+		fmt.Fprintf(w, "Linked %d messages to existing target messages\n", linkedMessages)
+	}
+
+	for _, summaryID := range sourceSummaryIDs {
+		if err := copyRewiredSummaryMessages(ctx, q, summaryID, oldToNew[summaryID], oldToNewMessage); err != nil {
+			return copied, len(reused), fail("link summary_messages for", summaryID, err)
+		}
+	}
+
+	// Shared context roots are already represented by the earlier source.
+	contextItems := make([]ContextSummary, 0, len(plan.SourceContext))
+	for _, item := range plan.SourceContext {
+		if reused[item.SummaryID] {
+			continue
+		}
+		contextItems = append(contextItems, item)
+	}
+	if err := MergeTransplantedContextItems(ctx, q, plan.TargetConversationID, contextItems, oldToNew); err != nil {
+		return copied, len(reused), fail("merge context items", "", err)
+	}
+
+	newSummaryIDs := make([]string, 0, len(sourceSummaryIDs))
+	copiedTokens := 0
+	for _, source := range plan.Ordered {
+		if reused[source.SummaryID] {
+			continue
+		}
+		newSummaryIDs = append(newSummaryIDs, oldToNew[source.SummaryID])
+		copiedTokens += source.TokenCount
+	}
+	if err := RecordAudit(ctx, q, AuditEntry{
+		Command: "transplant", ConversationID: plan.TargetConversationID, SummaryIDs: newSummaryIDs, TokensAfter: copiedTokens,
+		Detail: fmt.Sprintf("from conversation %d: %d summaries copied, %d reused, %d messages copied",
+			plan.SourceConversationID, copied, len(reused), copiedMessages),
+	}); err != nil {
+		return copied, len(reused), fail("record audit", "", err)
+	}
+	return copied, len(reused), nil
+}
+
+// copyTransplantedMessages deep-copies the deduplicated set of source messages
+// referenced by source summaries and returns an old->new message ID map. With
+// keepMessages, a source message whose identity hash matches a message already
+// in the target maps to that row instead; only unmatched messages are copied.
+// It returns the old->new map and the copied, linked, and part counts. A
+// failure on one message is a *TransplantFailure naming it, with the messages
+// remapped so far.
+func copyTransplantedMessages(ctx context.Context, q Queryer, targetConversationID int64, sourceSummaryIDs []string, keepMessages bool) (map[int64]int64, int, int, int, error) {
+	sourceMessages, err := loadSourceMessagesForSummaries(ctx, q, sourceSummaryIDs)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	if len(sourceMessages) == 0 {
+		return map[int64]int64{}, 0, 0, 0, nil
+	}
+
+	oldToNewMessage := make(map[int64]int64, len(sourceMessages))
+	if keepMessages {
+		targetHashes, err := loadTargetMessageHashes(ctx, q, targetConversationID)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		unmatched := make([]Message, 0, len(sourceMessages))
+		for _, source := range sourceMessages {
+			if match, ok := targetHashes[MessageIdentityHash(source.Role, source.Content)]; ok {
+				oldToNewMessage[source.MessageID] = match
+				continue
+			}
+			unmatched = append(unmatched, source)
+		}
+		sourceMessages = unmatched
+	}
+	linked := len(oldToNewMessage)
+	if len(sourceMessages) == 0 {
+		return oldToNewMessage, 0, linked, 0, nil
+	}
+	lastRemap := ""
+	fail := func(step string, sourceMessageID int64, err error) error {
+		return &TransplantFailure{
+			Step:             step,
+			SourceMessageID:  sourceMessageID,
+			MessagesRemapped: len(oldToNewMessage),
+			MessagesTotal:    linked + len(sourceMessages),
+			LastMessageRemap: lastRemap,
+			Err:              err,
+		}
+	}
+
+	targetSessionID, err := ConversationSessionID(ctx, q, targetConversationID)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	nextSeq, err := NextMessageSeq(ctx, q, targetConversationID)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	totalParts := 0
+	for _, source := range sourceMessages {
+		newMessageID, err := InsertCopiedMessage(ctx, q, targetConversationID, nextSeq, source)
+		if err != nil {
+			return nil, 0, 0, 0, fail("copy", source.MessageID, err)
+		}
+		nextSeq++
+		oldToNewMessage[source.MessageID] = newMessageID
+		lastRemap = fmt.Sprintf("%d -> %d", source.MessageID, newMessageID)
+
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO messages_fts (rowid, content)
+			VALUES (?, ?)
+		`, newMessageID, source.Content); err != nil {
+			return nil, 0, 0, 0, fail("index", source.MessageID, fmt.Errorf("insert messages_fts row for copied message %d: %w", newMessageID, err))
+		}
+
+		partsCopied, err := CopyMessageParts(ctx, q, source.MessageID, newMessageID, targetSessionID)
+		if err != nil {
+			return nil, 0, 0, 0, fail("copy parts of", source.MessageID, err)
+		}
+		totalParts += partsCopied
+	}
+
+	return oldToNewMessage, len(sourceMessages), linked, totalParts, nil
+}
+
+// loadTargetMessageHashes maps each identity hash (role + content) in a
+// conversation to its earliest message, the same match merge uses.
+func loadTargetMessageHashes(ctx context.Context, q Queryer, conversationID int64) (map[string]int64, error) {
+	messages, err := ConversationMessages(ctx, q, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]int64, len(messages))
+	for _, message := range messages {
+		hash := MessageIdentityHash(message.Role, message.Content)
+		if _, ok := hashes[hash]; !ok {
+			hashes[hash] = message.MessageID
+		}
+	}
+	return hashes, nil
+}
+
+// CountTransplantMessageLinks previews --keep-messages: how many messages
+// linked by the plan's summaries already exist in the target, and how many
+// would still be copied.
+func CountTransplantMessageLinks(ctx context.Context, q Queryer, plan TransplantPlan) (int, int, error) {
+	summaryIDs := make([]string, 0, len(plan.Ordered))
+	for _, summary := range plan.Ordered {
+		summaryIDs = append(summaryIDs, summary.SummaryID)
+	}
+	sourceMessages, err := loadSourceMessagesForSummaries(ctx, q, summaryIDs)
+	if err != nil {
+		return 0, 0, err
+	}
+	targetHashes, err := loadTargetMessageHashes(ctx, q, plan.TargetConversationID)
+	if err != nil {
+		return 0, 0, err
+	}
+	linked := 0
+	for _, source := range sourceMessages {
+		if _, ok := targetHashes[MessageIdentityHash(source.Role, source.Content)]; ok {
+			linked++
+		}
+	}
+	return linked, len(sourceMessages) - linked, nil
+}
+
+// loadSourceMessagesForSummaries resolves unique source messages for a summary set.
+func loadSourceMessagesForSummaries(ctx context.Context, q Queryer, summaryIDs []string) ([]Message, error) {
+	if len(summaryIDs) == 0 {
+		return nil, nil
+	}
+
+	const batchSize = 200
+	byID := make(map[int64]Message)
+
+	for start := 0; start < len(summaryIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(summaryIDs) {
+			end = len(summaryIDs)
+		}
+
+		batch := summaryIDs[start:end]
+		placeholders := strings.TrimRight(strings.Repeat("?,", len(batch)), ",")
+		query := fmt.Sprintf(`
+			SELECT DISTINCT
+				m.message_id,
+				m.conversation_id,
+				m.seq,
+				m.role,
+				m.content,
+				m.token_count,
+				m.created_at
+			FROM summary_messages sm
+			JOIN messages m ON m.message_id = sm.message_id
+			WHERE sm.summary_id IN (%s)
+		`, placeholders)
+
+		args := make([]any, 0, len(batch))
+		for _, summaryID := range batch {
+			args = append(args, summaryID)
+		}
+
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query source messages for transplant batch: %w", err)
+		}
+
+		for rows.Next() {
+			var message Message
+			if err := rows.Scan(
+				&message.MessageID,
+				&message.ConversationID,
+				&message.Seq,
+				&message.Role,
+				&message.Content,
+				&message.TokenCount,
+				&message.CreatedAt,
+			); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan source message row for transplant: %w", err)
+			}
+			byID[message.MessageID] = message
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("iterate source message rows for transplant: %w", err)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, fmt.Errorf("close source message rows for transplant: %w", err)
+		}
+	}
+
+	messages := make([]Message, 0, len(byID))
+	for _, message := range byID {
+		messages = append(messages, message)
+	}
+
+	// Preserve deterministic insertion order in target conversation.
+	sort.Slice(messages, func(i, j int) bool {
+		left := messages[i]
+		right := messages[j]
+		if left.CreatedAt != right.CreatedAt {
+			return left.CreatedAt < right.CreatedAt
+		}
+		if left.ConversationID != right.ConversationID {
+			return left.ConversationID < right.ConversationID
+		}
+		if left.Seq != right.Seq {
+			return left.Seq < right.Seq
+		}
+		return left.MessageID < right.MessageID
+	})
+
+	return messages, nil
+}
+
+// copyRewiredSummaryMessages copies source summary_messages onto the new summary
+// while remapping source message IDs to copied target message IDs.
+func copyRewiredSummaryMessages(ctx context.Context, q Queryer, oldSummaryID, newSummaryID string, oldToNewMessage map[int64]int64) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT message_id, ordinal
+		FROM summary_messages
+		WHERE summary_id = ?
+		ORDER BY ordinal ASC
+	`, oldSummaryID)
+	if err != nil {
+		return fmt.Errorf("query summary_messages for %s: %w", oldSummaryID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			oldMessageID int64
+			ordinal      int64
+		)
+		if err := rows.Scan(&oldMessageID, &ordinal); err != nil {
+			return fmt.Errorf("scan summary_message edge for %s: %w", oldSummaryID, err)
+		}
+
+		newMessageID, ok := oldToNewMessage[oldMessageID]
+		if !ok {
+			return fmt.Errorf("%w message for %s -> %d", ErrTransplantMissingRemap, oldSummaryID, oldMessageID)
+		}
+
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO summary_messages (summary_id, message_id, ordinal)
+			VALUES (?, ?, ?)
+		`, newSummaryID, newMessageID, ordinal); err != nil {
+			return fmt.Errorf("insert remapped summary_message for %s: %w", oldSummaryID, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate summary_messages for %s: %w", oldSummaryID, err)
+	}
+	return nil
+}
+
+func copyRemappedParentEdges(ctx context.Context, q Queryer, oldSummaryID, newSummaryID string, oldToNew map[string]string) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT parent_summary_id, ordinal
+		FROM summary_parents
+		WHERE summary_id = ?
+		ORDER BY ordinal ASC
+	`, oldSummaryID)
+	if err != nil {
+		return fmt.Errorf("query parent edges for %s: %w", oldSummaryID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var parentSummaryID string
+		var ordinal int64
+		if err := rows.Scan(&parentSummaryID, &ordinal); err != nil {
+			return fmt.Errorf("scan parent edge for %s: %w", oldSummaryID, err)
+		}
+
+		remappedParentID, ok := oldToNew[parentSummaryID]
+		if !ok {
+			return fmt.Errorf("%w parent for %s -> %s", ErrTransplantMissingRemap, oldSummaryID, parentSummaryID)
+		}
+
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+			VALUES (?, ?, ?)
+		`, newSummaryID, remappedParentID, ordinal); err != nil {
+			return fmt.Errorf("insert parent edge for %s: %w", oldSummaryID, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate parent edges for %s: %w", oldSummaryID, err)
+	}
+	return nil
+}
+
+// MergeTransplantedContextItems inserts transplanted summaries into the target
+// conversation's context and reorders all summary-type items by depth (descending),
+// then created_at (ascending). This preserves the depth-descending invariant that
+// the context engine expects, rather than blindly prepending which can interleave
+// depths incorrectly at the transplant boundary. Messages remain after all summaries.
+func MergeTransplantedContextItems(ctx context.Context, q Queryer, targetConversationID int64, sourceContext []ContextSummary, oldToNew map[string]string) error {
+	if len(sourceContext) == 0 {
+		return nil
+	}
+
+	// Step 1: Shift all existing ordinals up to make room for inserts without
+	// UNIQUE constraint violations. We use a large temporary offset.
+	var maxOrdinal sql.NullInt64
+	if err := q.QueryRowContext(ctx, `
+		SELECT MAX(ordinal)
+		FROM context_items
+		WHERE conversation_id = ?
+	`, targetConversationID).Scan(&maxOrdinal); err != nil {
+		return fmt.Errorf("query max target context ordinal for conversation %d: %w", targetConversationID, err)
+	}
+
+	tempShift := int64(len(sourceContext) + 1)
+	if maxOrdinal.Valid {
+		tempShift += maxOrdinal.Int64
+	}
+
+	if _, err := q.ExecContext(ctx, `
+		UPDATE context_items
+		SET ordinal = ordinal + ?
+		WHERE conversation_id = ?
+	`, tempShift, targetConversationID); err != nil {
+		return fmt.Errorf("temporarily shift context ordinals for conversation %d: %w", targetConversationID, err)
+	}
+
+	// Step 2: Insert transplanted summaries into the free low-ordinal range.
+	// They'll be reordered in step 3.
+	for i, source := range sourceContext {
+		newSummaryID, ok := oldToNew[source.SummaryID]
+		if !ok {
+			return fmt.Errorf("%w summary ID for context summary %s", ErrTransplantMissingRemap, source.SummaryID)
+		}
+
+		tempOrd := int64(i)
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+			VALUES (?, ?, 'summary', ?)
+		`, targetConversationID, tempOrd, newSummaryID); err != nil {
+			return fmt.Errorf("insert transplanted context item %d (%s): %w", i, source.SummaryID, err)
+		}
+	}
+
+	// Step 3: Move all summaries out of the final low-ordinal range before
+	// reordering. Otherwise a summary that should move to ordinal 0 can collide
+	// with a newly inserted temporary summary already sitting at ordinal 0.
+	maxAfterInsert := int64(len(sourceContext) - 1)
+	if maxOrdinal.Valid {
+		maxAfterInsert = tempShift + maxOrdinal.Int64
+	}
+	reorderShift := maxAfterInsert + 1
+	if _, err := q.ExecContext(ctx, `
+		UPDATE context_items
+		SET ordinal = ordinal + ?
+		WHERE conversation_id = ? AND item_type = 'summary'
+	`, reorderShift, targetConversationID); err != nil {
+		return fmt.Errorf("temporarily shift summary context ordinals for conversation %d: %w", targetConversationID, err)
+	}
+
+	// Step 4: Reorder all summary context items by depth DESC, then created_at ASC.
+	// This merges transplanted and existing summaries into the correct order.
+	caps, err := SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return err
+	}
+	if _, err := q.ExecContext(ctx, `
+		WITH ranked_summaries AS (
+			SELECT ci.rowid AS ci_rowid,
+				ROW_NUMBER() OVER (
+					ORDER BY s.depth DESC, s.created_at ASC, `+caps.SummarySeqExpr("s")+` ASC, ci.summary_id ASC
+				) - 1 AS new_ordinal
+			FROM context_items ci
+			JOIN summaries s ON s.summary_id = ci.summary_id
+			WHERE ci.conversation_id = ? AND ci.item_type = 'summary'
+		)
+		UPDATE context_items
+		SET ordinal = (
+			SELECT new_ordinal FROM ranked_summaries
+			WHERE ranked_summaries.ci_rowid = context_items.rowid
+		)
+		WHERE conversation_id = ? AND item_type = 'summary'
+	`, targetConversationID, targetConversationID); err != nil {
+		return fmt.Errorf("reorder summary context items for conversation %d: %w", targetConversationID, err)
+	}
+
+	// Step 5: Reorder message context items to follow after all summaries.
+	if _, err := q.ExecContext(ctx, `
+		WITH summary_count AS (
+			SELECT COUNT(*) AS cnt
+			FROM context_items
+			WHERE conversation_id = ? AND item_type = 'summary'
+		),
+		ranked_messages AS (
+			SELECT ci.rowid AS ci_rowid,
+				ROW_NUMBER() OVER (ORDER BY ci.ordinal ASC) - 1
+					+ (SELECT cnt FROM summary_count) AS new_ordinal
+			FROM context_items ci
+			WHERE ci.conversation_id = ? AND ci.item_type = 'message'
+		)
+		UPDATE context_items
+		SET ordinal = (
+			SELECT new_ordinal FROM ranked_messages
+			WHERE ranked_messages.ci_rowid = context_items.rowid
+		)
+		WHERE conversation_id = ? AND item_type = 'message'
+	`, targetConversationID, targetConversationID, targetConversationID); err != nil {
+		return fmt.Errorf("reorder message context items for conversation %d: %w", targetConversationID, err)
+	}
+
+	return nil
+}
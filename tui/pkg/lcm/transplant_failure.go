@@ -0,0 +1,81 @@
+package lcm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTransplantMissingRemap marks an edge whose source row was never given a
+// target ID, e.g. a parent summary outside the plan.
+var ErrTransplantMissingRemap = errors.New("missing remapped")
+
+// TransplantFailure is a copy error with the state of the remap when it
+// happened. The transaction still rolls back; this only says where and why,
+// so a failed transplant can be debugged from its error alone.
+type TransplantFailure struct {
+	Step            string // copy summary, copy message, link messages, merge context, record audit
+	SourceSummaryID string
+	SourceMessageID int64
+
+	SummariesRemapped int
+	SummariesTotal    int
+	LastSummaryRemap  string // "old -> new" for the last summary copied
+	MessagesRemapped  int
+	MessagesTotal     int
+	LastMessageRemap  string // "old -> new" for the last message copied or linked
+
+	Err error
+}
+
+func (f *TransplantFailure) Error() string {
+	var b strings.Builder
+	b.WriteString(f.Step)
+	switch {
+	case f.SourceSummaryID != "" && f.SourceMessageID != 0:
+		fmt.Fprintf(&b, " %s (message %d)", f.SourceSummaryID, f.SourceMessageID)
+	case f.SourceSummaryID != "":
+		fmt.Fprintf(&b, " %s", f.SourceSummaryID)
+	case f.SourceMessageID != 0:
+		fmt.Fprintf(&b, " message %d", f.SourceMessageID)
+	}
+	if kind := classifyTransplantError(f.Err); kind != "" {
+		fmt.Fprintf(&b, " failed (%s): %v", kind, f.Err)
+	} else {
+		fmt.Fprintf(&b, " failed: %v", f.Err)
+	}
+	fmt.Fprintf(&b, "; rolled back after remapping %d of %d summaries", f.SummariesRemapped, f.SummariesTotal)
+	if f.LastSummaryRemap != "" {
+		fmt.Fprintf(&b, " (last %s)", f.LastSummaryRemap)
+	}
+	fmt.Fprintf(&b, " and %d of %d messages", f.MessagesRemapped, f.MessagesTotal)
+	if f.LastMessageRemap != "" {
+		fmt.Fprintf(&b, " (last %s)", f.LastMessageRemap)
+	}
+	return b.String()
+}
+
+func (f *TransplantFailure) Unwrap() error {
+	return f.Err
+}
+
+// classifyTransplantError names the usual causes of a failed copy: SQLite
+// constraint violations and edges to rows that were never remapped. It
+// returns "" for anything else.
+func classifyTransplantError(err error) string {
+	if errors.Is(err, ErrTransplantMissingRemap) {
+		return "missing remap"
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "UNIQUE constraint failed"), strings.Contains(message, "PRIMARY KEY constraint failed"):
+		return "duplicate key"
+	case strings.Contains(message, "FOREIGN KEY constraint failed"):
+		return "foreign key"
+	case strings.Contains(message, "NOT NULL constraint failed"):
+		return "missing value"
+	case strings.Contains(message, "CHECK constraint failed"):
+		return "check constraint"
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func TestPreviousSummariesHonorsCountAndDepth(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-prev', 'Prev')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_d1', 1, 'condensed', 1, 'older arc', 20, '2026-03-22T09:00:00Z'),
+			('sum_l1', 1, 'leaf', 0, 'first leaf', 10, '2026-03-22T10:00:00Z'),
+			('sum_l2', 1, 'leaf', 0, 'second leaf', 10, '2026-03-22T10:01:00Z'),
+			('sum_l3', 1, 'leaf', 0, 'third leaf', 10, '2026-03-22T10:02:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+		VALUES (1, 0, 'summary', 'sum_d1'), (1, 1, 'summary', 'sum_l1'), (1, 2, 'summary', 'sum_l2'), (1, 3, 'summary', 'sum_l3')
+	`)
+
+	lookup := func(opts lcm.PreviousContextOptions) []lcm.PreviousSummary {
+		t.Helper()
+		summaries, err := lcm.PreviousSummaries(ctx, db, "sum_l3", 1, 0, "leaf", "2026-03-22T10:02:00Z", opts)
+		if err != nil {
+			t.Fatalf("previous summaries %+v: %v", opts, err)
+		}
+		return summaries
+	}
+	if got := lcm.DescribePreviousContext(lookup(lcm.DefaultPreviousContextOptions()), lcm.DefaultPreviousContextOptions()); got != "1 of 1 (sum_l2)" {
+		t.Fatalf("default lookup = %s", got)
+	}
+	twoOpts := lcm.PreviousContextOptions{Count: 2, Depth: -1}
+	two := lookup(twoOpts)
+	if got := lcm.DescribePreviousContext(two, twoOpts); got != "2 of 2 (sum_l1, sum_l2)" {
+		t.Fatalf("count 2 lookup = %s", got)
+	}
+	if joined := lcm.JoinPreviousSummaries(two); joined != "first leaf\n\nsecond leaf" {
+		t.Fatalf("joined previous context = %q", joined)
+	}
+	if got := lookup(lcm.PreviousContextOptions{Count: 3, Depth: 1}); len(got) != 1 || got[0].SummaryID != "sum_d1" {
+		t.Fatalf("depth 1 lookup = %+v", got)
+	}
+	if got := lookup(lcm.PreviousContextOptions{Count: 0, Depth: -1}); len(got) != 0 {
+		t.Fatalf("count 0 lookup = %+v", got)
+	}
+
+	prior, err := lcm.PriorSummaries(ctx, db, 1, 3, -1, 3)
+	if err != nil {
+		t.Fatalf("backfill prior context: %v", err)
+	}
+	if got := lcm.DescribePreviousContext(prior, lcm.PreviousContextOptions{Count: 3}); got != "3 of 3 (sum_d1, sum_l1, sum_l2)" {
+		t.Fatalf("backfill prior context = %s", got)
+	}
+
+	if _, _, err := parseRewriteArgs([]string{"1", "--all", "--prev-context-count", "-1"}); err == nil || !strings.Contains(err.Error(), "--prev-context-count") {
+		t.Fatalf("expected negative --prev-context-count to be rejected, got %v", err)
+	}
+}
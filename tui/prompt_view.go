@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// interactiveRewritePrompt is what an interactive rewrite of one summary
+// sends: its rebuilt source and the prompt rendered over it.
+type interactiveRewritePrompt struct {
+	source          lcm.SummarySource
+	previousContext string
+	targetTokens    int
+	prompt          string
+	redactions      lcm.RedactionCounts
+}
+
+// buildInteractiveRewritePrompt builds item's source and renders its depth
+// prompt with the agent's prompt directory, fresh tail, and redaction
+// settings. w, W, and the P prompt viewer all go through it, so the viewer
+// shows exactly what a rewrite would send.
+func (m model) buildInteractiveRewritePrompt(ctx context.Context, db *sql.DB, item rewriteSummary) (interactiveRewritePrompt, error) {
+	leaf := item.depth == 0 || strings.EqualFold(item.kind, "leaf")
+	source, err := lcm.BuildRewriteSource(ctx, db, item.summaryID, leaf, lcm.RewriteSourceOptions{
+		Timestamps: true, Location: time.Local, FreshTail: m.agentDefaults.rewriteFreshTail(),
+	})
+	if err != nil {
+		return interactiveRewritePrompt{}, fmt.Errorf("build source for %s: %w", item.summaryID, err)
+	}
+	redactions := source.Redact(m.sourceRedactor)
+	previousContext, err := resolveRewritePreviousContext(ctx, db, item)
+	if err != nil {
+		return interactiveRewritePrompt{}, fmt.Errorf("resolve previous context for %s: %w", item.summaryID, err)
+	}
+	// Previous context goes to the same API as the source, so it gets the
+	// same redaction.
+	previousContext, counts := m.sourceRedactor.Redact(previousContext)
+	redactions.Add(counts)
+	targetTokens := lcm.CondensedTargetTokens
+	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
+		targetTokens = lcm.LeafTargetTokens(source.EstimatedTokens)
+	}
+	prompt, err := lcm.RenderPrompt(item.depth, lcm.PromptVars{
+		TargetTokens:    targetTokens,
+		PreviousContext: previousContext,
+		ChildCount:      source.ItemCount,
+		TimeRange:       source.TimeRange,
+		Depth:           item.depth,
+		SourceText:      source.Text,
+	}, m.agentDefaults.promptDir())
+	if err != nil {
+		return interactiveRewritePrompt{}, fmt.Errorf("render prompt for %s: %w", item.summaryID, err)
+	}
+	return interactiveRewritePrompt{
+		source:          source,
+		previousContext: previousContext,
+		targetTokens:    targetTokens,
+		prompt:          prompt,
+		redactions:      redactions,
+	}, nil
+}
+
+// promptViewState is the read-only P overlay: the full prompt a rewrite of
+// the selected summary would send. Nothing is sent.
+type promptViewState struct {
+	summaryID    string
+	depth        int
+	template     string // template name and where it loads from
+	built        interactiveRewritePrompt
+	scrollOffset int
+}
+
+// startPromptView renders the selected summary's rewrite prompt into the
+// prompt overlay.
+func (m *model) startPromptView() {
+	summaryID, ok := m.currentSummaryID()
+	if !ok {
+		m.status = "No summary selected"
+		return
+	}
+	node := m.summary.nodes[summaryID]
+	if node == nil {
+		m.status = "Missing summary node"
+		return
+	}
+
+	db, err := openLCMDB(m.paths.lcmDBPath)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	defer db.Close()
+
+	item := rewriteSummary{
+		summaryID:      summaryID,
+		conversationID: m.summary.conversationID,
+		kind:           node.kind,
+		depth:          node.depth,
+		tokenCount:     node.tokenCount,
+		content:        node.content,
+		createdAt:      node.createdAt,
+	}
+	built, err := m.buildInteractiveRewritePrompt(context.Background(), db, item)
+	if err != nil {
+		m.status = "Error: " + err.Error()
+		return
+	}
+	template := lcm.PromptNameForDepth(item.depth)
+	if source, err := lcm.ResolvePromptSource(template, m.agentDefaults.promptDir()); err == nil {
+		template = fmt.Sprintf("%s (%s: %s)", source.Name, source.Kind, source.Path)
+	}
+	m.promptView = &promptViewState{summaryID: summaryID, depth: item.depth, template: template, built: built}
+	m.status = fmt.Sprintf("Prompt for %s (%d tokens); nothing is sent", summaryID, lcm.EstimateTokenCount(built.prompt)) + formatRedactionStatus(built.redactions)
+}
+
+// handlePromptViewKey scrolls or closes the prompt overlay.
+func (m *model) handlePromptViewKey(key string) {
+	pv := m.promptView
+	_, promptLines, viewHeight := m.promptViewLayout()
+	maxScroll := max(0, len(promptLines)-viewHeight)
+	switch key {
+	case "j", "down":
+		pv.scrollOffset++
+	case "k", "up":
+		pv.scrollOffset--
+	case "pgdown", " ":
+		pv.scrollOffset += viewHeight
+	case "pgup":
+		pv.scrollOffset -= viewHeight
+	case "g":
+		pv.scrollOffset = 0
+	case "G":
+		pv.scrollOffset = maxScroll
+	case "P", "esc", "b", "backspace":
+		m.promptView = nil
+		m.status = "Prompt view closed"
+		return
+	}
+	pv.scrollOffset = clamp(pv.scrollOffset, 0, maxScroll)
+}
+
+// promptViewLayout returns the overlay's header lines, the wrapped prompt,
+// and how many prompt lines fit below the header.
+func (m model) promptViewLayout() (header, promptLines []string, viewHeight int) {
+	pv := m.promptView
+	built := pv.built
+	header = []string{
+		fmt.Sprintf("Prompt for %s (d%d) — read-only, no API call", pv.summaryID, pv.depth),
+		"Template: " + pv.template,
+		fmt.Sprintf("Source: %d %s, %d tokens  Target: %d tokens  Prompt: %d tokens",
+			built.source.ItemCount, built.source.Label, built.source.EstimatedTokens, built.targetTokens, lcm.EstimateTokenCount(built.prompt)),
+	}
+	if built.source.TimeRange != "" {
+		header = append(header, "Time range: "+built.source.TimeRange)
+	}
+	if strings.TrimSpace(built.previousContext) != "" {
+		header = append(header, fmt.Sprintf("Previous context: %d tokens", lcm.EstimateTokenCount(built.previousContext)))
+	} else {
+		header = append(header, "Previous context: none")
+	}
+	header = append(header, "")
+
+	promptLines = strings.Split(wrapText(built.prompt, max(20, m.width-4)), "\n")
+	viewHeight = max(4, m.height-len(header)-6)
+	return header, promptLines, viewHeight
+}
+
+// renderPromptView draws the prompt overlay: a header with what went into
+// the prompt, then the wrapped prompt in a scrollable window.
+func (m model) renderPromptView() string {
+	lines, promptLines, viewHeight := m.promptViewLayout()
+	offset := clamp(m.promptView.scrollOffset, 0, max(0, len(promptLines)-viewHeight))
+	end := min(offset+viewHeight, len(promptLines))
+	for _, line := range promptLines[offset:end] {
+		lines = append(lines, "  "+line)
+	}
+	if len(promptLines) > viewHeight {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("  [%d/%d lines — j/k to scroll]", end, len(promptLines))))
+	}
+	return strings.Join(lines, "\n")
+}
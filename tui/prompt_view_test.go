@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPromptViewShowsRewritePromptWithoutSending(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "lcm.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+	setupBackfillTestSchema(t, db)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-prompt', 'Prompt')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES (1, 1, 1, 'user', 'rotate the staging credentials tonight', 8, '2026-03-22T10:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES ('sum_leaf', 1, 'leaf', 0, 'old leaf', 3, '2026-03-22T10:00:00Z', '[]')
+	`)
+	mustExec(t, db, `INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('sum_leaf', 1, 0)`)
+
+	m := model{
+		screen: screenSummaries,
+		width:  100,
+		height: 40,
+		paths:  appDataPaths{lcmDBPath: dbPath},
+		summary: summaryGraph{conversationID: 1, nodes: map[string]*summaryNode{
+			"sum_leaf": {id: "sum_leaf", kind: "leaf", depth: 0, content: "old leaf", tokenCount: 3, createdAt: "2026-03-22T10:00:00Z"},
+		}},
+		summaryRows: []summaryRow{{summaryID: "sum_leaf"}},
+	}
+	press := func(key string) {
+		t.Helper()
+		updated, cmd := m.handleSummariesKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(model)
+		if cmd != nil {
+			t.Fatalf("%s should not start any command", key)
+		}
+	}
+
+	press("P")
+	if m.promptView == nil {
+		t.Fatalf("expected the prompt view to open, status %q", m.status)
+	}
+	if m.pendingRewrite != nil {
+		t.Fatal("the prompt view must not start a rewrite")
+	}
+	view := m.renderSummaries()
+	for _, want := range []string{"read-only", "leaf.tmpl", "<conversation_segment>", "rotate the staging credentials tonight"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("prompt view missing %q:\n%s", want, view)
+		}
+	}
+
+	press("G")
+	press("j")
+	if _, lines, height := m.promptViewLayout(); m.promptView.scrollOffset != max(0, len(lines)-height) {
+		t.Fatalf("scroll offset %d not clamped to the last page", m.promptView.scrollOffset)
+	}
+	press("P")
+	if m.promptView != nil {
+		t.Fatal("expected P to close the prompt view")
+	}
+}
+
+func TestInteractiveRewritePromptRedactsPreviousContext(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lcm.db"))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+	setupBackfillTestSchema(t, db)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-prompt', 'Prompt')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES (1, 1, 1, 'user', 'rotate the staging credentials tonight', 8, '2026-03-22T10:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES
+			('sum_prev', 1, 'leaf', 0, 'Set ANTHROPIC_API_KEY=sk-ant-REDACTED on staging.', 20, '2026-03-22T09:00:00Z', '[]'),
+			('sum_leaf', 1, 'leaf', 0, 'old leaf', 3, '2026-03-22T10:00:00Z', '[]')
+	`)
+	mustExec(t, db, `INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('sum_leaf', 1, 0)`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+		VALUES (1, 0, 'summary', 'sum_prev'), (1, 1, 'summary', 'sum_leaf')
+	`)
+
+	redactor, err := newSourceRedactor(builtinRedactionPatterns)
+	if err != nil {
+		t.Fatalf("compile built-ins: %v", err)
+	}
+	m := model{sourceRedactor: redactor}
+	built, err := m.buildInteractiveRewritePrompt(context.Background(), db, rewriteSummary{
+		summaryID: "sum_leaf", conversationID: 1, kind: "leaf", depth: 0, createdAt: "2026-03-22T10:00:00Z",
+	})
+	if err != nil {
+		t.Fatalf("build interactive rewrite prompt: %v", err)
+	}
+	if !strings.Contains(built.previousContext, "[REDACTED:anthropic-key]") {
+		t.Fatalf("expected the prior summary's key to be redacted, got %q", built.previousContext)
+	}
+	if strings.Contains(built.prompt, "sk-ant-api03") || strings.Contains(built.previousContext, "sk-ant-api03") {
+		t.Fatalf("secret from the previous summary reached the prompt:\n%s", built.prompt)
+	}
+	if got := built.redactions["anthropic-key"]; got != 1 {
+		t.Fatalf("expected the previous-context redaction to be counted, got %v", built.redactions)
+	}
+}
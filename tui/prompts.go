@@ -1,50 +1,22 @@
 package main
 
 import (
-	"bytes"
-	"embed"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"text/template"
-)
-
-const defaultPromptOverrideDir = "~/.config/lcm-tui/prompts"
-
-var promptTemplateNames = []string{
-	"leaf.tmpl",
-	"condensed-d1.tmpl",
-	"condensed-d2.tmpl",
-	"condensed-d3.tmpl",
-}
 
-// defaultPromptFS stores the built-in prompt templates.
-//
-//go:embed prompts/*.tmpl
-var defaultPromptFS embed.FS
-
-// PromptVars is the template data passed into depth-aware prompt templates.
-type PromptVars struct {
-	TargetTokens    int
-	PreviousContext string
-	ChildCount      int
-	TimeRange       string
-	Depth           int
-	SourceText      string
-}
-
-type promptSource struct {
-	name string
-	kind string // "filesystem" or "embedded"
-	path string
-}
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
 
 type promptsOptions struct {
 	list            bool
 	exportDir       string
+	exportBundle    string // --export-bundle file, "-" for stdout
+	importBundle    string // --import-bundle file
 	showName        string
 	diffName        string
 	renderName      string
@@ -55,6 +27,13 @@ type promptsOptions struct {
 	depth           int
 	sourceText      string
 	promptDir       string
+
+	// sourceFile and previousContextFile load render inputs from disk; a
+	// literal "-" for --source-text or --previous-context reads stdin instead.
+	sourceFile          string
+	previousContextFile string
+	sourceTextSet       bool
+	previousContextSet  bool
 }
 
 // runPromptsCommand executes prompt template maintenance commands.
@@ -71,6 +50,12 @@ func runPromptsCommand(args []string) error {
 	if opts.exportDir != "" {
 		actions++
 	}
+	if opts.exportBundle != "" {
+		actions++
+	}
+	if opts.importBundle != "" {
+		actions++
+	}
 	if opts.showName != "" {
 		actions++
 	}
@@ -92,11 +77,22 @@ func runPromptsCommand(args []string) error {
 		return listPromptSources(opts.promptDir)
 	case opts.exportDir != "":
 		return exportPromptDefaults(opts.exportDir)
+	case opts.exportBundle != "":
+		return exportPromptBundle(opts.exportBundle, opts.promptDir, os.Stdout)
+	case opts.importBundle != "":
+		dir := opts.promptDir
+		if dir == "" {
+			dir = lcm.ExpandHomePath(lcm.DefaultPromptOverrideDir)
+		}
+		return importPromptBundle(opts.importBundle, dir, os.Stdout)
 	case opts.showName != "":
 		return showActivePrompt(opts.showName, opts.promptDir)
 	case opts.diffName != "":
 		return diffPromptTemplate(opts.diffName, opts.promptDir)
 	case opts.renderName != "":
+		if err := resolvePromptsRenderInputs(&opts, os.Stdin); err != nil {
+			return err
+		}
 		return renderPromptTemplate(opts)
 	default:
 		return fmt.Errorf("unknown prompts action\n%s", promptsUsageText())
@@ -105,7 +101,7 @@ func runPromptsCommand(args []string) error {
 
 func parsePromptsArgs(args []string) (promptsOptions, error) {
 	opts := promptsOptions{
-		targetTokens: condensedTargetTokens,
+		targetTokens: lcm.CondensedTargetTokens,
 		childCount:   4,
 		timeRange:    "2026-02-17 15:37 - 2026-02-17 21:14 UTC",
 		sourceText:   "[2026-02-17 15:37 UTC] [user] Example source context.\n[2026-02-17 16:10 UTC] [assistant] Example follow-up details.",
@@ -130,13 +126,29 @@ func parsePromptsArgs(args []string) (promptsOptions, error) {
 				i++
 				opts.exportDir = args[i]
 			} else {
-				opts.exportDir = defaultPromptOverrideDir
+				opts.exportDir = lcm.DefaultPromptOverrideDir
 			}
 		case strings.HasPrefix(arg, "--export="):
 			opts.exportDir = strings.TrimSpace(strings.TrimPrefix(arg, "--export="))
 			if opts.exportDir == "" {
-				opts.exportDir = defaultPromptOverrideDir
+				opts.exportDir = lcm.DefaultPromptOverrideDir
+			}
+		case arg == "--export-bundle":
+			value, err := nextValue("--export-bundle")
+			if err != nil {
+				return promptsOptions{}, err
 			}
+			opts.exportBundle = value
+		case strings.HasPrefix(arg, "--export-bundle="):
+			opts.exportBundle = strings.TrimSpace(strings.TrimPrefix(arg, "--export-bundle="))
+		case arg == "--import-bundle":
+			value, err := nextValue("--import-bundle")
+			if err != nil {
+				return promptsOptions{}, err
+			}
+			opts.importBundle = value
+		case strings.HasPrefix(arg, "--import-bundle="):
+			opts.importBundle = strings.TrimSpace(strings.TrimPrefix(arg, "--import-bundle="))
 		case arg == "--show":
 			value, err := nextValue("--show")
 			if err != nil {
@@ -184,8 +196,18 @@ func parsePromptsArgs(args []string) (promptsOptions, error) {
 				return promptsOptions{}, err
 			}
 			opts.previousContext = value
+			opts.previousContextSet = true
 		case strings.HasPrefix(arg, "--previous-context="):
 			opts.previousContext = strings.TrimSpace(strings.TrimPrefix(arg, "--previous-context="))
+			opts.previousContextSet = true
+		case arg == "--previous-context-file":
+			value, err := nextValue("--previous-context-file")
+			if err != nil {
+				return promptsOptions{}, err
+			}
+			opts.previousContextFile = value
+		case strings.HasPrefix(arg, "--previous-context-file="):
+			opts.previousContextFile = strings.TrimSpace(strings.TrimPrefix(arg, "--previous-context-file="))
 		case arg == "--child-count":
 			value, err := nextValue("--child-count")
 			if err != nil {
@@ -234,8 +256,18 @@ func parsePromptsArgs(args []string) (promptsOptions, error) {
 				return promptsOptions{}, err
 			}
 			opts.sourceText = value
+			opts.sourceTextSet = true
 		case strings.HasPrefix(arg, "--source-text="):
 			opts.sourceText = strings.TrimSpace(strings.TrimPrefix(arg, "--source-text="))
+			opts.sourceTextSet = true
+		case arg == "--source-file":
+			value, err := nextValue("--source-file")
+			if err != nil {
+				return promptsOptions{}, err
+			}
+			opts.sourceFile = value
+		case strings.HasPrefix(arg, "--source-file="):
+			opts.sourceFile = strings.TrimSpace(strings.TrimPrefix(arg, "--source-file="))
 		case arg == "--prompt-dir":
 			value, err := nextValue("--prompt-dir")
 			if err != nil {
@@ -257,11 +289,26 @@ func parsePromptsArgs(args []string) (promptsOptions, error) {
 	if opts.childCount < 0 {
 		return promptsOptions{}, fmt.Errorf("--child-count must be >= 0\n%s", promptsUsageText())
 	}
+	if opts.sourceTextSet && opts.sourceFile != "" {
+		return promptsOptions{}, fmt.Errorf("use only one of --source-text or --source-file\n%s", promptsUsageText())
+	}
+	if opts.previousContextSet && opts.previousContextFile != "" {
+		return promptsOptions{}, fmt.Errorf("use only one of --previous-context or --previous-context-file\n%s", promptsUsageText())
+	}
+	if opts.sourceTextSet && opts.sourceText == "-" && opts.previousContextSet && opts.previousContext == "-" {
+		return promptsOptions{}, fmt.Errorf("only one of --source-text or --previous-context can read from stdin\n%s", promptsUsageText())
+	}
 	if opts.exportDir != "" {
-		opts.exportDir = expandHomePath(opts.exportDir)
+		opts.exportDir = lcm.ExpandHomePath(opts.exportDir)
+	}
+	if opts.exportBundle != "" && opts.exportBundle != "-" {
+		opts.exportBundle = lcm.ExpandHomePath(opts.exportBundle)
+	}
+	if opts.importBundle != "" {
+		opts.importBundle = lcm.ExpandHomePath(opts.importBundle)
 	}
 	if opts.promptDir != "" {
-		opts.promptDir = expandHomePath(opts.promptDir)
+		opts.promptDir = lcm.ExpandHomePath(opts.promptDir)
 	}
 	return opts, nil
 }
@@ -270,20 +317,77 @@ func promptsUsageText() string {
 	return strings.TrimSpace(`Usage:
   lcm-tui prompts --list [--prompt-dir <dir>]
   lcm-tui prompts --export [dir]
+  lcm-tui prompts --export-bundle <file|-> [--prompt-dir <dir>]
+  lcm-tui prompts --import-bundle <file> [--prompt-dir <dir>]
   lcm-tui prompts --show <name> [--prompt-dir <dir>]
   lcm-tui prompts --diff <name> [--prompt-dir <dir>]
   lcm-tui prompts --render <name> --target-tokens <n> [--previous-context <text>] [--prompt-dir <dir>]
+  lcm-tui prompts --render <name> --source-file <path> [--previous-context-file <path>]
+  lcm-tui prompts --render <name> --source-text - < source.txt
+
+Render inputs:
+  --source-text <text|->        inline source text, or - to read stdin
+  --source-file <path>          read source text from a file
+  --previous-context <text|->   inline previous context, or - to read stdin
+  --previous-context-file <path> read previous context from a file
+
+Bundles:
+  --export-bundle writes all four active templates (override or embedded,
+  with their source) to one JSON file. --import-bundle validates every
+  template first and writes nothing if one fails, then writes the rest into
+  --prompt-dir (default ~/.config/lcm-tui/prompts). Templates identical to
+  the embedded default are not written.
 `)
 }
 
+// resolvePromptsRenderInputs replaces stdin ("-") and file-backed render
+// inputs with their contents. Newlines inside the input are preserved; only
+// the trailing line ending is dropped so the text matches rewrite sources.
+func resolvePromptsRenderInputs(opts *promptsOptions, stdin io.Reader) error {
+	readInput := func(flagName, inline string, inlineSet bool, path string) (string, bool, error) {
+		switch {
+		case path != "":
+			data, err := os.ReadFile(lcm.ExpandHomePath(path))
+			if err != nil {
+				return "", false, fmt.Errorf("read %s %q: %w", flagName, path, err)
+			}
+			return strings.TrimRight(string(data), "\r\n"), true, nil
+		case inlineSet && inline == "-":
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return "", false, fmt.Errorf("read %s from stdin: %w", flagName, err)
+			}
+			return strings.TrimRight(string(data), "\r\n"), true, nil
+		default:
+			return "", false, nil
+		}
+	}
+
+	sourceText, ok, err := readInput("source text", opts.sourceText, opts.sourceTextSet, opts.sourceFile)
+	if err != nil {
+		return err
+	}
+	if ok {
+		opts.sourceText = sourceText
+	}
+	previousContext, ok, err := readInput("previous context", opts.previousContext, opts.previousContextSet, opts.previousContextFile)
+	if err != nil {
+		return err
+	}
+	if ok {
+		opts.previousContext = previousContext
+	}
+	return nil
+}
+
 func listPromptSources(overrideDir string) error {
-	for _, name := range promptTemplateNames {
-		source, err := resolvePromptSource(name, overrideDir)
+	for _, name := range lcm.PromptTemplateNames() {
+		source, err := lcm.ResolvePromptSource(name, overrideDir)
 		if err != nil {
 			return err
 		}
-		if source.kind == "filesystem" {
-			fmt.Printf("%-18s %s (override)\n", name, source.path)
+		if source.Kind == lcm.PromptSourceFilesystem {
+			fmt.Printf("%-18s %s (override)\n", name, source.Path)
 			continue
 		}
 		fmt.Printf("%-18s embedded (no override)\n", name)
@@ -293,13 +397,13 @@ func listPromptSources(overrideDir string) error {
 
 func exportPromptDefaults(dir string) error {
 	if strings.TrimSpace(dir) == "" {
-		dir = expandHomePath(defaultPromptOverrideDir)
+		dir = lcm.ExpandHomePath(lcm.DefaultPromptOverrideDir)
 	}
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create prompt export dir %q: %w", dir, err)
 	}
-	for _, name := range promptTemplateNames {
-		content, err := readEmbeddedPromptTemplate(name)
+	for _, name := range lcm.PromptTemplateNames() {
+		content, err := lcm.DefaultPromptTemplate(name)
 		if err != nil {
 			return err
 		}
@@ -308,21 +412,21 @@ func exportPromptDefaults(dir string) error {
 			return fmt.Errorf("write %s: %w", path, err)
 		}
 	}
-	fmt.Printf("Exported %d prompt templates to %s\n", len(promptTemplateNames), dir)
+	fmt.Printf("Exported %d prompt templates to %s\n", len(lcm.PromptTemplateNames()), dir)
 	return nil
 }
 
 func showActivePrompt(name, overrideDir string) error {
-	normalized, err := normalizePromptTemplateName(name)
+	normalized, err := lcm.NormalizePromptTemplateName(name)
 	if err != nil {
 		return err
 	}
-	content, source, err := loadPromptTemplateContent(normalized, overrideDir)
+	content, source, err := lcm.LoadPromptTemplateContent(normalized, overrideDir)
 	if err != nil {
 		return err
 	}
-	if source.kind == "filesystem" {
-		fmt.Printf("# Source: %s\n\n", source.path)
+	if source.Kind == lcm.PromptSourceFilesystem {
+		fmt.Printf("# Source: %s\n\n", source.Path)
 	} else {
 		fmt.Printf("# Source: embedded (%s)\n\n", normalized)
 	}
@@ -334,18 +438,18 @@ func showActivePrompt(name, overrideDir string) error {
 }
 
 func diffPromptTemplate(name, overrideDir string) error {
-	normalized, err := normalizePromptTemplateName(name)
+	normalized, err := lcm.NormalizePromptTemplateName(name)
 	if err != nil {
 		return err
 	}
-	overridePath, overrideContent, found, err := loadPromptOverrideContent(normalized, overrideDir)
+	overridePath, overrideContent, found, err := lcm.LoadPromptOverride(normalized, overrideDir)
 	if err != nil {
 		return err
 	}
 	if !found {
-		return fmt.Errorf("no override found for %s (checked %s)", normalized, strings.Join(promptCandidatePaths(normalized, overrideDir), ", "))
+		return fmt.Errorf("no override found for %s (checked %s)", normalized, strings.Join(lcm.PromptCandidatePaths(normalized, overrideDir), ", "))
 	}
-	embedded, err := readEmbeddedPromptTemplate(normalized)
+	embedded, err := lcm.DefaultPromptTemplate(normalized)
 	if err != nil {
 		return err
 	}
@@ -358,18 +462,18 @@ func diffPromptTemplate(name, overrideDir string) error {
 }
 
 func renderPromptTemplate(opts promptsOptions) error {
-	normalized, err := normalizePromptTemplateName(opts.renderName)
+	normalized, err := lcm.NormalizePromptTemplateName(opts.renderName)
 	if err != nil {
 		return err
 	}
 	depth := opts.depth
 	if depth < 0 {
-		depth, err = depthForPromptName(normalized)
+		depth, err = lcm.DepthForPromptName(normalized)
 		if err != nil {
 			return err
 		}
 	}
-	vars := PromptVars{
+	vars := lcm.PromptVars{
 		TargetTokens:    opts.targetTokens,
 		PreviousContext: opts.previousContext,
 		ChildCount:      opts.childCount,
@@ -377,189 +481,15 @@ func renderPromptTemplate(opts promptsOptions) error {
 		Depth:           depth,
 		SourceText:      opts.sourceText,
 	}
-	prompt, err := renderPromptByName(normalized, vars, opts.promptDir)
+	prompt, err := lcm.RenderPromptByName(normalized, vars, opts.promptDir)
 	if err != nil {
 		return err
 	}
+	// Print verbatim so the output is byte-for-byte what rewrite would send.
 	fmt.Print(prompt)
-	if !strings.HasSuffix(prompt, "\n") {
-		fmt.Println()
-	}
 	return nil
 }
 
-// promptNameForDepth maps summary depth to prompt filename.
-func promptNameForDepth(depth int) string {
-	switch {
-	case depth <= 0:
-		return "leaf.tmpl"
-	case depth == 1:
-		return "condensed-d1.tmpl"
-	case depth == 2:
-		return "condensed-d2.tmpl"
-	default:
-		return "condensed-d3.tmpl"
-	}
-}
-
-// loadPromptTemplate resolves prompt source and parses a template.
-func loadPromptTemplate(name, overrideDir string) (*template.Template, error) {
-	normalized, err := normalizePromptTemplateName(name)
-	if err != nil {
-		return nil, err
-	}
-	content, _, err := loadPromptTemplateContent(normalized, overrideDir)
-	if err != nil {
-		return nil, err
-	}
-	tmpl, err := template.New(normalized).Parse(content)
-	if err != nil {
-		return nil, fmt.Errorf("parse prompt template %s: %w", normalized, err)
-	}
-	return tmpl, nil
-}
-
-// renderPrompt loads the depth-mapped template and executes it with vars.
-func renderPrompt(depth int, vars PromptVars, overrideDir string) (string, error) {
-	name := promptNameForDepth(depth)
-	return renderPromptByName(name, vars, overrideDir)
-}
-
-func renderPromptByName(name string, vars PromptVars, overrideDir string) (string, error) {
-	tmpl, err := loadPromptTemplate(name, overrideDir)
-	if err != nil {
-		return "", err
-	}
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, vars); err != nil {
-		return "", fmt.Errorf("execute prompt template %s: %w", name, err)
-	}
-	return buf.String(), nil
-}
-
-func resolvePromptSource(name, overrideDir string) (promptSource, error) {
-	normalized, err := normalizePromptTemplateName(name)
-	if err != nil {
-		return promptSource{}, err
-	}
-	_, source, err := loadPromptTemplateContent(normalized, overrideDir)
-	if err != nil {
-		return promptSource{}, err
-	}
-	return source, nil
-}
-
-func loadPromptTemplateContent(name, overrideDir string) (string, promptSource, error) {
-	for _, path := range promptCandidatePaths(name, overrideDir) {
-		data, err := os.ReadFile(path)
-		if err == nil {
-			return string(data), promptSource{name: name, kind: "filesystem", path: path}, nil
-		}
-		if !errors.Is(err, os.ErrNotExist) {
-			return "", promptSource{}, fmt.Errorf("read prompt template %s: %w", path, err)
-		}
-	}
-	content, err := readEmbeddedPromptTemplate(name)
-	if err != nil {
-		return "", promptSource{}, err
-	}
-	return content, promptSource{name: name, kind: "embedded", path: "prompts/" + name}, nil
-}
-
-func loadPromptOverrideContent(name, overrideDir string) (path, content string, found bool, err error) {
-	for _, candidate := range promptCandidatePaths(name, overrideDir) {
-		data, readErr := os.ReadFile(candidate)
-		if readErr == nil {
-			return candidate, string(data), true, nil
-		}
-		if !errors.Is(readErr, os.ErrNotExist) {
-			return "", "", false, fmt.Errorf("read prompt override %s: %w", candidate, readErr)
-		}
-	}
-	return "", "", false, nil
-}
-
-func readEmbeddedPromptTemplate(name string) (string, error) {
-	normalized, err := normalizePromptTemplateName(name)
-	if err != nil {
-		return "", err
-	}
-	data, err := defaultPromptFS.ReadFile("prompts/" + normalized)
-	if err != nil {
-		return "", fmt.Errorf("read embedded prompt template %s: %w", normalized, err)
-	}
-	return string(data), nil
-}
-
-func promptCandidatePaths(name, overrideDir string) []string {
-	paths := make([]string, 0, 2)
-	if strings.TrimSpace(overrideDir) != "" {
-		paths = append(paths, filepath.Join(expandHomePath(overrideDir), name))
-	}
-	defaultPath := filepath.Join(expandHomePath(defaultPromptOverrideDir), name)
-	if len(paths) == 0 || paths[0] != defaultPath {
-		paths = append(paths, defaultPath)
-	}
-	return paths
-}
-
-func normalizePromptTemplateName(name string) (string, error) {
-	trimmed := strings.TrimSpace(strings.ToLower(name))
-	if trimmed == "" {
-		return "", fmt.Errorf("template name is required")
-	}
-	if !strings.HasSuffix(trimmed, ".tmpl") {
-		trimmed += ".tmpl"
-	}
-	for _, candidate := range promptTemplateNames {
-		if candidate == trimmed {
-			return trimmed, nil
-		}
-	}
-	return "", fmt.Errorf("unknown prompt template %q", name)
-}
-
-func depthForPromptName(name string) (int, error) {
-	normalized, err := normalizePromptTemplateName(name)
-	if err != nil {
-		return 0, err
-	}
-	switch normalized {
-	case "leaf.tmpl":
-		return 0, nil
-	case "condensed-d1.tmpl":
-		return 1, nil
-	case "condensed-d2.tmpl":
-		return 2, nil
-	case "condensed-d3.tmpl":
-		return 3, nil
-	default:
-		return 0, fmt.Errorf("unsupported prompt template %s", normalized)
-	}
-}
-
-func expandHomePath(path string) string {
-	trimmed := strings.TrimSpace(path)
-	if trimmed == "" {
-		return trimmed
-	}
-	if trimmed == "~" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return trimmed
-		}
-		return home
-	}
-	if !strings.HasPrefix(trimmed, "~/") {
-		return trimmed
-	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return trimmed
-	}
-	return filepath.Join(home, strings.TrimPrefix(trimmed, "~/"))
-}
-
 type diffOp struct {
 	kind byte
 	line string
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// promptBundleFormat identifies a prompts --export-bundle file.
+const promptBundleFormat = "lcm-tui-prompts"
+
+// promptBundle is the whole active prompt set in one JSON document. The
+// JSON tags are the bundle file format.
+type promptBundle struct {
+	Format     string                 `json:"format"`
+	Version    int                    `json:"version"`
+	ExportedAt string                 `json:"exported_at"`
+	Templates  []promptBundleTemplate `json:"templates"`
+}
+
+// promptBundleTemplate is one template. Source is "embedded" or the override
+// path it was read from on the exporting machine.
+type promptBundleTemplate struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Content string `json:"content"`
+}
+
+// exportPromptBundle writes every active template, override or embedded,
+// to path ("-" for stdout).
+func exportPromptBundle(path, overrideDir string, stdout io.Writer) error {
+	bundle := promptBundle{
+		Format:     promptBundleFormat,
+		Version:    1,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	overrides := 0
+	for _, name := range lcm.PromptTemplateNames() {
+		content, source, err := lcm.LoadPromptTemplateContent(name, overrideDir)
+		if err != nil {
+			return err
+		}
+		entry := promptBundleTemplate{Name: name, Source: lcm.PromptSourceEmbedded, Content: content}
+		if source.Kind == lcm.PromptSourceFilesystem {
+			entry.Source = source.Path
+			overrides++
+		}
+		bundle.Templates = append(bundle.Templates, entry)
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode prompt bundle: %w", err)
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write prompt bundle %q: %w", path, err)
+	}
+	fmt.Fprintf(stdout, "Exported %d prompt templates (%d overrides, %d embedded) to %s\n",
+		len(bundle.Templates), overrides, len(bundle.Templates)-overrides, path)
+	return nil
+}
+
+// importPromptBundle validates every template in the bundle at path, then
+// writes them into dir. Nothing is written when any template fails. A
+// template identical to this build's embedded default is not written, and
+// any existing override for it is removed, so it follows the embedded
+// version from then on.
+func importPromptBundle(path, dir string, stdout io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read prompt bundle %q: %w", path, err)
+	}
+	var bundle promptBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("parse prompt bundle %q: %w", path, err)
+	}
+	if bundle.Format != promptBundleFormat {
+		return fmt.Errorf("%s is not a prompt bundle (format %q, want %q)", path, bundle.Format, promptBundleFormat)
+	}
+	if bundle.Version != 1 {
+		return fmt.Errorf("unsupported prompt bundle version %d in %s", bundle.Version, path)
+	}
+	if len(bundle.Templates) == 0 {
+		return fmt.Errorf("prompt bundle %s has no templates", path)
+	}
+
+	seen := make(map[string]bool)
+	var problems []string
+	for i, entry := range bundle.Templates {
+		name, err := lcm.NormalizePromptTemplateName(entry.Name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if seen[name] {
+			problems = append(problems, fmt.Sprintf("%s appears more than once", name))
+			continue
+		}
+		seen[name] = true
+		bundle.Templates[i].Name = name
+		if err := lcm.ValidatePromptTemplate(name, entry.Content); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("prompt bundle %s failed validation; nothing written:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create prompt dir %q: %w", dir, err)
+	}
+	written := 0
+	for _, entry := range bundle.Templates {
+		target := filepath.Join(dir, entry.Name)
+		existing, readErr := os.ReadFile(target)
+		if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+			return fmt.Errorf("read %s: %w", target, readErr)
+		}
+		hasOverride := readErr == nil
+
+		embedded, err := lcm.DefaultPromptTemplate(entry.Name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.Content == embedded:
+			if !hasOverride {
+				fmt.Fprintf(stdout, "%-18s embedded default, not written\n", entry.Name)
+				continue
+			}
+			if err := os.Remove(target); err != nil {
+				return fmt.Errorf("remove %s: %w", target, err)
+			}
+			fmt.Fprintf(stdout, "%-18s embedded default; removed override %s\n", entry.Name, target)
+			written++
+			continue
+		case hasOverride && string(existing) == entry.Content:
+			fmt.Fprintf(stdout, "%-18s unchanged %s\n", entry.Name, target)
+			continue
+		}
+		if err := os.WriteFile(target, []byte(entry.Content), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", target, err)
+		}
+		action := "created"
+		if hasOverride {
+			action = "replaced"
+		}
+		fmt.Fprintf(stdout, "%-18s %s %s (from %s)\n", entry.Name, action, target, entry.Source)
+		written++
+	}
+	fmt.Fprintf(stdout, "Imported %d of %d templates from %s into %s\n", written, len(bundle.Templates), path, dir)
+	return nil
+}
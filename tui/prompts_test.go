@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePromptsRenderInputsReadsStdinAndFiles(t *testing.T) {
+	t.Parallel()
+
+	sourcePath := filepath.Join(t.TempDir(), "source.txt")
+	source := "[user] first paragraph\n\n[assistant] second paragraph\n"
+	if err := os.WriteFile(sourcePath, []byte(source), 0o644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	opts, err := parsePromptsArgs([]string{"--render", "leaf", "--source-file", sourcePath, "--previous-context", "-"})
+	if err != nil {
+		t.Fatalf("parse prompts args: %v", err)
+	}
+	if err := resolvePromptsRenderInputs(&opts, strings.NewReader("line one\nline two\n")); err != nil {
+		t.Fatalf("resolve render inputs: %v", err)
+	}
+
+	if want := "[user] first paragraph\n\n[assistant] second paragraph"; opts.sourceText != want {
+		t.Fatalf("source text = %q, want %q", opts.sourceText, want)
+	}
+	if want := "line one\nline two"; opts.previousContext != want {
+		t.Fatalf("previous context = %q, want %q", opts.previousContext, want)
+	}
+}
+
+func TestResolvePromptsRenderInputsKeepsInlineText(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parsePromptsArgs([]string{"--render", "leaf", "--source-text", "inline source"})
+	if err != nil {
+		t.Fatalf("parse prompts args: %v", err)
+	}
+	if err := resolvePromptsRenderInputs(&opts, strings.NewReader("unused")); err != nil {
+		t.Fatalf("resolve render inputs: %v", err)
+	}
+	if opts.sourceText != "inline source" {
+		t.Fatalf("source text = %q, want inline source", opts.sourceText)
+	}
+	if opts.previousContext != "" {
+		t.Fatalf("previous context = %q, want empty", opts.previousContext)
+	}
+}
+
+func TestParsePromptsArgsRejectsConflictingSourceInputs(t *testing.T) {
+	t.Parallel()
+
+	_, err := parsePromptsArgs([]string{"--render", "leaf", "--source-text", "x", "--source-file", "source.txt"})
+	if err == nil || !strings.Contains(err.Error(), "use only one of --source-text or --source-file") {
+		t.Fatalf("expected conflicting source error, got %v", err)
+	}
+
+	_, err = parsePromptsArgs([]string{"--render", "leaf", "--source-text", "-", "--previous-context", "-"})
+	if err == nil || !strings.Contains(err.Error(), "can read from stdin") {
+		t.Fatalf("expected stdin conflict error, got %v", err)
+	}
+}
+
+func TestPromptBundleRoundTripValidatesBeforeWriting(t *testing.T) {
+	src := t.TempDir()
+	custom := "Custom leaf prompt, {{.TargetTokens}} tokens.\n{{.SourceText}}\n"
+	if err := os.WriteFile(filepath.Join(src, "leaf.tmpl"), []byte(custom), 0o644); err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "prompts.json")
+	if err := exportPromptBundle(bundlePath, src, io.Discard); err != nil {
+		t.Fatalf("export bundle: %v", err)
+	}
+
+	dst := t.TempDir()
+	if err := importPromptBundle(bundlePath, dst, io.Discard); err != nil {
+		t.Fatalf("import bundle: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dst, "leaf.tmpl"))
+	if err != nil || string(got) != custom {
+		t.Fatalf("imported leaf.tmpl = %q (%v)", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "condensed-d1.tmpl")); !os.IsNotExist(err) {
+		t.Fatalf("embedded default should not be written, stat err = %v", err)
+	}
+
+	stale := filepath.Join(dst, "condensed-d1.tmpl")
+	if err := os.WriteFile(stale, []byte("Old override.\n{{.SourceText}}\n"), 0o644); err != nil {
+		t.Fatalf("write stale override: %v", err)
+	}
+	if err := importPromptBundle(bundlePath, dst, io.Discard); err != nil {
+		t.Fatalf("re-import bundle: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("override matching the embedded default should be removed, stat err = %v", err)
+	}
+
+	bad := `{"format":"lcm-tui-prompts","version":1,"templates":[` +
+		`{"name":"leaf","content":"ok"},{"name":"condensed-d1","content":"{{.NoSuchField}}"}]}`
+	badPath := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(badPath, []byte(bad), 0o644); err != nil {
+		t.Fatalf("write bad bundle: %v", err)
+	}
+	empty := t.TempDir()
+	if err := importPromptBundle(badPath, empty, io.Discard); err == nil || !strings.Contains(err.Error(), "condensed-d1.tmpl") {
+		t.Fatalf("expected validation error naming condensed-d1.tmpl, got %v", err)
+	}
+	if entries, _ := os.ReadDir(empty); len(entries) != 0 {
+		t.Fatalf("failed import wrote %d files", len(entries))
+	}
+}
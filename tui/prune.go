@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type pruneOptions struct {
+	apply       bool
+	verbose     bool
+	orphansOnly bool
+	backupDB    bool
+}
+
+// pruneSummary is one summary that is neither in context nor reachable from
+// a context summary's DAG.
+type pruneSummary struct {
+	summaryID  string
+	kind       string
+	depth      int
+	tokenCount int
+}
+
+// prunePlan splits a conversation's summaries into those still needed and
+// those fully absorbed. A summary is kept when it is in context_items (of any
+// conversation), referenced by a focus brief, referenced as a parent by a
+// summary outside this conversation, or reachable through summary_parents
+// from any of those.
+type prunePlan struct {
+	conversationID  int64
+	totalSummaries  int
+	contextRoots    int
+	keptSummaries   int
+	prunable        []pruneSummary
+	prunableTokens  int
+	messageLinks    int // summary_messages rows owned by prunable summaries
+	parentLinks     int // summary_parents rows touching prunable summaries
+	ftsTables       []string
+	focusBriefTable bool
+	// orphansOnly narrows prunable to true orphans: unreachable summaries
+	// that no summary_parents edge names as a source either. referenced
+	// counts the unreachable summaries left out because one still does.
+	orphansOnly bool
+	referenced  int
+}
+
+// runPruneCommand executes the standalone prune CLI path.
+func runPruneCommand(args []string) error {
+	opts, conversationID, err := parsePruneArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	build := buildPrunePlan
+	if opts.orphansOnly {
+		build = buildOrphanPrunePlan
+	}
+	plan, err := build(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printPrunePlan(plan, opts.verbose)
+	if len(plan.prunable) == 0 {
+		return nil
+	}
+
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to delete.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "prune", opts.backupDB, nil); err != nil {
+		return err
+	}
+	fmt.Println("\nApplying...")
+	deleted, err := applyPrunePlan(ctx, db, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Deleted %d summaries. Active context is unchanged.\n", deleted)
+	return nil
+}
+
+func printPrunePlan(plan prunePlan, verbose bool) {
+	fmt.Printf("Conversation %d: %d summaries, %d in context, %d kept (in context or reachable from it)\n",
+		plan.conversationID, plan.totalSummaries, plan.contextRoots, plan.keptSummaries)
+	if plan.referenced > 0 {
+		fmt.Printf("Skipped %d absorbed summaries that other summaries still list as sources; prune without --orphans-only removes them.\n", plan.referenced)
+	}
+	if len(plan.prunable) == 0 {
+		fmt.Println("Nothing to prune.")
+		return
+	}
+
+	byDepth := make(map[int]int)
+	for _, item := range plan.prunable {
+		byDepth[item.depth]++
+	}
+	depths := make([]int, 0, len(byDepth))
+	for depth := range byDepth {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	parts := make([]string, 0, len(depths))
+	for _, depth := range depths {
+		parts = append(parts, fmt.Sprintf("d%d: %d", depth, byDepth[depth]))
+	}
+
+	label := "absorbed"
+	if plan.orphansOnly {
+		label = "orphaned"
+	}
+	fmt.Printf("Prunable: %d %s summaries (%dt) — %s\n", len(plan.prunable), label, plan.prunableTokens, strings.Join(parts, ", "))
+	fmt.Printf("Reclaimable rows: %d summaries, %d summary_messages, %d summary_parents\n",
+		len(plan.prunable), plan.messageLinks, plan.parentLinks)
+	if verbose {
+		for _, item := range plan.prunable {
+			fmt.Printf("  %s (%s, d%d, %dt)\n", item.summaryID, item.kind, item.depth, item.tokenCount)
+		}
+	}
+}
+
+// printOrphanSummaries is check-context's report of an orphan-only plan.
+func printOrphanSummaries(plan prunePlan) {
+	if len(plan.prunable) == 0 {
+		fmt.Printf("Conversation %d: no orphaned summaries.\n", plan.conversationID)
+		return
+	}
+	fmt.Printf("Conversation %d: %d orphaned summaries (%dt) that no context item, focus brief, or summary references:\n",
+		plan.conversationID, len(plan.prunable), plan.prunableTokens)
+	for _, item := range plan.prunable {
+		fmt.Printf("  %s (%s, d%d, %dt)\n", item.summaryID, item.kind, item.depth, item.tokenCount)
+	}
+}
+
+// buildPrunePlan computes the absorbed summaries for a conversation without
+// mutating the DB.
+func buildPrunePlan(ctx context.Context, db *sql.DB, conversationID int64) (prunePlan, error) {
+	return preparePrunePlan(ctx, db, prunePlan{conversationID: conversationID})
+}
+
+// buildOrphanPrunePlan is buildPrunePlan limited to true orphans: summaries
+// referenced by nothing at all, typically left by a failed compaction or
+// transplant. A summary that any other summary lists as a source is not an
+// orphan, even when that summary is itself unreachable.
+func buildOrphanPrunePlan(ctx context.Context, db *sql.DB, conversationID int64) (prunePlan, error) {
+	return preparePrunePlan(ctx, db, prunePlan{conversationID: conversationID, orphansOnly: true})
+}
+
+func preparePrunePlan(ctx context.Context, db *sql.DB, plan prunePlan) (prunePlan, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		return prunePlan{}, fmt.Errorf("check prune schema: %w", err)
+	}
+	plan.focusBriefTable = caps.HasTable("focus_brief_sources")
+	for _, table := range []string{"summaries_fts", "summaries_fts_cjk"} {
+		if caps.HasTable(table) {
+			plan.ftsTables = append(plan.ftsTables, table)
+		}
+	}
+	if err := plan.load(ctx, db); err != nil {
+		return prunePlan{}, err
+	}
+	return plan, nil
+}
+
+// load fills the summary sets for plan.conversationID from q. Apply reruns
+// it inside its transaction to recheck the plan.
+func (plan *prunePlan) load(ctx context.Context, q sqlQueryer) error {
+	conversationID := plan.conversationID
+	summaries := make(map[string]pruneSummary)
+	rows, err := q.QueryContext(ctx, `
+		SELECT summary_id, kind, COALESCE(depth, 0), COALESCE(token_count, 0)
+		FROM summaries
+		WHERE conversation_id = ?
+	`, conversationID)
+	if err != nil {
+		return fmt.Errorf("query summaries for conversation %d: %w", conversationID, err)
+	}
+	for rows.Next() {
+		var item pruneSummary
+		if err := rows.Scan(&item.summaryID, &item.kind, &item.depth, &item.tokenCount); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan summary row: %w", err)
+		}
+		summaries[item.summaryID] = item
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate summary rows: %w", err)
+	}
+	rows.Close()
+
+	rootQuery := `
+		SELECT ci.summary_id, ci.conversation_id = ?
+		FROM context_items ci
+		JOIN summaries s ON s.summary_id = ci.summary_id
+		WHERE s.conversation_id = ? AND ci.summary_id IS NOT NULL
+		UNION ALL
+		SELECT sp.parent_summary_id, 0
+		FROM summary_parents sp
+		JOIN summaries parent ON parent.summary_id = sp.parent_summary_id
+		LEFT JOIN summaries child ON child.summary_id = sp.summary_id
+		WHERE parent.conversation_id = ?
+		  AND COALESCE(child.conversation_id, -1) != parent.conversation_id`
+	rootArgs := []any{conversationID, conversationID, conversationID}
+	if plan.focusBriefTable {
+		rootQuery += `
+		UNION ALL
+		SELECT fbs.summary_id, 0
+		FROM focus_brief_sources fbs
+		JOIN summaries s ON s.summary_id = fbs.summary_id
+		WHERE s.conversation_id = ?`
+		rootArgs = append(rootArgs, conversationID)
+	}
+	rows, err = q.QueryContext(ctx, rootQuery, rootArgs...)
+	if err != nil {
+		return fmt.Errorf("query retained summaries for conversation %d: %w", conversationID, err)
+	}
+	keep := make(map[string]bool)
+	queue := make([]string, 0, 16)
+	contextRoots := make(map[string]bool)
+	for rows.Next() {
+		var summaryID string
+		var ownContext bool
+		if err := rows.Scan(&summaryID, &ownContext); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan retained summary row: %w", err)
+		}
+		if ownContext {
+			contextRoots[summaryID] = true
+		}
+		if !keep[summaryID] {
+			keep[summaryID] = true
+			queue = append(queue, summaryID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate retained summary rows: %w", err)
+	}
+	rows.Close()
+
+	edges := make(map[string][]string)
+	rows, err = q.QueryContext(ctx, `
+		SELECT sp.summary_id, sp.parent_summary_id
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.summary_id
+		WHERE s.conversation_id = ?
+	`, conversationID)
+	if err != nil {
+		return fmt.Errorf("query summary parents for conversation %d: %w", conversationID, err)
+	}
+	for rows.Next() {
+		var child, parent string
+		if err := rows.Scan(&child, &parent); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan summary parent row: %w", err)
+		}
+		edges[child] = append(edges[child], parent)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate summary parent rows: %w", err)
+	}
+	rows.Close()
+
+	// Everything a kept summary was built from must stay so it can still be
+	// dissolved or rewritten from its sources.
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, parent := range edges[current] {
+			if !keep[parent] {
+				keep[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	plan.totalSummaries = len(summaries)
+	plan.contextRoots = len(contextRoots)
+	plan.keptSummaries = 0
+	plan.prunable = plan.prunable[:0]
+	plan.prunableTokens = 0
+	plan.referenced = 0
+	for id, item := range summaries {
+		if keep[id] {
+			plan.keptSummaries++
+			continue
+		}
+		if plan.orphansOnly {
+			var sources int
+			if err := q.QueryRowContext(ctx, `
+				SELECT COUNT(*) FROM summary_parents WHERE parent_summary_id = ?
+			`, id).Scan(&sources); err != nil {
+				return fmt.Errorf("count references to %s: %w", id, err)
+			}
+			if sources > 0 {
+				plan.referenced++
+				continue
+			}
+		}
+		plan.prunable = append(plan.prunable, item)
+		plan.prunableTokens += item.tokenCount
+	}
+	sort.Slice(plan.prunable, func(i, j int) bool {
+		if plan.prunable[i].depth != plan.prunable[j].depth {
+			return plan.prunable[i].depth > plan.prunable[j].depth
+		}
+		return plan.prunable[i].summaryID < plan.prunable[j].summaryID
+	})
+
+	plan.messageLinks = 0
+	plan.parentLinks = 0
+	for _, item := range plan.prunable {
+		var messages, parents int
+		if err := q.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM summary_messages WHERE summary_id = ?
+		`, item.summaryID).Scan(&messages); err != nil {
+			return fmt.Errorf("count summary messages for %s: %w", item.summaryID, err)
+		}
+		if err := q.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM summary_parents WHERE summary_id = ?
+		`, item.summaryID).Scan(&parents); err != nil {
+			return fmt.Errorf("count summary parents for %s: %w", item.summaryID, err)
+		}
+		plan.messageLinks += messages
+		plan.parentLinks += parents
+	}
+	return nil
+}
+
+// applyPrunePlan recomputes the plan inside a transaction, refuses to
+// continue if the prunable set changed since the dry run, and deletes the
+// absorbed summaries with their link rows. Prunable summaries are deleted
+// highest depth first; by construction no kept summary lists one as a parent.
+func applyPrunePlan(ctx context.Context, db *sql.DB, plan prunePlan) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin prune transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	current := prunePlan{
+		conversationID:  plan.conversationID,
+		ftsTables:       plan.ftsTables,
+		focusBriefTable: plan.focusBriefTable,
+		orphansOnly:     plan.orphansOnly,
+	}
+	if err := current.load(ctx, tx); err != nil {
+		return 0, err
+	}
+	if !samePruneSet(plan.prunable, current.prunable) {
+		return 0, errors.New("prunable summaries changed since the plan was built; rerun the dry run")
+	}
+
+	for _, item := range current.prunable {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM summary_parents WHERE summary_id = ? OR parent_summary_id = ?
+		`, item.summaryID, item.summaryID); err != nil {
+			return 0, fmt.Errorf("delete summary_parents for %s: %w", item.summaryID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM summary_messages WHERE summary_id = ?
+		`, item.summaryID); err != nil {
+			return 0, fmt.Errorf("delete summary_messages for %s: %w", item.summaryID, err)
+		}
+		for _, table := range current.ftsTables {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE summary_id = ?`, table), item.summaryID); err != nil {
+				return 0, fmt.Errorf("delete %s row for %s: %w", table, item.summaryID, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM summaries WHERE summary_id = ?
+		`, item.summaryID); err != nil {
+			return 0, fmt.Errorf("delete summary %s: %w", item.summaryID, err)
+		}
+	}
+
+	summaryIDs := make([]string, 0, len(current.prunable))
+	prunedTokens := 0
+	for _, item := range current.prunable {
+		summaryIDs = append(summaryIDs, item.summaryID)
+		prunedTokens += item.tokenCount
+	}
+	command, label := "prune", "absorbed"
+	if current.orphansOnly {
+		command, label = "prune --orphans-only", "orphaned"
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: command, ConversationID: plan.conversationID, SummaryIDs: summaryIDs,
+		TokensBefore: prunedTokens, Detail: fmt.Sprintf("deleted %d %s summaries", len(summaryIDs), label),
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit prune transaction: %w", err)
+	}
+	rollback = false
+	return len(current.prunable), nil
+}
+
+func samePruneSet(a, b []pruneSummary) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[string]bool, len(a))
+	for _, item := range a {
+		ids[item.summaryID] = true
+	}
+	for _, item := range b {
+		if !ids[item.summaryID] {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePruneArgs(args []string) (pruneOptions, int64, error) {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	apply := fs.Bool("apply", false, "delete absorbed summaries")
+	verbose := fs.Bool("verbose", false, "list every prunable summary")
+	orphansOnly := fs.Bool("orphans-only", false, "only delete summaries that nothing references")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized := normalizePruneArgs(args)
+	if err := fs.Parse(normalized); err != nil {
+		return pruneOptions{}, 0, fmt.Errorf("%w\n%s", err, pruneUsageText())
+	}
+	if fs.NArg() != 1 {
+		return pruneOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", pruneUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return pruneOptions{}, 0, fmt.Errorf("parse conversation ID %q: %w\n%s", fs.Arg(0), err, pruneUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return pruneOptions{}, 0, err
+	}
+	return pruneOptions{apply: *apply, verbose: *verbose, orphansOnly: *orphansOnly, backupDB: backup}, conversationID, nil
+}
+
+func normalizePruneArgs(args []string) []string {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			flags = append(flags, arg)
+			continue
+		}
+		positionals = append(positionals, arg)
+	}
+	return append(flags, positionals...)
+}
+
+func pruneUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui prune <conversation_id> [--apply] [--verbose] [--orphans-only] [--backup-db]
+
+Find summaries that are neither in the active context nor reachable from a
+context summary's DAG (fully absorbed intermediates), and report the rows
+they occupy. With --apply, delete them along with their summary_messages and
+summary_parents rows. Context summaries and everything they were built from
+are always kept, so dissolve and rewrite keep working.
+
+With --orphans-only, delete only true orphans: absorbed summaries that no
+summary_parents edge names as a source either. They are dead weight from
+failed operations, and removing them cannot change the active context or
+any surviving summary's sources.
+
+Flags:
+  --apply          Delete the absorbed summaries (default: dry run)
+  --verbose        List every prunable summary
+  --orphans-only   Limit the plan to summaries that nothing references
+  --backup-db      With --apply, copy lcm.db to a timestamped backup first
+`)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPruneDeletesOnlyAbsorbedSummaries(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-prune', 'Prune')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 1, 'user', 'one', 1, '2026-03-22T10:00:00Z'),
+			(2, 1, 2, 'user', 'two', 1, '2026-03-22T10:01:00Z'),
+			(3, 1, 3, 'user', 'three', 1, '2026-03-22T10:02:00Z'),
+			(5, 1, 5, 'user', 'five', 1, '2026-03-22T10:04:00Z')
+	`)
+	// sum_c1 (in context) was built from l1+l2. sum_c2 was built from l2+l3
+	// and then superseded, so it and l3 are absorbed; l2 stays under sum_c1.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_l1', 1, 'leaf', 0, 'l1', 10, '2026-03-22T10:00:00Z'),
+			('sum_l2', 1, 'leaf', 0, 'l2', 10, '2026-03-22T10:01:00Z'),
+			('sum_l3', 1, 'leaf', 0, 'l3', 11, '2026-03-22T10:02:00Z'),
+			('sum_l5', 1, 'leaf', 0, 'l5', 10, '2026-03-22T10:04:00Z'),
+			('sum_c1', 1, 'condensed', 1, 'c1', 12, '2026-03-22T10:05:00Z'),
+			('sum_c2', 1, 'condensed', 1, 'c2', 13, '2026-03-22T10:06:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_l1', 1, 0), ('sum_l2', 2, 0), ('sum_l3', 3, 0), ('sum_l5', 5, 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES
+			('sum_c1', 'sum_l1', 0), ('sum_c1', 'sum_l2', 1),
+			('sum_c2', 'sum_l2', 0), ('sum_c2', 'sum_l3', 1)
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES (1, 0, 'summary', NULL, 'sum_c1'), (1, 1, 'summary', NULL, 'sum_l5')
+	`)
+
+	plan, err := buildPrunePlan(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	var ids []string
+	for _, item := range plan.prunable {
+		ids = append(ids, item.summaryID)
+	}
+	if strings.Join(ids, ",") != "sum_c2,sum_l3" {
+		t.Fatalf("unexpected prunable summaries %v", ids)
+	}
+	if plan.contextRoots != 2 || plan.keptSummaries != 4 || plan.prunableTokens != 24 {
+		t.Fatalf("unexpected plan stats %+v", plan)
+	}
+	if plan.messageLinks != 1 || plan.parentLinks != 2 {
+		t.Fatalf("unexpected reclaimable link rows %+v", plan)
+	}
+
+	deleted, err := applyPrunePlan(ctx, db, plan)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("deleted = %d, want 2", deleted)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = 1`, 4)
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id IN ('sum_c2', 'sum_l3')`, 0)
+	assertCount(t, db, `SELECT COUNT(*) FROM summary_parents`, 2)
+	assertCount(t, db, `SELECT COUNT(*) FROM summary_messages`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 1`, 4)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1`, 2)
+
+	again, err := buildPrunePlan(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("rebuild plan: %v", err)
+	}
+	if len(again.prunable) != 0 {
+		t.Fatalf("expected nothing left to prune, got %+v", again.prunable)
+	}
+}
+
+func TestApplyPrunePlanRefusesStalePlan(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-stale', 'Stale')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_orphan', 1, 'leaf', 0, 'orphan', 10, '2026-03-22T10:00:00Z')
+	`)
+
+	plan, err := buildPrunePlan(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if len(plan.prunable) != 1 {
+		t.Fatalf("expected orphan to be prunable, got %+v", plan.prunable)
+	}
+
+	// The live plugin puts it back into context before apply runs.
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES (1, 0, 'summary', NULL, 'sum_orphan')
+	`)
+	if _, err := applyPrunePlan(ctx, db, plan); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Fatalf("expected stale plan error, got %v", err)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_orphan'`, 1)
+}
+
+func TestPruneOrphansOnlySkipsReferencedSummaries(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-orphans', 'Orphans')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 1, 'user', 'one', 1, '2026-03-22T10:00:00Z'),
+			(3, 1, 3, 'user', 'three', 1, '2026-03-22T10:02:00Z'),
+			(4, 1, 4, 'user', 'four', 1, '2026-03-22T10:03:00Z')
+	`)
+	// sum_c1 is in context. sum_c2 was superseded: it is an orphan, but its
+	// source sum_l3 is still named by it and so is not. sum_x was never
+	// linked to anything.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_l1', 1, 'leaf', 0, 'l1', 10, '2026-03-22T10:00:00Z'),
+			('sum_l3', 1, 'leaf', 0, 'l3', 11, '2026-03-22T10:02:00Z'),
+			('sum_x', 1, 'leaf', 0, 'x', 7, '2026-03-22T10:03:00Z'),
+			('sum_c1', 1, 'condensed', 1, 'c1', 12, '2026-03-22T10:05:00Z'),
+			('sum_c2', 1, 'condensed', 1, 'c2', 13, '2026-03-22T10:06:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_l1', 1, 0), ('sum_l3', 3, 0), ('sum_x', 4, 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_c1', 'sum_l1', 0), ('sum_c2', 'sum_l3', 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id)
+		VALUES (1, 0, 'summary', NULL, 'sum_c1')
+	`)
+
+	plan, err := buildOrphanPrunePlan(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	var ids []string
+	for _, item := range plan.prunable {
+		ids = append(ids, item.summaryID)
+	}
+	if strings.Join(ids, ",") != "sum_c2,sum_x" || plan.referenced != 1 {
+		t.Fatalf("unexpected orphans %v (referenced %d)", ids, plan.referenced)
+	}
+
+	deleted, err := applyPrunePlan(ctx, db, plan)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("deleted = %d, want 2", deleted)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id IN ('sum_l1', 'sum_l3', 'sum_c1')`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id IN ('sum_c2', 'sum_x')`, 0)
+	assertCount(t, db, `SELECT COUNT(*) FROM summary_messages WHERE summary_id = 'sum_x'`, 0)
+	assertCount(t, db, `SELECT COUNT(*) FROM summary_parents`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'prune --orphans-only'`, 1)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type rebuildContextOptions struct {
+	apply    bool
+	backupDB bool
+}
+
+// rebuildContextItem is one row of the proposed context_items.
+type rebuildContextItem struct {
+	summaryID  string // empty for a message
+	messageID  int64
+	depth      int
+	seq        int64
+	role       string
+	tokenCount int
+	earliestAt string
+}
+
+func (item rebuildContextItem) isSummary() bool {
+	return item.summaryID != ""
+}
+
+func (item rebuildContextItem) key() string {
+	if item.isSummary() {
+		return "s:" + item.summaryID
+	}
+	return "m:" + strconv.FormatInt(item.messageID, 10)
+}
+
+// rebuildContextPlan is the context_items a conversation would get if it
+// were rebuilt from its summary DAG: every root summary (one no condensed
+// summary absorbed) in chronological order of the span it covers, then the
+// raw messages after the last message any summary covers.
+type rebuildContextPlan struct {
+	conversationID int64
+	items          []rebuildContextItem
+	currentItems   int
+	currentTokens  int
+	added          int
+	dropped        int
+	// uncoveredGap counts messages no summary covers that sit before the
+	// last covered message; they are left out of the rebuilt context.
+	uncoveredGap    int
+	gapFirstSeq     int64
+	gapLastSeq      int64
+	lastCoveredSeq  int64
+	hasCoveredSeq   bool
+	proposedTokens  int
+	proposedSummary int
+}
+
+// buildRebuildContextPlan derives the proposed context from summaries,
+// summary_parents, and summary_messages, and compares it with the current
+// context_items. It never reads context_items to choose what to place, so a
+// damaged context cannot leak into the rebuilt one.
+func buildRebuildContextPlan(ctx context.Context, q sqlQueryer, conversationID int64) (rebuildContextPlan, error) {
+	plan := rebuildContextPlan{conversationID: conversationID}
+	var exists int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM conversations WHERE conversation_id = ?`, conversationID).Scan(&exists); err != nil {
+		return plan, fmt.Errorf("query conversation %d: %w", conversationID, err)
+	}
+	if exists == 0 {
+		return plan, fmt.Errorf("conversation %d not found", conversationID)
+	}
+
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return plan, err
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.summary_id, COALESCE(s.depth, 0), s.token_count, COALESCE(s.earliest_at, s.created_at)
+		FROM summaries s
+		WHERE s.conversation_id = ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM summary_parents sp WHERE sp.parent_summary_id = s.summary_id
+		  )
+		ORDER BY COALESCE(s.earliest_at, s.created_at) ASC, `+caps.SummarySeqExpr("s")+` ASC, s.summary_id ASC
+	`, conversationID)
+	if err != nil {
+		return plan, fmt.Errorf("query root summaries for %d: %w", conversationID, err)
+	}
+	for rows.Next() {
+		var item rebuildContextItem
+		if err := rows.Scan(&item.summaryID, &item.depth, &item.tokenCount, &item.earliestAt); err != nil {
+			rows.Close()
+			return plan, fmt.Errorf("scan root summary: %w", err)
+		}
+		plan.items = append(plan.items, item)
+		plan.proposedSummary++
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return plan, fmt.Errorf("iterate root summaries: %w", err)
+	}
+	rows.Close()
+
+	var lastCovered sql.NullInt64
+	if err := q.QueryRowContext(ctx, `
+		SELECT MAX(m.seq)
+		FROM summary_messages sm
+		JOIN summaries s ON s.summary_id = sm.summary_id
+		JOIN messages m ON m.message_id = sm.message_id
+		WHERE s.conversation_id = ? AND m.conversation_id = ?
+	`, conversationID, conversationID).Scan(&lastCovered); err != nil {
+		return plan, fmt.Errorf("query last covered message for %d: %w", conversationID, err)
+	}
+	plan.lastCoveredSeq = lastCovered.Int64
+	plan.hasCoveredSeq = lastCovered.Valid
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT m.message_id, m.seq, m.role, m.token_count
+		FROM messages m
+		WHERE m.conversation_id = ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM summary_messages sm
+			JOIN summaries s ON s.summary_id = sm.summary_id
+			WHERE sm.message_id = m.message_id AND s.conversation_id = m.conversation_id
+		  )
+		ORDER BY m.seq ASC
+	`, conversationID)
+	if err != nil {
+		return plan, fmt.Errorf("query uncovered messages for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item rebuildContextItem
+		if err := rows.Scan(&item.messageID, &item.seq, &item.role, &item.tokenCount); err != nil {
+			return plan, fmt.Errorf("scan uncovered message: %w", err)
+		}
+		if plan.hasCoveredSeq && item.seq < plan.lastCoveredSeq {
+			if plan.uncoveredGap == 0 {
+				plan.gapFirstSeq = item.seq
+			}
+			plan.uncoveredGap++
+			plan.gapLastSeq = item.seq
+			continue
+		}
+		plan.items = append(plan.items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return plan, fmt.Errorf("iterate uncovered messages: %w", err)
+	}
+	for _, item := range plan.items {
+		plan.proposedTokens += item.tokenCount
+	}
+
+	current, err := lcm.LoadContextItems(ctx, q, conversationID)
+	if err != nil {
+		return plan, err
+	}
+	plan.currentItems = len(current)
+	currentKeys := make(map[string]bool, len(current))
+	for _, item := range current {
+		plan.currentTokens += item.TokenCount
+		switch {
+		case item.SummaryID.Valid:
+			currentKeys["s:"+item.SummaryID.String] = true
+		case item.MessageID.Valid:
+			currentKeys["m:"+strconv.FormatInt(item.MessageID.Int64, 10)] = true
+		}
+	}
+	proposedKeys := make(map[string]bool, len(plan.items))
+	for _, item := range plan.items {
+		proposedKeys[item.key()] = true
+		if !currentKeys[item.key()] {
+			plan.added++
+		}
+	}
+	for key := range currentKeys {
+		if !proposedKeys[key] {
+			plan.dropped++
+		}
+	}
+	plan.dropped += len(current) - len(currentKeys) // duplicates and dangling rows
+	return plan, nil
+}
+
+// formatRebuildContextPlanLines renders the proposed context, one line per
+// item with its new ordinal, and a comparison with the current context.
+func formatRebuildContextPlanLines(plan rebuildContextPlan) []string {
+	lines := []string{
+		fmt.Sprintf("Conversation %d: current context has %d items (%d tokens).", plan.conversationID, plan.currentItems, plan.currentTokens),
+		fmt.Sprintf("Rebuilt context: %d items (%d root summaries, %d fresh-tail messages, %d tokens); %d added, %d dropped.",
+			len(plan.items), plan.proposedSummary, len(plan.items)-plan.proposedSummary, plan.proposedTokens, plan.added, plan.dropped),
+	}
+	for i, item := range plan.items {
+		if item.isSummary() {
+			lines = append(lines, fmt.Sprintf("  %4d  summary  %-24s d%d  %6d tok  from %s", i, item.summaryID, item.depth, item.tokenCount, formatTimestamp(item.earliestAt)))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %4d  message  #%-23d seq %d  %6d tok  %s", i, item.messageID, item.seq, item.tokenCount, item.role))
+	}
+	if plan.uncoveredGap > 0 {
+		lines = append(lines, fmt.Sprintf("Warning: %d messages (seq %d-%d) are covered by no summary but precede the last covered message (seq %d); they are left out of the rebuilt context.",
+			plan.uncoveredGap, plan.gapFirstSeq, plan.gapLastSeq, plan.lastCoveredSeq))
+	}
+	return lines
+}
+
+// runRebuildContextCommand executes the standalone rebuild-context CLI path.
+func runRebuildContextCommand(args []string) error {
+	opts, conversationID, err := parseRebuildContextArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildRebuildContextPlan(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, line := range formatRebuildContextPlanLines(plan) {
+		fmt.Println(line)
+	}
+	if len(plan.items) == 0 {
+		return fmt.Errorf("conversation %d has no summaries or messages to rebuild from; nothing changed", conversationID)
+	}
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to replace context_items with the rebuilt context.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "rebuild-context", opts.backupDB, nil); err != nil {
+		return err
+	}
+	fmt.Println("\nApplying...")
+	if err := applyRebuildContextPlan(ctx, db, plan); err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Rebuilt conversation %d context with %d items (ordinals 0..%d). Changes take effect on next conversation turn.\n",
+		conversationID, len(plan.items), len(plan.items)-1)
+	return nil
+}
+
+// applyRebuildContextPlan replaces the conversation's context_items with the
+// plan in one transaction. The plan is recomputed inside the transaction and
+// must match the previewed one, and the ordinals are checked for 0..N-1
+// before commit.
+func applyRebuildContextPlan(ctx context.Context, db *sql.DB, previewed rebuildContextPlan) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin rebuild-context transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	plan, err := buildRebuildContextPlan(ctx, tx, previewed.conversationID)
+	if err != nil {
+		return err
+	}
+	if strings.Join(formatRebuildContextPlanLines(plan), "\n") != strings.Join(formatRebuildContextPlanLines(previewed), "\n") {
+		return errors.New("the database changed since the preview; run rebuild-context again to review the new plan")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM context_items WHERE conversation_id = ?
+	`, plan.conversationID); err != nil {
+		return fmt.Errorf("clear context items for %d: %w", plan.conversationID, err)
+	}
+	var summaryIDs []string
+	for i, item := range plan.items {
+		if item.isSummary() {
+			summaryIDs = append(summaryIDs, item.summaryID)
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+				VALUES (?, ?, 'summary', ?)
+			`, plan.conversationID, i, item.summaryID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO context_items (conversation_id, ordinal, item_type, message_id)
+				VALUES (?, ?, 'message', ?)
+			`, plan.conversationID, i, item.messageID)
+		}
+		if err != nil {
+			return fmt.Errorf("insert context item at ordinal %d: %w", i, err)
+		}
+	}
+
+	ordinals, err := loadContextOrdinals(ctx, tx, plan.conversationID)
+	if err != nil {
+		return err
+	}
+	if err := checkContiguousOrdinals(ordinals); err != nil {
+		return err
+	}
+	if len(ordinals) != len(plan.items) {
+		return fmt.Errorf("rebuilt context has %d rows, expected %d", len(ordinals), len(plan.items))
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "rebuild-context", ConversationID: plan.conversationID, SummaryIDs: summaryIDs,
+		TokensBefore: plan.currentTokens, TokensAfter: plan.proposedTokens,
+		Detail: fmt.Sprintf("replaced %d context items with %d (%d added, %d dropped)", plan.currentItems, len(plan.items), plan.added, plan.dropped),
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rebuild-context transaction: %w", err)
+	}
+	rollback = false
+	return nil
+}
+
+func parseRebuildContextArgs(args []string) (rebuildContextOptions, int64, error) {
+	fs := flag.NewFlagSet("rebuild-context", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	apply := fs.Bool("apply", false, "replace context_items with the rebuilt context")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized := normalizePruneArgs(args)
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return rebuildContextOptions{}, 0, errors.New(rebuildContextUsageText())
+		}
+		return rebuildContextOptions{}, 0, fmt.Errorf("%w\n%s", err, rebuildContextUsageText())
+	}
+	if fs.NArg() != 1 {
+		return rebuildContextOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", rebuildContextUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return rebuildContextOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), rebuildContextUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return rebuildContextOptions{}, 0, err
+	}
+	return rebuildContextOptions{apply: *apply, backupDB: backup}, conversationID, nil
+}
+
+func rebuildContextUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui rebuild-context <conversation_id> [--apply] [--backup-db]
+
+Regenerate a conversation's context_items from its summary DAG when the
+context is damaged but the summaries are intact. The rebuilt context is
+every root summary (one no condensed summary has absorbed), oldest span
+first whatever its depth, followed by the raw messages after the last
+message any summary covers, in seq order. The current context_items
+are only compared against, never used as input.
+
+The dry run lists the proposed context with its new ordinals and how it
+differs from the current one. Messages that no summary covers but that sit
+before the last covered message are reported and left out.
+
+Flags:
+  --apply     Replace context_items in one transaction and verify ordinals
+              are 0..N-1 before committing (default: dry run)
+  --backup-db With --apply, copy lcm.db to a timestamped backup first
+`)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRebuildContextFromSummaryDAG(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 0, 'user', 'm0', 5, '2026-03-01T10:00:00Z'),
+			(2, 1, 1, 'assistant', 'm1', 5, '2026-03-01T10:01:00Z'),
+			(3, 1, 2, 'user', 'm2', 5, '2026-03-01T10:02:00Z'),
+			(4, 1, 3, 'assistant', 'm3', 5, '2026-03-01T10:03:00Z'),
+			(5, 1, 4, 'user', 'm4', 5, '2026-03-01T10:04:00Z'),
+			(6, 1, 5, 'assistant', 'm5', 5, '2026-03-01T10:05:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, earliest_at, created_at)
+		VALUES
+			('sum_l1', 1, 'leaf', 0, 'l1', 10, '2026-03-01T10:00:00Z', '2026-03-01T11:00:00Z'),
+			('sum_l2', 1, 'leaf', 0, 'l2', 10, '2026-03-01T10:02:00Z', '2026-03-01T11:01:00Z'),
+			('sum_l3', 1, 'leaf', 0, 'l3', 10, '2026-03-01T10:03:00Z', '2026-03-01T11:02:00Z'),
+			('sum_d1', 1, 'condensed', 1, 'd1', 20, '2026-03-01T10:00:00Z', '2026-03-01T12:00:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_l1', 1, 0), ('sum_l2', 3, 0), ('sum_l3', 4, 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_d1', 'sum_l1', 0), ('sum_d1', 'sum_l2', 1)
+	`)
+	// Damaged context: an absorbed leaf, a gap, and a dangling row.
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id, message_id)
+		VALUES (1, 0, 'summary', 'sum_l1', NULL), (1, 3, 'message', NULL, 6), (1, 7, 'summary', 'sum_missing', NULL)
+	`)
+	ctx := context.Background()
+
+	plan, err := buildRebuildContextPlan(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	var keys []string
+	for _, item := range plan.items {
+		keys = append(keys, item.key())
+	}
+	if got := strings.Join(keys, ","); got != "s:sum_d1,s:sum_l3,m:5,m:6" {
+		t.Fatalf("rebuilt context = %s", got)
+	}
+	if plan.added != 3 || plan.dropped != 2 {
+		t.Fatalf("added=%d dropped=%d, want 3 and 2", plan.added, plan.dropped)
+	}
+	if plan.uncoveredGap != 1 || plan.gapFirstSeq != 1 {
+		t.Fatalf("expected message seq 1 reported as an uncovered gap, got %d from seq %d", plan.uncoveredGap, plan.gapFirstSeq)
+	}
+
+	if err := applyRebuildContextPlan(ctx, db, plan); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1`, 4)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 0 AND summary_id = 'sum_d1'`, 1)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 1 AND ordinal = 3 AND message_id = 6`, 1)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'rebuild-context'`, 1)
+
+	stale := plan
+	stale.added++
+	if err := applyRebuildContextPlan(ctx, db, stale); err == nil || !strings.Contains(err.Error(), "changed since the preview") {
+		t.Fatalf("expected a stale preview to be refused, got %v", err)
+	}
+}
+
+func TestRebuildContextOrdersRootsChronologically(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	// An older leaf that was never condensed must stay ahead of a newer d1.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, earliest_at, created_at)
+		VALUES
+			('sum_old_leaf', 1, 'leaf', 0, 'old', 10, '2026-03-01T09:00:00Z', '2026-03-01T11:00:00Z'),
+			('sum_new_d1', 1, 'condensed', 1, 'new', 20, '2026-03-01T10:00:00Z', '2026-03-01T12:00:00Z'),
+			('sum_tie', 1, 'leaf', 0, 'tie', 10, NULL, '2026-03-01T12:00:00Z')
+	`)
+
+	plan, err := buildRebuildContextPlan(context.Background(), db, 1)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	var keys []string
+	for _, item := range plan.items {
+		keys = append(keys, item.key())
+	}
+	if got := strings.Join(keys, ","); got != "s:sum_old_leaf,s:sum_new_d1,s:sum_tie" {
+		t.Fatalf("rebuilt context = %s", got)
+	}
+}
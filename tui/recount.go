@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type recountOptions struct {
+	apply    bool
+	backupDB bool
+}
+
+// zeroTokenSummary is a summary stored with token_count = 0. The estimate is
+// what the plugin would have written for the same content.
+type zeroTokenSummary struct {
+	summaryID string
+	kind      string
+	depth     int
+	estimated int
+	preview   string
+}
+
+// badInsert reports whether the row looks like an insert that never set
+// token_count: condensed nodes are built from non-empty parents, so zero is
+// never a legitimate value for them.
+func (s zeroTokenSummary) badInsert() bool {
+	return s.kind == "condensed"
+}
+
+// findZeroTokenSummaries lists conversationID's summaries whose token_count
+// is zero or NULL, deepest first.
+func findZeroTokenSummaries(ctx context.Context, q sqlQueryer, conversationID int64) ([]zeroTokenSummary, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT summary_id, kind, depth, content
+		FROM summaries
+		WHERE conversation_id = ? AND COALESCE(token_count, 0) = 0
+		ORDER BY depth DESC, summary_id ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query zero-token summaries for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var summaries []zeroTokenSummary
+	for rows.Next() {
+		var s zeroTokenSummary
+		var content string
+		if err := rows.Scan(&s.summaryID, &s.kind, &s.depth, &content); err != nil {
+			return nil, fmt.Errorf("scan zero-token summary: %w", err)
+		}
+		s.estimated = lcm.EstimateTokenCount(content)
+		s.preview = previewForLog(sanitizeForTerminal(oneLine(content)), 60)
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate zero-token summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// recountSummaryTokens sets token_count from the stored content for each
+// summary ID that is still zero, in one transaction. Rows fixed concurrently
+// are left alone.
+func recountSummaryTokens(ctx context.Context, db *sql.DB, summaryIDs []string) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin recount transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	updated := 0
+	for _, summaryID := range summaryIDs {
+		var (
+			conversationID int64
+			content        string
+		)
+		err := tx.QueryRowContext(ctx, `
+			SELECT conversation_id, content FROM summaries
+			WHERE summary_id = ? AND COALESCE(token_count, 0) = 0
+		`, summaryID).Scan(&conversationID, &content)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("query content for %s: %w", summaryID, err)
+		}
+		tokens := lcm.EstimateTokenCount(content)
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE summaries SET token_count = ? WHERE summary_id = ?
+		`, tokens, summaryID); err != nil {
+			return 0, fmt.Errorf("update token_count for %s: %w", summaryID, err)
+		}
+		if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+			Command: "recount", ConversationID: conversationID, SummaryIDs: []string{summaryID}, TokensAfter: tokens,
+		}); err != nil {
+			return 0, err
+		}
+		updated++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit recount: %w", err)
+	}
+	rollback = false
+	return updated, nil
+}
+
+func printZeroTokenSummaries(conversationID int64, summaries []zeroTokenSummary) {
+	if len(summaries) == 0 {
+		fmt.Printf("Conversation %d: OK, every summary has a non-zero token_count.\n", conversationID)
+		return
+	}
+	badInserts := 0
+	for _, s := range summaries {
+		if s.badInsert() {
+			badInserts++
+		}
+	}
+	fmt.Printf("Conversation %d: %d summaries with token_count = 0 (%d condensed)\n\n", conversationID, len(summaries), badInserts)
+	for _, s := range summaries {
+		note := ""
+		if s.badInsert() {
+			note = "  [likely bad insert]"
+		}
+		fmt.Printf("  %-28s d%d %-9s 0t -> %dt%s  %s\n", s.summaryID, s.depth, s.kind, s.estimated, note, s.preview)
+	}
+}
+
+// runRecountCommand executes the standalone recount CLI path.
+func runRecountCommand(args []string) error {
+	opts, conversationID, err := parseRecountArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	summaries, err := findZeroTokenSummaries(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printZeroTokenSummaries(conversationID, summaries)
+	if len(summaries) == 0 {
+		return nil
+	}
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to set token_count from each summary's content.")
+		return nil
+	}
+
+	ids := make([]string, 0, len(summaries))
+	for _, s := range summaries {
+		ids = append(ids, s.summaryID)
+	}
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "recount", opts.backupDB, nil); err != nil {
+		return err
+	}
+	updated, err := recountSummaryTokens(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Recounted %d summaries. Changes take effect on next conversation turn.\n", updated)
+	return nil
+}
+
+func parseRecountArgs(args []string) (recountOptions, int64, error) {
+	fs := flag.NewFlagSet("recount", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	apply := fs.Bool("apply", false, "write estimated token counts")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized := normalizePruneArgs(args)
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return recountOptions{}, 0, errors.New(recountUsageText())
+		}
+		return recountOptions{}, 0, fmt.Errorf("%w\n%s", err, recountUsageText())
+	}
+	if fs.NArg() != 1 {
+		return recountOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", recountUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return recountOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), recountUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return recountOptions{}, 0, err
+	}
+	return recountOptions{apply: *apply, backupDB: backup}, conversationID, nil
+}
+
+func recountUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui recount <conversation_id> [--apply] [--backup-db]
+
+Report summaries stored with token_count = 0 (a failed recount or an insert
+that never set the field). They skew per-depth totals and context budgets.
+Zero-token condensed summaries are flagged as likely bad inserts. Read-only
+unless --apply is given.
+
+Flags:
+  --apply       Set token_count to the estimate from each summary's content
+  --backup-db   With --apply, copy lcm.db to a timestamped backup first
+`)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecountZeroTokenSummaries(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-recount', 'Recount')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_leaf_ok', 1, 'leaf', 0, 'already counted', 4, '2026-03-01 10:00:00'),
+			('sum_leaf_zero', 1, 'leaf', 0, 'twelve chars', 0, '2026-03-01 10:00:00'),
+			('sum_top_zero', 1, 'condensed', 1, 'sixteen chars!!!', 0, '2026-03-01 11:00:00')
+	`)
+
+	found, err := findZeroTokenSummaries(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("find zero-token summaries: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("found %d zero-token summaries, want 2", len(found))
+	}
+	if found[0].summaryID != "sum_top_zero" || !found[0].badInsert() || found[0].estimated != 4 {
+		t.Fatalf("first = %+v, want condensed sum_top_zero flagged as bad insert with estimate 4", found[0])
+	}
+	if found[1].summaryID != "sum_leaf_zero" || found[1].badInsert() || found[1].estimated != 3 {
+		t.Fatalf("second = %+v, want leaf sum_leaf_zero with estimate 3", found[1])
+	}
+
+	updated, err := recountSummaryTokens(ctx, db, []string{"sum_top_zero", "sum_leaf_zero", "sum_leaf_ok"})
+	if err != nil {
+		t.Fatalf("recount: %v", err)
+	}
+	if updated != 2 {
+		t.Fatalf("updated = %d, want 2 (non-zero rows untouched)", updated)
+	}
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM summaries WHERE token_count = 0`, 0)
+	assertCountQuery(t, db, `SELECT token_count FROM summaries WHERE summary_id = 'sum_leaf_ok'`, 4)
+	assertCountQuery(t, db, `SELECT token_count FROM summaries WHERE summary_id = 'sum_top_zero'`, 4)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// defaultRedactionConfigPath holds extra redaction patterns and can turn
+// redaction on for every command.
+const defaultRedactionConfigPath = "~/.config/lcm-tui/redact.json"
+
+// builtinRedactionPatterns cover secret formats that commonly end up in tool
+// output. Names appear in the replacement marker and in redaction counts.
+var builtinRedactionPatterns = []redactionPattern{
+	{Name: "anthropic-key", Pattern: `sk-ant-[A-Za-z0-9_-]{20,}`},
+	{Name: "openai-key", Pattern: `sk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{32,}`},
+	{Name: "aws-access-key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws-secret-key", Pattern: `(?i)aws_secret_access_key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`},
+	{Name: "github-token", Pattern: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`},
+	{Name: "slack-token", Pattern: `\bxox[abposr]-[A-Za-z0-9-]{10,}`},
+	{Name: "google-api-key", Pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "bearer-token", Pattern: `(?i)\bbearer\s+[A-Za-z0-9._~+/=-]{20,}`},
+	{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+}
+
+// redactionPattern is one named regular expression, as written in redact.json.
+type redactionPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// redactionConfig is the contents of redact.json.
+type redactionConfig struct {
+	Enabled  bool               `json:"enabled,omitempty"`
+	Patterns []redactionPattern `json:"patterns,omitempty"`
+}
+
+type redactionRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// sourceRedactor replaces secrets in summary source text before it is sent
+// to a summary API. A nil redactor leaves text unchanged, so call sites do
+// not need to check whether redaction is enabled.
+type sourceRedactor struct {
+	rules []redactionRule
+}
+
+// Redact replaces every match with [REDACTED:<name>]. Rules run in order, so
+// built-ins apply before patterns from redact.json.
+func (r *sourceRedactor) Redact(text string) (string, lcm.RedactionCounts) {
+	counts := lcm.RedactionCounts{}
+	if r == nil {
+		return text, counts
+	}
+	for _, rule := range r.rules {
+		marker := "[REDACTED:" + rule.name + "]"
+		text = rule.re.ReplaceAllStringFunc(text, func(string) string {
+			counts[rule.name]++
+			return marker
+		})
+	}
+	return text, counts
+}
+
+func newSourceRedactor(patterns []redactionPattern) (*sourceRedactor, error) {
+	redactor := &sourceRedactor{}
+	for i, pattern := range patterns {
+		name := strings.TrimSpace(pattern.Name)
+		if name == "" {
+			name = "pattern-" + strconv.Itoa(i+1)
+		}
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", name, err)
+		}
+		redactor.rules = append(redactor.rules, redactionRule{name: name, re: re})
+	}
+	return redactor, nil
+}
+
+// loadRedactionConfig reads redact.json. A missing file yields an empty config.
+func loadRedactionConfig(path string) (redactionConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return redactionConfig{}, nil
+		}
+		return redactionConfig{}, fmt.Errorf("read redaction config %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var config redactionConfig
+	if err := decoder.Decode(&config); err != nil {
+		return redactionConfig{}, fmt.Errorf("parse redaction config %s: %w", path, err)
+	}
+	for _, pattern := range config.Patterns {
+		if strings.TrimSpace(pattern.Pattern) == "" {
+			return redactionConfig{}, fmt.Errorf("redaction config %s: pattern %q is empty", path, pattern.Name)
+		}
+	}
+	return config, nil
+}
+
+// resolveSourceRedactor returns the redactor for a command, or nil when
+// redaction is off. Redaction is opt-in: --redact, LCM_TUI_REDACT=1, or
+// "enabled": true in redact.json turns it on. Patterns from redact.json are
+// always added to the built-ins.
+func resolveSourceRedactor(cliRedact bool) (*sourceRedactor, error) {
+	config, err := loadRedactionConfig(lcm.ExpandHomePath(defaultRedactionConfigPath))
+	if err != nil {
+		return nil, err
+	}
+	enabled := cliRedact || config.Enabled
+	if value := strings.TrimSpace(os.Getenv("LCM_TUI_REDACT")); value != "" {
+		envEnabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("LCM_TUI_REDACT must be a boolean, got %q", value)
+		}
+		enabled = enabled || envEnabled
+	}
+	if !enabled {
+		return nil, nil
+	}
+	patterns := append(append([]redactionPattern{}, builtinRedactionPatterns...), config.Patterns...)
+	return newSourceRedactor(patterns)
+}
+
+// printRedactionTotal reports the run's redactions when redaction is on.
+func printRedactionTotal(r *sourceRedactor, counts lcm.RedactionCounts) {
+	fprintRedactionTotal(os.Stdout, r, counts)
+}
+
+func fprintRedactionTotal(w io.Writer, r *sourceRedactor, counts lcm.RedactionCounts) {
+	if r == nil {
+		return
+	}
+	if counts.Total() == 0 {
+		fmt.Fprintln(w, "Redaction: no secrets found in source text.")
+		return
+	}
+	fmt.Fprintf(w, "Redacted %s secrets from source text before sending.\n", counts)
+}
+
+// formatRedactionStatus is the status-bar suffix for an interactive rewrite
+// whose source had secrets redacted.
+func formatRedactionStatus(counts lcm.RedactionCounts) string {
+	if counts.Total() == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (redacted %d secrets)", counts.Total())
+}
@@ -0,0 +1,675 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type relinkOptions struct {
+	apply    bool
+	backupDB bool
+}
+
+type relinkMessage struct {
+	messageID int64
+	seq       int
+	createdAt string
+}
+
+type relinkLeaf struct {
+	summaryID  string
+	earliestAt string
+	latestAt   string
+	linked     bool
+	// auditCount is the message count from the leaf's compact audit entry;
+	// 0 when the audit log does not record one.
+	auditCount int
+}
+
+// relinkResult is one leaf without summary_messages rows. messages is the
+// range it replaced and basis says how that was derived; when the range
+// cannot be derived without guessing, messages is nil and reason says why.
+type relinkResult struct {
+	summaryID string
+	messages  []relinkMessage
+	basis     string
+	reason    string
+}
+
+type relinkPlan struct {
+	conversationID int64
+	leaves         int
+	results        []relinkResult
+}
+
+func (p relinkPlan) relinkable() []relinkResult {
+	var out []relinkResult
+	for _, r := range p.results {
+		if r.messages != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// relinkStep is one position in the conversation's timeline: an unlinked
+// leaf, or a known stretch of messages (a raw context message or a leaf
+// that still has its summary_messages).
+type relinkStep struct {
+	leafID string
+	lo, hi int
+}
+
+// relinkAuditCountPattern reads the message count from a compact audit
+// entry's detail, as written by applyBackfillLeafPass.
+var relinkAuditCountPattern = regexp.MustCompile(`^leaf summary of (\d+) messages`)
+
+// buildRelinkPlan works out which of conversationID's leaves lost their
+// summary_messages rows and, where the DAG pins it down, which messages each
+// replaced. Walking the context through summary_parents gives the leaves in
+// conversation order between anchors whose messages are known. A lone leaf
+// between two anchors replaced every unclaimed message in between; a run of
+// leaves is split only by the message counts in the audit log or by
+// non-overlapping earliest_at/latest_at windows. Anything else is reported,
+// never guessed.
+func buildRelinkPlan(ctx context.Context, q sqlQueryer, conversationID int64) (relinkPlan, error) {
+	plan := relinkPlan{conversationID: conversationID}
+	messages, err := loadRelinkMessages(ctx, q, conversationID)
+	if err != nil {
+		return plan, err
+	}
+	leaves, order, err := loadRelinkLeaves(ctx, q, conversationID)
+	if err != nil {
+		return plan, err
+	}
+	plan.leaves = len(order)
+	claimed, spans, err := loadRelinkClaims(ctx, q, conversationID)
+	if err != nil {
+		return plan, err
+	}
+	items, err := lcm.LoadContextItems(ctx, q, conversationID)
+	if err != nil {
+		return plan, err
+	}
+	children, err := loadRelinkEdges(ctx, q, conversationID)
+	if err != nil {
+		return plan, err
+	}
+
+	seqByID := make(map[int64]int, len(messages))
+	for _, m := range messages {
+		seqByID[m.messageID] = m.seq
+	}
+	inContext := make(map[int64]bool)
+	var timeline []relinkStep
+	var expand func(summaryID string, path map[string]bool)
+	expand = func(summaryID string, path map[string]bool) {
+		if path[summaryID] {
+			return
+		}
+		if leaf, ok := leaves[summaryID]; ok {
+			if !leaf.linked {
+				timeline = append(timeline, relinkStep{leafID: summaryID})
+			} else if span, ok := spans[summaryID]; ok {
+				timeline = append(timeline, relinkStep{lo: span[0], hi: span[1]})
+			}
+			return
+		}
+		path[summaryID] = true
+		for _, child := range children[summaryID] {
+			expand(child, path)
+		}
+		delete(path, summaryID)
+	}
+	for _, item := range items {
+		switch {
+		case item.MessageID.Valid:
+			if seq, ok := seqByID[item.MessageID.Int64]; ok {
+				inContext[item.MessageID.Int64] = true
+				timeline = append(timeline, relinkStep{lo: seq, hi: seq})
+			}
+		case item.SummaryID.Valid:
+			expand(item.SummaryID.String, make(map[string]bool))
+		}
+	}
+
+	reached := make(map[string]int)
+	for _, step := range timeline {
+		if step.leafID != "" {
+			reached[step.leafID]++
+		}
+	}
+
+	// Group the timeline into runs of unlinked leaves with the anchors on
+	// either side, and collect the unclaimed messages each run could own.
+	type relinkRun struct {
+		leafIDs    []string
+		lo, hi     int
+		candidates []relinkMessage
+	}
+	var runs []*relinkRun
+	var current *relinkRun
+	prevHi := math.MinInt
+	for _, step := range timeline {
+		if step.leafID != "" {
+			if current == nil {
+				current = &relinkRun{lo: prevHi}
+				runs = append(runs, current)
+			}
+			current.leafIDs = append(current.leafIDs, step.leafID)
+			continue
+		}
+		if current != nil {
+			current.hi = step.lo
+			current = nil
+		}
+		prevHi = step.hi
+	}
+	if current != nil {
+		current.hi = math.MaxInt
+	}
+	owners := make(map[int64]int)
+	for _, run := range runs {
+		for _, m := range messages {
+			if m.seq > run.lo && m.seq < run.hi && !claimed[m.messageID] && !inContext[m.messageID] {
+				run.candidates = append(run.candidates, m)
+				owners[m.messageID]++
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		gap := describeRelinkGap(run.lo, run.hi)
+		assigned, basis, reason := partitionRelinkRun(run.leafIDs, leaves, run.candidates, gap)
+		for _, m := range run.candidates {
+			if owners[m.messageID] > 1 && reason == "" {
+				reason = fmt.Sprintf("message %d also falls between the anchors of another leaf run; the context order is inconsistent", m.messageID)
+			}
+		}
+		for _, leafID := range run.leafIDs {
+			if reached[leafID] > 1 && reason == "" {
+				reason = fmt.Sprintf("leaf %s is reached %d times from the context, so its run has no single position", leafID, reached[leafID])
+			}
+		}
+		for i, leafID := range run.leafIDs {
+			if seen[leafID] {
+				continue
+			}
+			seen[leafID] = true
+			result := relinkResult{summaryID: leafID, reason: reason}
+			if reason == "" {
+				result.reason = checkRelinkLeaf(leaves[leafID], assigned[i])
+				if result.reason == "" {
+					result.messages, result.basis = assigned[i], basis
+				}
+			}
+			plan.results = append(plan.results, result)
+		}
+	}
+	for _, leafID := range order {
+		if !leaves[leafID].linked && reached[leafID] == 0 {
+			plan.results = append(plan.results, relinkResult{
+				summaryID: leafID,
+				reason:    "not reachable from the context through summary_parents; its position is unknown",
+			})
+		}
+	}
+	return plan, nil
+}
+
+// partitionRelinkRun splits candidates, in seq order, among a run of
+// leafIDs. It returns one slice per leaf and the basis, or a reason when the
+// split would be a guess.
+func partitionRelinkRun(leafIDs []string, leaves map[string]*relinkLeaf, candidates []relinkMessage, gap string) ([][]relinkMessage, string, string) {
+	if len(candidates) == 0 {
+		return nil, "", "no unclaimed messages " + gap
+	}
+	if len(leafIDs) == 1 {
+		return [][]relinkMessage{candidates}, "only unlinked leaf " + gap, ""
+	}
+	if counts, ok := relinkAuditCounts(leafIDs, leaves); ok {
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		if total == len(candidates) {
+			assigned := make([][]relinkMessage, len(leafIDs))
+			start := 0
+			for i, n := range counts {
+				assigned[i] = candidates[start : start+n]
+				start += n
+			}
+			return assigned, "audit log message counts " + gap, ""
+		}
+	}
+	if assigned, ok := partitionRelinkByTime(leafIDs, leaves, candidates); ok {
+		return assigned, "earliest_at/latest_at windows " + gap, ""
+	}
+	return nil, "", fmt.Sprintf("%d leaves share %d messages %s and neither audit counts nor time windows split them", len(leafIDs), len(candidates), gap)
+}
+
+func relinkAuditCounts(leafIDs []string, leaves map[string]*relinkLeaf) ([]int, bool) {
+	counts := make([]int, len(leafIDs))
+	for i, leafID := range leafIDs {
+		if leaves[leafID].auditCount <= 0 {
+			return nil, false
+		}
+		counts[i] = leaves[leafID].auditCount
+	}
+	return counts, true
+}
+
+// partitionRelinkByTime assigns each candidate to the one leaf whose
+// earliest_at..latest_at window contains it. It fails if any timestamp is
+// missing, a message matches zero or several windows, the assignment is out
+// of leaf order, or a leaf gets nothing.
+func partitionRelinkByTime(leafIDs []string, leaves map[string]*relinkLeaf, candidates []relinkMessage) ([][]relinkMessage, bool) {
+	type window struct{ from, to time.Time }
+	windows := make([]window, len(leafIDs))
+	for i, leafID := range leafIDs {
+		from, okFrom := lcm.ParseContextItemTime(leaves[leafID].earliestAt)
+		to, okTo := lcm.ParseContextItemTime(leaves[leafID].latestAt)
+		if !okFrom || !okTo {
+			return nil, false
+		}
+		windows[i] = window{from, to}
+	}
+	assigned := make([][]relinkMessage, len(leafIDs))
+	last := 0
+	for _, m := range candidates {
+		at, ok := lcm.ParseContextItemTime(m.createdAt)
+		if !ok {
+			return nil, false
+		}
+		match := -1
+		for i, w := range windows {
+			if !at.Before(w.from) && !at.After(w.to) {
+				if match >= 0 {
+					return nil, false
+				}
+				match = i
+			}
+		}
+		if match < last {
+			return nil, false
+		}
+		last = match
+		assigned[match] = append(assigned[match], m)
+	}
+	for _, msgs := range assigned {
+		if len(msgs) == 0 {
+			return nil, false
+		}
+	}
+	return assigned, true
+}
+
+// checkRelinkLeaf cross-checks a derived range against what the leaf row
+// and audit log still say about it.
+func checkRelinkLeaf(leaf *relinkLeaf, messages []relinkMessage) string {
+	if leaf.auditCount > 0 && leaf.auditCount != len(messages) {
+		return fmt.Sprintf("derived %d messages but the audit log records %d", len(messages), leaf.auditCount)
+	}
+	first, okFirst := lcm.ParseContextItemTime(messages[0].createdAt)
+	last, okLast := lcm.ParseContextItemTime(messages[len(messages)-1].createdAt)
+	if earliest, ok := lcm.ParseContextItemTime(leaf.earliestAt); ok && okFirst && !earliest.Equal(first) {
+		return fmt.Sprintf("first message is at %s but earliest_at is %s", messages[0].createdAt, leaf.earliestAt)
+	}
+	if latest, ok := lcm.ParseContextItemTime(leaf.latestAt); ok && okLast && !latest.Equal(last) {
+		return fmt.Sprintf("last message is at %s but latest_at is %s", messages[len(messages)-1].createdAt, leaf.latestAt)
+	}
+	return ""
+}
+
+func describeRelinkGap(lo, hi int) string {
+	switch {
+	case lo == math.MinInt && hi == math.MaxInt:
+		return "in the conversation"
+	case lo == math.MinInt:
+		return fmt.Sprintf("before seq %d", hi)
+	case hi == math.MaxInt:
+		return fmt.Sprintf("after seq %d", lo)
+	}
+	return fmt.Sprintf("between seq %d and %d", lo, hi)
+}
+
+func loadRelinkMessages(ctx context.Context, q sqlQueryer, conversationID int64) ([]relinkMessage, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT message_id, seq, COALESCE(created_at, '')
+		FROM messages
+		WHERE conversation_id = ?
+		ORDER BY seq ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query messages for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+	var messages []relinkMessage
+	for rows.Next() {
+		var m relinkMessage
+		if err := rows.Scan(&m.messageID, &m.seq, &m.createdAt); err != nil {
+			return nil, fmt.Errorf("scan relink message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate relink messages: %w", err)
+	}
+	return messages, nil
+}
+
+// loadRelinkLeaves returns conversationID's leaves keyed by ID, and their IDs
+// in creation order, with message counts from the audit log when present.
+func loadRelinkLeaves(ctx context.Context, q sqlQueryer, conversationID int64) (map[string]*relinkLeaf, []string, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.summary_id, COALESCE(s.earliest_at, ''), COALESCE(s.latest_at, ''),
+			EXISTS (SELECT 1 FROM summary_messages sm WHERE sm.summary_id = s.summary_id)
+		FROM summaries s
+		WHERE s.conversation_id = ? AND s.kind = 'leaf'
+		ORDER BY s.created_at ASC, `+caps.SummarySeqExpr("s")+` ASC, s.summary_id ASC
+	`, conversationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query leaves for %d: %w", conversationID, err)
+	}
+	leaves := make(map[string]*relinkLeaf)
+	var order []string
+	for rows.Next() {
+		var leaf relinkLeaf
+		if err := rows.Scan(&leaf.summaryID, &leaf.earliestAt, &leaf.latestAt, &leaf.linked); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("scan relink leaf: %w", err)
+		}
+		leaves[leaf.summaryID] = &leaf
+		order = append(order, leaf.summaryID)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, nil, fmt.Errorf("iterate relink leaves: %w", err)
+	}
+	rows.Close()
+
+	entries, err := loadAuditLog(ctx, q, conversationID, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if entry.Command != "compact" || len(entry.SummaryIDs) != 1 {
+			continue
+		}
+		leaf, ok := leaves[entry.SummaryIDs[0]]
+		if !ok {
+			continue
+		}
+		if match := relinkAuditCountPattern.FindStringSubmatch(entry.Detail); match != nil {
+			leaf.auditCount, _ = strconv.Atoi(match[1])
+		}
+	}
+	return leaves, order, nil
+}
+
+// loadRelinkClaims returns the messages already linked to a summary of
+// conversationID, and the seq span of each linked leaf.
+func loadRelinkClaims(ctx context.Context, q sqlQueryer, conversationID int64) (map[int64]bool, map[string][2]int, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sm.summary_id, m.message_id, m.seq
+		FROM summary_messages sm
+		JOIN summaries s ON s.summary_id = sm.summary_id
+		JOIN messages m ON m.message_id = sm.message_id
+		WHERE s.conversation_id = ?
+	`, conversationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query summary_messages for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+	claimed := make(map[int64]bool)
+	spans := make(map[string][2]int)
+	for rows.Next() {
+		var (
+			summaryID string
+			messageID int64
+			seq       int
+		)
+		if err := rows.Scan(&summaryID, &messageID, &seq); err != nil {
+			return nil, nil, fmt.Errorf("scan summary_messages row: %w", err)
+		}
+		claimed[messageID] = true
+		span, ok := spans[summaryID]
+		if !ok {
+			span = [2]int{seq, seq}
+		}
+		span[0] = min(span[0], seq)
+		span[1] = max(span[1], seq)
+		spans[summaryID] = span
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate summary_messages: %w", err)
+	}
+	return claimed, spans, nil
+}
+
+func loadRelinkEdges(ctx context.Context, q sqlQueryer, conversationID int64) (map[string][]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sp.summary_id, sp.parent_summary_id
+		FROM summary_parents sp
+		JOIN summaries s ON s.summary_id = sp.summary_id
+		WHERE s.conversation_id = ?
+		ORDER BY sp.summary_id ASC, sp.ordinal ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query summary_parents for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+	children := make(map[string][]string)
+	for rows.Next() {
+		var derivedID, sourceID string
+		if err := rows.Scan(&derivedID, &sourceID); err != nil {
+			return nil, fmt.Errorf("scan summary_parents row: %w", err)
+		}
+		children[derivedID] = append(children[derivedID], sourceID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate summary_parents: %w", err)
+	}
+	return children, nil
+}
+
+// applyRelinkPlan writes summary_messages for every relinkable leaf in one
+// transaction. A leaf or message that gained links since the plan was built
+// aborts the run rather than being linked twice.
+func applyRelinkPlan(ctx context.Context, db *sql.DB, plan relinkPlan) (int, error) {
+	results := plan.relinkable()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin relink transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	ids := make([]string, 0, len(results))
+	linked := 0
+	for _, r := range results {
+		var existing int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM summary_messages WHERE summary_id = ?`, r.summaryID).Scan(&existing); err != nil {
+			return 0, fmt.Errorf("count summary_messages for %s: %w", r.summaryID, err)
+		}
+		if existing > 0 {
+			return 0, fmt.Errorf("leaf %s gained summary_messages rows since the plan was built; rerun relink", r.summaryID)
+		}
+		for i, m := range r.messages {
+			var owner string
+			err := tx.QueryRowContext(ctx, `
+				SELECT sm.summary_id FROM summary_messages sm
+				JOIN summaries s ON s.summary_id = sm.summary_id
+				WHERE sm.message_id = ? AND s.conversation_id = ?
+				LIMIT 1
+			`, m.messageID, plan.conversationID).Scan(&owner)
+			if err == nil {
+				return 0, fmt.Errorf("message %d is already linked to %s; rerun relink", m.messageID, owner)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return 0, fmt.Errorf("check links for message %d: %w", m.messageID, err)
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES (?, ?, ?)
+			`, r.summaryID, m.messageID, i); err != nil {
+				return 0, fmt.Errorf("insert summary_message for %s: %w", r.summaryID, err)
+			}
+			linked++
+		}
+		ids = append(ids, r.summaryID)
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "relink", ConversationID: plan.conversationID, SummaryIDs: ids,
+		Detail: fmt.Sprintf("relinked %d leaves to %d messages", len(ids), linked),
+	}); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit relink: %w", err)
+	}
+	rollback = false
+	return linked, nil
+}
+
+func printRelinkPlan(plan relinkPlan) {
+	unlinked := len(plan.results)
+	if unlinked == 0 {
+		fmt.Printf("Conversation %d: OK, all %d leaves have summary_messages rows.\n", plan.conversationID, plan.leaves)
+		return
+	}
+	relinkable := plan.relinkable()
+	fmt.Printf("Conversation %d: %d of %d leaves have no summary_messages rows (%d relinkable, %d unrecoverable)\n",
+		plan.conversationID, unlinked, plan.leaves, len(relinkable), unlinked-len(relinkable))
+	if len(relinkable) > 0 {
+		fmt.Println("\nRelinkable:")
+		for _, r := range relinkable {
+			fmt.Printf("  %-28s seq %d-%d (%d messages)  %s\n",
+				r.summaryID, r.messages[0].seq, r.messages[len(r.messages)-1].seq, len(r.messages), r.basis)
+		}
+	}
+	if len(relinkable) < unlinked {
+		fmt.Println("\nUnrecoverable:")
+		unrecoverable := make([]relinkResult, 0, unlinked-len(relinkable))
+		for _, r := range plan.results {
+			if r.messages == nil {
+				unrecoverable = append(unrecoverable, r)
+			}
+		}
+		for _, r := range unrecoverable {
+			fmt.Printf("  %-28s %s\n", r.summaryID, r.reason)
+		}
+	}
+}
+
+// runRelinkCommand executes the standalone relink CLI path.
+func runRelinkCommand(args []string) error {
+	opts, conversationID, err := parseRelinkArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildRelinkPlan(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	printRelinkPlan(plan)
+	if len(plan.relinkable()) == 0 {
+		return nil
+	}
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to write summary_messages for the relinkable leaves.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "relink", opts.backupDB, nil); err != nil {
+		return err
+	}
+	linked, err := applyRelinkPlan(ctx, db, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Relinked %d leaves to %d messages.\n", len(plan.relinkable()), linked)
+	return nil
+}
+
+func parseRelinkArgs(args []string) (relinkOptions, int64, error) {
+	fs := flag.NewFlagSet("relink", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	apply := fs.Bool("apply", false, "write summary_messages for relinkable leaves")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized := normalizePruneArgs(args)
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return relinkOptions{}, 0, errors.New(relinkUsageText())
+		}
+		return relinkOptions{}, 0, fmt.Errorf("%w\n%s", err, relinkUsageText())
+	}
+	if fs.NArg() != 1 {
+		return relinkOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", relinkUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return relinkOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), relinkUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return relinkOptions{}, 0, err
+	}
+	return relinkOptions{apply: *apply, backupDB: backup}, conversationID, nil
+}
+
+func relinkUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui relink <conversation_id> [--apply] [--backup-db]
+
+Rebuild summary_messages for leaves that lost it (for example after a
+restore that dropped the join table but kept summaries and messages). The
+context and summary_parents give each leaf's position between messages that
+are still known; a lone leaf there owns every unclaimed message between
+them, and a run of leaves is split only by the audit log's per-leaf message
+counts or by non-overlapping earliest_at/latest_at windows. Leaves whose
+range cannot be derived without guessing are listed as unrecoverable and
+left alone. Read-only unless --apply is given.
+
+Flags:
+  --apply       Write summary_messages for the relinkable leaves
+  --backup-db   With --apply, copy lcm.db to a timestamped backup first
+`)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func TestRelinkRebuildsOnlyDerivableLeafRanges(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'relink'), (2, 'ambiguous')`)
+	for seq := 0; seq < 12; seq++ {
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+			VALUES (%d, 1, %d, 'user', 'm%d', 5, '2026-03-01T10:%02d:00Z')
+		`, seq+1, seq, seq, seq))
+	}
+	// leaf_a kept its links; leaf_b and leaf_c lost theirs but have compact
+	// audit entries; leaf_d is alone between raw messages seq 8 and 11;
+	// leaf_e is not reachable from the context at all.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at) VALUES
+			('leaf_a', 1, 'leaf', 0, 'a', 1, '2026-03-01T11:00:00Z'),
+			('leaf_b', 1, 'leaf', 0, 'b', 1, '2026-03-01T11:01:00Z'),
+			('leaf_c', 1, 'leaf', 0, 'c', 1, '2026-03-01T11:02:00Z'),
+			('leaf_d', 1, 'leaf', 0, 'd', 1, '2026-03-01T11:03:00Z'),
+			('leaf_e', 1, 'leaf', 0, 'e', 1, '2026-03-01T11:04:00Z'),
+			('cond_1', 1, 'condensed', 1, 'abc', 1, '2026-03-01T11:05:00Z')
+	`)
+	mustExec(t, db, `INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('leaf_a', 1, 0), ('leaf_a', 2, 1), ('leaf_a', 3, 2)`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal) VALUES
+			('cond_1', 'leaf_a', 0), ('cond_1', 'leaf_b', 1), ('cond_1', 'leaf_c', 2)
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id) VALUES
+			(1, 0, 'summary', NULL, 'cond_1'),
+			(1, 1, 'message', 9, NULL),
+			(1, 2, 'summary', NULL, 'leaf_d'),
+			(1, 3, 'message', 12, NULL)
+	`)
+	for id, count := range map[string]int{"leaf_b": 3, "leaf_c": 2} {
+		if err := lcm.RecordAudit(ctx, db, lcm.AuditEntry{Command: "compact", ConversationID: 1, SummaryIDs: []string{id},
+			Detail: fmt.Sprintf("leaf summary of %d messages (ordinals 1-%d), previous context none", count, count)}); err != nil {
+			t.Fatalf("record audit: %v", err)
+		}
+	}
+
+	plan, err := buildRelinkPlan(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	got := make(map[string]string)
+	for _, r := range plan.results {
+		if r.messages == nil {
+			got[r.summaryID] = "unrecoverable: " + r.reason
+			continue
+		}
+		got[r.summaryID] = fmt.Sprintf("seq %d-%d", r.messages[0].seq, r.messages[len(r.messages)-1].seq)
+	}
+	want := map[string]string{"leaf_b": "seq 3-5", "leaf_c": "seq 6-7", "leaf_d": "seq 9-10"}
+	for id, w := range want {
+		if got[id] != w {
+			t.Fatalf("%s = %q, want %q (plan %v)", id, got[id], w, got)
+		}
+	}
+	if !strings.Contains(got["leaf_e"], "not reachable") || len(got) != 4 {
+		t.Fatalf("expected leaf_e to be unrecoverable and leaf_a skipped, got %v", got)
+	}
+
+	linked, err := applyRelinkPlan(ctx, db, plan)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if linked != 7 {
+		t.Fatalf("linked %d messages, want 7", linked)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summary_messages WHERE summary_id = 'leaf_c' AND message_id IN (7, 8)`, 2)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'relink'`, 1)
+	if _, err := applyRelinkPlan(ctx, db, plan); err == nil || !strings.Contains(err.Error(), "rerun relink") {
+		t.Fatalf("a stale plan should be refused, got %v", err)
+	}
+	again, err := buildRelinkPlan(ctx, db, 1)
+	if err != nil || len(again.results) != 1 || again.results[0].summaryID != "leaf_e" {
+		t.Fatalf("only leaf_e should remain after apply, got %+v (%v)", again.results, err)
+	}
+
+	// Two adjacent leaves with no audit entries or time windows are never split.
+	for seq := 0; seq < 4; seq++ {
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+			VALUES (%d, 2, %d, 'user', 'x%d', 5, '2026-03-02T10:%02d:00Z')
+		`, 100+seq, seq, seq, seq))
+	}
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at) VALUES
+			('leaf_x', 2, 'leaf', 0, 'x', 1, '2026-03-02T11:00:00Z'),
+			('leaf_y', 2, 'leaf', 0, 'y', 1, '2026-03-02T11:01:00Z')
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id) VALUES
+			(2, 0, 'summary', 'leaf_x'), (2, 1, 'summary', 'leaf_y')
+	`)
+	ambiguous, err := buildRelinkPlan(ctx, db, 2)
+	if err != nil {
+		t.Fatalf("build ambiguous plan: %v", err)
+	}
+	if len(ambiguous.relinkable()) != 0 || len(ambiguous.results) != 2 || !strings.Contains(ambiguous.results[0].reason, "2 leaves share 4 messages") {
+		t.Fatalf("expected both leaves to be unrecoverable, got %+v", ambiguous.results)
+	}
+
+	mustExec(t, db, `UPDATE summaries SET earliest_at = '2026-03-02T10:00:00Z', latest_at = '2026-03-02T10:02:00Z' WHERE summary_id = 'leaf_x'`)
+	mustExec(t, db, `UPDATE summaries SET earliest_at = '2026-03-02T10:03:00Z', latest_at = '2026-03-02T10:03:00Z' WHERE summary_id = 'leaf_y'`)
+	windowed, err := buildRelinkPlan(ctx, db, 2)
+	if err != nil {
+		t.Fatalf("build windowed plan: %v", err)
+	}
+	if r := windowed.relinkable(); len(r) != 2 || len(r[0].messages) != 3 || len(r[1].messages) != 1 || !strings.Contains(r[0].basis, "earliest_at/latest_at") {
+		t.Fatalf("expected the time windows to split the run 3/1, got %+v", windowed.results)
+	}
+}
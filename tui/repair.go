@@ -11,7 +11,6 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"os"
 	"os/exec"
@@ -19,17 +18,22 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 const (
-	corruptedSummaryMarker = "[LCM fallback summary; truncated for context management]"
-	defaultLLMProvider     = "anthropic"
-	anthropicModel         = "claude-sonnet-4-20250514"
-	anthropicVersion       = "2023-06-01"
-	openAIResponsesModel   = "gpt-5.3-codex"
-	condensedTargetTokens  = 2000
+	defaultLLMProvider   = "anthropic"
+	anthropicModel       = "claude-sonnet-4-20250514"
+	anthropicVersion     = "2023-06-01"
+	openAIResponsesModel = "gpt-5.3-codex"
+	// minSummaryOutputMargin is the smallest headroom Anthropic requests get
+	// above the summary target, so max_tokens never truncates a summary that
+	// runs a little long.
+	minSummaryOutputMargin = 256
 	defaultHTTPTimeout     = 180 * time.Second
 
 	defaultAnthropicBaseURL = "https://api.anthropic.com"
@@ -50,54 +54,81 @@ const cliOutputMaxOverageFactor = 3
 const cliSummarizationSystemPrompt = "You are a summarization engine. Output ONLY the requested summary. No preamble, no conversation, no questions, no commentary. Never output HEARTBEAT_OK or any protocol tokens."
 
 type repairOptions struct {
-	apply     bool
-	dryRun    bool
-	all       bool
-	summaryID string
-	verbose   bool
-	provider  string
-	model     string
-	baseURL   string
-}
-
-type repairSummary struct {
-	summaryID         string
-	conversationID    int64
-	kind              string
-	depth             int
-	tokenCount        int
-	content           string
-	createdAt         string
-	childCount        int
-	contextOrdinal    int64
-	hasContextOrdinal bool
-}
-
-type leafSequenceEntry struct {
-	ordinal   int64
-	summaryID string
-	content   string
-	corrupted bool
-}
-
-type repairPlan struct {
-	summaries    []repairSummary
-	ordered      []repairSummary
-	leafSequence []leafSequenceEntry
-}
-
-type repairSource struct {
-	text            string
-	itemCount       int
-	estimatedTokens int
-	label           string
-}
-
-type sqlQueryer interface {
-	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
-	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
-	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
-}
+	apply       bool
+	dryRun      bool
+	all         bool
+	summaryID   string
+	verbose     bool
+	provider    string
+	model       string
+	baseURL     string
+	httpTimeout time.Duration
+	limit       int
+	offset      int
+	// generation carries --temperature and --max-output-tokens.
+	generation summaryGenerationSettings
+	// charTarget is --target-chars/--char-retries; the zero value keeps the
+	// token target.
+	charTarget charTarget
+	// modelFallbacks are tried in order when model is unavailable.
+	modelFallbacks []string
+	// strictHeadings fails the repair instead of warning when a condensed
+	// summary still lacks the required headings after retries.
+	strictHeadings bool
+	// json replaces the human dry-run report with a machine-readable one.
+	json bool
+	// commitEach commits every repaired summary on its own instead of
+	// applying the whole conversation in one transaction.
+	commitEach bool
+	// wrapWidth word-wraps --verbose content previews; 0 disables.
+	wrapWidth int
+	// previewTokens limits each --verbose preview; 0 prints it all.
+	previewTokens int
+	// markers are the fallback phrasings that mark a summary as corrupted:
+	// the built-in set plus --marker and --marker-file patterns.
+	markers []string
+	// tokens is --min-tokens/--max-tokens; only corrupted summaries whose
+	// token_count falls in the range are repaired.
+	tokens lcm.TokenRange
+	// reportFile receives a JSON (or .md) run report; "" disables. report
+	// collects it while the run is in progress and is nil when disabled.
+	reportFile string
+	report     *runReport
+	// redactor replaces secrets in source text before the prompt is built;
+	// nil when redaction is off.
+	redactor *sourceRedactor
+	// prevContext is --prev-context-count/--prev-context-depth.
+	prevContext lcm.PreviousContextOptions
+	// parallel is --parallel: how many --all conversations are repaired at
+	// once. requestsPerMinute is --requests-per-minute, shared by every
+	// worker; 0 leaves summary calls unthrottled.
+	parallel          int
+	requestsPerMinute int
+	// overallTimeout is --overall-timeout: the wall-clock budget for an
+	// --all --apply batch; 0 means no limit.
+	overallTimeout time.Duration
+	// out receives the conversation's progress output; nil is stdout.
+	// writeMu is set when conversations are repaired in parallel: summaries
+	// are then generated before any write, and each conversation's writes
+	// are committed under it, since SQLite allows one writer at a time.
+	out     io.Writer
+	writeMu *sync.Mutex
+	// backupDB copies lcm.db to a timestamped backup before --apply writes.
+	backupDB bool
+	// session is --session: the conversation named by its session ID,
+	// resolved once the DB is open.
+	session conversationSession
+}
+
+func (o repairOptions) stdout() io.Writer {
+	if o.out != nil {
+		return o.out
+	}
+	return os.Stdout
+}
+
+// sqlQueryer is the query surface shared by *sql.DB and *sql.Tx.
+type sqlQueryer = lcm.Queryer
 
 type anthropicClient struct {
 	provider string
@@ -105,12 +136,58 @@ type anthropicClient struct {
 	http     *http.Client
 	model    string
 	baseURL  string
+
+	// modelFallbacks are tried in order when model is unknown, retired, or
+	// overloaded; see isModelUnavailableError.
+	modelFallbacks []string
+	// logf, when set, reports fallback substitutions.
+	logf func(format string, args ...any)
+	// lastModel is the model that produced the most recent summary, and
+	// modelUsage counts summaries per model across the client's lifetime.
+	lastModel  string
+	modelUsage map[string]int
+	// observe, when set, is told about every summarize call: the model that
+	// answered (or last failed), estimated prompt and output tokens, and how
+	// long the call took. Run reports use it.
+	observe func(model string, promptTokens, outputTokens int, elapsed time.Duration, err error)
+	// temperature and maxOutputTokens override request generation settings;
+	// see summaryGenerationSettings. CLI-delegated calls ignore both.
+	temperature     *float64
+	maxOutputTokens int
+	// limiter, when set, paces calls; clients made by forConversation share
+	// it.
+	limiter *callLimiter
+	// deadline, when set, is the --overall-timeout of a batch: calls still
+	// running then are cut off, and later calls fail with errOverallTimeout.
+	deadline time.Time
+}
+
+// errOverallTimeout marks a summary call refused or cut off because the
+// batch's --overall-timeout passed.
+var errOverallTimeout = errors.New("--overall-timeout reached")
+
+// deadlinePassed reports whether the batch's --overall-timeout has passed.
+func (c *anthropicClient) deadlinePassed() bool {
+	return c != nil && !c.deadline.IsZero() && !time.Now().Before(c.deadline)
+}
+
+// newSummaryHTTPClient builds the HTTP client used for summary API calls. The
+// transport honors HTTPS_PROXY/HTTP_PROXY/NO_PROXY so corporate proxies work,
+// and a non-positive timeout falls back to defaultHTTPTimeout.
+func newSummaryHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return &http.Client{Timeout: timeout, Transport: transport}
 }
 
 type anthropicRequest struct {
 	Model       string                    `json:"model"`
 	MaxTokens   int                       `json:"max_tokens"`
-	Temperature float64                   `json:"temperature,omitempty"`
+	Temperature *float64                  `json:"temperature,omitempty"`
+	System      string                    `json:"system,omitempty"`
 	Messages    []anthropicRequestMessage `json:"messages"`
 }
 
@@ -140,6 +217,7 @@ type openAIResponsesRequest struct {
 	Model           string                        `json:"model"`
 	Input           []openAIResponsesInputMessage `json:"input"`
 	MaxOutputTokens int                           `json:"max_output_tokens"`
+	Temperature     *float64                      `json:"temperature,omitempty"`
 }
 
 type openAIResponsesInputMessage struct {
@@ -155,16 +233,23 @@ type openAIResponsesInputTextBlock struct {
 type openAIErrorEnvelope struct {
 	Error struct {
 		Type    string `json:"type"`
+		Code    string `json:"code"`
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
 // runRepairCommand executes the standalone repair CLI path.
-func runRepairCommand(args []string) error {
+func runRepairCommand(args []string) (err error) {
 	opts, conversationID, err := parseRepairArgs(args)
 	if err != nil {
 		return err
 	}
+	if !opts.all && !opts.session.set() {
+		opts.report = newRunReport(opts.reportFile, "repair", args, opts.apply, conversationID)
+	} else {
+		opts.report = newRunReport(opts.reportFile, "repair", args, opts.apply)
+	}
+	defer func() { err = opts.report.close(err) }()
 
 	paths, err := resolveDataPaths()
 	if err != nil {
@@ -178,14 +263,40 @@ func runRepairCommand(args []string) error {
 	defer db.Close()
 
 	ctx := context.Background()
+	conversationID, err = opts.session.resolve(db, conversationID)
+	if err != nil {
+		return err
+	}
+	if opts.session.set() {
+		opts.report.addConversation(conversationID)
+	}
 	conversationIDs, err := resolveRepairConversationIDs(ctx, db, opts, conversationID)
 	if err != nil {
 		return err
 	}
+	if opts.json {
+		if opts.all {
+			conversationIDs = selectConversationBatch(conversationIDs, opts.offset, opts.limit)
+		}
+		return writeRepairDryRunJSON(ctx, db, os.Stdout, conversationIDs, opts.summaryID, opts.markers, opts.tokens)
+	}
 	if len(conversationIDs) == 0 {
 		fmt.Println("No corrupted summaries found.")
 		return nil
 	}
+	if opts.all && (opts.limit > 0 || opts.offset > 0) {
+		total := len(conversationIDs)
+		conversationIDs = selectConversationBatch(conversationIDs, opts.offset, opts.limit)
+		fmt.Println(formatConversationBatch(conversationIDs, total, opts.offset, opts.apply))
+		if len(conversationIDs) == 0 {
+			return nil
+		}
+		fmt.Println()
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "repair", opts.apply && opts.backupDB, opts.report); err != nil {
+		return err
+	}
 
 	var client *anthropicClient
 	if opts.apply {
@@ -193,6 +304,10 @@ func runRepairCommand(args []string) error {
 		opts.provider = settings.provider
 		opts.model = settings.model
 		opts.baseURL = settings.baseURL
+		if err := opts.generation.checkProvider(opts.provider); err != nil {
+			return err
+		}
+		printStubSummarizerNotice(opts.provider)
 
 		apiKey, err := resolveProviderAPIKey(paths, opts.provider)
 		if err != nil {
@@ -201,28 +316,87 @@ func runRepairCommand(args []string) error {
 		client = &anthropicClient{
 			provider: opts.provider,
 			apiKey:   apiKey,
-			http:     &http.Client{Timeout: defaultHTTPTimeout},
+			http:     newSummaryHTTPClient(opts.httpTimeout),
 			model:    opts.model,
 			baseURL:  opts.baseURL,
+
+			modelFallbacks:  opts.modelFallbacks,
+			logf:            stdoutLogf,
+			temperature:     opts.generation.temperature,
+			maxOutputTokens: opts.generation.maxOutputTokens,
+			limiter:         newCallLimiter(opts.requestsPerMinute),
+		}
+		opts.report.observe(client)
+		if opts.overallTimeout > 0 {
+			client.deadline = time.Now().Add(opts.overallTimeout)
+			fmt.Printf("Overall timeout: %s (each call: %s)\n\n", opts.overallTimeout, opts.httpTimeout)
 		}
 	}
 
-	totalRepaired := 0
+	var (
+		totalRepaired int
+		unfinished    []int64
+	)
+	if opts.apply && opts.parallel > 1 {
+		totalRepaired, unfinished, err = runRepairConversationsParallel(ctx, db, os.Stdout, conversationIDs, opts, client)
+	} else {
+		totalRepaired, unfinished, err = runRepairConversations(ctx, db, os.Stdout, conversationIDs, opts, client)
+	}
+	if err != nil {
+		return err
+	}
+	if len(unfinished) > 0 {
+		printOverallTimeoutStop(os.Stdout, opts.overallTimeout, len(conversationIDs)-len(unfinished), unfinished)
+		opts.report.leaveUnfinished(unfinished)
+		fmt.Println("Rerun the same command to continue; repaired conversations no longer match.")
+		return nil
+	}
+
+	if opts.apply && opts.all {
+		fmt.Printf("\nDone. %d summaries repaired across %d conversations.\n", totalRepaired, len(conversationIDs))
+	}
+	return nil
+}
+
+// runRepairConversations repairs conversationIDs one at a time. When the
+// client's --overall-timeout passes it stops cleanly and returns the
+// conversations it did not finish; a conversation whose call was cut off
+// counts as unfinished, since its transaction was rolled back.
+func runRepairConversations(ctx context.Context, db *sql.DB, w io.Writer, conversationIDs []int64, opts repairOptions, client *anthropicClient) (int, []int64, error) {
+	total := 0
 	for i, id := range conversationIDs {
+		if client.deadlinePassed() {
+			return total, conversationIDs[i:], nil
+		}
 		if i > 0 {
-			fmt.Println()
+			fmt.Fprintln(w)
 		}
+		opts.report.addConversation(id)
 		repaired, err := runRepairConversation(ctx, db, id, opts, client)
+		if errors.Is(err, errOverallTimeout) {
+			// Without --commit-each nothing of this conversation was kept.
+			if opts.commitEach {
+				total += repaired
+			}
+			return total, conversationIDs[i:], nil
+		}
+		total += repaired
 		if err != nil {
-			return err
+			return total, nil, err
 		}
-		totalRepaired += repaired
 	}
+	return total, nil, nil
+}
 
-	if opts.apply && opts.all {
-		fmt.Printf("\nDone. %d summaries repaired across %d conversations.\n", totalRepaired, len(conversationIDs))
+// printOverallTimeoutStop reports an --all batch that hit --overall-timeout
+// and lists the conversations it left for the next run.
+func printOverallTimeoutStop(w io.Writer, timeout time.Duration, finished int, unfinished []int64) {
+	ids := make([]string, 0, len(unfinished))
+	for _, id := range unfinished {
+		ids = append(ids, strconv.FormatInt(id, 10))
 	}
-	return nil
+	fmt.Fprintf(w, "\nOverall timeout %s reached; %d of %d conversations finished.\n", timeout, finished, finished+len(unfinished))
+	fmt.Fprintf(w, "Unfinished conversations: %s\n", strings.Join(ids, ", "))
 }
 
 func parseRepairArgs(args []string) (repairOptions, int64, error) {
@@ -233,10 +407,42 @@ func parseRepairArgs(args []string) (repairOptions, int64, error) {
 	dryRun := fs.Bool("dry-run", true, "show what would be repaired")
 	all := fs.Bool("all", false, "scan all conversations")
 	summaryID := fs.String("summary-id", "", "repair a specific summary ID")
+	session := fs.String("session", "", "repair the conversation recorded under this session ID")
+	conversationIndex := fs.Int("conversation-index", 0, "with --session, which of the session's conversations (1 = newest)")
 	verbose := fs.Bool("verbose", false, "include old content hash and preview")
 	provider := fs.String("provider", "", "provider id (e.g. anthropic, openai)")
 	model := fs.String("model", "", "summary model id")
+	modelFallback := fs.String("model-fallback", "", "comma-separated models to try when the model is unavailable")
 	baseURL := fs.String("base-url", "", "custom API base URL")
+	stub := fs.Bool("stub", false, "use the deterministic stub summarizer (demos/tests only)")
+	httpTimeout := fs.Duration("http-timeout", defaultHTTPTimeout, "timeout for each summary API call (e.g. 300s, 10m)")
+	temperature := fs.String("temperature", "", "sampling temperature for summary calls (default: provider default)")
+	maxOutputTokens := fs.Int("max-output-tokens", 0, "output token ceiling for summary calls (default: derived from the target)")
+	targetChars := fs.Int("target-chars", 0, "aim each summary at about n characters instead of the token target")
+	charRetries := fs.Int("char-retries", 1, "with --target-chars, re-request results more than 50% off the target up to n times")
+	limit := fs.Int("limit", 0, "with --all, process at most n conversations")
+	offset := fs.Int("offset", 0, "with --all, skip the first n matching conversations")
+	minTokens, maxTokens := addSummaryTokenRangeFlags(fs, "repair")
+	strictHeadings := fs.Bool("strict-headings", false, "fail when a condensed summary lacks the required headings after retries")
+	jsonOutput := fs.Bool("json", false, "print the dry-run report as JSON")
+	commitEach := fs.Bool("commit-each", false, "commit each repaired summary instead of one transaction per conversation")
+	wrap := fs.Bool("wrap", true, "word-wrap --verbose content previews")
+	width := fs.Int("width", 0, "wrap width for --verbose previews (default: terminal width, else 100)")
+	previewTokenLimit := fs.Int("preview-tokens", defaultPreviewTokens, "tokens of each --verbose preview (0 = all)")
+	prevContextCount := fs.Int("prev-context-count", 1, "preceding summaries to include as previous context")
+	prevContextDepth := fs.Int("prev-context-depth", -1, "depth to draw previous context from (-1 = the summary's own depth)")
+	var extraMarkers []string
+	fs.Func("marker", "additional corrupted-summary marker (repeatable)", func(value string) error {
+		extraMarkers = append(extraMarkers, value)
+		return nil
+	})
+	markerFile := fs.String("marker-file", "", "file with one additional corrupted-summary marker per line")
+	reportFile := fs.String("report-file", "", "write a JSON (or .md) run report to this path")
+	redact := fs.Bool("redact", false, "redact secrets from source text before it is sent to the summary API")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+	parallel := fs.Int("parallel", 1, "with --all --apply, repair up to n conversations at once")
+	requestsPerMinute := fs.Int("requests-per-minute", 0, "cap summary calls per minute across all conversations (0 = no cap)")
+	overallTimeout := fs.Duration("overall-timeout", 0, "with --all --apply, stop the batch cleanly after this much wall-clock time (0 = no limit)")
 
 	normalizedArgs, err := normalizeRepairArgs(args)
 	if err != nil {
@@ -249,15 +455,36 @@ func parseRepairArgs(args []string) (repairOptions, int64, error) {
 		return repairOptions{}, 0, fmt.Errorf("--all and --summary-id cannot be combined\n%s", repairUsageText())
 	}
 
+	stubProvider, err := resolveStubProviderFlag(*stub, strings.TrimSpace(*provider))
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+
 	opts := repairOptions{
-		apply:     *apply,
-		dryRun:    *dryRun,
-		all:       *all,
-		summaryID: strings.TrimSpace(*summaryID),
-		verbose:   *verbose,
-		provider:  strings.TrimSpace(*provider),
-		model:     strings.TrimSpace(*model),
-		baseURL:   strings.TrimSpace(*baseURL),
+		apply:          *apply,
+		dryRun:         *dryRun,
+		all:            *all,
+		summaryID:      strings.TrimSpace(*summaryID),
+		verbose:        *verbose,
+		provider:       stubProvider,
+		model:          strings.TrimSpace(*model),
+		modelFallbacks: parseModelFallbackList(*modelFallback),
+		baseURL:        strings.TrimSpace(*baseURL),
+		httpTimeout:    *httpTimeout,
+		limit:          *limit,
+		offset:         *offset,
+		tokens:         lcm.TokenRange{Min: *minTokens, Max: *maxTokens},
+		strictHeadings: *strictHeadings,
+		json:           *jsonOutput,
+		commitEach:     *commitEach,
+		reportFile:     strings.TrimSpace(*reportFile),
+
+		parallel:          *parallel,
+		requestsPerMinute: *requestsPerMinute,
+		overallTimeout:    *overallTimeout,
+	}
+	if opts.apply && opts.json {
+		return repairOptions{}, 0, fmt.Errorf("--json is only supported for dry runs\n%s", repairUsageText())
 	}
 	if opts.apply {
 		opts.dryRun = false
@@ -265,20 +492,83 @@ func parseRepairArgs(args []string) (repairOptions, int64, error) {
 	if !opts.apply {
 		opts.dryRun = true
 	}
+	if opts.httpTimeout <= 0 {
+		return repairOptions{}, 0, fmt.Errorf("--http-timeout must be > 0\n%s", repairUsageText())
+	}
+	opts.generation, err = resolveSummaryGenerationSettings(*temperature, *maxOutputTokens)
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	opts.redactor, err = resolveSourceRedactor(*redact)
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	opts.charTarget, err = newCharTarget(*targetChars, *charRetries)
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	opts.session, err = newConversationSession(*session, *conversationIndex)
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	if opts.limit < 0 || opts.offset < 0 {
+		return repairOptions{}, 0, fmt.Errorf("--limit and --offset must be >= 0\n%s", repairUsageText())
+	}
+	if err := validateSummaryTokenRange(opts.tokens); err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	if *width < 0 {
+		return repairOptions{}, 0, fmt.Errorf("--width must be >= 0\n%s", repairUsageText())
+	}
+	opts.wrapWidth = resolveCLIWrapWidth(*wrap, *width)
+	if *previewTokenLimit < 0 {
+		return repairOptions{}, 0, fmt.Errorf("--preview-tokens must be >= 0\n%s", repairUsageText())
+	}
+	opts.previewTokens = *previewTokenLimit
+	opts.prevContext, err = resolvePreviousContextSettings(*prevContextCount, *prevContextDepth)
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	opts.markers, err = resolveCorruptedSummaryMarkers(extraMarkers, strings.TrimSpace(*markerFile))
+	if err != nil {
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
+	}
+	if !opts.all && (opts.limit > 0 || opts.offset > 0) {
+		return repairOptions{}, 0, fmt.Errorf("--limit and --offset require --all\n%s", repairUsageText())
+	}
+	if opts.parallel < 1 {
+		return repairOptions{}, 0, fmt.Errorf("--parallel must be >= 1\n%s", repairUsageText())
+	}
+	if opts.parallel > 1 && !opts.all {
+		return repairOptions{}, 0, fmt.Errorf("--parallel requires --all\n%s", repairUsageText())
+	}
+	if opts.requestsPerMinute < 0 {
+		return repairOptions{}, 0, fmt.Errorf("--requests-per-minute must be >= 0\n%s", repairUsageText())
+	}
+	if opts.overallTimeout < 0 {
+		return repairOptions{}, 0, fmt.Errorf("--overall-timeout must be >= 0\n%s", repairUsageText())
+	}
+	if opts.overallTimeout > 0 && !(opts.all && opts.apply) {
+		return repairOptions{}, 0, fmt.Errorf("--overall-timeout requires --all --apply\n%s", repairUsageText())
+	}
 
 	if opts.all {
-		if fs.NArg() != 0 {
-			return repairOptions{}, 0, fmt.Errorf("conversation ID is not allowed with --all\n%s", repairUsageText())
+		if fs.NArg() != 0 || opts.session.set() {
+			return repairOptions{}, 0, fmt.Errorf("conversation ID and --session are not allowed with --all\n%s", repairUsageText())
 		}
 		return opts, 0, nil
 	}
-	if fs.NArg() != 1 {
-		return repairOptions{}, 0, fmt.Errorf("conversation ID is required unless --all is used\n%s", repairUsageText())
+	if fs.NArg() == 0 && !opts.session.set() {
+		return repairOptions{}, 0, fmt.Errorf("conversation ID or --session is required unless --all is used\n%s", repairUsageText())
 	}
 
-	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	conversationID, err := opts.session.conversationArg(fs.Args())
 	if err != nil {
-		return repairOptions{}, 0, fmt.Errorf("parse conversation ID %q: %w", fs.Arg(0), err)
+		return repairOptions{}, 0, fmt.Errorf("%w\n%s", err, repairUsageText())
 	}
 	return opts, conversationID, nil
 }
@@ -290,13 +580,20 @@ func normalizeRepairArgs(args []string) ([]string, error) {
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch {
-		case arg == "--apply" || arg == "--dry-run" || arg == "--all" || arg == "--verbose":
+		case arg == "--apply" || arg == "--dry-run" || arg == "--all" || arg == "--verbose" || arg == "--json" || arg == "--commit-each" || arg == "--redact":
 			flags = append(flags, arg)
-		case strings.HasPrefix(arg, "--provider="), strings.HasPrefix(arg, "--model="), strings.HasPrefix(arg, "--base-url="):
+		case strings.HasPrefix(arg, "--provider="), strings.HasPrefix(arg, "--model="), strings.HasPrefix(arg, "--model-fallback="), strings.HasPrefix(arg, "--base-url="), strings.HasPrefix(arg, "--http-timeout="),
+			strings.HasPrefix(arg, "--temperature="), strings.HasPrefix(arg, "--max-output-tokens="),
+			strings.HasPrefix(arg, "--target-chars="), strings.HasPrefix(arg, "--char-retries="),
+			strings.HasPrefix(arg, "--limit="), strings.HasPrefix(arg, "--offset="), strings.HasPrefix(arg, "--min-tokens="), strings.HasPrefix(arg, "--max-tokens="), strings.HasPrefix(arg, "--width="), strings.HasPrefix(arg, "--preview-tokens="),
+			strings.HasPrefix(arg, "--prev-context-count="), strings.HasPrefix(arg, "--prev-context-depth="),
+			strings.HasPrefix(arg, "--marker="), strings.HasPrefix(arg, "--marker-file="), strings.HasPrefix(arg, "--report-file="),
+			strings.HasPrefix(arg, "--parallel="), strings.HasPrefix(arg, "--requests-per-minute="), strings.HasPrefix(arg, "--overall-timeout="),
+			strings.HasPrefix(arg, "--session="), strings.HasPrefix(arg, "--conversation-index="):
 			flags = append(flags, arg)
 		case strings.HasPrefix(arg, "--summary-id="):
 			flags = append(flags, arg)
-		case arg == "--provider" || arg == "--model" || arg == "--base-url":
+		case arg == "--provider" || arg == "--model" || arg == "--model-fallback" || arg == "--base-url" || arg == "--http-timeout" || arg == "--temperature" || arg == "--max-output-tokens" || arg == "--target-chars" || arg == "--char-retries" || arg == "--limit" || arg == "--offset" || arg == "--min-tokens" || arg == "--max-tokens" || arg == "--width" || arg == "--preview-tokens" || arg == "--prev-context-count" || arg == "--prev-context-depth" || arg == "--marker" || arg == "--marker-file" || arg == "--report-file" || arg == "--parallel" || arg == "--requests-per-minute" || arg == "--overall-timeout" || arg == "--session" || arg == "--conversation-index":
 			if i+1 >= len(args) {
 				return nil, errors.New("missing value for " + arg)
 			}
@@ -322,11 +619,69 @@ func repairUsageText() string {
 Usage:
   lcm-tui repair <conversation_id> [--dry-run] [--summary-id <id>] [--provider <id>] [--model <model>] [--base-url <url>]
   lcm-tui repair <conversation_id> --apply [--summary-id <id>] [--provider <id>] [--model <model>] [--base-url <url>]
-  lcm-tui repair --all [--dry-run|--apply] [--provider <id>] [--model <model>] [--base-url <url>]
+  lcm-tui repair --all [--dry-run|--apply] [--limit <n>] [--offset <n>] [--parallel <n>] [--provider <id>] [--model <model>] [--base-url <url>]
+  lcm-tui repair <conversation_id>|--all --json [--summary-id <id>] [--limit <n>] [--offset <n>]
+  lcm-tui repair --session <session_id> [--conversation-index <n>] [--dry-run|--apply] [--summary-id <id>]
+
+Flags:
+  --session <id>        repair the conversation recorded under this session ID (the JSONL file name)
+                        instead of passing its conversation ID
+  --conversation-index <n>
+                        with --session, pick the nth of the session's conversations, newest first as
+                        lcm-tui conversations lists them; required when a reset left several
+  --http-timeout <dur>  timeout for each summary API call (default 3m0s)
+  --temperature <t>     sampling temperature for summary calls, 0-2 (0-1 on Anthropic; default: provider default)
+  --max-output-tokens <n>
+                        output token ceiling; Anthropic keeps at least the target plus a margin
+  --target-chars <n>    aim each summary at about n characters instead of the usual token target
+                        (for fixed-width fields or character-limited storage); results more than 50%
+                        off are re-requested
+  --char-retries <n>    with --target-chars, how many times to re-request an off-target result (default 1)
+  --model-fallback <models>
+                        comma-separated models to retry with when the model is unknown, retired, or overloaded
+  --limit <n>           with --all, process at most n conversations (default: no limit)
+  --offset <n>          with --all, skip the first n matching conversations (ordered by ID)
+  --min-tokens <n>      only repair corrupted summaries with token_count >= n
+  --max-tokens <n>      only repair corrupted summaries with token_count <= n
+  --parallel <n>        with --all --apply, repair up to n conversations at once (default 1); each
+                        conversation still commits atomically and output stays in ID order
+  --requests-per-minute <n>
+                        space summary calls so all conversations together stay under n per minute
+  --overall-timeout <d>
+                        with --all --apply, wall-clock budget for the batch, e.g. 45m; stops cleanly
+                        and lists the unfinished conversations
+  --stub                use the deterministic stub summarizer (demos/tests only)
+  --strict-headings     fail instead of warn when condensed headings are missing or out of order
+  --json                print the dry-run report as JSON (corrupted summaries + repair order)
+  --commit-each         with --apply, commit each repaired summary so a failure keeps earlier repairs
+  --verbose             print the old content hash plus source, old, and new content previews
+  --width <n>           wrap --verbose previews at n columns (default: terminal width, else 100)
+  --wrap=false          print --verbose previews without wrapping
+  --preview-tokens <n>  tokens of each --verbose preview (default 300, 0 = full content)
+  --prev-context-count <n>
+                        preceding summaries to include as previous context (default 1; 0 = none)
+  --prev-context-depth <n>
+                        draw previous context from depth N instead of the summary's own depth
+  --marker <text>       also treat summaries containing text as corrupted (repeatable)
+  --marker-file <path>  read additional markers from a file, one per line (# comments allowed)
+  --report-file <path>  write a JSON run report (markdown when path ends in .md), even on failure
+  --backup-db           with --apply, copy lcm.db to a timestamped backup first
+  --redact              replace API keys, tokens, and private keys in source text with
+                        [REDACTED:<kind>] before it is sent (patterns: ~/.config/lcm-tui/redact.json)
+
+Built-in markers cover every fallback phrasing the plugin has written, e.g.
+"[LCM fallback summary; truncated for context management]". Markers match
+as literal, case-sensitive substrings.
 
 Env:
   LCM_TUI_SUMMARY_PROVIDER / LCM_TUI_SUMMARY_MODEL / LCM_TUI_SUMMARY_BASE_URL
   fall back to LCM_SUMMARY_PROVIDER / LCM_SUMMARY_MODEL / LCM_SUMMARY_BASE_URL
+  LCM_TUI_SUMMARY_TEMPERATURE / LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS
+  back --temperature / --max-output-tokens
+  HTTPS_PROXY / HTTP_PROXY / NO_PROXY route summary API calls through a proxy
+  LCM_SUMMARIZER=stub uses the deterministic stub summarizer (no API calls)
+  LCM_TUI_REDACT=1 turns on --redact for every command
+  LCM_TUI_BACKUP_DB=1 turns on --backup-db for every command
 `)
 }
 
@@ -335,12 +690,14 @@ func resolveRepairConversationIDs(ctx context.Context, db *sql.DB, opts repairOp
 		return []int64{conversationID}, nil
 	}
 
+	markerClause, markerArgs := lcm.CorruptedMarkerClause("content", opts.markers)
+	tokenClause, tokenArgs := opts.tokens.Clause("token_count")
 	rows, err := db.QueryContext(ctx, `
 		SELECT DISTINCT conversation_id
 		FROM summaries
-		WHERE content LIKE ?
+		WHERE `+markerClause+tokenClause+`
 		ORDER BY conversation_id ASC
-	`, "%"+corruptedSummaryMarker+"%")
+	`, append(markerArgs, tokenArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("query corrupted conversations: %w", err)
 	}
@@ -360,255 +717,101 @@ func resolveRepairConversationIDs(ctx context.Context, db *sql.DB, opts repairOp
 	return ids, nil
 }
 
+// selectConversationBatch returns ids[offset:offset+limit], clamped to the
+// slice. A zero limit means no limit. --all commands order ids by
+// conversation_id, so batches are stable across invocations.
+func selectConversationBatch(ids []int64, offset, limit int) []int64 {
+	if offset >= len(ids) {
+		return nil
+	}
+	end := len(ids)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return ids[offset:end]
+}
+
+// formatConversationBatch reports which slice of the matching conversations a
+// batched --all run covers and how to resume after it. When consumed is set,
+// processed conversations stop matching (repair --apply), so the next batch
+// starts again at the same offset.
+func formatConversationBatch(batch []int64, total, offset int, consumed bool) string {
+	if len(batch) == 0 {
+		return fmt.Sprintf("Batch: offset %d is past the %d matching conversations; nothing to process.", offset, total)
+	}
+	line := fmt.Sprintf("Batch: conversations %d-%d (items %d-%d of %d matching)",
+		batch[0], batch[len(batch)-1], offset+1, offset+len(batch), total)
+	if next := offset + len(batch); next < total {
+		if consumed {
+			next = offset
+		}
+		line += fmt.Sprintf("; resume with --offset %d", next)
+	}
+	return line
+}
+
 func runRepairConversation(ctx context.Context, db *sql.DB, conversationID int64, opts repairOptions, client *anthropicClient) (int, error) {
 	label := "Scanning"
 	if opts.apply {
 		label = "Repairing"
 	}
-	fmt.Printf("%s conversation %d...\n\n", label, conversationID)
+	w := opts.stdout()
+	fmt.Fprintf(w, "%s conversation %d...\n\n", label, conversationID)
 
-	plan, err := buildRepairPlan(ctx, db, conversationID, opts.summaryID)
+	plan, err := lcm.BuildRepairPlan(ctx, db, conversationID, opts.summaryID, opts.markers, opts.tokens)
 	if err != nil {
 		return 0, err
 	}
-	if len(plan.summaries) == 0 {
+	if opts.tokens.Active() {
+		fmt.Fprintf(w, "Token filter %s matched %d corrupted summaries.\n", opts.tokens, len(plan.Summaries))
+	}
+	if len(plan.Summaries) == 0 {
+		if opts.tokens.Active() {
+			return 0, nil
+		}
 		if opts.summaryID != "" {
-			exists, err := summaryExists(ctx, db, conversationID, opts.summaryID)
+			exists, err := lcm.SummaryExists(ctx, db, conversationID, opts.summaryID)
 			if err != nil {
 				return 0, err
 			}
 			if exists {
-				fmt.Printf("Summary %s is not corrupted.\n", opts.summaryID)
+				fmt.Fprintf(w, "Summary %s is not corrupted.\n", opts.summaryID)
 				return 0, nil
 			}
-			fmt.Printf("Summary %s not found in conversation %d.\n", opts.summaryID, conversationID)
+			fmt.Fprintf(w, "Summary %s not found in conversation %d.\n", opts.summaryID, conversationID)
 			return 0, nil
 		}
-		fmt.Println("No corrupted summaries found.")
+		fmt.Fprintln(w, "No corrupted summaries found.")
 		return 0, nil
 	}
 
 	if opts.dryRun {
-		printDryRunReport(plan.summaries, plan.ordered)
+		printDryRunReport(plan.Summaries, plan.Ordered)
 		return 0, nil
 	}
 
 	repaired, err := applyRepairs(ctx, db, plan, opts, client)
 	if err != nil {
+		if opts.commitEach && repaired > 0 {
+			// Repaired summaries lose the fallback marker, so a plain rerun
+			// picks up exactly the ones that remain.
+			fmt.Fprintf(w, "\nCommitted %d of %d repairs before the failure; rerun the same command to continue.\n", repaired, len(plan.Ordered))
+		}
 		return repaired, err
 	}
-	fmt.Printf("\nDone. %d summaries repaired. Changes take effect on next conversation turn.\n", repaired)
+	fmt.Fprintf(w, "\nDone. %d summaries repaired. Changes take effect on next conversation turn.\n", repaired)
 	return repaired, nil
 }
 
-// buildRepairPlan computes both the scan output and bottom-up repair order.
-// Leaves are repaired in context_items ordinal order so each repaired leaf can
-// feed previous_context into the next corrupted leaf.
-func buildRepairPlan(ctx context.Context, q sqlQueryer, conversationID int64, summaryID string) (repairPlan, error) {
-	summaries, err := loadCorruptedSummaries(ctx, q, conversationID, summaryID)
-	if err != nil {
-		return repairPlan{}, err
-	}
-	if len(summaries) == 0 {
-		return repairPlan{}, nil
-	}
-
-	leafSeq, err := loadLeafSequence(ctx, q, conversationID)
-	if err != nil {
-		return repairPlan{}, err
-	}
-
-	byID := make(map[string]*repairSummary, len(summaries))
-	for i := range summaries {
-		byID[summaries[i].summaryID] = &summaries[i]
-	}
-	for _, leaf := range leafSeq {
-		if item, ok := byID[leaf.summaryID]; ok {
-			item.contextOrdinal = leaf.ordinal
-			item.hasContextOrdinal = true
-		}
-	}
-
-	ordered := make([]repairSummary, 0, len(summaries))
-	seen := make(map[string]bool, len(summaries))
-
-	for _, leaf := range leafSeq {
-		item, ok := byID[leaf.summaryID]
-		if !ok || seen[leaf.summaryID] {
-			continue
-		}
-		if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-			ordered = append(ordered, *item)
-			seen[leaf.summaryID] = true
-		}
-	}
-
-	var orphanLeaves []repairSummary
-	var condensed []repairSummary
-	for _, item := range summaries {
-		if seen[item.summaryID] {
-			continue
-		}
-		if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-			orphanLeaves = append(orphanLeaves, item)
-			continue
-		}
-		condensed = append(condensed, item)
-	}
-
-	sort.Slice(orphanLeaves, func(i, j int) bool {
-		left := orphanLeaves[i]
-		right := orphanLeaves[j]
-		if left.createdAt != right.createdAt {
-			return left.createdAt < right.createdAt
-		}
-		return left.summaryID < right.summaryID
-	})
-	sort.Slice(condensed, func(i, j int) bool {
-		left := condensed[i]
-		right := condensed[j]
-		if left.depth != right.depth {
-			return left.depth < right.depth
-		}
-		if left.createdAt != right.createdAt {
-			return left.createdAt < right.createdAt
-		}
-		return left.summaryID < right.summaryID
-	})
-
-	ordered = append(ordered, orphanLeaves...)
-	ordered = append(ordered, condensed...)
-
-	sort.Slice(summaries, func(i, j int) bool {
-		left := summaries[i]
-		right := summaries[j]
-		if left.depth != right.depth {
-			return left.depth > right.depth
-		}
-		if left.createdAt != right.createdAt {
-			return left.createdAt < right.createdAt
-		}
-		return left.summaryID < right.summaryID
-	})
-
-	return repairPlan{
-		summaries:    summaries,
-		ordered:      ordered,
-		leafSequence: leafSeq,
-	}, nil
-}
-
-func loadCorruptedSummaries(ctx context.Context, q sqlQueryer, conversationID int64, summaryID string) ([]repairSummary, error) {
-	query := `
-		SELECT
-			s.summary_id,
-			s.conversation_id,
-			s.kind,
-			s.depth,
-			s.token_count,
-			s.content,
-			s.created_at,
-			COALESCE(spc.child_count, 0)
-		FROM summaries s
-		LEFT JOIN (
-			SELECT summary_id, COUNT(*) AS child_count
-			FROM summary_parents
-			GROUP BY summary_id
-		) spc ON spc.summary_id = s.summary_id
-		WHERE s.conversation_id = ?
-		  AND s.content LIKE ?
-	`
-	args := []any{conversationID, "%" + corruptedSummaryMarker + "%"}
-	if summaryID != "" {
-		query += " AND s.summary_id = ?"
-		args = append(args, summaryID)
-	}
-	query += " ORDER BY s.depth DESC, s.created_at ASC, s.summary_id ASC"
-
-	rows, err := q.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query corrupted summaries for conversation %d: %w", conversationID, err)
-	}
-	defer rows.Close()
-
-	var summaries []repairSummary
-	for rows.Next() {
-		var item repairSummary
-		if err := rows.Scan(
-			&item.summaryID,
-			&item.conversationID,
-			&item.kind,
-			&item.depth,
-			&item.tokenCount,
-			&item.content,
-			&item.createdAt,
-			&item.childCount,
-		); err != nil {
-			return nil, fmt.Errorf("scan corrupted summary row: %w", err)
-		}
-		summaries = append(summaries, item)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate corrupted summaries: %w", err)
-	}
-	return summaries, nil
-}
-
-func loadLeafSequence(ctx context.Context, q sqlQueryer, conversationID int64) ([]leafSequenceEntry, error) {
-	rows, err := q.QueryContext(ctx, `
-		SELECT
-			ci.ordinal,
-			ci.summary_id,
-			s.content,
-			CASE WHEN s.content LIKE ? THEN 1 ELSE 0 END AS corrupted
-		FROM context_items ci
-		JOIN summaries s ON ci.summary_id = s.summary_id
-		WHERE ci.conversation_id = ?
-		  AND ci.item_type = 'summary'
-		  AND s.depth = 0
-		ORDER BY ci.ordinal ASC
-	`, "%"+corruptedSummaryMarker+"%", conversationID)
-	if err != nil {
-		return nil, fmt.Errorf("query ordered leaves for conversation %d: %w", conversationID, err)
-	}
-	defer rows.Close()
-
-	var items []leafSequenceEntry
-	for rows.Next() {
-		var (
-			item         leafSequenceEntry
-			corruptedInt int
-		)
-		if err := rows.Scan(&item.ordinal, &item.summaryID, &item.content, &corruptedInt); err != nil {
-			return nil, fmt.Errorf("scan ordered leaf row: %w", err)
-		}
-		item.corrupted = corruptedInt == 1
-		items = append(items, item)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate ordered leaf rows: %w", err)
-	}
-	return items, nil
-}
-
-func summaryExists(ctx context.Context, q sqlQueryer, conversationID int64, summaryID string) (bool, error) {
-	var count int
-	if err := q.QueryRowContext(ctx, `
-		SELECT COUNT(*)
-		FROM summaries
-		WHERE conversation_id = ? AND summary_id = ?
-	`, conversationID, summaryID).Scan(&count); err != nil {
-		return false, fmt.Errorf("check summary existence for %q: %w", summaryID, err)
-	}
-	return count > 0, nil
-}
-
-func printDryRunReport(summaries []repairSummary, ordered []repairSummary) {
+func printDryRunReport(summaries []lcm.RepairSummary, ordered []lcm.RepairSummary) {
 	fmt.Printf("Found %d corrupted summaries:\n", len(summaries))
 	for _, item := range summaries {
-		line := fmt.Sprintf("  %s  %-9s d%d  %dt  %d chars", item.summaryID, item.kind, item.depth, item.tokenCount, len(item.content))
-		if item.depth > 0 || strings.EqualFold(item.kind, "condensed") {
-			line += fmt.Sprintf("  [%d children]", item.childCount)
+		line := fmt.Sprintf("  %s  %-9s d%d  %dt  %d chars", item.SummaryID, item.Kind, item.Depth, item.TokenCount, len(item.Content))
+		if item.Depth > 0 || strings.EqualFold(item.Kind, "condensed") {
+			line += fmt.Sprintf("  [%d children]", item.ChildCount)
+		}
+		if item.Marker != "" {
+			line += fmt.Sprintf("  marker: %q", item.Marker)
 		}
 		fmt.Println(line)
 	}
@@ -618,10 +821,10 @@ func printDryRunReport(summaries []repairSummary, ordered []repairSummary) {
 	depthCounts := make(map[int]int)
 	var depths []int
 	for _, item := range ordered {
-		if _, seen := depthCounts[item.depth]; !seen {
-			depths = append(depths, item.depth)
+		if _, seen := depthCounts[item.Depth]; !seen {
+			depths = append(depths, item.Depth)
 		}
-		depthCounts[item.depth]++
+		depthCounts[item.Depth]++
 	}
 	sort.Ints(depths)
 	for i, depth := range depths {
@@ -635,260 +838,232 @@ func printDryRunReport(summaries []repairSummary, ordered []repairSummary) {
 	fmt.Println("Run with --apply to execute repairs.")
 }
 
-func applyRepairs(ctx context.Context, db *sql.DB, plan repairPlan, opts repairOptions, client *anthropicClient) (int, error) {
-	if client == nil {
-		return 0, errors.New("missing Anthropic client")
-	}
-
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, fmt.Errorf("begin repair transaction: %w", err)
-	}
-
-	repaired := 0
-	rollbackNeeded := true
-	defer func() {
-		if rollbackNeeded {
-			_ = tx.Rollback()
-		}
-	}()
-
-	for i, item := range plan.ordered {
-		fmt.Printf("[%d/%d] %s (%s, d%d)\n", i+1, len(plan.ordered), item.summaryID, item.kind, item.depth)
-
-		source, err := buildSummaryRepairSource(ctx, tx, item)
+// repairDryRunJSON is the --json dry-run report: one entry per scanned
+// conversation, including conversations with nothing to repair.
+type repairDryRunJSON struct {
+	Conversations []repairConversationJSON `json:"conversations"`
+	Total         int                      `json:"total_corrupted"`
+}
+
+type repairConversationJSON struct {
+	ConversationID int64               `json:"conversation_id"`
+	Summaries      []repairSummaryJSON `json:"summaries"`
+	// RepairOrder lists summary IDs in the bottom-up order --apply uses.
+	RepairOrder []string `json:"repair_order"`
+}
+
+type repairSummaryJSON struct {
+	SummaryID     string `json:"summary_id"`
+	Kind          string `json:"kind"`
+	Depth         int    `json:"depth"`
+	TokenCount    int    `json:"token_count"`
+	ContentLength int    `json:"content_length"`
+	ChildCount    int    `json:"child_count"`
+	// RepairPosition is the 1-based index of the summary in RepairOrder.
+	RepairPosition int `json:"repair_position"`
+	// Marker is the corrupted-summary marker found in the content.
+	Marker string `json:"marker"`
+}
+
+// buildRepairConversationJSON converts a repair plan into its JSON form.
+func buildRepairConversationJSON(conversationID int64, plan lcm.RepairPlan) repairConversationJSON {
+	report := repairConversationJSON{
+		ConversationID: conversationID,
+		Summaries:      make([]repairSummaryJSON, 0, len(plan.Summaries)),
+		RepairOrder:    make([]string, 0, len(plan.Ordered)),
+	}
+	positions := make(map[string]int, len(plan.Ordered))
+	for i, item := range plan.Ordered {
+		report.RepairOrder = append(report.RepairOrder, item.SummaryID)
+		positions[item.SummaryID] = i + 1
+	}
+	for _, item := range plan.Summaries {
+		report.Summaries = append(report.Summaries, repairSummaryJSON{
+			SummaryID:      item.SummaryID,
+			Kind:           item.Kind,
+			Depth:          item.Depth,
+			TokenCount:     item.TokenCount,
+			ContentLength:  len(item.Content),
+			ChildCount:     item.ChildCount,
+			RepairPosition: positions[item.SummaryID],
+			Marker:         item.Marker,
+		})
+	}
+	return report
+}
+
+// writeRepairDryRunJSON scans each conversation and writes a single JSON
+// document so the output can be piped straight into other tools.
+func writeRepairDryRunJSON(ctx context.Context, db *sql.DB, w io.Writer, conversationIDs []int64, summaryID string, markers []string, tokens lcm.TokenRange) error {
+	report := repairDryRunJSON{Conversations: make([]repairConversationJSON, 0, len(conversationIDs))}
+	for _, id := range conversationIDs {
+		plan, err := lcm.BuildRepairPlan(ctx, db, id, summaryID, markers, tokens)
 		if err != nil {
-			return repaired, err
-		}
-		fmt.Printf("  Sources: %d %s (%d tokens)\n", source.itemCount, source.label, source.estimatedTokens)
-
-		oldDescriptor := "existing content"
-		if strings.Contains(item.content, corruptedSummaryMarker) {
-			oldDescriptor = "truncated garbage"
-		}
-		fmt.Printf("  Old: %d chars / %d tokens (%s)\n", len(item.content), item.tokenCount, oldDescriptor)
-		if opts.verbose {
-			fmt.Printf("  Old hash: %s | Preview: %q\n", shortSHA256(item.content), previewForLog(item.content, 100))
-		}
-
-		previousContext, err := resolvePreviousContext(ctx, tx, item)
-		if err != nil {
-			return repaired, err
-		}
-		prompt, targetTokens := buildRepairPrompt(item.kind, source.text, previousContext, source.estimatedTokens)
-		newContent, err := client.summarize(ctx, prompt, targetTokens)
-		if err != nil {
-			return repaired, fmt.Errorf("summarize %s: %w", item.summaryID, err)
-		}
-
-		newTokens := estimateTokenCount(newContent)
-		if newTokens == 0 && strings.TrimSpace(newContent) != "" {
-			newTokens = 1
-		}
-
-		if _, err := tx.ExecContext(ctx, `
-			UPDATE summaries
-			SET content = ?, token_count = ?
-			WHERE summary_id = ?
-		`, newContent, newTokens, item.summaryID); err != nil {
-			return repaired, fmt.Errorf("update summary %s: %w", item.summaryID, err)
+			return err
 		}
-		fmt.Printf("  New: %d chars / %d tokens ✓\n\n", len(newContent), newTokens)
-		repaired++
+		report.Conversations = append(report.Conversations, buildRepairConversationJSON(id, plan))
+		report.Total += len(plan.Summaries)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return repaired, fmt.Errorf("commit repair transaction: %w", err)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("encode repair report: %w", err)
 	}
-	rollbackNeeded = false
-	return repaired, nil
+	return nil
 }
 
-func buildSummaryRepairSource(ctx context.Context, q sqlQueryer, item repairSummary) (repairSource, error) {
-	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-		return buildLeafRepairSource(ctx, q, item.summaryID)
-	}
-	return buildCondensedRepairSource(ctx, q, item.summaryID)
-}
-
-// buildLeafRepairSource reconstructs a summary's source segment from linked messages and parts.
-func buildLeafRepairSource(ctx context.Context, q sqlQueryer, summaryID string) (repairSource, error) {
-	rows, err := q.QueryContext(ctx, `
-		SELECT
-			sm.ordinal,
-			m.message_id,
-			m.role,
-			m.content,
-			mp.ordinal,
-			mp.part_type,
-			mp.text_content
-		FROM summary_messages sm
-		JOIN messages m ON m.message_id = sm.message_id
-		LEFT JOIN message_parts mp
-			ON mp.message_id = m.message_id
-			AND COALESCE(mp.is_ignored, 0) = 0
-		WHERE sm.summary_id = ?
-		ORDER BY sm.ordinal ASC, mp.ordinal ASC
-	`, summaryID)
+// applyRepairs regenerates plan's summaries with client through
+// lcm.ApplyRepairs, printing progress to opts.stdout() and recording each
+// repair in opts.report. Parallel runs set opts.writeMu, which stages the
+// writes so no write lock is held across API calls.
+func applyRepairs(ctx context.Context, db *sql.DB, plan lcm.RepairPlan, opts repairOptions, client *anthropicClient) (int, error) {
+	if client == nil {
+		return 0, errors.New("missing Anthropic client")
+	}
+	w := opts.stdout()
+	redactions := lcm.RedactionCounts{}
+	repaired, err := lcm.ApplyRepairs(ctx, db, plan, lcm.RepairOptions{
+		Generate: func(ctx context.Context, q lcm.Queryer, item lcm.RepairSummary, pending map[string]string) (lcm.Repair, error) {
+			result, err := generateRepair(ctx, q, item, opts, client, pending, &redactions)
+			if err != nil {
+				return result, err
+			}
+			printRepairResult(result, opts)
+			return result, nil
+		},
+		CommitEach: opts.commitEach,
+		WriteMu:    opts.writeMu,
+		Progress: func(i, total int, item lcm.RepairSummary) {
+			fmt.Fprintf(w, "[%d/%d] %s (%s, d%d)\n", i, total, item.SummaryID, item.Kind, item.Depth)
+		},
+		Written: func(r lcm.Repair) {
+			opts.report.addSummary(repairReportEntry(r))
+		},
+		RolledBack: func(discarded []lcm.Repair) {
+			opts.report.rollBackFrom(opts.report.mark() - len(discarded))
+		},
+		Stopped: func(item lcm.RepairSummary) {
+			opts.report.stopAt(item.SummaryID)
+		},
+	})
 	if err != nil {
-		return repairSource{}, fmt.Errorf("query summary messages for %s: %w", summaryID, err)
+		return repaired, err
 	}
-	defer rows.Close()
+	fprintRedactionTotal(w, opts.redactor, redactions)
+	return repaired, nil
+}
 
-	type messageChunk struct {
-		role     string
-		fallback string
-		parts    []string
+func repairReportEntry(r lcm.Repair) runReportSummary {
+	return runReportSummary{
+		ConversationID: r.Summary.ConversationID, SummaryID: r.Summary.SummaryID, Kind: r.Summary.Kind, Depth: r.Summary.Depth,
+		Action: "repaired", OldTokens: r.Summary.TokenCount, NewTokens: r.Tokens, NewChars: utf8.RuneCountInString(r.Content), Model: r.Model,
+		DurationMS: time.Since(r.Started).Milliseconds(),
 	}
+}
 
-	var (
-		lines            []string
-		currentMessageID int64
-		active           bool
-		current          messageChunk
-	)
+// generateRepair builds item's source and previous context from q and
+// summarizes it. pending is lcm.RepairOptions.Generate's: content
+// regenerated earlier in the run but not yet written, keyed by summary ID.
+func generateRepair(ctx context.Context, q sqlQueryer, item lcm.RepairSummary, opts repairOptions, client *anthropicClient, pending map[string]string, redactions *lcm.RedactionCounts) (lcm.Repair, error) {
+	w := opts.stdout()
+	result := lcm.Repair{Summary: item, Started: time.Now()}
 
-	flushCurrent := func() {
-		if !active {
-			return
-		}
-		role := strings.TrimSpace(current.role)
-		if role == "" {
-			role = "unknown"
-		}
-		body := strings.TrimSpace(strings.Join(current.parts, "\n"))
-		if body == "" {
-			body = strings.TrimSpace(current.fallback)
-		}
-		if body == "" {
-			body = "(empty)"
-		}
-		lines = append(lines, fmt.Sprintf("[%s] %s", role, body))
+	source, err := lcm.BuildRepairSource(ctx, q, item, pending)
+	if err != nil {
+		return result, err
 	}
-
-	for rows.Next() {
-		var (
-			summaryOrdinal int64
-			messageID      int64
-			role           string
-			content        string
-			partOrdinal    sql.NullInt64
-			partType       sql.NullString
-			partTextValue  sql.NullString
-		)
-		if err := rows.Scan(&summaryOrdinal, &messageID, &role, &content, &partOrdinal, &partType, &partTextValue); err != nil {
-			return repairSource{}, fmt.Errorf("scan summary message row: %w", err)
-		}
-
-		if !active || currentMessageID != messageID {
-			flushCurrent()
-			currentMessageID = messageID
-			current = messageChunk{role: role, fallback: content}
-			active = true
-		}
-
-		partText := strings.TrimSpace(partTextValue.String)
-		if partText != "" {
-			current.parts = append(current.parts, partText)
-			continue
-		}
-
-		kind := strings.TrimSpace(partType.String)
-		if kind == "toolCall" || kind == "toolResult" || kind == "tool_call" || kind == "tool_result" {
-			current.parts = append(current.parts, "["+kind+"]")
-		}
+	if counts := source.Redact(opts.redactor); counts.Total() > 0 {
+		fmt.Fprintf(w, "  Redacted: %s\n", counts)
+		redactions.Add(counts)
 	}
-	if err := rows.Err(); err != nil {
-		return repairSource{}, fmt.Errorf("iterate summary message rows: %w", err)
+	fmt.Fprintf(w, "  Sources: %d %s (%d tokens)\n", source.ItemCount, source.Label, source.EstimatedTokens)
+	if opts.verbose {
+		printRepairPreview("Source preview", source.Text, opts)
 	}
-	flushCurrent()
 
-	if len(lines) == 0 {
-		return repairSource{}, fmt.Errorf("no source messages linked to summary %s", summaryID)
+	oldDescriptor := "existing content"
+	if item.Marker != "" {
+		oldDescriptor = fmt.Sprintf("truncated garbage, marker %q", item.Marker)
+	}
+	fmt.Fprintf(w, "  Old: %d chars / %d tokens (%s)\n", len(item.Content), item.TokenCount, oldDescriptor)
+	if opts.verbose {
+		fmt.Fprintf(w, "  Old hash: %s\n", shortSHA256(item.Content))
+		printRepairPreview("Old preview", item.Content, opts)
 	}
 
-	text := strings.Join(lines, "\n")
-	return repairSource{
-		text:            text,
-		itemCount:       len(lines),
-		estimatedTokens: estimateTokenCount(text),
-		label:           "messages",
-	}, nil
-}
-
-func buildCondensedRepairSource(ctx context.Context, q sqlQueryer, summaryID string) (repairSource, error) {
-	rows, err := q.QueryContext(ctx, `
-		SELECT sp.parent_summary_id, s.content
-		FROM summary_parents sp
-		JOIN summaries s ON s.summary_id = sp.parent_summary_id
-		WHERE sp.summary_id = ?
-		ORDER BY sp.ordinal ASC
-	`, summaryID)
+	previous, err := lcm.PreviousSummaries(ctx, q, item.SummaryID, item.ConversationID, item.Depth, item.Kind, item.CreatedAt, opts.prevContext)
 	if err != nil {
-		return repairSource{}, fmt.Errorf("query child summaries for %s: %w", summaryID, err)
+		return result, err
 	}
-	defer rows.Close()
-
-	var parts []string
-	for rows.Next() {
-		var childID string
-		var content string
-		if err := rows.Scan(&childID, &content); err != nil {
-			return repairSource{}, fmt.Errorf("scan child summary row: %w", err)
-		}
-		content = strings.TrimSpace(content)
-		if content == "" {
-			continue
+	for i := range previous {
+		if content, ok := pending[previous[i].SummaryID]; ok {
+			previous[i].Content = content
 		}
-		parts = append(parts, content)
 	}
-	if err := rows.Err(); err != nil {
-		return repairSource{}, fmt.Errorf("iterate child summary rows: %w", err)
+	fmt.Fprintf(w, "  Previous context: %s\n", lcm.DescribePreviousContext(previous, opts.prevContext))
+	// Previous context goes to the same API as the source, so it gets the
+	// same redaction.
+	previousContext, counts := opts.redactor.Redact(lcm.JoinPreviousSummaries(previous))
+	if counts.Total() > 0 {
+		fmt.Fprintf(w, "  Redacted previous context: %s\n", counts)
+		redactions.Add(counts)
 	}
-	if len(parts) == 0 {
-		return repairSource{}, fmt.Errorf("no child summaries linked to %s", summaryID)
+	prompt, targetTokens := buildRepairPrompt(item.Kind, source.Text, previousContext, source.EstimatedTokens, opts.charTarget)
+	summarize := opts.charTarget.summarizer(w, client.summarize)
+	var newContent string
+	if strings.EqualFold(item.Kind, "leaf") {
+		newContent, err = summarize(ctx, prompt, targetTokens)
+	} else {
+		newContent, err = summarizeCondensedRepair(ctx, w, summarize, prompt, targetTokens, opts.strictHeadings)
+	}
+	if err != nil {
+		return result, fmt.Errorf("summarize %s: %w", item.SummaryID, err)
+	}
+	if len(opts.modelFallbacks) > 0 {
+		fmt.Fprintf(w, "  Model: %s\n", client.lastModel)
 	}
 
-	text := strings.Join(parts, "\n\n")
-	return repairSource{
-		text:            text,
-		itemCount:       len(parts),
-		estimatedTokens: estimateTokenCount(text),
-		label:           "child summaries",
-	}, nil
+	newTokens := lcm.EstimateTokenCount(newContent)
+	if newTokens == 0 && strings.TrimSpace(newContent) != "" {
+		newTokens = 1
+	}
+	result.Content = newContent
+	result.Tokens = newTokens
+	result.Model = client.lastModel
+	return result, nil
 }
 
-func resolvePreviousContext(ctx context.Context, q sqlQueryer, item repairSummary) (string, error) {
-	content, err := previousContextLookup(ctx, q, item.summaryID, item.conversationID, item.depth, item.kind, item.createdAt)
-	if err != nil {
-		return "", err
+func printRepairResult(r lcm.Repair, opts repairOptions) {
+	if opts.verbose {
+		printRepairPreview("New preview", r.Content, opts)
 	}
-	if content == "" {
-		return "(none)", nil
+	if opts.charTarget.set() {
+		fmt.Fprintf(opts.stdout(), "  New: %s, target %d chars ✓\n\n", describeSummarySize(r.Content), opts.charTarget.chars)
+		return
 	}
-	return content, nil
+	fmt.Fprintf(opts.stdout(), "  New: %d chars / %d tokens ✓\n\n", len(r.Content), r.Tokens)
 }
 
-func buildRepairPrompt(kind, text, previousContext string, inputTokens int) (string, int) {
+// buildRepairPrompt returns the prompt for kind and the token target the
+// call is sized for. A --target-chars target replaces the token target in
+// both.
+func buildRepairPrompt(kind, text, previousContext string, inputTokens int, target charTarget) (string, int) {
+	targetTokens := lcm.CondensedTargetTokens
 	if strings.EqualFold(kind, "leaf") {
-		targetTokens := calculateLeafTargetTokens(inputTokens)
-		return buildLeafSummaryPrompt(text, previousContext, targetTokens), targetTokens
+		targetTokens = lcm.LeafTargetTokens(inputTokens)
 	}
-	return buildCondensedSummaryPrompt(text, previousContext, condensedTargetTokens), condensedTargetTokens
-}
-
-func calculateLeafTargetTokens(inputTokens int) int {
-	target := int(math.Floor(float64(inputTokens) * 0.35))
-	if target < 192 {
-		return 192
+	length := targetLengthPhrase(targetTokens, target)
+	if target.set() {
+		targetTokens = target.tokens()
 	}
-	if target > 1200 {
-		return 1200
+	if strings.EqualFold(kind, "leaf") {
+		return buildLeafSummaryPrompt(text, previousContext, length), targetTokens
 	}
-	return target
+	return buildCondensedSummaryPrompt(text, previousContext, length), targetTokens
 }
 
-func buildLeafSummaryPrompt(text, previousContext string, targetTokens int) string {
+func buildLeafSummaryPrompt(text, previousContext, targetLength string) string {
 	prev := strings.TrimSpace(previousContext)
 	if prev == "" {
 		prev = "(none)"
@@ -909,7 +1084,7 @@ Output requirements:
 - Keep it concise while preserving required details.
 - Track file operations (created, modified, deleted, renamed) with file paths and current status.
 - If no file operations appear, include exactly: "Files: none".
-- Target length: about %d tokens or less.
+- Target length: %s or less.
 
 <previous_context>
 %s
@@ -918,10 +1093,10 @@ Output requirements:
 <conversation_segment>
 %s
 </conversation_segment>
-`, targetTokens, prev, text)
+`, targetLength, prev, text)
 }
 
-func buildCondensedSummaryPrompt(text, previousContext string, targetTokens int) string {
+func buildCondensedSummaryPrompt(text, previousContext, targetLength string) string {
 	prev := strings.TrimSpace(previousContext)
 	if prev == "" {
 		prev = "(none)"
@@ -934,15 +1109,10 @@ Operator instructions: (none)
 Output requirements:
 - Use plain text.
 - Use these exact section headings in this exact order:
-Goals & Context
-Key Decisions
-Progress
-Constraints
-Critical Details
-Files
+%s
 - Under Files, list file operations (created, modified, deleted, renamed) with path and current status.
 - If no file operations are present, set Files to: none.
-- Target length: about %d tokens.
+- Target length: %s.
 
 <previous_context>
 %s
@@ -951,41 +1121,238 @@ Files
 <conversation_to_condense>
 %s
 </conversation_to_condense>
-`, targetTokens, prev, text)
+`, strings.Join(condensedSummaryHeadings, "\n"), targetLength, prev, text)
+}
+
+// condensedSummaryHeadings are the section headings buildCondensedSummaryPrompt
+// requires, in order.
+var condensedSummaryHeadings = []string{
+	"Goals & Context",
+	"Key Decisions",
+	"Progress",
+	"Constraints",
+	"Critical Details",
+	"Files",
+}
+
+// condensedHeadingRetries is how many extra summarize calls a condensed repair
+// makes when the output is missing required headings.
+const condensedHeadingRetries = 2
+
+// validateCondensedHeadings checks that content carries every required heading
+// in order. Headings may be decorated with markdown (#, **) or a trailing colon,
+// and "Files: none" counts as the Files heading.
+func validateCondensedHeadings(content string) error {
+	positions := make([]int, len(condensedSummaryHeadings))
+	for i := range positions {
+		positions[i] = -1
+	}
+	for lineIdx, line := range strings.Split(content, "\n") {
+		normalized := strings.ToLower(strings.Trim(strings.TrimSpace(line), "#* "))
+		for i, heading := range condensedSummaryHeadings {
+			if positions[i] >= 0 {
+				continue
+			}
+			want := strings.ToLower(heading)
+			rest, ok := strings.CutPrefix(normalized, want)
+			if !ok {
+				continue
+			}
+			rest = strings.TrimSpace(strings.Trim(rest, "*"))
+			if rest == "" || strings.HasPrefix(rest, ":") {
+				positions[i] = lineIdx
+			}
+		}
+	}
+
+	var missing []string
+	for i, pos := range positions {
+		if pos < 0 {
+			missing = append(missing, condensedSummaryHeadings[i])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing headings: %s", strings.Join(missing, ", "))
+	}
+	for i := 1; i < len(positions); i++ {
+		if positions[i] < positions[i-1] {
+			return fmt.Errorf("heading %q appears before %q", condensedSummaryHeadings[i], condensedSummaryHeadings[i-1])
+		}
+	}
+	return nil
+}
+
+// summarizeCondensedRepair re-requests a condensed summary until it carries the
+// required headings. When retries run out it fails in strict mode and otherwise
+// warns and returns the last attempt.
+func summarizeCondensedRepair(
+	ctx context.Context,
+	w io.Writer,
+	summarize func(context.Context, string, int) (string, error),
+	prompt string,
+	targetTokens int,
+	strict bool,
+) (string, error) {
+	var content string
+	var headingErr error
+	for attempt := 0; attempt <= condensedHeadingRetries; attempt++ {
+		var err error
+		content, err = summarize(ctx, prompt, targetTokens)
+		if err != nil {
+			return "", err
+		}
+		headingErr = validateCondensedHeadings(content)
+		if headingErr == nil {
+			return content, nil
+		}
+		if attempt < condensedHeadingRetries {
+			fmt.Fprintf(w, "  Headings invalid (%v); retrying (%d/%d)\n", headingErr, attempt+1, condensedHeadingRetries)
+		}
+	}
+	if strict {
+		return "", fmt.Errorf("condensed summary headings still invalid after %d retries: %w", condensedHeadingRetries, headingErr)
+	}
+	fmt.Fprintf(w, "  WARNING: applying condensed summary with invalid headings (%v); use --strict-headings to reject\n", headingErr)
+	return content, nil
+}
+
+// summaryPromptUserTags open the blocks that carry conversation-derived text.
+// Everything before the first of them is the template's fixed instructions.
+var summaryPromptUserTags = []string{"example_summaries", "previous_context", "conversation_segment", "conversation_to_condense"}
+
+// splitSummaryPrompt separates a rendered prompt into trusted instructions
+// (system) and conversation-derived content (user), so untrusted source text
+// never shares a message with the instructions. ok is false when the prompt
+// has no recognizable source block (e.g. a custom template); callers then
+// send the combined prompt as a single user message.
+func splitSummaryPrompt(prompt string) (system, user string, ok bool) {
+	cut := -1
+	for _, tag := range summaryPromptUserTags {
+		open := "<" + tag + ">"
+		idx := strings.Index(prompt, open)
+		if idx < 0 || (idx > 0 && prompt[idx-1] != '\n') {
+			continue
+		}
+		if cut < 0 || idx < cut {
+			cut = idx
+		}
+	}
+	if cut <= 0 {
+		return "", prompt, false
+	}
+	system = strings.TrimSpace(prompt[:cut])
+	user = strings.TrimSpace(prompt[cut:])
+	if system == "" || user == "" {
+		return "", prompt, false
+	}
+	return system, user, true
 }
 
+// providerSupportsSystemPrompt reports whether the HTTP path for provider
+// sends a separate system message. github-copilot proxies are left on the
+// combined form because not every backend model behind them accepts one.
+func providerSupportsSystemPrompt(provider string) bool {
+	switch normalizeProviderID(provider) {
+	case "anthropic", "openai", "openai-codex":
+		return true
+	default:
+		return false
+	}
+}
+
+// summarize runs the prompt against the configured model, moving down
+// modelFallbacks only when a model is unavailable. Other errors (rate limits,
+// auth, bad requests) are returned from the first model that hits them.
 func (c *anthropicClient) summarize(ctx context.Context, prompt string, targetTokens int) (string, error) {
-	provider, model := resolveSummaryProviderModel(c.provider, c.model)
+	if !c.deadline.IsZero() {
+		if c.deadlinePassed() {
+			return "", errOverallTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	chain := append([]string{c.model}, c.modelFallbacks...)
+	started := time.Now()
+	for i, modelHint := range chain {
+		content, model, err := c.summarizeWithModel(ctx, modelHint, prompt, targetTokens)
+		if c.observe != nil && (err == nil || i == len(chain)-1 || !isModelUnavailableError(err)) {
+			c.observe(model, lcm.EstimateTokenCount(prompt), lcm.EstimateTokenCount(content), time.Since(started), err)
+		}
+		if err == nil {
+			c.lastModel = model
+			if c.modelUsage == nil {
+				c.modelUsage = make(map[string]int)
+			}
+			c.modelUsage[model]++
+			return content, nil
+		}
+		if i == len(chain)-1 || !isModelUnavailableError(err) {
+			if c.deadlinePassed() {
+				return "", fmt.Errorf("%w: %v", errOverallTimeout, err)
+			}
+			return "", err
+		}
+		if c.logf != nil {
+			c.logf("  Model %s unavailable (%v); falling back to %s\n", model, err, chain[i+1])
+		}
+	}
+	return "", errors.New("no summary model configured")
+}
+
+// summarizeWithModel makes one summary call with modelHint and returns the
+// resolved model id alongside the content.
+func (c *anthropicClient) summarizeWithModel(ctx context.Context, modelHint, prompt string, targetTokens int) (string, string, error) {
+	provider, model := resolveSummaryProviderModel(c.provider, modelHint)
+	if provider == stubSummaryProvider {
+		return summarizeStub(prompt, targetTokens), model, nil
+	}
 	// Codex OAuth path has no raw API key: the codex CLI reads ~/.codex/auth.json
 	// directly. Allow an empty apiKey to reach summarizeOpenAI, which routes to
 	// the CLI delegate when hasCodexOAuth() is true.
 	if strings.TrimSpace(c.apiKey) == "" && !(provider == "openai-codex" && hasCodexOAuth()) {
-		return "", fmt.Errorf("missing API key for provider %q", provider)
+		return "", model, fmt.Errorf("missing API key for provider %q; set %s", provider, strings.Join(providerAPIEnvCandidates(provider), " or "))
 	}
 	if c.http == nil {
-		return "", errors.New("missing HTTP client")
+		return "", model, errors.New("missing HTTP client")
 	}
 	if targetTokens <= 0 {
-		targetTokens = condensedTargetTokens
+		targetTokens = lcm.CondensedTargetTokens
+	}
+	if err := c.limiter.wait(ctx); err != nil {
+		return "", model, err
 	}
 
+	var content string
+	var err error
 	switch provider {
 	case "anthropic":
-		return c.summarizeAnthropic(ctx, model, prompt, targetTokens)
+		content, err = c.summarizeAnthropic(ctx, model, prompt, targetTokens)
 	case "openai", "openai-codex", "github-copilot":
-		return c.summarizeOpenAI(ctx, model, prompt, targetTokens)
+		content, err = c.summarizeOpenAI(ctx, model, prompt, targetTokens)
 	default:
-		return "", fmt.Errorf("unsupported summarize provider %q (model %q)", provider, model)
+		err = fmt.Errorf("unsupported summarize provider %q (model %q)", provider, model)
 	}
+	return content, model, err
+}
+
+// anthropicMaxTokens is max_tokens for a call aiming at targetTokens: the
+// --max-output-tokens override when set, but never less than the target plus
+// a margin, since Anthropic cuts the summary off at exactly max_tokens.
+func (c *anthropicClient) anthropicMaxTokens(targetTokens int) int {
+	floor := targetTokens + max(minSummaryOutputMargin, targetTokens/4)
+	return max(floor, c.maxOutputTokens)
 }
 
 func (c *anthropicClient) summarizeAnthropic(ctx context.Context, model, prompt string, targetTokens int) (string, error) {
+	system, user, _ := splitSummaryPrompt(prompt)
 	reqBody := anthropicRequest{
 		Model:       model,
-		MaxTokens:   targetTokens,
-		Temperature: 0,
+		MaxTokens:   c.anthropicMaxTokens(targetTokens),
+		Temperature: c.temperature,
+		System:      system,
 		Messages: []anthropicRequestMessage{
-			{Role: "user", Content: prompt},
+			{Role: "user", Content: user},
 		},
 	}
 	payload, err := json.Marshal(reqBody)
@@ -1032,9 +1399,9 @@ func (c *anthropicClient) summarizeAnthropic(ctx context.Context, model, prompt
 	if resp.StatusCode >= 300 {
 		var apiErr anthropicErrorEnvelope
 		if json.Unmarshal(body, &apiErr) == nil && strings.TrimSpace(apiErr.Error.Message) != "" {
-			return "", fmt.Errorf("Anthropic API %d %s: %s", resp.StatusCode, apiErr.Error.Type, apiErr.Error.Message)
+			return "", &summaryAPIError{api: "Anthropic", status: resp.StatusCode, errType: apiErr.Error.Type, message: apiErr.Error.Message}
 		}
-		return "", fmt.Errorf("Anthropic API %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return "", &summaryAPIError{api: "Anthropic", status: resp.StatusCode, message: strings.TrimSpace(string(body))}
 	}
 
 	result, blockTypes, err := extractAnthropicSummary(body)
@@ -1188,7 +1555,7 @@ func normalizeCLISummaryOutput(cliName, result string, targetTokens int) (string
 		return result, nil
 	}
 
-	estimatedTokens := estimateTokenCount(result)
+	estimatedTokens := lcm.EstimateTokenCount(result)
 	maxTokens := targetTokens * cliOutputMaxOverageFactor
 	if slackLimit := targetTokens + cliOutputTokenSlack; slackLimit > maxTokens {
 		maxTokens = slackLimit
@@ -1208,12 +1575,12 @@ func capSummaryText(content string, originalTokens, maxTokens int) string {
 	}
 
 	for _, suffix := range suffixes {
-		contentBudget := maxTokens - estimateTokenCount(suffix)
+		contentBudget := maxTokens - lcm.EstimateTokenCount(suffix)
 		if contentBudget < 0 {
 			contentBudget = 0
 		}
 		capped := truncateTextToEstimatedTokens(content, contentBudget) + suffix
-		if estimateTokenCount(capped) <= maxTokens {
+		if lcm.EstimateTokenCount(capped) <= maxTokens {
 			return strings.TrimSpace(capped)
 		}
 	}
@@ -1250,20 +1617,15 @@ func (c *anthropicClient) summarizeOpenAI(ctx context.Context, model, prompt str
 		return summarizeViaCodexCLI(ctx, model, prompt, targetTokens)
 	}
 
+	maxOutputTokens := targetTokens
+	if c.maxOutputTokens > 0 {
+		maxOutputTokens = c.maxOutputTokens
+	}
 	reqBody := openAIResponsesRequest{
 		Model:           model,
-		MaxOutputTokens: targetTokens,
-		Input: []openAIResponsesInputMessage{
-			{
-				Role: "user",
-				Content: []openAIResponsesInputTextBlock{
-					{
-						Type: "input_text",
-						Text: prompt,
-					},
-				},
-			},
-		},
+		MaxOutputTokens: maxOutputTokens,
+		Temperature:     c.temperature,
+		Input:           buildOpenAIResponsesInput(c.provider, prompt),
 	}
 	payload, err := json.Marshal(reqBody)
 	if err != nil {
@@ -1300,9 +1662,9 @@ func (c *anthropicClient) summarizeOpenAI(ctx context.Context, model, prompt str
 	if resp.StatusCode >= 300 {
 		var apiErr openAIErrorEnvelope
 		if json.Unmarshal(body, &apiErr) == nil && strings.TrimSpace(apiErr.Error.Message) != "" {
-			return "", fmt.Errorf("OpenAI API %d %s: %s", resp.StatusCode, apiErr.Error.Type, apiErr.Error.Message)
+			return "", &summaryAPIError{api: "OpenAI", status: resp.StatusCode, errType: apiErr.Error.Type, code: apiErr.Error.Code, message: apiErr.Error.Message}
 		}
-		return "", fmt.Errorf("OpenAI API %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return "", &summaryAPIError{api: "OpenAI", status: resp.StatusCode, message: strings.TrimSpace(string(body))}
 	}
 
 	result, blockTypes, err := extractOpenAISummary(body)
@@ -1319,6 +1681,27 @@ func (c *anthropicClient) summarizeOpenAI(ctx context.Context, model, prompt str
 	return result, nil
 }
 
+// buildOpenAIResponsesInput sends the instructions as a system message when
+// the provider supports it and the prompt splits cleanly, and falls back to a
+// single combined user message otherwise.
+func buildOpenAIResponsesInput(provider, prompt string) []openAIResponsesInputMessage {
+	user := prompt
+	var input []openAIResponsesInputMessage
+	if providerSupportsSystemPrompt(provider) {
+		if system, content, ok := splitSummaryPrompt(prompt); ok {
+			input = append(input, openAIResponsesInputMessage{
+				Role:    "system",
+				Content: []openAIResponsesInputTextBlock{{Type: "input_text", Text: system}},
+			})
+			user = content
+		}
+	}
+	return append(input, openAIResponsesInputMessage{
+		Role:    "user",
+		Content: []openAIResponsesInputTextBlock{{Type: "input_text", Text: user}},
+	})
+}
+
 func extractAnthropicSummary(body []byte) (string, []string, error) {
 	var parsed anthropicResponse
 	if err := json.Unmarshal(body, &parsed); err != nil {
@@ -1478,6 +1861,10 @@ func resolveSummaryProviderModel(providerHint, modelHint string) (string, string
 	provider := normalizeProviderID(providerHint)
 	model := strings.TrimSpace(modelHint)
 
+	if provider == stubSummaryProvider {
+		return provider, stubSummaryProvider
+	}
+
 	if model == "" {
 		if provider == "openai" || provider == "openai-codex" || provider == "github-copilot" {
 			model = openAIResponsesModel
@@ -1532,6 +1919,9 @@ func resolveProviderAPIKey(paths appDataPaths, provider string) (string, error)
 	if normalizedProvider == "" {
 		normalizedProvider = defaultLLMProvider
 	}
+	if normalizedProvider == stubSummaryProvider {
+		return "", nil
+	}
 	envCandidates := providerAPIEnvCandidates(normalizedProvider)
 
 	for _, keyName := range envCandidates {
@@ -1668,21 +2058,29 @@ func resolveGatewayURL() string {
 	}
 }
 
+// providerAPIKeyEnvVars maps each summary provider to the env vars that hold
+// its API key, in lookup order. The first entry is the one error messages tell
+// the user to set. The same names scope the env-file and credential-file
+// fallbacks, so one provider never picks up another provider's key.
+var providerAPIKeyEnvVars = map[string][]string{
+	"anthropic":      {"ANTHROPIC_API_KEY"},
+	"openai":         {"OPENAI_API_KEY"},
+	"openai-codex":   {"OPENAI_API_KEY"},
+	"github-copilot": {"GITHUB_COPILOT_API_KEY", "OPENAI_API_KEY", "GITHUB_TOKEN"},
+}
+
+// providerAPIEnvCandidates returns the key env vars for provider. Providers
+// without an entry use <PROVIDER>_API_KEY; an empty provider means the
+// default provider.
 func providerAPIEnvCandidates(provider string) []string {
-	switch normalizeProviderID(provider) {
-	case "anthropic":
-		return []string{"ANTHROPIC_API_KEY"}
-	case "openai", "openai-codex":
-		return []string{"OPENAI_API_KEY"}
-	case "github-copilot":
-		return []string{"GITHUB_COPILOT_API_KEY", "OPENAI_API_KEY", "GITHUB_TOKEN"}
-	default:
-		derived := strings.ToUpper(strings.ReplaceAll(normalizeProviderID(provider), "-", "_"))
-		if derived == "" {
-			return []string{"ANTHROPIC_API_KEY"}
-		}
-		return []string{derived + "_API_KEY"}
+	normalizedProvider := normalizeProviderID(provider)
+	if normalizedProvider == "" {
+		normalizedProvider = defaultLLMProvider
+	}
+	if names, ok := providerAPIKeyEnvVars[normalizedProvider]; ok {
+		return append([]string(nil), names...)
 	}
+	return []string{strings.ToUpper(strings.ReplaceAll(normalizedProvider, "-", "_")) + "_API_KEY"}
 }
 
 func readAnthropicProfileMode(configPath string) (string, error) {
@@ -1857,6 +2255,11 @@ func walkForProviderKey(node any, provider string, envCandidates []string) strin
 	case map[string]any:
 		for key, child := range v {
 			lower := strings.ToLower(strings.TrimSpace(key))
+			if owner := credentialSectionProvider(lower); owner != "" && !providerAcceptsKeyOf(provider, owner) {
+				// Shared files like auth-tokens.json group keys by provider;
+				// never hand one provider's key to another.
+				continue
+			}
 			for _, envName := range envCandidates {
 				if lower == strings.ToLower(envName) {
 					if s, ok := child.(string); ok && looksLikeProviderKey(provider, s) {
@@ -1888,6 +2291,29 @@ func walkForProviderKey(node any, provider string, envCandidates []string) strin
 	return ""
 }
 
+// credentialSectionProvider returns the known provider a credential-file
+// object key names ("anthropic", "openai:default", ...), or "" for keys that
+// aren't provider sections.
+func credentialSectionProvider(key string) string {
+	name, _, _ := strings.Cut(normalizeProviderID(key), ":")
+	if _, ok := providerAPIKeyEnvVars[name]; ok {
+		return name
+	}
+	return ""
+}
+
+// providerAcceptsKeyOf reports whether owner's key env var is one provider
+// reads, e.g. openai-codex and github-copilot accept openai's key.
+func providerAcceptsKeyOf(provider, owner string) bool {
+	ownerEnv := providerAPIEnvCandidates(owner)[0]
+	for _, name := range providerAPIEnvCandidates(provider) {
+		if name == ownerEnv {
+			return true
+		}
+	}
+	return false
+}
+
 func looksLikeProviderKey(provider, value string) bool {
 	trimmed := strings.TrimSpace(value)
 	if !looksLikeAPIKey(trimmed) {
@@ -1897,9 +2323,12 @@ func looksLikeProviderKey(provider, value string) bool {
 	case "anthropic":
 		return strings.HasPrefix(trimmed, "sk-ant-")
 	case "openai", "openai-codex", "github-copilot":
+		if strings.HasPrefix(trimmed, "sk-ant-") {
+			return false
+		}
 		return strings.HasPrefix(trimmed, "sk-") || strings.HasPrefix(trimmed, "sess-")
 	default:
-		return true
+		return !strings.HasPrefix(trimmed, "sk-ant-")
 	}
 }
 
@@ -1929,6 +2358,14 @@ func shortSHA256(s string) string {
 	return fmt.Sprintf("%x", sum[:6])
 }
 
+// printRepairPreview prints the first opts.previewTokens of content indented
+// under label, wrapped so it stays legible in logs and narrow panes.
+func printRepairPreview(label, content string, opts repairOptions) {
+	w := opts.stdout()
+	fmt.Fprintf(w, "  %s:\n", label)
+	fmt.Fprintln(w, indentLines(wrapCLIText(previewTokens(content, opts.previewTokens), max(0, opts.wrapWidth-4)), "    "))
+}
+
 func previewForLog(s string, limit int) string {
 	s = strings.TrimSpace(strings.ReplaceAll(s, "\n", " "))
 	if len(s) <= limit {
@@ -1936,10 +2373,3 @@ func previewForLog(s string, limit int) string {
 	}
 	return s[:limit] + "..."
 }
-
-func estimateTokenCount(s string) int {
-	if len(s) == 0 {
-		return 0
-	}
-	return len(s) / 4
-}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// resolveCorruptedSummaryMarkers appends user patterns from --marker and
+// --marker-file to the built-in set, dropping blanks and duplicates.
+func resolveCorruptedSummaryMarkers(extra []string, markerFile string) ([]string, error) {
+	candidates := append([]string(nil), lcm.DefaultCorruptedSummaryMarkers...)
+	candidates = append(candidates, extra...)
+	if markerFile != "" {
+		fromFile, err := readCorruptedSummaryMarkerFile(markerFile)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, fromFile...)
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	markers := make([]string, 0, len(candidates))
+	for _, marker := range candidates {
+		marker = strings.TrimSpace(marker)
+		if marker == "" || seen[marker] {
+			continue
+		}
+		seen[marker] = true
+		markers = append(markers, marker)
+	}
+	return markers, nil
+}
+
+// readCorruptedSummaryMarkerFile reads one marker per line. Blank lines and
+// lines starting with # are skipped.
+func readCorruptedSummaryMarkerFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open marker file %q: %w", path, err)
+	}
+	defer file.Close()
+
+	var markers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		markers = append(markers, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read marker file %q: %w", path, err)
+	}
+	return markers, nil
+}
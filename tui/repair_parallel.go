@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// callLimiter spaces summary calls evenly to stay under a requests-per-minute
+// budget shared by every client holding it. A nil limiter never waits.
+type callLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newCallLimiter returns a limiter for perMinute calls, or nil when
+// perMinute is 0.
+func newCallLimiter(perMinute int) *callLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &callLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait blocks until the caller's slot, reserving the next one for whoever
+// asks after it.
+func (l *callLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// forConversation copies c's configuration into a client of its own, so
+// lastModel and model usage are per conversation, with fallback notices
+// written to w. The HTTP client, limiter, and observer are shared.
+func (c *anthropicClient) forConversation(w io.Writer) *anthropicClient {
+	clone := *c
+	clone.lastModel = ""
+	clone.modelUsage = nil
+	if c.logf != nil {
+		clone.logf = func(format string, args ...any) {
+			fmt.Fprintf(w, format, args...)
+		}
+	}
+	return &clone
+}
+
+// repairConversationResult is one conversation of a parallel run.
+type repairConversationResult struct {
+	out bytes.Buffer
+	// report buffers the conversation's report entries so the collector
+	// can add them in input order; nil when no --report-file was given.
+	report   *runReport
+	repaired int
+	err      error
+	skipped  bool
+	// unfinished is set when --overall-timeout passed before the
+	// conversation started or while its calls were running.
+	unfinished bool
+	done       chan struct{}
+}
+
+// runRepairConversationsParallel repairs conversationIDs with up to
+// opts.parallel conversations in flight. Each conversation has its own
+// client and output buffer; a buffer is written to w once every earlier
+// conversation's has been, so the output reads in the same order as a
+// sequential run. After a failure no further conversations are started;
+// those already running finish, and the first failure in input order is
+// returned with the total repaired. Once --overall-timeout passes, no
+// further conversations are started either, and the ones not finished are
+// returned in input order instead of an error.
+func runRepairConversationsParallel(ctx context.Context, db *sql.DB, w io.Writer, conversationIDs []int64, opts repairOptions, client *anthropicClient) (int, []int64, error) {
+	results := make([]*repairConversationResult, len(conversationIDs))
+	for i := range results {
+		results[i] = &repairConversationResult{done: make(chan struct{})}
+		if opts.report != nil {
+			results[i].report = &runReport{Summaries: []runReportSummary{}}
+		}
+	}
+	opts.writeMu = &sync.Mutex{}
+
+	var failed atomic.Bool
+	jobs := make(chan int)
+	var workers sync.WaitGroup
+	for n := 0; n < min(opts.parallel, len(conversationIDs)); n++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				result := results[i]
+				if failed.Load() {
+					result.skipped = true
+					close(result.done)
+					continue
+				}
+				if client.deadlinePassed() {
+					result.unfinished = true
+					close(result.done)
+					continue
+				}
+				conversationOpts := opts
+				conversationOpts.out = &result.out
+				conversationOpts.report = result.report
+				result.repaired, result.err = runRepairConversation(ctx, db, conversationIDs[i], conversationOpts, client.forConversation(&result.out))
+				if errors.Is(result.err, errOverallTimeout) {
+					result.unfinished = true
+					result.err = nil
+					if !opts.commitEach {
+						result.repaired = 0
+					}
+				}
+				if result.err != nil {
+					failed.Store(true)
+				}
+				close(result.done)
+			}
+		}()
+	}
+	go func() {
+		for i := range conversationIDs {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	total := 0
+	skipped := 0
+	var (
+		firstErr   error
+		unfinished []int64
+	)
+	for i, result := range results {
+		<-result.done
+		if result.skipped {
+			skipped++
+			continue
+		}
+		if result.unfinished {
+			unfinished = append(unfinished, conversationIDs[i])
+			if result.out.Len() == 0 {
+				continue
+			}
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		_, _ = w.Write(result.out.Bytes())
+		opts.report.addConversation(conversationIDs[i])
+		opts.report.absorb(result.report)
+		total += result.repaired
+		if result.err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("conversation %d: %w", conversationIDs[i], result.err)
+		}
+	}
+	workers.Wait()
+	if skipped > 0 {
+		fmt.Fprintf(w, "\n%d conversations were not started after the failure.\n", skipped)
+	}
+	if firstErr != nil {
+		return total, nil, firstErr
+	}
+	return total, unfinished, nil
+}
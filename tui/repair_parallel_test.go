@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func TestRunRepairConversationsParallelKeepsOrderAndFeedsRepairsForward(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	ids := []int64{1, 2, 3}
+	seedRepairBatchConversations(t, db, ids)
+
+	report := newRunReport(filepath.Join(t.TempDir(), "report.json"), "repair", nil, true)
+	opts := repairOptions{apply: true, all: true, parallel: 2, markers: lcm.DefaultCorruptedSummaryMarkers, report: report}
+	client := &anthropicClient{provider: stubSummaryProvider}
+
+	var out bytes.Buffer
+	repaired, unfinished, err := runRepairConversationsParallel(ctx, db, &out, ids, opts, client)
+	if err != nil {
+		t.Fatalf("parallel repair: %v\n%s", err, out.String())
+	}
+	if repaired != 6 || len(unfinished) != 0 {
+		t.Fatalf("repaired = %d, unfinished %v; want 6 and none", repaired, unfinished)
+	}
+
+	text := out.String()
+	last := -1
+	for _, id := range ids {
+		at := strings.Index(text, fmt.Sprintf("Repairing conversation %d...", id))
+		if at <= last {
+			t.Fatalf("conversation %d output out of order:\n%s", id, text)
+		}
+		last = at
+	}
+
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE content LIKE '[STUB SUMMARY%'`, 6)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'repair'`, 6)
+	// The condensed summary is regenerated from its repaired leaf, which was
+	// still only staged in memory when the parent was summarized.
+	for _, id := range ids {
+		var content string
+		if err := db.QueryRow(`SELECT content FROM summaries WHERE summary_id = ?`, fmt.Sprintf("sum_top_%d", id)).Scan(&content); err != nil {
+			t.Fatalf("load condensed summary: %v", err)
+		}
+		if strings.Contains(content, lcm.CorruptedSummaryMarker) || !strings.Contains(content, fmt.Sprintf("conversation %d", id)) {
+			t.Fatalf("sum_top_%d was not rebuilt from its repaired leaf: %q", id, content)
+		}
+	}
+	if len(report.Summaries) != 6 || len(report.ConversationIDs) != 3 {
+		t.Fatalf("unexpected report: %d summaries, conversations %v", len(report.Summaries), report.ConversationIDs)
+	}
+	// Summaries are added by the collector, so they follow input order
+	// whichever worker finished first.
+	for i, entry := range report.Summaries {
+		if want := ids[i/2]; entry.ConversationID != want {
+			t.Fatalf("report summary %d is from conversation %d, want %d", i, entry.ConversationID, want)
+		}
+	}
+}
+
+// seedRepairBatchConversations adds, for each id, a conversation with one
+// corrupted leaf and a corrupted condensed parent over it.
+func seedRepairBatchConversations(t *testing.T, db *sql.DB, ids []int64) {
+	t.Helper()
+	for _, id := range ids {
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO conversations (conversation_id, session_id, title) VALUES (%d, 'session-parallel-%d', 'Parallel')
+		`, id, id))
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+			VALUES (%d, %d, 1, 'user', 'ship the release notes for conversation %d', 8, '2026-03-22T10:00:00Z')
+		`, id, id, id))
+		mustExec(t, db, fmt.Sprintf(`
+			INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+			VALUES
+				('sum_leaf_%d', %d, 'leaf', 0, '%s', 10, '2026-03-22T10:00:00Z', '[]'),
+				('sum_top_%d', %d, 'condensed', 1, '%s', 10, '2026-03-22T10:05:00Z', '[]')
+		`, id, id, lcm.CorruptedSummaryMarker, id, id, lcm.CorruptedSummaryMarker))
+		mustExec(t, db, fmt.Sprintf(`INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('sum_leaf_%d', %d, 0)`, id, id))
+		mustExec(t, db, fmt.Sprintf(`INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal) VALUES ('sum_top_%d', 'sum_leaf_%d', 0)`, id, id))
+	}
+}
+
+func TestRunRepairConversationsStopsAtOverallTimeout(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	ids := []int64{1, 2, 3}
+	seedRepairBatchConversations(t, db, ids)
+
+	var out bytes.Buffer
+	report := newRunReport(filepath.Join(t.TempDir(), "report.json"), "repair", nil, true)
+	opts := repairOptions{apply: true, all: true, markers: lcm.DefaultCorruptedSummaryMarkers, report: report, out: &out}
+	client := &anthropicClient{provider: stubSummaryProvider}
+	// Each conversation makes four calls: the leaf, then the parent with two
+	// heading retries. The deadline passes after conversation 2's leaf is
+	// summarized, so its parent call is refused mid-conversation.
+	calls := 0
+	client.observe = func(string, int, int, time.Duration, error) {
+		calls++
+		if calls == 5 {
+			client.deadline = time.Now()
+		}
+	}
+
+	repaired, unfinished, err := runRepairConversations(ctx, db, &out, ids, opts, client)
+	if err != nil {
+		t.Fatalf("repair batch: %v\n%s", err, out.String())
+	}
+	if repaired != 2 {
+		t.Fatalf("repaired = %d, want 2\n%s", repaired, out.String())
+	}
+	if fmt.Sprint(unfinished) != "[2 3]" {
+		t.Fatalf("unfinished = %v, want [2 3]", unfinished)
+	}
+	// Conversation 1 is committed; conversation 2's transaction was rolled
+	// back, and conversation 3 was never started.
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE content LIKE '[STUB SUMMARY%' AND conversation_id = 1`, 2)
+	assertCount(t, db, fmt.Sprintf(`SELECT COUNT(*) FROM summaries WHERE content = '%s'`, lcm.CorruptedSummaryMarker), 4)
+	if strings.Contains(out.String(), "Repairing conversation 3") {
+		t.Fatalf("conversation 3 was started after the deadline:\n%s", out.String())
+	}
+
+	var stop bytes.Buffer
+	printOverallTimeoutStop(&stop, 45*time.Minute, len(ids)-len(unfinished), unfinished)
+	if want := "1 of 3 conversations finished.\nUnfinished conversations: 2, 3\n"; !strings.Contains(stop.String(), want) {
+		t.Fatalf("stop report = %q, want %q", stop.String(), want)
+	}
+}
+
+func TestApplyRepairsStagedRejectsConcurrentChange(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-staged', 'Staged')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES (1, 1, 1, 'user', 'please ship the release notes', 6, '2026-03-22T10:00:00Z')
+	`)
+	mustExec(t, db, fmt.Sprintf(`
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES ('sum_leaf', 1, 'leaf', 0, '%s', 10, '2026-03-22T10:00:00Z', '[]')
+	`, lcm.CorruptedSummaryMarker))
+	mustExec(t, db, `INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES ('sum_leaf', 1, 0)`)
+
+	plan, err := lcm.BuildRepairPlan(ctx, db, 1, "", lcm.DefaultCorruptedSummaryMarkers, lcm.TokenRange{})
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	// Another writer replaces the summary after the plan was built.
+	mustExec(t, db, `UPDATE summaries SET content = 'rewritten elsewhere' WHERE summary_id = 'sum_leaf'`)
+
+	var out bytes.Buffer
+	opts := repairOptions{out: &out, writeMu: &sync.Mutex{}}
+	repaired, err := applyRepairs(ctx, db, plan, opts, &anthropicClient{provider: stubSummaryProvider})
+	if err == nil || !strings.Contains(err.Error(), "changed while it was being repaired") {
+		t.Fatalf("expected concurrent change error, got %v", err)
+	}
+	if repaired != 0 {
+		t.Fatalf("repaired = %d, want 0", repaired)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE content = 'rewritten elsewhere'`, 1)
+}
+
+func TestCallLimiterSpacesCalls(t *testing.T) {
+	if newCallLimiter(0) != nil {
+		t.Fatal("expected no limiter for 0 requests per minute")
+	}
+	limiter := newCallLimiter(6000) // one call every 10ms
+	started := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 30*time.Millisecond {
+		t.Fatalf("4 calls took %v, want >= 30ms", elapsed)
+	}
+}
@@ -7,27 +7,195 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"math"
+	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 type rewriteOptions struct {
-	apply      bool
-	dryRun     bool
-	summaryID  string
-	depth      int
-	depthSet   bool
-	all        bool
-	promptDir  string
-	provider   string
-	model      string
-	baseURL    string
-	showDiff   bool
-	timestamps bool
-	tz         *time.Location
+	apply       bool
+	dryRun      bool
+	summaryID   string
+	depth       int
+	depthSet    bool
+	all         bool
+	promptDir   string
+	provider    string
+	model       string
+	baseURL     string
+	showDiff    bool
+	timestamps  bool
+	tz          *time.Location
+	freshTail   int
+	tokens      lcm.TokenRange
+	httpTimeout time.Duration
+	// overallTimeout is the wall-clock budget for the run's summary calls;
+	// the batch stops cleanly once it expires. 0 disables.
+	overallTimeout time.Duration
+	guard          rewriteGuard
+	// generation carries --temperature and --max-output-tokens.
+	generation summaryGenerationSettings
+	// modelFallbacks are tried in order when model is unavailable.
+	modelFallbacks []string
+	// contextOnly restricts the selection to summaries referenced by the
+	// conversation's context_items, i.e. those in the assembled prompt.
+	contextOnly bool
+	// continueFrom resumes an interrupted run at this summary ID, skipping
+	// the targets ordered before it.
+	continueFrom string
+	// explicitFlags names the flags given on the command line; agent
+	// defaults only fill in the rest.
+	explicitFlags map[string]bool
+	// wrapWidth word-wraps the printed OLD/NEW content; 0 disables.
+	wrapWidth int
+	// previewTokens limits the dry-run prompt preview; 0 prints it all.
+	previewTokens int
+	// deep rebuilds condensed sources from the raw messages under every
+	// leaf instead of the child summaries' text.
+	deep bool
+	// maxInputTokens skips summaries whose source is larger; 0 disables.
+	maxInputTokens int
+	// reportFile receives a JSON (or .md) run report; "" disables.
+	reportFile string
+	// redactor replaces secrets in source text before the prompt is built;
+	// nil when redaction is off.
+	redactor *sourceRedactor
+	// compareModels sends one summary's prompt to each model and prints the
+	// outputs instead of rewriting; entries may be "provider/model".
+	compareModels []string
+	// prevContext is --prev-context-count/--prev-context-depth: how many
+	// preceding summaries, from which depth, feed each prompt.
+	prevContext lcm.PreviousContextOptions
+	// ratioBounds is --flag-ratios: rewrites whose summary/source token
+	// ratio falls outside it are flagged in the output and run report.
+	ratioBounds compressionRatioBounds
+	// backupDB copies lcm.db to a timestamped backup before --apply writes.
+	backupDB bool
+	// examples picks --examples same-depth summaries to show as style
+	// exemplars; nil when the flag is 0.
+	examples *rewriteExemplars
+	// session is --session: the conversation named by its session ID,
+	// resolved once the DB is open.
+	session conversationSession
+	// charTarget is --target-chars/--char-retries; the zero value keeps the
+	// token target.
+	charTarget charTarget
+}
+
+// defaultRewriteMinTargetFraction is the smallest share of the target token
+// budget a rewrite may return before it is treated as a bad API turn.
+const defaultRewriteMinTargetFraction = 0.1
+
+// defaultDeepRewriteMaxInputTokens bounds --deep sources when
+// --max-input-tokens is not given; a full DAG walk can reach far past a
+// model's context window.
+const defaultDeepRewriteMaxInputTokens = 100000
+
+// rewriteRefusalPrefixes catch model refusals that would otherwise replace a
+// good summary. Matched case-insensitively against the start of the output.
+var rewriteRefusalPrefixes = []string{
+	"i'm sorry",
+	"i am sorry",
+	"i can't",
+	"i cannot",
+	"i'm unable",
+	"i am unable",
+	"i won't",
+	"as an ai",
+}
+
+// errSuspectRewrite marks rewrite output refused by rewriteGuard.
+var errSuspectRewrite = errors.New("suspicious rewrite output")
+
+// rewriteGuard rejects empty, refusal, or undersized rewrite output before it
+// overwrites a summary. force skips the check.
+type rewriteGuard struct {
+	minTargetFraction float64
+	force             bool
+}
+
+func defaultRewriteGuard() rewriteGuard {
+	return rewriteGuard{minTargetFraction: defaultRewriteMinTargetFraction}
+}
+
+// check reports why content looks like junk, or nil when it is acceptable.
+// It ignores force so callers can still warn about forced applies.
+func (g rewriteGuard) check(content string, targetTokens int) error {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return fmt.Errorf("%w: empty content", errSuspectRewrite)
+	}
+	lower := strings.ToLower(strings.TrimLeft(trimmed, "\"'`* "))
+	lower = strings.ReplaceAll(lower, "\u2019", "'")
+	for _, prefix := range rewriteRefusalPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return fmt.Errorf("%w: looks like a refusal (%q)", errSuspectRewrite, previewForLog(trimmed, 60))
+		}
+	}
+	minTokens := int(math.Ceil(g.minTargetFraction * float64(targetTokens)))
+	if tokens := lcm.EstimateTokenCount(trimmed); tokens < minTokens {
+		return fmt.Errorf("%w: %d tokens is below %d (%.0f%% of the %d-token target)",
+			errSuspectRewrite, tokens, minTokens, g.minTargetFraction*100, targetTokens)
+	}
+	return nil
+}
+
+// applySummaryRewrite is the single write path for CLI and TUI rewrites. It
+// refuses suspicious output unless the guard is forced, and records the
+// rewrite in audit_log in the same transaction.
+func applySummaryRewrite(ctx context.Context, db *sql.DB, summaryID, content string, tokens, targetTokens int, guard rewriteGuard) error {
+	if !guard.force {
+		if err := guard.check(content, targetTokens); err != nil {
+			return err
+		}
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin rewrite transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		conversationID int64
+		oldTokens      int
+	)
+	if err := tx.QueryRowContext(ctx, `
+		SELECT conversation_id, COALESCE(token_count, 0) FROM summaries WHERE summary_id = ?
+	`, summaryID).Scan(&conversationID, &oldTokens); err != nil {
+		return fmt.Errorf("load summary %s: %w", summaryID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE summaries
+		SET content = ?, token_count = ?
+		WHERE summary_id = ?
+	`, content, tokens, summaryID); err != nil {
+		return fmt.Errorf("update summary %s: %w", summaryID, err)
+	}
+	detail := ""
+	if guard.force {
+		detail = "guard forced"
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "rewrite", ConversationID: conversationID, SummaryIDs: []string{summaryID},
+		TokensBefore: oldTokens, TokensAfter: tokens, Detail: detail,
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rewrite of %s: %w", summaryID, err)
+	}
+	rollback = false
+	return nil
 }
 
 type rewriteSummary struct {
@@ -38,29 +206,23 @@ type rewriteSummary struct {
 	tokenCount     int
 	content        string
 	createdAt      string
+	createdSeq     int64 // summaries.created_seq: insertion order among same-second created_at
 	childCount     int
 }
 
-type rewriteSource struct {
-	text            string
-	itemCount       int
-	estimatedTokens int
-	timeRange       string
-	label           string
-}
-
-type summaryTimeRange struct {
-	earliest string
-	latest   string
-	valid    bool
-}
-
 // runRewriteCommand executes the standalone rewrite CLI workflow.
-func runRewriteCommand(args []string) error {
+func runRewriteCommand(args []string) (err error) {
 	opts, conversationID, err := parseRewriteArgs(args)
 	if err != nil {
 		return err
 	}
+	var report *runReport
+	if opts.session.set() {
+		report = newRunReport(opts.reportFile, "rewrite", args, opts.apply)
+	} else {
+		report = newRunReport(opts.reportFile, "rewrite", args, opts.apply, conversationID)
+	}
+	defer func() { err = report.close(err) }()
 
 	paths, err := resolveDataPaths()
 	if err != nil {
@@ -73,27 +235,81 @@ func runRewriteCommand(args []string) error {
 	}
 	defer db.Close()
 
+	ctx := context.Background()
+	if opts.session.set() {
+		conversationID, err = opts.session.resolve(db, conversationID)
+		if err != nil {
+			return err
+		}
+		report.addConversation(conversationID)
+	}
+	agent, err := resolveConversationAgent(ctx, db, paths.agentsDir, conversationID)
+	if err != nil {
+		return err
+	}
+	defaults, hasDefaults, err := resolveAgentDefaults(agent)
+	if err != nil {
+		return err
+	}
+	if hasDefaults {
+		applyRewriteAgentDefaults(&opts, defaults)
+		fmt.Println(describeAgentDefaults(agent))
+	}
+
 	settings := resolveTUISummaryRuntimeSettings(paths, opts.provider, opts.model, opts.baseURL, "", "")
 	opts.provider = settings.provider
 	opts.model = settings.model
 	opts.baseURL = settings.baseURL
+	if err := opts.generation.checkProvider(opts.provider); err != nil {
+		return err
+	}
+	printStubSummarizerNotice(opts.provider)
 
-	ctx := context.Background()
 	targets, err := loadRewriteTargets(ctx, db, conversationID, opts)
 	if err != nil {
 		return err
 	}
+	if opts.contextOnly {
+		withoutContext := opts
+		withoutContext.contextOnly = false
+		selected, err := loadRewriteTargets(ctx, db, conversationID, withoutContext)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Context-only: %d summaries in active context, %d excluded as not in context.\n", len(targets), len(selected)-len(targets))
+	}
 	if len(targets) == 0 {
+		if opts.tokens.Active() {
+			fmt.Printf("No summaries matched rewrite selection with token filter %s.\n", opts.tokens)
+			return nil
+		}
 		fmt.Println("No summaries matched rewrite selection.")
 		return nil
 	}
 
+	if opts.tokens.Active() {
+		fmt.Printf("Token filter %s matched %d summaries.\n", opts.tokens, len(targets))
+	}
+	if opts.continueFrom != "" {
+		resumed, err := resumeRewriteTargets(targets, opts.continueFrom)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Continuing from %s: skipping %d summaries processed by an earlier run.\n", opts.continueFrom, len(targets)-len(resumed))
+		targets = resumed
+	}
+	if len(opts.compareModels) > 0 {
+		return runRewriteModelComparison(ctx, db, paths, targets[0], opts, report)
+	}
 	fmt.Printf("Rewriting %d summaries in conversation %d...\n", len(targets), conversationID)
 	if opts.dryRun {
 		fmt.Println("Mode: dry-run (no DB writes)")
 	} else {
 		fmt.Println("Mode: apply")
 	}
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "rewrite", opts.apply && opts.backupDB, report); err != nil {
+		return err
+	}
 
 	var client *anthropicClient
 	if !opts.dryRun {
@@ -104,9 +320,14 @@ func runRewriteCommand(args []string) error {
 		client = &anthropicClient{
 			provider: opts.provider,
 			apiKey:   apiKey,
-			http:     &http.Client{Timeout: defaultHTTPTimeout},
+			http:     newSummaryHTTPClient(opts.httpTimeout),
 			model:    opts.model,
 			baseURL:  opts.baseURL,
+
+			modelFallbacks:  opts.modelFallbacks,
+			logf:            stdoutLogf,
+			temperature:     opts.generation.temperature,
+			maxOutputTokens: opts.generation.maxOutputTokens,
 		}
 	} else {
 		apiKey, err := resolveProviderAPIKey(paths, opts.provider)
@@ -114,71 +335,164 @@ func runRewriteCommand(args []string) error {
 			client = &anthropicClient{
 				provider: opts.provider,
 				apiKey:   apiKey,
-				http:     &http.Client{Timeout: defaultHTTPTimeout},
+				http:     newSummaryHTTPClient(opts.httpTimeout),
 				model:    opts.model,
 				baseURL:  opts.baseURL,
+
+				modelFallbacks:  opts.modelFallbacks,
+				logf:            stdoutLogf,
+				temperature:     opts.generation.temperature,
+				maxOutputTokens: opts.generation.maxOutputTokens,
 			}
 		}
 		if client == nil {
 			return fmt.Errorf("unable to resolve API key for provider %q during dry-run rewrite preview", opts.provider)
 		}
 	}
+	report.observe(client)
 
 	rewritten := 0
+	suspect := 0
+	oversized := 0
+	redactions := lcm.RedactionCounts{}
+	progress := rewriteProgress{}
+	ratios := rewriteRatioStats{}
+
+	// callCtx bounds the summary calls by --overall-timeout. DB reads and
+	// writes keep ctx so a rewrite that returned in time is still applied.
+	callCtx := ctx
+	if opts.overallTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, opts.overallTimeout)
+		defer cancel()
+		fmt.Printf("Overall timeout: %s (each call: %s)\n", opts.overallTimeout, opts.httpTimeout)
+	}
+	// stopAtDeadline ends the run cleanly when --overall-timeout expires.
+	// Applied rewrites are already committed; targets[idx:] are left for
+	// --continue-from.
+	stopAtDeadline := func(idx int) error {
+		next := targets[idx].summaryID
+		report.stopAt(next)
+		fmt.Printf("\nOverall timeout %s reached; stopping before %s (%d of %d summaries done).\n", opts.overallTimeout, next, idx, len(targets))
+		if opts.apply {
+			fmt.Print(progress.report(targets[idx:]))
+			fmt.Printf("Resume with --continue-from %s\n", next)
+		} else {
+			fmt.Printf("Previewed %d rewrites (dry-run).\n", rewritten)
+		}
+		printRedactionTotal(opts.redactor, redactions)
+		return nil
+	}
 	for idx, item := range targets {
+		if callCtx.Err() != nil {
+			return stopAtDeadline(idx)
+		}
 		fmt.Printf("\n[%d/%d] %s (d%d, %s)\n", idx+1, len(targets), item.summaryID, item.depth, item.kind)
+		// Every applied update is already committed, so a failure from here
+		// on reports exactly what was written and how to resume.
+		fail := func(err error) error {
+			report.stopAt(item.summaryID)
+			if !opts.apply {
+				return err
+			}
+			fmt.Print(progress.report(targets[idx:]))
+			return fmt.Errorf("%w (resume with --continue-from %s)", err, item.summaryID)
+		}
 
-		source, err := buildSummaryRewriteSource(ctx, db, item, opts.timestamps, opts.tz)
+		source, err := buildRewriteSourceForOptions(ctx, db, item, opts)
 		if err != nil {
-			return fmt.Errorf("build source for %s: %w", item.summaryID, err)
+			return fail(err)
 		}
-		previousContext, err := resolveRewritePreviousContext(ctx, db, item)
-		if err != nil {
-			return fmt.Errorf("resolve previous context for %s: %w", item.summaryID, err)
+		if counts := source.Redact(opts.redactor); counts.Total() > 0 {
+			fmt.Printf("Redacted: %s\n", counts)
+			redactions.Add(counts)
 		}
-
-		targetTokens := condensedTargetTokens
-		if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-			targetTokens = calculateLeafTargetTokens(source.estimatedTokens)
+		if opts.maxInputTokens > 0 && source.EstimatedTokens > opts.maxInputTokens {
+			fmt.Printf("SKIPPED: source is %d tokens (%d %s), over --max-input-tokens %d\n",
+				source.EstimatedTokens, source.ItemCount, source.Label, opts.maxInputTokens)
+			oversized++
+			progress.skipped = append(progress.skipped, item.summaryID)
+			report.addSummary(runReportSummary{
+				ConversationID: conversationID, SummaryID: item.summaryID, Kind: item.kind, Depth: item.depth,
+				Action: "skipped_oversized", OldTokens: item.tokenCount, NewTokens: item.tokenCount,
+				Note: fmt.Sprintf("source %d tokens > --max-input-tokens %d", source.EstimatedTokens, opts.maxInputTokens),
+			})
+			continue
 		}
-
-		prompt, err := renderPrompt(item.depth, PromptVars{
-			TargetTokens:    targetTokens,
-			PreviousContext: previousContext,
-			ChildCount:      source.itemCount,
-			TimeRange:       source.timeRange,
-			Depth:           item.depth,
-			SourceText:      source.text,
-		}, opts.promptDir)
+		prompt, targetTokens, err := renderRewritePrompt(ctx, db, item, source, opts)
 		if err != nil {
-			return fmt.Errorf("render prompt for %s: %w", item.summaryID, err)
+			return fail(err)
+		}
+		if !opts.apply {
+			fmt.Printf("PROMPT (%d tokens):\n%s\n\n", lcm.EstimateTokenCount(prompt), wrapCLIText(previewTokens(prompt, opts.previewTokens), opts.wrapWidth))
 		}
 
-		newContent, err := client.summarize(ctx, prompt, targetTokens)
+		callStarted := time.Now()
+		newContent, err := opts.charTarget.summarizer(os.Stdout, client.summarize)(callCtx, prompt, targetTokens)
 		if err != nil {
-			return fmt.Errorf("rewrite %s: %w", item.summaryID, err)
+			if callCtx.Err() != nil && ctx.Err() == nil {
+				fmt.Printf("Call cut off by --overall-timeout; %s was not rewritten.\n", item.summaryID)
+				return stopAtDeadline(idx)
+			}
+			return fail(fmt.Errorf("rewrite %s: %w", item.summaryID, err))
+		}
+		entry := runReportSummary{
+			ConversationID: conversationID, SummaryID: item.summaryID, Kind: item.kind, Depth: item.depth,
+			Action: "previewed", OldTokens: item.tokenCount, Model: client.lastModel,
+			DurationMS: time.Since(callStarted).Milliseconds(),
+		}
+		if len(opts.modelFallbacks) > 0 {
+			fmt.Printf("Model: %s\n", client.lastModel)
 		}
-		newTokens := estimateTokenCount(newContent)
+		newTokens := lcm.EstimateTokenCount(newContent)
 
-		printRewriteReport(item, source, item.content, newContent, item.tokenCount, newTokens)
+		printRewriteReport(item, source, item.content, newContent, item.tokenCount, newTokens, opts.wrapWidth)
+		entry.SourceTokens = source.EstimatedTokens
+		entry.CompressionRatio = compressionRatio(source.EstimatedTokens, newTokens)
+		ratioNote := ratios.observe(item, opts.ratioBounds, source.EstimatedTokens, newTokens)
+		fmt.Printf("Compression: %d -> %d tokens (%.2f of source)\n", source.EstimatedTokens, newTokens, entry.CompressionRatio)
+		if opts.charTarget.set() {
+			fmt.Printf("Length: %s (target %d chars)\n", describeSummarySize(newContent), opts.charTarget.chars)
+		}
+		if ratioNote != "" {
+			fmt.Printf("FLAGGED: %s\n", ratioNote)
+			entry.Note = ratioNote
+		}
 		if opts.showDiff {
 			diff := buildUnifiedDiff("old/"+item.summaryID, "new/"+item.summaryID, item.content, newContent)
-			for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
-				fmt.Println(colorizeDiffLineCLI(line))
+			printUnifiedDiffCLI(os.Stdout, diff, resolveCLIOutputStyle())
+		}
+
+		if guardErr := opts.guard.check(newContent, targetTokens); guardErr != nil {
+			switch {
+			case !opts.apply:
+				fmt.Printf("WARNING: %v; --apply would skip this rewrite without --force\n", guardErr)
+			case opts.guard.force:
+				fmt.Printf("WARNING: %v; applying anyway (--force)\n", guardErr)
+			default:
+				fmt.Printf("SKIPPED: %v; rerun with --force to apply\n", guardErr)
+				suspect++
+				progress.skipped = append(progress.skipped, item.summaryID)
+				entry.Action = "skipped_suspect"
+				entry.NewTokens = item.tokenCount
+				entry.Note = guardErr.Error()
+				report.addSummary(entry)
+				continue
 			}
 		}
 
 		if opts.apply {
-			if _, err := db.ExecContext(ctx, `
-				UPDATE summaries
-				SET content = ?, token_count = ?
-				WHERE summary_id = ?
-			`, newContent, newTokens, item.summaryID); err != nil {
-				return fmt.Errorf("update summary %s: %w", item.summaryID, err)
+			if err := applySummaryRewrite(ctx, db, item.summaryID, newContent, newTokens, targetTokens, opts.guard); err != nil {
+				return fail(err)
 			}
 			item.content = newContent
 			item.tokenCount = newTokens
+			progress.applied = append(progress.applied, item.summaryID)
+			entry.Action = "rewritten"
 		}
+		entry.NewTokens = newTokens
+		entry.NewChars = utf8.RuneCountInString(newContent)
+		report.addSummary(entry)
 		rewritten++
 	}
 
@@ -187,9 +501,130 @@ func runRewriteCommand(args []string) error {
 	} else {
 		fmt.Printf("\nDone. Previewed %d rewrites (dry-run).\n", rewritten)
 	}
+	if oversized > 0 {
+		fmt.Printf("Skipped %d summaries whose source exceeded --max-input-tokens %d.\n", oversized, opts.maxInputTokens)
+	}
+	fmt.Print(ratios.report(opts.ratioBounds))
+	printRedactionTotal(opts.redactor, redactions)
+	if suspect > 0 {
+		return fmt.Errorf("%d rewrites looked empty, refused, or undersized and were not applied; rerun with --force to apply them", suspect)
+	}
 	return nil
 }
 
+// buildRewriteSourceForOptions builds item's source, from the raw messages
+// under every leaf when opts.deep is set for a condensed summary.
+func buildRewriteSourceForOptions(ctx context.Context, q sqlQueryer, item rewriteSummary, opts rewriteOptions) (lcm.SummarySource, error) {
+	leaf := item.depth == 0 || strings.EqualFold(item.kind, "leaf")
+	source, err := lcm.BuildRewriteSource(ctx, q, item.summaryID, leaf, lcm.RewriteSourceOptions{
+		Timestamps: opts.timestamps, Location: opts.tz, FreshTail: opts.freshTail, Deep: opts.deep,
+	})
+	if err != nil {
+		return lcm.SummarySource{}, fmt.Errorf("build source for %s: %w", item.summaryID, err)
+	}
+	return source, nil
+}
+
+// renderRewritePrompt renders item's depth prompt over source and returns it
+// with the target token count. It prints which summaries went into the
+// previous context.
+func renderRewritePrompt(ctx context.Context, q sqlQueryer, item rewriteSummary, source lcm.SummarySource, opts rewriteOptions) (string, int, error) {
+	previous, err := lcm.PreviousSummaries(ctx, q, item.summaryID, item.conversationID, item.depth, item.kind, item.createdAt, opts.prevContext)
+	if err != nil {
+		return "", 0, fmt.Errorf("resolve previous context for %s: %w", item.summaryID, err)
+	}
+	fmt.Printf("Previous context: %s\n", lcm.DescribePreviousContext(previous, opts.prevContext))
+	previousContext, counts := opts.redactor.Redact(lcm.JoinPreviousSummaries(previous))
+	if counts.Total() > 0 {
+		fmt.Printf("Redacted previous context: %s\n", counts)
+	}
+	exemplars, err := opts.examples.forSummary(ctx, q, item)
+	if err != nil {
+		return "", 0, fmt.Errorf("select examples for %s: %w", item.summaryID, err)
+	}
+	var examples []string
+	if opts.examples != nil {
+		fmt.Printf("Examples: %s\n", describeRewriteExemplars(exemplars))
+		for _, exemplar := range exemplars {
+			// Examples and previous context go to the same API as the
+			// source, so they get the same redaction.
+			text, _ := opts.redactor.Redact(exemplar.content)
+			examples = append(examples, text)
+		}
+	}
+
+	targetTokens := lcm.CondensedTargetTokens
+	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
+		targetTokens = lcm.LeafTargetTokens(source.EstimatedTokens)
+	}
+	if opts.charTarget.set() {
+		targetTokens = opts.charTarget.tokens()
+	}
+
+	prompt, err := lcm.RenderPrompt(item.depth, lcm.PromptVars{
+		TargetTokens:    targetTokens,
+		PreviousContext: previousContext,
+		ChildCount:      source.ItemCount,
+		TimeRange:       source.TimeRange,
+		Depth:           item.depth,
+		SourceText:      source.Text,
+		FreshTailCount:  source.FreshCount,
+		Examples:        examples,
+		TargetChars:     opts.charTarget.chars,
+	}, opts.promptDir)
+	if err != nil {
+		return "", 0, fmt.Errorf("render prompt for %s: %w", item.summaryID, err)
+	}
+	if opts.charTarget.set() && !strings.Contains(prompt, fmt.Sprintf("%d characters", opts.charTarget.chars)) {
+		fmt.Printf("WARNING: the %s template does not use .TargetChars; it still asks for a token length\n", lcm.PromptNameForDepth(item.depth))
+	}
+	if len(examples) > 0 && !strings.Contains(prompt, examples[0]) {
+		fmt.Printf("WARNING: the %s template does not use .Examples; --examples has no effect on it\n", lcm.PromptNameForDepth(item.depth))
+	}
+	return prompt, targetTokens, nil
+}
+
+// rewriteProgress records what an --apply run has written so far.
+type rewriteProgress struct {
+	applied []string
+	skipped []string
+}
+
+// report lists applied, skipped, and remaining summary IDs after a failure.
+// remaining starts with the summary that failed.
+func (p rewriteProgress) report(remaining []rewriteSummary) string {
+	var b strings.Builder
+	b.WriteString("\nRewrite stopped before finishing.\n")
+	fmt.Fprintf(&b, "Applied (%d): %s\n", len(p.applied), formatRewriteIDList(p.applied))
+	if len(p.skipped) > 0 {
+		fmt.Fprintf(&b, "Skipped as suspicious (%d): %s\n", len(p.skipped), formatRewriteIDList(p.skipped))
+	}
+	ids := make([]string, 0, len(remaining))
+	for _, item := range remaining {
+		ids = append(ids, item.summaryID)
+	}
+	fmt.Fprintf(&b, "Remaining (%d): %s\n", len(ids), formatRewriteIDList(ids))
+	return b.String()
+}
+
+func formatRewriteIDList(ids []string) string {
+	if len(ids) == 0 {
+		return "(none)"
+	}
+	return strings.Join(ids, ", ")
+}
+
+// resumeRewriteTargets drops the targets ordered before summaryID so an
+// interrupted run can pick up where it stopped.
+func resumeRewriteTargets(targets []rewriteSummary, summaryID string) ([]rewriteSummary, error) {
+	for idx, item := range targets {
+		if item.summaryID == summaryID {
+			return targets[idx:], nil
+		}
+	}
+	return nil, fmt.Errorf("--continue-from %s does not match any selected summary", summaryID)
+}
+
 func parseRewriteArgs(args []string) (rewriteOptions, int64, error) {
 	fs := flag.NewFlagSet("rewrite", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
@@ -197,15 +632,45 @@ func parseRewriteArgs(args []string) (rewriteOptions, int64, error) {
 	apply := fs.Bool("apply", false, "apply rewrites to the DB")
 	dryRun := fs.Bool("dry-run", true, "show before/after without writing")
 	summaryID := fs.String("summary", "", "rewrite a specific summary ID")
+	session := fs.String("session", "", "rewrite the conversation recorded under this session ID")
+	conversationIndex := fs.Int("conversation-index", 0, "with --session, which of the session's conversations (1 = newest)")
 	depth := fs.Int("depth", 0, "rewrite summaries at a specific depth")
 	all := fs.Bool("all", false, "rewrite all summaries (bottom-up)")
 	promptDir := fs.String("prompt-dir", "", "custom prompt template directory")
 	provider := fs.String("provider", "", "provider id (e.g. anthropic, openai)")
 	model := fs.String("model", "", "summary model id")
+	modelFallback := fs.String("model-fallback", "", "comma-separated models to try when the model is unavailable")
 	baseURL := fs.String("base-url", "", "custom API base URL")
+	stub := fs.Bool("stub", false, "use the deterministic stub summarizer (demos/tests only)")
 	showDiff := fs.Bool("diff", false, "show unified diff")
 	timestamps := fs.Bool("timestamps", true, "inject timestamps into source text")
 	tzName := fs.String("tz", "", "timezone for timestamps (e.g. America/Los_Angeles; default: system local)")
+	freshTail := fs.Int("fresh-tail", 0, "mark the freshest N leaf source messages as [most recent]")
+	prevContextCount := fs.Int("prev-context-count", 1, "preceding summaries to include as previous context")
+	prevContextDepth := fs.Int("prev-context-depth", -1, "depth to draw previous context from (-1 = the summary's own depth)")
+	minTokens, maxTokens := addSummaryTokenRangeFlags(fs, "rewrite")
+	httpTimeout := fs.Duration("http-timeout", defaultHTTPTimeout, "timeout for each summary API call (e.g. 300s, 10m)")
+	fs.DurationVar(httpTimeout, "timeout-per-call", defaultHTTPTimeout, "alias for --http-timeout")
+	overallTimeout := fs.Duration("overall-timeout", 0, "stop the batch cleanly after this much wall-clock time (0 = no limit)")
+	temperature := fs.String("temperature", "", "sampling temperature for summary calls (default: provider default)")
+	maxOutputTokens := fs.Int("max-output-tokens", 0, "output token ceiling for summary calls (default: derived from the target)")
+	force := fs.Bool("force", false, "apply rewrites even when the output looks empty, refused, or undersized")
+	minTargetFraction := fs.Float64("min-target-fraction", defaultRewriteMinTargetFraction, "minimum share of the target tokens a rewrite must return")
+	continueFrom := fs.String("continue-from", "", "resume an interrupted run at this summary ID")
+	contextOnly := fs.Bool("context-only", false, "only rewrite summaries currently in the active context")
+	wrap := fs.Bool("wrap", true, "word-wrap printed OLD/NEW content")
+	width := fs.Int("width", 0, "wrap width for printed content (default: terminal width, else 100)")
+	previewTokenLimit := fs.Int("preview-tokens", defaultPreviewTokens, "tokens of the prompt to show in dry runs (0 = all)")
+	redact := fs.Bool("redact", false, "redact secrets from source text before it is sent to the summary API")
+	compareModels := fs.String("compare-models", "", "comma-separated models to run on one --summary side by side, without writing")
+	reportFile := fs.String("report-file", "", "write a JSON (or .md) run report to this path")
+	deep := fs.Bool("deep", false, "rebuild condensed sources from raw leaf messages")
+	maxInputTokens := fs.Int("max-input-tokens", 0, "skip summaries whose source exceeds n tokens (0 = no limit; --deep defaults to 100000)")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+	examples := fs.Int("examples", 0, "show n same-depth summaries as style examples in each prompt")
+	targetChars := fs.Int("target-chars", 0, "aim each summary at about n characters instead of the token target")
+	charRetries := fs.Int("char-retries", 1, "with --target-chars, re-request results more than 50% off the target up to n times")
+	flagRatios := fs.String("flag-ratios", defaultCompressionRatioBounds.String(), "flag rewrites whose summary/source token ratio is outside low,high")
 
 	normalizedArgs, err := normalizeRewriteArgs(args)
 	if err != nil {
@@ -224,23 +689,70 @@ func parseRewriteArgs(args []string) (rewriteOptions, int64, error) {
 		loc = parsed
 	}
 
+	stubProvider, err := resolveStubProviderFlag(*stub, strings.TrimSpace(*provider))
+	if err != nil {
+		return rewriteOptions{}, 0, fmt.Errorf("%w\n%s", err, rewriteUsageText())
+	}
+
 	opts := rewriteOptions{
-		apply:      *apply,
-		dryRun:     *dryRun,
-		summaryID:  strings.TrimSpace(*summaryID),
-		depth:      *depth,
-		all:        *all,
-		promptDir:  strings.TrimSpace(*promptDir),
-		provider:   strings.TrimSpace(*provider),
-		model:      strings.TrimSpace(*model),
-		baseURL:    strings.TrimSpace(*baseURL),
-		showDiff:   *showDiff,
-		timestamps: *timestamps,
-		tz:         loc,
-		depthSet:   rewriteDepthFlagSet(args),
+		apply:          *apply,
+		dryRun:         *dryRun,
+		summaryID:      strings.TrimSpace(*summaryID),
+		depth:          *depth,
+		all:            *all,
+		promptDir:      strings.TrimSpace(*promptDir),
+		provider:       stubProvider,
+		model:          strings.TrimSpace(*model),
+		modelFallbacks: parseModelFallbackList(*modelFallback),
+		baseURL:        strings.TrimSpace(*baseURL),
+		showDiff:       *showDiff,
+		timestamps:     *timestamps,
+		tz:             loc,
+		freshTail:      *freshTail,
+		tokens:         lcm.TokenRange{Min: *minTokens, Max: *maxTokens},
+		httpTimeout:    *httpTimeout,
+		overallTimeout: *overallTimeout,
+		guard:          rewriteGuard{minTargetFraction: *minTargetFraction, force: *force},
+		continueFrom:   strings.TrimSpace(*continueFrom),
+		contextOnly:    *contextOnly,
+		deep:           *deep,
+		reportFile:     strings.TrimSpace(*reportFile),
+		maxInputTokens: *maxInputTokens,
+		depthSet:       rewriteDepthFlagSet(args),
+		explicitFlags:  explicitFlags(fs),
+	}
+	if *width < 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--width must be >= 0")
+	}
+	opts.wrapWidth = resolveCLIWrapWidth(*wrap, *width)
+	if *previewTokenLimit < 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--preview-tokens must be >= 0")
+	}
+	opts.previewTokens = *previewTokenLimit
+	opts.prevContext, err = resolvePreviousContextSettings(*prevContextCount, *prevContextDepth)
+	if err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	opts.ratioBounds, err = parseCompressionRatioBounds(*flagRatios)
+	if err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	if opts.maxInputTokens < 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--max-input-tokens must be >= 0")
+	}
+	if *examples < 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--examples must be >= 0")
+	}
+	opts.examples = newRewriteExemplars(*examples)
+	opts.charTarget, err = newCharTarget(*targetChars, *charRetries)
+	if err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	if opts.deep && !opts.explicitFlags["max-input-tokens"] {
+		opts.maxInputTokens = defaultDeepRewriteMaxInputTokens
 	}
 	if opts.promptDir != "" {
-		opts.promptDir = expandHomePath(opts.promptDir)
+		opts.promptDir = lcm.ExpandHomePath(opts.promptDir)
 	}
 	if opts.apply {
 		opts.dryRun = false
@@ -265,13 +777,53 @@ func parseRewriteArgs(args []string) (rewriteOptions, int64, error) {
 	if opts.depthSet && opts.depth < 0 {
 		return rewriteOptions{}, 0, fmt.Errorf("--depth must be >= 0")
 	}
-	if fs.NArg() != 1 {
-		return rewriteOptions{}, 0, fmt.Errorf("conversation ID is required")
+	if opts.freshTail < 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--fresh-tail must be >= 0")
 	}
-
-	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err := validateSummaryTokenRange(opts.tokens); err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	if opts.httpTimeout <= 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--http-timeout must be > 0")
+	}
+	if opts.overallTimeout < 0 {
+		return rewriteOptions{}, 0, fmt.Errorf("--overall-timeout must be >= 0")
+	}
+	opts.generation, err = resolveSummaryGenerationSettings(*temperature, *maxOutputTokens)
 	if err != nil {
-		return rewriteOptions{}, 0, fmt.Errorf("parse conversation ID %q: %w", fs.Arg(0), err)
+		return rewriteOptions{}, 0, err
+	}
+	opts.redactor, err = resolveSourceRedactor(*redact)
+	if err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	opts.session, err = newConversationSession(*session, *conversationIndex)
+	if err != nil {
+		return rewriteOptions{}, 0, err
+	}
+	opts.compareModels = parseModelFallbackList(*compareModels)
+	if len(opts.compareModels) > 0 {
+		switch {
+		case opts.summaryID == "":
+			return rewriteOptions{}, 0, fmt.Errorf("--compare-models requires --summary")
+		case opts.apply:
+			return rewriteOptions{}, 0, fmt.Errorf("--compare-models never writes and cannot be combined with --apply")
+		case opts.continueFrom != "" || len(opts.modelFallbacks) > 0:
+			return rewriteOptions{}, 0, fmt.Errorf("--compare-models cannot be combined with --continue-from or --model-fallback")
+		case len(opts.compareModels) < 2:
+			return rewriteOptions{}, 0, fmt.Errorf("--compare-models needs at least two models")
+		}
+	}
+	if opts.guard.minTargetFraction < 0 || opts.guard.minTargetFraction > 1 {
+		return rewriteOptions{}, 0, fmt.Errorf("--min-target-fraction must be between 0 and 1")
+	}
+	conversationID, err := opts.session.conversationArg(fs.Args())
+	if err != nil {
+		return rewriteOptions{}, 0, err
 	}
 	return opts, conversationID, nil
 }
@@ -280,10 +832,43 @@ func normalizeRewriteArgs(args []string) ([]string, error) {
 	flags := make([]string, 0, len(args))
 	positionals := make([]string, 0, 1)
 
+	takesValue := map[string]bool{
+		"--summary":             true,
+		"--depth":               true,
+		"--prompt-dir":          true,
+		"--provider":            true,
+		"--model":               true,
+		"--model-fallback":      true,
+		"--tz":                  true,
+		"--base-url":            true,
+		"--fresh-tail":          true,
+		"--prev-context-count":  true,
+		"--prev-context-depth":  true,
+		"--min-tokens":          true,
+		"--max-tokens":          true,
+		"--http-timeout":        true,
+		"--timeout-per-call":    true,
+		"--overall-timeout":     true,
+		"--temperature":         true,
+		"--max-output-tokens":   true,
+		"--min-target-fraction": true,
+		"--continue-from":       true,
+		"--width":               true,
+		"--preview-tokens":      true,
+		"--max-input-tokens":    true,
+		"--report-file":         true,
+		"--compare-models":      true,
+		"--flag-ratios":         true,
+		"--examples":            true,
+		"--target-chars":        true,
+		"--char-retries":        true,
+		"--session":             true,
+		"--conversation-index":  true,
+	}
+
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
-		takesValue := arg == "--summary" || arg == "--depth" || arg == "--prompt-dir" || arg == "--provider" || arg == "--model" || arg == "--tz" || arg == "--base-url"
-		if takesValue {
+		if takesValue[arg] {
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("missing value for %s", arg)
 			}
@@ -291,14 +876,6 @@ func normalizeRewriteArgs(args []string) ([]string, error) {
 			i++
 			continue
 		}
-		if strings.HasPrefix(arg, "--summary=") || strings.HasPrefix(arg, "--depth=") || strings.HasPrefix(arg, "--prompt-dir=") || strings.HasPrefix(arg, "--provider=") || strings.HasPrefix(arg, "--model=") || strings.HasPrefix(arg, "--tz=") || strings.HasPrefix(arg, "--base-url=") {
-			flags = append(flags, arg)
-			continue
-		}
-		if arg == "--apply" || arg == "--dry-run" || strings.HasPrefix(arg, "--dry-run=") || arg == "--all" || arg == "--diff" || arg == "--timestamps" || strings.HasPrefix(arg, "--timestamps=") {
-			flags = append(flags, arg)
-			continue
-		}
 		if strings.HasPrefix(arg, "--") {
 			flags = append(flags, arg)
 			continue
@@ -322,8 +899,18 @@ func rewriteUsageText() string {
   lcm-tui rewrite <conversation_id> --summary <id> [--dry-run|--apply]
   lcm-tui rewrite <conversation_id> --depth <n> [--dry-run|--apply]
   lcm-tui rewrite <conversation_id> --all [--dry-run|--apply]
+  lcm-tui rewrite <conversation_id> --all --min-tokens 2500 [--dry-run|--apply]
+  lcm-tui rewrite <conversation_id> --all --context-only [--dry-run|--apply]
+  lcm-tui rewrite <conversation_id> --summary <id> --deep [--max-input-tokens <n>] [--dry-run|--apply]
+  lcm-tui rewrite <conversation_id> --summary <id> --compare-models <a,b,...>
+  lcm-tui rewrite --session <session_id> [--conversation-index <n>] --all [--dry-run|--apply]
 
 Flags:
+  --session <id>      rewrite the conversation recorded under this session ID (the JSONL file name)
+                      instead of passing its conversation ID
+  --conversation-index <n>
+                      with --session, pick the nth of the session's conversations, newest first as
+                      lcm-tui conversations lists them; required when a reset left several
   --summary <id>      rewrite a single summary
   --depth <n>         rewrite all summaries at depth n
   --all               rewrite all summaries (bottom-up)
@@ -332,18 +919,77 @@ Flags:
   --prompt-dir <path> custom template directory
   --provider <id>     API provider (inferred from model when omitted)
   --model <model>     API model (default: provider-specific)
+  --model-fallback <models>
+                      comma-separated models to retry with when the model is unknown, retired, or overloaded
   --base-url <url>    custom API base URL (overrides openclaw.json and env)
+  --stub              use the deterministic stub summarizer (demos/tests only)
   --diff              show unified diff
+  --width <n>         wrap printed OLD/NEW content at n columns (default: terminal width, else 100)
+  --wrap=false        print OLD/NEW content without wrapping
+  --preview-tokens <n>
+                      tokens of each prompt to show in dry runs (default 300, 0 = full prompt)
   --timestamps        inject timestamps into source text (default true)
   --tz <timezone>     timezone for timestamps (e.g. America/Los_Angeles; default: system local)
+  --fresh-tail <n>    mark the freshest N leaf source messages as [most recent] (default 0)
+  --prev-context-count <n>
+                      preceding summaries to include as previous context (default 1; 0 = none)
+  --prev-context-depth <n>
+                      draw previous context from depth N instead of the summary's own depth
+  --min-tokens <n>    only rewrite summaries with token_count >= n
+  --max-tokens <n>    only rewrite summaries with token_count <= n
+  --context-only      only rewrite summaries currently in the active context (context_items)
+  --deep              rebuild condensed sources from the raw messages under every leaf
+                      (higher fidelity when child summaries have degraded; larger prompts)
+  --max-input-tokens <n>
+                      skip summaries whose source exceeds n tokens (default: no limit, 100000 with --deep)
+  --http-timeout <d>  timeout for each summary API call (default 3m0s); alias --timeout-per-call
+  --overall-timeout <d>
+                      wall-clock budget for the whole run, e.g. 45m; stops cleanly before the next call
+  --temperature <t>   sampling temperature for summary calls, 0-2 (0-1 on Anthropic; default: provider default)
+  --max-output-tokens <n>
+                      output token ceiling per call (unlike --max-tokens, which selects summaries);
+                      Anthropic keeps at least the target plus a margin
+  --force             apply rewrites that look empty, refused, or undersized
+  --min-target-fraction <f>
+                      smallest share of the target tokens a rewrite may return (default 0.1; 0 disables)
+  --continue-from <id>
+                      resume an interrupted --apply run at this summary (printed on failure)
+  --report-file <path>
+                      write a JSON run report (markdown when path ends in .md), even on failure
+  --backup-db         with --apply, copy lcm.db to a timestamped backup first
+  --flag-ratios <low,high>
+                      flag rewrites whose summary/source token ratio is below low (lost too much)
+                      or above high (barely summarized) (default 0.05,0.95)
+  --examples <n>      show n existing summaries at the same depth as style examples in each prompt
+                      (default 0); complete, median-length, uncorrupted summaries are chosen first
+  --target-chars <n>  aim each summary at about n characters instead of the usual token target
+                      (for fixed-width fields or character-limited storage); results more than 50%
+                      off are re-requested
+  --char-retries <n>  with --target-chars, how many times to re-request an off-target result (default 1)
+  --compare-models <models>
+                      send one --summary's prompt to each model (bare or provider/model) and print
+                      the outputs with token counts; never writes
+  --redact            replace API keys, tokens, and private keys in source text with
+                      [REDACTED:<kind>] before it is sent (patterns: ~/.config/lcm-tui/redact.json)
 
 Env:
   LCM_TUI_SUMMARY_PROVIDER / LCM_TUI_SUMMARY_MODEL / LCM_TUI_SUMMARY_BASE_URL
   fall back to LCM_SUMMARY_PROVIDER / LCM_SUMMARY_MODEL / LCM_SUMMARY_BASE_URL
+  LCM_TUI_SUMMARY_TEMPERATURE / LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS
+  back --temperature / --max-output-tokens
+  HTTPS_PROXY / HTTP_PROXY / NO_PROXY route summary API calls through a proxy
+  LCM_SUMMARIZER=stub uses the deterministic stub summarizer (no API calls)
+  LCM_TUI_REDACT=1 turns on --redact for every command
+  LCM_TUI_BACKUP_DB=1 turns on --backup-db for every command
 `)
 }
 
 func loadRewriteTargets(ctx context.Context, q sqlQueryer, conversationID int64, opts rewriteOptions) ([]rewriteSummary, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	seq := caps.SummarySeqExpr("s")
 	query := `
 		SELECT
 			s.summary_id,
@@ -353,6 +999,7 @@ func loadRewriteTargets(ctx context.Context, q sqlQueryer, conversationID int64,
 			COALESCE(s.token_count, 0),
 			COALESCE(s.content, ''),
 			COALESCE(s.created_at, ''),
+			` + seq + `,
 			COALESCE(spc.child_count, 0)
 		FROM summaries s
 		LEFT JOIN (
@@ -371,7 +1018,19 @@ func loadRewriteTargets(ctx context.Context, q sqlQueryer, conversationID int64,
 		query += " AND COALESCE(s.depth, 0) = ?"
 		args = append(args, opts.depth)
 	}
-	query += " ORDER BY COALESCE(s.depth, 0) ASC, s.created_at ASC, s.summary_id ASC"
+	tokenClause, tokenArgs := opts.tokens.Clause("s.token_count")
+	query += tokenClause
+	args = append(args, tokenArgs...)
+	if opts.contextOnly {
+		query += `
+		  AND EXISTS (
+			SELECT 1 FROM context_items ci
+			WHERE ci.conversation_id = s.conversation_id
+			  AND ci.item_type = 'summary'
+			  AND ci.summary_id = s.summary_id
+		  )`
+	}
+	query += " ORDER BY COALESCE(s.depth, 0) ASC, s.created_at ASC, " + seq + " ASC, s.summary_id ASC"
 
 	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -390,6 +1049,7 @@ func loadRewriteTargets(ctx context.Context, q sqlQueryer, conversationID int64,
 			&item.tokenCount,
 			&item.content,
 			&item.createdAt,
+			&item.createdSeq,
 			&item.childCount,
 		); err != nil {
 			return nil, fmt.Errorf("scan rewrite summary row: %w", err)
@@ -400,7 +1060,7 @@ func loadRewriteTargets(ctx context.Context, q sqlQueryer, conversationID int64,
 		return nil, fmt.Errorf("iterate rewrite summary rows: %w", err)
 	}
 
-	if opts.summaryID != "" && len(targets) == 0 {
+	if opts.summaryID != "" && len(targets) == 0 && !opts.tokens.Active() && !opts.contextOnly {
 		return nil, fmt.Errorf("summary %s not found in conversation %d", opts.summaryID, conversationID)
 	}
 	if opts.all {
@@ -413,262 +1073,56 @@ func loadRewriteTargets(ctx context.Context, q sqlQueryer, conversationID int64,
 			if left.createdAt != right.createdAt {
 				return left.createdAt < right.createdAt
 			}
+			if left.createdSeq != right.createdSeq {
+				return left.createdSeq < right.createdSeq
+			}
 			return left.summaryID < right.summaryID
 		})
 	}
 	return targets, nil
 }
 
-func buildSummaryRewriteSource(ctx context.Context, q sqlQueryer, item rewriteSummary, includeTimestamps bool, loc *time.Location) (rewriteSource, error) {
-	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
-		return buildLeafRewriteSource(ctx, q, item.summaryID, includeTimestamps, loc)
-	}
-	return buildCondensedRewriteSource(ctx, q, item.summaryID, includeTimestamps, loc)
-}
-
-func buildLeafRewriteSource(ctx context.Context, q sqlQueryer, summaryID string, includeTimestamps bool, loc *time.Location) (rewriteSource, error) {
-	rows, err := q.QueryContext(ctx, fmt.Sprintf(`
-		SELECT m.role, %s AS content, COALESCE(m.created_at, '')
-		FROM summary_messages sm
-		JOIN messages m ON m.message_id = sm.message_id
-		WHERE sm.summary_id = ?
-		ORDER BY sm.ordinal ASC
-	`, messageDisplayContentSQL("m")), summaryID)
-	if err != nil {
-		return rewriteSource{}, fmt.Errorf("query leaf source for %s: %w", summaryID, err)
-	}
-	defer rows.Close()
-
-	parts := make([]string, 0, 32)
-	var earliest, latest string
-	for rows.Next() {
-		var role, content, createdAt string
-		if err := rows.Scan(&role, &content, &createdAt); err != nil {
-			return rewriteSource{}, fmt.Errorf("scan leaf source row: %w", err)
-		}
-		if strings.TrimSpace(role) == "" {
-			role = "unknown"
-		}
-		content = strings.TrimSpace(content)
-		if content == "" {
-			content = "(empty)"
-		}
-		formattedTime := formatTimestampWithLoc(createdAt, loc)
-		if formattedTime != "" {
-			if earliest == "" || formattedTime < earliest {
-				earliest = formattedTime
-			}
-			if latest == "" || formattedTime > latest {
-				latest = formattedTime
-			}
-		}
-		if includeTimestamps && formattedTime != "" {
-			parts = append(parts, fmt.Sprintf("[%s] [%s] %s", formattedTime, role, content))
-		} else {
-			parts = append(parts, fmt.Sprintf("[%s] %s", role, content))
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return rewriteSource{}, fmt.Errorf("iterate leaf source rows: %w", err)
-	}
-	if len(parts) == 0 {
-		return rewriteSource{}, fmt.Errorf("no messages linked to summary %s", summaryID)
-	}
-
-	text := strings.Join(parts, "\n")
-	return rewriteSource{
-		text:            text,
-		itemCount:       len(parts),
-		estimatedTokens: estimateTokenCount(text),
-		timeRange:       formatTimeRange(earliest, latest),
-		label:           "messages",
-	}, nil
-}
-
-func buildCondensedRewriteSource(ctx context.Context, q sqlQueryer, summaryID string, includeTimestamps bool, loc *time.Location) (rewriteSource, error) {
-	rows, err := q.QueryContext(ctx, `
-		SELECT sp.parent_summary_id, COALESCE(s.content, '')
-		FROM summary_parents sp
-		JOIN summaries s ON s.summary_id = sp.parent_summary_id
-		WHERE sp.summary_id = ?
-		ORDER BY sp.ordinal ASC
-	`, summaryID)
-	if err != nil {
-		return rewriteSource{}, fmt.Errorf("query condensed source for %s: %w", summaryID, err)
-	}
-	defer rows.Close()
-
-	parts := make([]string, 0, 32)
-	var minRange, maxRange string
-	for rows.Next() {
-		var childID, content string
-		if err := rows.Scan(&childID, &content); err != nil {
-			return rewriteSource{}, fmt.Errorf("scan condensed source row: %w", err)
-		}
-		content = strings.TrimSpace(content)
-		if content == "" {
-			continue
-		}
-		timeRange, err := lookupSummaryLeafTimeRange(ctx, q, childID, loc)
-		if err != nil {
-			return rewriteSource{}, fmt.Errorf("derive time range for child %s: %w", childID, err)
-		}
-		if timeRange.valid {
-			if minRange == "" || timeRange.earliest < minRange {
-				minRange = timeRange.earliest
-			}
-			if maxRange == "" || timeRange.latest > maxRange {
-				maxRange = timeRange.latest
-			}
-		}
-		if includeTimestamps && timeRange.valid {
-			header := fmt.Sprintf("[%s]", formatTimeRange(timeRange.earliest, timeRange.latest))
-			parts = append(parts, header+"\n"+content)
-			continue
-		}
-		parts = append(parts, content)
-	}
-	if err := rows.Err(); err != nil {
-		return rewriteSource{}, fmt.Errorf("iterate condensed source rows: %w", err)
-	}
-	if len(parts) == 0 {
-		return rewriteSource{}, fmt.Errorf("no child summaries linked to %s", summaryID)
-	}
-
-	text := strings.Join(parts, "\n\n")
-	return rewriteSource{
-		text:            text,
-		itemCount:       len(parts),
-		estimatedTokens: estimateTokenCount(text),
-		timeRange:       formatTimeRange(minRange, maxRange),
-		label:           "child summaries",
-	}, nil
-}
-
-func lookupSummaryLeafTimeRange(ctx context.Context, q sqlQueryer, summaryID string, loc *time.Location) (summaryTimeRange, error) {
-	var earliest, latest sql.NullString
-	err := q.QueryRowContext(ctx, `
-		WITH RECURSIVE walk(summary_id) AS (
-			SELECT ?
-			UNION ALL
-			SELECT sp.parent_summary_id
-			FROM summary_parents sp
-			JOIN walk w ON w.summary_id = sp.summary_id
-		)
-		SELECT MIN(m.created_at), MAX(m.created_at)
-		FROM walk w
-		JOIN summaries s ON s.summary_id = w.summary_id
-		JOIN summary_messages sm ON sm.summary_id = s.summary_id
-		JOIN messages m ON m.message_id = sm.message_id
-		WHERE COALESCE(s.depth, 0) = 0
-	`, summaryID).Scan(&earliest, &latest)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return summaryTimeRange{}, nil
-		}
-		return summaryTimeRange{}, fmt.Errorf("query summary time range: %w", err)
-	}
-	e := formatTimestampWithLoc(earliest.String, loc)
-	l := formatTimestampWithLoc(latest.String, loc)
-	if e == "" || l == "" {
-		return summaryTimeRange{}, nil
-	}
-	return summaryTimeRange{earliest: e, latest: l, valid: true}, nil
-}
-
 func resolveRewritePreviousContext(ctx context.Context, q sqlQueryer, item rewriteSummary) (string, error) {
 	// Use the shared previousContextLookup which handles both active
 	// context_items and absorbed nodes via summary_parents
-	return previousContextLookup(ctx, q, item.summaryID, item.conversationID, item.depth, item.kind, item.createdAt)
+	return lcm.PreviousContext(ctx, q, item.summaryID, item.conversationID, item.depth, item.kind, item.createdAt)
 }
 
-func colorizeDiffLineCLI(line string) string {
+// printUnifiedDiffCLI prints a unified diff, colored only when style allows
+// (stdout is a terminal and NO_COLOR is unset).
+func printUnifiedDiffCLI(w io.Writer, diff string, style cliOutputStyle) {
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		fmt.Fprintln(w, colorizeDiffLineCLI(line, style))
+	}
+}
+
+func colorizeDiffLineCLI(line string, style cliOutputStyle) string {
 	switch {
 	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
-		return "\033[1m" + line + "\033[0m" // bold
+		return style.paint(cliSGRBold, line)
 	case strings.HasPrefix(line, "@@"):
-		return "\033[36m" + line + "\033[0m" // cyan
+		return style.paint(cliSGRCyan, line)
 	case strings.HasPrefix(line, "+"):
-		return "\033[32m" + line + "\033[0m" // green
+		return style.paint(cliSGRGreen, line)
 	case strings.HasPrefix(line, "-"):
-		return "\033[31m" + line + "\033[0m" // red
+		return style.paint(cliSGRRed, line)
 	default:
 		return line
 	}
 }
 
-func printRewriteReport(item rewriteSummary, source rewriteSource, oldContent, newContent string, oldTokens, newTokens int) {
+func printRewriteReport(item rewriteSummary, source lcm.SummarySource, oldContent, newContent string, oldTokens, newTokens, width int) {
 	kindLabel := fmt.Sprintf("d%d", item.depth)
 	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
 		kindLabel = "leaf"
 	}
-	header := fmt.Sprintf("━━━ %s (%s, %d %s", item.summaryID, kindLabel, source.itemCount, source.label)
-	if source.timeRange != "" {
-		header += ", " + source.timeRange
+	header := fmt.Sprintf("━━━ %s (%s, %d %s", item.summaryID, kindLabel, source.ItemCount, source.Label)
+	if source.TimeRange != "" {
+		header += ", " + source.TimeRange
 	}
 	header += ") ━━━"
 	fmt.Println(header)
-	fmt.Printf("OLD (%d tokens):\n%s\n\n", oldTokens, strings.TrimSpace(oldContent))
-	fmt.Printf("NEW (%d tokens):\n%s\n\n", newTokens, strings.TrimSpace(newContent))
+	fmt.Printf("OLD (%d tokens):\n%s\n\n", oldTokens, wrapCLIText(oldContent, width))
+	fmt.Printf("NEW (%d tokens):\n%s\n\n", newTokens, wrapCLIText(newContent, width))
 	fmt.Printf("Δ tokens: %+d (%d -> %d)\n", newTokens-oldTokens, oldTokens, newTokens)
 }
-
-func formatTimestampWithLoc(raw string, loc *time.Location) string {
-	trimmed := strings.TrimSpace(raw)
-	if trimmed == "" {
-		return ""
-	}
-	if loc == nil {
-		loc = time.Local
-	}
-	if parsed, err := parseSQLiteTime(trimmed); err == nil {
-		t := parsed.In(loc)
-		zone := t.Format("MST")
-		return t.Format("2006-01-02 15:04 ") + zone
-	}
-	return ""
-}
-
-func parseSQLiteTime(raw string) (time.Time, error) {
-	layouts := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02 15:04:05.000",
-		"2006-01-02 15:04:05.000000",
-		"2006-01-02T15:04:05Z",
-		"2006-01-02T15:04:05.000Z",
-		"2006-01-02T15:04:05.000000Z",
-		"2006-01-02T15:04:05-07:00",
-		"2006-01-02T15:04:05.000-07:00",
-		"2006-01-02T15:04:05.000000-07:00",
-	}
-	for _, layout := range layouts {
-		parsed, parseErr := timeParse(layout, raw)
-		if parseErr == nil {
-			return parsed, nil
-		}
-	}
-	return time.Time{}, fmt.Errorf("unsupported time format %q", raw)
-}
-
-func formatTimeRange(earliest, latest string) string {
-	e := strings.TrimSpace(earliest)
-	l := strings.TrimSpace(latest)
-	if e == "" && l == "" {
-		return ""
-	}
-	if l == "" {
-		return e
-	}
-	if e == "" {
-		return l
-	}
-	if e == l {
-		return e
-	}
-	return e + " - " + l
-}
-
-// timeParse exists to keep timestamp parsing logic testable and isolated.
-func timeParse(layout, value string) (time.Time, error) {
-	return time.Parse(layout, value)
-}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// rewriteComparison is one model's output for --compare-models.
+type rewriteComparison struct {
+	label    string
+	provider string
+	model    string
+	content  string
+	tokens   int
+	duration time.Duration
+	suspect  error
+	err      error
+}
+
+// resolveCompareModel maps one --compare-models entry to a provider and
+// model. A "provider/model" entry names its own provider; a bare model uses
+// the run's provider.
+func resolveCompareModel(entry, runProvider string) (string, string) {
+	if strings.Contains(entry, "/") {
+		return resolveSummaryProviderModel("", entry)
+	}
+	return runProvider, entry
+}
+
+// runRewriteModelComparison builds item's source and prompt once, sends the
+// same prompt to every --compare-models entry, and prints the outputs one
+// after another plus a token table. Nothing is written to the DB. A model
+// that fails is reported in the table and the others still run.
+func runRewriteModelComparison(ctx context.Context, db *sql.DB, paths appDataPaths, item rewriteSummary, opts rewriteOptions, report *runReport) error {
+	source, err := buildRewriteSourceForOptions(ctx, db, item, opts)
+	if err != nil {
+		return err
+	}
+	if counts := source.Redact(opts.redactor); counts.Total() > 0 {
+		fmt.Printf("Redacted: %s\n", counts)
+	}
+	prompt, targetTokens, err := renderRewritePrompt(ctx, db, item, source, opts)
+	if err != nil {
+		return err
+	}
+
+	kindLabel := fmt.Sprintf("d%d", item.depth)
+	if item.depth == 0 || strings.EqualFold(item.kind, "leaf") {
+		kindLabel = "leaf"
+	}
+	fmt.Printf("Comparing %d models on %s (%s, %d %s, source %d tokens, prompt %d tokens, target %d tokens). No DB writes.\n",
+		len(opts.compareModels), item.summaryID, kindLabel, source.ItemCount, source.Label,
+		source.EstimatedTokens, lcm.EstimateTokenCount(prompt), targetTokens)
+	fmt.Printf("\nCURRENT (%d tokens):\n%s\n", item.tokenCount, wrapCLIText(item.content, opts.wrapWidth))
+
+	results := make([]rewriteComparison, 0, len(opts.compareModels))
+	for _, entry := range opts.compareModels {
+		result := rewriteComparison{label: entry}
+		result.provider, result.model = resolveCompareModel(entry, opts.provider)
+		if opts.provider == stubSummaryProvider {
+			result.provider = stubSummaryProvider
+		}
+		apiKey, err := resolveProviderAPIKey(paths, result.provider)
+		if err == nil {
+			err = opts.generation.checkProvider(result.provider)
+		}
+		if err == nil {
+			client := &anthropicClient{
+				provider: result.provider,
+				apiKey:   apiKey,
+				http:     newSummaryHTTPClient(opts.httpTimeout),
+				model:    result.model,
+				baseURL:  resolveProviderBaseURL(paths, result.provider, compareBaseURL(opts, result.provider)),
+
+				temperature:     opts.generation.temperature,
+				maxOutputTokens: opts.generation.maxOutputTokens,
+			}
+			report.observe(client)
+			started := time.Now()
+			result.content, err = client.summarize(ctx, prompt, targetTokens)
+			result.duration = time.Since(started)
+		}
+		if err != nil {
+			result.err = err
+			fmt.Printf("\n━━━ %s (%s) ━━━\nERROR: %v\n", result.label, result.provider, err)
+			report.addSummary(runReportSummary{
+				ConversationID: item.conversationID, SummaryID: item.summaryID, Kind: item.kind, Depth: item.depth,
+				Action: "compare_failed", OldTokens: item.tokenCount, NewTokens: item.tokenCount, Model: result.model,
+				Note: err.Error(),
+			})
+			results = append(results, result)
+			continue
+		}
+		result.tokens = lcm.EstimateTokenCount(result.content)
+		result.suspect = opts.guard.check(result.content, targetTokens)
+
+		fmt.Printf("\n━━━ %s (%s, %d tokens, %s) ━━━\n", result.label, result.provider, result.tokens, result.duration.Round(time.Millisecond))
+		if result.suspect != nil {
+			fmt.Printf("WARNING: %v\n", result.suspect)
+		}
+		fmt.Println(wrapCLIText(result.content, opts.wrapWidth))
+		entry := runReportSummary{
+			ConversationID: item.conversationID, SummaryID: item.summaryID, Kind: item.kind, Depth: item.depth,
+			Action: "compared", OldTokens: item.tokenCount, NewTokens: result.tokens, Model: result.model,
+			DurationMS: result.duration.Milliseconds(),
+		}
+		if result.suspect != nil {
+			entry.Note = result.suspect.Error()
+		}
+		report.addSummary(entry)
+		results = append(results, result)
+	}
+
+	fmt.Println()
+	printRewriteComparisonTable(item, targetTokens, results)
+
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d models failed to summarize %s", failed, len(results), item.summaryID)
+	}
+	return nil
+}
+
+// compareBaseURL passes --base-url through only to the run's own provider,
+// so a proxy for one provider is not used for another.
+func compareBaseURL(opts rewriteOptions, provider string) string {
+	if provider == opts.provider {
+		return opts.baseURL
+	}
+	return ""
+}
+
+func printRewriteComparisonTable(item rewriteSummary, targetTokens int, results []rewriteComparison) {
+	table := cliTable{columns: []cliTableColumn{
+		{header: "model"},
+		{header: "provider"},
+		{header: "tokens", align: cliAlignRight},
+		{header: "vs current", align: cliAlignRight},
+		{header: "of target", align: cliAlignRight},
+		{header: "time", align: cliAlignRight},
+		{header: "status", flex: true},
+	}}
+	table.addRow("(current)", "", strconv.Itoa(item.tokenCount), "", formatTargetShare(item.tokenCount, targetTokens), "", "stored")
+	for _, result := range results {
+		if result.err != nil {
+			table.addRow(result.label, result.provider, "", "", "", "", "error")
+			continue
+		}
+		status := "ok"
+		if result.suspect != nil {
+			status = "suspect: " + result.suspect.Error()
+		}
+		table.addRow(
+			result.label,
+			result.provider,
+			strconv.Itoa(result.tokens),
+			fmt.Sprintf("%+d", result.tokens-item.tokenCount),
+			formatTargetShare(result.tokens, targetTokens),
+			result.duration.Round(100*time.Millisecond).String(),
+			status,
+		)
+	}
+	for _, line := range table.render(resolveCLIOutputStyle()) {
+		fmt.Println(line)
+	}
+}
+
+func formatTargetShare(tokens, targetTokens int) string {
+	if targetTokens <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", tokens*100/targetTokens)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rewriteDiffStats counts changed lines between two summary bodies using the
+// same line diff the review screen renders.
+func rewriteDiffStats(oldContent, newContent string) (added, removed int) {
+	if oldContent == newContent {
+		return 0, 0
+	}
+	for _, op := range lineDiff(strings.Split(oldContent, "\n"), strings.Split(newContent, "\n")) {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// renderRewriteConfirm is the final checkpoint between review and the DB
+// write. It fits on one screen and only an explicit Y applies.
+func (m model) renderRewriteConfirm() string {
+	rw := m.pendingRewrite
+	added, removed := rewriteDiffStats(rw.oldContent, rw.newContent)
+	lines := []string{
+		fmt.Sprintf("Confirm rewrite: %s (%s, d%d)", rw.summaryID, rw.kind, rw.depth),
+		"",
+		fmt.Sprintf("Lines:  %s  %s", diffAddStyle.Render(fmt.Sprintf("+%d", added)), diffRemStyle.Render(fmt.Sprintf("-%d", removed))),
+		fmt.Sprintf("Tokens: %d -> %d (%+dt)", rw.oldTokens, rw.newTokens, rw.newTokens-rw.oldTokens),
+		fmt.Sprintf("Chars:  %d -> %d (%+d)", len(rw.oldContent), len(rw.newContent), len(rw.newContent)-len(rw.oldContent)),
+	}
+	if rw.force && rw.suspect != nil {
+		lines = append(lines, diffRemStyle.Render(fmt.Sprintf("Forcing past guard: %v", rw.suspect)))
+	}
+	lines = append(lines, "")
+	lines = append(lines, "This overwrites the stored summary content.")
+	lines = append(lines, "Press Y (shift+y) to apply. Press n or Esc to go back to the review.")
+	return strings.Join(lines, "\n")
+}
+
+// rewriteAuditEntry records one node applied during a subtree rewrite.
+type rewriteAuditEntry struct {
+	summaryID string
+	depth     int
+	oldTokens int
+	newTokens int
+	auto      bool // applied by auto-accept rather than an explicit confirm
+}
+
+// recordRewriteAudit notes an applied rewrite while a subtree run is active.
+func (m *model) recordRewriteAudit(rw rewriteState) {
+	if m.subtreeTotal == 0 {
+		return
+	}
+	m.subtreeAudit = append(m.subtreeAudit, rewriteAuditEntry{
+		summaryID: rw.summaryID,
+		depth:     rw.depth,
+		oldTokens: rw.oldTokens,
+		newTokens: rw.newTokens,
+		auto:      m.autoAccept,
+	})
+}
+
+// finishSubtreeRewrite ends a subtree run and, when anything was applied,
+// opens the audit overlay listing each node's token delta.
+func (m *model) finishSubtreeRewrite(status string) {
+	m.subtreeQueue = nil
+	m.subtreeTotal = 0
+	m.autoAccept = false
+	m.status = status
+	if len(m.subtreeAudit) > 0 {
+		m.rewriteAudit = m.subtreeAudit
+	}
+	m.subtreeAudit = nil
+	m.subtreeUsage = 0
+}
+
+// resolveAutoAcceptMaxTokens reads the auto-accept budget ceiling from env.
+// Unset, zero, or invalid values leave auto-accept unbounded.
+func resolveAutoAcceptMaxTokens() int {
+	value := strings.TrimSpace(os.Getenv("LCM_TUI_AUTO_ACCEPT_MAX_TOKENS"))
+	if value == "" {
+		return 0
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Printf("[lcm-tui] invalid LCM_TUI_AUTO_ACCEPT_MAX_TOKENS=%q, auto-accept budget disabled", value)
+		return 0
+	}
+	return parsed
+}
+
+// startAutoAccept enables auto-accept and opens a fresh budget window, so an
+// explicit A after a budget pause buys another full ceiling's worth of calls.
+func (m *model) startAutoAccept() {
+	m.autoAccept = true
+	m.autoAcceptBaseline = m.subtreeUsage
+}
+
+// autoAcceptSpent is the estimated API usage since auto-accept last started.
+func (m model) autoAcceptSpent() int {
+	return m.subtreeUsage - m.autoAcceptBaseline
+}
+
+// autoAcceptOverBudget reports whether auto-accept has used up its ceiling.
+func (m model) autoAcceptOverBudget() bool {
+	return m.autoAcceptMaxTokens > 0 && m.autoAcceptSpent() >= m.autoAcceptMaxTokens
+}
+
+// renderSubtreeUsage summarizes estimated API spend for the inflight banner.
+func (m model) renderSubtreeUsage() string {
+	if m.subtreeTotal == 0 {
+		return ""
+	}
+	line := fmt.Sprintf("API usage: ~%dt est. (prompt + output)", m.subtreeUsage)
+	if m.autoAccept && m.autoAcceptMaxTokens > 0 {
+		line += fmt.Sprintf("  auto-accept budget: %d/%dt", m.autoAcceptSpent(), m.autoAcceptMaxTokens)
+	}
+	return line
+}
+
+// renderRewriteAudit lists every node applied by the last subtree run.
+func (m model) renderRewriteAudit() string {
+	lines := []string{fmt.Sprintf("Subtree rewrite audit: %d applied", len(m.rewriteAudit)), ""}
+	totalOld, totalNew, auto := 0, 0, 0
+	for _, entry := range m.rewriteAudit {
+		marker := ""
+		if entry.auto {
+			marker = " [auto]"
+			auto++
+		}
+		lines = append(lines, fmt.Sprintf("  %s d%d  %dt -> %dt (%+dt)%s",
+			entry.summaryID, entry.depth, entry.oldTokens, entry.newTokens, entry.newTokens-entry.oldTokens, marker))
+		totalOld += entry.oldTokens
+		totalNew += entry.newTokens
+	}
+	maxLines := max(6, m.height-8)
+	if len(lines) > maxLines {
+		hidden := len(lines) - maxLines
+		lines = append(lines[:maxLines], fmt.Sprintf("  ... %d more", hidden))
+	}
+	lines = append(lines, "")
+	lines = append(lines, fmt.Sprintf("Total: %dt -> %dt (%+dt), %d auto-accepted", totalOld, totalNew, totalNew-totalOld, auto))
+	lines = append(lines, "Press any key to close.")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRewriteDiffStats(t *testing.T) {
+	added, removed := rewriteDiffStats("a\nb\nc", "a\nB\nc\nd")
+	if added != 2 || removed != 1 {
+		t.Fatalf("got +%d -%d, want +2 -1", added, removed)
+	}
+	if added, removed := rewriteDiffStats("same", "same"); added != 0 || removed != 0 {
+		t.Fatalf("expected no changes, got +%d -%d", added, removed)
+	}
+}
+
+func TestRewriteReviewRequiresExplicitConfirm(t *testing.T) {
+	m := model{screen: screenSummaries}
+	m.pendingRewrite = &rewriteState{
+		summaryID:  "sum_a",
+		depth:      1,
+		oldContent: "old line",
+		oldTokens:  100,
+		newContent: "new line\nextra",
+		newTokens:  60,
+		phase:      rewriteReview,
+	}
+
+	press := func(key string) {
+		t.Helper()
+		updated, _ := m.handleSummariesKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(model)
+	}
+
+	press("y")
+	if m.pendingRewrite == nil || m.pendingRewrite.phase != rewriteConfirm {
+		t.Fatalf("expected y to open the confirm step, got %+v", m.pendingRewrite)
+	}
+	view := m.renderRewriteOverlay()
+	for _, want := range []string{"+2", "-1", "100 -> 60 (-40t)"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("confirm view missing %q:\n%s", want, view)
+		}
+	}
+
+	// A second y must not apply; only Y does.
+	press("y")
+	if m.pendingRewrite == nil || m.pendingRewrite.phase != rewriteConfirm {
+		t.Fatalf("expected lowercase y to be ignored in confirm, got %+v", m.pendingRewrite)
+	}
+	press("n")
+	if m.pendingRewrite == nil || m.pendingRewrite.phase != rewriteReview {
+		t.Fatalf("expected n to return to review, got %+v", m.pendingRewrite)
+	}
+
+	m.pendingRewrite.suspect = errors.New("looks truncated")
+	press("y")
+	if m.pendingRewrite.phase != rewriteReview || !strings.Contains(m.status, "Refused") {
+		t.Fatalf("expected suspicious rewrite to stay in review, got phase=%d status=%q", m.pendingRewrite.phase, m.status)
+	}
+	press("!")
+	if m.pendingRewrite.phase != rewriteConfirm || !m.pendingRewrite.force {
+		t.Fatalf("expected ! to force into confirm, got %+v", m.pendingRewrite)
+	}
+}
+
+func TestFinishSubtreeRewriteShowsAudit(t *testing.T) {
+	m := model{screen: screenSummaries, subtreeTotal: 2, autoAccept: true}
+	m.recordRewriteAudit(rewriteState{summaryID: "sum_leaf", depth: 0, oldTokens: 900, newTokens: 700})
+	m.recordRewriteAudit(rewriteState{summaryID: "sum_root", depth: 1, oldTokens: 2000, newTokens: 2100})
+	m.finishSubtreeRewrite("Subtree rewrite complete (2 nodes, auto-accepted)")
+
+	if m.subtreeTotal != 0 || m.autoAccept || m.subtreeAudit != nil {
+		t.Fatalf("expected subtree state reset, got %+v", m)
+	}
+	audit := m.renderRewriteAudit()
+	for _, want := range []string{"sum_leaf d0  900t -> 700t (-200t) [auto]", "sum_root d1  2000t -> 2100t (+100t) [auto]", "Total: 2900t -> 2800t (-100t), 2 auto-accepted"} {
+		if !strings.Contains(audit, want) {
+			t.Fatalf("audit missing %q:\n%s", want, audit)
+		}
+	}
+
+	updated, _ := m.handleSummariesKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if updated.(model).rewriteAudit != nil {
+		t.Fatal("expected any key to dismiss the audit")
+	}
+}
+
+func TestAutoAcceptBudgetCeiling(t *testing.T) {
+	m := model{subtreeTotal: 5, autoAcceptMaxTokens: 1000}
+	m.startAutoAccept()
+	m.subtreeUsage = 999
+	if m.autoAcceptOverBudget() {
+		t.Fatal("expected budget to hold below the ceiling")
+	}
+	m.subtreeUsage = 1200
+	if !m.autoAcceptOverBudget() {
+		t.Fatal("expected budget to trip at the ceiling")
+	}
+	if got := m.renderSubtreeUsage(); !strings.Contains(got, "~1200t") || !strings.Contains(got, "1200/1000t") {
+		t.Fatalf("unexpected usage banner %q", got)
+	}
+
+	// An explicit A after the pause opens a fresh window.
+	m.autoAccept = false
+	m.startAutoAccept()
+	if m.autoAcceptOverBudget() || m.autoAcceptSpent() != 0 {
+		t.Fatalf("expected fresh budget window, spent %d", m.autoAcceptSpent())
+	}
+
+	m.autoAcceptMaxTokens = 0
+	m.subtreeUsage = 1 << 30
+	if m.autoAcceptOverBudget() {
+		t.Fatal("expected zero ceiling to disable the budget")
+	}
+}
+
+func TestResolveAutoAcceptMaxTokens(t *testing.T) {
+	for value, want := range map[string]int{"": 0, "50000": 50000, " 800 ": 800, "-5": 0, "lots": 0} {
+		t.Setenv("LCM_TUI_AUTO_ACCEPT_MAX_TOKENS", value)
+		if got := resolveAutoAcceptMaxTokens(); got != want {
+			t.Fatalf("LCM_TUI_AUTO_ACCEPT_MAX_TOKENS=%q: got %d, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// rewriteExampleFooter is the closing line every summary template asks for;
+// a summary that has it is preferred as an exemplar over one that was cut
+// off or ignored its instructions.
+const rewriteExampleFooter = "Expand for details about:"
+
+// rewriteExemplars picks --examples style exemplars for rewrite prompts. The
+// pool for each (conversation, depth) is chosen the first time that depth is
+// rewritten and then reused, so rewrites applied later in the run never feed
+// back into the examples. A nil *rewriteExemplars picks none.
+type rewriteExemplars struct {
+	count   int
+	markers []string
+	pools   map[rewriteExemplarKey][]rewriteExemplar
+}
+
+type rewriteExemplarKey struct {
+	conversationID int64
+	depth          int
+}
+
+// rewriteExemplar is one existing summary shown as a style example.
+type rewriteExemplar struct {
+	summaryID string
+	content   string
+}
+
+// newRewriteExemplars returns a picker for count examples, or nil when count
+// is 0.
+func newRewriteExemplars(count int) *rewriteExemplars {
+	if count <= 0 {
+		return nil
+	}
+	return &rewriteExemplars{
+		count:   count,
+		markers: lcm.DefaultCorruptedSummaryMarkers,
+		pools:   make(map[rewriteExemplarKey][]rewriteExemplar),
+	}
+}
+
+// forSummary returns up to count exemplars for item: other summaries of the
+// same conversation and depth, never item itself.
+func (e *rewriteExemplars) forSummary(ctx context.Context, q sqlQueryer, item rewriteSummary) ([]rewriteExemplar, error) {
+	if e == nil {
+		return nil, nil
+	}
+	key := rewriteExemplarKey{conversationID: item.conversationID, depth: item.depth}
+	pool, ok := e.pools[key]
+	if !ok {
+		// One spare covers the case where item itself is in the pool.
+		var err error
+		pool, err = loadRewriteExemplars(ctx, q, item.conversationID, item.depth, e.count+1, e.markers)
+		if err != nil {
+			return nil, err
+		}
+		e.pools[key] = pool
+	}
+
+	picked := make([]rewriteExemplar, 0, e.count)
+	for _, example := range pool {
+		if example.summaryID == item.summaryID {
+			continue
+		}
+		if len(picked) == e.count {
+			break
+		}
+		picked = append(picked, example)
+	}
+	return picked, nil
+}
+
+// loadRewriteExemplars ranks a conversation's summaries at depth and returns
+// the best limit. Corrupted summaries are never candidates. Summaries that
+// end with the "Expand for details about:" footer rank first; within each
+// group the ones closest to the median token count come first, ties broken
+// by summary ID, so the same DB always yields the same examples.
+func loadRewriteExemplars(ctx context.Context, q sqlQueryer, conversationID int64, depth int, limit int, markers []string) ([]rewriteExemplar, error) {
+	markerClause, markerArgs := lcm.CorruptedMarkerClause("content", markers)
+	args := append([]any{conversationID, depth}, markerArgs...)
+	rows, err := q.QueryContext(ctx, `
+		SELECT summary_id, content, token_count
+		FROM summaries
+		WHERE conversation_id = ? AND depth = ? AND trim(content) != '' AND NOT `+markerClause+`
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query example summaries: %w", err)
+	}
+	defer rows.Close()
+
+	type candidate struct {
+		rewriteExemplar
+		tokens   int
+		complete bool
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.summaryID, &c.content, &c.tokens); err != nil {
+			return nil, fmt.Errorf("scan example summary: %w", err)
+		}
+		c.complete = strings.Contains(c.content, rewriteExampleFooter)
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate example summaries: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	tokens := make([]int, len(candidates))
+	for i, c := range candidates {
+		tokens[i] = c.tokens
+	}
+	sort.Ints(tokens)
+	median := tokens[len(tokens)/2]
+	distance := func(c candidate) int {
+		if c.tokens > median {
+			return c.tokens - median
+		}
+		return median - c.tokens
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.complete != b.complete {
+			return a.complete
+		}
+		if da, db := distance(a), distance(b); da != db {
+			return da < db
+		}
+		return a.summaryID < b.summaryID
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	examples := make([]rewriteExemplar, 0, len(candidates))
+	for _, c := range candidates {
+		examples = append(examples, c.rewriteExemplar)
+	}
+	return examples, nil
+}
+
+// describeRewriteExemplars lists the example summary IDs for the run output.
+func describeRewriteExemplars(examples []rewriteExemplar) string {
+	if len(examples) == 0 {
+		return "none at this depth"
+	}
+	ids := make([]string, 0, len(examples))
+	for _, example := range examples {
+		ids = append(ids, example.summaryID)
+	}
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressionRatioBounds flag a rewrite that kept less than 5% of its
+// source's tokens or more than 95% of them.
+var defaultCompressionRatioBounds = compressionRatioBounds{low: 0.05, high: 0.95}
+
+// compressionRatioBounds are --flag-ratios: the accepted range of summary
+// tokens divided by source tokens. Below low the summary probably lost too
+// much; above high the source was barely summarized.
+type compressionRatioBounds struct {
+	low  float64
+	high float64
+}
+
+func (b compressionRatioBounds) String() string {
+	return strconv.FormatFloat(b.low, 'f', -1, 64) + "," + strconv.FormatFloat(b.high, 'f', -1, 64)
+}
+
+// parseCompressionRatioBounds parses "low,high", e.g. "0.05,0.95".
+func parseCompressionRatioBounds(raw string) (compressionRatioBounds, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 2 {
+		return compressionRatioBounds{}, fmt.Errorf("--flag-ratios must be low,high (e.g. 0.05,0.95), got %q", raw)
+	}
+	low, lowErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	high, highErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if lowErr != nil || highErr != nil {
+		return compressionRatioBounds{}, fmt.Errorf("--flag-ratios must be two numbers, got %q", raw)
+	}
+	if low < 0 || high <= low {
+		return compressionRatioBounds{}, fmt.Errorf("--flag-ratios needs 0 <= low < high, got %q", raw)
+	}
+	return compressionRatioBounds{low: low, high: high}, nil
+}
+
+// compressionRatio is summary tokens over source tokens; 0 when the source
+// is empty.
+func compressionRatio(sourceTokens, summaryTokens int) float64 {
+	if sourceTokens <= 0 {
+		return 0
+	}
+	return float64(summaryTokens) / float64(sourceTokens)
+}
+
+// flag names why ratio is an outlier, or "" when it is within bounds.
+func (b compressionRatioBounds) flag(ratio float64) string {
+	switch {
+	case ratio < b.low:
+		return fmt.Sprintf("ratio %.2f < %g: may have lost too much", ratio, b.low)
+	case ratio > b.high:
+		return fmt.Sprintf("ratio %.2f > %g: barely summarized", ratio, b.high)
+	}
+	return ""
+}
+
+// rewriteRatioStats collects each rewrite's compression ratio for the end of
+// run summary.
+type rewriteRatioStats struct {
+	ratios  []float64
+	flagged []string // "sum_x (leaf): ratio ..."
+}
+
+// observe records one rewrite and returns its outlier note, if any.
+func (s *rewriteRatioStats) observe(item rewriteSummary, bounds compressionRatioBounds, sourceTokens, summaryTokens int) string {
+	if sourceTokens <= 0 {
+		return ""
+	}
+	ratio := compressionRatio(sourceTokens, summaryTokens)
+	s.ratios = append(s.ratios, ratio)
+	note := bounds.flag(ratio)
+	if note != "" {
+		s.flagged = append(s.flagged, fmt.Sprintf("%s (d%d): %s", item.summaryID, item.depth, note))
+	}
+	return note
+}
+
+// report renders the run's ratio range and median, then each outlier.
+func (s rewriteRatioStats) report(bounds compressionRatioBounds) string {
+	if len(s.ratios) == 0 {
+		return ""
+	}
+	sorted := append([]float64(nil), s.ratios...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compression ratios (summary/source tokens): min %.2f, median %.2f, max %.2f over %d rewrites; %d outside %s\n",
+		sorted[0], median, sorted[len(sorted)-1], len(sorted), len(s.flagged), bounds)
+	for _, line := range s.flagged {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	return b.String()
+}
@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 func TestBuildLeafRewriteSourceUsesMessagePartsForEmptyMessageContent(t *testing.T) {
@@ -45,28 +50,392 @@ func TestBuildLeafRewriteSourceUsesMessagePartsForEmptyMessageContent(t *testing
 		t.Fatalf("seed tool part: %v", err)
 	}
 
-	source, err := buildLeafRewriteSource(context.Background(), db, "sum_broken", false, time.UTC)
+	source, err := lcm.BuildRewriteSource(context.Background(), db, "sum_broken", true, lcm.RewriteSourceOptions{Location: time.UTC})
 	if err != nil {
 		t.Fatalf("build leaf rewrite source: %v", err)
 	}
 
-	if source.itemCount != 2 {
-		t.Fatalf("source item count = %d, want 2", source.itemCount)
+	if source.ItemCount != 2 {
+		t.Fatalf("source item count = %d, want 2", source.ItemCount)
+	}
+	if source.Label != "messages" {
+		t.Fatalf("source label = %q, want messages", source.Label)
+	}
+	if !strings.Contains(source.Text, "Tool input") {
+		t.Fatalf("expected tool input label in rewrite source, got %q", source.Text)
+	}
+	if !strings.Contains(source.Text, "select name from companies") {
+		t.Fatalf("expected tool input content in rewrite source, got %q", source.Text)
+	}
+	if !strings.Contains(source.Text, "Active company: Acme Robotics") {
+		t.Fatalf("expected tool output content in rewrite source, got %q", source.Text)
+	}
+	if strings.Contains(source.Text, "(empty)") {
+		t.Fatalf("rewrite source should not fall back to empty marker, got %q", source.Text)
+	}
+}
+
+func TestLeafRewriteAndRepairSourcesSkipIgnoredAndSyntheticParts(t *testing.T) {
+	t.Parallel()
+
+	dbPath := setupRewriteSourceTestDB(t)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO messages (message_id, role, content, created_at)
+		VALUES
+			(301, 'user', 'raw content with pasted noise', '2026-05-14 22:00:00'),
+			(302, 'assistant', 'fallback answer', '2026-05-14 22:00:01');
+
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES
+			('sum_curated', 301, 0),
+			('sum_curated', 302, 1);
+
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content, is_ignored, is_synthetic)
+		VALUES
+			('part-301-a', 301, 'session-rewrite', 'text', 0, 'keep this question', 0, 0),
+			('part-301-b', 301, 'session-rewrite', 'text', 1, 'pasted noise', 1, 0),
+			('part-301-c', 301, 'session-rewrite', 'text', 2, 'injected reminder', 0, 1),
+			('part-302-a', 302, 'session-rewrite', 'text', 0, 'synthetic only', NULL, 1);
+	`); err != nil {
+		t.Fatalf("seed messages: %v", err)
+	}
+
+	rewrite, err := lcm.BuildRewriteSource(context.Background(), db, "sum_curated", true, lcm.RewriteSourceOptions{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("build leaf rewrite source: %v", err)
+	}
+	repair, err := lcm.BuildRepairSource(context.Background(), db, lcm.RepairSummary{SummaryID: "sum_curated"}, nil)
+	if err != nil {
+		t.Fatalf("build leaf repair source: %v", err)
+	}
+
+	want := "[user] keep this question\n[assistant] fallback answer"
+	if rewrite.Text != want {
+		t.Fatalf("rewrite source = %q, want %q", rewrite.Text, want)
+	}
+	if repair.Text != rewrite.Text || repair.ItemCount != rewrite.ItemCount {
+		t.Fatalf("repair source %q (%d) differs from rewrite source %q (%d)", repair.Text, repair.ItemCount, rewrite.Text, rewrite.ItemCount)
+	}
+}
+
+func TestLeafSourceIncludesFilePartStandIns(t *testing.T) {
+	t.Parallel()
+
+	dbPath := setupRewriteSourceTestDB(t)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		ALTER TABLE messages ADD COLUMN conversation_id INTEGER;
+		CREATE TABLE large_files (
+			file_id TEXT PRIMARY KEY,
+			conversation_id INTEGER NOT NULL,
+			file_name TEXT,
+			mime_type TEXT,
+			storage_uri TEXT NOT NULL,
+			exploration_summary TEXT,
+			created_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+
+		INSERT INTO messages (message_id, conversation_id, role, content, created_at)
+		VALUES
+			(401, 7, 'user', '', '2026-05-14 22:00:00'),
+			(402, 7, 'user', 'MEDIA:/tmp/diagram.png', '2026-05-14 22:00:01');
+
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES
+			('sum_files', 401, 0),
+			('sum_files', 402, 1);
+
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content, file_name, file_mime, file_url)
+		VALUES
+			('part-401-a', 401, 'session-rewrite', 'text', 0, 'see the attached report', NULL, NULL, NULL),
+			('part-401-b', 401, 'session-rewrite', 'file', 1, NULL, 'q3-report.pdf', 'application/pdf', 'file:///lcm/files/file_0123456789abcdef.pdf'),
+			('part-402-a', 402, 'session-rewrite', 'file', 0, NULL, NULL, 'image/png', 'https://cdn.example.com/u/diagram.png');
+
+		INSERT INTO large_files (file_id, conversation_id, file_name, mime_type, storage_uri, exploration_summary)
+		VALUES ('file_0123456789abcdef', 7, 'q3-report.pdf', 'application/pdf', '/lcm/files/file_0123456789abcdef.pdf', 'Revenue up 12%; churn flat.');
+	`); err != nil {
+		t.Fatalf("seed messages: %v", err)
 	}
-	if source.label != "messages" {
-		t.Fatalf("source label = %q, want messages", source.label)
+
+	rewrite, err := lcm.BuildRewriteSource(context.Background(), db, "sum_files", true, lcm.RewriteSourceOptions{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("build leaf rewrite source: %v", err)
+	}
+	want := strings.Join([]string{
+		"[user] see the attached report",
+		"[file: q3-report.pdf (application/pdf)]",
+		"File summary: Revenue up 12%; churn flat.",
+		"[user] [image: diagram.png (image/png)]",
+	}, "\n")
+	if rewrite.Text != want {
+		t.Fatalf("rewrite source = %q, want %q", rewrite.Text, want)
+	}
+	repair, err := lcm.BuildRepairSource(context.Background(), db, lcm.RepairSummary{SummaryID: "sum_files"}, nil)
+	if err != nil {
+		t.Fatalf("build leaf repair source: %v", err)
 	}
-	if !strings.Contains(source.text, "Tool input") {
-		t.Fatalf("expected tool input label in rewrite source, got %q", source.text)
+	if repair.Text != rewrite.Text {
+		t.Fatalf("repair source %q differs from rewrite source %q", repair.Text, rewrite.Text)
 	}
-	if !strings.Contains(source.text, "select name from companies") {
-		t.Fatalf("expected tool input content in rewrite source, got %q", source.text)
+}
+
+func TestLeafSourceRendersStructuredParts(t *testing.T) {
+	t.Parallel()
+
+	dbPath := setupRewriteSourceTestDB(t)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
 	}
-	if !strings.Contains(source.text, "Active company: Acme Robotics") {
-		t.Fatalf("expected tool output content in rewrite source, got %q", source.text)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO messages (message_id, role, content, created_at)
+		VALUES (501, 'assistant', '', '2026-05-14 22:00:00');
+
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_parts', 501, 0);
+
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, text_content, patch_hash, patch_files)
+		VALUES ('part-501-a', 501, 'session-rewrite', 'patch', 0, NULL, 'abc123', '["src/a.go","src/b.go"]');
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, subtask_agent, subtask_desc, subtask_prompt)
+		VALUES ('part-501-b', 501, 'session-rewrite', 'subtask', 1, 'explore', 'Find the loader', 'Where is config read?');
+		INSERT INTO message_parts (part_id, message_id, session_id, part_type, ordinal, step_reason, step_cost, step_tokens_in, step_tokens_out)
+		VALUES
+			('part-501-c', 501, 'session-rewrite', 'step_finish', 2, 'stop', 0.5, 10, 20),
+			('part-501-d', 501, 'session-rewrite', 'step_start', 3, NULL, NULL, NULL, NULL);
+	`); err != nil {
+		t.Fatalf("seed messages: %v", err)
+	}
+
+	rewrite, err := lcm.BuildRewriteSource(context.Background(), db, "sum_parts", true, lcm.RewriteSourceOptions{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("build leaf rewrite source: %v", err)
+	}
+	want := strings.Join([]string{
+		"[assistant] [patch abc123] src/a.go, src/b.go",
+		"[subtask: explore] Find the loader",
+		"Prompt: Where is config read?",
+		"[step finish: stop, 10 in / 20 out tokens, $0.5000]",
+	}, "\n")
+	if rewrite.Text != want {
+		t.Fatalf("rewrite source = %q, want %q", rewrite.Text, want)
+	}
+}
+
+func TestBuildLeafRewriteSourceMarksFreshTail(t *testing.T) {
+	t.Parallel()
+
+	dbPath := setupRewriteSourceTestDB(t)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO messages (message_id, role, content, created_at)
+		VALUES
+			(201, 'user', 'first question', '2026-05-14 22:00:00'),
+			(202, 'assistant', 'first answer', '2026-05-14 22:00:01'),
+			(203, 'user', 'latest question', '2026-05-14 22:00:02');
+
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES
+			('sum_fresh', 201, 0),
+			('sum_fresh', 202, 1),
+			('sum_fresh', 203, 2);
+	`); err != nil {
+		t.Fatalf("seed messages: %v", err)
+	}
+
+	source, err := lcm.BuildRewriteSource(context.Background(), db, "sum_fresh", true, lcm.RewriteSourceOptions{Location: time.UTC, FreshTail: 2})
+	if err != nil {
+		t.Fatalf("build leaf rewrite source: %v", err)
+	}
+	if source.FreshCount != 2 {
+		t.Fatalf("fresh count = %d, want 2", source.FreshCount)
+	}
+	want := strings.Join([]string{
+		"[user] first question",
+		"[most recent] [assistant] first answer",
+		"[most recent] [user] latest question",
+	}, "\n")
+	if source.Text != want {
+		t.Fatalf("source text = %q, want %q", source.Text, want)
+	}
+
+	prompt, err := lcm.RenderPrompt(0, lcm.PromptVars{TargetTokens: 400, SourceText: source.Text, FreshTailCount: source.FreshCount}, "")
+	if err != nil {
+		t.Fatalf("render prompt: %v", err)
+	}
+	if !strings.Contains(prompt, "The final 2 messages are marked [most recent]") {
+		t.Fatalf("expected fresh-tail guidance in prompt, got %q", prompt)
+	}
+
+	source, err = lcm.BuildRewriteSource(context.Background(), db, "sum_fresh", true, lcm.RewriteSourceOptions{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("build leaf rewrite source: %v", err)
+	}
+	if strings.Contains(source.Text, "[most recent]") {
+		t.Fatalf("fresh-tail marker should be off by default, got %q", source.Text)
+	}
+}
+
+func TestLoadRewriteTargetsFiltersByTokenRange(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lcm.db"))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		CREATE TABLE summaries (
+			summary_id TEXT PRIMARY KEY,
+			conversation_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			depth INTEGER,
+			content TEXT,
+			token_count INTEGER,
+			created_at TEXT
+		);
+		CREATE TABLE summary_parents (
+			summary_id TEXT NOT NULL,
+			parent_summary_id TEXT NOT NULL,
+			ordinal INTEGER NOT NULL
+		);
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_small', 7, 'leaf', 0, 'small', 400, '2026-05-14 22:00:00'),
+			('sum_mid', 7, 'leaf', 0, 'mid', 1800, '2026-05-14 22:01:00'),
+			('sum_big', 7, 'condensed', 1, 'big', 3200, '2026-05-14 22:02:00'),
+			('sum_other', 8, 'leaf', 0, 'other', 5000, '2026-05-14 22:03:00');
+		CREATE TABLE context_items (
+			conversation_id INTEGER NOT NULL,
+			ordinal INTEGER NOT NULL,
+			item_type TEXT NOT NULL,
+			summary_id TEXT
+		);
+		INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id)
+		VALUES
+			(7, 0, 'summary', 'sum_big'),
+			(7, 1, 'summary', 'sum_small'),
+			(8, 0, 'summary', 'sum_mid');
+	`); err != nil {
+		t.Fatalf("seed summaries: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts rewriteOptions
+		want []string
+	}{
+		{name: "min only", opts: rewriteOptions{all: true, tokens: lcm.TokenRange{Min: 1800}}, want: []string{"sum_mid", "sum_big"}},
+		{name: "max only", opts: rewriteOptions{all: true, tokens: lcm.TokenRange{Max: 1800}}, want: []string{"sum_small", "sum_mid"}},
+		{name: "range with depth", opts: rewriteOptions{depthSet: true, depth: 0, tokens: lcm.TokenRange{Min: 1000, Max: 4000}}, want: []string{"sum_mid"}},
+		{name: "summary filtered out", opts: rewriteOptions{summaryID: "sum_small", tokens: lcm.TokenRange{Min: 1000}}, want: []string{}},
+		{name: "context only", opts: rewriteOptions{all: true, contextOnly: true}, want: []string{"sum_small", "sum_big"}},
+		{name: "context only with min", opts: rewriteOptions{all: true, contextOnly: true, tokens: lcm.TokenRange{Min: 1000}}, want: []string{"sum_big"}},
+		{name: "summary not in context", opts: rewriteOptions{summaryID: "sum_mid", contextOnly: true}, want: []string{}},
+	}
+	for _, tc := range tests {
+		targets, err := loadRewriteTargets(context.Background(), db, 7, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: load rewrite targets: %v", tc.name, err)
+		}
+		got := make([]string, 0, len(targets))
+		for _, target := range targets {
+			got = append(got, target.summaryID)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseRewriteArgsRejectsInvertedTokenRange(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := parseRewriteArgs([]string{"7", "--all", "--min-tokens", "3000", "--max-tokens", "1000"})
+	if err == nil || !strings.Contains(err.Error(), "--min-tokens must be <= --max-tokens") {
+		t.Fatalf("expected inverted range error, got %v", err)
+	}
+
+	opts, conversationID, err := parseRewriteArgs([]string{"7", "--all", "--min-tokens=2500"})
+	if err != nil {
+		t.Fatalf("parse rewrite args: %v", err)
+	}
+	if conversationID != 7 || opts.tokens.Min != 2500 || opts.tokens.Max != 0 {
+		t.Fatalf("got conversation %d min %d max %d, want 7/2500/0", conversationID, opts.tokens.Min, opts.tokens.Max)
+	}
+}
+
+func TestParseRewriteArgsGenerationOverrides(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"7", "--temperature", "0.3", "--all", "--max-output-tokens=5000", "--max-tokens", "4000"})
+	if err != nil {
+		t.Fatalf("parse rewrite args: %v", err)
 	}
-	if strings.Contains(source.text, "(empty)") {
-		t.Fatalf("rewrite source should not fall back to empty marker, got %q", source.text)
+	if opts.generation.temperature == nil || *opts.generation.temperature != 0.3 || opts.generation.maxOutputTokens != 5000 || opts.tokens.Max != 4000 {
+		t.Fatalf("unexpected generation settings %+v (max-tokens %d)", opts.generation, opts.tokens.Max)
+	}
+}
+
+func TestParseRewriteArgsTimeouts(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"7", "--all", "--timeout-per-call", "90s", "--overall-timeout=45m"})
+	if err != nil {
+		t.Fatalf("parse rewrite args: %v", err)
+	}
+	if opts.httpTimeout != 90*time.Second || opts.overallTimeout != 45*time.Minute {
+		t.Fatalf("unexpected timeouts: per call %s, overall %s", opts.httpTimeout, opts.overallTimeout)
+	}
+	if _, _, err := parseRewriteArgs([]string{"7", "--all", "--overall-timeout", "-1m"}); err == nil {
+		t.Fatal("expected negative --overall-timeout to be rejected")
+	}
+}
+
+func TestRewriteCompressionRatioFlags(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"7", "--all", "--flag-ratios=0.1,0.8"})
+	if err != nil {
+		t.Fatalf("parse rewrite args: %v", err)
+	}
+	if opts.ratioBounds != (compressionRatioBounds{low: 0.1, high: 0.8}) {
+		t.Fatalf("unexpected bounds %+v", opts.ratioBounds)
+	}
+	for _, bad := range []string{"0.5", "0.9,0.1", "x,1"} {
+		if _, _, err := parseRewriteArgs([]string{"7", "--all", "--flag-ratios", bad}); err == nil {
+			t.Fatalf("expected --flag-ratios %q to be rejected", bad)
+		}
+	}
+
+	stats := rewriteRatioStats{}
+	bounds := defaultCompressionRatioBounds
+	if note := stats.observe(rewriteSummary{summaryID: "sum_ok"}, bounds, 1000, 200); note != "" {
+		t.Fatalf("0.2 should be within bounds, got %q", note)
+	}
+	if note := stats.observe(rewriteSummary{summaryID: "sum_lazy"}, bounds, 1000, 970); !strings.Contains(note, "barely summarized") {
+		t.Fatalf("0.97 should be flagged high, got %q", note)
+	}
+	if note := stats.observe(rewriteSummary{summaryID: "sum_lossy", depth: 1}, bounds, 1000, 20); !strings.Contains(note, "lost too much") {
+		t.Fatalf("0.02 should be flagged low, got %q", note)
+	}
+	report := stats.report(bounds)
+	for _, want := range []string{"min 0.02, median 0.20, max 0.97 over 3 rewrites; 2 outside 0.05,0.95", "sum_lossy (d1): ratio 0.02"} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("ratio report missing %q:\n%s", want, report)
+		}
 	}
 }
 
@@ -99,9 +468,24 @@ func setupRewriteSourceTestDB(t *testing.T) string {
 			part_type TEXT NOT NULL,
 			ordinal INTEGER NOT NULL,
 			text_content TEXT,
+			is_ignored INTEGER,
+			is_synthetic INTEGER,
 			tool_name TEXT,
 			tool_input TEXT,
-			tool_output TEXT
+			tool_output TEXT,
+			file_mime TEXT,
+			file_name TEXT,
+			file_url TEXT,
+			patch_hash TEXT,
+			patch_files TEXT,
+			subtask_prompt TEXT,
+			subtask_desc TEXT,
+			subtask_agent TEXT,
+			step_reason TEXT,
+			step_cost REAL,
+			step_tokens_in INTEGER,
+			step_tokens_out INTEGER,
+			snapshot_hash TEXT
 		);
 	`); err != nil {
 		t.Fatalf("create schema: %v", err)
@@ -109,3 +493,421 @@ func setupRewriteSourceTestDB(t *testing.T) string {
 
 	return dbPath
 }
+
+func TestRewriteGuardFlagsEmptyRefusalAndUndersizedOutput(t *testing.T) {
+	t.Parallel()
+
+	guard := defaultRewriteGuard()
+	good := strings.Repeat("durable detail ", 60)
+	if err := guard.check(good, 1200); err != nil {
+		t.Fatalf("expected full-size output to pass, got %v", err)
+	}
+
+	cases := map[string]string{
+		"empty":     "  \n ",
+		"refusal":   "I’m sorry, but I can't summarize this conversation.",
+		"quoted":    `"I cannot help with that."`,
+		"undersize": "Files: none",
+	}
+	for name, content := range cases {
+		if err := guard.check(content, 1200); !errors.Is(err, errSuspectRewrite) {
+			t.Fatalf("%s: expected suspect rewrite error, got %v", name, err)
+		}
+	}
+
+	if err := (rewriteGuard{}).check("Files: none", 1200); err != nil {
+		t.Fatalf("expected zero fraction to disable the size check, got %v", err)
+	}
+}
+
+func TestApplySummaryRewriteRefusesSuspectOutputUnlessForced(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lcm.db"))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+		CREATE TABLE summaries (summary_id TEXT PRIMARY KEY, conversation_id INTEGER, content TEXT, token_count INTEGER);
+		INSERT INTO summaries VALUES ('sum_a', 1, 'good summary', 900);
+	`); err != nil {
+		t.Fatalf("seed summaries: %v", err)
+	}
+
+	ctx := context.Background()
+	err = applySummaryRewrite(ctx, db, "sum_a", "I can't do that.", 5, 1200, defaultRewriteGuard())
+	if !errors.Is(err, errSuspectRewrite) {
+		t.Fatalf("expected refusal to be rejected, got %v", err)
+	}
+	var content string
+	if err := db.QueryRow(`SELECT content FROM summaries WHERE summary_id = 'sum_a'`).Scan(&content); err != nil {
+		t.Fatalf("read summary: %v", err)
+	}
+	if content != "good summary" {
+		t.Fatalf("expected original content to survive, got %q", content)
+	}
+
+	forced := defaultRewriteGuard()
+	forced.force = true
+	if err := applySummaryRewrite(ctx, db, "sum_a", "short", 2, 1200, forced); err != nil {
+		t.Fatalf("expected forced apply to succeed, got %v", err)
+	}
+	if err := db.QueryRow(`SELECT content FROM summaries WHERE summary_id = 'sum_a'`).Scan(&content); err != nil {
+		t.Fatalf("read summary: %v", err)
+	}
+	if content != "short" {
+		t.Fatalf("expected forced content, got %q", content)
+	}
+}
+
+func TestResumeRewriteTargetsAndProgressReport(t *testing.T) {
+	targets := []rewriteSummary{{summaryID: "sum_a"}, {summaryID: "sum_b"}, {summaryID: "sum_c"}}
+
+	resumed, err := resumeRewriteTargets(targets, "sum_b")
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if len(resumed) != 2 || resumed[0].summaryID != "sum_b" {
+		t.Fatalf("unexpected resumed targets %+v", resumed)
+	}
+	if _, err := resumeRewriteTargets(targets, "sum_missing"); err == nil {
+		t.Fatal("expected unknown --continue-from to fail")
+	}
+
+	report := rewriteProgress{applied: []string{"sum_a"}}.report(targets[1:])
+	if !strings.Contains(report, "Applied (1): sum_a") || !strings.Contains(report, "Remaining (2): sum_b, sum_c") || strings.Contains(report, "Skipped") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+
+	opts, _, err := parseRewriteArgs([]string{"44", "--all", "--apply", "--continue-from", "sum_b"})
+	if err != nil || opts.continueFrom != "sum_b" {
+		t.Fatalf("parse --continue-from = %+v (%v)", opts.continueFrom, err)
+	}
+}
+
+func TestBuildDeepCondensedRewriteSourceWalksToLeafMessages(t *testing.T) {
+	db := newBackfillTestDB(t)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'session-deep')`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 1, 'user', 'first raw message', 4, '2026-05-14 10:00:00'),
+			(2, 1, 2, 'assistant', 'second raw message', 4, '2026-05-14 10:05:00'),
+			(3, 1, 3, 'user', 'third raw message', 4, '2026-05-14 11:00:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('leaf_a', 1, 'leaf', 0, 'lossy a', 2, '2026-05-14 10:06:00'),
+			('leaf_b', 1, 'leaf', 0, 'lossy b', 2, '2026-05-14 11:01:00'),
+			('cond_1', 1, 'condensed', 1, 'lossy d1', 2, '2026-05-14 11:02:00'),
+			('cond_2', 1, 'condensed', 2, 'lossy d2', 2, '2026-05-14 11:03:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('leaf_a', 1, 0), ('leaf_a', 2, 1), ('leaf_b', 3, 0)
+	`)
+	// leaf_b is reachable through cond_1 and directly; it appears once.
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('cond_1', 'leaf_a', 0), ('cond_1', 'leaf_b', 1), ('cond_2', 'cond_1', 0), ('cond_2', 'leaf_b', 1)
+	`)
+
+	source, err := lcm.BuildRewriteSource(context.Background(), db, "cond_2", false, lcm.RewriteSourceOptions{Location: time.UTC, Deep: true})
+	if err != nil {
+		t.Fatalf("build deep source: %v", err)
+	}
+	if source.ItemCount != 3 || source.Label != "messages from 2 leaves" {
+		t.Fatalf("unexpected source counts %d %q", source.ItemCount, source.Label)
+	}
+	if strings.Contains(source.Text, "lossy") {
+		t.Fatalf("deep source should not include child summary text:\n%s", source.Text)
+	}
+	first := strings.Index(source.Text, "first raw message")
+	third := strings.Index(source.Text, "third raw message")
+	if first < 0 || third < first || strings.Count(source.Text, "third raw message") != 1 {
+		t.Fatalf("expected leaf messages in source order once each:\n%s", source.Text)
+	}
+	if !strings.HasPrefix(source.Text, "[") || source.TimeRange == "" {
+		t.Fatalf("expected time range headers, got range %q:\n%s", source.TimeRange, source.Text)
+	}
+}
+
+func TestParseRewriteArgsDeepDefaultsMaxInputTokens(t *testing.T) {
+	opts, _, err := parseRewriteArgs([]string{"44", "--summary", "sum_a", "--deep"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !opts.deep || opts.maxInputTokens != defaultDeepRewriteMaxInputTokens {
+		t.Fatalf("unexpected deep options %+v", opts)
+	}
+	opts, _, err = parseRewriteArgs([]string{"44", "--summary", "sum_a", "--deep", "--max-input-tokens", "0"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.maxInputTokens != 0 {
+		t.Fatalf("explicit --max-input-tokens 0 should disable the limit, got %d", opts.maxInputTokens)
+	}
+}
+
+func TestRewriteModelComparisonRunsEveryModelWithoutWriting(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	imported, err := lcm.ApplyBackfillImport(ctx, db, lcm.BackfillInput{
+		Agent: "agent-compare", SessionID: "session-compare", Messages: makeBackfillMessages(4), SessionPath: "/tmp/compare.jsonl",
+	})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES ('sum_compare', ?, 'leaf', 0, 'the stored leaf summary', 6, datetime('now'), '[]');
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		SELECT 'sum_compare', message_id, seq FROM messages WHERE conversation_id = ?;
+	`, imported.ConversationID, imported.ConversationID); err != nil {
+		t.Fatalf("seed summary: %v", err)
+	}
+
+	opts, conversationID, err := parseRewriteArgs([]string{
+		strconv.FormatInt(imported.ConversationID, 10), "--summary", "sum_compare", "--compare-models", "model-a, openai/gpt-x,model-a",
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !reflect.DeepEqual(opts.compareModels, []string{"model-a", "openai/gpt-x"}) {
+		t.Fatalf("unexpected compare models %q", opts.compareModels)
+	}
+	opts.provider = stubSummaryProvider
+	targets, err := loadRewriteTargets(ctx, db, conversationID, opts)
+	if err != nil || len(targets) != 1 {
+		t.Fatalf("load target: %+v (%v)", targets, err)
+	}
+
+	report := newRunReport(filepath.Join(t.TempDir(), "report.json"), "rewrite", nil, false, conversationID)
+	if err := runRewriteModelComparison(ctx, db, appDataPaths{}, targets[0], opts, report); err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if len(report.Summaries) != 2 || len(report.Calls) != 2 {
+		t.Fatalf("expected one report entry and call per model, got %+v / %+v", report.Summaries, report.Calls)
+	}
+	for i, model := range []string{"model-a", "gpt-x"} {
+		entry := report.Summaries[i]
+		if entry.Action != "compared" || entry.Model != model || entry.NewTokens == 0 {
+			t.Fatalf("unexpected entry %d: %+v", i, entry)
+		}
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_compare' AND content = 'the stored leaf summary'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'rewrite'`, 0)
+}
+
+func TestParseRewriteArgsCompareModelsRequiresOneSummaryDryRun(t *testing.T) {
+	for _, args := range [][]string{
+		{"7", "--all", "--compare-models", "a,b"},
+		{"7", "--summary", "sum_a", "--compare-models", "a,b", "--apply"},
+		{"7", "--summary", "sum_a", "--compare-models", "a"},
+		{"7", "--summary", "sum_a", "--compare-models", "a,b", "--model-fallback", "c"},
+	} {
+		if _, _, err := parseRewriteArgs(args); err == nil {
+			t.Fatalf("expected %q to be rejected", args)
+		}
+	}
+	if provider, model := resolveCompareModel("openai/gpt-5.3-codex", "anthropic"); provider != "openai" || model != "gpt-5.3-codex" {
+		t.Fatalf("provider/model entry resolved to %s %s", provider, model)
+	}
+	if provider, model := resolveCompareModel("claude-haiku-4-5", "anthropic"); provider != "anthropic" || model != "claude-haiku-4-5" {
+		t.Fatalf("bare entry resolved to %s %s", provider, model)
+	}
+}
+
+func TestRewriteExamplesPickSameDepthExemplarsDeterministically(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-examples', 'Examples')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES
+			('sum_target', 1, 'leaf', 0, 'target leaf', 100, '2026-03-22T10:00:00Z', '[]'),
+			('sum_median', 1, 'leaf', 0, 'median leaf
+Expand for details about: commands', 100, '2026-03-22T10:01:00Z', '[]'),
+			('sum_far', 1, 'leaf', 0, 'long leaf
+Expand for details about: logs', 400, '2026-03-22T10:02:00Z', '[]'),
+			('sum_cutoff', 1, 'leaf', 0, 'leaf that never reached its footer', 100, '2026-03-22T10:03:00Z', '[]'),
+			('sum_corrupt', 1, 'leaf', 0, '`+lcm.CorruptedSummaryMarker+` Expand for details about: x', 100, '2026-03-22T10:04:00Z', '[]'),
+			('sum_parent', 1, 'condensed', 1, 'condensed
+Expand for details about: plans', 100, '2026-03-22T10:05:00Z', '[]')
+	`)
+
+	opts, _, err := parseRewriteArgs([]string{"1", "--summary", "sum_target", "--examples", "2"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	item := rewriteSummary{summaryID: "sum_target", conversationID: 1, kind: "leaf", depth: 0, content: "target leaf", tokenCount: 100}
+	examples, err := opts.examples.forSummary(ctx, db, item)
+	if err != nil {
+		t.Fatalf("select examples: %v", err)
+	}
+	if got := describeRewriteExemplars(examples); got != "sum_median, sum_far" {
+		t.Fatalf("examples = %s, want complete summaries nearest the median first", got)
+	}
+
+	// The pool is cached per depth, so a rewrite applied mid-run does not
+	// change the examples later summaries see.
+	mustExec(t, db, `UPDATE summaries SET content = 'rewritten' WHERE summary_id = 'sum_median'`)
+	source := lcm.SummarySource{Text: "user: ship it", ItemCount: 1, EstimatedTokens: 4, Label: "messages"}
+	prompt, _, err := renderRewritePrompt(ctx, db, item, source, opts)
+	if err != nil {
+		t.Fatalf("render prompt: %v", err)
+	}
+	if !strings.Contains(prompt, "<example_summaries>") || !strings.Contains(prompt, "median leaf") || strings.Contains(prompt, "condensed") {
+		t.Fatalf("prompt does not carry the leaf examples:\n%s", prompt)
+	}
+	if system, _, ok := splitSummaryPrompt(prompt); !ok || strings.Contains(system, "median leaf") {
+		t.Fatalf("examples should be sent with the conversation content, not the instructions:\n%s", system)
+	}
+
+	opts, _, err = parseRewriteArgs([]string{"1", "--summary", "sum_target"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	prompt, _, err = renderRewritePrompt(ctx, db, item, source, opts)
+	if err != nil {
+		t.Fatalf("render prompt: %v", err)
+	}
+	if strings.Contains(prompt, "example_summaries") {
+		t.Fatalf("examples should be off by default:\n%s", prompt)
+	}
+	if _, _, err := parseRewriteArgs([]string{"1", "--summary", "sum_target", "--examples", "-1"}); err == nil {
+		t.Fatal("expected negative --examples to be rejected")
+	}
+}
+
+func TestRenderRewritePromptRedactsPreviousContext(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'redact-previous')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, file_ids)
+		VALUES
+			('sum_prev', 1, 'leaf', 0, 'set ANTHROPIC_API_KEY=sk-ant-REDACTED', 20, '2026-03-22T10:00:00Z', '[]'),
+			('sum_target', 1, 'leaf', 0, 'target leaf', 20, '2026-03-22T10:01:00Z', '[]')
+	`)
+	opts, _, err := parseRewriteArgs([]string{"1", "--summary", "sum_target", "--redact"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	item := rewriteSummary{summaryID: "sum_target", conversationID: 1, kind: "leaf", depth: 0, content: "target leaf", tokenCount: 20, createdAt: "2026-03-22T10:01:00Z"}
+	source := lcm.SummarySource{Text: "user: ship it", ItemCount: 1, EstimatedTokens: 4, Label: "messages"}
+	prompt, _, err := renderRewritePrompt(ctx, db, item, source, opts)
+	if err != nil {
+		t.Fatalf("render prompt: %v", err)
+	}
+	if strings.Contains(prompt, "sk-ant-api03") || !strings.Contains(prompt, "[REDACTED:anthropic-key]") {
+		t.Fatalf("previous context should be redacted:\n%s", prompt)
+	}
+}
+
+func TestSameSecondSummariesFollowInsertionOrder(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'same-second')`)
+	// One compaction pass: three leaves in the same second, inserted in an
+	// order that sorts differently by summary_id.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES
+			('sum_c', 1, 'leaf', 0, 'first', 10, '2026-03-22T10:00:00Z'),
+			('sum_a', 1, 'leaf', 0, 'second', 10, '2026-03-22T10:00:00Z'),
+			('sum_b', 1, 'leaf', 0, 'third', 10, '2026-03-22T10:00:00Z')
+	`)
+
+	targets, err := loadRewriteTargets(ctx, db, 1, rewriteOptions{all: true})
+	if err != nil {
+		t.Fatalf("load rewrite targets: %v", err)
+	}
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.summaryID)
+	}
+	if want := []string{"sum_c", "sum_a", "sum_b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rewrite --all order = %v, want %v", got, want)
+	}
+
+	nodes, err := loadSummaryNodes(db, 1)
+	if err != nil {
+		t.Fatalf("load summary nodes: %v", err)
+	}
+	ids := []string{"sum_a", "sum_b", "sum_c"}
+	sortSummaryIDs(ids, nodes)
+	if want := []string{"sum_c", "sum_a", "sum_b"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("sortSummaryIDs = %v, want %v", ids, want)
+	}
+
+	previous, err := lcm.PreviousSummaries(ctx, db, "sum_b", 1, 0, "leaf", "2026-03-22T10:00:00Z", lcm.PreviousContextOptions{Count: 3, Depth: -1})
+	if err != nil {
+		t.Fatalf("previous summaries: %v", err)
+	}
+	if len(previous) != 2 || previous[0].SummaryID != "sum_c" || previous[1].SummaryID != "sum_a" {
+		t.Fatalf("previous summaries of sum_b = %+v, want sum_c then sum_a", previous)
+	}
+}
+
+func TestSameSecondSummariesFollowCreatedSeqOverRowid(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `ALTER TABLE summaries ADD COLUMN created_seq INTEGER`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'same-second-seq')`)
+	// rowid order is c, a, b, but created_seq says b, c, a, as it would
+	// after a VACUUM renumbered the rows.
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, created_seq)
+		VALUES
+			('sum_c', 1, 'leaf', 0, 'second', 10, '2026-03-22T10:00:00Z', 2),
+			('sum_a', 1, 'leaf', 0, 'third', 10, '2026-03-22T10:00:00Z', 3),
+			('sum_b', 1, 'leaf', 0, 'first', 10, '2026-03-22T10:00:00Z', 1)
+	`)
+	want := []string{"sum_b", "sum_c", "sum_a"}
+
+	targets, err := loadRewriteTargets(ctx, db, 1, rewriteOptions{all: true})
+	if err != nil {
+		t.Fatalf("load rewrite targets: %v", err)
+	}
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.summaryID)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rewrite --all order = %v, want %v", got, want)
+	}
+
+	nodes, err := loadSummaryNodes(db, 1)
+	if err != nil {
+		t.Fatalf("load summary nodes: %v", err)
+	}
+	ids := []string{"sum_a", "sum_b", "sum_c"}
+	sortSummaryIDs(ids, nodes)
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("sortSummaryIDs = %v, want %v", ids, want)
+	}
+
+	previous, err := lcm.PreviousSummaries(ctx, db, "sum_a", 1, 0, "leaf", "2026-03-22T10:00:00Z", lcm.PreviousContextOptions{Count: 3, Depth: -1})
+	if err != nil {
+		t.Fatalf("previous summaries: %v", err)
+	}
+	if len(previous) != 2 || previous[0].SummaryID != "sum_b" || previous[1].SummaryID != "sum_c" {
+		t.Fatalf("previous summaries of sum_a = %+v, want sum_b then sum_c", previous)
+	}
+
+	// A new summary takes the next created_seq.
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		t.Fatalf("schema capabilities: %v", err)
+	}
+	seqColumn, seqValue := caps.NextSummarySeq()
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at`+seqColumn+`)
+		VALUES ('sum_d', 1, 'leaf', 0, 'fourth', 10, '2026-03-22T10:00:00Z'`+seqValue+`)
+	`)
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_d' AND created_seq = 4`, 1)
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// runReport is the consolidated record --report-file writes after a repair,
+// rewrite, backfill, or transplant run. It is written even when the run
+// fails, with the error and the summary it stopped at.
+type runReport struct {
+	Command         string   `json:"command"`
+	Args            []string `json:"args"`
+	Mode            string   `json:"mode"`
+	ConversationIDs []int64  `json:"conversation_ids"`
+	StartedAt       string   `json:"started_at"`
+	FinishedAt      string   `json:"finished_at"`
+	DurationMS      int64    `json:"duration_ms"`
+	Outcome         string   `json:"outcome"`
+	Error           string   `json:"error,omitempty"`
+	StoppedAt       string   `json:"stopped_at,omitempty"`
+	// Unfinished lists the conversations an --overall-timeout batch left
+	// for the next run.
+	Unfinished []int64            `json:"unfinished_conversations,omitempty"`
+	BackupPath string             `json:"backup_path,omitempty"`
+	Models     map[string]int     `json:"models,omitempty"`
+	Totals     runReportTotals    `json:"totals"`
+	Summaries  []runReportSummary `json:"summaries"`
+	Calls      []runReportCall    `json:"calls,omitempty"`
+
+	path    string
+	started time.Time
+	// mu guards Calls, which parallel repairs record from several
+	// goroutines. Their summaries are buffered per conversation and added
+	// with absorb from the collecting goroutine.
+	mu sync.Mutex
+}
+
+// runReportSummary is one summary the run touched. Token counts are the
+// stored token_count before and after; a copy or new summary has no "before".
+type runReportSummary struct {
+	ConversationID int64  `json:"conversation_id"`
+	SummaryID      string `json:"summary_id"`
+	Kind           string `json:"kind,omitempty"`
+	Depth          int    `json:"depth"`
+	Action         string `json:"action"`
+	OldTokens      int    `json:"old_tokens"`
+	NewTokens      int    `json:"new_tokens"`
+	NewChars       int    `json:"new_chars,omitempty"`
+	Model          string `json:"model,omitempty"`
+	DurationMS     int64  `json:"duration_ms,omitempty"`
+	Note           string `json:"note,omitempty"`
+
+	// SourceTokens and CompressionRatio (new/source) are set by rewrite.
+	SourceTokens     int     `json:"source_tokens,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+}
+
+// runReportCall is one summarize call. Prompt and output sizes use the same
+// chars/4 estimate as token_count, so they approximate billed tokens; multiply
+// by the provider's per-token price for a cost estimate.
+type runReportCall struct {
+	Model        string `json:"model"`
+	PromptTokens int    `json:"prompt_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	DurationMS   int64  `json:"duration_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+type runReportTotals struct {
+	Summaries    int `json:"summaries"`
+	OldTokens    int `json:"old_tokens"`
+	NewTokens    int `json:"new_tokens"`
+	Calls        int `json:"calls"`
+	FailedCalls  int `json:"failed_calls"`
+	PromptTokens int `json:"prompt_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// newRunReport starts a report for command, or returns nil when path is
+// empty. Every method is a no-op on a nil report, so callers record
+// unconditionally.
+func newRunReport(path, command string, args []string, apply bool, conversationIDs ...int64) *runReport {
+	if strings.TrimSpace(path) == "" {
+		return nil
+	}
+	mode := "dry-run"
+	if apply {
+		mode = "apply"
+	}
+	now := time.Now()
+	return &runReport{
+		Command:         command,
+		Args:            append([]string(nil), args...),
+		Mode:            mode,
+		ConversationIDs: append([]int64(nil), conversationIDs...),
+		StartedAt:       now.UTC().Format(time.RFC3339),
+		Summaries:       []runReportSummary{},
+		path:            path,
+		started:         now,
+	}
+}
+
+// addConversation records a conversation ID resolved after the run started.
+func (r *runReport) addConversation(conversationID int64) {
+	if r == nil || conversationID <= 0 {
+		return
+	}
+	for _, id := range r.ConversationIDs {
+		if id == conversationID {
+			return
+		}
+	}
+	r.ConversationIDs = append(r.ConversationIDs, conversationID)
+}
+
+func (r *runReport) addSummary(entry runReportSummary) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Summaries = append(r.Summaries, entry)
+}
+
+// absorb adds the summaries recorded in a per-conversation buffer. Buffers
+// are absorbed in input order, so the first stop point recorded is kept.
+func (r *runReport) absorb(part *runReport) {
+	if r == nil || part == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Summaries = append(r.Summaries, part.Summaries...)
+	if r.StoppedAt == "" {
+		r.StoppedAt = part.StoppedAt
+	}
+}
+
+// stopAt names the summary a failed run was working on.
+func (r *runReport) stopAt(summaryID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.StoppedAt = summaryID
+}
+
+// leaveUnfinished records the conversations an --overall-timeout batch did
+// not finish.
+func (r *runReport) leaveUnfinished(conversationIDs []int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Unfinished = append(r.Unfinished, conversationIDs...)
+}
+
+// mark returns a position for rollBackFrom.
+func (r *runReport) mark() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.Summaries)
+}
+
+// rollBackFrom relabels summaries recorded since mark whose writes were
+// discarded with their transaction.
+func (r *runReport) rollBackFrom(mark int) {
+	if r == nil {
+		return
+	}
+	for i := mark; i < len(r.Summaries); i++ {
+		r.Summaries[i].Note = "rolled back: " + r.Summaries[i].Action
+		r.Summaries[i].Action = "rolled_back"
+		r.Summaries[i].NewTokens = r.Summaries[i].OldTokens
+	}
+}
+
+// observe records summarize calls made through client.
+func (r *runReport) observe(client *anthropicClient) {
+	if r == nil || client == nil {
+		return
+	}
+	client.observe = func(model string, promptTokens, outputTokens int, elapsed time.Duration, err error) {
+		call := runReportCall{
+			Model:        model,
+			PromptTokens: promptTokens,
+			OutputTokens: outputTokens,
+			DurationMS:   elapsed.Milliseconds(),
+		}
+		if err != nil {
+			call.Error = err.Error()
+		}
+		r.mu.Lock()
+		r.Calls = append(r.Calls, call)
+		r.mu.Unlock()
+	}
+}
+
+// addNewSummaries records conversationID's summaries that are not in before
+// under action, for commands such as backfill that create summaries in bulk.
+func (r *runReport) addNewSummaries(ctx context.Context, q sqlQueryer, conversationID int64, before map[string]bool, action string) error {
+	if r == nil || conversationID <= 0 {
+		return nil
+	}
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return err
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT summary_id, kind, COALESCE(depth, 0), COALESCE(token_count, 0)
+		FROM summaries
+		WHERE conversation_id = ?
+		ORDER BY COALESCE(depth, 0) ASC, created_at ASC, `+caps.SummarySeqExpr("")+` ASC, summary_id ASC
+	`, conversationID)
+	if err != nil {
+		return fmt.Errorf("query summaries for report %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := runReportSummary{ConversationID: conversationID, Action: action}
+		if err := rows.Scan(&entry.SummaryID, &entry.Kind, &entry.Depth, &entry.NewTokens); err != nil {
+			return fmt.Errorf("scan report summary: %w", err)
+		}
+		if !before[entry.SummaryID] {
+			r.Summaries = append(r.Summaries, entry)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate report summaries: %w", err)
+	}
+	return nil
+}
+
+// loadConversationSummaryIDs snapshots a conversation's summary IDs before a
+// run so addNewSummaries can tell which ones it created.
+func loadConversationSummaryIDs(ctx context.Context, q sqlQueryer, conversationID int64) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	if conversationID <= 0 {
+		return ids, nil
+	}
+	rows, err := q.QueryContext(ctx, `SELECT summary_id FROM summaries WHERE conversation_id = ?`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query summary IDs for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan summary ID: %w", err)
+		}
+		ids[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate summary IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// finish fills in timing, outcome, and totals from runErr.
+func (r *runReport) finish(runErr error) {
+	now := time.Now()
+	r.FinishedAt = now.UTC().Format(time.RFC3339)
+	r.DurationMS = now.Sub(r.started).Milliseconds()
+	r.Outcome = "completed"
+	if runErr != nil {
+		r.Outcome = "failed"
+		r.Error = runErr.Error()
+	}
+
+	r.Totals = runReportTotals{}
+	r.Models = nil
+	for _, s := range r.Summaries {
+		if s.Action == "rolled_back" || strings.HasPrefix(s.Action, "skipped") {
+			continue
+		}
+		r.Totals.Summaries++
+		r.Totals.OldTokens += s.OldTokens
+		r.Totals.NewTokens += s.NewTokens
+	}
+	for _, call := range r.Calls {
+		r.Totals.Calls++
+		r.Totals.PromptTokens += call.PromptTokens
+		if call.Error != "" {
+			r.Totals.FailedCalls++
+			continue
+		}
+		r.Totals.OutputTokens += call.OutputTokens
+		if r.Models == nil {
+			r.Models = make(map[string]int)
+		}
+		r.Models[call.Model]++
+	}
+}
+
+// close finishes the report and writes it to its path, returning runErr
+// unchanged. A write failure is returned only when the run itself succeeded;
+// otherwise it is printed so the original error is not masked.
+func (r *runReport) close(runErr error) error {
+	if r == nil {
+		return runErr
+	}
+	r.finish(runErr)
+	if err := writeRunReport(r.path, r); err != nil {
+		if runErr == nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return runErr
+	}
+	fmt.Printf("Run report written to %s\n", r.path)
+	return runErr
+}
+
+// writeRunReport writes JSON, or markdown when path ends in .md.
+func writeRunReport(path string, r *runReport) error {
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".md") {
+		data = []byte(renderRunReportMarkdown(r))
+	} else {
+		encoded, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode run report: %w", err)
+		}
+		data = append(encoded, '\n')
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write run report %q: %w", path, err)
+	}
+	return nil
+}
+
+func renderRunReportMarkdown(r *runReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# lcm-tui %s run report\n\n", r.Command)
+	fmt.Fprintf(&b, "- Command: `lcm-tui %s %s`\n", r.Command, strings.Join(r.Args, " "))
+	fmt.Fprintf(&b, "- Mode: %s\n", r.Mode)
+	ids := make([]string, 0, len(r.ConversationIDs))
+	for _, id := range r.ConversationIDs {
+		ids = append(ids, fmt.Sprintf("%d", id))
+	}
+	fmt.Fprintf(&b, "- Conversations: %s\n", strings.Join(ids, ", "))
+	fmt.Fprintf(&b, "- Started: %s, finished: %s (%s)\n", r.StartedAt, r.FinishedAt, time.Duration(r.DurationMS)*time.Millisecond)
+	fmt.Fprintf(&b, "- Outcome: %s\n", r.Outcome)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "- Error: %s\n", oneLine(r.Error))
+	}
+	if r.StoppedAt != "" {
+		fmt.Fprintf(&b, "- Stopped at: %s\n", r.StoppedAt)
+	}
+	if len(r.Unfinished) > 0 {
+		unfinished := make([]string, 0, len(r.Unfinished))
+		for _, id := range r.Unfinished {
+			unfinished = append(unfinished, fmt.Sprintf("%d", id))
+		}
+		fmt.Fprintf(&b, "- Unfinished conversations: %s\n", strings.Join(unfinished, ", "))
+	}
+	if r.BackupPath != "" {
+		fmt.Fprintf(&b, "- DB backup: %s\n", r.BackupPath)
+	}
+	if len(r.Models) > 0 {
+		models := make([]string, 0, len(r.Models))
+		for model, count := range r.Models {
+			models = append(models, fmt.Sprintf("%s (%d)", model, count))
+		}
+		sort.Strings(models)
+		fmt.Fprintf(&b, "- Models: %s\n", strings.Join(models, ", "))
+	}
+	fmt.Fprintf(&b, "- Calls: %d (%d failed), ~%d prompt tokens, ~%d output tokens\n",
+		r.Totals.Calls, r.Totals.FailedCalls, r.Totals.PromptTokens, r.Totals.OutputTokens)
+	fmt.Fprintf(&b, "- Summaries: %d, %dt -> %dt (%+dt)\n",
+		r.Totals.Summaries, r.Totals.OldTokens, r.Totals.NewTokens, r.Totals.NewTokens-r.Totals.OldTokens)
+
+	if len(r.Summaries) > 0 {
+		b.WriteString("\n| conversation | summary | kind | depth | action | before | after | ratio | model | note |\n")
+		b.WriteString("|---:|---|---|---:|---|---:|---:|---:|---|---|\n")
+		for _, s := range r.Summaries {
+			ratio := ""
+			if s.SourceTokens > 0 {
+				ratio = fmt.Sprintf("%.2f", s.CompressionRatio)
+			}
+			fmt.Fprintf(&b, "| %d | %s | %s | %d | %s | %d | %d | %s | %s | %s |\n",
+				s.ConversationID, s.SummaryID, s.Kind, s.Depth, s.Action, s.OldTokens, s.NewTokens, ratio, s.Model,
+				strings.ReplaceAll(oneLine(s.Note), "|", `\|`))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReportWritesJSONOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	report := newRunReport(path, "rewrite", []string{"44", "--all", "--apply"}, true, 44)
+	client := &anthropicClient{}
+	report.observe(client)
+	client.observe("claude-a", 1000, 200, 2*time.Second, nil)
+	client.observe("claude-a", 900, 0, time.Second, errors.New("rate limited"))
+	report.addSummary(runReportSummary{ConversationID: 44, SummaryID: "sum_a", Action: "rewritten", OldTokens: 500, NewTokens: 200, Model: "claude-a"})
+	report.addSummary(runReportSummary{ConversationID: 44, SummaryID: "sum_b", Action: "skipped_suspect", OldTokens: 400, NewTokens: 400})
+	report.stopAt("sum_c")
+
+	runErr := errors.New("rewrite sum_c: rate limited")
+	if err := report.close(runErr); err != runErr {
+		t.Fatalf("close should return the run error unchanged, got %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var decoded runReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, data)
+	}
+	if decoded.Outcome != "failed" || decoded.StoppedAt != "sum_c" || decoded.Mode != "apply" {
+		t.Fatalf("unexpected outcome fields %+v", &decoded)
+	}
+	totals := decoded.Totals
+	if totals.Summaries != 1 || totals.OldTokens != 500 || totals.NewTokens != 200 {
+		t.Fatalf("skipped summaries should not count toward totals: %+v", totals)
+	}
+	if totals.Calls != 2 || totals.FailedCalls != 1 || totals.PromptTokens != 1900 || totals.OutputTokens != 200 {
+		t.Fatalf("unexpected call totals %+v", totals)
+	}
+	if decoded.Models["claude-a"] != 1 {
+		t.Fatalf("expected one successful claude-a call, got %v", decoded.Models)
+	}
+}
+
+func TestRunReportMarkdownAndNilReport(t *testing.T) {
+	var disabled *runReport
+	disabled.addSummary(runReportSummary{SummaryID: "sum_a"})
+	if err := disabled.close(nil); err != nil {
+		t.Fatalf("nil report close: %v", err)
+	}
+	if newRunReport("", "repair", nil, true) != nil {
+		t.Fatal("expected no report without a path")
+	}
+
+	path := filepath.Join(t.TempDir(), "run.md")
+	report := newRunReport(path, "transplant", []string{"1", "2", "--apply"}, true, 1, 2)
+	report.addSummary(runReportSummary{ConversationID: 1, SummaryID: "sum_a", Kind: "leaf", Action: "transplanted", NewTokens: 40, Note: "copied into conversation 2"})
+	if err := report.close(nil); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	text := string(data)
+	for _, want := range []string{"# lcm-tui transplant run report", "- Outcome: completed", "| 1 | sum_a | leaf | 0 | transplanted | 0 | 40 |"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("markdown report missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+const defaultInjectionSnippet = 60
+
+// injectionRule is one heuristic for directive-like text that should not
+// survive into a summary. Summaries are reinserted into the model's context,
+// so a persisted instruction keeps acting on every later turn.
+type injectionRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var injectionRules = []injectionRule{
+	{"ignore-instructions", regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override)\s+(?:all\s+|any\s+)?(?:of\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding|original)\s+(?:instructions|prompts?|rules|directions|guidelines|messages)`)},
+	{"new-instructions", regexp.MustCompile(`(?i)\b(?:new|updated|real|actual)\s+(?:system\s+)?instructions\s*:`)},
+	{"role-override", regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(?:a|an|in|the)\b|\bfrom\s+now\s+on,?\s+you\s+(?:will|must|are)\b|\bact\s+as\s+(?:an?\s+)?(?:unrestricted|jailbroken|unfiltered)\b`)},
+	{"you-must", regexp.MustCompile(`(?i)\byou\s+(?:must|shall|are\s+required\s+to)\s+(?:always|never|now|immediately|not)\b`)},
+	{"system-prompt-marker", regexp.MustCompile(`(?im)^\s*(?:system|developer)\s*:\s|<\s*/?\s*(?:system|system_prompt|instructions)\s*>|\[\s*(?:SYSTEM|INST)\s*\]|<<\s*SYS\s*>>`)},
+	{"prompt-exfiltration", regexp.MustCompile(`(?i)\b(?:reveal|print|repeat|output|show)\s+(?:me\s+)?(?:your|the)\s+(?:system\s+prompt|hidden\s+(?:prompt|instructions)|initial\s+instructions)`)},
+	{"conceal-from-user", regexp.MustCompile(`(?i)\b(?:do\s+not|don't|never)\s+(?:tell|inform|reveal\s+(?:this\s+)?to|mention\s+(?:this\s+)?to)\s+the\s+user\b`)},
+}
+
+type scanInjectionOptions struct {
+	conversationID int64
+	contextOnly    bool
+	jsonOutput     bool
+	snippet        int
+}
+
+// injectionMatch is one rule hit. The JSON tags are the --json output
+// contract, shared with injectionFinding and injectionReport.
+type injectionMatch struct {
+	Rule    string `json:"rule"`
+	Text    string `json:"text"`
+	Snippet string `json:"snippet"`
+}
+
+type injectionFinding struct {
+	SummaryID  string           `json:"summary_id"`
+	Kind       string           `json:"kind"`
+	Depth      int              `json:"depth"`
+	TokenCount int              `json:"token_count"`
+	InContext  bool             `json:"in_context"`
+	Matches    []injectionMatch `json:"matches"`
+}
+
+type injectionReport struct {
+	ConversationID int64              `json:"conversation_id"`
+	Scanned        int                `json:"scanned"`
+	Flagged        []injectionFinding `json:"flagged"`
+}
+
+// runScanInjectionCommand lints a conversation's summaries for persisted
+// instruction-like text. Read-only; a heuristic, not a guarantee.
+func runScanInjectionCommand(args []string) error {
+	opts, err := parseScanInjectionArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := scanSummariesForInjection(context.Background(), db, opts)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("encode scan-injection report: %w", err)
+		}
+		return nil
+	}
+	printInjectionReport(report)
+	return nil
+}
+
+// scanSummariesForInjection runs every rule over each summary in the
+// conversation, deepest first, and keeps the summaries with any hit.
+func scanSummariesForInjection(ctx context.Context, q sqlQueryer, opts scanInjectionOptions) (injectionReport, error) {
+	report := injectionReport{ConversationID: opts.conversationID, Flagged: []injectionFinding{}}
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return injectionReport{}, err
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.summary_id, s.kind, s.depth, s.token_count, s.content,
+			EXISTS (
+				SELECT 1 FROM context_items ci
+				WHERE ci.conversation_id = s.conversation_id AND ci.summary_id = s.summary_id
+			)
+		FROM summaries s
+		WHERE s.conversation_id = ?
+		ORDER BY s.depth DESC, s.created_at ASC, `+caps.SummarySeqExpr("s")+` ASC, s.summary_id ASC
+	`, opts.conversationID)
+	if err != nil {
+		return injectionReport{}, fmt.Errorf("query summaries for conversation %d: %w", opts.conversationID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var finding injectionFinding
+		var content string
+		if err := rows.Scan(&finding.SummaryID, &finding.Kind, &finding.Depth, &finding.TokenCount, &content, &finding.InContext); err != nil {
+			return injectionReport{}, fmt.Errorf("scan summary row: %w", err)
+		}
+		if opts.contextOnly && !finding.InContext {
+			continue
+		}
+		report.Scanned++
+		finding.Matches = findInjectionMatches(content, opts.snippet)
+		if len(finding.Matches) > 0 {
+			report.Flagged = append(report.Flagged, finding)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return injectionReport{}, fmt.Errorf("iterate summaries: %w", err)
+	}
+	return report, nil
+}
+
+// findInjectionMatches returns every rule hit in content, in rule order.
+func findInjectionMatches(content string, snippet int) []injectionMatch {
+	var matches []injectionMatch
+	for _, rule := range injectionRules {
+		for _, loc := range rule.re.FindAllStringIndex(content, -1) {
+			matches = append(matches, injectionMatch{
+				Rule:    rule.name,
+				Text:    strings.Join(strings.Fields(content[loc[0]:loc[1]]), " "),
+				Snippet: grepSnippet(content, loc[0], loc[1], snippet),
+			})
+		}
+	}
+	return matches
+}
+
+func printInjectionReport(report injectionReport) {
+	if len(report.Flagged) == 0 {
+		fmt.Printf("Conversation %d: scanned %d summaries, none look like injected instructions.\n", report.ConversationID, report.Scanned)
+		return
+	}
+	fmt.Printf("Conversation %d: scanned %d summaries, %d flagged. These are heuristic hits; review each before rewriting.\n\n",
+		report.ConversationID, report.Scanned, len(report.Flagged))
+	for _, finding := range report.Flagged {
+		kindLabel := finding.Kind
+		if finding.Kind == "condensed" {
+			kindLabel = fmt.Sprintf("d%d", finding.Depth)
+		}
+		location := "not in context"
+		if finding.InContext {
+			location = "in context"
+		}
+		fmt.Printf("%s  %s  %dt  %s\n", finding.SummaryID, kindLabel, finding.TokenCount, location)
+		for _, match := range finding.Matches {
+			fmt.Printf("  %-21s %s\n", match.Rule, match.Snippet)
+		}
+	}
+	fmt.Println("\nTo regenerate a flagged summary from its sources (dry run first):")
+	for _, finding := range report.Flagged {
+		fmt.Printf("  lcm-tui rewrite %d --summary %s\n", report.ConversationID, finding.SummaryID)
+	}
+}
+
+func parseScanInjectionArgs(args []string) (scanInjectionOptions, error) {
+	fs := flag.NewFlagSet("scan-injection", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	contextOnly := fs.Bool("context-only", false, "only scan summaries in the active context")
+	jsonOutput := fs.Bool("json", false, "print findings as JSON")
+	snippet := fs.Int("snippet", defaultInjectionSnippet, "characters of context on each side of a match")
+
+	normalized, err := normalizeScanInjectionArgs(args)
+	if err != nil {
+		return scanInjectionOptions{}, fmt.Errorf("%w\n%s", err, scanInjectionUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return scanInjectionOptions{}, errors.New(scanInjectionUsageText())
+		}
+		return scanInjectionOptions{}, fmt.Errorf("%w\n%s", err, scanInjectionUsageText())
+	}
+	if fs.NArg() != 1 {
+		return scanInjectionOptions{}, fmt.Errorf("conversation ID is required\n%s", scanInjectionUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return scanInjectionOptions{}, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), scanInjectionUsageText())
+	}
+	if *snippet < 0 {
+		return scanInjectionOptions{}, fmt.Errorf("--snippet must be >= 0\n%s", scanInjectionUsageText())
+	}
+	return scanInjectionOptions{
+		conversationID: conversationID,
+		contextOnly:    *contextOnly,
+		jsonOutput:     *jsonOutput,
+		snippet:        *snippet,
+	}, nil
+}
+
+func normalizeScanInjectionArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--snippet":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func scanInjectionUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui scan-injection <conversation_id> [--context-only] [--json] [--snippet <n>]
+
+Flag summaries whose content looks like injected instructions: "ignore
+previous instructions", "you must always ...", "you are now ...", system
+prompt markers, requests to reveal the prompt or hide things from the user.
+Summaries are reinserted into context, so a persisted directive keeps
+acting on later turns. Each hit prints with its rule and the matched span
+in brackets. This is a heuristic linter: expect some false positives (for
+example a summary quoting a discussion about prompt injection) and do not
+treat a clean result as a guarantee. Read-only.
+
+Flags:
+  --context-only   only scan summaries in the active context
+  --json           print findings as JSON
+  --snippet <n>    characters of context on each side of a match (default 60)
+`)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// lcmSchemaTable describes a table the TUI reads when present.
+type lcmSchemaTable struct {
+	name     string
+	required bool
+	feature  string
+}
+
+// lcmSchemaColumn describes a column added by later plugin migrations.
+type lcmSchemaColumn struct {
+	table   string
+	column  string
+	feature string
+}
+
+// lcmSchemaTables lists the tables the TUI knows about. Required tables back
+// the core browsing screens; the rest gate optional features.
+var lcmSchemaTables = []lcmSchemaTable{
+	{name: "conversations", required: true, feature: "conversation lookup"},
+	{name: "messages", required: true, feature: "message browsing"},
+	{name: "summaries", required: true, feature: "summary DAG"},
+	{name: "summary_parents", required: true, feature: "condensed summary lineage"},
+	{name: "summary_messages", required: true, feature: "leaf summary sources"},
+	{name: "context_items", required: true, feature: "context screen"},
+	{name: "message_parts", feature: "tool/file part rendering"},
+	{name: "large_files", feature: "files screen"},
+	{name: "messages_fts", feature: "full-text index maintenance on import"},
+	{name: "focus_briefs", feature: "focus brief screen"},
+	{name: "focus_brief_sources", feature: "focus brief overlay"},
+	{name: "lcm_migration_state", feature: "migration step tracking"},
+	{name: "summaries_fts", feature: "full-text index maintenance on prune"},
+	{name: "summaries_fts_cjk", feature: "CJK full-text index maintenance on prune"},
+	{name: "conversation_tags", feature: "conversation tags (created by lcm-tui tag)"},
+	{name: "conversation_settings", feature: "per-conversation settings (created by lcm-tui settings)"},
+	{name: "audit_log", feature: "mutation history (created by the first lcm-tui write)"},
+}
+
+// lcmSchemaColumns lists optional columns that older databases may lack.
+var lcmSchemaColumns = []lcmSchemaColumn{
+	{table: "summaries", column: "depth", feature: "depth-aware prompts and rewrite"},
+	{table: "summaries", column: "earliest_at", feature: "summary time ranges (backfill)"},
+	{table: "summaries", column: "latest_at", feature: "context screen summary timestamps"},
+	{table: "summaries", column: "descendant_count", feature: "descendant metadata (backfill)"},
+	{table: "summaries", column: "descendant_token_count", feature: "descendant token metadata"},
+	{table: "summaries", column: "source_message_token_count", feature: "source token metadata"},
+	{table: "summaries", column: "model", feature: "summary provenance"},
+	{table: "summaries", column: "created_seq", feature: "same-second summary ordering"},
+	{table: "messages", column: "identity_hash", feature: "message identity dedupe (backfill/transplant)"},
+	{table: "messages", column: "large_content", feature: "externalized large message content"},
+	{table: "conversations", column: "session_key", feature: "session key lookup"},
+	{table: "conversations", column: "title", feature: "conversation titles"},
+	{table: "conversations", column: "active", feature: "active conversation filtering"},
+	{table: "large_files", column: "line_count", feature: "file line counts"},
+}
+
+// lcmSchemaCapabilities is the probed schema of an LCM database; see
+// lcm.SchemaCapabilitiesOf.
+type lcmSchemaCapabilities = lcm.SchemaCapabilities
+
+// missingRequiredTables returns required tables absent from the DB.
+func missingRequiredTables(caps lcmSchemaCapabilities) []string {
+	missing := make([]string, 0)
+	for _, table := range lcmSchemaTables {
+		if table.required && !caps.HasTable(table.name) {
+			missing = append(missing, table.name)
+		}
+	}
+	return missing
+}
+
+// missingOptionalColumns returns "table.column" keys absent from present tables.
+func missingOptionalColumns(caps lcmSchemaCapabilities) []string {
+	missing := make([]string, 0)
+	for _, column := range lcmSchemaColumns {
+		if caps.HasTable(column.table) && !caps.HasColumn(column.table, column.column) {
+			missing = append(missing, column.table+"."+column.column)
+		}
+	}
+	return missing
+}
+
+// runSchemaCommand prints detected schema capabilities for the LCM database.
+func runSchemaCommand(args []string) error {
+	if len(args) > 0 {
+		if args[0] == "-h" || args[0] == "--help" {
+			return errors.New(schemaUsageText())
+		}
+		return fmt.Errorf("unexpected argument %q\n%s", args[0], schemaUsageText())
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	printSchemaReport(paths.lcmDBPath, caps)
+	return nil
+}
+
+func schemaUsageText() string {
+	return strings.TrimSpace(`Usage:
+  lcm-tui schema
+
+Prints the LCM database schema version and which optional tables/columns are
+present, along with the features that depend on them.
+`)
+}
+
+func printSchemaReport(dbPath string, caps lcmSchemaCapabilities) {
+	fmt.Printf("Database: %s\n", dbPath)
+	fmt.Printf("user_version: %d\n", caps.UserVersion)
+	if caps.HasTable("lcm_migration_state") {
+		fmt.Printf("migration steps recorded: %d\n", caps.MigrationSteps)
+	}
+
+	fmt.Println("\nTables:")
+	for _, table := range lcmSchemaTables {
+		status := "present"
+		if !caps.HasTable(table.name) {
+			status = "missing"
+			if table.required {
+				status = "MISSING (required)"
+			}
+		}
+		fmt.Printf("  %-22s %-20s %s\n", table.name, status, table.feature)
+	}
+
+	fmt.Println("\nOptional columns:")
+	for _, column := range lcmSchemaColumns {
+		key := column.table + "." + column.column
+		status := "present"
+		switch {
+		case !caps.HasTable(column.table):
+			status = "n/a (no table)"
+		case !caps.HasColumn(column.table, column.column):
+			status = "missing"
+		}
+		fmt.Printf("  %-37s %-15s %s\n", key, status, column.feature)
+	}
+
+	missingTables := missingRequiredTables(caps)
+	missingColumns := missingOptionalColumns(caps)
+	if len(missingTables) == 0 && len(missingColumns) == 0 {
+		fmt.Println("\nAll known features are supported by this schema.")
+		return
+	}
+	if len(missingTables) > 0 {
+		fmt.Printf("\nMissing required tables: %s\n", strings.Join(missingTables, ", "))
+	}
+	if len(missingColumns) > 0 {
+		fmt.Printf("\nDegraded features (missing columns): %s\n", strings.Join(missingColumns, ", "))
+	}
+}
+
+// logLCMSchemaCapabilities logs a one-line capability summary at startup.
+// Missing databases are skipped so a fresh install does not create one.
+func logLCMSchemaCapabilities(dbPath string) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return
+	}
+	db, err := openLCMDB(dbPath)
+	if err != nil {
+		log.Printf("[lcm-tui] schema probe skipped: %v", err)
+		return
+	}
+	defer db.Close()
+
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
+		log.Printf("[lcm-tui] schema probe failed: %v", err)
+		return
+	}
+	missingTables := missingRequiredTables(caps)
+	missingColumns := missingOptionalColumns(caps)
+	if len(missingTables) == 0 && len(missingColumns) == 0 {
+		log.Printf("[lcm-tui] schema user_version=%d: all known tables and columns present", caps.UserVersion)
+		return
+	}
+	log.Printf(
+		"[lcm-tui] schema user_version=%d: missing tables=[%s] missing columns=[%s]",
+		caps.UserVersion,
+		strings.Join(missingTables, ", "),
+		strings.Join(missingColumns, ", "),
+	)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func TestProbeLCMSchemaReportsMissingOptionalColumns(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lcm.db"))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		PRAGMA user_version = 7;
+		CREATE TABLE conversations (conversation_id INTEGER PRIMARY KEY, session_id TEXT);
+		CREATE TABLE messages (message_id INTEGER PRIMARY KEY, content TEXT, identity_hash TEXT);
+		CREATE TABLE summaries (summary_id TEXT PRIMARY KEY, depth INTEGER, latest_at TEXT);
+		CREATE TABLE summary_parents (summary_id TEXT, parent_summary_id TEXT, ordinal INTEGER);
+		CREATE TABLE summary_messages (summary_id TEXT, message_id INTEGER, ordinal INTEGER);
+	`); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	caps, err := lcm.SchemaCapabilitiesOf(context.Background(), db)
+	if err != nil {
+		t.Fatalf("probe schema: %v", err)
+	}
+	if caps.UserVersion != 7 {
+		t.Fatalf("user_version = %d, want 7", caps.UserVersion)
+	}
+	if !caps.HasColumn("summaries", "latest_at") {
+		t.Fatalf("expected summaries.latest_at to be detected")
+	}
+	if caps.HasColumn("summaries", "earliest_at") {
+		t.Fatalf("summaries.earliest_at should be reported missing")
+	}
+	if got := strings.Join(missingRequiredTables(caps), ","); got != "context_items" {
+		t.Fatalf("missing required tables = %q, want context_items", got)
+	}
+
+	missing := strings.Join(missingOptionalColumns(caps), ",")
+	if !strings.Contains(missing, "summaries.earliest_at") || !strings.Contains(missing, "messages.large_content") {
+		t.Fatalf("expected missing optional columns, got %q", missing)
+	}
+	if strings.Contains(missing, "large_files.") {
+		t.Fatalf("columns of absent tables should not be listed as missing, got %q", missing)
+	}
+}
+
+func TestSchemaCapabilitiesCachesPerDBUntilLCMTUICreatesATable(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		t.Fatalf("schema capabilities: %v", err)
+	}
+	if caps.HasTable("conversation_tags") {
+		t.Fatalf("conversation_tags should not exist before the first tag")
+	}
+	// A table created behind the cache's back is not seen until the cache is
+	// dropped.
+	mustExec(t, db, `CREATE TABLE scratch (id INTEGER)`)
+	if caps, _ := lcm.SchemaCapabilitiesOf(ctx, db); caps.HasTable("scratch") {
+		t.Fatalf("expected the cached probe to be reused")
+	}
+
+	if err := ensureConversationTagsTable(ctx, db); err != nil {
+		t.Fatalf("create conversation_tags: %v", err)
+	}
+	caps, err = lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		t.Fatalf("schema capabilities after create: %v", err)
+	}
+	if !caps.HasTable("conversation_tags") || !caps.HasColumn("conversation_tags", "tag") || !caps.HasTable("scratch") {
+		t.Fatalf("expected a fresh probe after lcm-tui created a table")
+	}
+}
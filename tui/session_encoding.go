@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// sessionDecoder converts session JSONL bytes to UTF-8 before they are
+// parsed. Older exports are sometimes latin-1, UTF-16, or a mix of encodings
+// line by line; without this json.Unmarshal either drops those lines or the
+// content reaches the terminal as replacement characters. It also tallies
+// what each line turned out to be, so an empty parse can say why.
+type sessionDecoder struct {
+	label      string            // "auto" or the --encoding value
+	forced     encoding.Encoding // nil in auto mode
+	bom        string            // encoding named by the file's byte order mark
+	transcoded int               // auto mode: non-UTF-8 lines decoded as windows-1252
+
+	lines       int // non-blank lines read
+	nonMessage  int // valid JSON lines whose type is not "message"
+	parseErrors int // lines (or their message payloads) that are not valid JSON
+}
+
+// newSessionDecoder resolves an --encoding value. Empty and "auto" detect a
+// byte order mark and transcode only lines that are not valid UTF-8; any
+// WHATWG label (latin1, windows-1252, utf-16le, shift_jis, ...) decodes the
+// whole file with that encoding.
+func newSessionDecoder(label string) (*sessionDecoder, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" || label == "auto" {
+		return &sessionDecoder{label: "auto"}, nil
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported --encoding %q (use auto, utf-8, latin1, windows-1252, utf-16le, ...)", label)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		// Strip a BOM but otherwise read as-is, without per-line guessing.
+		return &sessionDecoder{label: name, forced: unicode.UTF8BOM}, nil
+	}
+	return &sessionDecoder{label: label, forced: enc}, nil
+}
+
+// openSessionScanner opens a session JSONL for line scanning with decoder's
+// stream conversion applied. Callers still pass each line through
+// decoder.line.
+func openSessionScanner(path string, decoder *sessionDecoder) (*bufio.Scanner, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open session %q: %w", path, err)
+	}
+	scanner := bufio.NewScanner(decoder.reader(file))
+	buf := make([]byte, 64*1024)
+	scanner.Buffer(buf, 16*1024*1024)
+	return scanner, file, nil
+}
+
+// reader wraps r so it yields UTF-8. In auto mode only a byte order mark
+// changes the stream; other non-UTF-8 bytes are handled per line by line().
+func (d *sessionDecoder) reader(r io.Reader) io.Reader {
+	if d.forced != nil {
+		return transform.NewReader(r, d.forced.NewDecoder())
+	}
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}), bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		d.bom = "utf-16"
+		return transform.NewReader(br, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder())
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		_, _ = br.Discard(3)
+	}
+	return br
+}
+
+// line returns a UTF-8 version of one scanned line. Invalid UTF-8 in auto
+// mode is assumed to be windows-1252, the usual superset of latin-1.
+func (d *sessionDecoder) line(raw []byte) []byte {
+	if d.forced != nil || d.bom != "" || utf8.Valid(raw) {
+		return raw
+	}
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(raw)
+	if err != nil {
+		return raw
+	}
+	d.transcoded++
+	return decoded
+}
+
+// messageLine decodes one scanned line into a session message, counting
+// blank-free lines, non-message entries, and JSON failures as it goes.
+func (d *sessionDecoder) messageLine(raw []byte) (sessionLine, lineMessage, bool) {
+	line := d.line(raw)
+	if len(line) == 0 {
+		return sessionLine{}, lineMessage{}, false
+	}
+	d.lines++
+
+	var item sessionLine
+	if err := json.Unmarshal(line, &item); err != nil {
+		d.parseErrors++
+		return sessionLine{}, lineMessage{}, false
+	}
+	if item.Type != "message" {
+		d.nonMessage++
+		return sessionLine{}, lineMessage{}, false
+	}
+	var msg lineMessage
+	if err := json.Unmarshal(item.Message, &msg); err != nil {
+		d.parseErrors++
+		return sessionLine{}, lineMessage{}, false
+	}
+	return item, msg, true
+}
+
+// parseDiagnostic reports how the file's lines were classified, e.g.
+// "0 of 512 lines parsed as messages (480 non-message, 32 parse errors)".
+func (d *sessionDecoder) parseDiagnostic() string {
+	if d.lines == 0 {
+		return "session file has no non-blank lines"
+	}
+	return fmt.Sprintf("%d of %d lines parsed as messages (%d non-message, %d parse errors)",
+		d.lines-d.nonMessage-d.parseErrors, d.lines, d.nonMessage, d.parseErrors)
+}
+
+// summary describes any transcoding that happened while reading path, or
+// returns "" when the file was read as plain UTF-8.
+func (d *sessionDecoder) summary(path string) string {
+	switch {
+	case d.label == "utf-8":
+		return ""
+	case d.forced != nil:
+		return fmt.Sprintf("Transcoded %s from %s to UTF-8", path, d.label)
+	case d.bom != "":
+		return fmt.Sprintf("Transcoded %s from %s (byte order mark) to UTF-8", path, d.bom)
+	case d.transcoded > 0:
+		return fmt.Sprintf("Transcoded %d non-UTF-8 lines in %s from windows-1252 to UTF-8", d.transcoded, path)
+	}
+	return ""
+}
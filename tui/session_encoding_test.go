@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func writeSessionBytes(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "legacy.jsonl")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+	return path
+}
+
+func TestParseSessionTranscodesMixedLatin1Lines(t *testing.T) {
+	utf8Line := `{"type":"message","id":"m1","message":{"role":"user","content":"naïve"}}`
+	latin1Line := append([]byte(`{"type":"message","id":"m2","message":{"role":"assistant","content":"caf`), 0xE9, '"', '}', '}')
+	data := append([]byte(utf8Line+"\n"), latin1Line...)
+	path := writeSessionBytes(t, append(data, '\n'))
+
+	decoder, err := newSessionDecoder("")
+	if err != nil {
+		t.Fatalf("new decoder: %v", err)
+	}
+	messages, err := parseBackfillSessionFile(path, false, decoder, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Content != "naïve" || messages[1].Content != "café" {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	if note := decoder.summary("legacy.jsonl"); !strings.Contains(note, "1 non-UTF-8 lines") {
+		t.Fatalf("expected transcoding note, got %q", note)
+	}
+}
+
+func TestParseSessionDecodesUTF16WithBOM(t *testing.T) {
+	line := `{"type":"message","id":"m1","message":{"role":"user","content":"héllo"}}` + "\n"
+	encoded, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(line))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	path := writeSessionBytes(t, encoded)
+
+	decoder, _ := newSessionDecoder("auto")
+	messages, err := parseSessionMessages(path, decoder, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(messages) != 1 || messages[0].text != "héllo" {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	if note := decoder.summary("legacy.jsonl"); !strings.Contains(note, "utf-16") {
+		t.Fatalf("expected BOM note, got %q", note)
+	}
+}
+
+func TestSessionDecoderExplicitEncoding(t *testing.T) {
+	line := append([]byte(`{"type":"message","id":"m1","message":{"role":"user","content":"`), 0xC5, 0x6E, 0x67, 0x73, 0x74, 0x72, 0xF6, 0x6D, '"', '}', '}', '\n')
+	path := writeSessionBytes(t, line)
+
+	decoder, err := newSessionDecoder("ISO-8859-1")
+	if err != nil {
+		t.Fatalf("new decoder: %v", err)
+	}
+	messages, err := parseBackfillSessionFile(path, false, decoder, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Content != "Ångström" {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	if note := decoder.summary("legacy.jsonl"); !strings.Contains(note, "iso-8859-1") {
+		t.Fatalf("expected explicit encoding note, got %q", note)
+	}
+
+	plain, _ := newSessionDecoder("utf-8")
+	if _, err := parseBackfillSessionFile(path, false, plain, nil); err != nil {
+		t.Fatalf("parse utf-8: %v", err)
+	}
+	if note := plain.summary("legacy.jsonl"); note != "" {
+		t.Fatalf("expected no note for explicit utf-8, got %q", note)
+	}
+
+	if _, err := newSessionDecoder("klingon"); err == nil {
+		t.Fatal("expected unknown encoding to be rejected")
+	}
+}
+
+func TestSessionParseDiagnosticCountsLineOutcomes(t *testing.T) {
+	data := strings.Join([]string{
+		`{"type":"session","id":"s1"}`,
+		`{"type":"model_change","id":"c1"}`,
+		``,
+		`{"type":"message","id":"m1","message":"not an object"}`,
+		`not json at all`,
+	}, "\n")
+	path := writeSessionBytes(t, []byte(data+"\n"))
+
+	decoder, err := newSessionDecoder("")
+	if err != nil {
+		t.Fatalf("new decoder: %v", err)
+	}
+	messages, err := parseSessionMessages(path, decoder, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("expected no messages, got %+v", messages)
+	}
+	if got, want := decoder.parseDiagnostic(), "0 of 4 lines parsed as messages (2 non-message, 2 parse errors)"; got != want {
+		t.Fatalf("diagnostic = %q, want %q", got, want)
+	}
+
+	empty, _ := newSessionDecoder("")
+	if _, err := parseSessionMessages(writeSessionBytes(t, []byte("\n\n")), empty, nil); err != nil {
+		t.Fatalf("parse empty: %v", err)
+	}
+	if got := empty.parseDiagnostic(); !strings.Contains(got, "no non-blank lines") {
+		t.Fatalf("expected empty-file diagnostic, got %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// conversationSession is --session/--conversation-index: a conversation named
+// by the session ID it was recorded under (the JSONL file name) instead of
+// its numeric ID. The zero value means the numeric ID was given.
+type conversationSession struct {
+	sessionID string
+	// index is --conversation-index for sessions that were reset into
+	// several conversations: 1-based, newest first as `lcm-tui conversations`
+	// lists them, so 1 is the one the TUI opens. 0 requires exactly one.
+	index int
+}
+
+// newConversationSession validates the --session and --conversation-index
+// flags.
+func newConversationSession(sessionID string, index int) (conversationSession, error) {
+	s := conversationSession{sessionID: strings.TrimSpace(sessionID), index: index}
+	if s.index < 0 {
+		return conversationSession{}, fmt.Errorf("--conversation-index must be >= 1")
+	}
+	if s.index > 0 && s.sessionID == "" {
+		return conversationSession{}, fmt.Errorf("--conversation-index requires --session")
+	}
+	return s, nil
+}
+
+func (s conversationSession) set() bool {
+	return s.sessionID != ""
+}
+
+// conversationArg reads the conversation from a command's positional args:
+// the numeric ID, or 0 when --session names it instead and resolve looks it
+// up once the DB is open.
+func (s conversationSession) conversationArg(args []string) (int64, error) {
+	switch {
+	case s.set() && len(args) > 0:
+		return 0, fmt.Errorf("pass a conversation ID or --session, not both")
+	case s.set():
+		return 0, nil
+	case len(args) != 1:
+		return 0, fmt.Errorf("conversation ID or --session is required")
+	}
+	conversationID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse conversation ID %q: %w", args[0], err)
+	}
+	return conversationID, nil
+}
+
+// resolve returns conversationID unchanged when no --session was given, and
+// otherwise the session's conversation. A session with several conversations
+// needs --conversation-index; the error lists them to choose from.
+func (s conversationSession) resolve(db *sql.DB, conversationID int64) (int64, error) {
+	if !s.set() {
+		return conversationID, nil
+	}
+	candidates, err := loadSessionConversations(db, s.sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, fmt.Errorf("no LCM conversation found for session %q", s.sessionID)
+	}
+
+	var picked sessionConversation
+	switch {
+	case s.index > len(candidates):
+		return 0, fmt.Errorf("--conversation-index %d is out of range: session %q has %d conversations\n%s",
+			s.index, s.sessionID, len(candidates), formatSessionConversations(candidates))
+	case s.index > 0:
+		picked = candidates[s.index-1]
+	case len(candidates) > 1:
+		return 0, fmt.Errorf("session %q has %d conversations; pass --conversation-index <n> or the conversation ID\n%s",
+			s.sessionID, len(candidates), formatSessionConversations(candidates))
+	default:
+		picked = candidates[0]
+	}
+	// Stderr, so --json output on stdout stays one JSON document.
+	fmt.Fprintf(os.Stderr, "Session %s: conversation %d\n", s.sessionID, picked.conversationID)
+	return picked.conversationID, nil
+}
+
+// formatSessionConversations lists a session's conversations by
+// --conversation-index, newest first.
+func formatSessionConversations(conversations []sessionConversation) string {
+	lines := make([]string, 0, len(conversations))
+	for i, c := range conversations {
+		line := fmt.Sprintf("  %d. conversation %d: %d messages", i+1, c.conversationID, c.messageCount)
+		if c.createdAt != "" {
+			line += ", created " + c.createdAt
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// setContentProvenance is stored in summaries.model for hand-written content.
+const setContentProvenance = "manual"
+
+type setContentOptions struct {
+	fromFile string
+	apply    bool
+	backupDB bool
+}
+
+// setContentPlan is a summary and the content that would replace it.
+type setContentPlan struct {
+	summaryID      string
+	conversationID int64
+	kind           string
+	depth          int
+	oldContent     string
+	oldTokens      int
+	newContent     string
+	newTokens      int
+}
+
+func (p setContentPlan) unchanged() bool {
+	return p.oldContent == p.newContent
+}
+
+// buildSetContentPlan loads summaryID and pairs it with newContent.
+func buildSetContentPlan(ctx context.Context, q sqlQueryer, summaryID, newContent string) (setContentPlan, error) {
+	plan := setContentPlan{summaryID: summaryID, newContent: newContent, newTokens: lcm.EstimateTokenCount(newContent)}
+	err := q.QueryRowContext(ctx, `
+		SELECT conversation_id, kind, COALESCE(depth, 0), content, COALESCE(token_count, 0)
+		FROM summaries
+		WHERE summary_id = ?
+	`, summaryID).Scan(&plan.conversationID, &plan.kind, &plan.depth, &plan.oldContent, &plan.oldTokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return setContentPlan{}, fmt.Errorf("summary %s not found", summaryID)
+	}
+	if err != nil {
+		return setContentPlan{}, fmt.Errorf("load summary %s: %w", summaryID, err)
+	}
+	return plan, nil
+}
+
+func printSetContentPlan(w io.Writer, plan setContentPlan, style cliOutputStyle) {
+	fmt.Fprintf(w, "Summary %s (%s, d%d, conversation %d)\n", plan.summaryID, plan.kind, plan.depth, plan.conversationID)
+	if plan.unchanged() {
+		fmt.Fprintln(w, "Content is identical to the stored summary; nothing to do.")
+		return
+	}
+	fmt.Fprintf(w, "Tokens: %d -> %d (%+d)\n", plan.oldTokens, plan.newTokens, plan.newTokens-plan.oldTokens)
+	oldChars, newChars := utf8.RuneCountInString(plan.oldContent), utf8.RuneCountInString(plan.newContent)
+	fmt.Fprintf(w, "Chars:  %d -> %d (%+d)\n\n", oldChars, newChars, newChars-oldChars)
+	diff := buildUnifiedDiff("old/"+plan.summaryID, "new/"+plan.summaryID, plan.oldContent, plan.newContent)
+	printUnifiedDiffCLI(w, diff, style)
+}
+
+// saveSummaryRevision writes the content being replaced to
+// backups/summary-<id>-<UTC timestamp>.txt so a hand edit can be undone with
+// set-content --from-file on that file.
+func saveSummaryRevision(dbPath string, plan setContentPlan) (string, error) {
+	dir := lcmBackupDir(dbPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create backup directory: %w", err)
+	}
+	base := fmt.Sprintf("summary-%s-%s", plan.summaryID, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, base+".txt")
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.txt", base, n))
+	}
+	if err := os.WriteFile(path, []byte(plan.oldContent+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("save previous content of %s: %w", plan.summaryID, err)
+	}
+	return path, nil
+}
+
+// applySetContent writes plan.newContent with provenance "manual" and an
+// audit entry naming the saved revision, in one transaction. It refuses when
+// the summary changed since the plan was built.
+func applySetContent(ctx context.Context, db *sql.DB, plan setContentPlan, revisionPath string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin set-content transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	current, err := buildSetContentPlan(ctx, tx, plan.summaryID, plan.newContent)
+	if err != nil {
+		return err
+	}
+	if current.oldContent != plan.oldContent {
+		return fmt.Errorf("summary %s changed since it was read; rerun set-content", plan.summaryID)
+	}
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		return fmt.Errorf("check summaries.model schema: %w", err)
+	}
+	update := `UPDATE summaries SET content = ?, token_count = ? WHERE summary_id = ?`
+	args := []any{plan.newContent, plan.newTokens, plan.summaryID}
+	if caps.HasColumn("summaries", "model") {
+		update = `UPDATE summaries SET content = ?, token_count = ?, model = ? WHERE summary_id = ?`
+		args = []any{plan.newContent, plan.newTokens, setContentProvenance, plan.summaryID}
+	}
+	if _, err := tx.ExecContext(ctx, update, args...); err != nil {
+		return fmt.Errorf("update summary %s: %w", plan.summaryID, err)
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "set-content", ConversationID: plan.conversationID, SummaryIDs: []string{plan.summaryID},
+		TokensBefore: plan.oldTokens, TokensAfter: plan.newTokens,
+		Detail: fmt.Sprintf("provenance %s; previous content saved to %s", setContentProvenance, revisionPath),
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit set-content of %s: %w", plan.summaryID, err)
+	}
+	rollback = false
+	return nil
+}
+
+// readSetContentInput reads --from-file, or stdin for "-". Only the trailing
+// line ending is dropped, as for prompts render inputs.
+func readSetContentInput(path string, stdin io.Reader) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(lcm.ExpandHomePath(path))
+	}
+	if err != nil {
+		return "", fmt.Errorf("read --from-file %q: %w", path, err)
+	}
+	content := strings.TrimRight(string(data), "\r\n")
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("--from-file %q is empty; refusing to blank the summary", path)
+	}
+	return content, nil
+}
+
+// runSetContentCommand executes the standalone set-content CLI path.
+func runSetContentCommand(args []string) error {
+	opts, summaryID, err := parseSetContentArgs(args)
+	if err != nil {
+		return err
+	}
+	content, err := readSetContentInput(opts.fromFile, os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildSetContentPlan(ctx, db, summaryID, content)
+	if err != nil {
+		return err
+	}
+	printSetContentPlan(os.Stdout, plan, resolveCLIOutputStyle())
+	if plan.unchanged() {
+		return nil
+	}
+	if !opts.apply {
+		fmt.Println("\nDry run. Use --apply to write this content with provenance \"manual\".")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "set-content", opts.backupDB, nil); err != nil {
+		return err
+	}
+	revisionPath, err := saveSummaryRevision(paths.lcmDBPath, plan)
+	if err != nil {
+		return err
+	}
+	if err := applySetContent(ctx, db, plan, revisionPath); err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. Updated %s (%d -> %d tokens). Previous content saved to %s. Changes take effect on next conversation turn.\n",
+		plan.summaryID, plan.oldTokens, plan.newTokens, revisionPath)
+	return nil
+}
+
+func parseSetContentArgs(args []string) (setContentOptions, string, error) {
+	fs := flag.NewFlagSet("set-content", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fromFile := fs.String("from-file", "", "file holding the replacement content (- for stdin)")
+	apply := fs.Bool("apply", false, "write the new content")
+	dryRun := fs.Bool("dry-run", true, "show the token delta and diff without writing")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalized, err := normalizeSetContentArgs(args)
+	if err != nil {
+		return setContentOptions{}, "", fmt.Errorf("%w\n%s", err, setContentUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return setContentOptions{}, "", errors.New(setContentUsageText())
+		}
+		return setContentOptions{}, "", fmt.Errorf("%w\n%s", err, setContentUsageText())
+	}
+	if fs.NArg() != 1 || strings.TrimSpace(fs.Arg(0)) == "" {
+		return setContentOptions{}, "", fmt.Errorf("summary ID is required\n%s", setContentUsageText())
+	}
+	if strings.TrimSpace(*fromFile) == "" {
+		return setContentOptions{}, "", fmt.Errorf("--from-file is required\n%s", setContentUsageText())
+	}
+	explicit := explicitFlags(fs)
+	if *apply && explicit["dry-run"] && *dryRun {
+		return setContentOptions{}, "", fmt.Errorf("--apply and --dry-run are mutually exclusive")
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return setContentOptions{}, "", err
+	}
+	return setContentOptions{fromFile: strings.TrimSpace(*fromFile), apply: *apply, backupDB: backup}, strings.TrimSpace(fs.Arg(0)), nil
+}
+
+func normalizeSetContentArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--from-file":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func setContentUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui set-content <summary_id> --from-file <path|-> [--dry-run|--apply] [--backup-db]
+
+Replace a summary's content with hand-written text from a file (or stdin with
+-). The dry run prints the old and new token and character counts and a
+unified diff. With --apply, the previous content is saved to
+backups/summary-<id>-<timestamp>.txt next to lcm.db, then the new content and
+its token count are written with provenance "manual" (summaries.model, when
+the column exists) and an audit_log entry, in one transaction. Only the
+trailing line ending of the file is dropped.
+
+Flags:
+  --from-file <path|-> replacement content; - reads stdin
+  --dry-run            show the token delta and diff without writing (default)
+  --apply              write the new content
+  --backup-db          with --apply, copy lcm.db to a timestamped backup first
+`)
+}
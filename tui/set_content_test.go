@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetContentPreviewsAndAppliesManualContent(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `ALTER TABLE summaries ADD COLUMN model TEXT`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'set-content')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at, model)
+		VALUES ('sum_a', 1, 'leaf', 0, 'line one
+line two', 4, '2026-03-01T10:00:00Z', 'claude')
+	`)
+
+	newContent := "line one\nline two, corrected by hand"
+	plan, err := buildSetContentPlan(ctx, db, "sum_a", newContent)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	var out bytes.Buffer
+	printSetContentPlan(&out, plan, cliOutputStyle{})
+	for _, want := range []string{"Tokens: 4 -> 9 (+5)", "-line two", "+line two, corrected by hand"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("preview should contain %q:\n%s", want, out.String())
+		}
+	}
+
+	t.Setenv("LCM_TUI_BACKUP_DIR", t.TempDir())
+	revision, err := saveSummaryRevision("lcm.db", plan)
+	if err != nil {
+		t.Fatalf("save revision: %v", err)
+	}
+	if saved, err := os.ReadFile(revision); err != nil || string(saved) != "line one\nline two\n" {
+		t.Fatalf("revision file = %q (%v)", saved, err)
+	}
+	if err := applySetContent(ctx, db, plan, revision); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_a' AND content = 'line one
+line two, corrected by hand' AND token_count = 9 AND model = 'manual'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'set-content' AND tokens_before = 4 AND tokens_after = 9`, 1)
+
+	if err := applySetContent(ctx, db, plan, revision); err == nil || !strings.Contains(err.Error(), "changed since it was read") {
+		t.Fatalf("a stale plan should be refused, got %v", err)
+	}
+	if _, _, err := parseSetContentArgs([]string{"sum_a"}); err == nil || !strings.Contains(err.Error(), "--from-file is required") {
+		t.Fatalf("expected --from-file to be required, got %v", err)
+	}
+	if _, err := readSetContentInput("-", strings.NewReader("\n\n")); err == nil {
+		t.Fatal("expected empty content to be refused")
+	}
+}
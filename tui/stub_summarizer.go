@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// stubSummaryProvider selects the deterministic local summarizer. It never
+// touches the network and exists for demos and tests only.
+const stubSummaryProvider = "stub"
+
+// stubSummaryMarker prefixes every stub summary so placeholder output is
+// obvious in the TUI and in the database if it is ever applied by mistake.
+const stubSummaryMarker = "[STUB SUMMARY - deterministic placeholder, not LLM-generated]"
+
+// stubSourceTags are the prompt blocks holding the text to summarize, in the
+// order the bundled templates use them.
+var stubSourceTags = []string{"conversation_segment", "conversation_to_condense"}
+
+// stubSummarizerRequested reports whether LCM_SUMMARIZER selects the stub.
+func stubSummarizerRequested() bool {
+	return normalizeProviderID(os.Getenv("LCM_SUMMARIZER")) == stubSummaryProvider
+}
+
+// resolveStubProviderFlag folds --stub into the provider flag value.
+func resolveStubProviderFlag(stub bool, provider string) (string, error) {
+	if !stub {
+		return provider, nil
+	}
+	if provider != "" && normalizeProviderID(provider) != stubSummaryProvider {
+		return "", fmt.Errorf("--stub cannot be combined with --provider %s", provider)
+	}
+	return stubSummaryProvider, nil
+}
+
+// printStubSummarizerNotice warns CLI users that summaries are placeholders.
+func printStubSummarizerNotice(provider string) {
+	if provider != stubSummaryProvider {
+		return
+	}
+	fmt.Println("Summarizer: stub (deterministic placeholder output; do not apply to a real database)")
+}
+
+// summarizeStub returns the marker followed by the prompt's source text cut
+// to roughly targetTokens. Identical input always yields identical output.
+func summarizeStub(prompt string, targetTokens int) string {
+	if targetTokens <= 0 {
+		targetTokens = lcm.CondensedTargetTokens
+	}
+	source := stubPromptSource(prompt)
+	source = strings.Join(strings.Fields(source), " ")
+	if source == "" {
+		return stubSummaryMarker
+	}
+	return stubSummaryMarker + "\n" + truncateString(source, targetTokens*4)
+}
+
+// stubPromptSource extracts the source block from a rendered prompt, falling
+// back to the whole prompt for custom templates without a known block.
+func stubPromptSource(prompt string) string {
+	for _, tag := range stubSourceTags {
+		open := "<" + tag + ">"
+		start := strings.LastIndex(prompt, open)
+		if start < 0 {
+			continue
+		}
+		body := prompt[start+len(open):]
+		if end := strings.Index(body, "</"+tag+">"); end >= 0 {
+			body = body[:end]
+		}
+		return strings.TrimSpace(body)
+	}
+	return strings.TrimSpace(prompt)
+}
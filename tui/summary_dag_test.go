@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sharedLeafGraph has two condensed summaries that both consume sum_shared.
+func sharedLeafGraph() summaryGraph {
+	return summaryGraph{
+		conversationID: 3,
+		roots:          []string{"sum_top"},
+		nodes: map[string]*summaryNode{
+			"sum_top":    {id: "sum_top", kind: "condensed", depth: 2, children: []string{"sum_a", "sum_b"}, expanded: true},
+			"sum_a":      {id: "sum_a", kind: "condensed", depth: 1, children: []string{"sum_shared", "sum_x"}, parents: []string{"sum_top"}, expanded: true},
+			"sum_b":      {id: "sum_b", kind: "condensed", depth: 1, children: []string{"sum_shared"}, parents: []string{"sum_top"}, expanded: true},
+			"sum_shared": {id: "sum_shared", kind: "leaf", parents: []string{"sum_a", "sum_b"}},
+			"sum_x":      {id: "sum_x", kind: "leaf", parents: []string{"sum_a"}},
+		},
+	}
+}
+
+func TestPopulateSummaryChildrenTracksParentsAndDedupesEdges(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lcm.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+		CREATE TABLE summaries (summary_id TEXT PRIMARY KEY, conversation_id INTEGER NOT NULL);
+		CREATE TABLE summary_parents (summary_id TEXT, parent_summary_id TEXT, ordinal INTEGER);
+		INSERT INTO summaries VALUES ('sum_a', 3), ('sum_b', 3), ('sum_shared', 3);
+		INSERT INTO summary_parents VALUES
+			('sum_a', 'sum_shared', 0),
+			('sum_a', 'sum_shared', 1),
+			('sum_b', 'sum_shared', 0);
+	`); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	nodes := map[string]*summaryNode{
+		"sum_a":      {id: "sum_a"},
+		"sum_b":      {id: "sum_b"},
+		"sum_shared": {id: "sum_shared"},
+	}
+	if _, err := populateSummaryChildren(db, 3, nodes); err != nil {
+		t.Fatalf("populate: %v", err)
+	}
+	if len(nodes["sum_a"].children) != 1 {
+		t.Fatalf("expected duplicate edge to collapse, got %v", nodes["sum_a"].children)
+	}
+	parents := append([]string(nil), nodes["sum_shared"].parents...)
+	sortSummaryIDs(parents, nodes)
+	if strings.Join(parents, ",") != "sum_a,sum_b" {
+		t.Fatalf("expected two parents, got %v", parents)
+	}
+}
+
+func TestBuildSummaryRowsMarksSharedRepeats(t *testing.T) {
+	rows := buildSummaryRows(sharedLeafGraph())
+
+	var ids []string
+	repeats := 0
+	for _, row := range rows {
+		ids = append(ids, row.summaryID)
+		if row.repeat {
+			repeats++
+			if row.summaryID != "sum_shared" {
+				t.Fatalf("unexpected repeat row %+v", row)
+			}
+		}
+	}
+	if strings.Join(ids, ",") != "sum_top,sum_a,sum_shared,sum_x,sum_b,sum_shared" || repeats != 1 {
+		t.Fatalf("unexpected rows %v (repeats %d)", ids, repeats)
+	}
+	if rows[2].repeat || !rows[5].repeat {
+		t.Fatalf("expected only the second listing to be a repeat: %+v", rows)
+	}
+}
+
+func TestExpandOnRepeatRowJumpsToFirstListing(t *testing.T) {
+	m := model{summary: sharedLeafGraph()}
+	m.summaryRows = buildSummaryRows(m.summary)
+	m.summaryCursor = 5
+
+	m.expandOrToggleSelectedSummary()
+	if m.summaryCursor != 2 {
+		t.Fatalf("expected cursor on first listing, got %d", m.summaryCursor)
+	}
+}
+
+func TestCollectSubtreeBottomUpQueuesSharedNodeOnce(t *testing.T) {
+	m := model{summary: sharedLeafGraph()}
+	queue := m.collectSubtreeBottomUp("sum_top")
+
+	var ids []string
+	for _, item := range queue {
+		ids = append(ids, item.summaryID)
+	}
+	if strings.Join(ids, ",") != "sum_shared,sum_x,sum_a,sum_b,sum_top" {
+		t.Fatalf("unexpected bottom-up order %v", ids)
+	}
+}
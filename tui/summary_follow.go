@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// summaryFollowInterval is how often follow mode reloads the summary DAG.
+const summaryFollowInterval = 2 * time.Second
+
+var summaryFlashStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("214"))
+
+// summaryFollowTickMsg drives follow-mode reloads. seq ties a tick to the
+// follow session that scheduled it so toggling F never leaves two timers.
+type summaryFollowTickMsg struct {
+	seq int
+}
+
+// summaryGraphDiff lists summary IDs that differ between two loads of the
+// same conversation's DAG.
+type summaryGraphDiff struct {
+	added   []string
+	changed []string
+	removed []string
+}
+
+func (d summaryGraphDiff) empty() bool {
+	return len(d.added) == 0 && len(d.changed) == 0 && len(d.removed) == 0
+}
+
+// tuiLaunchOptions holds flags accepted when lcm-tui starts the interactive UI.
+type tuiLaunchOptions struct {
+	follow   bool
+	markdown bool   // start with markdown-styled detail panes
+	encoding string // --encoding for session files read without an LCM conversation
+	roleMap  string // --role-map applied to session files, as in backfill
+}
+
+// parseTUILaunchArgs picks out interactive launch flags. Unrecognized
+// arguments are ignored, as they were before launch flags existed.
+func parseTUILaunchArgs(args []string) tuiLaunchOptions {
+	opts := tuiLaunchOptions{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--follow", "--follow=true":
+			opts.follow = true
+		case "--follow=false":
+			opts.follow = false
+		case "--markdown", "--markdown=true":
+			opts.markdown = true
+		case "--markdown=false":
+			opts.markdown = false
+		case "--encoding":
+			if i+1 < len(args) {
+				opts.encoding = args[i+1]
+				i++
+			}
+		case "--role-map":
+			if i+1 < len(args) {
+				opts.roleMap = args[i+1]
+				i++
+			}
+		default:
+			if value, ok := strings.CutPrefix(arg, "--encoding="); ok {
+				opts.encoding = value
+			}
+			if value, ok := strings.CutPrefix(arg, "--role-map="); ok {
+				opts.roleMap = value
+			}
+		}
+	}
+	return opts
+}
+
+func summaryFollowTickCmd(seq int) tea.Cmd {
+	return tea.Tick(summaryFollowInterval, func(time.Time) tea.Msg {
+		return summaryFollowTickMsg{seq: seq}
+	})
+}
+
+// mergeSummaryGraphRefresh carries expansion state from prev into next and
+// reports which summaries were added, changed, or removed between the loads.
+func mergeSummaryGraphRefresh(prev, next summaryGraph) summaryGraphDiff {
+	diff := summaryGraphDiff{}
+	for id, node := range next.nodes {
+		old, ok := prev.nodes[id]
+		if !ok {
+			diff.added = append(diff.added, id)
+			continue
+		}
+		node.expanded = old.expanded
+		if old.content != node.content || old.tokenCount != node.tokenCount || len(old.children) != len(node.children) {
+			diff.changed = append(diff.changed, id)
+		}
+	}
+	for id := range prev.nodes {
+		if _, ok := next.nodes[id]; !ok {
+			diff.removed = append(diff.removed, id)
+		}
+	}
+	return diff
+}
+
+// toggleSummaryFollow flips follow mode and returns the tick command that
+// starts it. Bumping the sequence retires any tick already in flight.
+func (m *model) toggleSummaryFollow() tea.Cmd {
+	m.summaryFollow = !m.summaryFollow
+	m.summaryFollowSeq++
+	m.summaryFlash = nil
+	if !m.summaryFollow {
+		m.status = "Follow mode off"
+		return nil
+	}
+	m.status = fmt.Sprintf("Follow mode on: reloading every %s", summaryFollowInterval)
+	return summaryFollowTickCmd(m.summaryFollowSeq)
+}
+
+// refreshFollowedSummaries reloads the DAG for follow mode, keeping the cursor
+// on the same summary and flashing anything new or changed since last tick.
+func (m *model) refreshFollowedSummaries() {
+	session, ok := m.currentSession()
+	if !ok {
+		return
+	}
+	summary, err := loadSummaryGraph(m.paths.lcmDBPath, session.id)
+	if err != nil {
+		m.status = "Follow reload failed: " + err.Error()
+		return
+	}
+
+	selectedID, hadSelection := m.currentSummaryID()
+	diff := mergeSummaryGraphRefresh(m.summary, summary)
+	m.summary = summary
+	m.summaryRows = buildSummaryRows(summary)
+	m.summaryCursor = clamp(m.summaryCursor, 0, len(m.summaryRows)-1)
+	if hadSelection {
+		for idx, row := range m.summaryRows {
+			if row.summaryID == selectedID {
+				m.summaryCursor = idx
+				break
+			}
+		}
+	}
+
+	m.summaryFlash = make(map[string]bool, len(diff.added)+len(diff.changed))
+	for _, id := range append(diff.added, diff.changed...) {
+		m.summaryFlash[id] = true
+		delete(m.summarySources, id)
+		delete(m.summarySourceErr, id)
+	}
+	m.loadCurrentSummarySources()
+
+	if diff.empty() {
+		return
+	}
+	m.status = fmt.Sprintf("Follow %s: %d summaries (+%d new, %d changed, -%d removed)",
+		time.Now().Format("15:04:05"),
+		len(summary.nodes),
+		len(diff.added),
+		len(diff.changed),
+		len(diff.removed))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergeSummaryGraphRefreshKeepsExpansionAndDiffs(t *testing.T) {
+	t.Parallel()
+
+	prev := summaryGraph{
+		conversationID: 7,
+		roots:          []string{"sum_root"},
+		nodes: map[string]*summaryNode{
+			"sum_root": {id: "sum_root", kind: "condensed", depth: 1, content: "root", tokenCount: 50, children: []string{"sum_a"}, expanded: true},
+			"sum_a":    {id: "sum_a", kind: "leaf", content: "leaf a", tokenCount: 20},
+			"sum_gone": {id: "sum_gone", kind: "leaf", content: "dropped", tokenCount: 10},
+		},
+	}
+	next := summaryGraph{
+		conversationID: 7,
+		roots:          []string{"sum_root"},
+		nodes: map[string]*summaryNode{
+			"sum_root": {id: "sum_root", kind: "condensed", depth: 1, content: "root", tokenCount: 50, children: []string{"sum_a", "sum_b"}},
+			"sum_a":    {id: "sum_a", kind: "leaf", content: "leaf a", tokenCount: 20},
+			"sum_b":    {id: "sum_b", kind: "leaf", content: "leaf b", tokenCount: 25},
+		},
+	}
+
+	diff := mergeSummaryGraphRefresh(prev, next)
+	if !next.nodes["sum_root"].expanded {
+		t.Fatalf("expected sum_root to stay expanded after refresh")
+	}
+	sort.Strings(diff.changed)
+	if strings.Join(diff.added, ",") != "sum_b" || strings.Join(diff.changed, ",") != "sum_root" || strings.Join(diff.removed, ",") != "sum_gone" {
+		t.Fatalf("diff = added %v changed %v removed %v, want [sum_b] [sum_root] [sum_gone]", diff.added, diff.changed, diff.removed)
+	}
+
+	if rows := buildSummaryRows(next); len(rows) != 3 {
+		t.Fatalf("visible rows = %d, want 3 with root expanded", len(rows))
+	}
+	if again := mergeSummaryGraphRefresh(next, next); !again.empty() {
+		t.Fatalf("identical reload should produce an empty diff, got %+v", again)
+	}
+}
+
+func TestParseTUILaunchArgs(t *testing.T) {
+	t.Parallel()
+
+	if opts := parseTUILaunchArgs([]string{"--follow"}); !opts.follow {
+		t.Fatalf("parse --follow = %+v, want follow enabled", opts)
+	}
+	if opts := parseTUILaunchArgs(nil); opts.follow {
+		t.Fatalf("parse no args = %+v, want follow disabled", opts)
+	}
+	if opts := parseTUILaunchArgs([]string{"--db", "/tmp/lcm.db", "--follow=false"}); opts.follow {
+		t.Fatalf("parse --follow=false = %+v, want follow disabled", opts)
+	}
+	if opts := parseTUILaunchArgs([]string{"--encoding=latin1", "--follow"}); opts.encoding != "latin1" || !opts.follow {
+		t.Fatalf("parse --encoding=latin1 = %+v, want encoding latin1", opts)
+	}
+	if opts := parseTUILaunchArgs([]string{"--encoding", "utf-16", "--follow"}); opts.encoding != "utf-16" || !opts.follow {
+		t.Fatalf("parse --encoding utf-16 = %+v, want encoding utf-16", opts)
+	}
+	if opts := parseTUILaunchArgs([]string{"--role-map=developer=user"}); opts.roleMap != "developer=user" {
+		t.Fatalf("parse --role-map = %+v, want developer=user", opts)
+	}
+	if opts := parseTUILaunchArgs([]string{"--role-map", "developer=user"}); opts.roleMap != "developer=user" {
+		t.Fatalf("parse --role-map developer=user = %+v, want developer=user", opts)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// summaryKindMismatch is a summary whose kind disagrees with its depth.
+// Rewrite, repair, and backfill branch on depth == 0 || kind == "leaf" to
+// choose source text, prompt template, and target tokens, so a leaf at d2 or
+// a condensed node at d0 is summarized with the wrong prompt.
+type summaryKindMismatch struct {
+	summaryID string
+	kind      string
+	depth     int
+}
+
+// expectedKind derives the kind from depth: 0 is a leaf, deeper is condensed.
+func (m summaryKindMismatch) expectedKind() string {
+	return expectedSummaryKind(m.depth)
+}
+
+func (m summaryKindMismatch) describe() string {
+	return fmt.Sprintf("%s: kind %q at depth %d, expected %q", m.summaryID, m.kind, m.depth, m.expectedKind())
+}
+
+func expectedSummaryKind(depth int) string {
+	if depth == 0 {
+		return "leaf"
+	}
+	return "condensed"
+}
+
+// findSummaryKindMismatches returns conversationID's summaries whose kind is
+// not the one their depth implies, ordered by depth then ID.
+func findSummaryKindMismatches(ctx context.Context, q sqlQueryer, conversationID int64) ([]summaryKindMismatch, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT summary_id, COALESCE(kind, ''), COALESCE(depth, 0)
+		FROM summaries
+		WHERE conversation_id = ?
+		  AND COALESCE(kind, '') != CASE WHEN COALESCE(depth, 0) = 0 THEN 'leaf' ELSE 'condensed' END
+		ORDER BY COALESCE(depth, 0) ASC, summary_id ASC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query summary kinds for %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	var mismatches []summaryKindMismatch
+	for rows.Next() {
+		var m summaryKindMismatch
+		if err := rows.Scan(&m.summaryID, &m.kind, &m.depth); err != nil {
+			return nil, fmt.Errorf("scan summary kind: %w", err)
+		}
+		mismatches = append(mismatches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate summary kinds: %w", err)
+	}
+	return mismatches, nil
+}
+
+func printSummaryKindMismatches(conversationID int64, mismatches []summaryKindMismatch) {
+	if len(mismatches) == 0 {
+		fmt.Printf("Conversation %d: summary kinds OK (leaf at d0, condensed above).\n", conversationID)
+		return
+	}
+	fmt.Printf("Conversation %d: %d summaries have a kind that disagrees with their depth:\n", conversationID, len(mismatches))
+	for _, m := range mismatches {
+		fmt.Printf("  %s\n", m.describe())
+	}
+}
+
+// fixSummaryKinds sets each mismatched summary's kind from its depth in one
+// transaction and returns how many were changed. Depth is trusted over kind
+// because the DAG's parent/child structure is what depth records. The set is
+// recomputed inside the transaction so the fix matches the DB it commits to.
+func fixSummaryKinds(ctx context.Context, db *sql.DB, conversationID int64) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin summary kind transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	mismatches, err := findSummaryKindMismatches(ctx, tx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range mismatches {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE summaries SET kind = ? WHERE summary_id = ?
+		`, m.expectedKind(), m.summaryID); err != nil {
+			return 0, fmt.Errorf("set kind of %s: %w", m.summaryID, err)
+		}
+	}
+	if len(mismatches) > 0 {
+		summaryIDs := make([]string, 0, len(mismatches))
+		for _, m := range mismatches {
+			summaryIDs = append(summaryIDs, m.summaryID)
+		}
+		if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+			Command: "check-context --fix", ConversationID: conversationID, SummaryIDs: summaryIDs,
+			Detail: fmt.Sprintf("reconciled kind with depth for %d summaries", len(mismatches)),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit summary kind fix: %w", err)
+	}
+	rollback = false
+	return len(mismatches), nil
+}
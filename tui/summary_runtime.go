@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +16,7 @@ type summaryRuntimeSettings struct {
 // resolveTUISummaryRuntimeSettings centralizes standalone/interactive summary
 // provider resolution so every lcm-tui summarization entrypoint honors the
 // same CLI, TUI env, legacy env, config, and provider-default precedence.
+// LCM_SUMMARIZER=stub overrides all of it so demos never reach a real API.
 func resolveTUISummaryRuntimeSettings(
 	paths appDataPaths,
 	cliProvider string,
@@ -22,6 +25,10 @@ func resolveTUISummaryRuntimeSettings(
 	defaultProvider string,
 	defaultModel string,
 ) summaryRuntimeSettings {
+	if stubSummarizerRequested() || normalizeProviderID(cliProvider) == stubSummaryProvider {
+		return summaryRuntimeSettings{provider: stubSummaryProvider, model: stubSummaryProvider}
+	}
+
 	providerHint := firstNonEmptyString(
 		cliProvider,
 		os.Getenv("LCM_TUI_SUMMARY_PROVIDER"),
@@ -57,3 +64,53 @@ func firstNonEmptyString(values ...string) string {
 	}
 	return ""
 }
+
+// summaryGenerationSettings are optional overrides for summary requests. A
+// nil temperature leaves the provider default in place, and a zero
+// maxOutputTokens derives the output ceiling from each call's target.
+type summaryGenerationSettings struct {
+	temperature     *float64
+	maxOutputTokens int
+}
+
+// resolveSummaryGenerationSettings applies --temperature and
+// --max-output-tokens over LCM_TUI_SUMMARY_TEMPERATURE and
+// LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS. Empty and zero values mean "not set".
+func resolveSummaryGenerationSettings(cliTemperature string, cliMaxOutputTokens int) (summaryGenerationSettings, error) {
+	var settings summaryGenerationSettings
+	if value := firstNonEmptyString(cliTemperature, os.Getenv("LCM_TUI_SUMMARY_TEMPERATURE")); value != "" {
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return summaryGenerationSettings{}, fmt.Errorf("temperature must be a number between 0 and 2, got %q", value)
+		}
+		settings.temperature = &temperature
+	}
+
+	settings.maxOutputTokens = cliMaxOutputTokens
+	if settings.maxOutputTokens == 0 {
+		if value := strings.TrimSpace(os.Getenv("LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS")); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return summaryGenerationSettings{}, fmt.Errorf("LCM_TUI_SUMMARY_MAX_OUTPUT_TOKENS must be an integer, got %q", value)
+			}
+			settings.maxOutputTokens = parsed
+		}
+	}
+	if settings.maxOutputTokens < 0 {
+		return summaryGenerationSettings{}, fmt.Errorf("max output tokens must be >= 0, got %d", settings.maxOutputTokens)
+	}
+	return settings, nil
+}
+
+// checkProvider rejects a temperature the resolved provider would refuse.
+// Anthropic accepts 0-1; the OpenAI-compatible providers accept 0-2, which
+// resolveSummaryGenerationSettings already enforces.
+func (s summaryGenerationSettings) checkProvider(provider string) error {
+	if s.temperature == nil {
+		return nil
+	}
+	if normalizeProviderID(provider) == "anthropic" && *s.temperature > 1 {
+		return fmt.Errorf("temperature must be between 0 and 1 for provider anthropic, got %g", *s.temperature)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// addSummaryTokenRangeFlags registers --min-tokens and --max-tokens on fs;
+// verb completes "only <verb> summaries with token_count >= n".
+func addSummaryTokenRangeFlags(fs *flag.FlagSet, verb string) (*int, *int) {
+	minTokens := fs.Int("min-tokens", 0, "only "+verb+" summaries with token_count >= n")
+	maxTokens := fs.Int("max-tokens", 0, "only "+verb+" summaries with token_count <= n")
+	return minTokens, maxTokens
+}
+
+// validateSummaryTokenRange checks the --min-tokens / --max-tokens values.
+func validateSummaryTokenRange(r lcm.TokenRange) error {
+	if r.Min < 0 {
+		return fmt.Errorf("--min-tokens must be >= 0")
+	}
+	if r.Max < 0 {
+		return fmt.Errorf("--max-tokens must be >= 0")
+	}
+	if r.Max > 0 && r.Min > r.Max {
+		return fmt.Errorf("--min-tokens must be <= --max-tokens")
+	}
+	return nil
+}
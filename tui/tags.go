@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// conversationTagsSchema is owned by lcm-tui, not the plugin: tags are an
+// organizational layer and never affect compaction or assembly. The table is
+// created on the first tag write; readers treat a missing table as no tags.
+const conversationTagsSchema = `
+	CREATE TABLE IF NOT EXISTS conversation_tags (
+		conversation_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (conversation_id, tag)
+	)
+`
+
+const maxConversationTagLength = 64
+
+type tagOptions struct {
+	list     bool
+	backupDB bool // copy lcm.db before tags are changed
+}
+
+// normalizeConversationTag lowercases tag and rejects anything outside
+// [a-z0-9._-], so tags stay safe to print and to pass on the command line.
+func normalizeConversationTag(raw string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(raw))
+	if tag == "" {
+		return "", errors.New("tag must not be empty")
+	}
+	if len(tag) > maxConversationTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", raw, maxConversationTagLength)
+	}
+	for _, r := range tag {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return "", fmt.Errorf("tag %q may only contain letters, digits, '-', '_', and '.'", raw)
+		}
+	}
+	return tag, nil
+}
+
+func ensureConversationTagsTable(ctx context.Context, q sqlQueryer) error {
+	if _, err := q.ExecContext(ctx, conversationTagsSchema); err != nil {
+		return fmt.Errorf("create conversation_tags: %w", err)
+	}
+	lcm.ForgetSchemaCapabilities()
+	return nil
+}
+
+// addConversationTags tags conversationID, returning how many tags were new.
+// New tags are recorded in the audit log; run it in a transaction so the
+// entry commits with them.
+func addConversationTags(ctx context.Context, q sqlQueryer, conversationID int64, tags []string) (int, error) {
+	exists, err := lcm.ConversationExists(ctx, q, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("conversation %d not found", conversationID)
+	}
+	if err := ensureConversationTagsTable(ctx, q); err != nil {
+		return 0, err
+	}
+	var changed []string
+	for _, tag := range tags {
+		res, err := q.ExecContext(ctx, `
+			INSERT OR IGNORE INTO conversation_tags (conversation_id, tag) VALUES (?, ?)
+		`, conversationID, tag)
+		if err != nil {
+			return 0, fmt.Errorf("tag conversation %d with %q: %w", conversationID, tag, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			changed = append(changed, tag)
+		}
+	}
+	if len(changed) > 0 {
+		if err := lcm.RecordAudit(ctx, q, lcm.AuditEntry{
+			Command: "tag", ConversationID: conversationID,
+			Detail: "added " + formatConversationTags(changed),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(changed), nil
+}
+
+// removeConversationTags untags conversationID, returning how many tags were
+// removed. Like addConversationTags it records the change in the audit log.
+func removeConversationTags(ctx context.Context, q sqlQueryer, conversationID int64, tags []string) (int, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("check conversation_tags table: %w", err)
+	}
+	if !caps.HasTable("conversation_tags") {
+		return 0, nil
+	}
+	var changed []string
+	for _, tag := range tags {
+		res, err := q.ExecContext(ctx, `
+			DELETE FROM conversation_tags WHERE conversation_id = ? AND tag = ?
+		`, conversationID, tag)
+		if err != nil {
+			return 0, fmt.Errorf("untag %q from conversation %d: %w", tag, conversationID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			changed = append(changed, tag)
+		}
+	}
+	if len(changed) > 0 {
+		if err := lcm.RecordAudit(ctx, q, lcm.AuditEntry{
+			Command: "untag", ConversationID: conversationID,
+			Detail: "removed " + formatConversationTags(changed),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(changed), nil
+}
+
+// loadConversationTags returns each listed conversation's tags, sorted. A DB
+// that was never tagged yields an empty map.
+func loadConversationTags(ctx context.Context, q sqlQueryer, conversationIDs []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return tags, nil
+	}
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return tags, fmt.Errorf("check conversation_tags table: %w", err)
+	}
+	if !caps.HasTable("conversation_tags") {
+		return tags, nil
+	}
+
+	args := make([]any, len(conversationIDs))
+	for i, id := range conversationIDs {
+		args[i] = id
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT conversation_id, tag
+		FROM conversation_tags
+		WHERE conversation_id IN (`+lcm.SQLPlaceholders(len(conversationIDs))+`)
+		ORDER BY conversation_id ASC, tag ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query conversation tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID int64
+		var tag string
+		if err := rows.Scan(&conversationID, &tag); err != nil {
+			return nil, fmt.Errorf("scan conversation tag: %w", err)
+		}
+		tags[conversationID] = append(tags[conversationID], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate conversation tags: %w", err)
+	}
+	return tags, nil
+}
+
+// conversationTagUsage is one tag and the conversations carrying it.
+type conversationTagUsage struct {
+	tag             string
+	conversationIDs []int64
+}
+
+func loadConversationTagUsage(ctx context.Context, q sqlQueryer) ([]conversationTagUsage, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("check conversation_tags table: %w", err)
+	}
+	if !caps.HasTable("conversation_tags") {
+		return nil, nil
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT tag, conversation_id FROM conversation_tags ORDER BY tag ASC, conversation_id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query tag usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []conversationTagUsage
+	for rows.Next() {
+		var tag string
+		var conversationID int64
+		if err := rows.Scan(&tag, &conversationID); err != nil {
+			return nil, fmt.Errorf("scan tag usage: %w", err)
+		}
+		if len(usage) == 0 || usage[len(usage)-1].tag != tag {
+			usage = append(usage, conversationTagUsage{tag: tag})
+		}
+		last := &usage[len(usage)-1]
+		last.conversationIDs = append(last.conversationIDs, conversationID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tag usage: %w", err)
+	}
+	return usage, nil
+}
+
+// formatConversationTags renders tags for list rows and headers.
+func formatConversationTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+// runTagCommand executes `lcm-tui tag` and, with remove set, `lcm-tui untag`.
+func runTagCommand(args []string, remove bool) error {
+	command := "tag"
+	if remove {
+		command = "untag"
+	}
+	opts, conversationID, tags, err := parseTagArgs(command, args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if opts.list {
+		usage, err := loadConversationTagUsage(ctx, db)
+		if err != nil {
+			return err
+		}
+		if len(usage) == 0 {
+			fmt.Println("No conversations are tagged.")
+			return nil
+		}
+		table := cliTable{columns: []cliTableColumn{
+			{header: "tag"},
+			{header: "convs", align: cliAlignRight},
+			{header: "conversation_ids", flex: true},
+		}}
+		for _, u := range usage {
+			ids := make([]string, 0, len(u.conversationIDs))
+			for _, id := range u.conversationIDs {
+				ids = append(ids, strconv.FormatInt(id, 10))
+			}
+			table.addRow(u.tag, strconv.Itoa(len(u.conversationIDs)), strings.Join(ids, ","))
+		}
+		for _, line := range table.render(resolveCLIOutputStyle()) {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	if len(tags) > 0 {
+		if err := backupBeforeApply(ctx, db, paths.lcmDBPath, command, opts.backupDB, nil); err != nil {
+			return err
+		}
+		if err := applyTagChange(ctx, db, conversationID, tags, remove); err != nil {
+			return err
+		}
+	}
+
+	current, err := loadConversationTags(ctx, db, []int64{conversationID})
+	if err != nil {
+		return err
+	}
+	if len(current[conversationID]) == 0 {
+		fmt.Printf("Conversation %d has no tags.\n", conversationID)
+		return nil
+	}
+	fmt.Printf("Conversation %d tags: %s\n", conversationID, formatConversationTags(current[conversationID]))
+	return nil
+}
+
+// applyTagChange adds or removes tags and their audit entry in one
+// transaction, then reports how many changed.
+func applyTagChange(ctx context.Context, db *sql.DB, conversationID int64, tags []string, remove bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tag transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var changed int
+	if remove {
+		changed, err = removeConversationTags(ctx, tx, conversationID, tags)
+	} else {
+		changed, err = addConversationTags(ctx, tx, conversationID, tags)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tags for conversation %d: %w", conversationID, err)
+	}
+	rollback = false
+	if remove {
+		fmt.Printf("Removed %d of %d tags from conversation %d.\n", changed, len(tags), conversationID)
+	} else {
+		fmt.Printf("Added %d of %d tags to conversation %d.\n", changed, len(tags), conversationID)
+	}
+	return nil
+}
+
+func parseTagArgs(command string, args []string) (tagOptions, int64, []string, error) {
+	usage := tagUsageText()
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	list := fs.Bool("list", false, "list every tag with its conversations")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before changing tags")
+
+	if err := fs.Parse(normalizePruneArgs(args)); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return tagOptions{}, 0, nil, errors.New(usage)
+		}
+		return tagOptions{}, 0, nil, fmt.Errorf("%w\n%s", err, usage)
+	}
+	if *list {
+		if command != "tag" || fs.NArg() != 0 {
+			return tagOptions{}, 0, nil, fmt.Errorf("--list takes no arguments and is only valid for tag\n%s", usage)
+		}
+		return tagOptions{list: true}, 0, nil, nil
+	}
+	if fs.NArg() < 1 {
+		return tagOptions{}, 0, nil, fmt.Errorf("conversation ID is required\n%s", usage)
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return tagOptions{}, 0, nil, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), usage)
+	}
+	if command == "untag" && fs.NArg() < 2 {
+		return tagOptions{}, 0, nil, fmt.Errorf("at least one tag is required\n%s", usage)
+	}
+
+	seen := make(map[string]bool)
+	tags := make([]string, 0, fs.NArg()-1)
+	for _, raw := range fs.Args()[1:] {
+		tag, err := normalizeConversationTag(raw)
+		if err != nil {
+			return tagOptions{}, 0, nil, fmt.Errorf("%w\n%s", err, usage)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return tagOptions{}, 0, nil, fmt.Errorf("%w\n%s", err, usage)
+	}
+	return tagOptions{backupDB: backup}, conversationID, tags, nil
+}
+
+func tagUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui tag <conversation_id> <tag> [<tag>...]
+  lcm-tui tag <conversation_id>
+  lcm-tui tag --list
+  lcm-tui untag <conversation_id> <tag> [<tag>...]
+
+Tags organize conversations for triage (e.g. keep, archive, needs-repair).
+They are stored in a conversation_tags table that lcm-tui creates on first
+use and never affect compaction or context assembly. Tags are lowercased and
+may contain letters, digits, '-', '_', and '.'. With only a conversation ID,
+tag prints its tags.
+
+Flags:
+  --list        list every tag with the conversations carrying it
+  --backup-db   back up lcm.db before changing tags
+`)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConversationTagsAddRemoveAndLoad(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-a', 'A')`)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id, title) VALUES (2, 'session-b', 'B')`)
+
+	tags, err := loadConversationTags(ctx, db, []int64{1, 2})
+	if err != nil || len(tags) != 0 {
+		t.Fatalf("expected no tags before the table exists, got %v (%v)", tags, err)
+	}
+
+	added, err := addConversationTags(ctx, db, 1, []string{"archive", "keep"})
+	if err != nil || added != 2 {
+		t.Fatalf("add tags: added=%d err=%v", added, err)
+	}
+	if added, err = addConversationTags(ctx, db, 1, []string{"keep"}); err != nil || added != 0 {
+		t.Fatalf("re-adding a tag should be a no-op: added=%d err=%v", added, err)
+	}
+	if _, err := addConversationTags(ctx, db, 2, []string{"keep"}); err != nil {
+		t.Fatalf("tag conversation 2: %v", err)
+	}
+	if _, err := addConversationTags(ctx, db, 99, []string{"keep"}); err == nil {
+		t.Fatal("expected an error tagging a missing conversation")
+	}
+
+	removed, err := removeConversationTags(ctx, db, 1, []string{"archive", "missing"})
+	if err != nil || removed != 1 {
+		t.Fatalf("remove tags: removed=%d err=%v", removed, err)
+	}
+
+	tags, err = loadConversationTags(ctx, db, []int64{1, 2})
+	if err != nil {
+		t.Fatalf("load tags: %v", err)
+	}
+	if !reflect.DeepEqual(tags, map[int64][]string{1: {"keep"}, 2: {"keep"}}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	// Only calls that changed something are audited.
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'tag'`, 2)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'tag' AND conversation_id = 1 AND detail = 'added #archive #keep'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'untag' AND conversation_id = 1 AND detail = 'removed #archive'`, 1)
+	usage, err := loadConversationTagUsage(ctx, db)
+	if err != nil || len(usage) != 1 || !reflect.DeepEqual(usage[0].conversationIDs, []int64{1, 2}) {
+		t.Fatalf("unexpected tag usage %+v (%v)", usage, err)
+	}
+}
+
+func TestParseTagArgsNormalizesTags(t *testing.T) {
+	_, conversationID, tags, err := parseTagArgs("tag", []string{"7", "Keep", "needs-repair", "keep"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if conversationID != 7 || !reflect.DeepEqual(tags, []string{"keep", "needs-repair"}) {
+		t.Fatalf("unexpected parse result %d %v", conversationID, tags)
+	}
+	if _, _, _, err := parseTagArgs("tag", []string{"7", "has space"}); err == nil {
+		t.Fatal("expected invalid tag to be rejected")
+	}
+	if _, _, _, err := parseTagArgs("untag", []string{"7"}); err == nil {
+		t.Fatal("expected untag without tags to be rejected")
+	}
+	opts, _, _, err := parseTagArgs("tag", []string{"--list"})
+	if err != nil || !opts.list {
+		t.Fatalf("expected --list, got %+v (%v)", opts, err)
+	}
+}
+
+func TestSessionTagFilterCyclesAndSkipsUntagged(t *testing.T) {
+	t.Parallel()
+
+	m := model{
+		width:  180,
+		height: 10,
+		screen: screenSessions,
+		sessions: []sessionEntry{
+			{id: "untagged", updatedAt: time.Unix(1700000000, 0)},
+			{id: "kept", updatedAt: time.Unix(1700000000, 0), tags: []string{"keep"}},
+			{id: "archived", updatedAt: time.Unix(1700000000, 0), tags: []string{"archive", "keep"}},
+		},
+	}
+
+	m.cycleSessionTagFilter()
+	if m.sessionTagFilter != "archive" || m.sessionCursor != 2 {
+		t.Fatalf("expected archive filter on session 2, got %q at %d", m.sessionTagFilter, m.sessionCursor)
+	}
+	m.cycleSessionTagFilter()
+	if m.sessionTagFilter != "keep" {
+		t.Fatalf("expected keep filter, got %q", m.sessionTagFilter)
+	}
+	m.moveFilteredSessionCursor(-1)
+	if m.sessionCursor != 1 {
+		t.Fatalf("expected cursor on the kept session, got %d", m.sessionCursor)
+	}
+	m.moveFilteredSessionCursor(-1)
+	if m.sessionCursor != 1 {
+		t.Fatalf("expected cursor to stay on the first match, got %d", m.sessionCursor)
+	}
+
+	rendered := m.renderSessions()
+	if strings.Contains(rendered, "untagged") || !strings.Contains(rendered, "#archive #keep") {
+		t.Fatalf("unexpected filtered sessions:\n%s", rendered)
+	}
+
+	m.cycleSessionTagFilter()
+	if m.sessionTagFilter != "" || len(strings.Split(m.renderSessions(), "\n")) != 3 {
+		t.Fatalf("expected the filter to clear, got %q", m.sessionTagFilter)
+	}
+}
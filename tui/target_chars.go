@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// charTargetTolerance is how far a --target-chars result may miss before it
+// counts as wildly off and is re-requested: outside 50%-150% of the target.
+const charTargetTolerance = 0.5
+
+// charTarget is --target-chars/--char-retries for rewrite and repair: aim a
+// summary at a character count instead of the usual token target, for
+// consumers with fixed-width fields or character limits. The zero value
+// keeps token targeting.
+type charTarget struct {
+	chars   int
+	retries int
+}
+
+func newCharTarget(chars, retries int) (charTarget, error) {
+	if chars < 0 {
+		return charTarget{}, fmt.Errorf("--target-chars must be >= 0")
+	}
+	if retries < 0 {
+		return charTarget{}, fmt.Errorf("--char-retries must be >= 0")
+	}
+	return charTarget{chars: chars, retries: retries}, nil
+}
+
+func (c charTarget) set() bool {
+	return c.chars > 0
+}
+
+// tokens is the token target the call is sized for, using the same chars/4
+// estimate as token_count.
+func (c charTarget) tokens() int {
+	return max(1, (c.chars+3)/4)
+}
+
+// check reports a result outside charTargetTolerance of the target.
+func (c charTarget) check(content string) error {
+	if !c.set() {
+		return nil
+	}
+	chars := utf8.RuneCountInString(content)
+	low := int(float64(c.chars) * (1 - charTargetTolerance))
+	high := int(float64(c.chars) * (1 + charTargetTolerance))
+	if chars < low || chars > high {
+		return fmt.Errorf("result is %d chars, target %d (accepted %d-%d)", chars, c.chars, low, high)
+	}
+	return nil
+}
+
+// summarizer wraps summarize so that a result wildly off the character
+// target is re-requested up to c.retries times, with the previous answer and
+// its length appended to the prompt. When retries run out it warns and returns the
+// last attempt, like summarizeCondensedRepair. Without a target it returns
+// summarize unchanged.
+func (c charTarget) summarizer(w io.Writer, summarize func(context.Context, string, int) (string, error)) func(context.Context, string, int) (string, error) {
+	if !c.set() {
+		return summarize
+	}
+	return func(ctx context.Context, prompt string, targetTokens int) (string, error) {
+		attemptPrompt := prompt
+		var content string
+		var lengthErr error
+		for attempt := 0; attempt <= c.retries; attempt++ {
+			var err error
+			content, err = summarize(ctx, attemptPrompt, targetTokens)
+			if err != nil {
+				return "", err
+			}
+			lengthErr = c.check(content)
+			if lengthErr == nil {
+				return content, nil
+			}
+			if attempt < c.retries {
+				fmt.Fprintf(w, "Length off target (%v); retrying (%d/%d)\n", lengthErr, attempt+1, c.retries)
+				attemptPrompt = fmt.Sprintf("%s\n\nA previous answer was %d characters. Rewrite it to about %d characters.\n\nPrevious answer:\n%s",
+					prompt, utf8.RuneCountInString(content), c.chars, content)
+			}
+		}
+		fmt.Fprintf(w, "WARNING: keeping a summary off its character target (%v)\n", lengthErr)
+		return content, nil
+	}
+}
+
+// describeSummarySize is "N chars / M tokens" for a generated summary.
+func describeSummarySize(content string) string {
+	return fmt.Sprintf("%d chars / %d tokens", utf8.RuneCountInString(content), lcm.EstimateTokenCount(content))
+}
+
+// targetLengthPhrase fills "Target length: ..." in the built-in repair
+// prompts.
+func targetLengthPhrase(targetTokens int, target charTarget) string {
+	if target.set() {
+		return fmt.Sprintf("about %d characters", target.chars)
+	}
+	return fmt.Sprintf("about %d tokens", targetTokens)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+func TestCharTargetRetriesOffTargetResults(t *testing.T) {
+	target, err := newCharTarget(100, 1)
+	if err != nil {
+		t.Fatalf("new char target: %v", err)
+	}
+	if target.tokens() != 25 {
+		t.Fatalf("tokens() = %d, want 25", target.tokens())
+	}
+
+	var prompts []string
+	replies := []string{strings.Repeat("x", 400), strings.Repeat("y", 110)}
+	summarize := func(_ context.Context, prompt string, targetTokens int) (string, error) {
+		prompts = append(prompts, prompt)
+		if targetTokens != 25 {
+			t.Fatalf("call sized for %d tokens, want 25", targetTokens)
+		}
+		reply := replies[0]
+		replies = replies[1:]
+		return reply, nil
+	}
+	var out bytes.Buffer
+	content, err := target.summarizer(&out, summarize)(context.Background(), "prompt", target.tokens())
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if len(content) != 110 || len(prompts) != 2 {
+		t.Fatalf("expected one retry ending at 110 chars, got %d chars after %d calls", len(content), len(prompts))
+	}
+	if !strings.Contains(prompts[1], "A previous answer was 400 characters. Rewrite it to about 100 characters.") {
+		t.Fatalf("retry prompt should note the previous length, got %q", prompts[1])
+	}
+	if !strings.HasSuffix(prompts[1], "Previous answer:\n"+strings.Repeat("x", 400)) {
+		t.Fatalf("retry prompt should include the rejected answer, got %q", prompts[1])
+	}
+	if !strings.Contains(out.String(), "retrying (1/1)") {
+		t.Fatalf("expected a retry line, got %q", out.String())
+	}
+
+	out.Reset()
+	replies = []string{"short", "still short"}
+	content, err = target.summarizer(&out, summarize)(context.Background(), "prompt", target.tokens())
+	if err != nil || content != "still short" || !strings.Contains(out.String(), "WARNING: keeping a summary off its character target") {
+		t.Fatalf("expected the last attempt with a warning, got %q (%v), output %q", content, err, out.String())
+	}
+	if describeSummarySize("ünïcode!") != "8 chars / 2 tokens" {
+		t.Fatalf("describeSummarySize counts characters, got %q", describeSummarySize("ünïcode!"))
+	}
+	if _, err := newCharTarget(-1, 1); err == nil {
+		t.Fatal("expected an error for a negative --target-chars")
+	}
+}
+
+func TestTargetCharsReplacesTokenTargetInPrompts(t *testing.T) {
+	for depth := 0; depth <= 3; depth++ {
+		prompt, err := lcm.RenderPrompt(depth, lcm.PromptVars{TargetTokens: 400, TargetChars: 800, SourceText: "source"}, "")
+		if err != nil {
+			t.Fatalf("render depth %d: %v", depth, err)
+		}
+		if !strings.Contains(prompt, "about 800 characters") || strings.Contains(prompt, "400 tokens") {
+			t.Fatalf("depth %d prompt should ask for characters only:\n%s", depth, prompt)
+		}
+		prompt, err = lcm.RenderPrompt(depth, lcm.PromptVars{TargetTokens: 400, SourceText: "source"}, "")
+		if err != nil || !strings.Contains(prompt, "about 400 tokens") {
+			t.Fatalf("depth %d prompt should keep the token target by default (%v)", depth, err)
+		}
+	}
+
+	prompt, tokens := buildRepairPrompt("leaf", "text", "", 10000, charTarget{chars: 280, retries: 1})
+	if tokens != 70 || !strings.Contains(prompt, "Target length: about 280 characters or less.") {
+		t.Fatalf("repair leaf prompt = %d tokens:\n%s", tokens, prompt)
+	}
+	prompt, tokens = buildRepairPrompt("condensed", "text", "", 10000, charTarget{})
+	if tokens != lcm.CondensedTargetTokens || !strings.Contains(prompt, "Target length: about 2000 tokens.") {
+		t.Fatalf("repair condensed prompt without --target-chars = %d tokens:\n%s", tokens, prompt)
+	}
+
+	rewriteOpts, _, err := parseRewriteArgs([]string{"44", "--all", "--target-chars", "280", "--char-retries=2"})
+	if err != nil || rewriteOpts.charTarget != (charTarget{chars: 280, retries: 2}) {
+		t.Fatalf("rewrite --target-chars = %+v (%v)", rewriteOpts.charTarget, err)
+	}
+	repairOpts, _, err := parseRepairArgs([]string{"44", "--target-chars=280"})
+	if err != nil || repairOpts.charTarget != (charTarget{chars: 280, retries: 1}) {
+		t.Fatalf("repair --target-chars = %+v (%v)", repairOpts.charTarget, err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// summaryTimeRangeFix compares a summary's stored earliest_at/latest_at with
+// the range recomputed from the leaf messages beneath it.
+type summaryTimeRangeFix struct {
+	summaryID        string
+	conversationID   int64
+	storedEarliest   string
+	storedLatest     string
+	computedEarliest string
+	computedLatest   string
+}
+
+// computed reports whether any leaf messages were found under the summary.
+func (f summaryTimeRangeFix) computed() bool {
+	return f.computedEarliest != "" && f.computedLatest != ""
+}
+
+// changed reports whether applying the fix would alter stored metadata.
+func (f summaryTimeRangeFix) changed() bool {
+	return f.computed() && (f.storedEarliest != f.computedEarliest || f.storedLatest != f.computedLatest)
+}
+
+// buildSummaryTimeRangeFix recomputes one summary's time range with the same
+// recursive leaf walk rewrite uses for prompt timestamps.
+func buildSummaryTimeRangeFix(ctx context.Context, db *sql.DB, summaryID string) (summaryTimeRangeFix, error) {
+	caps, err := lcm.SchemaCapabilitiesOf(ctx, db)
+	if err != nil {
+		return summaryTimeRangeFix{}, fmt.Errorf("check summaries schema: %w", err)
+	}
+	for _, column := range []string{"earliest_at", "latest_at"} {
+		if !caps.HasColumn("summaries", column) {
+			return summaryTimeRangeFix{}, fmt.Errorf("summaries.%s column is missing; run the plugin once to migrate the schema", column)
+		}
+	}
+
+	fix := summaryTimeRangeFix{summaryID: summaryID}
+	err = db.QueryRowContext(ctx, `
+		SELECT conversation_id, COALESCE(earliest_at, ''), COALESCE(latest_at, '')
+		FROM summaries
+		WHERE summary_id = ?
+	`, summaryID).Scan(&fix.conversationID, &fix.storedEarliest, &fix.storedLatest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return summaryTimeRangeFix{}, fmt.Errorf("summary %s not found", summaryID)
+	}
+	if err != nil {
+		return summaryTimeRangeFix{}, fmt.Errorf("query stored time range for %s: %w", summaryID, err)
+	}
+	fix.storedEarliest = strings.TrimSpace(fix.storedEarliest)
+	fix.storedLatest = strings.TrimSpace(fix.storedLatest)
+
+	fix.computedEarliest, fix.computedLatest, err = lcm.SummaryLeafTimeRange(ctx, db, summaryID)
+	if err != nil {
+		return summaryTimeRangeFix{}, fmt.Errorf("derive time range for %s: %w", summaryID, err)
+	}
+	return fix, nil
+}
+
+// applySummaryTimeRangeFix writes the recomputed range to the summary and
+// records the old and new ranges in the audit log, in one transaction.
+func applySummaryTimeRangeFix(ctx context.Context, db *sql.DB, fix summaryTimeRangeFix) error {
+	if !fix.computed() {
+		return fmt.Errorf("no leaf messages found under %s", fix.summaryID)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin time range transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE summaries
+		SET earliest_at = ?, latest_at = ?
+		WHERE summary_id = ?
+	`, fix.computedEarliest, fix.computedLatest, fix.summaryID)
+	if err != nil {
+		return fmt.Errorf("update time range for %s: %w", fix.summaryID, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read affected rows for %s: %w", fix.summaryID, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("summary %s not found", fix.summaryID)
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "time-range", ConversationID: fix.conversationID, SummaryIDs: []string{fix.summaryID},
+		Detail: fmt.Sprintf("earliest_at/latest_at %s => %s",
+			formatStoredTimeRange(fix.storedEarliest, fix.storedLatest),
+			formatStoredTimeRange(fix.computedEarliest, fix.computedLatest)),
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit time range for %s: %w", fix.summaryID, err)
+	}
+	rollback = false
+	return nil
+}
+
+// formatStoredTimeRange renders a raw stored range for the confirmation
+// overlay, marking unset values explicitly.
+func formatStoredTimeRange(earliest, latest string) string {
+	if earliest == "" && latest == "" {
+		return "(unset)"
+	}
+	if earliest == "" {
+		earliest = "(unset)"
+	}
+	if latest == "" {
+		latest = "(unset)"
+	}
+	return earliest + " -> " + latest
+}
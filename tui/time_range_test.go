@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSummaryTimeRangeFixRecomputesFromLeaves(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id, title) VALUES (1, 'session-range', 'Range')
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at)
+		VALUES
+			(1, 1, 1, 'user', 'a', 1, '2026-03-01 09:00:00'),
+			(2, 1, 2, 'assistant', 'b', 1, '2026-03-01 10:00:00'),
+			(3, 1, 3, 'user', 'c', 1, '2026-03-02 08:30:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, earliest_at, latest_at, created_at)
+		VALUES
+			('sum_leaf_a', 1, 'leaf', 0, 'a', 1, '2026-03-01 09:00:00', '2026-03-01 10:00:00', '2026-03-01 10:00:00'),
+			('sum_leaf_b', 1, 'leaf', 0, 'b', 1, '2026-03-02 08:30:00', '2026-03-02 08:30:00', '2026-03-02 08:30:00'),
+			('sum_top', 1, 'condensed', 1, 'top', 1, '2026-02-01 00:00:00', NULL, '2026-03-02 09:00:00'),
+			('sum_empty', 1, 'leaf', 0, 'none', 1, NULL, NULL, '2026-03-02 09:00:00')
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal)
+		VALUES ('sum_leaf_a', 1, 0), ('sum_leaf_a', 2, 1), ('sum_leaf_b', 3, 0)
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_parents (summary_id, parent_summary_id, ordinal)
+		VALUES ('sum_top', 'sum_leaf_a', 0), ('sum_top', 'sum_leaf_b', 1)
+	`)
+
+	leaf, err := buildSummaryTimeRangeFix(ctx, db, "sum_leaf_a")
+	if err != nil {
+		t.Fatalf("build leaf fix: %v", err)
+	}
+	if leaf.changed() {
+		t.Fatalf("expected matching leaf range, got %+v", leaf)
+	}
+
+	top, err := buildSummaryTimeRangeFix(ctx, db, "sum_top")
+	if err != nil {
+		t.Fatalf("build condensed fix: %v", err)
+	}
+	if !top.changed() || top.computedEarliest != "2026-03-01 09:00:00" || top.computedLatest != "2026-03-02 08:30:00" {
+		t.Fatalf("unexpected condensed fix %+v", top)
+	}
+	if err := applySummaryTimeRangeFix(ctx, db, top); err != nil {
+		t.Fatalf("apply fix: %v", err)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE summary_id = 'sum_top' AND earliest_at = '2026-03-01 09:00:00' AND latest_at = '2026-03-02 08:30:00'`, 1)
+	assertCount(t, db, `
+		SELECT COUNT(*) FROM audit_log
+		WHERE command = 'time-range' AND conversation_id = 1 AND summary_ids LIKE '%sum_top%'
+		  AND detail = 'earliest_at/latest_at 2026-02-01 00:00:00 -> (unset) => 2026-03-01 09:00:00 -> 2026-03-02 08:30:00'
+	`, 1)
+
+	empty, err := buildSummaryTimeRangeFix(ctx, db, "sum_empty")
+	if err != nil {
+		t.Fatalf("build empty fix: %v", err)
+	}
+	if empty.computed() || empty.changed() {
+		t.Fatalf("expected no computed range without leaf messages, got %+v", empty)
+	}
+	if err := applySummaryTimeRangeFix(ctx, db, empty); err == nil {
+		t.Fatal("expected apply to refuse an empty range")
+	}
+
+	if _, err := buildSummaryTimeRangeFix(ctx, db, "sum_missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
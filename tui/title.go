@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type titleOptions struct {
+	title    string
+	backupDB bool // copy lcm.db before the title is written
+}
+
+// runTitleCommand sets conversations.title for one conversation.
+func runTitleCommand(args []string) error {
+	opts, conversationID, err := parseTitleArgs(args)
+	if err != nil {
+		return err
+	}
+	title := opts.title
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	previous, err := loadConversationTitle(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "title", opts.backupDB, nil); err != nil {
+		return err
+	}
+	if err := setConversationTitle(ctx, db, conversationID, title); err != nil {
+		return err
+	}
+	fmt.Printf("Conversation %d title: %q (was %q)\n", conversationID, title, previous)
+	return nil
+}
+
+// normalizeConversationTitle collapses whitespace so titles stay on one line
+// in headers and lists.
+func normalizeConversationTitle(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// loadConversationTitle returns the stored title for conversationID.
+func loadConversationTitle(ctx context.Context, q sqlQueryer, conversationID int64) (string, error) {
+	var title string
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(title, '') FROM conversations WHERE conversation_id = ?
+	`, conversationID).Scan(&title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("conversation %d not found", conversationID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("query title for conversation %d: %w", conversationID, err)
+	}
+	return title, nil
+}
+
+// setConversationTitle stores a normalized, non-empty title and an audit
+// entry with the old and new title, in one transaction. The TUI and the
+// title command share it.
+func setConversationTitle(ctx context.Context, db *sql.DB, conversationID int64, title string) error {
+	title = normalizeConversationTitle(title)
+	if title == "" {
+		return errors.New("title must not be empty")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin title transaction: %w", err)
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			_ = tx.Rollback()
+		}
+	}()
+
+	previous, err := loadConversationTitle(ctx, tx, conversationID)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE conversations
+		SET title = ?,
+		    updated_at = datetime('now')
+		WHERE conversation_id = ?
+	`, title, conversationID)
+	if err != nil {
+		return fmt.Errorf("update title for conversation %d: %w", conversationID, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read affected rows for conversation %d: %w", conversationID, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("conversation %d not found", conversationID)
+	}
+	if err := lcm.RecordAudit(ctx, tx, lcm.AuditEntry{
+		Command: "title", ConversationID: conversationID,
+		Detail: fmt.Sprintf("title %q -> %q", previous, title),
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit title for conversation %d: %w", conversationID, err)
+	}
+	rollback = false
+	return nil
+}
+
+// parseTitleArgs accepts the conversation ID followed by the title. Unquoted
+// multi-word titles are joined with single spaces.
+func parseTitleArgs(args []string) (titleOptions, int64, error) {
+	fs := flag.NewFlagSet("title", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before writing the title")
+	if err := fs.Parse(normalizePruneArgs(args)); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return titleOptions{}, 0, errors.New(titleUsageText())
+		}
+		return titleOptions{}, 0, fmt.Errorf("%w\n%s", err, titleUsageText())
+	}
+	if fs.NArg() < 2 {
+		return titleOptions{}, 0, fmt.Errorf("conversation ID and title are required\n%s", titleUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return titleOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), titleUsageText())
+	}
+	title := normalizeConversationTitle(strings.Join(fs.Args()[1:], " "))
+	if title == "" {
+		return titleOptions{}, 0, fmt.Errorf("title must not be empty\n%s", titleUsageText())
+	}
+	backup, err := resolveBackupDB(*backupDB)
+	if err != nil {
+		return titleOptions{}, 0, fmt.Errorf("%w\n%s", err, titleUsageText())
+	}
+	return titleOptions{title: title, backupDB: backup}, conversationID, nil
+}
+
+func titleUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui title <conversation_id> "<new title>" [--backup-db]
+
+Sets conversations.title, shown in the session list and screen headers.
+Backfilled conversations default to their session ID; use this to give them
+a friendlier name. Whitespace is collapsed to single spaces.
+
+Flags:
+  --backup-db   back up lcm.db before writing the title
+`)
+}
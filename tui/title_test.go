@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTitleTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "lcm.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(`
+		CREATE TABLE conversations (
+			conversation_id INTEGER PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			session_key TEXT,
+			title TEXT,
+			updated_at TEXT
+		);
+		INSERT INTO conversations (conversation_id, session_id, title) VALUES
+			(7, 'session-7', 'session-7');
+	`); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return db, dbPath
+}
+
+func TestParseTitleArgs(t *testing.T) {
+	opts, conversationID, err := parseTitleArgs([]string{"12", "Release", "  planning\tnotes ", "--backup-db"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if conversationID != 12 || opts.title != "Release planning notes" || !opts.backupDB {
+		t.Fatalf("unexpected parse result %d %+v", conversationID, opts)
+	}
+
+	for _, args := range [][]string{{"12"}, {"abc", "x"}, {"12", "   "}} {
+		if _, _, err := parseTitleArgs(args); err == nil {
+			t.Fatalf("expected error for args %q", args)
+		}
+	}
+}
+
+func TestSetConversationTitle(t *testing.T) {
+	db, _ := newTitleTestDB(t)
+	ctx := context.Background()
+
+	if err := setConversationTitle(ctx, db, 7, "  Weekly\nsync  "); err != nil {
+		t.Fatalf("set title: %v", err)
+	}
+	title, err := loadConversationTitle(ctx, db, 7)
+	if err != nil {
+		t.Fatalf("load title: %v", err)
+	}
+	if title != "Weekly sync" {
+		t.Fatalf("expected normalized title, got %q", title)
+	}
+	assertCount(t, db, `SELECT COUNT(*) FROM audit_log WHERE command = 'title' AND conversation_id = 7 AND detail LIKE '%-> "Weekly sync"'`, 1)
+
+	if err := setConversationTitle(ctx, db, 99, "x"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if err := setConversationTitle(ctx, db, 7, " "); err == nil {
+		t.Fatal("expected empty title error")
+	}
+}
+
+func TestLoadConversationMetadataIncludesTitle(t *testing.T) {
+	db, _ := newTitleTestDB(t)
+	mustExec(t, db, `UPDATE conversations SET title = 'Named' WHERE conversation_id = 7`)
+
+	metadata := loadConversationMetadataFromDB(db, []string{"session-7"})
+	if metadata["session-7"].title != "Named" {
+		t.Fatalf("expected title in metadata, got %+v", metadata["session-7"])
+	}
+}
+
+func TestTitleEditRenamesConversationInline(t *testing.T) {
+	db, dbPath := newTitleTestDB(t)
+	m := model{
+		screen:   screenConversation,
+		paths:    appDataPaths{lcmDBPath: dbPath},
+		sessions: []sessionEntry{{id: "session-7", conversationID: 7, title: "session-7"}},
+	}
+	if conversationDisplayTitle(m.sessions[0]) != "" {
+		t.Fatal("expected session ID title to be hidden")
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("T")})
+	m = updated.(model)
+	if m.titleEdit == nil {
+		t.Fatal("expected rename prompt to open")
+	}
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("qa")},
+		{Type: tea.KeyBackspace},
+		{Type: tea.KeySpace},
+		{Type: tea.KeyRunes, Runes: []rune("run")},
+		{Type: tea.KeyEnter},
+	} {
+		updated, _ = m.Update(msg)
+		m = updated.(model)
+	}
+
+	if m.titleEdit != nil {
+		t.Fatalf("expected prompt to close, status %q", m.status)
+	}
+	if m.sessions[0].title != "q run" || m.currentConversationTitle() != "q run" {
+		t.Fatalf("expected cached title update, got %q", m.sessions[0].title)
+	}
+	title, err := loadConversationTitle(context.Background(), db, 7)
+	if err != nil || title != "q run" {
+		t.Fatalf("expected stored title, got %q (%v)", title, err)
+	}
+}
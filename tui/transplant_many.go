@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+const (
+	transplantManyOrderArgs    = "args"
+	transplantManyOrderRecency = "recency"
+)
+
+type transplantManyOptions struct {
+	apply    bool
+	dryRun   bool
+	order    string
+	backupDB bool
+}
+
+// transplantManyPlan coordinates several per-source transplant plans into one
+// target. sources are in apply order; shared[i] counts summaries in sources[i]
+// whose content was already planned from an earlier source.
+type transplantManyPlan struct {
+	targetConversationID int64
+	order                string
+	sources              []lcm.TransplantPlan
+	shared               []int
+	skipped              []int64
+}
+
+// runTransplantManyCommand executes the multi-source transplant CLI path.
+func runTransplantManyCommand(args []string) error {
+	opts, targetConversationID, sourceConversationIDs, err := parseTransplantManyArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plan, err := buildTransplantManyPlan(ctx, db, targetConversationID, sourceConversationIDs, opts.order)
+	if err != nil {
+		return err
+	}
+	if len(plan.sources) == 0 {
+		fmt.Printf("None of the %d source conversations have summary context items. Nothing to transplant.\n", len(sourceConversationIDs))
+		return nil
+	}
+
+	duplicates := printTransplantManyReport(plan)
+	if duplicates > 0 {
+		if opts.apply {
+			return fmt.Errorf("aborting transplant-many: target conversation %d already contains %d matching summary content hashes", targetConversationID, duplicates)
+		}
+		return nil
+	}
+	if opts.dryRun {
+		fmt.Println()
+		fmt.Println("Run with --apply to execute.")
+		return nil
+	}
+
+	if err := backupBeforeApply(ctx, db, paths.lcmDBPath, "transplant-many", opts.backupDB, nil); err != nil {
+		return err
+	}
+	copied, reused, err := applyTransplantMany(ctx, db, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDone. %d summaries copied, %d shared summaries reused, from %d conversations into conversation %d.\n", copied, reused, len(plan.sources), targetConversationID)
+	return nil
+}
+
+func parseTransplantManyArgs(args []string) (transplantManyOptions, int64, []int64, error) {
+	fs := flag.NewFlagSet("transplant-many", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	apply := fs.Bool("apply", false, "apply transplant to the DB")
+	dryRun := fs.Bool("dry-run", true, "show what would be transplanted")
+	order := fs.String("order", transplantManyOrderArgs, "source order: args or recency")
+	backupDB := fs.Bool("backup-db", false, "back up lcm.db before applying")
+
+	normalizedArgs, err := normalizeTransplantManyArgs(args)
+	if err != nil {
+		return transplantManyOptions{}, 0, nil, fmt.Errorf("%w\n%s", err, transplantManyUsageText())
+	}
+	if err := fs.Parse(normalizedArgs); err != nil {
+		return transplantManyOptions{}, 0, nil, fmt.Errorf("%w\n%s", err, transplantManyUsageText())
+	}
+	if fs.NArg() < 2 {
+		return transplantManyOptions{}, 0, nil, fmt.Errorf("target and at least one source conversation ID are required\n%s", transplantManyUsageText())
+	}
+
+	targetConversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return transplantManyOptions{}, 0, nil, fmt.Errorf("parse target conversation ID %q: %w", fs.Arg(0), err)
+	}
+
+	seen := make(map[int64]bool, fs.NArg()-1)
+	sourceConversationIDs := make([]int64, 0, fs.NArg()-1)
+	for _, raw := range fs.Args()[1:] {
+		sourceConversationID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return transplantManyOptions{}, 0, nil, fmt.Errorf("parse source conversation ID %q: %w", raw, err)
+		}
+		if sourceConversationID == targetConversationID {
+			return transplantManyOptions{}, 0, nil, fmt.Errorf("source conversation %d is also the target", sourceConversationID)
+		}
+		if seen[sourceConversationID] {
+			return transplantManyOptions{}, 0, nil, fmt.Errorf("source conversation %d listed more than once", sourceConversationID)
+		}
+		seen[sourceConversationID] = true
+		sourceConversationIDs = append(sourceConversationIDs, sourceConversationID)
+	}
+
+	opts := transplantManyOptions{
+		apply:  *apply,
+		dryRun: *dryRun,
+		order:  strings.ToLower(strings.TrimSpace(*order)),
+	}
+	opts.backupDB, err = resolveBackupDB(*backupDB)
+	if err != nil {
+		return transplantManyOptions{}, 0, nil, err
+	}
+	if opts.order != transplantManyOrderArgs && opts.order != transplantManyOrderRecency {
+		return transplantManyOptions{}, 0, nil, fmt.Errorf("--order must be %q or %q", transplantManyOrderArgs, transplantManyOrderRecency)
+	}
+	if opts.apply {
+		opts.dryRun = false
+	}
+	if !opts.apply {
+		opts.dryRun = true
+	}
+	return opts, targetConversationID, sourceConversationIDs, nil
+}
+
+func normalizeTransplantManyArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--order" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--") || arg == "-h" {
+			flags = append(flags, arg)
+			continue
+		}
+		positionals = append(positionals, arg)
+	}
+	return append(flags, positionals...), nil
+}
+
+func transplantManyUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui transplant-many <target_conversation_id> <source_id> [source_id...] [--dry-run]
+  lcm-tui transplant-many <target_conversation_id> <source_id> [source_id...] --apply
+
+Flags:
+  --dry-run          show combined plan without writes (default)
+  --apply            transplant all sources in one transaction
+  --order <mode>     source order: args (as listed, default) or recency (oldest source first)
+  --backup-db        with --apply, copy lcm.db to a timestamped backup first
+
+Summaries whose content already came from an earlier source are reused rather
+than copied again.
+`)
+}
+
+// buildTransplantManyPlan plans every source against the target, orders the
+// sources, and counts content shared with earlier sources.
+func buildTransplantManyPlan(ctx context.Context, q sqlQueryer, targetConversationID int64, sourceConversationIDs []int64, order string) (transplantManyPlan, error) {
+	plan := transplantManyPlan{
+		targetConversationID: targetConversationID,
+		order:                order,
+	}
+	for _, sourceConversationID := range sourceConversationIDs {
+		sourcePlan, err := lcm.BuildTransplantPlan(ctx, q, sourceConversationID, targetConversationID)
+		if err != nil {
+			return transplantManyPlan{}, fmt.Errorf("plan source conversation %d: %w", sourceConversationID, err)
+		}
+		if len(sourcePlan.SourceContext) == 0 {
+			plan.skipped = append(plan.skipped, sourceConversationID)
+			continue
+		}
+		plan.sources = append(plan.sources, sourcePlan)
+	}
+
+	if order == transplantManyOrderRecency {
+		sort.SliceStable(plan.sources, func(i, j int) bool {
+			return latestTransplantContextAt(plan.sources[i]) < latestTransplantContextAt(plan.sources[j])
+		})
+	}
+
+	seen := make(map[string]int64)
+	plan.shared = make([]int, len(plan.sources))
+	for i, sourcePlan := range plan.sources {
+		for _, summary := range sourcePlan.Ordered {
+			hash := lcm.ContentSHA256(summary.Content)
+			owner, ok := seen[hash]
+			if !ok {
+				seen[hash] = sourcePlan.SourceConversationID
+				continue
+			}
+			if owner != sourcePlan.SourceConversationID {
+				plan.shared[i]++
+			}
+		}
+	}
+	return plan, nil
+}
+
+// latestTransplantContextAt returns the newest created_at among a source's
+// context summaries, used to order sources by recency.
+func latestTransplantContextAt(plan lcm.TransplantPlan) string {
+	latest := ""
+	for _, item := range plan.SourceContext {
+		if item.CreatedAt > latest {
+			latest = item.CreatedAt
+		}
+	}
+	return latest
+}
+
+// printTransplantManyReport prints the combined plan and returns the number of
+// source summaries that already exist in the target.
+func printTransplantManyReport(plan transplantManyPlan) int {
+	fmt.Printf("Transplant-many: %d conversations -> conversation %d (order: %s)\n\n", len(plan.sources), plan.targetConversationID, plan.order)
+
+	totalContext := 0
+	totalSummaries := 0
+	totalShared := 0
+	totalTokens := 0
+	duplicates := 0
+	for i, sourcePlan := range plan.sources {
+		fmt.Printf("  %d. conversation %d: %d context summaries, %d DAG summaries, %d shared with earlier sources, ~%d context tokens\n",
+			i+1,
+			sourcePlan.SourceConversationID,
+			len(sourcePlan.SourceContext),
+			len(sourcePlan.Ordered),
+			plan.shared[i],
+			sourcePlan.ContextTokenOverhead,
+		)
+		totalContext += len(sourcePlan.SourceContext)
+		totalSummaries += len(sourcePlan.Ordered)
+		totalShared += plan.shared[i]
+		totalTokens += sourcePlan.ContextTokenOverhead
+		duplicates += len(sourcePlan.Duplicates)
+	}
+	for _, sourceConversationID := range plan.skipped {
+		fmt.Printf("  -  conversation %d: no summary context items (skipped)\n", sourceConversationID)
+	}
+	fmt.Println()
+
+	if len(plan.sources) > 0 {
+		target := plan.sources[0].TargetContext
+		fmt.Printf("Target current context (%d items):\n", target.Total)
+		fmt.Printf("  %d summaries + %d messages\n\n", target.Summaries, target.Messages)
+	}
+
+	fmt.Println("After transplant:")
+	fmt.Printf("  up to %d new context items merged by depth\n", totalContext)
+	fmt.Printf("  %d summaries copied, %d shared summaries reused\n", totalSummaries-totalShared, totalShared)
+	fmt.Printf("  Estimated token overhead in context: ~%d tokens\n", totalTokens)
+
+	if duplicates > 0 {
+		fmt.Println()
+		fmt.Printf("Warning: found %d source summaries with content already present in target conversation.\n", duplicates)
+		for _, sourcePlan := range plan.sources {
+			for _, duplicate := range sourcePlan.Duplicates {
+				fmt.Printf("  conversation %d  %s  hash=%s  matches_in_target=%d\n", sourcePlan.SourceConversationID, duplicate.SummaryID, duplicate.ContentHash, duplicate.TargetCount)
+			}
+		}
+		fmt.Println("Aborting apply to avoid duplicate transplants.")
+		return duplicates
+	}
+	return 0
+}
+
+// applyTransplantMany applies every source plan in order inside one
+// transaction, reusing summaries already copied from an earlier source.
+func applyTransplantMany(ctx context.Context, db *sql.DB, plan transplantManyPlan) (int, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin transplant-many transaction: %w", err)
+	}
+
+	rollbackNeeded := true
+	defer func() {
+		if rollbackNeeded {
+			_ = tx.Rollback()
+		}
+	}()
+
+	index := make(lcm.TransplantContentIndex)
+	totalCopied := 0
+	totalReused := 0
+	for i, sourcePlan := range plan.sources {
+		fmt.Printf("\n== Source %d/%d: conversation %d ==\n", i+1, len(plan.sources), sourcePlan.SourceConversationID)
+		copied, reused, err := lcm.CopyTransplantPlan(ctx, tx, sourcePlan, index, os.Stdout)
+		totalCopied += copied
+		totalReused += reused
+		if err != nil {
+			return totalCopied, totalReused, fmt.Errorf("transplant conversation %d: %w", sourcePlan.SourceConversationID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return totalCopied, totalReused, fmt.Errorf("commit transplant-many transaction: %w", err)
+	}
+	rollbackNeeded = false
+	return totalCopied, totalReused, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyTransplantManyDedupesSharedSummaries(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lcm.db"))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	setupTransplantTestSchema(t, db)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id) VALUES
+		(1, 'source-one'),
+		(2, 'source-two'),
+		(3, 'target-session');
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at) VALUES
+		(101, 1, 0, 'user', 'one raw', 10, '2026-01-01T00:00:00Z'),
+		(201, 2, 0, 'user', 'two raw', 10, '2026-01-03T00:00:00Z'),
+		(301, 3, 0, 'user', 'target raw', 7, '2026-01-04T00:00:00Z');
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth) VALUES
+		('sum_one_shared', 1, 'leaf', 'shared leaf', 40, '2026-01-01T00:05:00Z', '', 0),
+		('sum_one_own', 1, 'leaf', 'one only', 30, '2026-01-01T00:06:00Z', '', 0),
+		('sum_two_shared', 2, 'leaf', 'shared leaf', 40, '2026-01-03T00:05:00Z', '', 0),
+		('sum_two_own', 2, 'leaf', 'two only', 20, '2026-01-03T00:06:00Z', '', 0);
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES
+		('sum_one_shared', 101, 0),
+		('sum_one_own', 101, 0),
+		('sum_two_shared', 201, 0),
+		('sum_two_own', 201, 0);
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id) VALUES
+		(1, 0, 'summary', NULL, 'sum_one_shared'),
+		(1, 1, 'summary', NULL, 'sum_one_own'),
+		(2, 0, 'summary', NULL, 'sum_two_shared'),
+		(2, 1, 'summary', NULL, 'sum_two_own'),
+		(3, 0, 'message', 301, NULL);
+	`)
+
+	plan, err := buildTransplantManyPlan(ctx, db, 3, []int64{2, 1}, transplantManyOrderRecency)
+	if err != nil {
+		t.Fatalf("build transplant-many plan: %v", err)
+	}
+	if len(plan.sources) != 2 || plan.sources[0].SourceConversationID != 1 {
+		t.Fatalf("recency order should put conversation 1 first, got %+v", plan.sources)
+	}
+	if plan.shared[0] != 0 || plan.shared[1] != 1 {
+		t.Fatalf("shared counts = %v, want [0 1]", plan.shared)
+	}
+
+	copied, reused, err := applyTransplantMany(ctx, db, plan)
+	if err != nil {
+		t.Fatalf("apply transplant-many: %v", err)
+	}
+	if copied != 3 || reused != 1 {
+		t.Fatalf("copied=%d reused=%d, want 3/1", copied, reused)
+	}
+
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = 3`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = 3 AND content = 'shared leaf'`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 3 AND item_type = 'summary'`, 3)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 3 AND item_type = 'message'`, 1)
+	assertCount(t, db, `
+		SELECT COUNT(*)
+		FROM summary_messages sm
+		JOIN summaries s ON s.summary_id = sm.summary_id
+		JOIN messages m ON m.message_id = sm.message_id
+		WHERE s.conversation_id = 3
+		  AND m.conversation_id != 3
+	`, 0)
+}
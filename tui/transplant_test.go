@@ -3,7 +3,11 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
 )
 
 func TestApplyTransplantDeepCopiesMessages(t *testing.T) {
@@ -18,87 +22,7 @@ func TestApplyTransplantDeepCopiesMessages(t *testing.T) {
 		t.Fatalf("enable foreign keys: %v", err)
 	}
 
-	mustExec(t, db, `
-		CREATE TABLE conversations (
-			conversation_id INTEGER PRIMARY KEY,
-			session_id TEXT NOT NULL
-		);
-		CREATE TABLE summaries (
-			summary_id TEXT PRIMARY KEY,
-			conversation_id INTEGER NOT NULL,
-			kind TEXT NOT NULL,
-			content TEXT NOT NULL,
-			token_count INTEGER NOT NULL,
-			created_at TEXT NOT NULL,
-			file_ids TEXT,
-			depth INTEGER NOT NULL
-		);
-		CREATE TABLE summary_parents (
-			summary_id TEXT NOT NULL,
-			parent_summary_id TEXT NOT NULL,
-			ordinal INTEGER NOT NULL
-		);
-		CREATE TABLE messages (
-			message_id INTEGER PRIMARY KEY AUTOINCREMENT,
-			conversation_id INTEGER NOT NULL,
-			seq INTEGER NOT NULL,
-			role TEXT NOT NULL,
-			content TEXT NOT NULL,
-			token_count INTEGER NOT NULL,
-			identity_hash TEXT,
-			created_at TEXT NOT NULL,
-			UNIQUE (conversation_id, seq)
-		);
-		CREATE TABLE summary_messages (
-			summary_id TEXT NOT NULL,
-			message_id INTEGER NOT NULL,
-			ordinal INTEGER NOT NULL,
-			PRIMARY KEY (summary_id, message_id)
-		);
-		CREATE TABLE context_items (
-			conversation_id INTEGER NOT NULL,
-			ordinal INTEGER NOT NULL,
-			item_type TEXT NOT NULL,
-			message_id INTEGER,
-			summary_id TEXT,
-			created_at TEXT,
-			UNIQUE (conversation_id, ordinal)
-		);
-		CREATE TABLE message_parts (
-			part_id TEXT PRIMARY KEY,
-			message_id INTEGER NOT NULL,
-			session_id TEXT NOT NULL,
-			part_type TEXT NOT NULL,
-			ordinal INTEGER NOT NULL,
-			text_content TEXT,
-			is_ignored INTEGER,
-			is_synthetic INTEGER,
-			tool_call_id TEXT,
-			tool_name TEXT,
-			tool_status TEXT,
-			tool_input TEXT,
-			tool_output TEXT,
-			tool_error TEXT,
-			tool_title TEXT,
-			patch_hash TEXT,
-			patch_files TEXT,
-			file_mime TEXT,
-			file_name TEXT,
-			file_url TEXT,
-			subtask_prompt TEXT,
-			subtask_desc TEXT,
-			subtask_agent TEXT,
-			step_reason TEXT,
-			step_cost REAL,
-			step_tokens_in INTEGER,
-			step_tokens_out INTEGER,
-			snapshot_hash TEXT,
-			compaction_auto INTEGER,
-			metadata TEXT,
-			UNIQUE (message_id, ordinal)
-		);
-		CREATE VIRTUAL TABLE messages_fts USING fts5(content);
-	`)
+	setupTransplantTestSchema(t, db)
 
 	mustExec(t, db, `
 		INSERT INTO conversations (conversation_id, session_id) VALUES
@@ -135,11 +59,11 @@ func TestApplyTransplantDeepCopiesMessages(t *testing.T) {
 		(2, 0, 'message', 201, NULL);
 	`)
 
-	plan, err := buildTransplantPlan(ctx, db, 1, 2)
+	plan, err := lcm.BuildTransplantPlan(ctx, db, 1, 2)
 	if err != nil {
 		t.Fatalf("build transplant plan: %v", err)
 	}
-	if _, err := applyTransplant(ctx, db, plan); err != nil {
+	if _, err := lcm.ApplyTransplant(ctx, db, plan, nil); err != nil {
 		t.Fatalf("apply transplant: %v", err)
 	}
 
@@ -197,6 +121,128 @@ func TestApplyTransplantDeepCopiesMessages(t *testing.T) {
 	`, 2)
 }
 
+func TestApplyTransplantKeepMessagesLinksMatchingTargetMessages(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id) VALUES
+		(1, 'source-session'),
+		(2, 'target-session');
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at) VALUES
+		(101, 1, 0, 'user', 'shared question', 10, '2026-01-01T00:00:00Z'),
+		(102, 1, 1, 'assistant', 'source only', 12, '2026-01-01T00:01:00Z'),
+		(201, 2, 0, 'user', 'shared question', 10, '2026-01-01T00:00:00Z'),
+		(202, 2, 1, 'assistant', 'shared question', 10, '2026-01-01T00:00:30Z');
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth) VALUES
+		('sum_src_a', 1, 'leaf', 'leaf a', 40, '2026-01-01T00:05:00Z', '', 0);
+	`)
+	mustExec(t, db, `
+		INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES
+		('sum_src_a', 101, 0),
+		('sum_src_a', 102, 1);
+	`)
+	mustExec(t, db, `
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id) VALUES
+		(1, 0, 'summary', NULL, 'sum_src_a'),
+		(2, 0, 'message', 201, NULL);
+	`)
+
+	plan, err := lcm.BuildTransplantPlan(ctx, db, 1, 2)
+	if err != nil {
+		t.Fatalf("build transplant plan: %v", err)
+	}
+	plan.KeepMessages = true
+	linked, copies, err := lcm.CountTransplantMessageLinks(ctx, db, plan)
+	if err != nil {
+		t.Fatalf("count links: %v", err)
+	}
+	if linked != 1 || copies != 1 {
+		t.Fatalf("preview linked=%d copies=%d, want 1/1", linked, copies)
+	}
+	if _, err := lcm.ApplyTransplant(ctx, db, plan, nil); err != nil {
+		t.Fatalf("apply transplant: %v", err)
+	}
+
+	// The user message matches 201 by role + content; 202 has the same text
+	// but a different role and must not be used.
+	assertCountQuery(t, db, `
+		SELECT COUNT(*)
+		FROM summary_messages sm
+		JOIN summaries s ON s.summary_id = sm.summary_id
+		WHERE s.conversation_id = 2 AND sm.message_id = 201 AND sm.ordinal = 0
+	`, 1)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 2 AND content = 'shared question'`, 2)
+	assertCountQuery(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 2 AND content = 'source only'`, 1)
+	assertCountQuery(t, db, `
+		SELECT COUNT(*)
+		FROM summary_messages sm
+		JOIN summaries s ON s.summary_id = sm.summary_id
+		JOIN messages m ON m.message_id = sm.message_id
+		WHERE s.conversation_id = 2 AND m.conversation_id != 2
+	`, 0)
+}
+
+func TestApplyTransplantReportsFailingMessageAndRollsBack(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:transplant_failure?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	setupTransplantTestSchema(t, db)
+	mustExec(t, db, `
+		INSERT INTO conversations (conversation_id, session_id) VALUES
+		(1, 'source-session'),
+		(2, 'target-session');
+	`)
+	mustExec(t, db, `
+		INSERT INTO messages (message_id, conversation_id, seq, role, content, token_count, created_at) VALUES
+		(101, 1, 0, 'user', 'source one', 10, '2026-01-01T00:00:00Z'),
+		(102, 1, 1, 'assistant', 'source two', 12, '2026-01-01T00:01:00Z'),
+		(201, 2, 0, 'assistant', 'source two', 12, '2026-01-02T00:00:00Z');
+	`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, content, token_count, created_at, file_ids, depth) VALUES
+		('sum_src_a', 1, 'leaf', 'leaf a', 40, '2026-01-01T00:05:00Z', '', 0);
+		INSERT INTO summary_messages (summary_id, message_id, ordinal) VALUES
+		('sum_src_a', 101, 0),
+		('sum_src_a', 102, 1);
+		INSERT INTO context_items (conversation_id, ordinal, item_type, message_id, summary_id) VALUES
+		(1, 0, 'summary', NULL, 'sum_src_a'),
+		(2, 0, 'message', 201, NULL);
+	`)
+	// Copying message 102 into the target collides with message 201.
+	mustExec(t, db, `CREATE UNIQUE INDEX messages_conversation_content ON messages (conversation_id, content)`)
+
+	plan, err := lcm.BuildTransplantPlan(ctx, db, 1, 2)
+	if err != nil {
+		t.Fatalf("build transplant plan: %v", err)
+	}
+	_, err = lcm.ApplyTransplant(ctx, db, plan, nil)
+	var failure *lcm.TransplantFailure
+	if !errors.As(err, &failure) {
+		t.Fatalf("expected a transplant failure, got %v", err)
+	}
+	if failure.SourceMessageID != 102 || failure.SummariesRemapped != 1 || failure.MessagesRemapped != 1 || failure.MessagesTotal != 2 {
+		t.Fatalf("unexpected failure state: %+v", failure)
+	}
+	for _, want := range []string{"copy message 102 failed (duplicate key)", "1 of 1 summaries (last sum_src_a -> sum_", "1 of 2 messages (last 101 -> "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q should contain %q", err, want)
+		}
+	}
+
+	assertCount(t, db, `SELECT COUNT(*) FROM summaries WHERE conversation_id = 2`, 0)
+	assertCount(t, db, `SELECT COUNT(*) FROM messages WHERE conversation_id = 2`, 1)
+	assertCount(t, db, `SELECT COUNT(*) FROM context_items WHERE conversation_id = 2`, 1)
+}
+
 func TestMergeTransplantedContextItemsAvoidsShiftedOrdinalCollision(t *testing.T) {
 	db, err := sql.Open("sqlite", "file::memory:?cache=shared")
 	if err != nil {
@@ -244,8 +290,8 @@ func TestMergeTransplantedContextItemsAvoidsShiftedOrdinalCollision(t *testing.T
 		}
 	}
 
-	err = mergeTransplantedContextItems(ctx, db, 2, []transplantContextSummary{{
-		summaryID: "sum_source_archive",
+	err = lcm.MergeTransplantedContextItems(ctx, db, 2, []lcm.ContextSummary{{
+		SummaryID: "sum_source_archive",
 	}}, map[string]string{
 		"sum_source_archive": "sum_new_archive",
 	})
@@ -279,7 +325,93 @@ func TestMergeTransplantedContextItemsAvoidsShiftedOrdinalCollision(t *testing.T
 	`, 0)
 }
 
-func mustExec(t *testing.T, db *sql.DB, query string) {
+// setupTransplantTestSchema creates the tables touched by transplant writes.
+func setupTransplantTestSchema(t *testing.T, db *sql.DB) {
+	t.Helper()
+	mustExec(t, db, `
+		CREATE TABLE conversations (
+			conversation_id INTEGER PRIMARY KEY,
+			session_id TEXT NOT NULL
+		);
+		CREATE TABLE summaries (
+			summary_id TEXT PRIMARY KEY,
+			conversation_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			content TEXT NOT NULL,
+			token_count INTEGER NOT NULL,
+			created_at TEXT NOT NULL,
+			file_ids TEXT,
+			depth INTEGER NOT NULL
+		);
+		CREATE TABLE summary_parents (
+			summary_id TEXT NOT NULL,
+			parent_summary_id TEXT NOT NULL,
+			ordinal INTEGER NOT NULL
+		);
+		CREATE TABLE messages (
+			message_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			seq INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			token_count INTEGER NOT NULL,
+			identity_hash TEXT,
+			created_at TEXT NOT NULL,
+			UNIQUE (conversation_id, seq)
+		);
+		CREATE TABLE summary_messages (
+			summary_id TEXT NOT NULL,
+			message_id INTEGER NOT NULL,
+			ordinal INTEGER NOT NULL,
+			PRIMARY KEY (summary_id, message_id)
+		);
+		CREATE TABLE context_items (
+			conversation_id INTEGER NOT NULL,
+			ordinal INTEGER NOT NULL,
+			item_type TEXT NOT NULL,
+			message_id INTEGER,
+			summary_id TEXT,
+			created_at TEXT,
+			UNIQUE (conversation_id, ordinal)
+		);
+		CREATE TABLE message_parts (
+			part_id TEXT PRIMARY KEY,
+			message_id INTEGER NOT NULL,
+			session_id TEXT NOT NULL,
+			part_type TEXT NOT NULL,
+			ordinal INTEGER NOT NULL,
+			text_content TEXT,
+			is_ignored INTEGER,
+			is_synthetic INTEGER,
+			tool_call_id TEXT,
+			tool_name TEXT,
+			tool_status TEXT,
+			tool_input TEXT,
+			tool_output TEXT,
+			tool_error TEXT,
+			tool_title TEXT,
+			patch_hash TEXT,
+			patch_files TEXT,
+			file_mime TEXT,
+			file_name TEXT,
+			file_url TEXT,
+			subtask_prompt TEXT,
+			subtask_desc TEXT,
+			subtask_agent TEXT,
+			step_reason TEXT,
+			step_cost REAL,
+			step_tokens_in INTEGER,
+			step_tokens_out INTEGER,
+			snapshot_hash TEXT,
+			compaction_auto INTEGER,
+			metadata TEXT,
+			UNIQUE (message_id, ordinal)
+		);
+		CREATE VIRTUAL TABLE messages_fts USING fts5(content);
+	`)
+}
+
+func mustExec(t testing.TB, db *sql.DB, query string) {
 	t.Helper()
 	if _, err := db.Exec(query); err != nil {
 		t.Fatalf("exec query failed: %v\nquery:\n%s", err, query)
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+// defaultTUIPrefsPath holds display preferences the TUI remembers between
+// runs. It is written by the TUI itself, unlike agents.json and redact.json.
+const defaultTUIPrefsPath = "~/.config/lcm-tui/tui.json"
+
+// tuiPrefs is the contents of tui.json.
+type tuiPrefs struct {
+	RelativeTimes bool `json:"relativeTimes,omitempty"`
+}
+
+// loadTUIPrefs reads tui.json. A missing file yields the defaults.
+func loadTUIPrefs(path string) (tuiPrefs, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tuiPrefs{}, nil
+	}
+	if err != nil {
+		return tuiPrefs{}, fmt.Errorf("read TUI preferences %q: %w", path, err)
+	}
+	var prefs tuiPrefs
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return tuiPrefs{}, fmt.Errorf("parse TUI preferences %q: %w", path, err)
+	}
+	return prefs, nil
+}
+
+// saveTUIPrefs writes tui.json, creating its directory when needed.
+func saveTUIPrefs(path string, prefs tuiPrefs) error {
+	data, err := json.MarshalIndent(prefs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode TUI preferences: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create TUI preferences dir: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write TUI preferences %q: %w", path, err)
+	}
+	return nil
+}
+
+// resolveTUIPrefsPath expands defaultTUIPrefsPath.
+func resolveTUIPrefsPath() string {
+	return lcm.ExpandHomePath(defaultTUIPrefsPath)
+}
+
+// toggleRelativeTimes flips relative timestamps on every screen and saves
+// the choice. A failed save still toggles for this run.
+func (m *model) toggleRelativeTimes() {
+	m.relativeTimes = !m.relativeTimes
+	if len(m.messages) > 0 {
+		offset := m.convViewport.YOffset
+		m.refreshConversationViewportWithMode(conversationViewportTop)
+		m.convViewport.SetYOffset(offset)
+	}
+	label := "Timestamps: absolute"
+	if m.relativeTimes {
+		label = "Timestamps: relative"
+	}
+	if err := saveTUIPrefs(resolveTUIPrefsPath(), tuiPrefs{RelativeTimes: m.relativeTimes}); err != nil {
+		m.status = fmt.Sprintf("%s (not saved: %v)", label, err)
+		return
+	}
+	m.status = label
+}
+
+func (m model) timesToggleLabel() string {
+	if m.relativeTimes {
+		return "R: absolute times"
+	}
+	return "R: relative times"
+}
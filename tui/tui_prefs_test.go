@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFormatRelativeTime(t *testing.T) {
+	now := time.Date(2026, time.March, 22, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{-5 * time.Second, "just now"},
+		{30 * time.Second, "just now"},
+		{3 * time.Minute, "3m ago"},
+		{5*time.Hour + 59*time.Minute, "5h ago"},
+		{49 * time.Hour, "2d ago"},
+		{40 * 24 * time.Hour, now.Add(-40 * 24 * time.Hour).Local().Format("2006-01-02")},
+	}
+	for _, tc := range cases {
+		if got := formatRelativeTime(now.Add(-tc.ago), now); got != tc.want {
+			t.Fatalf("formatRelativeTime(-%s) = %q, want %q", tc.ago, got, tc.want)
+		}
+	}
+	if got := displayTimestamp("not a time", true); got != "not a time" {
+		t.Fatalf("unparseable timestamp rendered as %q", got)
+	}
+	if got := displayTimestamp("2026-03-22 10:00:00", false); got != formatTimestamp("2026-03-22 10:00:00") {
+		t.Fatalf("absolute mode rendered %q", got)
+	}
+}
+
+func TestTUIPrefsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lcm-tui", "tui.json")
+	prefs, err := loadTUIPrefs(path)
+	if err != nil || prefs.RelativeTimes {
+		t.Fatalf("missing file should load defaults, got %+v (%v)", prefs, err)
+	}
+	if err := saveTUIPrefs(path, tuiPrefs{RelativeTimes: true}); err != nil {
+		t.Fatalf("save prefs: %v", err)
+	}
+	prefs, err = loadTUIPrefs(path)
+	if err != nil || !prefs.RelativeTimes {
+		t.Fatalf("expected relative times to be remembered, got %+v (%v)", prefs, err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestViewShowsResizeHintBelowMinimumSize(t *testing.T) {
+	for _, size := range [][2]int{{39, 30}, {120, 9}, {12, 3}} {
+		m := model{screen: screenAgents, width: size[0], height: size[1]}
+		view := m.View()
+		if !strings.Contains(strings.ReplaceAll(view, "\n", " "), "Terminal") {
+			t.Fatalf("%dx%d: expected resize hint, got %q", size[0], size[1], view)
+		}
+		lines := strings.Split(view, "\n")
+		if len(lines) > size[1] {
+			t.Fatalf("%dx%d: hint uses %d lines", size[0], size[1], len(lines))
+		}
+		for _, line := range lines {
+			if w := runewidth.StringWidth(line); w > size[0] {
+				t.Fatalf("%dx%d: line %q is %d columns wide", size[0], size[1], line, w)
+			}
+		}
+	}
+
+	m := model{screen: screenAgents, width: minViewWidth, height: minViewHeight}
+	if strings.Contains(m.View(), "Terminal too small") {
+		t.Fatal("expected the normal layout at the minimum size")
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Martian-Engineering/lossless-claw/tui/pkg/lcm"
+)
+
+type watchOptions struct {
+	jsonOutput bool
+	interval   time.Duration
+}
+
+// watchSnapshot is one poll of a conversation: its summary DAG and the
+// context items the plugin would assemble. The graph's content is sanitized
+// for the terminal, so hashes holds shortSHA256 of each summary's raw
+// content to make content hashes match what is stored.
+type watchSnapshot struct {
+	graph         summaryGraph
+	hashes        map[string]string
+	contextItems  int
+	contextTokens int
+}
+
+// watchEvent is one line of watch output. The first event is a "snapshot" of
+// the starting state; after that a "change" is emitted only when a poll
+// differs from the one before it, and "error" when a poll fails. The JSON
+// tags are the --json output contract.
+type watchEvent struct {
+	Type           string `json:"type"`
+	Time           string `json:"time"`
+	ConversationID int64  `json:"conversation_id"`
+
+	Summaries          int `json:"summaries"`
+	SummariesDelta     int `json:"summaries_delta"`
+	ContextItems       int `json:"context_items"`
+	ContextItemsDelta  int `json:"context_items_delta"`
+	ContextTokens      int `json:"context_tokens"`
+	ContextTokensDelta int `json:"context_tokens_delta"`
+
+	Added   []watchSummary `json:"added,omitempty"`
+	Changed []watchChange  `json:"changed,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+type watchSummary struct {
+	SummaryID   string `json:"summary_id"`
+	Kind        string `json:"kind"`
+	Depth       int    `json:"depth"`
+	Tokens      int    `json:"tokens"`
+	ContentHash string `json:"content_hash"`
+}
+
+type watchChange struct {
+	SummaryID    string `json:"summary_id"`
+	Depth        int    `json:"depth"`
+	TokensBefore int    `json:"tokens_before"`
+	TokensAfter  int    `json:"tokens_after"`
+	HashBefore   string `json:"hash_before"`
+	HashAfter    string `json:"hash_after"`
+}
+
+// conversationWatcher polls one conversation and diffs each poll against the
+// previous one with the same comparison follow mode uses.
+type conversationWatcher struct {
+	db             *sql.DB
+	conversationID int64
+	prev           *watchSnapshot
+}
+
+func loadWatchSnapshot(ctx context.Context, db *sql.DB, conversationID int64) (watchSnapshot, error) {
+	graph, err := loadConversationSummaryGraph(db, conversationID)
+	if err != nil {
+		return watchSnapshot{}, err
+	}
+	hashes, err := loadSummaryContentHashes(ctx, db, conversationID)
+	if err != nil {
+		return watchSnapshot{}, err
+	}
+	items, err := lcm.LoadContextItems(ctx, db, conversationID)
+	if err != nil {
+		return watchSnapshot{}, err
+	}
+	snapshot := watchSnapshot{graph: graph, hashes: hashes, contextItems: len(items)}
+	for _, item := range items {
+		snapshot.contextTokens += item.TokenCount
+	}
+	return snapshot, nil
+}
+
+// loadSummaryContentHashes hashes each summary's content as stored.
+func loadSummaryContentHashes(ctx context.Context, db *sql.DB, conversationID int64) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT summary_id, content FROM summaries WHERE conversation_id = ?
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("query summary content for conversation %d: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var summaryID, content string
+		if err := rows.Scan(&summaryID, &content); err != nil {
+			return nil, fmt.Errorf("scan summary content: %w", err)
+		}
+		hashes[summaryID] = shortSHA256(content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate summary content: %w", err)
+	}
+	return hashes, nil
+}
+
+// poll loads a snapshot and returns the event it produces. ok is false when
+// nothing changed since the last poll.
+func (w *conversationWatcher) poll(ctx context.Context, now time.Time) (watchEvent, bool, error) {
+	next, err := loadWatchSnapshot(ctx, w.db, w.conversationID)
+	if err != nil {
+		return watchEvent{}, false, err
+	}
+	event := watchEvent{
+		Type:           "change",
+		Time:           now.UTC().Format(time.RFC3339),
+		ConversationID: w.conversationID,
+		Summaries:      len(next.graph.nodes),
+		ContextItems:   next.contextItems,
+		ContextTokens:  next.contextTokens,
+	}
+	prev := w.prev
+	w.prev = &next
+	if prev == nil {
+		event.Type = "snapshot"
+		return event, true, nil
+	}
+
+	event.SummariesDelta = event.Summaries - len(prev.graph.nodes)
+	event.ContextItemsDelta = event.ContextItems - prev.contextItems
+	event.ContextTokensDelta = event.ContextTokens - prev.contextTokens
+	diff := mergeSummaryGraphRefresh(prev.graph, next.graph)
+	// A change sanitizing hides from the graph comparison still counts.
+	for id, hash := range next.hashes {
+		if old, ok := prev.hashes[id]; ok && old != hash && !slices.Contains(diff.changed, id) {
+			diff.changed = append(diff.changed, id)
+		}
+	}
+	for _, ids := range [][]string{diff.added, diff.changed, diff.removed} {
+		sort.Strings(ids)
+	}
+	for _, id := range diff.added {
+		node := next.graph.nodes[id]
+		event.Added = append(event.Added, watchSummary{
+			SummaryID: id, Kind: node.kind, Depth: node.depth, Tokens: node.tokenCount, ContentHash: next.hashes[id],
+		})
+	}
+	for _, id := range diff.changed {
+		old, node := prev.graph.nodes[id], next.graph.nodes[id]
+		event.Changed = append(event.Changed, watchChange{
+			SummaryID: id, Depth: node.depth, TokensBefore: old.tokenCount, TokensAfter: node.tokenCount,
+			HashBefore: prev.hashes[id], HashAfter: next.hashes[id],
+		})
+	}
+	event.Removed = diff.removed
+	if diff.empty() && event.ContextItemsDelta == 0 && event.ContextTokensDelta == 0 {
+		return watchEvent{}, false, nil
+	}
+	return event, true, nil
+}
+
+// runWatchCommand executes the standalone watch CLI path. It polls until
+// interrupted and exits cleanly on SIGINT or SIGTERM.
+func runWatchCommand(args []string) error {
+	opts, conversationID, err := parseWatchArgs(args)
+	if err != nil {
+		return err
+	}
+
+	paths, err := resolveDataPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(paths.lcmDBPath); err != nil {
+		return fmt.Errorf("stat LCM database %q: %w", paths.lcmDBPath, err)
+	}
+	db, err := openLCMDB(paths.lcmDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	exists, err := lcm.ConversationExists(ctx, db, conversationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("conversation %d not found", conversationID)
+	}
+
+	watcher := &conversationWatcher{db: db, conversationID: conversationID}
+	emit := func(event watchEvent) error {
+		if opts.jsonOutput {
+			return json.NewEncoder(os.Stdout).Encode(event)
+		}
+		_, err := fmt.Fprintln(os.Stdout, formatWatchEvent(event))
+		return err
+	}
+	event, _, err := watcher.poll(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := emit(event); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			event, ok, err := watcher.poll(ctx, now)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				// A poll can fail while the plugin holds a write lock; report
+				// it and keep watching.
+				event = watchEvent{Type: "error", Time: now.UTC().Format(time.RFC3339), ConversationID: conversationID, Error: err.Error()}
+				ok = true
+			}
+			if !ok {
+				continue
+			}
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// formatWatchEvent renders an event as one line for a terminal or log.
+func formatWatchEvent(event watchEvent) string {
+	prefix := fmt.Sprintf("%s conv %d", event.Time, event.ConversationID)
+	switch event.Type {
+	case "error":
+		return fmt.Sprintf("%s poll failed: %s", prefix, event.Error)
+	case "snapshot":
+		return fmt.Sprintf("%s watching: %d summaries, %d context items (%dt)", prefix, event.Summaries, event.ContextItems, event.ContextTokens)
+	}
+	parts := []string{fmt.Sprintf("summaries %d (%+d)", event.Summaries, event.SummariesDelta)}
+	for _, added := range event.Added {
+		parts = append(parts, fmt.Sprintf("+%s d%d %dt", added.SummaryID, added.Depth, added.Tokens))
+	}
+	for _, changed := range event.Changed {
+		parts = append(parts, fmt.Sprintf("~%s %d->%dt", changed.SummaryID, changed.TokensBefore, changed.TokensAfter))
+	}
+	for _, removed := range event.Removed {
+		parts = append(parts, "-"+removed)
+	}
+	parts = append(parts, fmt.Sprintf("context %d items (%+d), %dt (%+d)",
+		event.ContextItems, event.ContextItemsDelta, event.ContextTokens, event.ContextTokensDelta))
+	return prefix + " " + strings.Join(parts, ", ")
+}
+
+func parseWatchArgs(args []string) (watchOptions, int64, error) {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOutput := fs.Bool("json", false, "emit one JSON event per line")
+	interval := fs.Duration("interval", summaryFollowInterval, "how often to poll the database")
+
+	normalized, err := normalizeWatchArgs(args)
+	if err != nil {
+		return watchOptions{}, 0, fmt.Errorf("%w\n%s", err, watchUsageText())
+	}
+	if err := fs.Parse(normalized); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return watchOptions{}, 0, errors.New(watchUsageText())
+		}
+		return watchOptions{}, 0, fmt.Errorf("%w\n%s", err, watchUsageText())
+	}
+	if fs.NArg() != 1 {
+		return watchOptions{}, 0, fmt.Errorf("conversation ID is required\n%s", watchUsageText())
+	}
+	conversationID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || conversationID <= 0 {
+		return watchOptions{}, 0, fmt.Errorf("invalid conversation ID %q\n%s", fs.Arg(0), watchUsageText())
+	}
+	if *interval < 100*time.Millisecond {
+		return watchOptions{}, 0, fmt.Errorf("--interval must be at least 100ms\n%s", watchUsageText())
+	}
+	return watchOptions{jsonOutput: *jsonOutput, interval: *interval}, conversationID, nil
+}
+
+func normalizeWatchArgs(args []string) ([]string, error) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--interval":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--"):
+			flags = append(flags, arg)
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+	return append(flags, positionals...), nil
+}
+
+func watchUsageText() string {
+	return strings.TrimSpace(`
+Usage:
+  lcm-tui watch <conversation_id> [--json] [--interval <duration>]
+
+Poll a conversation without the TUI and print a line whenever compaction
+changes it: summaries added, removed, or rewritten (content or token count),
+or the context items changing. The first line is the starting state; after
+that only deltas are printed. Runs until interrupted (Ctrl-C or SIGTERM).
+Read-only.
+
+Flags:
+  --json                 emit one JSON event per line (snapshot, change, error)
+  --interval <duration>  how often to poll the database (default 2s, minimum 100ms)
+`)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConversationWatcherEmitsOnlyDeltas(t *testing.T) {
+	db := newBackfillTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 22, 10, 0, 0, 0, time.UTC)
+	mustExec(t, db, `INSERT INTO conversations (conversation_id, session_id) VALUES (1, 'watch')`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_a', 1, 'leaf', 0, 'first leaf', 10, '2026-03-22T09:00:00Z')
+	`)
+	mustExec(t, db, `INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id) VALUES (1, 0, 'summary', 'sum_a')`)
+
+	watcher := &conversationWatcher{db: db, conversationID: 1}
+	event, ok, err := watcher.poll(ctx, now)
+	if err != nil || !ok || event.Type != "snapshot" || event.Summaries != 1 || event.ContextItems != 1 || event.ContextTokens != 10 {
+		t.Fatalf("first poll = %+v, %v, %v", event, ok, err)
+	}
+	if event, ok, err := watcher.poll(ctx, now); err != nil || ok {
+		t.Fatalf("an unchanged poll should emit nothing, got %+v (%v)", event, err)
+	}
+
+	mustExec(t, db, `UPDATE summaries SET content = 'first leaf, rewritten', token_count = 12 WHERE summary_id = 'sum_a'`)
+	mustExec(t, db, `
+		INSERT INTO summaries (summary_id, conversation_id, kind, depth, content, token_count, created_at)
+		VALUES ('sum_b', 1, 'leaf', 0, 'second leaf', 8, '2026-03-22T10:00:00Z')
+	`)
+	mustExec(t, db, `INSERT INTO context_items (conversation_id, ordinal, item_type, summary_id) VALUES (1, 1, 'summary', 'sum_b')`)
+	event, ok, err = watcher.poll(ctx, now.Add(2*time.Second))
+	if err != nil || !ok || event.Type != "change" {
+		t.Fatalf("change poll = %+v, %v, %v", event, ok, err)
+	}
+	if event.SummariesDelta != 1 || event.ContextItemsDelta != 1 || event.ContextTokensDelta != 10 {
+		t.Fatalf("deltas = %+v", event)
+	}
+	if len(event.Added) != 1 || event.Added[0].SummaryID != "sum_b" || event.Added[0].Tokens != 8 {
+		t.Fatalf("added = %+v", event.Added)
+	}
+	if len(event.Changed) != 1 || event.Changed[0].TokensBefore != 10 || event.Changed[0].TokensAfter != 12 ||
+		event.Changed[0].HashBefore == event.Changed[0].HashAfter {
+		t.Fatalf("changed = %+v", event.Changed)
+	}
+	if line := formatWatchEvent(event); !strings.Contains(line, "+sum_b d0 8t") || !strings.Contains(line, "~sum_a 10->12t") {
+		t.Fatalf("formatted event = %s", line)
+	}
+
+	mustExec(t, db, `DELETE FROM context_items WHERE summary_id = 'sum_b'`)
+	mustExec(t, db, `DELETE FROM summaries WHERE summary_id = 'sum_b'`)
+	event, ok, err = watcher.poll(ctx, now.Add(4*time.Second))
+	if err != nil || !ok || len(event.Removed) != 1 || event.Removed[0] != "sum_b" || event.SummariesDelta != -1 {
+		t.Fatalf("removal poll = %+v, %v, %v", event, ok, err)
+	}
+
+	// A control character is stripped for display, so only the raw hash
+	// sees this edit.
+	raw := "first leaf,\x07 rewritten"
+	if _, err := db.Exec(`UPDATE summaries SET content = ? WHERE summary_id = 'sum_a'`, raw); err != nil {
+		t.Fatalf("update summary: %v", err)
+	}
+	event, ok, err = watcher.poll(ctx, now.Add(6*time.Second))
+	if err != nil || !ok || len(event.Changed) != 1 || event.Changed[0].HashAfter != shortSHA256(raw) {
+		t.Fatalf("raw-only change poll = %+v, %v, %v", event, ok, err)
+	}
+}
+
+func TestParseWatchArgs(t *testing.T) {
+	opts, id, err := parseWatchArgs([]string{"--interval", "500ms", "44", "--json"})
+	if err != nil || id != 44 || !opts.jsonOutput || opts.interval != 500*time.Millisecond {
+		t.Fatalf("parse = %+v, %d, %v", opts, id, err)
+	}
+	if _, _, err := parseWatchArgs([]string{"44", "--interval", "10ms"}); err == nil {
+		t.Fatal("expected a too-short --interval to be rejected")
+	}
+}